@@ -0,0 +1,259 @@
+// Package aggregate rolls up the steps, agent labels, and plugins used by many pipelines into organisation-wide
+// statistics, for dashboards and migration planning.
+package aggregate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// StepPlugins maps well-known step names to the short name of the plugin providing them. Steps not listed here are
+// counted under UnknownPlugin.
+var StepPlugins = map[string]string{
+	"archiveArtifacts":         "core",
+	"fingerprint":              "core",
+	"bat":                      "workflow-durable-task-step",
+	"node":                     "workflow-durable-task-step",
+	"powershell":               "workflow-durable-task-step",
+	"pwsh":                     "workflow-durable-task-step",
+	"sh":                       "workflow-durable-task-step",
+	"ws":                       "workflow-durable-task-step",
+	"catchError":               "workflow-basic-steps",
+	"deleteDir":                "workflow-basic-steps",
+	"dir":                      "workflow-basic-steps",
+	"echo":                     "workflow-basic-steps",
+	"error":                    "workflow-basic-steps",
+	"fileExists":               "workflow-basic-steps",
+	"isUnix":                   "workflow-basic-steps",
+	"mail":                     "workflow-basic-steps",
+	"pwd":                      "workflow-basic-steps",
+	"readFile":                 "workflow-basic-steps",
+	"retry":                    "workflow-basic-steps",
+	"sleep":                    "workflow-basic-steps",
+	"stash":                    "workflow-basic-steps",
+	"timeout":                  "workflow-basic-steps",
+	"unstable":                 "workflow-basic-steps",
+	"unstash":                  "workflow-basic-steps",
+	"waitUntil":                "workflow-basic-steps",
+	"warnError":                "workflow-basic-steps",
+	"withEnv":                  "workflow-basic-steps",
+	"writeFile":                "workflow-basic-steps",
+	"build":                    "pipeline-build-step",
+	"input":                    "pipeline-input-step",
+	"milestone":                "pipeline-milestone-step",
+	"lock":                     "lockable-resources",
+	"checkout":                 "workflow-scm-step",
+	"git":                      "git",
+	"junit":                    "junit",
+	"script":                   "pipeline-model-definition",
+	"withCredentials":          "credentials-binding",
+	"sshagent":                 "ssh-agent",
+	"timestamps":               "timestamper",
+	"ansiColor":                "ansicolor",
+	"withMaven":                "pipeline-maven",
+	"cleanWs":                  "ws-cleanup",
+	"copyArtifacts":            "copyartifact",
+	"readJSON":                 "pipeline-utility-steps",
+	"readYaml":                 "pipeline-utility-steps",
+	"writeJSON":                "pipeline-utility-steps",
+	"writeYaml":                "pipeline-utility-steps",
+	"zip":                      "pipeline-utility-steps",
+	"unzip":                    "pipeline-utility-steps",
+	"slackSend":                "slack",
+	"emailext":                 "email-ext",
+	"publishHTML":              "htmlpublisher",
+	"recordIssues":             "warnings-ng",
+	"withDockerRegistry":       "docker-workflow",
+	"withKubeConfig":           "kubernetes-cli",
+	"container":                "kubernetes",
+	"properties":               "workflow-multibranch",
+	"library":                  "workflow-cps-global-lib",
+	"parallel":                 "workflow-cps",
+	"stage":                    "pipeline-stage-step",
+	"updateGitlabCommitStatus": "gitlab-plugin",
+}
+
+// UnknownPlugin is the plugin steps missing from StepPlugins are counted under
+const UnknownPlugin = "<unknown>"
+
+// Inventory is what a single pipeline uses
+type Inventory struct {
+	Name   string `json:"name"`
+	Stages int    `json:"stages"`
+	// Steps counts invocations of each step, including steps nested in other steps and in post conditions.
+	Steps map[string]int `json:"steps"`
+	// AgentLabels counts the stages, and the pipeline itself, declaring an agent with each label. Agents without a
+	// label are counted under their type, such as "any".
+	AgentLabels map[string]int `json:"agentLabels"`
+	// Plugins counts step invocations by the plugin providing the step.
+	Plugins map[string]int `json:"plugins"`
+}
+
+// Collect builds the inventory of a single pipeline.
+func Collect(name string, root *model.Root) (*Inventory, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{
+		Name:        name,
+		Steps:       map[string]int{},
+		AgentLabels: map[string]int{},
+		Plugins:     map[string]int{},
+	}
+	inv.agent(p.Agent)
+
+	var visitSteps func(steps []*plan.Step)
+	visitSteps = func(steps []*plan.Step) {
+		for _, st := range steps {
+			inv.Steps[st.Name]++
+			plugin, ok := StepPlugins[st.Name]
+			if !ok {
+				plugin = UnknownPlugin
+			}
+			inv.Plugins[plugin]++
+			visitSteps(st.Children)
+		}
+	}
+	var visitStage func(s *plan.Stage)
+	visitStage = func(s *plan.Stage) {
+		inv.Stages++
+		if s.Agent != nil && !s.Agent.Inherited {
+			inv.agent(s.Agent)
+		}
+		visitSteps(s.Steps)
+		for _, b := range s.Post {
+			visitSteps(b.Steps)
+		}
+		for _, c := range s.Children {
+			visitStage(c)
+		}
+	}
+	for _, s := range p.Stages {
+		visitStage(s)
+	}
+	for _, b := range p.Post {
+		visitSteps(b.Steps)
+	}
+	return inv, nil
+}
+
+func (strct *Inventory) agent(a *plan.Agent) {
+	switch {
+	case a == nil || a.Type == "none":
+	case a.Label != "":
+		strct.AgentLabels[a.Label]++
+	default:
+		strct.AgentLabels[a.Type]++
+	}
+}
+
+// Count is how much something is used across pipelines
+type Count struct {
+	Name string `json:"name"`
+	// Pipelines is the number of pipelines using it.
+	Pipelines int `json:"pipelines"`
+	// Total is the number of uses across all pipelines.
+	Total int `json:"total"`
+}
+
+// Rollup is the combined usage of many pipelines. Each list is sorted by Total, highest first, then by Name.
+type Rollup struct {
+	Pipelines   int      `json:"pipelines"`
+	Stages      int      `json:"stages"`
+	Steps       []*Count `json:"steps"`
+	AgentLabels []*Count `json:"agentLabels"`
+	Plugins     []*Count `json:"plugins"`
+}
+
+// Aggregate combines the inventories of many pipelines.
+func Aggregate(inventories []*Inventory) *Rollup {
+	r := &Rollup{}
+	steps, labels, plugins := map[string]*Count{}, map[string]*Count{}, map[string]*Count{}
+	for _, inv := range inventories {
+		if inv == nil {
+			continue
+		}
+		r.Pipelines++
+		r.Stages += inv.Stages
+		add(steps, inv.Steps)
+		add(labels, inv.AgentLabels)
+		add(plugins, inv.Plugins)
+	}
+	r.Steps = sorted(steps)
+	r.AgentLabels = sorted(labels)
+	r.Plugins = sorted(plugins)
+	return r
+}
+
+func add(into map[string]*Count, counts map[string]int) {
+	for name, n := range counts {
+		c, ok := into[name]
+		if !ok {
+			c = &Count{Name: name}
+			into[name] = c
+		}
+		c.Pipelines++
+		c.Total += n
+	}
+}
+
+func sorted(counts map[string]*Count) []*Count {
+	out := make([]*Count, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Top returns at most the first n counts.
+func Top(counts []*Count, n int) []*Count {
+	if n < len(counts) {
+		return counts[:n]
+	}
+	return counts
+}
+
+// WriteJSON writes the rollup as indented JSON.
+func (strct *Rollup) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(strct)
+}
+
+// WriteCSV writes the rollup as CSV, with one row per count and the columns category, name, pipelines, and total.
+// The category is one of "step", "agentLabel", or "plugin".
+func (strct *Rollup) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"category", "name", "pipelines", "total"}); err != nil {
+		return err
+	}
+	for _, section := range []struct {
+		category string
+		counts   []*Count
+	}{
+		{"step", strct.Steps},
+		{"agentLabel", strct.AgentLabels},
+		{"plugin", strct.Plugins},
+	} {
+		for _, c := range section.counts {
+			if err := cw.Write([]string{section.category, c.Name, strconv.Itoa(c.Pipelines), strconv.Itoa(c.Total)}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
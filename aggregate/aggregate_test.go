@@ -0,0 +1,79 @@
+package aggregate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, name string) *Inventory {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	inv, err := Collect(name, root)
+	require.NoError(t, err)
+	return inv
+}
+
+func TestCollect(t *testing.T) {
+	assert.Equal(t, &Inventory{
+		Name:        "agent/agentLabel",
+		Stages:      1,
+		Steps:       map[string]int{"script": 1},
+		AgentLabels: map[string]int{"some-label": 1},
+		Plugins:     map[string]int{"pipeline-model-definition": 1},
+	}, collect(t, "agent/agentLabel"))
+
+	_, err := Collect("empty", &model.Root{})
+	assert.Error(t, err)
+}
+
+func TestAggregate(t *testing.T) {
+	custom := &Inventory{
+		Name:        "custom",
+		Stages:      2,
+		Steps:       map[string]int{"sh": 3, "mystep": 1},
+		AgentLabels: map[string]int{"some-label": 2},
+		Plugins:     map[string]int{"workflow-durable-task-step": 3, UnknownPlugin: 1},
+	}
+	r := Aggregate([]*Inventory{collect(t, "agent/agentLabel"), collect(t, "agent/agentAny"), custom})
+	assert.Equal(t, 3, r.Pipelines)
+	assert.Equal(t, 4, r.Stages)
+	assert.Equal(t, []*Count{
+		{Name: "sh", Pipelines: 1, Total: 3},
+		{Name: "script", Pipelines: 2, Total: 2},
+		{Name: "mystep", Pipelines: 1, Total: 1},
+	}, r.Steps)
+	assert.Equal(t, []*Count{
+		{Name: "some-label", Pipelines: 2, Total: 3},
+		{Name: "any", Pipelines: 1, Total: 1},
+	}, r.AgentLabels)
+	assert.Equal(t, []*Count{{Name: "sh", Pipelines: 1, Total: 3}}, Top(r.Steps, 1))
+	assert.Len(t, Top(r.Plugins, 10), 3)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteCSV(&buf))
+	assert.Equal(t, `category,name,pipelines,total
+step,sh,1,3
+step,script,2,2
+step,mystep,1,1
+agentLabel,some-label,2,3
+agentLabel,any,1,1
+plugin,workflow-durable-task-step,1,3
+plugin,pipeline-model-definition,2,2
+plugin,<unknown>,1,1
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, r.WriteJSON(&buf))
+	decoded := &Rollup{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	assert.Equal(t, r, decoded)
+}
@@ -0,0 +1,76 @@
+// Package analysis inspects pipelines for problems that are only visible across stages, such as artifacts and
+// workspaces shared between them, and for how they use parameters and job-level settings.
+package analysis
+
+import (
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Finding is a problem reported by an analyzer
+type Finding struct {
+	// Rule identifies the kind of problem.
+	Rule string `json:"rule"`
+	// Stage is the ID of the stage the problem was found in, if any.
+	Stage   string `json:"stage,omitempty"`
+	Message string `json:"message"`
+}
+
+// record reports findings to the metrics sink, counted by rule, and returns them
+func record(findings []*Finding) []*Finding {
+	for _, f := range findings {
+		metrics.Add(metrics.Findings, metrics.Labels{"rule": f.Rule}, 1)
+	}
+	return findings
+}
+
+// stepVisitor is called for each step in a stage, in order
+type stepVisitor func(stage *plan.Stage, step *plan.Step)
+
+// visitSteps visits the steps of every stage in the plan, in execution order, including those in the post sections of
+// stages and their matrices, and then those in the pipeline's post section, with a stage with an empty ID. Steps
+// nested in tree steps are visited after their enclosing step.
+func visitSteps(p *plan.Plan, fn stepVisitor) {
+	var visitStage func(s *plan.Stage)
+	var visit func(s *plan.Stage, steps []*plan.Step)
+	visit = func(s *plan.Stage, steps []*plan.Step) {
+		for _, st := range steps {
+			fn(s, st)
+			visit(s, st.Children)
+		}
+	}
+	visitStage = func(s *plan.Stage) {
+		visit(s, s.Steps)
+		for _, c := range s.Children {
+			visitStage(c)
+		}
+		// A matrix's post section runs for each cell, before the stage's own.
+		for _, b := range s.MatrixPost {
+			visit(s, b.Steps)
+		}
+		for _, b := range s.Post {
+			visit(s, b.Steps)
+		}
+	}
+	for _, s := range p.Stages {
+		visitStage(s)
+	}
+	pipeline := &plan.Stage{Agent: p.Agent, Environment: p.Environment}
+	for _, b := range p.Post {
+		visit(pipeline, b.Steps)
+	}
+}
+
+// concurrent returns true if the two stages may run at the same time, because they are in different branches of a
+// parallel or matrix stage.
+func concurrent(p *plan.Plan, a, b *plan.Stage) bool {
+	n := 0
+	for n < len(a.Path) && n < len(b.Path) && a.Path[n] == b.Path[n] {
+		n++
+	}
+	if n == len(a.Path) || n == len(b.Path) || n == 0 {
+		return false
+	}
+	parent := p.Find(plan.ID(a.Path[:n]))
+	return parent != nil && (parent.ChildMode == plan.Parallel || parent.ChildMode == plan.Matrix)
+}
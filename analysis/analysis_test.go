@@ -0,0 +1,19 @@
+package analysis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, name string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// ArtifactKind is the kind of artifact step
+type ArtifactKind string
+
+const (
+	// Stash is a stash step, saving files for later stages of the same build
+	Stash ArtifactKind = "stash"
+	// Unstash is an unstash step, restoring files stashed earlier in the build
+	Unstash ArtifactKind = "unstash"
+	// Archive is an archiveArtifacts or archive step, saving files with the build record
+	Archive ArtifactKind = "archive"
+	// Copy is a copyArtifacts step, fetching files archived by another job
+	Copy ArtifactKind = "copy"
+	// Fingerprint is a fingerprint step, or an archiveArtifacts step with fingerprint set, recording checksums of
+	// files so their use can be traced across jobs
+	Fingerprint ArtifactKind = "fingerprint"
+)
+
+// ArtifactUse is a single artifact step
+type ArtifactUse struct {
+	Kind ArtifactKind `json:"kind"`
+	// Stage is the ID of the stage the step is in. It is empty for steps in the pipeline's post section.
+	Stage string `json:"stage"`
+	// Name is the stash name for stash and unstash, the file pattern for archive and fingerprint, and the source
+	// project for copy.
+	Name string `json:"name"`
+
+	stage *plan.Stage
+	order int
+}
+
+// ArtifactEdge is a flow of artifacts from one stage, or another pipeline, into a stage
+type ArtifactEdge struct {
+	// From is the ID of the stage the files come from, or "project:<name>" for files copied from another job.
+	From string `json:"from"`
+	// To is the ID of the stage the files are used in, or empty for the pipeline's post section.
+	To   string `json:"to"`
+	Name string `json:"name"`
+}
+
+// ArtifactFlow is the artifact usage of a pipeline and the flows between its stages
+type ArtifactFlow struct {
+	Uses     []*ArtifactUse  `json:"uses"`
+	Edges    []*ArtifactEdge `json:"edges"`
+	Findings []*Finding      `json:"findings,omitempty"`
+}
+
+// Artifacts finds every stash, unstash, archiveArtifacts, copyArtifacts, and fingerprint step in the pipeline,
+// including those in post sections, and builds the graph of artifact flows between stages. It reports unstash steps
+// with no stash of that name (unstash-without-stash), or only with stashes that can't have run yet
+// (unstash-before-stash), and stashes which are never unstashed (unused-stash).
+func Artifacts(root *model.Root) (*ArtifactFlow, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	flow := &ArtifactFlow{Uses: []*ArtifactUse{}, Edges: []*ArtifactEdge{}}
+
+	order := 0
+	visitSteps(p, func(stage *plan.Stage, step *plan.Step) {
+		order++
+		use := &ArtifactUse{Stage: stage.ID, stage: stage, order: order}
+		args := stepArguments(step)
+		switch step.Name {
+		case "stash":
+			use.Kind, use.Name = Stash, args.Get("name").String()
+		case "unstash":
+			use.Kind, use.Name = Unstash, args.Get("name").String()
+		case "archiveArtifacts":
+			use.Kind, use.Name = Archive, args.Get("artifacts").String()
+			// A single unnamed argument is the artifacts, whatever key it's asked for.
+			if args != nil && args.Single == nil && args.Get("fingerprint").String() == "true" {
+				flow.Uses = append(flow.Uses, use)
+				use = &ArtifactUse{Kind: Fingerprint, Stage: stage.ID, Name: use.Name, stage: stage, order: order}
+			}
+		case "fingerprint":
+			use.Kind, use.Name = Fingerprint, args.Get("targets").String()
+		case "archive":
+			use.Kind, use.Name = Archive, args.Get("includes").String()
+		case "copyArtifacts":
+			use.Kind, use.Name = Copy, args.Get("projectName").String()
+		default:
+			return
+		}
+		flow.Uses = append(flow.Uses, use)
+	})
+
+	unstashed := make(map[*ArtifactUse]bool)
+	for _, u := range flow.Uses {
+		switch u.Kind {
+		case Copy:
+			flow.Edges = append(flow.Edges, &ArtifactEdge{From: "project:" + u.Name, To: u.Stage, Name: u.Name})
+		case Unstash:
+			found, before := false, false
+			for _, s := range flow.Uses {
+				if s.Kind != Stash || s.Name != u.Name {
+					continue
+				}
+				found = true
+				unstashed[s] = true
+				if s.order < u.order && !concurrent(p, s.stage, u.stage) {
+					before = true
+					if s.Stage != u.Stage {
+						flow.Edges = append(flow.Edges, &ArtifactEdge{From: s.Stage, To: u.Stage, Name: u.Name})
+					}
+				}
+			}
+			switch {
+			case !found:
+				flow.Findings = append(flow.Findings, &Finding{Rule: "unstash-without-stash", Stage: u.Stage,
+					Message: fmt.Sprintf("unstash %q has no matching stash", u.Name)})
+			case !before:
+				flow.Findings = append(flow.Findings, &Finding{Rule: "unstash-before-stash", Stage: u.Stage,
+					Message: fmt.Sprintf("unstash %q may run before any matching stash has completed", u.Name)})
+			}
+		}
+	}
+	for _, u := range flow.Uses {
+		if u.Kind == Stash && !unstashed[u] {
+			flow.Findings = append(flow.Findings, &Finding{Rule: "unused-stash", Stage: u.Stage,
+				Message: fmt.Sprintf("stash %q is never unstashed", u.Name)})
+		}
+	}
+	record(flow.Findings)
+	return flow, nil
+}
+
+// stepArguments returns the arguments of a step or tree step
+func stepArguments(step *plan.Step) *model.ArgumentList {
+	switch {
+	case step.Source == nil:
+	case step.Source.Step != nil:
+		return step.Source.Step.Arguments
+	case step.Source.Tree != nil:
+		return step.Source.Tree.Arguments
+	}
+	return nil
+}
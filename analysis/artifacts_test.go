@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifacts(t *testing.T) {
+	flow, err := Artifacts(loadRoot(t, "artifacts"))
+	require.NoError(t, err)
+
+	assert.Len(t, flow.Uses, 9)
+	assert.Equal(t, []*ArtifactEdge{
+		{From: "project:upstream", To: "Build", Name: "upstream"},
+		{From: "Build", To: "Test/Unit", Name: "binaries"},
+		{From: "Test/Unit", To: "Publish", Name: "reports"},
+	}, flow.Edges)
+	assert.Equal(t, []*Finding{
+		{Rule: "unstash-before-stash", Stage: "Test/Integration", Message: `unstash "reports" may run before any matching stash has completed`},
+		{Rule: "unstash-without-stash", Stage: "Test/Integration", Message: `unstash "missing" has no matching stash`},
+		{Rule: "unused-stash", Stage: "Build", Message: `stash "unused" is never unstashed`},
+	}, flow.Findings)
+}
+
+func TestArtifactsPostAndFingerprints(t *testing.T) {
+	flow, err := Artifacts(loadRoot(t, "fingerprints"))
+	require.NoError(t, err)
+
+	var uses []ArtifactUse
+	for _, u := range flow.Uses {
+		uses = append(uses, ArtifactUse{Kind: u.Kind, Stage: u.Stage, Name: u.Name})
+	}
+	assert.Equal(t, []ArtifactUse{
+		{Kind: Archive, Stage: "Build", Name: "bin/*"},
+		{Kind: Fingerprint, Stage: "Build", Name: "bin/*"},
+		{Kind: Stash, Stage: "Build", Name: "bin"},
+		{Kind: Unstash, Stage: "Test/Unit", Name: "bin"},
+		{Kind: Stash, Stage: "Test", Name: "reports"},
+		{Kind: Archive, Stage: "Test", Name: "logs/*"},
+		{Kind: Unstash, Stage: "", Name: "reports"},
+		{Kind: Fingerprint, Stage: "", Name: "reports/*.xml"},
+	}, uses)
+	assert.Equal(t, []*ArtifactEdge{
+		{From: "Build", To: "Test/Unit", Name: "bin"},
+		{From: "Test", To: "", Name: "reports"},
+	}, flow.Edges)
+	assert.Empty(t, flow.Findings)
+}
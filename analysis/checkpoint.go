@@ -0,0 +1,163 @@
+package analysis
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// CheckpointVersion is the version of the Checkpoint format, incremented when it changes incompatibly
+const CheckpointVersion = 1
+
+// Checkpoint is a manifest of a pipeline's leaf stages for orchestrators which retry failed parts of a pipeline
+// themselves: what each stage needs before it can run again, and what it produces for the stages after it.
+type Checkpoint struct {
+	Version int                `json:"version"`
+	Stages  []*CheckpointStage `json:"stages"`
+}
+
+// CheckpointStage is a single leaf stage of a Checkpoint
+type CheckpointStage struct {
+	ID string `json:"id"`
+	// Needs are the IDs of the stages which must finish before the stage starts, as from plan.Plan's Needs.
+	Needs []string `json:"needs"`
+	// Restart is the ID of the top-level stage Jenkins would restart the stage with, using "Restart from Stage".
+	Restart   string               `json:"restart"`
+	Inputs    *CheckpointInputs    `json:"inputs"`
+	Artifacts *CheckpointArtifacts `json:"artifacts"`
+}
+
+// CheckpointInputs is what a stage needs besides its workspace
+type CheckpointInputs struct {
+	// Parameters are the build parameters referenced by the stage or its environment.
+	Parameters []string `json:"parameters,omitempty"`
+	// Credentials are the IDs of the credentials bound in the stage's environment.
+	Credentials []string `json:"credentials,omitempty"`
+	// Artifacts are the stashes unstashed from earlier stages, and the files copied from other jobs.
+	Artifacts []*ArtifactEdge `json:"artifacts,omitempty"`
+	// Approval is true if the stage, or a stage enclosing it, waits for an input to be answered.
+	Approval bool `json:"approval,omitempty"`
+}
+
+// CheckpointArtifacts is what a stage produces
+type CheckpointArtifacts struct {
+	// Stashes are the names of the stashes the stage saves.
+	Stashes []string `json:"stashes,omitempty"`
+	// Archives are the file patterns the stage archives with the build.
+	Archives []string `json:"archives,omitempty"`
+}
+
+// CheckpointManifest returns the checkpoint manifest of the pipeline. It returns an error if the pipeline's stage
+// dependencies form a cycle.
+func CheckpointManifest(root *model.Root) (*Checkpoint, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	needs, err := p.Needs()
+	if err != nil {
+		return nil, err
+	}
+	flow, err := Artifacts(root)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Checkpoint{Version: CheckpointVersion, Stages: []*CheckpointStage{}}
+	for _, s := range p.Leaves() {
+		c := &CheckpointStage{ID: s.ID, Needs: needs[s.ID], Restart: s.Path[0], Inputs: &CheckpointInputs{},
+			Artifacts: &CheckpointArtifacts{}}
+		for i := range s.Path {
+			if enclosing := p.Find(plan.ID(s.Path[:i+1])); enclosing != nil && enclosing.Input != nil {
+				c.Inputs.Approval = true
+			}
+		}
+
+		params := map[string]bool{}
+		credentials := map[string]bool{}
+		for _, e := range s.Environment {
+			if e.Credential != "" {
+				credentials[e.Credential] = true
+			}
+			addParams(params, e.Value)
+		}
+		if b, err := json.Marshal(s.Source); err == nil {
+			var v interface{}
+			if json.Unmarshal(b, &v) == nil {
+				visitStrings(v, func(str string) {
+					addParams(params, str)
+				})
+			}
+		}
+		c.Inputs.Parameters = sortedKeys(params)
+		c.Inputs.Credentials = sortedKeys(credentials)
+
+		for _, e := range flow.Edges {
+			if e.To == s.ID {
+				c.Inputs.Artifacts = append(c.Inputs.Artifacts, e)
+			}
+		}
+		for _, u := range flow.Uses {
+			switch {
+			case u.Stage != s.ID:
+			case u.Kind == Stash:
+				c.Artifacts.Stashes = append(c.Artifacts.Stashes, u.Name)
+			case u.Kind == Archive:
+				c.Artifacts.Archives = append(c.Artifacts.Archives, u.Name)
+			}
+		}
+		out.Stages = append(out.Stages, c)
+	}
+	return out, nil
+}
+
+// Rerun returns the IDs of the stages to run again after the given stages failed: the failed stages and every stage
+// which needs one of them, directly or indirectly, in the manifest's order.
+func (strct *Checkpoint) Rerun(failed ...string) []string {
+	rerun := map[string]bool{}
+	for _, id := range failed {
+		rerun[id] = true
+	}
+	// Stages are in execution order, but dependsOn can make a stage need a later one, so repeat until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for _, s := range strct.Stages {
+			if rerun[s.ID] {
+				continue
+			}
+			for _, n := range s.Needs {
+				if rerun[n] {
+					rerun[s.ID], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	out := []string{}
+	for _, s := range strct.Stages {
+		if rerun[s.ID] {
+			out = append(out, s.ID)
+		}
+	}
+	return out
+}
+
+func addParams(params map[string]bool, s string) {
+	for _, m := range paramRef.FindAllStringSubmatch(s, -1) {
+		params[m[1]+m[2]] = true
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
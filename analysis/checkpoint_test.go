@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointManifest(t *testing.T) {
+	c, err := CheckpointManifest(loadRoot(t, "checkpoint"))
+	require.NoError(t, err)
+	assert.Equal(t, CheckpointVersion, c.Version)
+
+	stages := map[string]*CheckpointStage{}
+	var ids []string
+	for _, s := range c.Stages {
+		stages[s.ID] = s
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"Build", "Test/Unit", "Test/Lint", "Deploy/Upload"}, ids)
+
+	assert.Equal(t, &CheckpointStage{ID: "Build", Needs: []string{}, Restart: "Build",
+		Inputs:    &CheckpointInputs{Parameters: []string{"VERSION"}, Credentials: []string{"deploy-key"}},
+		Artifacts: &CheckpointArtifacts{Stashes: []string{"binaries"}, Archives: []string{"bin/**"}}}, stages["Build"])
+	assert.Equal(t, []*ArtifactEdge{{From: "Build", To: "Test/Unit", Name: "binaries"}},
+		stages["Test/Unit"].Inputs.Artifacts)
+	assert.Equal(t, "Test", stages["Test/Lint"].Restart)
+	assert.False(t, stages["Test/Lint"].Inputs.Approval)
+
+	upload := stages["Deploy/Upload"]
+	assert.Equal(t, []string{"Test/Lint", "Test/Unit"}, upload.Needs)
+	assert.Equal(t, "Deploy", upload.Restart)
+	assert.True(t, upload.Inputs.Approval)
+	assert.Equal(t, []string{"TARGET"}, upload.Inputs.Parameters)
+}
+
+func TestCheckpointRerun(t *testing.T) {
+	c, err := CheckpointManifest(loadRoot(t, "checkpoint"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test/Lint", "Deploy/Upload"}, c.Rerun("Test/Lint"))
+	assert.Equal(t, []string{"Build", "Test/Unit", "Test/Lint", "Deploy/Upload"}, c.Rerun("Build"))
+	assert.Equal(t, []string{"Deploy/Upload"}, c.Rerun("Deploy/Upload"))
+	assert.Equal(t, []string{}, c.Rerun())
+}
@@ -0,0 +1,90 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+// ContextCoverage lists which stages run for one context
+type ContextCoverage struct {
+	Context string `json:"context"`
+	// Run are the IDs of stages whose when conditions, and those of their enclosing stages, are all met.
+	Run []string `json:"run"`
+	// Maybe are the IDs of stages whose conditions can't be decided from the context.
+	Maybe []string `json:"maybe,omitempty"`
+	// Skipped are the IDs of stages which don't run.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// WhenCoverage is the result of evaluating a pipeline's when conditions against several contexts
+type WhenCoverage struct {
+	Contexts []*ContextCoverage `json:"contexts"`
+	// Unreachable are the IDs of stages skipped in every context.
+	Unreachable []string   `json:"unreachable,omitempty"`
+	Findings    []*Finding `json:"findings,omitempty"`
+}
+
+// Coverage evaluates the when conditions of every stage against each of the given contexts, such as a list of
+// branches or sets of changed files, and reports which stages would run under each. Stages which are skipped under
+// all of them are reported as unreachable-stage, once for the outermost such stage, as they are likely dead code
+// caused by contradictory conditions.
+func Coverage(root *model.Root, contexts []*when.Context) (*WhenCoverage, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	cov := &WhenCoverage{}
+	reachable := make(map[string]bool)
+	for _, ctx := range contexts {
+		cc := &ContextCoverage{Context: ctx.Name, Run: []string{}}
+		var visit func(stages []*plan.Stage, parent when.Result)
+		visit = func(stages []*plan.Stage, parent when.Result) {
+			for _, s := range stages {
+				result := parent
+				if result != when.False {
+					switch when.Evaluate(s.When, ctx) {
+					case when.False:
+						result = when.False
+					case when.Unknown:
+						result = when.Unknown
+					}
+				}
+				switch result {
+				case when.True:
+					cc.Run = append(cc.Run, s.ID)
+				case when.Unknown:
+					cc.Maybe = append(cc.Maybe, s.ID)
+				default:
+					cc.Skipped = append(cc.Skipped, s.ID)
+				}
+				if result != when.False {
+					reachable[s.ID] = true
+				}
+				visit(s.Children, result)
+			}
+		}
+		visit(p.Stages, when.True)
+		cov.Contexts = append(cov.Contexts, cc)
+	}
+
+	if len(contexts) > 0 {
+		var visit func(stages []*plan.Stage)
+		visit = func(stages []*plan.Stage) {
+			for _, s := range stages {
+				if !reachable[s.ID] {
+					cov.Unreachable = append(cov.Unreachable, s.ID)
+					cov.Findings = append(cov.Findings, &Finding{Rule: "unreachable-stage", Stage: s.ID,
+						Message: fmt.Sprintf("stage is skipped in all %d contexts", len(contexts))})
+					continue
+				}
+				visit(s.Children)
+			}
+		}
+		visit(p.Stages)
+	}
+	record(cov.Findings)
+	return cov, nil
+}
@@ -0,0 +1,27 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/when"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	cov, err := Coverage(loadRoot(t, "coverage"), []*when.Context{
+		{Name: "main", Branch: "main", ChangedFiles: []string{"src/main.go"}},
+		{Name: "release", Branch: "release-1.0", ChangedFiles: []string{"docs/guide/index.md"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*ContextCoverage{
+		{Context: "main", Run: []string{"Build"}, Skipped: []string{"Docs", "Release", "Release/Publish", "Dead", "Dead/Inner"}},
+		{Context: "release", Run: []string{"Build", "Docs", "Release"}, Maybe: []string{"Release/Publish"},
+			Skipped: []string{"Dead", "Dead/Inner"}},
+	}, cov.Contexts)
+	assert.Equal(t, []string{"Dead"}, cov.Unreachable)
+	assert.Equal(t, []*Finding{
+		{Rule: "unreachable-stage", Stage: "Dead", Message: "stage is skipped in all 2 contexts"},
+	}, cov.Findings)
+}
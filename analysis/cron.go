@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/cron"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// cronSpecs maps the triggers taking cron specs to the argument holding the spec
+var cronSpecs = map[string]string{"cron": "spec", "pollSCM": "scmpoll_spec"}
+
+// CronCheck checks the cron and pollSCM triggers of a pipeline over the year after From
+type CronCheck struct {
+	// Seed is the job's full name, from which Jenkins picks the values of H.
+	Seed string
+	// Controller is the controller's time zone, used by specs without a TZ= line. Nil is UTC.
+	Controller *time.Location
+	// Expected is the time zone the pipeline's authors think the specs are in, or nil if it's not known.
+	Expected *time.Location
+	// From is the start of the year checked. The zero time is now.
+	From time.Time
+}
+
+// Check parses the specs of the pipeline's cron and pollSCM triggers, reporting those that are invalid as
+// cron-invalid, those whose fire times are skipped or repeated by daylight saving changes as cron-dst, and those
+// without a TZ= line whose fire times move by an hour in the Expected zone during the year as cron-drift.
+func (c CronCheck) Check(root *model.Root) ([]*Finding, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	controller := c.Controller
+	if controller == nil {
+		controller = time.UTC
+	}
+	from := c.From
+	if from.IsZero() {
+		from = time.Now()
+	}
+	from = from.In(controller)
+	to := from.AddDate(1, 0, 0)
+
+	if root.Pipeline.Triggers == nil {
+		return nil, nil
+	}
+	var findings []*Finding
+	for _, t := range root.Pipeline.Triggers.Triggers {
+		if t == nil || cronSpecs[t.Name] == "" {
+			continue
+		}
+		spec, ok := callArgs(t, cronSpecs[t.Name])[cronSpecs[t.Name]]
+		if !ok {
+			continue
+		}
+		schedule, err := cron.Parse(spec, c.Seed)
+		if err != nil {
+			findings = append(findings, &Finding{Rule: "cron-invalid",
+				Message: fmt.Sprintf("%s trigger spec %q is invalid: %s", t.Name, spec, err)})
+			continue
+		}
+		for _, e := range schedule.Entries {
+			for _, s := range e.Shifts(from, to) {
+				what := "are repeated, so they fire twice"
+				if s.Skipped {
+					what = "are skipped, so they don't fire"
+				}
+				findings = append(findings, &Finding{Rule: "cron-dst",
+					Message: fmt.Sprintf("%s trigger %q: on %s, fire times %s in %s %s", t.Name, e.Spec,
+						s.At.Format("2006-01-02"), strings.Join(s.Times, ", "), s.At.Location(), what)})
+			}
+			if e.Location == nil && c.Expected != nil && offsetChanges(controller, c.Expected, from, to) {
+				findings = append(findings, &Finding{Rule: "cron-drift",
+					Message: fmt.Sprintf("%s trigger %q has no TZ= line, so it runs in %s and moves by an hour in %s "+
+						"when daylight saving time starts or ends; add TZ=%s", t.Name, e.Spec, controller, c.Expected,
+						c.Expected)})
+			}
+		}
+	}
+	return record(findings), nil
+}
+
+// offsetChanges returns whether the difference between two zones' UTC offsets changes between from and to
+func offsetChanges(a, b *time.Location, from, to time.Time) bool {
+	diff := func(t time.Time) int {
+		_, ao := t.In(a).Zone()
+		_, bo := t.In(b).Zone()
+		return ao - bo
+	}
+	first := diff(from)
+	for t := from; t.Before(to); t = t.Add(24 * time.Hour) {
+		if diff(t) != first {
+			return true
+		}
+	}
+	return false
+}
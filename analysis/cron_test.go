@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronCheck(t *testing.T) {
+	root := loadRoot(t, "cron")
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	check := CronCheck{Seed: "folder/job", Expected: london, From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	findings, err := check.Check(root)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+	assert.Equal(t, &Finding{Rule: "cron-dst", Message: `cron trigger "30 2 * * *": on 2024-03-10, fire times ` +
+		`02:30 in America/New_York are skipped, so they don't fire`}, findings[0])
+	assert.Equal(t, &Finding{Rule: "cron-drift", Message: `cron trigger "0 22 * * 1-5" has no TZ= line, so it runs ` +
+		`in UTC and moves by an hour in Europe/London when daylight saving time starts or ends; add ` +
+		`TZ=Europe/London`}, findings[1])
+	assert.Equal(t, "cron-invalid", findings[2].Rule)
+	assert.Contains(t, findings[2].Message, "expected 5 fields but found 4")
+
+	// In the expected zone, the spec without TZ= no longer drifts.
+	check.Controller = london
+	findings, err = check.Check(root)
+	require.NoError(t, err)
+	assert.Len(t, findings, 2)
+
+	_, err = check.Check(nil)
+	assert.Error(t, err)
+}
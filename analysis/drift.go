@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// absent is how a setting missing from a branch is described in drift findings
+const absent = "<none>"
+
+// Drift compares the job-level settings of the pipelines from several branches of a multibranch project, keyed by
+// branch name, and reports each parameter (parameter-drift), trigger (trigger-drift), and option (option-drift) which
+// isn't declared identically in all of them. Jenkins applies these settings from whichever branch built last, so
+// differences between branches cause the job's configuration to change back and forth.
+func Drift(branches map[string]*model.Root) ([]*Finding, error) {
+	names := make([]string, 0, len(branches))
+	for name, root := range branches {
+		if root == nil || root.Pipeline == nil {
+			return nil, fmt.Errorf("branch %q has no pipeline", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []*Finding
+	compare := func(rule string, kind string, settings func(p *model.Pipeline) []*model.MethodCall,
+		key func(call *model.MethodCall) string) {
+		values := make(map[string]map[string]string)
+		var keys []string
+		for _, branch := range names {
+			for _, call := range settings(branches[branch].Pipeline) {
+				if call == nil {
+					continue
+				}
+				k := key(call)
+				if values[k] == nil {
+					values[k] = make(map[string]string)
+					keys = append(keys, k)
+				}
+				values[k][branch] = describeCall(call)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var per []string
+			differs := false
+			for _, branch := range names {
+				v, ok := values[k][branch]
+				if !ok {
+					v = absent
+				}
+				if v != values[k][names[0]] || !ok {
+					differs = true
+				}
+				per = append(per, fmt.Sprintf("%s: %s", branch, v))
+			}
+			if differs {
+				findings = append(findings, &Finding{Rule: rule,
+					Message: fmt.Sprintf("%s %q differs between branches (%s)", kind, k, strings.Join(per, "; "))})
+			}
+		}
+	}
+
+	compare("parameter-drift", "parameter", func(p *model.Pipeline) []*model.MethodCall {
+		if p.Parameters == nil {
+			return nil
+		}
+		return p.Parameters.Parameters
+	}, func(call *model.MethodCall) string {
+		if name := call.Args().Get("name").String(); name != "" {
+			return name
+		}
+		return call.Name
+	})
+	compare("trigger-drift", "trigger", func(p *model.Pipeline) []*model.MethodCall {
+		if p.Triggers == nil {
+			return nil
+		}
+		return p.Triggers.Triggers
+	}, func(call *model.MethodCall) string {
+		return call.Name
+	})
+	compare("option-drift", "option", func(p *model.Pipeline) []*model.MethodCall {
+		if p.Options == nil {
+			return nil
+		}
+		return p.Options.Options
+	}, func(call *model.MethodCall) string {
+		return call.Name
+	})
+	return record(findings), nil
+}
+
+// describeCall renders a method call much as it would be written in a Jenkinsfile
+func describeCall(call *model.MethodCall) string {
+	var args []string
+	for _, a := range call.Arguments {
+		switch {
+		case a == nil:
+		case a.WithKey != nil:
+			args = append(args, a.WithKey.Key+": "+describeValue(a.WithKey.Value))
+		case a.Single != nil:
+			args = append(args, describeValue(a.Single))
+		}
+	}
+	return call.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func describeValue(v *model.ValueOrMethodCall) string {
+	switch {
+	case v == nil:
+		return ""
+	case v.Single == nil:
+		if v.Call != nil {
+			return describeCall(v.Call)
+		}
+		return ""
+	case v.Single.IsLiteral && v.Single.Value != nil && v.Single.Value.AsString != nil:
+		return fmt.Sprintf("%q", v.Single.String())
+	}
+	return v.Single.String()
+}
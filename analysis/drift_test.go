@@ -0,0 +1,29 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrift(t *testing.T) {
+	findings, err := Drift(map[string]*model.Root{
+		"main":    loadRoot(t, "drift-main"),
+		"feature": loadRoot(t, "drift-feature"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Finding{
+		{Rule: "parameter-drift", Message: `parameter "VERSION" differs between branches (` +
+			`feature: string(name: "VERSION", defaultValue: "2.0"); main: string(name: "VERSION", defaultValue: "1.0"))`},
+		{Rule: "option-drift", Message: `option "disableConcurrentBuilds" differs between branches (` +
+			`feature: <none>; main: disableConcurrentBuilds())`},
+	}, findings)
+}
+
+func TestDriftMissingPipeline(t *testing.T) {
+	_, err := Drift(map[string]*model.Root{"main": {}})
+	assert.EqualError(t, err, `branch "main" has no pipeline`)
+}
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"sigs.k8s.io/yaml"
+)
+
+// VersionKind is the kind of version a library is loaded at
+type VersionKind string
+
+const (
+	// VersionDefault is no version, loading the library's default version as configured in Jenkins
+	VersionDefault VersionKind = "default"
+	// VersionCommit is a commit hash
+	VersionCommit VersionKind = "commit"
+	// VersionRelease is a release tag, such as 1.2.3 or v1.2
+	VersionRelease VersionKind = "release"
+	// VersionBranch is anything else, which is usually a branch
+	VersionBranch VersionKind = "branch"
+	// VersionDynamic is a version chosen when the pipeline runs, from a Groovy expression
+	VersionDynamic VersionKind = "dynamic"
+)
+
+// Library is a shared library identifier from the libraries directive
+type Library struct {
+	// ID is the identifier as written, such as "build-utils@1.2.0".
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Kind    VersionKind `json:"kind"`
+	// Pinned is true if the version always loads the same code: a commit or a release tag.
+	Pinned bool `json:"pinned"`
+}
+
+// LibraryReport is the libraries a pipeline loads and the problems with them
+type LibraryReport struct {
+	Libraries []*Library `json:"libraries"`
+	Findings  []*Finding `json:"findings,omitempty"`
+}
+
+// LibraryManifest is the versions of each shared library available, keyed by library name. Commits may be listed
+// abbreviated or in full.
+type LibraryManifest map[string][]string
+
+var (
+	libraryName    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	libraryVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/+-]*$`)
+	commitVersion  = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+	releaseVersion = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*([-+][A-Za-z0-9.-]+)?$`)
+)
+
+// ParseLibraryManifest parses a YAML or JSON library manifest.
+func ParseLibraryManifest(b []byte) (LibraryManifest, error) {
+	m := LibraryManifest{}
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseLibrary parses a library identifier, name@version or just name. It returns an error if the name or version
+// isn't valid.
+func ParseLibrary(id string) (*Library, error) {
+	lib := &Library{ID: id, Name: id, Kind: VersionDefault}
+	if i := strings.Index(id, "@"); i >= 0 {
+		lib.Name, lib.Version = id[:i], id[i+1:]
+		if !libraryVersion.MatchString(lib.Version) || strings.Contains(lib.Version, "..") ||
+			strings.HasSuffix(lib.Version, "/") || strings.HasSuffix(lib.Version, ".lock") {
+			return nil, fmt.Errorf("library %s: invalid version %q", id, lib.Version)
+		}
+	}
+	if !libraryName.MatchString(lib.Name) {
+		return nil, fmt.Errorf("library %s: invalid name %q", id, lib.Name)
+	}
+	switch {
+	case lib.Version == "":
+	case commitVersion.MatchString(lib.Version):
+		lib.Kind, lib.Pinned = VersionCommit, true
+	case releaseVersion.MatchString(lib.Version):
+		lib.Kind, lib.Pinned = VersionRelease, true
+	default:
+		lib.Kind = VersionBranch
+	}
+	return lib, nil
+}
+
+// Libraries parses the identifiers in the pipeline's libraries directive. It reports identifiers which aren't valid
+// (invalid-library), and libraries loaded at their default version, a branch, or a version chosen at run time
+// (unpinned-library). If manifest isn't nil, it also reports libraries which aren't in it (unknown-library), and
+// versions which aren't (unknown-library-version).
+func Libraries(root *model.Root, manifest LibraryManifest) (*LibraryReport, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	report := &LibraryReport{Libraries: []*Library{}}
+	if root.Pipeline.Libraries == nil {
+		return report, nil
+	}
+	for _, raw := range root.Pipeline.Libraries.Libraries {
+		if raw == nil {
+			continue
+		}
+		id := raw.String()
+		if !raw.IsLiteral {
+			name := strings.Trim(id, `"'`)
+			if i := strings.Index(name, "@"); i >= 0 {
+				name = name[:i]
+			}
+			report.Libraries = append(report.Libraries, &Library{ID: id, Name: name, Kind: VersionDynamic})
+			report.Findings = append(report.Findings, &Finding{Rule: "unpinned-library",
+				Message: fmt.Sprintf("library %s is loaded at a version chosen when the pipeline runs", id)})
+			continue
+		}
+		lib, err := ParseLibrary(id)
+		if err != nil {
+			report.Findings = append(report.Findings, &Finding{Rule: "invalid-library", Message: err.Error()})
+			continue
+		}
+		report.Libraries = append(report.Libraries, lib)
+		switch lib.Kind {
+		case VersionDefault:
+			report.Findings = append(report.Findings, &Finding{Rule: "unpinned-library",
+				Message: fmt.Sprintf("library %s is loaded at its default version", id)})
+		case VersionBranch:
+			report.Findings = append(report.Findings, &Finding{Rule: "unpinned-library",
+				Message: fmt.Sprintf("library %s is loaded at branch %s rather than a release or commit", id,
+					lib.Version)})
+		}
+		if manifest == nil {
+			continue
+		}
+		versions, ok := manifest[lib.Name]
+		switch {
+		case !ok:
+			report.Findings = append(report.Findings, &Finding{Rule: "unknown-library",
+				Message: fmt.Sprintf("library %s isn't in the library manifest", lib.Name)})
+		case lib.Version != "" && !hasVersion(versions, lib):
+			report.Findings = append(report.Findings, &Finding{Rule: "unknown-library-version",
+				Message: fmt.Sprintf("library %s has no version %s", lib.Name, lib.Version)})
+		}
+	}
+	report.Findings = record(report.Findings)
+	return report, nil
+}
+
+// hasVersion returns true if the versions include the library's version. Commits match if either is an abbreviation
+// of the other.
+func hasVersion(versions []string, lib *Library) bool {
+	for _, v := range versions {
+		if v == lib.Version {
+			return true
+		}
+		if lib.Kind == VersionCommit && commitVersion.MatchString(v) &&
+			(strings.HasPrefix(v, lib.Version) || strings.HasPrefix(lib.Version, v)) {
+			return true
+		}
+	}
+	return false
+}
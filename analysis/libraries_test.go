@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLibrary(t *testing.T) {
+	for id, want := range map[string]*Library{
+		"utils":               {ID: "utils", Name: "utils", Kind: VersionDefault},
+		"utils@1.2.0":         {ID: "utils@1.2.0", Name: "utils", Version: "1.2.0", Kind: VersionRelease, Pinned: true},
+		"utils@v2-rc.1":       {ID: "utils@v2-rc.1", Name: "utils", Version: "v2-rc.1", Kind: VersionRelease, Pinned: true},
+		"utils@3f2a9c1":       {ID: "utils@3f2a9c1", Name: "utils", Version: "3f2a9c1", Kind: VersionCommit, Pinned: true},
+		"utils@feature/retry": {ID: "utils@feature/retry", Name: "utils", Version: "feature/retry", Kind: VersionBranch},
+	} {
+		lib, err := ParseLibrary(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, want, lib, id)
+	}
+
+	for id, msg := range map[string]string{
+		"utils@":          `library utils@: invalid version ""`,
+		"utils@a..b":      `library utils@a..b: invalid version "a..b"`,
+		"utils@x.lock":    `library utils@x.lock: invalid version "x.lock"`,
+		"utils@has space": `library utils@has space: invalid version "has space"`,
+		"@1.0":            `library @1.0: invalid name ""`,
+		"a@b@c":           `library a@b@c: invalid version "b@c"`,
+	} {
+		_, err := ParseLibrary(id)
+		assert.EqualError(t, err, msg, id)
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	root := loadRoot(t, "libraries")
+	report, err := Libraries(root, nil)
+	require.NoError(t, err)
+	assert.Len(t, report.Libraries, 6)
+	assert.Equal(t, &Library{ID: `"report@${env.REPORT_VERSION}"`, Name: "report", Kind: VersionDynamic},
+		report.Libraries[5])
+	assert.Equal(t, []*Finding{
+		{Rule: "unpinned-library", Message: "library deploy-utils is loaded at its default version"},
+		{Rule: "unpinned-library", Message: "library notify@main is loaded at branch main rather than a release or commit"},
+		{Rule: "invalid-library", Message: `library broken@feature/: invalid version "feature/"`},
+		{Rule: "unpinned-library",
+			Message: `library "report@${env.REPORT_VERSION}" is loaded at a version chosen when the pipeline runs`},
+	}, report.Findings)
+
+	manifest, err := ParseLibraryManifest([]byte("build-utils: [1.1.0, 1.2.0]\n" +
+		"notify: [main]\n" +
+		"scan: [3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39]\n"))
+	require.NoError(t, err)
+	report, err = Libraries(root, manifest)
+	require.NoError(t, err)
+	assert.Equal(t, []*Finding{
+		{Rule: "unpinned-library", Message: "library deploy-utils is loaded at its default version"},
+		{Rule: "unknown-library", Message: "library deploy-utils isn't in the library manifest"},
+		{Rule: "unpinned-library", Message: "library notify@main is loaded at branch main rather than a release or commit"},
+		{Rule: "unknown-library-version", Message: "library build-utils has no version v9"},
+		{Rule: "invalid-library", Message: `library broken@feature/: invalid version "feature/"`},
+		{Rule: "unpinned-library",
+			Message: `library "report@${env.REPORT_VERSION}" is loaded at a version chosen when the pipeline runs`},
+	}, report.Findings)
+
+	_, err = ParseLibraryManifest([]byte("- a\n"))
+	assert.Error(t, err)
+
+	report, err = Libraries(&model.Root{Pipeline: &model.Pipeline{}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &LibraryReport{Libraries: []*Library{}}, report)
+}
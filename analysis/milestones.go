@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+var (
+	// deployStage matches the names of stages which deploy or publish
+	deployStage = regexp.MustCompile(`(?i)deploy|release|publish|promot|rollout`)
+	// deployCommand matches script commands which deploy or publish
+	deployCommand = regexp.MustCompile(`\bkubectl\s+(apply|rollout|set\s+image)|\bhelm\s+(upgrade|install)|` +
+		`\bterraform\s+apply|\bdocker\s+push|\bmvn\b.*\bdeploy\b|\b(npm|yarn)\s+publish|\btwine\s+upload|` +
+		`\bgcloud\s+.*\bdeploy\b|\baws\s+(s3\s+sync|ecs\s+update-service|lambda\s+update-function-code)`)
+)
+
+// Milestones checks how the pipeline uses milestone steps to stop superseded builds. It reports milestones Jenkins
+// rejects: ones in parallel branches or matrix cells (milestone-parallel), and ones whose ordinals aren't increasing
+// (milestone-order). Unless disableConcurrentBuilds keeps builds from overlapping, it also reports stages which
+// deploy, found by their names and script commands, with no milestone before them (milestone-missing), or with an
+// input since the last milestone (milestone-input): an older build that gets there late could deploy over a newer one.
+func Milestones(p *plan.Plan) []*Finding {
+	var findings []*Finding
+	add := func(rule, stage, format string, args ...interface{}) {
+		findings = append(findings, &Finding{Rule: rule, Stage: stage, Message: fmt.Sprintf(format, args...)})
+	}
+	milestones := map[*plan.Step]*plan.Milestone{}
+	previous := 0
+	for _, m := range p.Milestones() {
+		milestones[m.Step] = m
+		switch {
+		case m.Concurrent:
+			add("milestone-parallel", m.Stage, "milestone %d is in a parallel branch or matrix cell, which fails the build",
+				m.Ordinal)
+		case m.Explicit && m.Ordinal <= previous:
+			add("milestone-order", m.Stage,
+				"milestone ordinal %d isn't greater than the previous milestone's ordinal %d, which fails the build",
+				m.Ordinal, previous)
+		}
+		previous = m.Ordinal
+	}
+	if p.Concurrency != nil && p.Concurrency.Serial {
+		return record(findings)
+	}
+
+	passed := false
+	input := ""
+	reported := map[string]bool{}
+	deploy := func(s *plan.Stage, what string) {
+		if reported[s.ID] {
+			return
+		}
+		switch {
+		case !passed:
+			reported[s.ID] = true
+			add("milestone-missing", s.ID, "%s with no milestone before it, so an older build could deploy after a "+
+				"newer one", what)
+		case input != "":
+			reported[s.ID] = true
+			add("milestone-input", s.ID, "%s after %s with no milestone since, so a superseded build approved late "+
+				"could still deploy", what, input)
+		}
+	}
+	var visitSteps func(s *plan.Stage, steps []*plan.Step)
+	visitSteps = func(s *plan.Stage, steps []*plan.Step) {
+		for _, st := range steps {
+			switch {
+			case milestones[st] != nil:
+				passed, input = true, ""
+			case st.Name == "input":
+				input = "the input step"
+			case st.Script != "" && deployCommand.MatchString(st.Script):
+				deploy(s, fmt.Sprintf("%s step runs %q", st.Name, deployCommand.FindString(st.Script)))
+			}
+			visitSteps(s, st.Children)
+		}
+	}
+	var visitStage func(s *plan.Stage)
+	visitStage = func(s *plan.Stage) {
+		if s.Input != nil {
+			input = "the stage's input"
+		}
+		visitSteps(s, s.Steps)
+		// Stages named for deploying are taken to deploy with their own steps, so a milestone among them is in time.
+		if len(s.Steps) > 0 && deployStage.MatchString(s.Name) {
+			deploy(s, "stage deploys")
+		}
+		for _, c := range s.Children {
+			visitStage(c)
+		}
+		for _, b := range s.Post {
+			visitSteps(s, b.Steps)
+		}
+	}
+	for _, s := range p.Stages {
+		visitStage(s)
+	}
+	return record(findings)
+}
@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMilestones(t *testing.T) {
+	root := loadRoot(t, "milestones")
+	p, err := plan.Build(root)
+	require.NoError(t, err)
+
+	milestones := p.Milestones()
+	require.Len(t, milestones, 3)
+	assert.Equal(t, &plan.Milestone{Stage: "Tests/Unit", Ordinal: 1, Explicit: true, Concurrent: true, Valid: true,
+		Step: milestones[0].Step}, milestones[0])
+	assert.Equal(t, "staging", milestones[1].Label)
+	assert.Equal(t, 2, milestones[2].Ordinal)
+	assert.False(t, milestones[2].Explicit)
+
+	assert.Equal(t, []*Finding{
+		{Rule: "milestone-parallel", Stage: "Tests/Unit",
+			Message: "milestone 1 is in a parallel branch or matrix cell, which fails the build"},
+		{Rule: "milestone-order", Stage: "Staging",
+			Message: "milestone ordinal 1 isn't greater than the previous milestone's ordinal 1, which fails the build"},
+		{Rule: "milestone-missing", Stage: "Build", Message: `sh step runs "docker push" with no milestone before ` +
+			"it, so an older build could deploy after a newer one"},
+		{Rule: "milestone-input", Stage: "Deploy to production", Message: "stage deploys after the stage's input with no " +
+			"milestone since, so a superseded build approved late could still deploy"},
+	}, Milestones(p))
+
+	root.Pipeline.Options = &model.Options{Options: []*model.MethodCall{{Name: "disableConcurrentBuilds"}}}
+	p, err = plan.Build(root)
+	require.NoError(t, err)
+	assert.Len(t, Milestones(p), 2)
+}
@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// DuplicateOptions reports options declared more than once in the same options directive (duplicate-option), for the
+// pipeline and each stage. Which of the duplicates Jenkins applies is undefined.
+func DuplicateOptions(root *model.Root) ([]*Finding, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	var findings []*Finding
+	for _, name := range root.Pipeline.Options.Duplicates() {
+		findings = append(findings, &Finding{Rule: "duplicate-option",
+			Message: fmt.Sprintf("option %s is declared more than once in the pipeline's options", name)})
+	}
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			for _, name := range s.Source.Options.Duplicates() {
+				findings = append(findings, &Finding{Rule: "duplicate-option", Stage: s.ID,
+					Message: fmt.Sprintf("option %s is declared more than once in the stage's options", name)})
+			}
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	return record(findings), nil
+}
@@ -0,0 +1,25 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicateOptions(t *testing.T) {
+	root := &model.Root{Pipeline: &model.Pipeline{
+		Options: &model.Options{Options: []*model.MethodCall{{Name: "timestamps"}, {Name: "timestamps"}}},
+		Stages: []*model.Stage{{Name: "Build", Stages: []*model.Stage{{
+			Name:    "Compile",
+			Options: &model.Options{Options: []*model.MethodCall{{Name: "retry"}, {Name: "timeout"}, {Name: "retry"}}},
+		}}}},
+	}}
+	findings, err := DuplicateOptions(root)
+	require.NoError(t, err)
+	assert.Equal(t, []*Finding{
+		{Rule: "duplicate-option", Message: "option timestamps is declared more than once in the pipeline's options"},
+		{Rule: "duplicate-option", Stage: "Build/Compile", Message: "option retry is declared more than once in the stage's options"},
+	}, findings)
+}
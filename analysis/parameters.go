@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// paramRef matches references to build parameters in Groovy strings and expressions, as params.NAME or
+// params['NAME'].
+var paramRef = regexp.MustCompile(`\bparams(?:\.([A-Za-z_][A-Za-z0-9_]*)|\[\s*['"]([^'"]+)['"]\s*\])`)
+
+// groovyListItem matches the quoted items of a Groovy list literal
+var groovyListItem = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'|"((?:[^"\\]|\\.)*)"`)
+
+// Parameters cross-checks the parameters declared in the pipeline's parameters directive against the references to
+// them anywhere else in the pipeline. It reports references to parameters which aren't declared
+// (undeclared-parameter), declared parameters which are never referenced (unused-parameter), and choice parameters
+// whose default value isn't one of the choices (choice-default-not-in-choices).
+func Parameters(root *model.Root) ([]*Finding, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	var findings []*Finding
+
+	declared := make(map[string]bool)
+	var names []string
+	if root.Pipeline.Parameters != nil {
+		for _, p := range root.Pipeline.Parameters.Parameters {
+			if p == nil {
+				continue
+			}
+			name := p.Args().Get("name").String()
+			if name == "" {
+				continue
+			}
+			if !declared[name] {
+				names = append(names, name)
+			}
+			declared[name] = true
+			if p.Name == "choice" {
+				findings = append(findings, checkChoice(name, p)...)
+			}
+		}
+	}
+
+	// Everything but the parameters directive itself is searched for references.
+	pipeline := *root.Pipeline
+	pipeline.Parameters = nil
+	b, err := json.Marshal(&pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	var undeclared []string
+	visitStrings(tree, func(s string) {
+		for _, m := range paramRef.FindAllStringSubmatch(s, -1) {
+			name := m[1] + m[2]
+			if !referenced[name] && !declared[name] {
+				undeclared = append(undeclared, name)
+			}
+			referenced[name] = true
+		}
+	})
+
+	for _, name := range undeclared {
+		findings = append(findings, &Finding{Rule: "undeclared-parameter",
+			Message: fmt.Sprintf("parameter %q is referenced but not declared in the parameters directive", name)})
+	}
+	for _, name := range names {
+		if !referenced[name] {
+			findings = append(findings, &Finding{Rule: "unused-parameter",
+				Message: fmt.Sprintf("parameter %q is declared but never referenced", name)})
+		}
+	}
+	return record(findings), nil
+}
+
+func checkChoice(name string, p *model.MethodCall) []*Finding {
+	def := p.Args().Get("defaultValue")
+	if def == nil || !def.IsLiteral {
+		return nil
+	}
+	choices, ok := choiceValues(p.Args().Get("choices"))
+	if !ok {
+		return nil
+	}
+	for _, c := range choices {
+		if c == def.String() {
+			return nil
+		}
+	}
+	return []*Finding{{Rule: "choice-default-not-in-choices",
+		Message: fmt.Sprintf("default value %q of choice parameter %q is not one of its choices: %s", def.String(), name,
+			strings.Join(choices, ", "))}}
+}
+
+// choiceValues returns the choices of a choice parameter, given either as a newline separated string or a Groovy list
+// of string literals. It returns false if the choices can't be determined statically.
+func choiceValues(arg *model.RawArgument) ([]string, bool) {
+	if arg == nil {
+		return nil, false
+	}
+	if arg.IsLiteral {
+		return strings.Split(arg.String(), "\n"), true
+	}
+	src := strings.TrimSpace(arg.String())
+	if !strings.HasPrefix(src, "[") || !strings.HasSuffix(src, "]") {
+		return nil, false
+	}
+	var out []string
+	for _, m := range groovyListItem.FindAllStringSubmatch(src, -1) {
+		out = append(out, m[1]+m[2])
+	}
+	return out, true
+}
+
+// visitStrings calls fn for every string value in a decoded JSON document, in a stable order
+func visitStrings(v interface{}, fn func(string)) {
+	switch t := v.(type) {
+	case string:
+		fn(t)
+	case []interface{}:
+		for _, e := range t {
+			visitStrings(e, fn)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			visitStrings(t[k], fn)
+		}
+	}
+}
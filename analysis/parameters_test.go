@@ -0,0 +1,19 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParameters(t *testing.T) {
+	findings, err := Parameters(loadRoot(t, "parameters"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Finding{
+		{Rule: "choice-default-not-in-choices", Message: `default value "qa" of choice parameter "TARGET" is not one of its choices: dev, staging, prod`},
+		{Rule: "undeclared-parameter", Message: `parameter "MISSING" is referenced but not declared in the parameters directive`},
+		{Rule: "unused-parameter", Message: `parameter "UNUSED" is declared but never referenced`},
+	}, findings)
+}
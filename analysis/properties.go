@@ -0,0 +1,309 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// JobProperty is a property Jenkins sets on a pipeline's job when the pipeline runs, as it appears in the job's
+// config.xml
+type JobProperty struct {
+	// Class is the property's element name in config.xml, such as "jenkins.model.BuildDiscarderProperty".
+	Class string `json:"class"`
+	// Directive is the directive the property comes from: "options", "parameters", or "triggers".
+	Directive string `json:"directive"`
+	// Settings are the property's settings which the pipeline determines, keyed by their path below the property's
+	// element. Elements holding a name are identified by it, as in "parameterDefinitions/
+	// hudson.model.StringParameterDefinition[VERSION]/defaultValue". Settings given by Groovy expressions are left
+	// out, since their values aren't known.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+const (
+	parametersProperty = "hudson.model.ParametersDefinitionProperty"
+	triggersProperty   = "org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty"
+)
+
+// optionProperties maps options to the job properties they set, and the settings each option argument maps to
+var optionProperties = map[string]struct {
+	class    string
+	settings map[string]string
+}{
+	"buildDiscarder": {"jenkins.model.BuildDiscarderProperty", map[string]string{
+		"numToKeepStr": "strategy/numToKeep", "daysToKeepStr": "strategy/daysToKeep",
+		"artifactNumToKeepStr": "strategy/artifactNumToKeep", "artifactDaysToKeepStr": "strategy/artifactDaysToKeep"}},
+	"copyArtifactPermission": {"hudson.plugins.copyartifact.CopyArtifactPermissionProperty",
+		map[string]string{"projectNames": "projectNameList/string"}},
+	"disableConcurrentBuilds": {"org.jenkinsci.plugins.workflow.job.properties.DisableConcurrentBuildsJobProperty",
+		map[string]string{"abortPrevious": "abortPrevious"}},
+	"disableResume": {"org.jenkinsci.plugins.workflow.job.properties.DisableResumeJobProperty", nil},
+	"durabilityHint": {"org.jenkinsci.plugins.workflow.job.properties.DurabilityHintJobProperty",
+		map[string]string{"hint": "hint"}},
+	"overrideIndexTriggers": {"jenkins.branch.OverrideIndexTriggersJobProperty",
+		map[string]string{"value": "enableTriggers"}},
+	"preserveStashes": {"org.jenkinsci.plugins.pipeline.modeldefinition.properties.PreserveStashesJobProperty",
+		map[string]string{"buildCount": "buildCount"}},
+	"rateLimitBuilds": {"jenkins.branch.RateLimitBranchProperty_-JobPropertyImpl",
+		map[string]string{"count": "count", "durationName": "durationName", "userBoost": "userBoost"}},
+}
+
+// triggerClasses maps triggers to their classes, and the settings each trigger argument maps to
+var triggerClasses = map[string]struct {
+	class    string
+	settings map[string]string
+}{
+	"cron":     {"hudson.triggers.TimerTrigger", map[string]string{"spec": "spec"}},
+	"pollSCM":  {"hudson.triggers.SCMTrigger", map[string]string{"scmpoll_spec": "spec"}},
+	"upstream": {"jenkins.triggers.ReverseBuildTrigger", map[string]string{"upstreamProjects": "upstreamProjects"}},
+}
+
+// parameterClasses maps parameter types to their classes
+var parameterClasses = map[string]string{
+	"booleanParam": "hudson.model.BooleanParameterDefinition",
+	"choice":       "hudson.model.ChoiceParameterDefinition",
+	"credentials":  "com.cloudbees.plugins.credentials.CredentialsParameterDefinition",
+	"file":         "hudson.model.FileParameterDefinition",
+	"password":     "hudson.model.PasswordParameterDefinition",
+	"run":          "hudson.model.RunParameterDefinition",
+	"string":       "hudson.model.StringParameterDefinition",
+	"text":         "hudson.model.TextParameterDefinition",
+}
+
+// defaultArgs are the arguments of options and triggers which can be given without a name
+var defaultArgs = map[string]string{
+	"cron": "spec", "pollSCM": "scmpoll_spec", "durabilityHint": "hint", "overrideIndexTriggers": "value",
+	"copyArtifactPermission": "projectNames", "upstream": "upstreamProjects",
+}
+
+// JobProperties returns the properties Jenkins sets on the job from the pipeline's options, parameters, and triggers,
+// sorted by class. Options which don't set job properties, such as timeout, are left out.
+func JobProperties(root *model.Root) ([]*JobProperty, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	p := root.Pipeline
+	var props []*JobProperty
+	if p.Options != nil {
+		for _, o := range p.Options.Options {
+			if o == nil {
+				continue
+			}
+			mapping, ok := optionProperties[o.Name]
+			if !ok {
+				continue
+			}
+			prop := &JobProperty{Class: mapping.class, Directive: "options", Settings: map[string]string{}}
+			// Options such as buildDiscarder and rateLimitBuilds take their settings from a nested call.
+			call := o
+			if len(o.Arguments) == 1 && o.Arguments[0] != nil && o.Arguments[0].Single != nil &&
+				o.Arguments[0].Single.Single == nil && o.Arguments[0].Single.Call != nil {
+				call = o.Arguments[0].Single.Call
+			}
+			for arg, v := range callArgs(call, defaultArgs[o.Name]) {
+				if key, ok := mapping.settings[arg]; ok {
+					prop.Settings[key] = v
+				}
+			}
+			props = append(props, prop)
+		}
+	}
+	if p.Triggers != nil && len(p.Triggers.Triggers) > 0 {
+		prop := &JobProperty{Class: triggersProperty, Directive: "triggers", Settings: map[string]string{}}
+		for _, t := range p.Triggers.Triggers {
+			if t == nil {
+				continue
+			}
+			mapping, ok := triggerClasses[t.Name]
+			if !ok {
+				continue
+			}
+			for arg, v := range callArgs(t, defaultArgs[t.Name]) {
+				if key, ok := mapping.settings[arg]; ok {
+					prop.Settings["triggers/"+mapping.class+"/"+key] = v
+				}
+			}
+		}
+		props = append(props, prop)
+	}
+	if p.Parameters != nil && len(p.Parameters.Parameters) > 0 {
+		prop := &JobProperty{Class: parametersProperty, Directive: "parameters", Settings: map[string]string{}}
+		for _, param := range p.Parameters.Parameters {
+			if param == nil {
+				continue
+			}
+			class, ok := parameterClasses[param.Name]
+			if !ok {
+				continue
+			}
+			args := callArgs(param, "")
+			name, ok := args["name"]
+			if !ok {
+				continue
+			}
+			prefix := fmt.Sprintf("parameterDefinitions/%s[%s]/", class, name)
+			prop.Settings[prefix+"name"] = name
+			for _, key := range []string{"defaultValue", "description"} {
+				if v, ok := args[key]; ok {
+					prop.Settings[prefix+key] = v
+				}
+			}
+		}
+		props = append(props, prop)
+	}
+	sort.SliceStable(props, func(i, j int) bool {
+		return props[i].Class < props[j].Class
+	})
+	return props, nil
+}
+
+// callArgs returns the literal arguments of a call, with an unnamed argument under the given default name
+func callArgs(call *model.MethodCall, def string) map[string]string {
+	args := map[string]string{}
+	for _, a := range call.Arguments {
+		var key string
+		var v *model.ValueOrMethodCall
+		switch {
+		case a == nil:
+			continue
+		case a.WithKey != nil && a.WithKey.Key != "":
+			key, v = a.WithKey.Key, a.WithKey.Value
+		default:
+			key, v = def, a.Single
+		}
+		if key != "" && v != nil && v.Single != nil && v.Single.IsLiteral {
+			args[key] = v.Single.String()
+		}
+	}
+	return args
+}
+
+// managedProperties are the property classes which pipelines set, so a job having one the pipeline doesn't declare is
+// drift rather than configuration from elsewhere
+func managedProperties() map[string]string {
+	managed := map[string]string{parametersProperty: "parameters", triggersProperty: "triggers"}
+	for _, mapping := range optionProperties {
+		managed[mapping.class] = "options"
+	}
+	return managed
+}
+
+// ConfigDrift compares the job properties the pipeline sets with those in a job's config.xml. It reports properties
+// the pipeline declares which the job doesn't have (job-property-missing), properties pipelines manage which the job
+// has but the pipeline doesn't declare (job-property-unexpected), and settings whose values differ
+// (job-property-drift). Jenkins updates the job's properties each time the pipeline runs, so drift means the job was
+// changed by hand, or the Jenkinsfile has changed since the last build.
+func ConfigDrift(root *model.Root, configXML []byte) ([]*Finding, error) {
+	want, err := JobProperties(root)
+	if err != nil {
+		return nil, err
+	}
+	have, err := configProperties(configXML)
+	if err != nil {
+		return nil, err
+	}
+	var findings []*Finding
+	declared := map[string]bool{}
+	for _, prop := range want {
+		declared[prop.Class] = true
+		settings, ok := have[prop.Class]
+		if !ok {
+			findings = append(findings, &Finding{Rule: "job-property-missing",
+				Message: fmt.Sprintf("the pipeline's %s set %s, which the job doesn't have", prop.Directive, prop.Class)})
+			continue
+		}
+		for _, key := range sortedSettings(prop.Settings) {
+			actual, ok := settings[key]
+			if !ok {
+				actual = absent
+			}
+			if actual != prop.Settings[key] {
+				findings = append(findings, &Finding{Rule: "job-property-drift",
+					Message: fmt.Sprintf("%s setting %s is %s in the job but %s in the pipeline", prop.Class, key,
+						actual, prop.Settings[key])})
+			}
+		}
+	}
+	managed := managedProperties()
+	var classes []string
+	for class := range have {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		if directive, ok := managed[class]; ok && !declared[class] {
+			findings = append(findings, &Finding{Rule: "job-property-unexpected",
+				Message: fmt.Sprintf("the job has %s, which the pipeline's %s don't set", class, directive)})
+		}
+	}
+	return record(findings), nil
+}
+
+func sortedSettings(settings map[string]string) []string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configProperties reads the properties of a job's config.xml, flattening each into settings keyed as in JobProperty
+func configProperties(configXML []byte) (map[string]map[string]string, error) {
+	// Jenkins declares its XML as version 1.1, which encoding/xml refuses, although the documents are also valid 1.0.
+	if bytes.HasPrefix(configXML, []byte("<?xml")) {
+		if end := bytes.Index(configXML, []byte("?>")); end > 0 {
+			prolog := bytes.Replace(configXML[:end], []byte("'1.1'"), []byte("'1.0'"), 1)
+			prolog = bytes.Replace(prolog, []byte(`"1.1"`), []byte(`"1.0"`), 1)
+			configXML = append(prolog, configXML[end:]...)
+		}
+	}
+	dec := xml.NewDecoder(bytes.NewReader(configXML))
+	root := &xmlNode{}
+	if err := dec.Decode(root); err != nil {
+		return nil, fmt.Errorf("parsing config.xml: %s", err)
+	}
+	props := map[string]map[string]string{}
+	for _, section := range root.Children {
+		if section.XMLName.Local != "properties" {
+			continue
+		}
+		for _, prop := range section.Children {
+			settings := map[string]string{}
+			prop.flatten("", settings)
+			props[prop.XMLName.Local] = settings
+		}
+	}
+	return props, nil
+}
+
+// xmlNode is an element of a config.xml
+type xmlNode struct {
+	XMLName  xml.Name
+	Text     string     `xml:",chardata"`
+	Children []*xmlNode `xml:",any"`
+}
+
+// flatten adds the element's leaf values to settings, keyed by their path below it
+func (n *xmlNode) flatten(prefix string, settings map[string]string) {
+	for _, c := range n.Children {
+		segment := c.XMLName.Local
+		for _, gc := range c.Children {
+			if gc.XMLName.Local == "name" && len(c.Children) > 1 {
+				segment += "[" + strings.TrimSpace(gc.Text) + "]"
+				break
+			}
+		}
+		path := prefix + segment
+		if len(c.Children) == 0 {
+			settings[path] = strings.TrimSpace(c.Text)
+			continue
+		}
+		c.flatten(path+"/", settings)
+	}
+}
@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobProperties(t *testing.T) {
+	props, err := JobProperties(loadRoot(t, "properties"))
+	require.NoError(t, err)
+	assert.Equal(t, []*JobProperty{
+		{Class: "hudson.model.ParametersDefinitionProperty", Directive: "parameters", Settings: map[string]string{
+			"parameterDefinitions/hudson.model.StringParameterDefinition[VERSION]/name":         "VERSION",
+			"parameterDefinitions/hudson.model.StringParameterDefinition[VERSION]/defaultValue": "2.0",
+		}},
+		{Class: "jenkins.model.BuildDiscarderProperty", Directive: "options",
+			Settings: map[string]string{"strategy/numToKeep": "10"}},
+		{Class: "org.jenkinsci.plugins.workflow.job.properties.DisableConcurrentBuildsJobProperty",
+			Directive: "options", Settings: map[string]string{}},
+		{Class: "org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty", Directive: "triggers",
+			Settings: map[string]string{"triggers/hudson.triggers.TimerTrigger/spec": "H 4 * * *"}},
+	}, props)
+}
+
+func TestConfigDrift(t *testing.T) {
+	config, err := ioutil.ReadFile(filepath.Join("testdata", "config.xml"))
+	require.NoError(t, err)
+	findings, err := ConfigDrift(loadRoot(t, "properties"), config)
+	require.NoError(t, err)
+	assert.Equal(t, []*Finding{
+		{Rule: "job-property-drift", Message: "hudson.model.ParametersDefinitionProperty setting parameterDefinitions/" +
+			"hudson.model.StringParameterDefinition[VERSION]/defaultValue is 1.0 in the job but 2.0 in the pipeline"},
+		{Rule: "job-property-missing", Message: "the pipeline's options set " +
+			"org.jenkinsci.plugins.workflow.job.properties.DisableConcurrentBuildsJobProperty, which the job doesn't have"},
+		{Rule: "job-property-unexpected", Message: "the job has " +
+			"org.jenkinsci.plugins.workflow.job.properties.DurabilityHintJobProperty, which the pipeline's options don't set"},
+	}, findings)
+
+	_, err = ConfigDrift(loadRoot(t, "properties"), []byte("<flow-definition>"))
+	assert.EqualError(t, err, "parsing config.xml: XML syntax error on line 1: unexpected EOF")
+}
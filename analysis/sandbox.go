@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// signaturePattern is a construct which needs script approval, and the signature an administrator approves for it
+type signaturePattern struct {
+	pattern   *regexp.Regexp
+	signature string
+}
+
+// signatureCatalog lists Groovy constructs which the script security sandbox rejects until an administrator approves
+// their signatures. Matching is textual, so it can be fooled by strings and comments, and constructs not listed here
+// may also need approval.
+var signatureCatalog = []signaturePattern{
+	{regexp.MustCompile(`\bSystem\s*\.\s*getenv\s*\(\s*\)`), "staticMethod java.lang.System getenv"},
+	{regexp.MustCompile(`\bSystem\s*\.\s*getenv\s*\(\s*[^\s)]`), "staticMethod java.lang.System getenv java.lang.String"},
+	{regexp.MustCompile(`\bSystem\s*\.\s*getProperty\s*\(`), "staticMethod java.lang.System getProperty java.lang.String"},
+	{regexp.MustCompile(`\bSystem\s*\.\s*exit\s*\(`), "staticMethod java.lang.System exit int"},
+	{regexp.MustCompile(`\bThread\s*\.\s*sleep\s*\(`), "staticMethod java.lang.Thread sleep long"},
+	{regexp.MustCompile(`\bClass\s*\.\s*forName\s*\(`), "staticMethod java.lang.Class forName java.lang.String"},
+	{regexp.MustCompile(`\.\s*getClass\s*\(\s*\)|\.\s*class\b`), "method java.lang.Object getClass"},
+	{regexp.MustCompile(`\bnew\s+(java\.io\.)?File\s*\(`), "new java.io.File java.lang.String"},
+	{regexp.MustCompile(`\bnew\s+(java\.net\.)?URL\s*\(`), "new java.net.URL java.lang.String"},
+	{regexp.MustCompile(`\bnew\s+(groovy\.json\.)?JsonSlurper\s*\(`), "new groovy.json.JsonSlurper"},
+	{regexp.MustCompile(`\.\s*parseText\s*\(`), "method groovy.json.JsonSlurper parseText java.lang.String"},
+	{regexp.MustCompile(`\.\s*execute\s*\(\s*\)`),
+		"staticMethod org.codehaus.groovy.runtime.ProcessGroovyMethods execute java.lang.String"},
+	{regexp.MustCompile(`\bEval\s*\.\s*me\s*\(`), "staticMethod groovy.util.Eval me java.lang.String"},
+	{regexp.MustCompile(`\bJenkins\s*\.\s*(instance|getInstance\s*\(\s*\))`),
+		"staticMethod jenkins.model.Jenkins getInstance"},
+	{regexp.MustCompile(`\bJenkins\s*\.\s*get\s*\(\s*\)`), "staticMethod jenkins.model.Jenkins get"},
+	{regexp.MustCompile(`\bcurrentBuild\s*\.\s*(rawBuild|getRawBuild\s*\(\s*\))`),
+		"method org.jenkinsci.plugins.workflow.support.steps.build.RunWrapper getRawBuild"},
+	{regexp.MustCompile(`\.\s*getBuildCauses\s*\(\s*\)|\bcurrentBuild\s*\.\s*buildCauses\b`),
+		"method org.jenkinsci.plugins.workflow.support.steps.build.RunWrapper getBuildCauses"},
+}
+
+// SignatureUse is a construct in a pipeline's Groovy which needs script approval
+type SignatureUse struct {
+	Signature string `json:"signature"`
+	// Stage is the ID of the stage the construct is in.
+	Stage string `json:"stage"`
+	// Context is where the construct is: "script step" or "when expression".
+	Context string `json:"context"`
+	// Match is the text which matched.
+	Match string `json:"match"`
+}
+
+// SandboxSignatures scans the script steps and when expressions of a pipeline for constructs in a catalog of
+// signatures which need script approval, so administrators can approve them ahead of the pipeline's first run. Uses
+// are returned in pipeline order, and each signature is only reported once per stage and context.
+func SandboxSignatures(p *plan.Plan) []*SignatureUse {
+	var uses []*SignatureUse
+	seen := map[string]bool{}
+	scan := func(stage string, context string, src string) {
+		for _, sig := range signatureCatalog {
+			match := sig.pattern.FindString(src)
+			key := stage + "\x00" + context + "\x00" + sig.signature
+			if match == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			uses = append(uses, &SignatureUse{Signature: sig.signature, Stage: stage, Context: context, Match: match})
+		}
+	}
+	var visitWhen func(stage string, conditions []*model.StepOrNestedWhenCondition)
+	visitWhen = func(stage string, conditions []*model.StepOrNestedWhenCondition) {
+		for _, c := range conditions {
+			switch {
+			case c == nil:
+			case c.Step != nil:
+				if c.Step.Name == "expression" {
+					scan(stage, "when expression", argumentSource(c.Step.Arguments))
+				}
+			case c.Nested != nil:
+				visitWhen(stage, c.Nested.Children)
+			}
+		}
+	}
+	scanScript := func(stage string, st *plan.Step) {
+		if st.Name != "script" || st.Source == nil {
+			return
+		}
+		switch {
+		case st.Source.Step != nil:
+			scan(stage, "script step", argumentSource(st.Source.Step.Arguments))
+		case st.Source.Tree != nil:
+			scan(stage, "script step", argumentSource(st.Source.Tree.Arguments))
+		}
+	}
+	var visitStages func(stages []*plan.Stage)
+	visitStages = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			if s.When != nil {
+				visitWhen(s.ID, s.When.Conditions)
+			}
+			visitStepList(s.Steps, func(st *plan.Step) { scanScript(s.ID, st) })
+			visitStages(s.Children)
+			for _, b := range s.Post {
+				visitStepList(b.Steps, func(st *plan.Step) { scanScript(s.ID, st) })
+			}
+		}
+	}
+	visitStages(p.Stages)
+	return uses
+}
+
+// argumentSource joins the values of all a step's arguments, which for script steps and expressions is their Groovy
+func argumentSource(args *model.ArgumentList) string {
+	if args == nil {
+		return ""
+	}
+	src := args.Single.String()
+	for _, a := range args.Positional {
+		src += "\n" + a.String()
+	}
+	for _, a := range args.Named {
+		if a != nil {
+			src += "\n" + a.Value.String()
+		}
+	}
+	return src
+}
+
+// Approvals returns the distinct signatures used, sorted, as an administrator would enter them on the In-process
+// Script Approval page
+func Approvals(uses []*SignatureUse) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, u := range uses {
+		if !seen[u.Signature] {
+			seen[u.Signature] = true
+			out = append(out, u.Signature)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SandboxFindings reports each use of a signature needing approval (sandbox-approval)
+func SandboxFindings(uses []*SignatureUse) []*Finding {
+	var findings []*Finding
+	for _, u := range uses {
+		findings = append(findings, &Finding{Rule: "sandbox-approval", Stage: u.Stage,
+			Message: fmt.Sprintf("%s %q needs script approval of %q", u.Context, u.Match, u.Signature)})
+	}
+	return record(findings)
+}
@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxSignatures(t *testing.T) {
+	p, err := plan.Build(loadRoot(t, "sandbox"))
+	require.NoError(t, err)
+
+	uses := SandboxSignatures(p)
+	assert.Equal(t, []*SignatureUse{
+		{Signature: "staticMethod java.lang.System getenv java.lang.String", Stage: "Build", Context: "when expression",
+			Match: "System.getenv('"},
+		{Signature: "new java.io.File java.lang.String", Stage: "Build", Context: "script step", Match: "new File("},
+		{Signature: "new groovy.json.JsonSlurper", Stage: "Build", Context: "script step", Match: "new JsonSlurper("},
+		{Signature: "method groovy.json.JsonSlurper parseText java.lang.String", Stage: "Build",
+			Context: "script step", Match: ".parseText("},
+		{Signature: "method org.jenkinsci.plugins.workflow.support.steps.build.RunWrapper getRawBuild",
+			Stage: "Report", Context: "script step", Match: "currentBuild.rawBuild"},
+	}, uses)
+
+	assert.Equal(t, []string{
+		"method groovy.json.JsonSlurper parseText java.lang.String",
+		"method org.jenkinsci.plugins.workflow.support.steps.build.RunWrapper getRawBuild",
+		"new groovy.json.JsonSlurper",
+		"new java.io.File java.lang.String",
+		"staticMethod java.lang.System getenv java.lang.String",
+	}, Approvals(uses))
+
+	findings := SandboxFindings(uses[:1])
+	assert.Equal(t, []*Finding{{Rule: "sandbox-approval", Stage: "Build", Message: `when expression "System.getenv('" ` +
+		`needs script approval of "staticMethod java.lang.System getenv java.lang.String"`}}, findings)
+}
@@ -0,0 +1,207 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Severity is how serious a finding is
+type Severity string
+
+// Severities, from least to most serious
+const (
+	Info    Severity = "info"
+	Warning Severity = "warning"
+	Error   Severity = "error"
+)
+
+var severityRanks = map[Severity]int{Info: 0, Warning: 1, Error: 2}
+
+// AtLeast returns whether s is at least as serious as other.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
+
+// ParseSeverity parses a severity's name.
+func ParseSeverity(s string) (Severity, error) {
+	if _, ok := severityRanks[Severity(s)]; !ok {
+		return "", fmt.Errorf("unknown severity %q, must be one of: info, warning, error", s)
+	}
+	return Severity(s), nil
+}
+
+// Source is the kind of check a finding comes from, which policies can treat differently
+type Source string
+
+// Sources of findings
+const (
+	// SourceLint is for likely mistakes and bad practices, which the pipeline still runs with.
+	SourceLint Source = "lint"
+	// SourceValidation is for pipelines Jenkins would reject, or which fail when they run.
+	SourceValidation Source = "validation"
+	// SourceSecurity is for untrusted code and configuration, and script approvals.
+	SourceSecurity Source = "security"
+	// SourcePolicy is for breaches of an organization's limits, such as on matrix size.
+	SourcePolicy Source = "policy"
+)
+
+// Rule is the classification of a rule's findings
+type Rule struct {
+	Source   Source   `json:"source"`
+	Severity Severity `json:"severity"`
+}
+
+var (
+	rulesLock sync.RWMutex
+	rules     = map[string]Rule{
+		"choice-default-not-in-choices": {SourceValidation, Error},
+		"cron-drift":                    {SourceLint, Warning},
+		"cron-dst":                      {SourceLint, Warning},
+		"cron-invalid":                  {SourceValidation, Error},
+		"duplicate-option":              {SourceValidation, Error},
+		"invalid-library":               {SourceValidation, Error},
+		"job-property-drift":            {SourceLint, Warning},
+		"job-property-missing":          {SourceLint, Warning},
+		"job-property-unexpected":       {SourceLint, Warning},
+		"milestone-input":               {SourceLint, Warning},
+		"milestone-missing":             {SourceLint, Warning},
+		"milestone-order":               {SourceValidation, Error},
+		"milestone-parallel":            {SourceValidation, Error},
+		"option-drift":                  {SourceLint, Info},
+		"parameter-drift":               {SourceLint, Info},
+		"pipeline-size":                 {SourceValidation, Error},
+		"sandbox-approval":              {SourceSecurity, Warning},
+		"stage-complexity":              {SourceLint, Info},
+		"timeout-exceeded":              {SourceLint, Error},
+		"timeout-risk":                  {SourceLint, Warning},
+		"trigger-drift":                 {SourceLint, Info},
+		"undeclared-parameter":          {SourceValidation, Error},
+		"unknown-library":               {SourceValidation, Error},
+		"unknown-library-version":       {SourceValidation, Error},
+		"unpinned-library":              {SourceLint, Warning},
+		"unreachable-stage":             {SourceLint, Warning},
+		"unstash-before-stash":          {SourceValidation, Error},
+		"unstash-without-stash":         {SourceValidation, Error},
+		"untrusted-credentials":         {SourceSecurity, Error},
+		"untrusted-job-property":        {SourceSecurity, Error},
+		"untrusted-library":             {SourceSecurity, Error},
+		"unused-parameter":              {SourceLint, Info},
+		"unused-stash":                  {SourceLint, Info},
+		"workspace-not-shared":          {SourceLint, Warning},
+	}
+)
+
+// DefaultRule is the classification of rules which aren't registered
+var DefaultRule = Rule{Source: SourceLint, Severity: Warning}
+
+// RegisterRule classifies the findings of a rule reported outside this package. It panics if the rule is already
+// registered.
+func RegisterRule(name string, rule Rule) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+	if _, exists := rules[name]; exists {
+		panic(fmt.Sprintf("rule %q is already registered", name))
+	}
+	rules[name] = rule
+}
+
+// RuleFor returns the classification of a rule, or DefaultRule if it isn't registered.
+func RuleFor(name string) Rule {
+	rulesLock.RLock()
+	defer rulesLock.RUnlock()
+	if r, ok := rules[name]; ok {
+		return r
+	}
+	return DefaultRule
+}
+
+// Source returns the source of the finding's rule.
+func (strct *Finding) Source() Source {
+	return RuleFor(strct.Rule).Source
+}
+
+// Severity returns the severity of the finding's rule.
+func (strct *Finding) Severity() Severity {
+	return RuleFor(strct.Rule).Severity
+}
+
+// Exit codes for tools reporting findings
+const (
+	// ExitOK is for checks which passed, perhaps with findings below the policy's thresholds.
+	ExitOK = 0
+	// ExitFindings is for findings at or above the policy's thresholds.
+	ExitFindings = 1
+	// ExitError is for checks which couldn't be run, such as because a pipeline couldn't be read.
+	ExitError = 2
+)
+
+// ExitPolicy decides which findings fail a check, so that every tool reporting findings fails for the same reasons
+type ExitPolicy struct {
+	// FailOn is the least severity which fails, by source. Sources which aren't listed use Default.
+	FailOn map[Source]Severity `json:"failOn,omitempty"`
+	// Default is the least severity which fails for other sources. It defaults to Error.
+	Default Severity `json:"default,omitempty"`
+}
+
+// ParseExitPolicy parses a policy from a list of thresholds separated by commas, as given on a command line. Each is
+// either a severity, which sets the default, or a source and a severity separated by "=", such as
+// "error,security=warning".
+func ParseExitPolicy(spec string) (*ExitPolicy, error) {
+	p := &ExitPolicy{FailOn: map[Source]Severity{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		source, name := "", part
+		if i := strings.Index(part, "="); i >= 0 {
+			source, name = part[:i], part[i+1:]
+		}
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		switch Source(source) {
+		case "":
+			p.Default = severity
+		case SourceLint, SourceValidation, SourceSecurity, SourcePolicy:
+			p.FailOn[Source(source)] = severity
+		default:
+			return nil, fmt.Errorf("unknown source %q, must be one of: lint, validation, security, policy", source)
+		}
+	}
+	return p, nil
+}
+
+// Fails returns whether a finding fails the check.
+func (strct *ExitPolicy) Fails(f *Finding) bool {
+	threshold := Error
+	if strct != nil {
+		if t, ok := strct.FailOn[f.Source()]; ok {
+			threshold = t
+		} else if strct.Default != "" {
+			threshold = strct.Default
+		}
+	}
+	return f.Severity().AtLeast(threshold)
+}
+
+// Failing returns the findings which fail the check, in order.
+func (strct *ExitPolicy) Failing(findings []*Finding) []*Finding {
+	var out []*Finding
+	for _, f := range findings {
+		if strct.Fails(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// ExitCode returns ExitFindings if any finding fails the check, or ExitOK otherwise.
+func (strct *ExitPolicy) ExitCode(findings []*Finding) int {
+	if len(strct.Failing(findings)) > 0 {
+		return ExitFindings
+	}
+	return ExitOK
+}
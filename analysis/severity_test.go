@@ -0,0 +1,39 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitPolicy(t *testing.T) {
+	findings := []*Finding{
+		{Rule: "unused-parameter", Message: "parameter A is never used"},
+		{Rule: "sandbox-approval", Message: "needs approval"},
+		{Rule: "made-up", Message: "unregistered"},
+	}
+	assert.Equal(t, Info, findings[0].Severity())
+	assert.Equal(t, SourceSecurity, findings[1].Source())
+	assert.Equal(t, DefaultRule, RuleFor("made-up"))
+
+	var defaults *ExitPolicy
+	assert.Equal(t, ExitOK, defaults.ExitCode(findings))
+
+	p, err := ParseExitPolicy("error, security=warning")
+	require.NoError(t, err)
+	assert.Equal(t, &ExitPolicy{Default: Error, FailOn: map[Source]Severity{SourceSecurity: Warning}}, p)
+	assert.Equal(t, []*Finding{findings[1]}, p.Failing(findings))
+	assert.Equal(t, ExitFindings, p.ExitCode(findings))
+
+	p, err = ParseExitPolicy("info,lint=error")
+	require.NoError(t, err)
+	assert.Equal(t, []*Finding{findings[1]}, p.Failing(findings))
+
+	_, err = ParseExitPolicy("fatal")
+	assert.EqualError(t, err, `unknown severity "fatal", must be one of: info, warning, error`)
+	_, err = ParseExitPolicy("style=info")
+	assert.EqualError(t, err, `unknown source "style", must be one of: lint, validation, security, policy`)
+
+	assert.Panics(t, func() { RegisterRule("unused-stash", DefaultRule) })
+}
@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Jenkins compiles a Declarative pipeline block into a single JVM method, which fails with "Method code too large"
+// once its bytecode reaches 64KB. The bytecode size can't be known without compiling, so the defaults below are
+// conservative budgets on the size of the Groovy source instead.
+const (
+	// DefaultMaxBytes is the default budget for the estimated size of the whole pipeline's source.
+	DefaultMaxBytes = 32 * 1024
+	// DefaultMaxStageSteps is the default budget for the number of steps in a single stage.
+	DefaultMaxStageSteps = 50
+	// stringOverhead approximates the quotes, separators, and whitespace around each value when rendered as Groovy.
+	stringOverhead = 4
+)
+
+// StageSize is the estimated size of a single stage, not counting its nested stages
+type StageSize struct {
+	Stage string `json:"stage"`
+	Bytes int    `json:"bytes"`
+	// Steps counts the stage's steps, including those nested in tree steps and post conditions.
+	Steps int `json:"steps"`
+}
+
+// SizeEstimate is the estimated size of a pipeline when rendered as a Jenkinsfile
+type SizeEstimate struct {
+	Bytes int `json:"bytes"`
+	// Stages are the sizes of each stage, largest first.
+	Stages []*StageSize `json:"stages"`
+}
+
+// EstimateSize estimates the size of the pipeline's Groovy source, in total and by stage, from the lengths of the
+// names and values in it. It's meant for comparing with the budgets of a SizeBudget, not as an exact measure.
+func EstimateSize(p *plan.Plan) (*SizeEstimate, error) {
+	total, err := sourceSize(p.Source)
+	if err != nil {
+		return nil, err
+	}
+	est := &SizeEstimate{Bytes: total}
+	var visit func(stages []*plan.Stage) error
+	visit = func(stages []*plan.Stage) error {
+		for _, s := range stages {
+			own := *s.Source
+			own.Stages, own.Parallel = nil, nil
+			if own.Matrix != nil {
+				matrix := *own.Matrix
+				matrix.Stages = nil
+				own.Matrix = &matrix
+			}
+			bytes, err := sourceSize(&own)
+			if err != nil {
+				return err
+			}
+			steps := 0
+			visitStepList(s.Steps, func(*plan.Step) { steps++ })
+			for _, b := range s.Post {
+				visitStepList(b.Steps, func(*plan.Step) { steps++ })
+			}
+			est.Stages = append(est.Stages, &StageSize{Stage: s.ID, Bytes: bytes, Steps: steps})
+			if err := visit(s.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(p.Stages); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(est.Stages, func(i, j int) bool {
+		return est.Stages[i].Bytes > est.Stages[j].Bytes
+	})
+	return est, nil
+}
+
+// sourceSize estimates the Groovy source size of a model value from the strings in its JSON
+func sourceSize(v interface{}) (int, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return 0, err
+	}
+	size := 0
+	visitStrings(doc, func(s string) {
+		size += len(s) + stringOverhead
+	})
+	return size, nil
+}
+
+func visitStepList(steps []*plan.Step, fn func(*plan.Step)) {
+	for _, s := range steps {
+		fn(s)
+		visitStepList(s.Children, fn)
+	}
+}
+
+// SizeBudget limits the size of a pipeline. Zero fields use DefaultMaxBytes and DefaultMaxStageSteps.
+type SizeBudget struct {
+	MaxBytes      int `json:"maxBytes,omitempty"`
+	MaxStageSteps int `json:"maxStageSteps,omitempty"`
+}
+
+// Check reports pipelines estimated to be larger than the budget (pipeline-size), suggesting the largest stages as
+// the ones to move into shared library steps, and stages with more steps than the budget allows (stage-complexity).
+func (b SizeBudget) Check(est *SizeEstimate) []*Finding {
+	maxBytes, maxSteps := b.MaxBytes, b.MaxStageSteps
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxStageSteps
+	}
+	var findings []*Finding
+	if est.Bytes > maxBytes {
+		msg := fmt.Sprintf("pipeline is an estimated %d bytes, over the budget of %d, and may exceed the JVM's "+
+			"method size limit", est.Bytes, maxBytes)
+		// Suggest the fewest stages which would bring the pipeline back within budget.
+		excess := est.Bytes - maxBytes
+		var split []string
+		for _, s := range est.Stages {
+			if excess <= 0 {
+				break
+			}
+			split = append(split, fmt.Sprintf("%s (%d bytes)", s.Stage, s.Bytes))
+			excess -= s.Bytes
+		}
+		if len(split) > 0 {
+			msg += fmt.Sprintf("; consider moving the steps of %s into shared library steps", strings.Join(split, ", "))
+		}
+		findings = append(findings, &Finding{Rule: "pipeline-size", Message: msg})
+	}
+	for _, s := range est.Stages {
+		if s.Steps > maxSteps {
+			findings = append(findings, &Finding{Rule: "stage-complexity", Stage: s.Stage,
+				Message: fmt.Sprintf("stage has %d steps, over the budget of %d; consider moving them into a shared "+
+					"library step", s.Steps, maxSteps)})
+		}
+	}
+	return record(findings)
+}
@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeBudget(t *testing.T) {
+	echo := func(message string) *model.AnyStep {
+		return &model.AnyStep{Step: &model.Step{Name: "echo", Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{{
+			Key: "message", Value: &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: &message}}}}}}}
+	}
+	stage := func(name string, steps int) *model.Stage {
+		b := &model.Branch{Name: "default"}
+		for i := 0; i < steps; i++ {
+			b.Steps = append(b.Steps, echo(fmt.Sprintf("step %d", i)))
+		}
+		return &model.Stage{Name: name, Branches: []*model.Branch{b}}
+	}
+	root := &model.Root{Pipeline: &model.Pipeline{Agent: &model.Agent{Type: "any"}, Stages: []*model.Stage{
+		stage("small", 2),
+		{Name: "outer", Stages: []*model.Stage{stage("large", 60)}},
+	}}}
+	p, err := plan.Build(root)
+	require.NoError(t, err)
+
+	est, err := EstimateSize(p)
+	require.NoError(t, err)
+	require.Len(t, est.Stages, 3)
+	assert.Equal(t, "outer/large", est.Stages[0].Stage)
+	assert.Equal(t, 60, est.Stages[0].Steps)
+	assert.Equal(t, "small", est.Stages[1].Stage)
+	assert.Equal(t, "outer", est.Stages[2].Stage)
+	assert.Equal(t, 0, est.Stages[2].Steps)
+	assert.True(t, est.Bytes > est.Stages[0].Bytes+est.Stages[1].Bytes)
+
+	findings := SizeBudget{}.Check(est)
+	assert.Equal(t, []*Finding{{Rule: "stage-complexity", Stage: "outer/large",
+		Message: "stage has 60 steps, over the budget of 50; consider moving them into a shared library step"}}, findings)
+
+	findings = SizeBudget{MaxBytes: est.Bytes - 10, MaxStageSteps: 100}.Check(est)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "pipeline-size", findings[0].Rule)
+	assert.Equal(t, fmt.Sprintf("pipeline is an estimated %d bytes, over the budget of %d, and may exceed the JVM's "+
+		"method size limit; consider moving the steps of outer/large (%d bytes) into shared library steps", est.Bytes,
+		est.Bytes-10, est.Stages[0].Bytes), findings[0].Message)
+}
@@ -0,0 +1,48 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// DefaultTimeoutThreshold is the fraction of a timeout a stage's p95 duration may reach before Timeouts reports it
+const DefaultTimeoutThreshold = 0.9
+
+// Timeouts compares the timeout options of the pipeline and its stages with the durations attached to the plan by
+// Plan.AttachDurations. It reports timeouts which the p95 duration exceeds (timeout-exceeded), or comes within
+// threshold of, as a fraction of the timeout (timeout-risk). A threshold of zero uses DefaultTimeoutThreshold.
+func Timeouts(p *plan.Plan, threshold float64) []*Finding {
+	if threshold <= 0 {
+		threshold = DefaultTimeoutThreshold
+	}
+	var findings []*Finding
+	check := func(stage string, timeout time.Duration, ok bool, timing *plan.Timing) {
+		if !ok || timing == nil {
+			return
+		}
+		switch {
+		case timing.P95 >= timeout:
+			findings = append(findings, &Finding{Rule: "timeout-exceeded", Stage: stage,
+				Message: fmt.Sprintf("p95 duration %s over %d builds exceeds the timeout of %s", timing.P95,
+					timing.Samples, timeout)})
+		case float64(timing.P95) >= threshold*float64(timeout):
+			findings = append(findings, &Finding{Rule: "timeout-risk", Stage: stage,
+				Message: fmt.Sprintf("p95 duration %s over %d builds is within %.0f%% of the timeout of %s", timing.P95,
+					timing.Samples, (1-threshold)*100, timeout)})
+		}
+	}
+	timeout, ok := p.Timeout()
+	check("", timeout, ok, p.Timing)
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			timeout, ok := s.Timeout()
+			check(s.ID, timeout, ok, s.Timing)
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	return record(findings)
+}
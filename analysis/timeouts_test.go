@@ -0,0 +1,34 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeouts(t *testing.T) {
+	p, err := plan.Build(loadRoot(t, "timeouts"))
+	require.NoError(t, err)
+	assert.Empty(t, Timeouts(p, 0))
+
+	p.AttachDurations(map[string][]time.Duration{
+		"":       {40 * time.Minute, 50 * time.Minute},
+		"Build":  {5 * time.Minute, 9 * time.Minute},
+		"Test":   {25 * time.Minute},
+		"Deploy": {time.Hour},
+	})
+	assert.Equal(t, []*Finding{
+		{Rule: "timeout-risk", Stage: "Build",
+			Message: "p95 duration 9m0s over 2 builds is within 10% of the timeout of 10m0s"},
+		{Rule: "timeout-exceeded", Stage: "Test",
+			Message: "p95 duration 25m0s over 1 builds exceeds the timeout of 20m0s"},
+	}, Timeouts(p, 0))
+
+	findings := Timeouts(p, 0.8)
+	require.Len(t, findings, 3)
+	assert.Equal(t, "timeout-risk", findings[0].Rule)
+	assert.Equal(t, "", findings[0].Stage)
+}
@@ -0,0 +1,163 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Risk is how much a pipeline exposes to the authors of untrusted pull requests
+type Risk string
+
+const (
+	// RiskLow means pull request authors can only run code on agents, without credentials.
+	RiskLow Risk = "low"
+	// RiskMedium means pull request authors can also change the job's configuration.
+	RiskMedium Risk = "medium"
+	// RiskHigh means pull request authors can also use credentials or run code outside the sandbox.
+	RiskHigh Risk = "high"
+)
+
+// TrustReport summarizes what the author of a pull request from an untrusted fork controls when a multibranch project
+// builds the pull request's own Jenkinsfile
+type TrustReport struct {
+	Risk Risk `json:"risk"`
+	// PreSandbox are the directives Jenkins applies to the job or loads before any pipeline code runs in the sandbox.
+	PreSandbox []string `json:"preSandbox,omitempty"`
+	// Credentials are the IDs of the credentials the pipeline binds, sorted.
+	Credentials []string `json:"credentials,omitempty"`
+	// Scripts counts the script blocks and shell steps, which run the author's code.
+	Scripts  int        `json:"scripts"`
+	Findings []*Finding `json:"findings,omitempty"`
+}
+
+// jobDirectives are the pipeline directives Jenkins applies to the job's configuration when the pipeline runs
+var jobDirectives = []string{"options", "parameters", "triggers"}
+
+// credentialsIDPattern finds credential IDs in the bindings of withCredentials steps
+var credentialsIDPattern = regexp.MustCompile(`credentialsId\s*:\s*['"]([^'"]+)['"]`)
+
+// Trust reports what a pull request author controls by editing the pipeline, for multibranch projects which build
+// untrusted forks with the pull request's Jenkinsfile. Job properties set by the options, parameters, and triggers
+// directives are applied to the job (untrusted-job-property); libraries loaded at a version the Jenkinsfile chooses
+// may run the author's code outside the sandbox if the library is trusted (untrusted-library); and credentials bound
+// by the pipeline are available to the author's code (untrusted-credentials).
+func Trust(root *model.Root) (*TrustReport, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	report := &TrustReport{Risk: RiskLow}
+	raise := func(r Risk) {
+		if r == RiskHigh || (r == RiskMedium && report.Risk == RiskLow) {
+			report.Risk = r
+		}
+	}
+
+	pipeline := root.Pipeline
+	present := map[string]bool{
+		"options":    pipeline.Options != nil && len(pipeline.Options.Options) > 0,
+		"parameters": pipeline.Parameters != nil && len(pipeline.Parameters.Parameters) > 0,
+		"triggers":   pipeline.Triggers != nil && len(pipeline.Triggers.Triggers) > 0,
+	}
+	for _, d := range jobDirectives {
+		if !present[d] {
+			continue
+		}
+		report.PreSandbox = append(report.PreSandbox, d)
+		report.Findings = append(report.Findings, &Finding{Rule: "untrusted-job-property",
+			Message: fmt.Sprintf("the pipeline's %s are applied to the job by pull request builds too", d)})
+		raise(RiskMedium)
+	}
+	if pipeline.Libraries != nil {
+		for _, lib := range pipeline.Libraries.Libraries {
+			if lib == nil {
+				continue
+			}
+			report.PreSandbox = append(report.PreSandbox, "library "+lib.String())
+			if !lib.IsLiteral || strings.Contains(lib.String(), "@") {
+				report.Findings = append(report.Findings, &Finding{Rule: "untrusted-library",
+					Message: fmt.Sprintf("library %s is loaded at a version the Jenkinsfile chooses; if the library "+
+						"is trusted, pull requests can run code outside the sandbox", lib.String())})
+				raise(RiskHigh)
+			}
+		}
+	}
+
+	credentials := map[string]string{}
+	for _, e := range p.Environment {
+		if e.Credential != "" {
+			credentials[e.Credential] = ""
+		}
+	}
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			for _, e := range s.Environment {
+				if _, ok := credentials[e.Credential]; e.Credential != "" && !ok {
+					credentials[e.Credential] = s.ID
+				}
+			}
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	visitSteps(p, func(s *plan.Stage, st *plan.Step) {
+		switch {
+		case st.Name == "script" || scriptStep(st.Name):
+			report.Scripts++
+		case st.Name == "withCredentials" && st.Source != nil && st.Source.Tree != nil:
+			args := st.Source.Tree.Arguments
+			if args == nil {
+				return
+			}
+			var sources []string
+			if args.Single != nil {
+				sources = append(sources, args.Single.String())
+			}
+			for _, a := range args.Positional {
+				sources = append(sources, a.String())
+			}
+			for _, a := range args.Named {
+				if a.Value != nil {
+					sources = append(sources, a.Value.String())
+				}
+			}
+			for _, src := range sources {
+				for _, m := range credentialsIDPattern.FindAllStringSubmatch(src, -1) {
+					if _, ok := credentials[m[1]]; !ok {
+						credentials[m[1]] = s.ID
+					}
+				}
+			}
+		}
+	})
+	for id := range credentials {
+		report.Credentials = append(report.Credentials, id)
+	}
+	sort.Strings(report.Credentials)
+	for _, id := range report.Credentials {
+		report.Findings = append(report.Findings, &Finding{Rule: "untrusted-credentials", Stage: credentials[id],
+			Message: fmt.Sprintf("credential %s is available to code from pull request authors", id)})
+		raise(RiskHigh)
+	}
+	report.Findings = record(report.Findings)
+	return report, nil
+}
+
+// scriptStep returns true for steps which run a shell script
+func scriptStep(name string) bool {
+	switch name {
+	case "sh", "bat", "powershell", "pwsh":
+		return true
+	}
+	return false
+}
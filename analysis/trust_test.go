@@ -0,0 +1,35 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrust(t *testing.T) {
+	report, err := Trust(loadRoot(t, "trust"))
+	require.NoError(t, err)
+	assert.Equal(t, &TrustReport{
+		Risk:        RiskHigh,
+		PreSandbox:  []string{"triggers", "library deploy-utils", "library build-utils@feature"},
+		Credentials: []string{"npm-token", "registry"},
+		Scripts:     3,
+		Findings: []*Finding{
+			{Rule: "untrusted-job-property", Message: "the pipeline's triggers are applied to the job by pull request builds too"},
+			{Rule: "untrusted-library", Message: "library build-utils@feature is loaded at a version the Jenkinsfile " +
+				"chooses; if the library is trusted, pull requests can run code outside the sandbox"},
+			{Rule: "untrusted-credentials", Stage: "Publish",
+				Message: "credential npm-token is available to code from pull request authors"},
+			{Rule: "untrusted-credentials", Message: "credential registry is available to code from pull request authors"},
+		},
+	}, report)
+
+	report, err = Trust(&model.Root{Pipeline: &model.Pipeline{Agent: &model.Agent{Type: "any"}, Stages: []*model.Stage{}}})
+	require.NoError(t, err)
+	assert.Equal(t, &TrustReport{Risk: RiskLow}, report)
+
+	_, err = Trust(&model.Root{})
+	assert.EqualError(t, err, "a root with a pipeline is required")
+}
@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// PipelineWorkspace is the workspace ID for stages running on the pipeline-level agent
+const PipelineWorkspace = "pipeline"
+
+// producerSteps are steps which leave build output in the workspace
+var producerSteps = map[string]bool{
+	"sh": true, "bat": true, "powershell": true, "pwsh": true, "writeFile": true, "writeJSON": true, "writeYaml": true,
+}
+
+// consumerSteps are steps which expect build output to already be in the workspace
+var consumerSteps = map[string]bool{
+	"archiveArtifacts": true, "archive": true, "junit": true, "stash": true, "readFile": true, "readJSON": true,
+	"readYaml": true, "publishHTML": true, "recordIssues": true, "jacoco": true, "fingerprint": true,
+}
+
+// restoreSteps are steps which bring files from elsewhere into the workspace
+var restoreSteps = map[string]bool{
+	"unstash": true, "copyArtifacts": true,
+}
+
+// WorkspaceReport describes which workspace each stage runs in and the problems that causes
+type WorkspaceReport struct {
+	// Workspaces maps each stage ID to the ID of the stage which allocated its workspace, or PipelineWorkspace.
+	// Stages with no agent at all are omitted.
+	Workspaces map[string]string `json:"workspaces"`
+	Findings   []*Finding        `json:"findings,omitempty"`
+}
+
+// Workspaces determines the workspace each stage runs in and reports stages which consume build output, such as with
+// junit or archiveArtifacts, in a different workspace from the one the output was produced in, with no unstash or
+// copyArtifacts to bring it across (workspace-not-shared). A stage gets a new workspace when it declares its own
+// agent, unless it is a docker or dockerfile agent with reuseNode set.
+func Workspaces(root *model.Root) (*WorkspaceReport, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	report := &WorkspaceReport{Workspaces: make(map[string]string)}
+
+	var assign func(stages []*plan.Stage, parent string)
+	assign = func(stages []*plan.Stage, parent string) {
+		for _, s := range stages {
+			ws := parent
+			if a := s.Agent; a != nil && !a.Inherited {
+				switch {
+				case a.Type == "none":
+					ws = ""
+				case !reusesNode(a.Source) || parent == "":
+					ws = s.ID
+				}
+			}
+			if ws != "" {
+				report.Workspaces[s.ID] = ws
+			}
+			assign(s.Children, ws)
+		}
+	}
+	top := ""
+	if p.Agent != nil && p.Agent.Type != "none" {
+		top = PipelineWorkspace
+	}
+	assign(p.Stages, top)
+
+	// producedIn maps a workspace to whether build output has been produced in it; producer records the last stage
+	// to produce output in any workspace.
+	producedIn := make(map[string]bool)
+	restoredIn := make(map[string]bool)
+	var producer *plan.Stage
+	reported := make(map[string]bool)
+	visitSteps(p, func(stage *plan.Stage, step *plan.Step) {
+		ws, ok := report.Workspaces[stage.ID]
+		if !ok {
+			return
+		}
+		switch {
+		case restoreSteps[step.Name]:
+			restoredIn[ws] = true
+		case consumerSteps[step.Name]:
+			if producedIn[ws] || restoredIn[ws] || producer == nil || reported[stage.ID] {
+				break
+			}
+			if other := report.Workspaces[producer.ID]; other != ws {
+				reported[stage.ID] = true
+				report.Findings = append(report.Findings, &Finding{Rule: "workspace-not-shared", Stage: stage.ID,
+					Message: fmt.Sprintf("%s runs on a different agent workspace than stage %q, which produced build output; "+
+						"stash the files there and unstash them here", step.Name, producer.ID)})
+			}
+		}
+		if producerSteps[step.Name] {
+			producedIn[ws] = true
+			producer = stage
+		}
+	})
+	record(report.Findings)
+	return report, nil
+}
+
+func reusesNode(a *model.Agent) bool {
+	if a == nil || (a.Type != "docker" && a.Type != "dockerfile") {
+		return false
+	}
+	for _, arg := range a.Arguments {
+		if arg != nil && arg.Key == "reuseNode" && arg.Value != nil && arg.Value.Raw != nil {
+			return arg.Value.Raw.String() == "true"
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaces(t *testing.T) {
+	report, err := Workspaces(loadRoot(t, "workspaces"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"Build":             "Build",
+		"Test":              "Test",
+		"Package":           "Package",
+		"Package/Compile":   "Package",
+		"Package/In Docker": "Package",
+		"Publish":           "Publish",
+	}, report.Workspaces)
+	assert.Equal(t, []*Finding{
+		{Rule: "workspace-not-shared", Stage: "Test", Message: `junit runs on a different agent workspace than stage "Build", ` +
+			`which produced build output; stash the files there and unstash them here`},
+	}, report.Findings)
+}
@@ -0,0 +1,184 @@
+// Package approval lists the manual approval gates of a pipeline, stage input directives and input steps, with the
+// policy each one enforces: who may approve, how long it waits, and whether it holds an executor while waiting.
+package approval
+
+import (
+	"strings"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Kind is how a gate is declared
+type Kind string
+
+const (
+	// Directive is a stage's input directive, evaluated before the stage runs
+	Directive Kind = "directive"
+	// Step is an input step
+	Step Kind = "step"
+)
+
+// Gate is a single approval gate
+type Gate struct {
+	// Stage is the ID of the stage the gate is in.
+	Stage   string `json:"stage"`
+	Kind    Kind   `json:"kind"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+	Ok      string `json:"ok,omitempty"`
+	// Submitters are the users and groups allowed to approve. If empty, any user who can build the job may.
+	Submitters []string `json:"submitters,omitempty"`
+	// SubmitterParameter is the variable the approving user's ID is stored in, if any.
+	SubmitterParameter string `json:"submitterParameter,omitempty"`
+	// Parameters are the names of the parameters the approver is asked for.
+	Parameters []string `json:"parameters,omitempty"`
+	// Timeout is how long the gate waits before the build is aborted, if a timeout covers it. It is the shortest of
+	// the timeouts enclosing the gate.
+	Timeout *time.Duration `json:"timeout,omitempty"`
+	// TimeoutSource describes the timeout Timeout comes from: "pipeline", the ID of a stage with a timeout option, or
+	// "timeout step".
+	TimeoutSource string `json:"timeoutSource,omitempty"`
+	// HoldsExecutor is true if the gate waits while the build holds an agent's executor.
+	HoldsExecutor bool `json:"holdsExecutor"`
+	// Dynamic is true if any of the gate's settings are Groovy expressions, so the policy above may be incomplete.
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// AnyoneMaySubmit returns true if the gate doesn't restrict who may approve it.
+func (strct *Gate) AnyoneMaySubmit() bool {
+	return len(strct.Submitters) == 0
+}
+
+// Gates returns the approval gates of the pipeline, in execution order.
+func Gates(root *model.Root) ([]*Gate, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	var gates []*Gate
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			if s.Input != nil {
+				gates = append(gates, directive(p, s))
+			}
+			gates = append(gates, steps(p, s, s.Steps, nil)...)
+			for _, b := range s.Post {
+				gates = append(gates, steps(p, s, b.Steps, nil)...)
+			}
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	return gates, nil
+}
+
+func directive(p *plan.Plan, s *plan.Stage) *Gate {
+	in := s.Input
+	g := &Gate{Stage: s.ID, Kind: Directive}
+	g.ID = g.literal(in.ID)
+	g.Message = g.literal(in.Message)
+	g.Ok = g.literal(in.Ok)
+	g.Submitters = submitters(g.literal(in.Submitter))
+	g.SubmitterParameter = g.literal(in.SubmitterParameter)
+	if in.Parameters != nil {
+		for _, param := range in.Parameters.Parameters {
+			if param == nil {
+				continue
+			}
+			if name := param.Args().Get("name"); name != nil {
+				g.Parameters = append(g.Parameters, g.literal(name))
+			}
+		}
+	}
+	stageTimeouts(p, s, g)
+
+	// The input directive is evaluated before the stage's own agent is allocated, so it only holds an executor if
+	// an enclosing stage or the pipeline has an agent.
+	parent := p.Agent
+	if len(s.Path) > 1 {
+		parent = p.Find(plan.ID(s.Path[:len(s.Path)-1])).Agent
+	}
+	g.HoldsExecutor = holds(parent)
+	return g
+}
+
+func steps(p *plan.Plan, s *plan.Stage, stepList []*plan.Step, timeouts []time.Duration) []*Gate {
+	var gates []*Gate
+	for _, st := range stepList {
+		if st.Name == "input" && st.Source != nil && st.Source.Step != nil {
+			gates = append(gates, step(p, s, st.Source.Step.Arguments, timeouts))
+		}
+		inner := timeouts
+		if st.Name == "timeout" && st.Source != nil && st.Source.Tree != nil {
+			if d, ok := plan.StepTimeout(st.Source.Tree.Arguments); ok {
+				inner = append(append([]time.Duration{}, timeouts...), d)
+			}
+		}
+		gates = append(gates, steps(p, s, st.Children, inner)...)
+	}
+	return gates
+}
+
+func step(p *plan.Plan, s *plan.Stage, args *model.ArgumentList, timeouts []time.Duration) *Gate {
+	g := &Gate{Stage: s.ID, Kind: Step, HoldsExecutor: holds(s.Agent)}
+	g.Message = g.literal(args.Get("message"))
+	if args != nil && args.Single == nil {
+		g.ID = g.literal(args.Get("id"))
+		g.Ok = g.literal(args.Get("ok"))
+		g.Submitters = submitters(g.literal(args.Get("submitter")))
+		g.SubmitterParameter = g.literal(args.Get("submitterParameter"))
+		if args.Get("parameters") != nil {
+			// Input step parameters are Groovy expressions, so their names aren't known.
+			g.Dynamic = true
+		}
+	}
+	stageTimeouts(p, s, g)
+	for _, d := range timeouts {
+		if g.Timeout == nil || d < *g.Timeout {
+			d := d
+			g.Timeout, g.TimeoutSource = &d, "timeout step"
+		}
+	}
+	return g
+}
+
+// stageTimeouts sets the gate's timeout to the shortest of the pipeline's timeout option and the timeout options of
+// the stage and its enclosing stages.
+func stageTimeouts(p *plan.Plan, s *plan.Stage, g *Gate) {
+	if d, ok := p.Timeout(); ok {
+		g.Timeout, g.TimeoutSource = &d, "pipeline"
+	}
+	for i := range s.Path {
+		enclosing := p.Find(plan.ID(s.Path[:i+1]))
+		if d, ok := enclosing.Timeout(); ok && (g.Timeout == nil || d < *g.Timeout) {
+			g.Timeout, g.TimeoutSource = &d, enclosing.ID
+		}
+	}
+}
+
+func holds(a *plan.Agent) bool {
+	return a != nil && a.Type != "none"
+}
+
+func (strct *Gate) literal(a *model.RawArgument) string {
+	if a == nil {
+		return ""
+	}
+	if !a.IsLiteral {
+		strct.Dynamic = true
+	}
+	return a.String()
+}
+
+func submitters(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
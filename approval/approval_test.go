@@ -0,0 +1,60 @@
+package approval
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGates(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "gates.json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+
+	gates, err := Gates(root)
+	require.NoError(t, err)
+	require.Len(t, gates, 3)
+
+	thirty, five, two := 30*time.Minute, 5*time.Minute, 2*time.Hour
+	assert.Equal(t, &Gate{
+		Stage:              "Approve",
+		Kind:               Directive,
+		Message:            "Deploy?",
+		Ok:                 "Yes",
+		Submitters:         []string{"alice", "release-managers"},
+		SubmitterParameter: "APPROVER",
+		Parameters:         []string{"REASON"},
+		Timeout:            &thirty,
+		TimeoutSource:      "Approve",
+		HoldsExecutor:      false,
+	}, gates[0])
+	assert.False(t, gates[0].AnyoneMaySubmit())
+
+	assert.Equal(t, &Gate{
+		Stage:         "Deploy",
+		Kind:          Step,
+		Message:       "Really?",
+		Timeout:       &five,
+		TimeoutSource: "timeout step",
+		HoldsExecutor: true,
+	}, gates[1])
+	assert.True(t, gates[1].AnyoneMaySubmit())
+
+	assert.Equal(t, &Gate{
+		Stage:         "Deploy",
+		Kind:          Step,
+		Message:       "Last chance",
+		Submitters:    []string{"env.ADMINS"},
+		Timeout:       &two,
+		TimeoutSource: "pipeline",
+		HoldsExecutor: true,
+		Dynamic:       true,
+	}, gates[2])
+}
@@ -0,0 +1,153 @@
+// Package audit validates and converts fleets of pipelines. Incremental audits re-check only the pipelines whose
+// canonical hash has changed since a previous audit, and carry over the results of the rest, so auditing a large fleet
+// again takes as long as checking what changed.
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/abayer/go-jenkinsfile/convert"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/store"
+	"github.com/abayer/go-jenkinsfile/validate"
+)
+
+// Pipeline is a pipeline to audit
+type Pipeline struct {
+	Repo   string
+	Branch string
+	Root   *model.Root
+}
+
+// Options change what an audit checks
+type Options struct {
+	// Targets are the conversion targets each pipeline is converted to.
+	Targets []string `json:"targets,omitempty"`
+	// Convert are the options for the conversions.
+	Convert convert.Options `json:"convert"`
+}
+
+// Conversion is the result of converting a pipeline to a target
+type Conversion struct {
+	Target string `json:"target"`
+	// Path is where the target system expects the configuration, if the conversion succeeded.
+	Path     string   `json:"path,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is why the conversion failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Result is the audit of a single pipeline
+type Result struct {
+	// Key identifies the pipeline, with its fingerprint from model.Hash.
+	Key store.Key `json:"key"`
+	// Errors are the pipeline's violations of Declarative semantics, from validate.Pipeline.
+	Errors      []validate.ValidationError `json:"errors"`
+	Conversions []*Conversion              `json:"conversions,omitempty"`
+	// Reused is true if the result was carried over from the previous audit, because a pipeline with the same
+	// fingerprint was audited then.
+	Reused bool `json:"reused,omitempty"`
+}
+
+// Report is the audit of a fleet of pipelines
+type Report struct {
+	Options *Options `json:"options"`
+	// Results are the results for each pipeline, sorted by repository and branch.
+	Results []*Result `json:"results"`
+	// Audited counts the pipelines which were validated and converted, rather than reused.
+	Audited int `json:"audited"`
+	// Reused counts the pipelines whose results were carried over from the previous audit.
+	Reused int `json:"reused"`
+}
+
+// Run audits every pipeline. opts may be nil. It returns an error if a pipeline is missing or given twice, or a
+// target isn't a registered converter; a pipeline failing to convert is recorded in its result instead.
+func Run(pipelines []*Pipeline, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	for _, target := range opts.Targets {
+		if _, ok := convert.Get(target); !ok {
+			return nil, fmt.Errorf("unknown conversion target %q", target)
+		}
+	}
+	return run(pipelines, opts, nil)
+}
+
+// Incremental audits the current pipelines with the options of the previous audit, validating and converting only
+// those whose fingerprint no pipeline had then. The others get a copy of the earlier result, so a pipeline copied to
+// another branch or repository isn't audited again either. A nil prev audits everything, with no conversions.
+func Incremental(prev *Report, current []*Pipeline) (*Report, error) {
+	if prev == nil {
+		return Run(current, nil)
+	}
+	if prev.Options == nil {
+		return nil, errors.New("the previous report has no options")
+	}
+	previous := map[string]*Result{}
+	for _, r := range prev.Results {
+		if r != nil {
+			previous[r.Key.Fingerprint] = r
+		}
+	}
+	return run(current, prev.Options, previous)
+}
+
+func run(pipelines []*Pipeline, opts *Options, previous map[string]*Result) (*Report, error) {
+	report := &Report{Options: opts, Results: []*Result{}}
+	seen := map[string]bool{}
+	for _, p := range pipelines {
+		if p == nil || p.Root == nil || p.Root.Pipeline == nil {
+			return nil, errors.New("a root with a pipeline is required for each pipeline")
+		}
+		key := store.Key{Repo: p.Repo, Branch: p.Branch}
+		id := key.Repo + "@" + key.Branch
+		if seen[id] {
+			return nil, fmt.Errorf("pipeline %s is given more than once", id)
+		}
+		seen[id] = true
+		var err error
+		if key.Fingerprint, err = model.Hash(p.Root); err != nil {
+			return nil, fmt.Errorf("%s: %s", id, err)
+		}
+
+		if r, ok := previous[key.Fingerprint]; ok {
+			reused := *r
+			reused.Key, reused.Reused = key, true
+			report.Results = append(report.Results, &reused)
+			report.Reused++
+			continue
+		}
+		report.Results = append(report.Results, audit(key, p.Root, opts))
+		report.Audited++
+	}
+	sort.Slice(report.Results, func(i, j int) bool {
+		a, b := report.Results[i].Key, report.Results[j].Key
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Branch < b.Branch
+	})
+	return report, nil
+}
+
+// audit validates and converts a single pipeline
+func audit(key store.Key, root *model.Root, opts *Options) *Result {
+	r := &Result{Key: key, Errors: validate.Pipeline(root)}
+	if r.Errors == nil {
+		r.Errors = []validate.ValidationError{}
+	}
+	for _, target := range opts.Targets {
+		c := &Conversion{Target: target}
+		res, err := convert.Convert(target, root, opts.Convert)
+		if err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Path, c.Warnings = res.Path, res.Warnings
+		}
+		r.Conversions = append(r.Conversions, c)
+	}
+	return r
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make %TARGET%'
+            }
+        }
+    }
+}`
+
+func parse(t *testing.T, target string) *model.Root {
+	root, err := parser.Parse(strings.NewReader(strings.Replace(source, "%TARGET%", target, 1)))
+	require.NoError(t, err)
+	return root
+}
+
+func TestIncremental(t *testing.T) {
+	first, err := Run([]*Pipeline{
+		{Repo: "org/app", Branch: "main", Root: parse(t, "app")},
+		{Repo: "org/lib", Branch: "main", Root: parse(t, "lib")},
+	}, &Options{Targets: []string{"codefresh"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.Audited)
+	assert.Equal(t, 0, first.Reused)
+	require.Len(t, first.Results, 2)
+	assert.Equal(t, "org/app", first.Results[0].Key.Repo)
+	assert.Empty(t, first.Results[0].Errors)
+	require.Len(t, first.Results[0].Conversions, 1)
+	assert.Equal(t, "codefresh", first.Results[0].Conversions[0].Target)
+	assert.Empty(t, first.Results[0].Conversions[0].Error)
+
+	// Reports are read back from storage between audits.
+	b, err := json.Marshal(first)
+	require.NoError(t, err)
+	prev := &Report{}
+	require.NoError(t, json.Unmarshal(b, prev))
+
+	changed := parse(t, "lib all")
+	second, err := Incremental(prev, []*Pipeline{
+		{Repo: "org/app", Branch: "main", Root: parse(t, "app")},
+		{Repo: "org/app", Branch: "feature", Root: parse(t, "app")},
+		{Repo: "org/lib", Branch: "main", Root: changed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, second.Audited)
+	assert.Equal(t, 2, second.Reused)
+	assert.Equal(t, []string{"codefresh"}, second.Options.Targets)
+
+	require.Len(t, second.Results, 3)
+	feature, main, lib := second.Results[0], second.Results[1], second.Results[2]
+	assert.Equal(t, "feature", feature.Key.Branch)
+	assert.True(t, feature.Reused)
+	assert.Equal(t, first.Results[0].Key.Fingerprint, feature.Key.Fingerprint)
+	assert.Equal(t, first.Results[0].Conversions, feature.Conversions)
+	assert.True(t, main.Reused)
+	assert.False(t, lib.Reused)
+	h, err := model.Hash(changed)
+	require.NoError(t, err)
+	assert.Equal(t, h, lib.Key.Fingerprint)
+	assert.NotEqual(t, first.Results[1].Key.Fingerprint, lib.Key.Fingerprint)
+	assert.Len(t, lib.Conversions, 1)
+}
+
+func TestIncrementalWithoutPrevious(t *testing.T) {
+	r, err := Incremental(nil, []*Pipeline{{Repo: "app", Branch: "main", Root: parse(t, "app")}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.Audited)
+	assert.Empty(t, r.Results[0].Conversions)
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := Run(nil, &Options{Targets: []string{"travis"}})
+	assert.EqualError(t, err, `unknown conversion target "travis"`)
+
+	_, err = Run([]*Pipeline{
+		{Repo: "app", Branch: "main", Root: parse(t, "app")},
+		{Repo: "app", Branch: "main", Root: parse(t, "lib")},
+	}, nil)
+	assert.EqualError(t, err, "pipeline app@main is given more than once")
+
+	_, err = Run([]*Pipeline{{Repo: "app", Branch: "main", Root: &model.Root{}}}, nil)
+	assert.EqualError(t, err, "a root with a pipeline is required for each pipeline")
+
+	_, err = Incremental(&Report{}, nil)
+	assert.EqualError(t, err, "the previous report has no options")
+}
+
+func TestRunValidates(t *testing.T) {
+	root := parse(t, "app")
+	root.Pipeline.Stages = append(root.Pipeline.Stages, root.Pipeline.Stages[0].DeepCopy())
+	r, err := Run([]*Pipeline{{Repo: "app", Branch: "main", Root: root}}, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, r.Results[0].Errors)
+}
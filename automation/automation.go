@@ -0,0 +1,133 @@
+// Package automation opens pull requests updating pipelines across many repositories, the last step of platform-wide
+// changes made with the transform package: each modified pipeline is rendered back to its Jenkinsfile, committed to a
+// new branch, and proposed with a summary of the changes.
+package automation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/transform"
+	"github.com/abayer/go-jenkinsfile/writer"
+)
+
+// SCM is a source code host to open pull requests on. webhook.GitHub and webhook.GitLab are SCMs.
+type SCM interface {
+	// Fetch returns the contents of a file on a branch or at a commit.
+	Fetch(repo, ref, path string) ([]byte, error)
+	// CreateBranch creates a branch starting at the head of base.
+	CreateBranch(repo, branch, base string) error
+	// WriteFile commits a file's new contents to a branch.
+	WriteFile(repo, branch, path string, content []byte, message string) error
+	// OpenPullRequest opens a pull request merging head into base, and returns its URL.
+	OpenPullRequest(repo, head, base, title, description string) (string, error)
+}
+
+// Update is a modified pipeline to propose for a repository
+type Update struct {
+	Repo string
+	// Base is the branch to propose the change to, such as the repository's default branch.
+	Base string
+	// Path is the path of the Jenkinsfile in the repository.
+	Path string
+	// Root is the modified pipeline.
+	Root *model.Root
+	// Source is the Jenkinsfile Root was parsed from with parser.ParseSource, if it was, so that the parts of it which
+	// haven't changed keep their text and comments. Without it, the whole Jenkinsfile is rendered anew.
+	Source *parser.Source
+}
+
+// Options describe the pull requests to open
+type Options struct {
+	// Branch is the name of the branch to create in each repository.
+	Branch string
+	// Title is the title of the pull requests.
+	Title string
+	// Message is the commit message. It defaults to Title.
+	Message string
+	// Description comes before the summary of the changes in the pull requests' descriptions.
+	Description string
+}
+
+// Result is the outcome of proposing an update
+type Result struct {
+	Repo string `json:"repo"`
+	// URL is the pull request's URL, if one was opened.
+	URL     string             `json:"url,omitempty"`
+	Changes *transform.Changes `json:"changes,omitempty"`
+	// Skipped says why no pull request was needed, such as because the pipeline hasn't changed.
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Open proposes each update, returning their results in order. A pipeline is compared with the Jenkinsfile on its
+// base branch, and if they differ, it's rendered and committed to a new branch, and a pull request is opened with the
+// change summary from transform.Summarize. An update failing is recorded in its result, and doesn't stop the others.
+// It returns an error if the options lack a branch or title.
+func Open(scm SCM, updates []*Update, opts *Options) ([]*Result, error) {
+	if opts == nil || opts.Branch == "" || opts.Title == "" {
+		return nil, errors.New("a branch and title are required")
+	}
+	var out []*Result
+	for _, u := range updates {
+		r := &Result{Repo: u.Repo}
+		if err := open(scm, u, opts, r); err != nil {
+			r.Error = err.Error()
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func open(scm SCM, u *Update, opts *Options, r *Result) error {
+	if u.Root == nil || u.Root.Pipeline == nil {
+		return errors.New("a root with a pipeline is required")
+	}
+	current, err := scm.Fetch(u.Repo, u.Base, u.Path)
+	if err != nil {
+		return err
+	}
+	if u.Source != nil && u.Source.Text != string(current) {
+		return fmt.Errorf("%s has changed on %s since it was parsed", u.Path, u.Base)
+	}
+	before, err := parser.Parse(bytes.NewReader(current))
+	if err != nil {
+		return fmt.Errorf("%s on %s: %s", u.Path, u.Base, err)
+	}
+	if r.Changes, err = transform.Summarize(before, u.Root); err != nil {
+		return err
+	}
+	if r.Changes.Empty() {
+		r.Skipped = "the pipeline hasn't changed"
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	if u.Source != nil {
+		err = writer.Rewrite(u.Root, u.Source, buf)
+	} else {
+		err = writer.Write(u.Root, buf)
+	}
+	if err != nil {
+		return err
+	}
+	message := opts.Message
+	if message == "" {
+		message = opts.Title
+	}
+	if err := scm.CreateBranch(u.Repo, opts.Branch, u.Base); err != nil {
+		return err
+	}
+	if err := scm.WriteFile(u.Repo, opts.Branch, u.Path, buf.Bytes(), message); err != nil {
+		return err
+	}
+	description := r.Changes.Markdown()
+	if opts.Description != "" {
+		description = opts.Description + "\n\n" + description
+	}
+	r.URL, err = scm.OpenPullRequest(u.Repo, opts.Branch, u.Base, opts.Title, description)
+	return err
+}
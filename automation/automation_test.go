@@ -0,0 +1,127 @@
+package automation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/transform"
+	"github.com/abayer/go-jenkinsfile/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ SCM = &webhook.GitHub{}
+	_ SCM = &webhook.GitLab{}
+)
+
+const jenkinsfile = `pipeline {
+    agent any
+    stages {
+        stage('Deploy') {
+            steps {
+                // Applies the plan
+                sh 'terraform apply'
+            }
+        }
+    }
+}
+`
+
+// fakeSCM holds the files of each repository's branches, keyed by repo, branch, and path
+type fakeSCM struct {
+	files map[string]string
+	pulls []string
+}
+
+func (f *fakeSCM) Fetch(repo, ref, path string) ([]byte, error) {
+	content, ok := f.files[repo+"@"+ref+":"+path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(content), nil
+}
+
+func (f *fakeSCM) CreateBranch(repo, branch, base string) error {
+	for key, content := range f.files {
+		if strings.HasPrefix(key, repo+"@"+base+":") {
+			f.files[repo+"@"+branch+":"+strings.TrimPrefix(key, repo+"@"+base+":")] = content
+		}
+	}
+	return nil
+}
+
+func (f *fakeSCM) WriteFile(repo, branch, path string, content []byte, message string) error {
+	f.files[repo+"@"+branch+":"+path] = string(content)
+	return nil
+}
+
+func (f *fakeSCM) OpenPullRequest(repo, head, base, title, description string) (string, error) {
+	f.pulls = append(f.pulls, repo+" "+head+"->"+base+" "+title+"\n"+description)
+	return "https://scm.example.com/" + repo + "/pull/1", nil
+}
+
+func TestOpen(t *testing.T) {
+	scm := &fakeSCM{files: map[string]string{
+		"org/api@main:Jenkinsfile": jenkinsfile,
+		"org/web@main:Jenkinsfile": jenkinsfile,
+	}}
+	rules, err := transform.ParseWrapRules([]byte("rules:\n- script: terraform apply\n  retry: 2\n"))
+	require.NoError(t, err)
+
+	root, source, err := parser.ParseSource(strings.NewReader(jenkinsfile))
+	require.NoError(t, err)
+	_, err = transform.Wrap(root, rules)
+	require.NoError(t, err)
+	unchanged, err := parser.Parse(strings.NewReader(jenkinsfile))
+	require.NoError(t, err)
+
+	results, err := Open(scm, []*Update{
+		{Repo: "org/api", Base: "main", Path: "Jenkinsfile", Root: root, Source: source},
+		{Repo: "org/web", Base: "main", Path: "Jenkinsfile", Root: unchanged},
+		{Repo: "org/docs", Base: "main", Path: "Jenkinsfile", Root: unchanged},
+	}, &Options{Branch: "retry-terraform", Title: "Retry terraform apply", Description: "Retries flaky applies."})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "https://scm.example.com/org/api/pull/1", results[0].URL)
+	assert.Equal(t, `pipeline {
+    agent any
+    stages {
+        stage('Deploy') {
+            steps {
+                retry(2) {
+                    // Applies the plan
+                    sh 'terraform apply'
+                }
+            }
+        }
+    }
+}
+`, scm.files["org/api@retry-terraform:Jenkinsfile"])
+	assert.Equal(t, []string{"org/api retry-terraform->main Retry terraform apply\n" +
+		"Retries flaky applies.\n\n- Stage `Deploy` changed: steps\n"}, scm.pulls)
+
+	assert.Equal(t, "the pipeline hasn't changed", results[1].Skipped)
+	assert.Empty(t, results[1].URL)
+	assert.Equal(t, "not found", results[2].Error)
+}
+
+func TestOpenChangedSince(t *testing.T) {
+	root, source, err := parser.ParseSource(strings.NewReader(jenkinsfile))
+	require.NoError(t, err)
+	scm := &fakeSCM{files: map[string]string{"org/api@main:Jenkinsfile": "// Updated\n" + jenkinsfile}}
+	results, err := Open(scm, []*Update{{Repo: "org/api", Base: "main", Path: "Jenkinsfile", Root: root,
+		Source: source}}, &Options{Branch: "b", Title: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, "Jenkinsfile has changed on main since it was parsed", results[0].Error)
+}
+
+func TestOpenOptions(t *testing.T) {
+	_, err := Open(&fakeSCM{}, nil, &Options{Title: "t"})
+	assert.EqualError(t, err, "a branch and title are required")
+	_, err = Open(&fakeSCM{}, nil, nil)
+	assert.EqualError(t, err, "a branch and title are required")
+}
@@ -0,0 +1,206 @@
+// Package bundle packs a pipeline and the results of analysing it into a single JSON or YAML artifact, so systems
+// downstream of a build can ingest one document rather than calling each analysis themselves.
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/aggregate"
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/dedupe"
+	"github.com/abayer/go-jenkinsfile/features"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/validate"
+	"sigs.k8s.io/yaml"
+)
+
+// Version is the version of the bundle format Build produces. It changes when a change to the format would break
+// readers of older bundles.
+const Version = 1
+
+// Format is a bundle's serialization
+type Format string
+
+const (
+	// JSON is the bundle as a JSON document
+	JSON Format = "json"
+	// YAML is the bundle as a YAML document, with the same structure as its JSON
+	YAML Format = "yaml"
+)
+
+// Options change what Build puts in a bundle
+type Options struct {
+	// Name identifies the pipeline, such as by its repository and path, and names it in the inventory.
+	Name string
+	// SizeBudget is the budget the pipeline's size is linted against.
+	SizeBudget analysis.SizeBudget
+}
+
+// Bundle is a pipeline with the results of analysing it
+type Bundle struct {
+	Version int    `json:"version"`
+	Name    string `json:"name,omitempty"`
+	// Fingerprint is the pipeline's fingerprint, as from dedupe.Fingerprint.
+	Fingerprint string               `json:"fingerprint"`
+	Model       *model.Root          `json:"model"`
+	Metrics     *Metrics             `json:"metrics"`
+	Inventory   *aggregate.Inventory `json:"inventory"`
+	Lint        *Lint                `json:"lint"`
+	// Plan is the pipeline's execution plan. Bundles read with Unmarshal have only the plan's serialized fields, and
+	// not its links back to the model.
+	Plan *plan.Plan `json:"plan"`
+}
+
+// Metrics measure the pipeline's size and shape
+type Metrics struct {
+	Size *analysis.SizeEstimate `json:"size"`
+	// Depth is the deepest nesting of stages, with the pipeline's own stages at depth 1.
+	Depth int `json:"depth"`
+	// Width is the most stages running at once in a single parallel or matrix stage, counting matrix cells, or 1
+	// for pipelines with neither.
+	Width    int          `json:"width"`
+	Features features.Set `json:"features"`
+}
+
+// Lint is what's wrong with the pipeline
+type Lint struct {
+	// Errors are the pipeline's violations of Declarative semantics, from validate.Pipeline.
+	Errors []validate.ValidationError `json:"errors"`
+	// Findings are the problems the analyzers which need nothing but the pipeline report: duplicate options,
+	// parameters, size, milestones, and sandbox approvals.
+	Findings []*analysis.Finding `json:"findings"`
+}
+
+// Build analyses a pipeline and bundles it with the results. The bundle holds a copy of the pipeline, so later
+// changes to root don't change it. opts may be nil.
+func Build(root *model.Root, opts *Options) (*Bundle, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	b := &Bundle{Version: Version, Name: opts.Name, Model: root.DeepCopy()}
+	var err error
+	if b.Fingerprint, err = dedupe.Fingerprint(b.Model); err != nil {
+		return nil, fmt.Errorf("fingerprint: %s", err)
+	}
+	if b.Plan, err = plan.Build(b.Model); err != nil {
+		return nil, fmt.Errorf("plan: %s", err)
+	}
+	if b.Inventory, err = aggregate.Collect(opts.Name, b.Model); err != nil {
+		return nil, fmt.Errorf("inventory: %s", err)
+	}
+	if b.Metrics, err = measure(b.Model, b.Plan); err != nil {
+		return nil, fmt.Errorf("metrics: %s", err)
+	}
+	if b.Lint, err = lint(b.Model, b.Plan, b.Metrics.Size, opts.SizeBudget); err != nil {
+		return nil, fmt.Errorf("lint: %s", err)
+	}
+	return b, nil
+}
+
+func measure(root *model.Root, p *plan.Plan) (*Metrics, error) {
+	size, err := analysis.EstimateSize(p)
+	if err != nil {
+		return nil, err
+	}
+	used, err := features.Detect(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metrics{Size: size, Width: 1, Features: used}
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			if len(s.Path) > m.Depth {
+				m.Depth = len(s.Path)
+			}
+			width := 0
+			switch s.ChildMode {
+			case plan.Parallel:
+				width = len(s.Children)
+			case plan.Matrix:
+				width = cells(s.Axes)
+			}
+			if width > m.Width {
+				m.Width = width
+			}
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	return m, nil
+}
+
+// cells returns the number of cells of a matrix's axes, before excludes
+func cells(axes []*model.Axis) int {
+	n := 1
+	for _, a := range axes {
+		if a != nil {
+			n *= len(a.Values)
+		}
+	}
+	return n
+}
+
+func lint(root *model.Root, p *plan.Plan, size *analysis.SizeEstimate, budget analysis.SizeBudget) (*Lint, error) {
+	l := &Lint{Errors: validate.Pipeline(root), Findings: []*analysis.Finding{}}
+	if l.Errors == nil {
+		l.Errors = []validate.ValidationError{}
+	}
+	for _, analyze := range []func(*model.Root) ([]*analysis.Finding, error){
+		analysis.DuplicateOptions,
+		analysis.Parameters,
+	} {
+		findings, err := analyze(root)
+		if err != nil {
+			return nil, err
+		}
+		l.Findings = append(l.Findings, findings...)
+	}
+	l.Findings = append(l.Findings, budget.Check(size)...)
+	l.Findings = append(l.Findings, analysis.Milestones(p)...)
+	l.Findings = append(l.Findings, analysis.SandboxFindings(analysis.SandboxSignatures(p))...)
+	return l, nil
+}
+
+// MarshalJSON marshals the bundle, with its model marshaled by model.Marshal, so that a union with none of its
+// alternatives set fails with its path rather than without one.
+func (strct *Bundle) MarshalJSON() ([]byte, error) {
+	type bundle Bundle
+	m, err := model.Marshal(strct.Model, model.StrictMarshal())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&struct {
+		*bundle
+		Model json.RawMessage `json:"model"`
+	}{bundle: (*bundle)(strct), Model: m})
+}
+
+// Marshal returns the bundle in the given format.
+func (strct *Bundle) Marshal(format Format) ([]byte, error) {
+	switch format {
+	case JSON:
+		return json.MarshalIndent(strct, "", "  ")
+	case YAML:
+		return yaml.Marshal(strct)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Unmarshal reads a bundle in either format. It returns an error for bundles from a later version of the format.
+func Unmarshal(content []byte) (*Bundle, error) {
+	b := &Bundle{}
+	if err := yaml.Unmarshal(content, b); err != nil {
+		return nil, err
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("bundle version %d is newer than the supported version %d", b.Version, Version)
+	}
+	return b, nil
+}
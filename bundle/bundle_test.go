@@ -0,0 +1,141 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/dedupe"
+	"github.com/abayer/go-jenkinsfile/features"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pipeline = `pipeline {
+    agent { label 'linux' }
+    options {
+        timeout(time: 1, unit: 'HOURS')
+        timeout(time: 2, unit: 'HOURS')
+    }
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make'
+            }
+        }
+        stage('Test') {
+            parallel {
+                stage('Unit') {
+                    steps {
+                        sh 'make unit'
+                    }
+                }
+                stage('Integration') {
+                    steps {
+                        sh 'make integration'
+                    }
+                }
+            }
+        }
+    }
+}`
+
+func build(t *testing.T) *Bundle {
+	root, err := parser.Parse(strings.NewReader(pipeline))
+	require.NoError(t, err)
+	b, err := Build(root, &Options{Name: "app"})
+	require.NoError(t, err)
+	return b
+}
+
+func TestBuild(t *testing.T) {
+	b := build(t)
+	assert.Equal(t, Version, b.Version)
+	assert.Equal(t, "app", b.Name)
+
+	fp, err := dedupe.Fingerprint(b.Model)
+	require.NoError(t, err)
+	assert.Equal(t, fp, b.Fingerprint)
+
+	assert.Equal(t, 2, b.Metrics.Depth)
+	assert.Equal(t, 2, b.Metrics.Width)
+	assert.True(t, b.Metrics.Features.Has(features.ParallelStages))
+	assert.True(t, b.Metrics.Size.Bytes > 0)
+
+	assert.Equal(t, "app", b.Inventory.Name)
+	assert.Equal(t, 4, b.Inventory.Stages)
+	assert.Equal(t, map[string]int{"sh": 3}, b.Inventory.Steps)
+
+	assert.Empty(t, b.Lint.Errors)
+	var rules []string
+	for _, f := range b.Lint.Findings {
+		rules = append(rules, f.Rule)
+	}
+	assert.Contains(t, rules, "duplicate-option")
+
+	require.Len(t, b.Plan.Stages, 2)
+	assert.Equal(t, "Test", b.Plan.Stages[1].ID)
+
+	_, err = Build(&model.Root{}, nil)
+	assert.EqualError(t, err, "a root with a pipeline is required")
+}
+
+func TestBuildCopiesModel(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(pipeline))
+	require.NoError(t, err)
+	b, err := Build(root, nil)
+	require.NoError(t, err)
+	root.Pipeline.Stages[0].Name = "Compile"
+	assert.Equal(t, "Build", b.Model.Pipeline.Stages[0].Name)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	b := build(t)
+	for _, format := range []Format{JSON, YAML} {
+		t.Run(string(format), func(t *testing.T) {
+			content, err := b.Marshal(format)
+			require.NoError(t, err)
+			read, err := Unmarshal(content)
+			require.NoError(t, err)
+
+			assert.Equal(t, b.Fingerprint, read.Fingerprint)
+			assert.Equal(t, b.Metrics, read.Metrics)
+			assert.Equal(t, b.Inventory, read.Inventory)
+			assert.Equal(t, b.Lint, read.Lint)
+			fp, err := dedupe.Fingerprint(read.Model)
+			require.NoError(t, err)
+			assert.Equal(t, b.Fingerprint, fp)
+			assert.Equal(t, len(b.Plan.Stages), len(read.Plan.Stages))
+		})
+	}
+
+	_, err := b.Marshal("xml")
+	assert.EqualError(t, err, `unknown format "xml"`)
+}
+
+func TestMarshalEmptyUnion(t *testing.T) {
+	b := build(t)
+	b.Model.Pipeline.Stages[0].Branches[0].Steps[0] = &model.AnyStep{}
+	_, err := b.Marshal(JSON)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/pipeline/stages/0/branches/0/steps/0: AnyStep has none of its alternatives set")
+}
+
+func TestUnmarshalNewerVersion(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"version": 2}`))
+	assert.EqualError(t, err, "bundle version 2 is newer than the supported version 1")
+}
+
+func TestLintSizeBudget(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(pipeline))
+	require.NoError(t, err)
+	b, err := Build(root, &Options{SizeBudget: analysis.SizeBudget{MaxBytes: 10}})
+	require.NoError(t, err)
+	var rules []string
+	for _, f := range b.Lint.Findings {
+		rules = append(rules, f.Rule)
+	}
+	assert.Contains(t, rules, "pipeline-size")
+}
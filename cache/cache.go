@@ -0,0 +1,121 @@
+// Package cache provides a thread-safe, lazily loaded cache with expiry, for metadata such as descriptor catalogs and
+// plugin maps which are expensive to fetch from Jenkins and rarely change. Shared caches are process-wide, so servers
+// embedding the validator load the metadata once rather than on every request.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Loader loads the value for a key. It is called at most once at a time for each key.
+type Loader func(key string) (interface{}, error)
+
+// Cache holds values loaded on first use, until they expire or are invalidated. Errors returned by the loader are not
+// cached, so the next Get for the key tries again.
+type Cache struct {
+	ttl  time.Duration
+	load Loader
+
+	lock    sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+type entry struct {
+	// done is closed once the load has completed
+	done    chan struct{}
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// New creates a cache which loads values with load and keeps them for ttl. A ttl of zero or less keeps values until
+// they are invalidated.
+func New(ttl time.Duration, load Loader) *Cache {
+	return &Cache{ttl: ttl, load: load, entries: make(map[string]*entry), now: time.Now}
+}
+
+// Get returns the value for key, loading it if it isn't cached or has expired. Concurrent calls for the same key wait
+// for a single load.
+func (strct *Cache) Get(key string) (interface{}, error) {
+	strct.lock.Lock()
+	e, ok := strct.entries[key]
+	if ok {
+		select {
+		case <-e.done:
+			if e.err != nil || (strct.ttl > 0 && !strct.now().Before(e.expires)) {
+				ok = false
+			}
+		default:
+			// A load is in progress; wait for it below.
+		}
+	}
+	if !ok {
+		e = &entry{done: make(chan struct{})}
+		strct.entries[key] = e
+		strct.lock.Unlock()
+		strct.fill(key, e)
+		return e.value, e.err
+	}
+	strct.lock.Unlock()
+	<-e.done
+	return e.value, e.err
+}
+
+// fill loads the value for key into e and closes its done channel. If the loader panics, calls waiting for the load
+// get an error, which isn't cached, and the panic continues.
+func (strct *Cache) fill(key string, e *entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.value, e.err = nil, fmt.Errorf("loading %s panicked: %v", key, r)
+			close(e.done)
+			panic(r)
+		}
+	}()
+	e.value, e.err = strct.load(key)
+	e.expires = strct.now().Add(strct.ttl)
+	close(e.done)
+}
+
+// Invalidate removes the value for key, so the next Get loads it again. A load already in progress is not affected.
+func (strct *Cache) Invalidate(key string) {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	delete(strct.entries, key)
+}
+
+// InvalidateAll removes all values from the cache.
+func (strct *Cache) InvalidateAll() {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	strct.entries = make(map[string]*entry)
+}
+
+var (
+	sharedLock sync.Mutex
+	shared     = map[string]*Cache{}
+)
+
+// Shared returns the process-wide cache with the given name, creating it with ttl and load on first use. Later calls
+// with the same name return the existing cache and ignore ttl and load.
+func Shared(name string, ttl time.Duration, load Loader) *Cache {
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+	c, ok := shared[name]
+	if !ok {
+		c = New(ttl, load)
+		shared[name] = c
+	}
+	return c
+}
+
+// InvalidateShared removes all values from every process-wide cache.
+func InvalidateShared() {
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+	for _, c := range shared {
+		c.InvalidateAll()
+	}
+}
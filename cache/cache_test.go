@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetExpiresAndInvalidates(t *testing.T) {
+	loads := 0
+	c := New(time.Minute, func(key string) (interface{}, error) {
+		loads++
+		return key + "-value", nil
+	})
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	v, err := c.Get("plugins")
+	require.NoError(t, err)
+	assert.Equal(t, "plugins-value", v)
+	_, _ = c.Get("plugins")
+	assert.Equal(t, 1, loads)
+
+	now = now.Add(time.Minute)
+	_, _ = c.Get("plugins")
+	assert.Equal(t, 2, loads)
+
+	c.Invalidate("plugins")
+	_, _ = c.Get("plugins")
+	assert.Equal(t, 3, loads)
+
+	c.InvalidateAll()
+	_, _ = c.Get("plugins")
+	assert.Equal(t, 4, loads)
+}
+
+func TestGetDoesNotCacheErrors(t *testing.T) {
+	fail := true
+	c := New(0, func(key string) (interface{}, error) {
+		if fail {
+			return nil, errors.New("jenkins unavailable")
+		}
+		return "catalog", nil
+	})
+	_, err := c.Get("descriptors")
+	assert.EqualError(t, err, "jenkins unavailable")
+
+	fail = false
+	v, err := c.Get("descriptors")
+	require.NoError(t, err)
+	assert.Equal(t, "catalog", v)
+}
+
+func TestGetLoaderPanics(t *testing.T) {
+	fail := true
+	c := New(0, func(key string) (interface{}, error) {
+		if fail {
+			panic("boom")
+		}
+		return "catalog", nil
+	})
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = c.Get("descriptors")
+	})
+
+	fail = false
+	v, err := c.Get("descriptors")
+	require.NoError(t, err)
+	assert.Equal(t, "catalog", v)
+}
+
+func TestGetConcurrentLoadsOnce(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	c := New(0, func(key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "catalog", nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Get("descriptors")
+			assert.NoError(t, err)
+			assert.Equal(t, "catalog", v)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+func TestShared(t *testing.T) {
+	a := Shared("test", 0, func(string) (interface{}, error) { return 1, nil })
+	b := Shared("test", 0, func(string) (interface{}, error) { return 2, nil })
+	assert.Same(t, a, b)
+	v, err := b.Get("x")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	InvalidateShared()
+}
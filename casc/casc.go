@@ -0,0 +1,229 @@
+// Package casc generates Configuration-as-Code bundles which seed Jenkins controllers with jobs for pipelines. Each
+// pipeline becomes a Job DSL pipelineJob, in the jobs section of the configuration, which loads the Jenkinsfile from
+// its repository. The job's parameters and triggers are seeded from the pipeline, so they are in place before its
+// first build rather than after it.
+package casc
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/abayer/go-jenkinsfile/groovyq"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Job is a pipeline job to seed
+type Job struct {
+	// Name is the job's full name, with any enclosing folders separated by "/". Folders are created as needed.
+	Name        string
+	Description string
+	// Repository is the URL of the git repository holding the Jenkinsfile.
+	Repository string
+	// Branch is the branch to build. It defaults to "*/main".
+	Branch string
+	// Credentials is the ID of the credentials to clone the repository with, if it needs any.
+	Credentials string
+	// ScriptPath is the path of the Jenkinsfile in the repository. It defaults to "Jenkinsfile".
+	ScriptPath string
+	// Root is the pipeline in the Jenkinsfile.
+	Root *model.Root
+}
+
+// Bundle is a generated Configuration-as-Code fragment
+type Bundle struct {
+	// Content is the YAML of the fragment.
+	Content []byte
+	// Warnings describe parameters and triggers which could not be seeded.
+	Warnings []string
+}
+
+// Generate generates a Configuration-as-Code fragment seeding the given jobs, in order
+func Generate(jobs []*Job) (*Bundle, error) {
+	b := &Bundle{}
+	folders := map[string]bool{}
+	var scripts []yaml.MapSlice
+	for i, j := range jobs {
+		if j == nil || j.Name == "" {
+			return nil, fmt.Errorf("job %d: a name is required", i)
+		}
+		if j.Repository == "" {
+			return nil, fmt.Errorf("job %s: a repository is required", j.Name)
+		}
+		if j.Root == nil || j.Root.Pipeline == nil {
+			return nil, fmt.Errorf("job %s: a root with a pipeline is required", j.Name)
+		}
+		w := &writer{}
+		parts := strings.Split(j.Name, "/")
+		for n := 1; n < len(parts); n++ {
+			folder := strings.Join(parts[:n], "/")
+			if !folders[folder] {
+				folders[folder] = true
+				w.line("folder(%s)", groovyq.Quote(folder))
+			}
+		}
+		b.Warnings = append(b.Warnings, w.job(j)...)
+		scripts = append(scripts, yaml.MapSlice{{Key: "script", Value: w.String()}})
+	}
+	content, err := yaml.Marshal(yaml.MapSlice{{Key: "jobs", Value: scripts}})
+	if err != nil {
+		return nil, err
+	}
+	b.Content = content
+	return b, nil
+}
+
+// writer writes indented Job DSL
+type writer struct {
+	buf    strings.Builder
+	indent int
+}
+
+func (w *writer) line(format string, args ...interface{}) {
+	w.buf.WriteString(strings.Repeat("    ", w.indent))
+	w.buf.WriteString(fmt.Sprintf(format, args...))
+	w.buf.WriteString("\n")
+}
+
+func (w *writer) block(format string, args []interface{}, body func()) {
+	w.line(format+" {", args...)
+	w.indent++
+	body()
+	w.indent--
+	w.line("}")
+}
+
+func (w *writer) String() string {
+	return w.buf.String()
+}
+
+func (w *writer) job(j *Job) []string {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("job %s: %s", j.Name, fmt.Sprintf(format, args...)))
+	}
+	branch, scriptPath := j.Branch, j.ScriptPath
+	if branch == "" {
+		branch = "*/main"
+	}
+	if scriptPath == "" {
+		scriptPath = "Jenkinsfile"
+	}
+	p := j.Root.Pipeline
+	w.block("pipelineJob(%s)", []interface{}{groovyq.Quote(j.Name)}, func() {
+		if j.Description != "" {
+			w.line("description(%s)", groovyq.Quote(j.Description))
+		}
+		if p.Parameters != nil && len(p.Parameters.Parameters) > 0 {
+			w.block("parameters", nil, func() {
+				for _, param := range p.Parameters.Parameters {
+					if line, ok := parameter(param); ok {
+						w.line("%s", line)
+					} else {
+						warn("%s parameters are not seeded", param.Name)
+					}
+				}
+			})
+		}
+		var triggers []*model.MethodCall
+		if p.Triggers != nil {
+			for _, t := range p.Triggers.Triggers {
+				if _, ok := triggerProperties[t.Name]; ok && t.Args().Get("spec") != nil {
+					triggers = append(triggers, t)
+				} else {
+					warn("%s triggers are not seeded", t.Name)
+				}
+			}
+		}
+		if len(triggers) > 0 {
+			w.block("properties", nil, func() {
+				w.block("pipelineTriggers", nil, func() {
+					w.block("triggers", nil, func() {
+						for _, t := range triggers {
+							w.block(t.Name, nil, func() {
+								w.line("%s(%s)", triggerProperties[t.Name], groovyValue(t.Args().Get("spec")))
+							})
+						}
+					})
+				})
+			})
+		}
+		w.block("definition", nil, func() {
+			w.block("cpsScm", nil, func() {
+				w.block("scm", nil, func() {
+					w.block("git", nil, func() {
+						w.block("remote", nil, func() {
+							w.line("url(%s)", groovyq.Quote(j.Repository))
+							if j.Credentials != "" {
+								w.line("credentials(%s)", groovyq.Quote(j.Credentials))
+							}
+						})
+						w.line("branch(%s)", groovyq.Quote(branch))
+					})
+				})
+				w.line("scriptPath(%s)", groovyq.Quote(scriptPath))
+				w.line("lightweight(true)")
+			})
+		})
+	})
+	return warnings
+}
+
+// triggerProperties maps the triggers which can be seeded to the name of their schedule property in Job DSL
+var triggerProperties = map[string]string{
+	"cron":    "spec",
+	"pollSCM": "scmpoll_spec",
+}
+
+// parameter returns the Job DSL for a parameters directive entry, and false if it has no Job DSL equivalent
+func parameter(param *model.MethodCall) (string, bool) {
+	name := param.Args().Get("name")
+	if name == nil {
+		return "", false
+	}
+	description := groovyq.Quote("")
+	if d := param.Args().Get("description"); d != nil {
+		description = groovyValue(d)
+	}
+	defaultValue := func(def string) string {
+		if d := param.Args().Get("defaultValue"); d != nil {
+			return groovyValue(d)
+		}
+		return def
+	}
+	switch param.Name {
+	case "string":
+		return fmt.Sprintf("stringParam(%s, %s, %s)", groovyValue(name), defaultValue("''"), description), true
+	case "text":
+		return fmt.Sprintf("textParam(%s, %s, %s)", groovyValue(name), defaultValue("''"), description), true
+	case "booleanParam":
+		return fmt.Sprintf("booleanParam(%s, %s, %s)", groovyValue(name), defaultValue("false"), description), true
+	case "password":
+		return fmt.Sprintf("nonStoredPasswordParam(%s, %s)", groovyValue(name), description), true
+	case "choice":
+		choices := param.Args().Get("choices")
+		if choices == nil {
+			return "", false
+		}
+		list := choices.String()
+		if choices.IsLiteral {
+			// Declarative allows the choices as a single string, one per line.
+			var quoted []string
+			for _, c := range strings.Split(choices.String(), "\n") {
+				quoted = append(quoted, groovyq.Quote(c))
+			}
+			list = "[" + strings.Join(quoted, ", ") + "]"
+		}
+		return fmt.Sprintf("choiceParam(%s, %s, %s)", groovyValue(name), list, description), true
+	}
+	return "", false
+}
+
+// groovyValue returns an argument as Groovy source: literal strings quoted, and everything else as it is
+func groovyValue(v *model.RawArgument) string {
+	if v.IsLiteral && v.Value != nil && v.Value.AsString != nil {
+		return groovyq.Quote(*v.Value.AsString)
+	}
+	return v.String()
+}
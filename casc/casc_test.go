@@ -0,0 +1,48 @@
+package casc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, name string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestGenerate(t *testing.T) {
+	root := loadRoot(t, "app")
+	bundle, err := Generate([]*Job{
+		{Name: "team/app/release", Description: "Releases the app", Repository: "https://example.com/app.git",
+			Credentials: "git", Root: root},
+		{Name: "team/app/nightly", Repository: "https://example.com/app.git", Branch: "*/develop",
+			ScriptPath: "ci/Jenkinsfile", Root: &model.Root{Pipeline: &model.Pipeline{}}},
+	})
+	require.NoError(t, err)
+
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "jobs.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(bundle.Content))
+	assert.Equal(t, []string{
+		"job team/app/release: run parameters are not seeded",
+		"job team/app/release: upstream triggers are not seeded",
+	}, bundle.Warnings)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	_, err := Generate([]*Job{{Repository: "https://example.com/app.git"}})
+	assert.EqualError(t, err, "job 0: a name is required")
+	_, err = Generate([]*Job{{Name: "app"}})
+	assert.EqualError(t, err, "job app: a repository is required")
+	_, err = Generate([]*Job{{Name: "app", Repository: "https://example.com/app.git"}})
+	assert.EqualError(t, err, "job app: a root with a pipeline is required")
+}
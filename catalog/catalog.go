@@ -0,0 +1,322 @@
+// Package catalog describes the vocabulary of Declarative Pipelines: the sections valid in each context, and the
+// steps, options, triggers, parameters, when conditions, and agent types with their parameters, as Jenkins' descriptors
+// do. It is hand-maintained for Jenkins core and widely used plugins, and can be extended with the steps of other
+// plugins and shared libraries.
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/aggregate"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Contexts in which sections are valid
+const (
+	ContextPipeline = "pipeline"
+	ContextStage    = "stage"
+	ContextMatrix   = "matrix"
+	ContextAxis     = "axis"
+	ContextExclude  = "exclude"
+	ContextInput    = "input"
+	ContextWhen     = "when"
+)
+
+// Parameter is a parameter of a step or symbol
+type Parameter struct {
+	Name string `json:"name"`
+	// Type is the parameter's type: "string", "boolean", "int", "list", "map", "enum", or "object".
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// Default is true for the parameter given when the step or symbol is called with a single unnamed argument.
+	Default bool `json:"default,omitempty"`
+	// Values are the values an enum parameter accepts.
+	Values []string `json:"values,omitempty"`
+}
+
+// Symbol is a step, option, trigger, parameter type, when condition, or agent type
+type Symbol struct {
+	Name string `json:"name"`
+	// Plugin is the short name of the plugin providing the symbol, or "core" for Jenkins core and the plugins every
+	// Pipeline installation has.
+	Plugin string `json:"plugin,omitempty"`
+	// Block is true for symbols which take a block, such as dir or allOf.
+	Block bool `json:"block,omitempty"`
+	// Stage is true for options which are valid in stages as well as the pipeline.
+	Stage      bool         `json:"stage,omitempty"`
+	Parameters []*Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter returns the parameter with the given name, or nil if there's none.
+func (strct *Symbol) Parameter(name string) *Parameter {
+	for _, p := range strct.Parameters {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Catalog is the vocabulary of Declarative Pipelines
+type Catalog struct {
+	// Sections are the directives valid in each context, such as "agent" and "stages" in "pipeline".
+	Sections       map[string][]string `json:"sections"`
+	Steps          []*Symbol           `json:"steps"`
+	Options        []*Symbol           `json:"options"`
+	Triggers       []*Symbol           `json:"triggers"`
+	Parameters     []*Symbol           `json:"parameters"`
+	WhenConditions []*Symbol           `json:"whenConditions"`
+	PostConditions []string            `json:"postConditions"`
+	Agents         []*Symbol           `json:"agents"`
+}
+
+// Step returns the step with the given name, or nil if there's none.
+func (strct *Catalog) Step(name string) *Symbol {
+	return find(strct.Steps, name)
+}
+
+// Option returns the option with the given name, or nil if there's none.
+func (strct *Catalog) Option(name string) *Symbol {
+	return find(strct.Options, name)
+}
+
+func find(symbols []*Symbol, name string) *Symbol {
+	for _, s := range symbols {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSteps adds steps, such as those of a shared library, replacing any with the same name, and keeps the steps
+// sorted by name.
+func (strct *Catalog) AddSteps(steps ...*Symbol) {
+	for _, s := range steps {
+		replaced := false
+		for i, existing := range strct.Steps {
+			if existing.Name == s.Name {
+				strct.Steps[i], replaced = s, true
+			}
+		}
+		if !replaced {
+			strct.Steps = append(strct.Steps, s)
+		}
+	}
+	sortSymbols(strct.Steps)
+}
+
+func sortSymbols(symbols []*Symbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+}
+
+// Default returns a new catalog of Jenkins core and widely used plugins. Each call returns a copy, which callers may
+// extend.
+func Default() *Catalog {
+	c := &Catalog{
+		Sections: map[string][]string{
+			ContextPipeline: {"agent", "environment", "libraries", "options", "parameters", "post", "stages", "tools",
+				"triggers"},
+			ContextStage: {"agent", "environment", "failFast", "input", "matrix", "options", "parallel", "post", "stages",
+				"steps", "tools", "when"},
+			ContextMatrix:  {"agent", "axes", "environment", "excludes", "input", "options", "post", "stages", "tools", "when"},
+			ContextAxis:    {"name", "values"},
+			ContextExclude: {"axis"},
+			ContextInput:   {"id", "message", "ok", "parameters", "submitter", "submitterParameter"},
+			ContextWhen:    {"beforeAgent", "beforeInput", "beforeOptions"},
+		},
+		PostConditions: append([]string{}, model.PostConditions...),
+	}
+	for _, s := range defaultSteps {
+		step := symbol(s)
+		if plugin, ok := aggregate.StepPlugins[step.Name]; ok {
+			step.Plugin = plugin
+		}
+		c.Steps = append(c.Steps, step)
+	}
+	for _, group := range []struct {
+		specs [][]string
+		dst   *[]*Symbol
+	}{
+		{defaultOptions, &c.Options},
+		{defaultTriggers, &c.Triggers},
+		{defaultParameters, &c.Parameters},
+		{defaultWhenConditions, &c.WhenConditions},
+		{defaultAgents, &c.Agents},
+	} {
+		for _, s := range group.specs {
+			*group.dst = append(*group.dst, symbol(s))
+		}
+	}
+	for _, symbols := range [][]*Symbol{c.Steps, c.Options, c.Triggers, c.Parameters, c.WhenConditions, c.Agents} {
+		sortSymbols(symbols)
+	}
+	return c
+}
+
+// symbol builds a symbol from a compact spec: its name, then flags and parameters. Flags are "block", "stage", and
+// "plugin=name". Parameters are "name:type", with "!" after the name if it's required, "=" before
+// it if it's the default parameter, and enum values after the type separated by "|", as in "unit:enum|SECONDS|MINUTES".
+func symbol(spec []string) *Symbol {
+	s := &Symbol{Name: spec[0], Plugin: "core"}
+	for _, part := range spec[1:] {
+		switch {
+		case part == "block":
+			s.Block = true
+		case part == "stage":
+			s.Stage = true
+		case strings.HasPrefix(part, "plugin="):
+			s.Plugin = strings.TrimPrefix(part, "plugin=")
+		default:
+			colon := strings.Index(part, ":")
+			if colon < 0 {
+				panic(fmt.Sprintf("catalog: bad parameter %q of %s", part, s.Name))
+			}
+			p := &Parameter{Name: part[:colon]}
+			types := strings.Split(part[colon+1:], "|")
+			p.Type = types[0]
+			if len(types) > 1 {
+				p.Values = types[1:]
+			}
+			if strings.HasPrefix(p.Name, "=") {
+				p.Name, p.Default = p.Name[1:], true
+			}
+			if strings.HasSuffix(p.Name, "!") {
+				p.Name, p.Required = strings.TrimSuffix(p.Name, "!"), true
+			}
+			s.Parameters = append(s.Parameters, p)
+		}
+	}
+	return s
+}
+
+var timeUnits = "enum|NANOSECONDS|MICROSECONDS|MILLISECONDS|SECONDS|MINUTES|HOURS|DAYS"
+
+var results = "enum|SUCCESS|UNSTABLE|FAILURE|NOT_BUILT|ABORTED"
+
+var defaultSteps = [][]string{
+	{"archiveArtifacts", "=artifacts!:string", "allowEmptyArchive:boolean", "excludes:string", "fingerprint:boolean",
+		"onlyIfSuccessful:boolean"},
+	{"bat", "=script!:string", "encoding:string", "label:string", "returnStatus:boolean", "returnStdout:boolean"},
+	{"build", "=job!:string", "parameters:list", "propagate:boolean", "quietPeriod:int", "wait:boolean"},
+	{"catchError", "block", "buildResult:" + results, "catchInterruptions:boolean", "message:string",
+		"stageResult:" + results},
+	{"checkout", "=scm!:object", "changelog:boolean", "poll:boolean"},
+	{"cleanWs", "deleteDirs:boolean", "disableDeferredWipeout:boolean", "notFailBuild:boolean", "patterns:list"},
+	{"deleteDir"},
+	{"dir", "block", "=path!:string"},
+	{"echo", "=message!:string"},
+	{"emailext", "attachLog:boolean", "body!:string", "mimeType:string", "recipientProviders:list", "subject!:string",
+		"to:string"},
+	{"error", "=message!:string"},
+	{"fileExists", "=file!:string"},
+	{"fingerprint", "=targets!:string"},
+	{"git", "branch:string", "changelog:boolean", "credentialsId:string", "poll:boolean", "=url!:string"},
+	{"input", "=message!:string", "id:string", "ok:string", "parameters:list", "submitter:string",
+		"submitterParameter:string"},
+	{"isUnix"},
+	{"junit", "allowEmptyResults:boolean", "keepLongStdio:boolean", "skipPublishingChecks:boolean",
+		"=testResults!:string"},
+	{"library", "=identifier!:string", "changelog:boolean"},
+	{"load", "=path!:string"},
+	{"lock", "block", "inversePrecedence:boolean", "label:string", "quantity:int", "=resource:string",
+		"skipIfLocked:boolean", "variable:string"},
+	{"mail", "bcc:string", "body!:string", "cc:string", "charset:string", "from:string", "mimeType:string",
+		"replyTo:string", "subject!:string", "to:string"},
+	{"milestone", "label:string", "=ordinal:int"},
+	{"node", "block", "=label:string"},
+	{"powershell", "=script!:string", "encoding:string", "label:string", "returnStatus:boolean", "returnStdout:boolean"},
+	{"pwsh", "=script!:string", "encoding:string", "label:string", "returnStatus:boolean", "returnStdout:boolean"},
+	{"readFile", "encoding:string", "=file!:string"},
+	{"retry", "block", "=count!:int"},
+	{"script", "block"},
+	{"sh", "=script!:string", "encoding:string", "label:string", "returnStatus:boolean", "returnStdout:boolean"},
+	{"slackSend", "channel:string", "color:string", "=message:string", "tokenCredentialId:string"},
+	{"sleep", "=time!:int", "unit:" + timeUnits},
+	{"stash", "allowEmpty:boolean", "excludes:string", "includes:string", "=name!:string",
+		"useDefaultExcludes:boolean"},
+	{"timeout", "block", "activity:boolean", "=time!:int", "unit:" + timeUnits},
+	{"tool", "=name!:string", "type:string"},
+	{"unstable", "=message!:string"},
+	{"unstash", "=name!:string"},
+	{"waitUntil", "block", "initialRecurrencePeriod:int", "quiet:boolean"},
+	{"warnError", "block", "catchInterruptions:boolean", "=message!:string"},
+	{"withCredentials", "block", "=bindings!:list"},
+	{"withEnv", "block", "=overrides!:list"},
+	{"writeFile", "encoding:string", "file!:string", "text!:string"},
+}
+
+var defaultOptions = [][]string{
+	{"buildDiscarder", "=strategy!:object"},
+	{"checkoutToSubdirectory", "=path!:string"},
+	{"disableConcurrentBuilds", "abortPrevious:boolean"},
+	{"disableResume"},
+	{"durabilityHint", "=hint!:enum|PERFORMANCE_OPTIMIZED|SURVIVABLE_NONATOMIC|MAX_SURVIVABILITY"},
+	{"lock", "stage", "plugin=lockable-resources", "inversePrecedence:boolean", "label:string", "quantity:int",
+		"=resource:string", "skipIfLocked:boolean", "variable:string"},
+	{"newContainerPerStage"},
+	{"overrideIndexTriggers", "=enabled!:boolean"},
+	{"parallelsAlwaysFailFast"},
+	{"preserveStashes", "buildCount:int"},
+	{"quietPeriod", "=quietPeriod!:int"},
+	{"retry", "stage", "=count!:int"},
+	{"skipDefaultCheckout", "stage", "=skip:boolean"},
+	{"skipStagesAfterUnstable"},
+	{"throttle", "stage", "plugin=throttle-concurrents", "=categories!:list"},
+	{"timeout", "stage", "activity:boolean", "=time!:int", "unit:" + timeUnits},
+	{"timestamps", "stage", "plugin=timestamper"},
+}
+
+var defaultTriggers = [][]string{
+	{"cron", "=spec!:string"},
+	{"githubPush", "plugin=github"},
+	{"pollSCM", "=scmpoll_spec!:string", "ignorePostCommitHooks:boolean"},
+	{"upstream", "threshold:" + results, "=upstreamProjects!:string"},
+}
+
+var defaultParameters = [][]string{
+	{"booleanParam", "defaultValue:boolean", "description:string", "name!:string"},
+	{"choice", "choices!:list", "description:string", "name!:string"},
+	{"credentials", "credentialType:string", "defaultValue:string", "description:string", "name!:string",
+		"required:boolean", "plugin=credentials"},
+	{"file", "description:string", "name!:string"},
+	{"password", "defaultValue:string", "description:string", "name!:string"},
+	{"string", "defaultValue:string", "description:string", "name!:string", "trim:boolean"},
+	{"text", "defaultValue:string", "description:string", "name!:string"},
+}
+
+var comparators = "enum|EQUALS|GLOB|REGEXP"
+
+var defaultWhenConditions = [][]string{
+	{"allOf", "block"},
+	{"anyOf", "block"},
+	{"branch", "comparator:" + comparators, "=pattern!:string"},
+	{"buildingTag"},
+	{"changeRequest", "author:string", "authorDisplayName:string", "authorEmail:string", "branch:string",
+		"comparator:" + comparators, "fork:string", "id:string", "target:string", "title:string", "url:string"},
+	{"changelog", "=pattern!:string"},
+	{"changeset", "caseSensitive:boolean", "comparator:" + comparators, "=pattern!:string"},
+	{"environment", "name!:string", "value!:string"},
+	{"equals", "actual!:object", "expected!:object"},
+	{"expression", "block"},
+	{"not", "block"},
+	{"tag", "comparator:" + comparators, "=pattern:string"},
+	{"triggeredBy", "=cause!:string"},
+}
+
+var defaultAgents = [][]string{
+	{"any"},
+	{"docker", "alwaysPull:boolean", "args:string", "customWorkspace:string", "=image!:string", "label:string",
+		"registryCredentialsId:string", "registryUrl:string", "reuseNode:boolean", "plugin=docker-workflow"},
+	{"dockerfile", "additionalBuildArgs:string", "args:string", "customWorkspace:string", "dir:string",
+		"filename:string", "label:string", "reuseNode:boolean", "plugin=docker-workflow"},
+	{"kubernetes", "cloud:string", "defaultContainer:string", "inheritFrom:string", "label:string", "yaml:string",
+		"yamlFile:string", "plugin=kubernetes"},
+	{"label", "=label!:string"},
+	{"node", "customWorkspace:string", "label!:string"},
+	{"none"},
+}
@@ -0,0 +1,62 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	sh := c.Step("sh")
+	require.NotNil(t, sh)
+	assert.Equal(t, "workflow-durable-task-step", sh.Plugin)
+	assert.Equal(t, &Parameter{Name: "script", Type: "string", Required: true, Default: true},
+		sh.Parameter("script"))
+	assert.True(t, c.Step("dir").Block)
+	assert.Equal(t, []string{"SUCCESS", "UNSTABLE", "FAILURE", "NOT_BUILT", "ABORTED"},
+		c.Step("catchError").Parameter("buildResult").Values)
+	assert.True(t, c.Option("timeout").Stage)
+	assert.False(t, c.Option("disableConcurrentBuilds").Stage)
+	assert.Contains(t, c.Sections[ContextStage], "matrix")
+
+	c.AddSteps(&Symbol{Name: "deployApp", Plugin: "shared-library"}, &Symbol{Name: "sh", Plugin: "custom"})
+	assert.Equal(t, "custom", c.Step("sh").Plugin)
+	assert.NotNil(t, c.Step("deployApp"))
+	assert.Equal(t, "workflow-durable-task-step", Default().Step("sh").Plugin)
+}
+
+func TestCompletions(t *testing.T) {
+	comp := Default().Completions()
+	assert.Equal(t, CompletionsVersion, comp.Version)
+	items := map[string]*Completion{}
+	for _, i := range comp.Items {
+		items[i.Kind+":"+i.Label] = i
+	}
+
+	assert.Equal(t, []string{ContextMatrix, ContextPipeline, ContextStage}, items["section:agent"].Contexts)
+	assert.Equal(t, "Runs a shell script.", items["step:sh"].Documentation)
+	for _, i := range comp.Items {
+		assert.NotEmpty(t, i.Documentation, "%s:%s", i.Kind, i.Label)
+	}
+	assert.Equal(t, "failFast true", items["section:failFast"].Snippet)
+	assert.Equal(t, "sh '${1}'", items["step:sh"].Snippet)
+	assert.Equal(t, "sh(script: string, encoding: string?, label: string?, returnStatus: boolean?, "+
+		"returnStdout: boolean?)", items["step:sh"].Detail)
+	assert.Equal(t, "dir('${1}') {\n\t$0\n}", items["step:dir"].Snippet)
+	assert.Equal(t, "writeFile(file: '${1}', text: '${2}')", items["step:writeFile"].Snippet)
+	assert.Equal(t, "script {\n\t$0\n}", items["step:script"].Snippet)
+	assert.Equal(t, "deleteDir()", items["step:deleteDir"].Snippet)
+	assert.Equal(t, []string{ContextOptions, ContextStageOptions}, items["option:retry"].Contexts)
+	assert.Equal(t, []string{ContextWhen}, items["whenCondition:branch"].Contexts)
+	assert.Equal(t, []string{ContextPost}, items["postCondition:failure"].Contexts)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, comp.WriteJSON(buf))
+	var decoded Completions
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded.Items, len(comp.Items))
+}
@@ -0,0 +1,200 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/docs"
+)
+
+// CompletionsVersion is the version of the Completions format, incremented when it changes incompatibly
+const CompletionsVersion = 1
+
+// Completion contexts, besides those of sections such as ContextPipeline and ContextStage, naming where symbols are
+// valid
+const (
+	ContextSteps        = "steps"
+	ContextOptions      = "options"
+	ContextStageOptions = "stageOptions"
+	ContextTriggers     = "triggers"
+	ContextParameters   = "parameters"
+	ContextPost         = "post"
+	ContextAgent        = "agent"
+)
+
+// Kinds of completion
+const (
+	KindSection       = "section"
+	KindStep          = "step"
+	KindOption        = "option"
+	KindTrigger       = "trigger"
+	KindParameter     = "parameter"
+	KindWhenCondition = "whenCondition"
+	KindPostCondition = "postCondition"
+	KindAgent         = "agent"
+)
+
+// Completion is a single completion item for an editor
+type Completion struct {
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+	// Contexts are where the item is valid, such as ContextStage for sections of stages or ContextSteps for steps.
+	Contexts []string `json:"contexts"`
+	// Detail is the item's signature, such as "sh(script: string, returnStdout: boolean, ...)".
+	Detail string `json:"detail,omitempty"`
+	// Snippet is the text to insert, with TextMate-style tab stops such as ${1}, which most editors support.
+	Snippet string `json:"snippet"`
+	// Documentation is the item's hover text, from the docs package.
+	Documentation string       `json:"documentation,omitempty"`
+	Plugin        string       `json:"plugin,omitempty"`
+	Parameters    []*Parameter `json:"parameters,omitempty"`
+}
+
+// Completions is completion data for editors which don't use a language server, as a single JSON document
+type Completions struct {
+	Version int           `json:"version"`
+	Items   []*Completion `json:"items"`
+}
+
+// booleanSections are sections whose value is a boolean rather than a block
+var booleanSections = map[string]bool{"failFast": true, "beforeAgent": true, "beforeInput": true, "beforeOptions": true}
+
+// valueSections are sections whose value is a string rather than a block
+var valueSections = map[string]bool{"name": true, "values": true, "id": true, "message": true, "ok": true,
+	"submitter": true, "submitterParameter": true}
+
+// Completions returns the catalog's completion data: its sections, steps, and symbols, each with the contexts it's
+// valid in, its signature, a snippet, and its documentation.
+func (strct *Catalog) Completions() *Completions {
+	out := &Completions{Version: CompletionsVersion}
+
+	sections := map[string][]string{}
+	var names []string
+	for context, list := range strct.Sections {
+		for _, name := range list {
+			if sections[name] == nil {
+				names = append(names, name)
+			}
+			sections[name] = append(sections[name], context)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sort.Strings(sections[name])
+		snippet := name + " {\n\t$0\n}"
+		switch {
+		case booleanSections[name]:
+			snippet = name + " true"
+		case valueSections[name]:
+			snippet = name + " '${1}'"
+		}
+		out.Items = append(out.Items, &Completion{Label: name, Kind: KindSection, Contexts: sections[name],
+			Snippet: snippet})
+	}
+
+	add := func(kind string, symbols []*Symbol, contexts func(s *Symbol) []string) {
+		for _, s := range symbols {
+			out.Items = append(out.Items, &Completion{Label: s.Name, Kind: kind, Contexts: contexts(s),
+				Detail: signature(s), Snippet: snippet(s), Plugin: s.Plugin, Parameters: s.Parameters})
+		}
+	}
+	only := func(contexts ...string) func(*Symbol) []string {
+		return func(*Symbol) []string {
+			return contexts
+		}
+	}
+	add(KindStep, strct.Steps, only(ContextSteps))
+	add(KindOption, strct.Options, func(s *Symbol) []string {
+		if s.Stage {
+			return []string{ContextOptions, ContextStageOptions}
+		}
+		return []string{ContextOptions}
+	})
+	add(KindTrigger, strct.Triggers, only(ContextTriggers))
+	add(KindParameter, strct.Parameters, only(ContextParameters))
+	add(KindWhenCondition, strct.WhenConditions, only(ContextWhen))
+	add(KindAgent, strct.Agents, only(ContextAgent))
+	for _, c := range strct.PostConditions {
+		out.Items = append(out.Items, &Completion{Label: c, Kind: KindPostCondition, Contexts: []string{ContextPost},
+			Snippet: c + " {\n\t$0\n}"})
+	}
+	for _, item := range out.Items {
+		if d := docs.For(item.Kind + ":" + item.Label); d != nil {
+			item.Documentation = d.Summary
+		}
+	}
+	return out
+}
+
+// WriteJSON writes the completion data as indented JSON.
+func (strct *Completions) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(strct)
+}
+
+// signature describes how a symbol is called, listing its parameters with their types
+func signature(s *Symbol) string {
+	var params []string
+	for _, p := range s.Parameters {
+		t := p.Type
+		if p.Type == "enum" {
+			t = strings.Join(p.Values, "|")
+		}
+		if !p.Required {
+			t += "?"
+		}
+		params = append(params, fmt.Sprintf("%s: %s", p.Name, t))
+	}
+	sig := s.Name + "(" + strings.Join(params, ", ") + ")"
+	if s.Block {
+		sig += " { ... }"
+	}
+	return sig
+}
+
+// snippet is the text inserted for a symbol: a call with its required parameters, using the unnamed form if only
+// the default parameter is required
+func snippet(s *Symbol) string {
+	var required []*Parameter
+	for _, p := range s.Parameters {
+		if p.Required {
+			required = append(required, p)
+		}
+	}
+	var call string
+	switch {
+	case len(required) == 1 && required[0].Default:
+		call = s.Name + " " + placeholder(required[0], 1)
+		if s.Block {
+			call = s.Name + "(" + placeholder(required[0], 1) + ")"
+		}
+	case len(required) > 0:
+		var args []string
+		for i, p := range required {
+			args = append(args, p.Name+": "+placeholder(p, i+1))
+		}
+		call = s.Name + "(" + strings.Join(args, ", ") + ")"
+	case s.Block:
+		call = s.Name
+	default:
+		call = s.Name + "()"
+	}
+	if s.Block {
+		call += " {\n\t$0\n}"
+	}
+	return call
+}
+
+func placeholder(p *Parameter, n int) string {
+	switch p.Type {
+	case "string", "enum":
+		return fmt.Sprintf("'${%d}'", n)
+	case "list":
+		return fmt.Sprintf("[${%d}]", n)
+	}
+	return fmt.Sprintf("${%d}", n)
+}
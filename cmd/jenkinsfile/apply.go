@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/detect"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/transform"
+	"github.com/abayer/go-jenkinsfile/writer"
+)
+
+// diffContext is the number of unchanged lines around each hunk of a diff
+const diffContext = 3
+
+// PatchReport is the report of applying a patch to a tree of Jenkinsfiles
+type PatchReport struct {
+	Files []*PatchedFile `json:"files"`
+	// Written is true if the changed files were written back.
+	Written bool `json:"written,omitempty"`
+}
+
+// PatchedFile is the outcome of patching one file. Changes is nil for files which were skipped or failed.
+type PatchedFile struct {
+	// Source is the file's path relative to the patched directory, with forward slashes.
+	Source  string             `json:"source"`
+	Changes *transform.Changes `json:"changes,omitempty"`
+	// Diff is the unified diff of the file, for dry runs.
+	Diff string `json:"diff,omitempty"`
+	// Skipped says why a file which isn't a Declarative pipeline wasn't patched.
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Failed returns the number of files which couldn't be patched
+func (strct *PatchReport) Failed() int {
+	n := 0
+	for _, f := range strct.Files {
+		if f.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Markdown renders the report, with the changes to each file
+func (strct *PatchReport) Markdown() string {
+	buf := &bytes.Buffer{}
+	changed, unchanged, skipped := 0, 0, 0
+	for _, f := range strct.Files {
+		switch {
+		case f.Skipped != "":
+			skipped++
+		case f.Changes == nil:
+		case f.Changes.Empty():
+			unchanged++
+		default:
+			changed++
+		}
+	}
+	verb := "changed"
+	if strct.Written {
+		verb = "written"
+	}
+	fmt.Fprintf(buf, "# Patch\n\n%d %s, %d unchanged, %d failed, %d skipped.\n", changed, verb, unchanged,
+		strct.Failed(), skipped)
+	for _, f := range strct.Files {
+		fmt.Fprintf(buf, "\n## %s\n\n", f.Source)
+		switch {
+		case f.Error != "":
+			fmt.Fprintf(buf, "Failed: %s\n", f.Error)
+		case f.Skipped != "":
+			fmt.Fprintf(buf, "Skipped: %s\n", f.Skipped)
+		default:
+			buf.WriteString(f.Changes.Markdown())
+		}
+		if f.Diff != "" {
+			fmt.Fprintf(buf, "\n```diff\n%s```\n", f.Diff)
+		}
+	}
+	return buf.String()
+}
+
+// applyCommand applies a patch file to every Declarative Jenkinsfile in a directory tree matching a glob, and prints
+// a summary of the changes to each file. Changed files are only written back with -w; with --dry-run, the report
+// includes their diffs instead. It fails if any file couldn't be patched, after patching the others.
+func applyCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("f", "", "the YAML or JSON patch file to apply")
+	dir := fs.String("dir", ".", "the directory to patch the Jenkinsfiles of")
+	glob := fs.String("glob", "**/Jenkinsfile", "an Ant-style pattern, relative to --dir, of the files to patch")
+	write := fs.Bool("w", false, "write the changed files back")
+	dryRun := fs.Bool("dry-run", false, "show the diff of each changed file rather than writing it")
+	format := fs.String("format", "markdown", "the format of the report: markdown or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *file == "":
+		return errors.New("-f is required")
+	case *write && *dryRun:
+		return errors.New("-w and --dry-run can't be used together")
+	case *format != "markdown" && *format != "json":
+		return fmt.Errorf("unknown report format %q, must be markdown or json", *format)
+	}
+	b, err := ioutil.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	patch, err := transform.ParsePatch(b)
+	if err != nil {
+		return fmt.Errorf("%s: %s", *file, err)
+	}
+
+	sources, err := matchFiles(*dir, *glob)
+	if err != nil {
+		return err
+	}
+	r := &PatchReport{Files: []*PatchedFile{}, Written: *write}
+	for _, source := range sources {
+		f := &PatchedFile{Source: source}
+		r.Files = append(r.Files, f)
+		path := filepath.Join(*dir, filepath.FromSlash(source))
+		before, after, err := patchFile(path, patch, f)
+		switch {
+		case err != nil:
+			f.Error = err.Error()
+			continue
+		case f.Changes == nil || f.Changes.Empty():
+			continue
+		case *dryRun:
+			f.Diff = unifiedDiff(source, before, after)
+		case *write:
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, []byte(after), info.Mode()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *format == "json" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		fmt.Fprint(stdout, r.Markdown())
+	}
+	if n := r.Failed(); n > 0 {
+		return fmt.Errorf("%d of %d files could not be patched", n, len(r.Files))
+	}
+	return nil
+}
+
+// patchFile applies the patch to a Declarative Jenkinsfile, recording the changes in f, and returns its text before
+// and after. Unchanged parts of the file keep their original text. Files which aren't Declarative pipelines are
+// recorded as skipped.
+func patchFile(path string, patch *transform.Patch, f *PatchedFile) (string, string, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	if c := detect.Classify(src); c.Kind != detect.Declarative {
+		f.Skipped = strings.Join(c.Reasons, "; ")
+		return "", "", nil
+	}
+	before, err := parser.Parse(bytes.NewReader(src))
+	if err != nil {
+		return "", "", err
+	}
+	// The patch is applied to a pipeline parsed with its source, rather than a copy, so Rewrite can reuse its text.
+	root, source, err := parser.ParseSource(bytes.NewReader(src))
+	if err != nil {
+		return "", "", err
+	}
+	if err := transform.ApplyPatch(root, patch); err != nil {
+		return "", "", err
+	}
+	if f.Changes, err = transform.Summarize(before, root); err != nil {
+		return "", "", err
+	}
+	if f.Changes.Empty() {
+		return source.Text, source.Text, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := writer.Rewrite(root, source, buf); err != nil {
+		return "", "", err
+	}
+	return source.Text, buf.String(), nil
+}
+
+// diffLine is a line of a diff: unchanged (' '), removed ('-'), or added ('+'), with its index in each file
+type diffLine struct {
+	op   byte
+	text string
+	a, b int
+}
+
+// unifiedDiff returns the unified diff between two versions of a file, or an empty string if they're the same
+func unifiedDiff(name string, before, after string) string {
+	x, y := splitLines(before), splitLines(after)
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []diffLine
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', x[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(buf, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		// Changes separated by fewer unchanged lines than the context on both sides go in the same hunk.
+		last := k
+		for n := k; n < len(lines) && n-last <= 2*diffContext; n++ {
+			if lines[n].op != ' ' {
+				last = n
+			}
+		}
+		start, stop := k-diffContext, last+diffContext+1
+		if start < 0 {
+			start = 0
+		}
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		hunk := lines[start:stop]
+		removed, added := 0, 0
+		for _, l := range hunk {
+			if l.op != '+' {
+				removed++
+			}
+			if l.op != '-' {
+				added++
+			}
+		}
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(hunk[0].a, removed), hunkRange(hunk[0].b, added))
+		for _, l := range hunk {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			buf.WriteByte('\n')
+		}
+		k = stop
+	}
+	return buf.String()
+}
+
+// hunkRange formats the lines of one side of a hunk, given the index of its first line
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const applyJenkinsfile = `// Deploys the API
+pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make'
+            }
+        }
+        stage('Deploy') {
+            steps {
+                sh 'terraform apply -auto-approve'
+            }
+        }
+    }
+}
+`
+
+const applyPatch = `wrap:
+- script: terraform apply
+  retry: 2
+`
+
+func TestApplyCommandDryRun(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"patch.yaml":      applyPatch,
+		"api/Jenkinsfile": applyJenkinsfile,
+		"web/Jenkinsfile": "node { sh 'npm ci' }\n",
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"apply", "-f", filepath.Join(dir, "patch.yaml"), "--dir", dir, "--dry-run"}, stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "# Patch\n\n"+
+		"1 changed, 0 unchanged, 0 failed, 1 skipped.\n\n"+
+		"## api/Jenkinsfile\n\n"+
+		"- Stage `Deploy` changed: steps\n\n"+
+		"```diff\n"+
+		"--- a/api/Jenkinsfile\n"+
+		"+++ b/api/Jenkinsfile\n"+
+		"@@ -9,7 +9,9 @@\n"+
+		"         }\n"+
+		"         stage('Deploy') {\n"+
+		"             steps {\n"+
+		"-                sh 'terraform apply -auto-approve'\n"+
+		"+                retry(2) {\n"+
+		"+                    sh 'terraform apply -auto-approve'\n"+
+		"+                }\n"+
+		"             }\n"+
+		"         }\n"+
+		"     }\n"+
+		"```\n\n"+
+		"## web/Jenkinsfile\n\n"+
+		"Skipped: contains a node block\n", stdout.String())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "api", "Jenkinsfile"))
+	require.NoError(t, err)
+	assert.Equal(t, applyJenkinsfile, string(b), "dry runs don't write files")
+}
+
+func TestApplyCommandWrite(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"patch.yaml":         applyPatch,
+		"Jenkinsfile":        applyJenkinsfile,
+		"broken/Jenkinsfile": "pipeline {\n  agent any\n  stages {\n    stage('Build') { deploy { } }\n  }\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	args := []string{"apply", "-f", filepath.Join(dir, "patch.yaml"), "--dir", dir, "-w", "--format", "json"}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 1, run(args, stdout, stderr))
+	assert.Equal(t, "jenkinsfile apply: 1 of 2 files could not be patched\n", stderr.String())
+	r := &PatchReport{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), r))
+	assert.True(t, r.Written)
+	require.Len(t, r.Files, 2)
+	assert.Equal(t, "Jenkinsfile", r.Files[0].Source)
+	require.NotNil(t, r.Files[0].Changes)
+	assert.False(t, r.Files[0].Changes.Empty())
+	assert.Empty(t, r.Files[0].Diff)
+	assert.NotEmpty(t, r.Files[1].Error)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Jenkinsfile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "// Deploys the API\n")
+	assert.Contains(t, string(b), "retry(2) {\n")
+
+	// Applying the patch again changes nothing.
+	stdout.Reset()
+	run(args, stdout, &bytes.Buffer{})
+	r = &PatchReport{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), r))
+	require.Len(t, r.Files, 2)
+	require.NotNil(t, r.Files[0].Changes)
+	assert.True(t, r.Files[0].Changes.Empty())
+}
+
+func TestApplyCommandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"apply"}, err: "jenkinsfile apply: -f is required\n"},
+		{args: []string{"apply", "-f", "patch.yaml", "-w", "--dry-run"},
+			err: "jenkinsfile apply: -w and --dry-run can't be used together\n"},
+		{args: []string{"apply", "-f", "patch.yaml", "--format", "xml"},
+			err: "jenkinsfile apply: unknown report format \"xml\", must be markdown or json\n"},
+	} {
+		stderr := &bytes.Buffer{}
+		assert.NotEqual(t, 0, run(tc.args, &bytes.Buffer{}, stderr))
+		assert.Equal(t, tc.err, stderr.String())
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Equal(t, "", unifiedDiff("f", "a\nb\n", "a\nb\n"))
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n", unifiedDiff("f", "a\nb\n", "a\nc\n"))
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -0,0 +1 @@\n+a\n", unifiedDiff("f", "", "a\n"))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/abayer/go-jenkinsfile/aggregate"
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/detect"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/tabular"
+	"github.com/abayer/go-jenkinsfile/validate"
+	"github.com/abayer/go-jenkinsfile/webhook"
+)
+
+// OrgAudit is the report of auditing the Jenkinsfiles of an organization's repositories
+type OrgAudit struct {
+	Org string `json:"org"`
+	// Path is the path of the Jenkinsfile in each repository.
+	Path string `json:"path"`
+	// Repos are the audits of each repository, sorted by name.
+	Repos []*RepoAudit `json:"repos"`
+	// Rollup combines the inventories of the audited pipelines.
+	Rollup *aggregate.Rollup `json:"rollup"`
+}
+
+// RepoAudit is the audit of one repository's Jenkinsfile, on its default branch. At most one of Skipped and Error is
+// set, and the rest only when neither is.
+type RepoAudit struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// Skipped says why a repository wasn't audited, such as because it has no Jenkinsfile.
+	Skipped string `json:"skipped,omitempty"`
+	// Error is why the Jenkinsfile couldn't be fetched or parsed.
+	Error string `json:"error,omitempty"`
+	// Errors are the pipeline's violations of Declarative semantics, from validate.Pipeline.
+	Errors    []validate.ValidationError `json:"errors,omitempty"`
+	Findings  []*analysis.Finding        `json:"findings,omitempty"`
+	Inventory *aggregate.Inventory       `json:"inventory,omitempty"`
+}
+
+// Markdown renders the report for review
+func (strct *OrgAudit) Markdown() string {
+	buf := &bytes.Buffer{}
+	audited, invalid, failed, skipped := 0, 0, 0, 0
+	for _, r := range strct.Repos {
+		switch {
+		case r.Skipped != "":
+			skipped++
+		case r.Error != "":
+			failed++
+		default:
+			audited++
+			if len(r.Errors) > 0 {
+				invalid++
+			}
+		}
+	}
+	fmt.Fprintf(buf, "# Audit of %s\n\n", strct.Org)
+	fmt.Fprintf(buf, "%d audited (%d invalid), %d failed, %d skipped.\n", audited, invalid, failed, skipped)
+	if plugins := aggregate.Top(strct.Rollup.Plugins, 10); len(plugins) > 0 {
+		buf.WriteString("\n## Most used plugins\n\n")
+		for _, c := range plugins {
+			fmt.Fprintf(buf, "- %s: %d steps in %d pipelines\n", c.Name, c.Total, c.Pipelines)
+		}
+	}
+	for _, r := range strct.Repos {
+		if r.Skipped != "" {
+			continue
+		}
+		fmt.Fprintf(buf, "\n## %s\n\n", r.Repo)
+		if r.Error != "" {
+			fmt.Fprintf(buf, "Failed: %s\n", r.Error)
+			continue
+		}
+		if len(r.Errors) == 0 && len(r.Findings) == 0 {
+			buf.WriteString("No problems found.\n")
+		}
+		for _, e := range r.Errors {
+			fmt.Fprintf(buf, "- error: `%s`: %s\n", e.Path, e.Message)
+		}
+		for _, f := range r.Findings {
+			fmt.Fprintf(buf, "- %s: %s", f.Severity(), f.Message)
+			if f.Stage != "" {
+				fmt.Fprintf(buf, " (stage `%s`)", f.Stage)
+			}
+			fmt.Fprintf(buf, " [%s]\n", f.Rule)
+		}
+	}
+	if skipped > 0 {
+		buf.WriteString("\n## Skipped\n\n")
+		for _, r := range strct.Repos {
+			if r.Skipped != "" {
+				fmt.Fprintf(buf, "- %s: %s\n", r.Repo, r.Skipped)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// orgSource lists an organization's repositories and fetches their files, as webhook.GitHub does
+type orgSource interface {
+	Repositories(org string) ([]*webhook.Repository, error)
+	Fetch(repo, commit, path string) ([]byte, error)
+}
+
+// auditCommand audits the Jenkinsfile on the default branch of every repository in a GitHub organization, parsing,
+// validating, linting, and taking the inventory of each concurrently. It writes the report as audit.json and audit.md,
+// with findings.csv, inventory.csv, and rollup.csv for spreadsheets, to the output directory, and prints a summary.
+func auditCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	org := fs.String("github-org", "", "the GitHub organization whose repositories to audit")
+	token := fs.String("token", os.Getenv("GITHUB_TOKEN"), "a token allowed to list the organization's "+
+		"repositories and read their contents; it defaults to $GITHUB_TOKEN")
+	apiURL := fs.String("github-url", webhook.DefaultGitHubURL, "the root URL of the GitHub API")
+	path := fs.String("path", "Jenkinsfile", "the path of the Jenkinsfile in each repository")
+	outDir := fs.String("out-dir", "", "the directory to write the report to")
+	concurrency := fs.Int("concurrency", 8, "the number of repositories to audit at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *org == "":
+		return errors.New("--github-org is required")
+	case *outDir == "":
+		return errors.New("--out-dir is required")
+	case *concurrency < 1:
+		return errors.New("--concurrency must be at least 1")
+	}
+
+	a, err := auditOrg(&webhook.GitHub{URL: *apiURL, Token: *token}, *org, *path, *concurrency)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(a, *outDir); err != nil {
+		return err
+	}
+	summary := a.Markdown()
+	if i := strings.Index(summary, "\n\n## "); i >= 0 {
+		summary = summary[:i+1]
+	}
+	fmt.Fprint(stdout, summary)
+	fmt.Fprintf(stdout, "\nThe report is in %s.\n", *outDir)
+	return nil
+}
+
+// auditOrg lists the organization's repositories and audits each of their Jenkinsfiles, with at most concurrency
+// repositories being fetched and audited at once. Archived repositories are skipped.
+func auditOrg(scm orgSource, org string, path string, concurrency int) (*OrgAudit, error) {
+	repos, err := scm.Repositories(org)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	a := &OrgAudit{Org: org, Path: path, Repos: make([]*RepoAudit, len(repos))}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				a.Repos[i] = auditRepo(scm, repos[i], path)
+			}
+		}()
+	}
+	for i := range repos {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var inventories []*aggregate.Inventory
+	for _, r := range a.Repos {
+		if r.Inventory != nil {
+			inventories = append(inventories, r.Inventory)
+		}
+	}
+	a.Rollup = aggregate.Aggregate(inventories)
+	return a, nil
+}
+
+// auditRepo fetches a repository's Jenkinsfile from its default branch and audits it
+func auditRepo(scm orgSource, repo *webhook.Repository, path string) *RepoAudit {
+	r := &RepoAudit{Repo: repo.Name, Branch: repo.DefaultBranch}
+	if repo.Archived {
+		r.Skipped = "archived"
+		return r
+	}
+	src, err := scm.Fetch(repo.Name, repo.DefaultBranch, path)
+	switch {
+	case webhook.IsNotFound(err):
+		r.Skipped = "no " + path
+		return r
+	case err != nil:
+		r.Error = err.Error()
+		return r
+	}
+	if c := detect.Classify(src); c.Kind != detect.Declarative {
+		r.Skipped = strings.Join(c.Reasons, "; ")
+		return r
+	}
+	root, err := parser.Parse(bytes.NewReader(src))
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if r.Errors = validate.Pipeline(root); len(r.Errors) > 0 {
+		// The analyzers assume a valid pipeline.
+		return r
+	}
+	if r.Findings, err = lintPipeline(root); err == nil {
+		r.Inventory, err = aggregate.Collect(repo.Name, root)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// lintPipeline runs the analyzers which need nothing but the pipeline
+func lintPipeline(root *model.Root) ([]*analysis.Finding, error) {
+	var out []*analysis.Finding
+	for _, analyze := range []func(*model.Root) ([]*analysis.Finding, error){
+		analysis.DuplicateOptions,
+		analysis.Parameters,
+	} {
+		findings, err := analyze(root)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, findings...)
+	}
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, analysis.Milestones(p)...)
+	return append(out, analysis.SandboxFindings(analysis.SandboxSignatures(p))...), nil
+}
+
+// writeAudit writes the report to dir in each of its formats
+func writeAudit(a *OrgAudit, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "audit.json"), append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "audit.md"), []byte(a.Markdown()), 0644); err != nil {
+		return err
+	}
+
+	findings := &tabular.Table{Header: append([]string{"repo"}, tabular.Findings(nil).Header...)}
+	var inventories []*aggregate.Inventory
+	for _, r := range a.Repos {
+		for _, row := range tabular.Findings(r.Findings).Rows {
+			findings.Rows = append(findings.Rows, append([]string{r.Repo}, row...))
+		}
+		for _, e := range r.Errors {
+			findings.Rows = append(findings.Rows, []string{r.Repo, "validation", string(analysis.Error),
+				string(analysis.SourceValidation), "", e.Error()})
+		}
+		if r.Inventory != nil {
+			inventories = append(inventories, r.Inventory)
+		}
+	}
+	for name, write := range map[string]func(w io.Writer) error{
+		"findings.csv":  func(w io.Writer) error { return findings.Write(w, tabular.CSV) },
+		"inventory.csv": func(w io.Writer) error { return tabular.Inventories(inventories).Write(w, tabular.CSV) },
+		"rollup.csv":    a.Rollup.WriteCSV,
+	} {
+		buf := &bytes.Buffer{}
+		if err := write(buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditCommand(t *testing.T) {
+	files := map[string]string{
+		"/repos/acme/api/contents/Jenkinsfile": `pipeline {
+  agent { label 'linux' }
+  stages {
+    stage('Build') { steps { sh 'make' } }
+  }
+}`,
+		"/repos/acme/broken/contents/Jenkinsfile": "pipeline {\n  agent any\n  stages {\n" +
+			"    stage('Build') { deploy { } }\n  }\n}\n",
+		"/repos/acme/legacy/contents/Jenkinsfile": "node('linux') {\n  stage('Build') { sh 'make' }\n}\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if r.URL.Path == "/orgs/acme/repos" {
+			_, _ = w.Write([]byte(`[
+				{"full_name": "acme/legacy", "default_branch": "master"},
+				{"full_name": "acme/api", "default_branch": "main"},
+				{"full_name": "acme/docs", "default_branch": "main"},
+				{"full_name": "acme/broken", "default_branch": "main"},
+				{"full_name": "acme/old", "default_branch": "master", "archived": true}]`))
+			return
+		}
+		if content, ok := files[r.URL.Path]; ok {
+			_, _ = w.Write([]byte(content))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"audit", "--github-org", "acme", "--github-url", server.URL, "--token", "secret",
+		"--out-dir", dir, "--concurrency", "2"}, stdout, stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "# Audit of acme\n\n1 audited (0 invalid), 1 failed, 3 skipped.\n\nThe report is in "+dir+".\n",
+		stdout.String())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "audit.json"))
+	require.NoError(t, err)
+	a := &OrgAudit{}
+	require.NoError(t, json.Unmarshal(b, a))
+	require.Len(t, a.Repos, 5)
+	var names []string
+	for _, r := range a.Repos {
+		names = append(names, r.Repo)
+	}
+	assert.Equal(t, []string{"acme/api", "acme/broken", "acme/docs", "acme/legacy", "acme/old"}, names)
+	assert.Equal(t, "main", a.Repos[0].Branch)
+	require.NotNil(t, a.Repos[0].Inventory)
+	assert.Equal(t, 1, a.Repos[0].Inventory.Steps["sh"])
+	assert.NotEmpty(t, a.Repos[1].Error)
+	assert.Equal(t, "no Jenkinsfile", a.Repos[2].Skipped)
+	assert.NotEmpty(t, a.Repos[3].Skipped)
+	assert.Equal(t, "archived", a.Repos[4].Skipped)
+	assert.Equal(t, 1, a.Rollup.Pipelines)
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "audit.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "## acme/api\n\nNo problems found.\n")
+	assert.Contains(t, string(b), "- acme/old: archived\n")
+	b, err = ioutil.ReadFile(filepath.Join(dir, "inventory.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "acme/api,step,sh,1\n")
+	for _, name := range []string{"findings.csv", "rollup.csv"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+}
+
+func TestAuditCommandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"audit", "--out-dir", "x"}, err: "jenkinsfile audit: --github-org is required\n"},
+		{args: []string{"audit", "--github-org", "acme"}, err: "jenkinsfile audit: --out-dir is required\n"},
+		{args: []string{"audit", "--github-org", "acme", "--out-dir", "x", "--concurrency", "0"},
+			err: "jenkinsfile audit: --concurrency must be at least 1\n"},
+	} {
+		stderr := &bytes.Buffer{}
+		assert.NotEqual(t, 0, run(tc.args, &bytes.Buffer{}, stderr))
+		assert.Equal(t, tc.err, stderr.String())
+	}
+}
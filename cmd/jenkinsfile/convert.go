@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/convert"
+	"github.com/abayer/go-jenkinsfile/detect"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/scripted"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+// Migration is the report of a batch conversion
+type Migration struct {
+	Target string           `json:"target"`
+	Files  []*MigrationFile `json:"files"`
+}
+
+// MigrationFile is the outcome of converting one file. At most one of Output, Skipped, and Error is empty.
+type MigrationFile struct {
+	// Source is the file's path relative to the converted directory, with forward slashes.
+	Source string `json:"source"`
+	// Output is where the converted configuration was written, relative to the output directory.
+	Output   string   `json:"output,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Skipped says why a file which isn't a pipeline wasn't converted.
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Failed returns the number of files which couldn't be converted
+func (strct *Migration) Failed() int {
+	n := 0
+	for _, f := range strct.Files {
+		if f.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Markdown renders the report for reviewing the migration
+func (strct *Migration) Markdown() string {
+	buf := &bytes.Buffer{}
+	converted, warned, skipped := 0, 0, 0
+	for _, f := range strct.Files {
+		switch {
+		case f.Skipped != "":
+			skipped++
+		case f.Error == "":
+			converted++
+			if len(f.Warnings) > 0 {
+				warned++
+			}
+		}
+	}
+	fmt.Fprintf(buf, "# Migration to %s\n\n", strct.Target)
+	fmt.Fprintf(buf, "%d converted (%d with warnings), %d failed, %d skipped.\n", converted, warned, strct.Failed(),
+		skipped)
+	for _, f := range strct.Files {
+		fmt.Fprintf(buf, "\n## %s\n\n", f.Source)
+		switch {
+		case f.Error != "":
+			fmt.Fprintf(buf, "Failed: %s\n", f.Error)
+		case f.Skipped != "":
+			fmt.Fprintf(buf, "Skipped: %s\n", f.Skipped)
+		default:
+			fmt.Fprintf(buf, "Converted to `%s`.\n", f.Output)
+		}
+		if len(f.Warnings) > 0 {
+			buf.WriteString("\n")
+			for _, w := range f.Warnings {
+				fmt.Fprintf(buf, "- %s\n", w)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// convertCommand converts every file in a directory tree matching a glob, writing each converted configuration to
+// the output directory where the target expects it, relative to the directory holding the Jenkinsfile, and prints a
+// report of the migration. It fails if any file couldn't be converted, after converting the others.
+func convertCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("to", "", "the system to convert to: "+strings.Join(convert.Targets(), ", "))
+	dir := fs.String("dir", ".", "the directory to convert the Jenkinsfiles of")
+	glob := fs.String("glob", "**/Jenkinsfile", "an Ant-style pattern, relative to --dir, of the files to convert")
+	outDir := fs.String("out-dir", "", "the directory to write converted configuration to")
+	format := fs.String("format", "markdown", "the format of the report: markdown or json")
+	name := fs.String("name", "", "the name to give converted pipelines, where the target needs one; it defaults "+
+		"to the name of the directory holding the Jenkinsfile")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *target == "":
+		return errors.New("--to is required")
+	case *outDir == "":
+		return errors.New("--out-dir is required")
+	case *format != "markdown" && *format != "json":
+		return fmt.Errorf("unknown report format %q, must be markdown or json", *format)
+	}
+	if _, ok := convert.Get(*target); !ok {
+		return fmt.Errorf("unknown conversion target %q, must be one of: %s", *target,
+			strings.Join(convert.Targets(), ", "))
+	}
+
+	sources, err := matchFiles(*dir, *glob)
+	if err != nil {
+		return err
+	}
+	m := &Migration{Target: *target, Files: []*MigrationFile{}}
+	written := map[string]string{}
+	for _, source := range sources {
+		f := &MigrationFile{Source: source}
+		m.Files = append(m.Files, f)
+		opts := convert.Options{Name: *name}
+		if opts.Name == "" {
+			opts.Name = filepath.Base(filepath.Join(*dir, filepath.Dir(source)))
+		}
+		res, err := convertFile(filepath.Join(*dir, filepath.FromSlash(source)), *target, opts, f)
+		if err != nil || res == nil {
+			if err != nil {
+				f.Error = err.Error()
+			}
+			continue
+		}
+		f.Warnings = append(f.Warnings, res.Warnings...)
+		out := filepath.ToSlash(filepath.Join(filepath.Dir(source), res.Path))
+		if other, ok := written[out]; ok {
+			f.Error = fmt.Sprintf("%s was already written for %s", out, other)
+			continue
+		}
+		path := filepath.Join(*outDir, filepath.FromSlash(out))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, res.Content, 0644); err != nil {
+			return err
+		}
+		written[out] = source
+		f.Output = out
+	}
+
+	if *format == "json" {
+		b, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		fmt.Fprint(stdout, m.Markdown())
+	}
+	if n := m.Failed(); n > 0 {
+		return fmt.Errorf("%d of %d files could not be converted", n, len(m.Files))
+	}
+	return nil
+}
+
+// matchFiles returns the paths of the files in dir matching the glob, relative to dir with forward slashes, sorted.
+// Hidden directories, such as .git, are skipped.
+func matchFiles(dir string, glob string) ([]string, error) {
+	var out []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel != "." && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if when.Glob(glob, rel) {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out, err
+}
+
+// convertFile converts a Declarative or Scripted Jenkinsfile, or Kyoto JSON. It returns nil for files which aren't
+// pipelines, recording why in f, and adds the notes of Scripted conversions to f's warnings.
+func convertFile(path string, target string, opts convert.Options, f *MigrationFile) (*convert.Result, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root *model.Root
+	c := detect.Classify(src)
+	switch {
+	case (c.Kind == detect.Declarative || c.Kind == detect.KyotoJSON) && len(c.Pipelines) == 1:
+		root = c.Pipelines[0].Root
+	case c.Kind == detect.Declarative:
+		root, err = parser.Parse(bytes.NewReader(src))
+	case c.Kind == detect.KyotoJSON:
+		root, err = model.Decode(src)
+	case c.Kind == detect.Scripted:
+		var res *scripted.Result
+		if res, err = scripted.ToDeclarative(src); err == nil {
+			root = res.Root
+			f.Warnings = append(f.Warnings, "converted from a Scripted pipeline")
+			for _, n := range res.Notes {
+				f.Warnings = append(f.Warnings, fmt.Sprintf("line %d: %s", n.Line, n.Reason))
+			}
+		}
+	default:
+		f.Skipped = strings.Join(c.Reasons, "; ")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return convert.Convert(target, root, opts)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "jenkinsfile")
+	require.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestConvertCommand(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"api/Jenkinsfile": `pipeline {
+  agent { docker { image 'golang:1.14' } }
+  stages {
+    stage('Build') { steps { sh 'make' } }
+  }
+}`,
+		"web/Jenkinsfile": `node('linux') {
+  stage('Build') { sh 'npm ci' }
+}`,
+		"docs/Jenkinsfile":    "# not a pipeline\n",
+		"broken/Jenkinsfile":  "pipeline {\n  agent any\n  stages {\n    stage('Build') { deploy { } }\n  }\n}\n",
+		".git/Jenkinsfile":    "ignored",
+		"api/Jenkinsfile.old": "not matched",
+	})
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "converted")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"convert", "--to", "harness", "--dir", dir, "--out-dir", out, "--format", "json"}, stdout,
+		stderr)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "jenkinsfile convert: 1 of 4 files could not be converted\n", stderr.String())
+
+	m := &Migration{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), m))
+	assert.Equal(t, "harness", m.Target)
+	require.Len(t, m.Files, 4)
+	assert.Equal(t, "api/Jenkinsfile", m.Files[0].Source)
+	assert.Equal(t, "api/.harness/pipeline.yaml", m.Files[0].Output)
+	assert.Empty(t, m.Files[0].Warnings)
+	assert.Equal(t, "broken/Jenkinsfile", m.Files[1].Source)
+	assert.NotEmpty(t, m.Files[1].Error)
+	assert.Equal(t, "docs/Jenkinsfile", m.Files[2].Source)
+	assert.Equal(t, "no pipeline, node, or stage blocks found", m.Files[2].Skipped)
+	assert.Equal(t, "web/.harness/pipeline.yaml", m.Files[3].Output)
+	assert.Contains(t, m.Files[3].Warnings, "converted from a Scripted pipeline")
+
+	b, err := ioutil.ReadFile(filepath.Join(out, "api", ".harness", "pipeline.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "name: api\n")
+	assert.Contains(t, string(b), "image: golang:1.14\n")
+	assert.FileExists(t, filepath.Join(out, "web", ".harness", "pipeline.yaml"))
+}
+
+func TestConvertCommandMarkdown(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"Jenkinsfile": `pipeline {
+  agent { label 'linux' }
+  stages {
+    stage('Build') { steps { sh 'make' } }
+  }
+}`,
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"convert", "--to", "codefresh", "--dir", dir, "--out-dir", filepath.Join(dir, "out")},
+		stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "# Migration to codefresh\n\n"+
+		"1 converted (1 with warnings), 0 failed, 0 skipped.\n\n"+
+		"## Jenkinsfile\n\n"+
+		"Converted to `codefresh.yml`.\n\n"+
+		"- Build: label agent has no container image, using alpine:latest\n", stdout.String())
+	assert.FileExists(t, filepath.Join(dir, "out", "codefresh.yml"))
+}
+
+func TestConvertCommandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"convert", "--out-dir", "x"}, err: "jenkinsfile convert: --to is required\n"},
+		{args: []string{"convert", "--to", "harness"}, err: "jenkinsfile convert: --out-dir is required\n"},
+		{args: []string{"convert", "--to", "travis", "--out-dir", "x"},
+			err: "jenkinsfile convert: unknown conversion target \"travis\", must be one of: codefresh, harness\n"},
+		{args: []string{"convert", "--to", "harness", "--out-dir", "x", "--format", "xml"},
+			err: "jenkinsfile convert: unknown report format \"xml\", must be markdown or json\n"},
+		{args: []string{"frobnicate"}, err: "jenkinsfile: unknown command \"frobnicate\"\n"},
+	} {
+		stderr := &bytes.Buffer{}
+		assert.NotEqual(t, 0, run(tc.args, &bytes.Buffer{}, stderr))
+		assert.Equal(t, tc.err, stderr.String())
+	}
+}
@@ -0,0 +1,46 @@
+// Command jenkinsfile works with Jenkinsfiles from the command line.
+//
+// Usage:
+//
+//	jenkinsfile apply -f <patch> [--dir <dir>] [--glob <pattern>] [-w | --dry-run] [--format markdown|json]
+//	jenkinsfile audit --github-org <org> --out-dir <dir> [--token <token>] [--path <path>] [--concurrency <n>]
+//	jenkinsfile convert --to <target> [--dir <dir>] [--glob <pattern>] --out-dir <dir> [--format markdown|json]
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// commands are the subcommands, by name
+var commands = map[string]func(args []string, stdout io.Writer, stderr io.Writer) error{
+	"apply":   applyCommand,
+	"audit":   auditCommand,
+	"convert": convertCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the subcommand named by the first argument, and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: jenkinsfile <command> [arguments]\n\ncommands:\n"+
+			"  apply    apply a patch file to Jenkinsfiles\n"+
+			"  audit    audit the Jenkinsfiles of a GitHub organization\n"+
+			"  convert  convert Jenkinsfiles to other CI systems")
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "jenkinsfile: unknown command %q\n", args[0])
+		return 2
+	}
+	if err := cmd(args[1:], stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "jenkinsfile %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
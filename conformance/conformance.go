@@ -0,0 +1,312 @@
+// Package conformance is a differential testing harness comparing how this module reads and writes pipelines with how
+// Jenkins' own pipeline-model-converter does. Run over a corpus of Jenkinsfiles against a Jenkins controller, it
+// reports where the two diverge, and which parts of the Kyoto AST the corpus exercises, so gaps in both the
+// implementation and the corpus can be found.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Kinds of divergence
+const (
+	// KindRejected means Jenkins rejected the Jenkinsfile, so there is nothing to compare.
+	KindRejected = "rejected"
+	// KindError means the converter failed for another reason, such as being unreachable.
+	KindError = "error"
+	// KindDecode means the model couldn't decode Jenkins' JSON.
+	KindDecode = "decode"
+	// KindInvariant means the decoded model violates model.CheckInvariants.
+	KindInvariant = "invariant"
+	// KindRender means encoding the decoded model doesn't reproduce Jenkins' JSON.
+	KindRender = "render"
+	// KindRoundTrip means converting the model's JSON back to a Jenkinsfile and then to JSON again, with Jenkins,
+	// doesn't reproduce the original JSON.
+	KindRoundTrip = "round-trip"
+)
+
+// defaultMaxDiffs is the default limit on the differences reported for each comparison
+const defaultMaxDiffs = 10
+
+// Case is a Jenkinsfile in a corpus
+type Case struct {
+	// Name identifies the case in reports, such as the Jenkinsfile's repository and path.
+	Name        string
+	Jenkinsfile string
+}
+
+// Divergence is a difference between this module and Jenkins for one case
+type Divergence struct {
+	Case string `json:"case"`
+	Kind string `json:"kind"`
+	// Path is the JSON pointer of the difference, for render and round-trip divergences.
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report is the outcome of running a Harness over a corpus
+type Report struct {
+	Cases       int           `json:"cases"`
+	Divergences []*Divergence `json:"divergences,omitempty"`
+	// Coverage counts the cases exercising each feature of the Kyoto AST. Features are dotted paths of properties,
+	// with stages' properties under "stage", and the names of steps, conditions, options, triggers, and parameters
+	// and the types of agents in brackets, as in "stage.when.conditions[branch]".
+	Coverage map[string]int `json:"coverage"`
+	// Novel are the cases which exercised features no earlier case did, in corpus order. Running just them keeps
+	// the corpus' coverage while cutting down the requests made to Jenkins.
+	Novel []string `json:"novel,omitempty"`
+}
+
+// Features returns the features covered, sorted
+func (strct *Report) Features() []string {
+	var features []string
+	for f := range strct.Coverage {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// Harness compares this module with a Converter
+type Harness struct {
+	Converter Converter
+	// RoundTrip also checks that Jenkins converts the model's JSON back to an equivalent Jenkinsfile, at the cost of
+	// two more requests per case.
+	RoundTrip bool
+	// MaxDiffs limits the differences reported for each comparison. Zero means 10.
+	MaxDiffs int
+}
+
+// Run runs each case in the corpus: Jenkins converts it to JSON, which is decoded into a model.Root, checked, and
+// encoded again, and any difference from Jenkins' JSON is reported. Properties with default values, such as
+// "failFast": false, are equivalent to leaving them out, as Jenkins often does.
+func (strct *Harness) Run(corpus []*Case) *Report {
+	report := &Report{Coverage: map[string]int{}}
+	for _, c := range corpus {
+		report.Cases++
+		expected, err := strct.Converter.ToJSON(c.Jenkinsfile)
+		if err != nil {
+			report.add(c, converterKind(err), "", err.Error())
+			continue
+		}
+		if report.cover(expected) {
+			report.Novel = append(report.Novel, c.Name)
+		}
+		root := &model.Root{}
+		if err := json.Unmarshal(expected, root); err != nil {
+			report.add(c, KindDecode, "", err.Error())
+			continue
+		}
+		if err := model.CheckInvariants(root); err != nil {
+			report.add(c, KindInvariant, "", err.Error())
+		}
+		rendered, err := json.Marshal(root)
+		if err != nil {
+			report.add(c, KindRender, "", err.Error())
+			continue
+		}
+		strct.compare(report, c, KindRender, expected, rendered)
+		if strct.RoundTrip {
+			jenkinsfile, err := strct.Converter.ToJenkinsfile(rendered)
+			if err != nil {
+				report.add(c, KindRoundTrip, "", fmt.Sprintf("converting to a Jenkinsfile: %s", err))
+				continue
+			}
+			again, err := strct.Converter.ToJSON(jenkinsfile)
+			if err != nil {
+				report.add(c, KindRoundTrip, "", fmt.Sprintf("converting back to JSON: %s", err))
+				continue
+			}
+			strct.compare(report, c, KindRoundTrip, expected, again)
+		}
+	}
+	return report
+}
+
+func converterKind(err error) string {
+	if _, ok := err.(*RejectedError); ok {
+		return KindRejected
+	}
+	return KindError
+}
+
+func (strct *Report) add(c *Case, kind string, path string, message string) {
+	strct.Divergences = append(strct.Divergences, &Divergence{Case: c.Name, Kind: kind, Path: path, Message: message})
+}
+
+// compare reports the differences between Jenkins' JSON and other JSON for the same pipeline
+func (strct *Harness) compare(report *Report, c *Case, kind string, expected []byte, actual []byte) {
+	var e, a interface{}
+	if err := json.Unmarshal(expected, &e); err != nil {
+		report.add(c, kind, "", err.Error())
+		return
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		report.add(c, kind, "", err.Error())
+		return
+	}
+	max := strct.MaxDiffs
+	if max <= 0 {
+		max = defaultMaxDiffs
+	}
+	var diffs []*Divergence
+	diff("", e, a, func(path string, message string) bool {
+		diffs = append(diffs, &Divergence{Case: c.Name, Kind: kind, Path: path, Message: message})
+		return len(diffs) < max
+	})
+	report.Divergences = append(report.Divergences, diffs...)
+}
+
+// diff calls fn with the JSON pointer of each difference between two decoded JSON values, in document order, until fn
+// returns false. It returns false once fn has.
+func diff(path string, expected interface{}, actual interface{}, fn func(path string, message string) bool) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range e {
+			keys[k] = true
+		}
+		for k := range a {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			at := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			ev, inE := e[k]
+			av, inA := a[k]
+			switch {
+			case !inA && zero(ev), !inE && zero(av):
+				// Jenkins leaves out many properties with default values, such as "failFast": false.
+			case !inA:
+				if !fn(at, "Jenkins has "+summary(ev)+" but this module has nothing") {
+					return false
+				}
+			case !inE:
+				if !fn(at, "this module has "+summary(av)+" but Jenkins has nothing") {
+					return false
+				}
+			case !diff(at, ev, av, fn):
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(e) && i < len(a); i++ {
+			if !diff(fmt.Sprintf("%s/%d", path, i), e[i], a[i], fn) {
+				return false
+			}
+		}
+		if len(e) != len(a) {
+			return fn(path, fmt.Sprintf("Jenkins has %d elements but this module has %d", len(e), len(a)))
+		}
+		return true
+	default:
+		if expected == actual {
+			return true
+		}
+	}
+	return fn(path, "Jenkins has "+summary(expected)+" but this module has "+summary(actual))
+}
+
+// zero returns whether a decoded JSON value is null, false, zero, empty, or an empty list or object
+func zero(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// summary returns a value as short JSON
+func summary(v interface{}) string {
+	b, _ := json.Marshal(v)
+	if len(b) > 60 {
+		return string(b[:57]) + "..."
+	}
+	return string(b)
+}
+
+// namedFeatures are the lists whose elements are distinguished by name in coverage features
+var namedFeatures = map[string]bool{"steps": true, "children": true, "conditions": true, "options": true,
+	"triggers": true, "parameters": true}
+
+// cover records the features exercised by a case's JSON, and returns whether any are new
+func (strct *Report) cover(b []byte) bool {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return false
+	}
+	features := map[string]bool{}
+	collectFeatures(v, "", "", features)
+	novel := false
+	for f := range features {
+		if strct.Coverage[f] == 0 {
+			novel = true
+		}
+		strct.Coverage[f]++
+	}
+	return novel
+}
+
+func collectFeatures(v interface{}, feature string, key string, features map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok && namedFeatures[key] {
+			feature += "[" + name + "]"
+			features[feature] = true
+		}
+		if t, ok := v["type"].(string); ok && key == "agent" {
+			features[feature+"["+t+"]"] = true
+		}
+		for k, child := range v {
+			f := k
+			if feature != "" {
+				f = feature + "." + k
+			}
+			features[f] = true
+			if k == "stages" || k == "parallel" {
+				for _, s := range asList(child) {
+					collectFeatures(s, "stage", k, features)
+				}
+				continue
+			}
+			collectFeatures(child, f, k, features)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectFeatures(child, feature, key, features)
+		}
+	}
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
@@ -0,0 +1,133 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConverter converts Jenkinsfiles by looking them up, and JSON to Jenkinsfiles by returning the Jenkinsfile
+// registered for it
+type fakeConverter struct {
+	json         map[string]string
+	jenkinsfiles map[string]string
+}
+
+func (f *fakeConverter) ToJSON(jenkinsfile string) ([]byte, error) {
+	if j, ok := f.json[jenkinsfile]; ok {
+		return []byte(j), nil
+	}
+	return nil, &RejectedError{Errors: []string{"no pipeline"}}
+}
+
+func (f *fakeConverter) ToJenkinsfile(json []byte) (string, error) {
+	if j, ok := f.jenkinsfiles[string(json)]; ok {
+		return j, nil
+	}
+	return "", fmt.Errorf("unexpected JSON %s", json)
+}
+
+const simple = `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build", "branches": [{"name": "default",
+	"steps": [{"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]}]}]}]}}`
+
+func TestHarness(t *testing.T) {
+	f := &fakeConverter{json: map[string]string{
+		"simple": simple,
+		// An extra property the model doesn't know
+		"unknown": `{"pipeline": {"agent": {"type": "any"}, "stages": [], "bogus": true}}`,
+		// Duplicate stage names decode, but violate the model's invariants.
+		"duplicate": `{"pipeline": {"agent": {"type": "none"}, "stages": [
+			{"name": "a", "branches": [{"name": "default", "steps": []}]},
+			{"name": "a", "branches": [{"name": "default", "steps": []}]}]}}`,
+		"when": `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Deploy",
+			"when": {"conditions": [{"name": "branch", "arguments": {"isLiteral": true, "value": "main"}}]},
+			"branches": [{"name": "default", "steps": []}]}]}}`,
+	}}
+	h := &Harness{Converter: f}
+	report := h.Run([]*Case{{Name: "simple", Jenkinsfile: "simple"}, {Name: "again", Jenkinsfile: "simple"},
+		{Name: "rejected", Jenkinsfile: "scripted"}, {Name: "unknown", Jenkinsfile: "unknown"},
+		{Name: "duplicate", Jenkinsfile: "duplicate"}, {Name: "when", Jenkinsfile: "when"}})
+
+	assert.Equal(t, 6, report.Cases)
+	assert.Equal(t, []string{"simple", "unknown", "duplicate", "when"}, report.Novel)
+	require.Len(t, report.Divergences, 3)
+	assert.Equal(t, &Divergence{Case: "rejected", Kind: KindRejected, Message: "rejected by Jenkins: no pipeline"},
+		report.Divergences[0])
+	assert.Equal(t, "unknown", report.Divergences[1].Case)
+	assert.Equal(t, KindDecode, report.Divergences[1].Kind)
+	assert.Equal(t, KindInvariant, report.Divergences[2].Kind)
+
+	assert.Equal(t, 2, report.Coverage["stage.branches.steps[sh]"])
+	assert.Contains(t, report.Features(), "stage.when.conditions[branch]")
+	assert.Contains(t, report.Features(), "pipeline.agent[any]")
+}
+
+func TestHarnessRoundTrip(t *testing.T) {
+	rendered := `{"pipeline":{"agent":{"type":"any"},"stages":[{"branches":[{"name":"default","steps":[{"arguments":` +
+		`[{"key":"script","value":{"isLiteral":true,"value":"make"}}],"name":"sh"}]}],"failFast":false,"name":"Build"}]}}`
+	f := &fakeConverter{
+		json: map[string]string{
+			"simple": simple,
+			"regenerated": `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build", "branches": [{"name": "default",
+				"steps": [{"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make all"}}]}]}]}]}}`,
+		},
+		jenkinsfiles: map[string]string{rendered: "regenerated"},
+	}
+	report := (&Harness{Converter: f, RoundTrip: true}).Run([]*Case{{Name: "simple", Jenkinsfile: "simple"}})
+	require.Len(t, report.Divergences, 1, "%v", report.Divergences)
+	assert.Equal(t, &Divergence{Case: "simple", Kind: KindRoundTrip,
+		Path:    "/pipeline/stages/0/branches/0/steps/0/arguments/0/value/value",
+		Message: `Jenkins has "make" but this module has "make all"`}, report.Divergences[0])
+}
+
+func TestDiff(t *testing.T) {
+	var paths []string
+	diff("", map[string]interface{}{"a/b": []interface{}{1.0, 2.0}, "c": true, "d": "x"},
+		map[string]interface{}{"a/b": []interface{}{1.0}, "c": false, "e": nil, "f": []interface{}{}}, func(path string, message string) bool {
+			paths = append(paths, path+": "+message)
+			return len(paths) < 3
+		})
+	assert.Equal(t, []string{
+		"/a~1b: Jenkins has 2 elements but this module has 1",
+		"/c: Jenkins has true but this module has false",
+		"/d: Jenkins has \"x\" but this module has nothing",
+	}, paths)
+}
+
+func TestJenkins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ := r.BasicAuth()
+		require.NoError(t, r.ParseForm())
+		switch {
+		case user != "admin" || token != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/jenkins/pipeline-model-converter/toJson" && r.PostForm.Get("jenkinsfile") == "pipeline {}":
+			fmt.Fprint(w, `{"status": "ok", "data": {"result": "failure", "errors": [{"error": ["Missing required section 'stages'", "Missing required section 'agent'"]}]}}`)
+		case r.URL.Path == "/jenkins/pipeline-model-converter/toJson":
+			fmt.Fprint(w, `{"status": "ok", "data": {"result": "success", "json": {"pipeline": {}}}}`)
+		case r.URL.Path == "/jenkins/pipeline-model-converter/toJenkinsfile":
+			fmt.Fprintf(w, `{"status": "ok", "data": {"result": "success", "jenkinsfile": %q}}`, "// "+r.PostForm.Get("json"))
+		}
+	}))
+	defer server.Close()
+
+	j := &Jenkins{URL: server.URL + "/jenkins/", User: "admin", Token: "secret"}
+	b, err := j.ToJSON("pipeline { agent any }")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"pipeline": {}}`, string(b))
+
+	_, err = j.ToJSON("pipeline {}")
+	assert.EqualError(t, err, "rejected by Jenkins: Missing required section 'stages'; Missing required section 'agent'")
+
+	jenkinsfile, err := j.ToJenkinsfile([]byte(`{"pipeline": {}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `// {"pipeline": {}}`, jenkinsfile)
+
+	j.Token = "wrong"
+	_, err = j.ToJSON("pipeline {}")
+	assert.EqualError(t, err, "toJson: 401 Unauthorized")
+}
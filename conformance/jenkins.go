@@ -0,0 +1,118 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Converter converts between Jenkinsfiles and the Kyoto AST JSON, as Jenkins' pipeline-model-converter does
+type Converter interface {
+	// ToJSON converts a Declarative Jenkinsfile to its JSON representation.
+	ToJSON(jenkinsfile string) ([]byte, error)
+	// ToJenkinsfile converts a JSON representation back to a Jenkinsfile.
+	ToJenkinsfile(json []byte) (string, error)
+}
+
+// RejectedError is returned by a Converter when Jenkins rejects its input, as opposed to failing to convert it
+type RejectedError struct {
+	Errors []string
+}
+
+func (e *RejectedError) Error() string {
+	return "rejected by Jenkins: " + strings.Join(e.Errors, "; ")
+}
+
+// Jenkins is a Converter using the pipeline-model-converter endpoints of a Jenkins controller, which the Pipeline:
+// Declarative plugin installs
+type Jenkins struct {
+	// URL is the controller's root URL, such as "https://jenkins.example.com/".
+	URL string
+	// User and Token are the credentials to authenticate with, if any. Authenticating with an API token means no
+	// CSRF crumb is needed.
+	User  string
+	Token string
+	// Client is the HTTP client to use. Nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// converterResponse is the response of the converter endpoints
+type converterResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result      string          `json:"result"`
+		JSON        json.RawMessage `json:"json"`
+		Jenkinsfile string          `json:"jenkinsfile"`
+		Errors      []struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"errors"`
+	} `json:"data"`
+}
+
+// ToJSON posts a Jenkinsfile to the toJson endpoint.
+func (strct *Jenkins) ToJSON(jenkinsfile string) ([]byte, error) {
+	resp, err := strct.post("toJson", url.Values{"jenkinsfile": {jenkinsfile}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.JSON, nil
+}
+
+// ToJenkinsfile posts JSON to the toJenkinsfile endpoint.
+func (strct *Jenkins) ToJenkinsfile(json []byte) (string, error) {
+	resp, err := strct.post("toJenkinsfile", url.Values{"json": {string(json)}})
+	if err != nil {
+		return "", err
+	}
+	return resp.Data.Jenkinsfile, nil
+}
+
+func (strct *Jenkins) post(endpoint string, form url.Values) (*converterResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(strct.URL, "/")+"/pipeline-model-converter/"+endpoint,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if strct.User != "" {
+		req.SetBasicAuth(strct.User, strct.Token)
+	}
+	client := strct.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", endpoint, httpResp.Status)
+	}
+	resp := &converterResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, fmt.Errorf("%s: %s", endpoint, err)
+	}
+	if resp.Data.Result != "success" {
+		rejected := &RejectedError{}
+		for _, e := range resp.Data.Errors {
+			// Errors are either strings or lists of strings.
+			var msg string
+			var msgs []string
+			if json.Unmarshal(e.Error, &msg) == nil {
+				rejected.Errors = append(rejected.Errors, msg)
+			} else if json.Unmarshal(e.Error, &msgs) == nil {
+				rejected.Errors = append(rejected.Errors, msgs...)
+			}
+		}
+		return nil, rejected
+	}
+	return resp, nil
+}
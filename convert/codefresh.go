@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+func init() {
+	Register(&codefresh{})
+}
+
+// codefresh converts to a Codefresh pipeline. Each top-level stage becomes a Codefresh stage, leaf stages become
+// freestyle steps, and parallel stages become parallel steps. A git-clone step is added first, since Jenkins checks
+// out the repository implicitly.
+type codefresh struct{}
+
+func (c *codefresh) Name() string {
+	return "codefresh"
+}
+
+func (c *codefresh) Convert(p *plan.Plan, opts Options) (*Result, error) {
+	w := &warnings{}
+	if len(p.Post) > 0 {
+		w.add("pipeline", "post conditions are not converted")
+	}
+	settingsWarnings(p, w)
+
+	ids := identifiers{"clone": true}
+	stages := []string{"clone"}
+	steps := yaml.MapSlice{{Key: "clone", Value: yaml.MapSlice{
+		{Key: "title", Value: "Cloning repository"},
+		{Key: "type", Value: "git-clone"},
+		{Key: "repo", Value: "${{CF_REPO_OWNER}}/${{CF_REPO_NAME}}"},
+		{Key: "revision", Value: "${{CF_REVISION}}"},
+		{Key: "stage", Value: "clone"},
+	}}}
+	for _, s := range p.Stages {
+		stages = append(stages, s.Name)
+		steps = append(steps, c.steps(s, s.Name, opts, w, ids)...)
+	}
+
+	out := yaml.MapSlice{
+		{Key: "version", Value: "1.0"},
+		{Key: "stages", Value: stages},
+		{Key: "steps", Value: steps},
+	}
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Path: "codefresh.yml", Content: b, Warnings: w.list}, nil
+}
+
+// steps returns the Codefresh steps for a stage and its children, keyed by step name.
+func (c *codefresh) steps(s *plan.Stage, stage string, opts Options, w *warnings, ids identifiers) yaml.MapSlice {
+	stageWarnings(s.ID, s, w)
+	var out yaml.MapSlice
+	if len(s.Steps) > 0 {
+		out = append(out, yaml.MapItem{Key: ids.unique(codefreshName(s.ID)), Value: c.freestyle(s, stage, opts, w)})
+	}
+	switch s.ChildMode {
+	case plan.Sequential:
+		for _, child := range s.Children {
+			out = append(out, c.steps(child, stage, opts, w, ids)...)
+		}
+	case plan.Parallel:
+		var parallel yaml.MapSlice
+		for _, child := range s.Children {
+			parallel = append(parallel, c.steps(child, "", opts, w, ids)...)
+		}
+		out = append(out, yaml.MapItem{Key: ids.unique(codefreshName(s.ID + "/parallel")), Value: yaml.MapSlice{
+			{Key: "title", Value: s.Name},
+			{Key: "type", Value: "parallel"},
+			{Key: "stage", Value: stage},
+			{Key: "steps", Value: parallel},
+		}})
+	}
+	return out
+}
+
+func (c *codefresh) freestyle(s *plan.Stage, stage string, opts Options, w *warnings) yaml.MapSlice {
+	step := yaml.MapSlice{
+		{Key: "title", Value: s.Name},
+		{Key: "type", Value: "freestyle"},
+	}
+	if stage != "" {
+		step = append(step, yaml.MapItem{Key: "stage", Value: stage})
+	}
+	step = append(step,
+		yaml.MapItem{Key: "image", Value: image(s.ID, s, opts, w)},
+		yaml.MapItem{Key: "working_directory", Value: "${{clone}}"},
+	)
+	if len(s.Environment) > 0 {
+		var env []string
+		for _, e := range s.Environment {
+			value := e.Value
+			switch {
+			case e.Credential != "":
+				value = fmt.Sprintf("${{%s}}", e.Key)
+				w.add("environment", "%s must be defined as a secret variable holding credential %q", e.Key, e.Credential)
+			case !e.Literal:
+				w.add(s.ID, "environment variable %s is a Groovy expression and is converted verbatim", e.Key)
+			}
+			env = append(env, e.Key+"="+value)
+		}
+		step = append(step, yaml.MapItem{Key: "environment", Value: env})
+	}
+	if s.Resources != nil {
+		w.add(s.ID, "resources are not converted; Codefresh steps use the resources of the pipeline's runtime")
+	}
+	return append(step, yaml.MapItem{Key: "commands", Value: commands(s.ID, s.Steps, w)})
+}
+
+var codefreshInvalid = regexp.MustCompile(`[^0-9A-Za-z_-]+`)
+
+func codefreshName(id string) string {
+	return codefreshInvalid.ReplaceAllString(id, "_")
+}
@@ -0,0 +1,256 @@
+// Package convert translates pipelines into the configuration formats of other CI systems. Each target is a Converter
+// registered by name, written against the execution plan from the plan package.
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Options are settings common to all converters
+type Options struct {
+	// Name is the name to give the converted pipeline, where the target needs one.
+	Name string
+	// DefaultImage is the container image used for stages whose agent isn't a docker agent, where the target requires
+	// an image. It defaults to "alpine:latest".
+	DefaultImage string
+}
+
+// Result is the output of a conversion
+type Result struct {
+	// Path is where the target system expects the configuration, relative to the repository root.
+	Path string
+	// Content is the converted configuration.
+	Content []byte
+	// Warnings describe parts of the pipeline which could not be converted faithfully.
+	Warnings []string
+}
+
+// Converter converts an execution plan to a target system's configuration
+type Converter interface {
+	// Name is the name the converter is registered under.
+	Name() string
+	// Convert converts the plan.
+	Convert(p *plan.Plan, opts Options) (*Result, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Converter{}
+)
+
+// Register makes a converter available by its name. It panics if a converter is already registered with that name.
+func Register(c Converter) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, exists := registry[c.Name()]; exists {
+		panic(fmt.Sprintf("converter %q is already registered", c.Name()))
+	}
+	registry[c.Name()] = c
+}
+
+// Get returns the converter registered with the given name.
+func Get(name string) (Converter, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Targets returns the names of all registered converters, sorted.
+func Targets() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Convert builds the execution plan for root and converts it with the converter registered as target. The duration
+// and number of warnings are reported to the metrics sink.
+func Convert(target string, root *model.Root, opts Options) (*Result, error) {
+	c, ok := Get(target)
+	if !ok {
+		return nil, fmt.Errorf("unknown conversion target %q, must be one of: %s", target, strings.Join(Targets(), ", "))
+	}
+	labels := metrics.Labels{"target": target}
+	defer metrics.Since(metrics.ConversionDuration, labels, time.Now())
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Convert(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Add(metrics.ConversionWarnings, labels, float64(len(res.Warnings)))
+	return res, nil
+}
+
+// warnings collects conversion warnings, dropping duplicates
+type warnings struct {
+	list []string
+	seen map[string]bool
+}
+
+func (w *warnings) add(where string, format string, args ...interface{}) {
+	msg := fmt.Sprintf("%s: %s", where, fmt.Sprintf(format, args...))
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+	if !w.seen[msg] {
+		w.seen[msg] = true
+		w.list = append(w.list, msg)
+	}
+}
+
+// identifiers makes a conversion's identifiers unique. Targets whose identifiers allow fewer characters than stage
+// names can otherwise give stages whose names differ only in punctuation the same identifier.
+type identifiers map[string]bool
+
+// unique returns id, or if it's already used, id with the first suffix of "_2", "_3", and so on which isn't
+func (strct identifiers) unique(id string) string {
+	out := id
+	for n := 2; strct[out]; n++ {
+		out = fmt.Sprintf("%s_%d", id, n)
+	}
+	strct[out] = true
+	return out
+}
+
+// commands translates a stage's steps to shell commands, recording a warning for each step without a shell
+// equivalent.
+func commands(where string, steps []*plan.Step, w *warnings) []string {
+	var out []string
+	for _, s := range steps {
+		switch s.Name {
+		case "sh":
+			out = append(out, s.Script)
+		case "echo":
+			out = append(out, "echo "+shellQuote(s.Source.Step.Arguments.Get("message").String()))
+		case "dir":
+			dir := shellQuote(s.Source.Tree.Arguments.Get("path").String())
+			for _, c := range commands(where, s.Children, w) {
+				out = append(out, "cd "+dir+" && "+c)
+			}
+		default:
+			if len(s.Children) > 0 {
+				w.add(where, "%s block is not converted; its contents are", s.Name)
+				out = append(out, commands(where, s.Children, w)...)
+			} else {
+				w.add(where, "step %s has no equivalent and is skipped", s.Name)
+			}
+		}
+	}
+	return out
+}
+
+func image(where string, s *plan.Stage, opts Options, w *warnings) string {
+	if s.Agent != nil && s.Agent.Image != "" {
+		return s.Agent.Image
+	}
+	img := opts.DefaultImage
+	if img == "" {
+		img = "alpine:latest"
+	}
+	agentType := "no"
+	if s.Agent != nil {
+		agentType = s.Agent.Type
+	}
+	w.add(where, "%s agent has no container image, using %s", agentType, img)
+	return img
+}
+
+// resourceLimits returns the memory and CPU limits of a stage for targets which only have limits, using the requests
+// where there are no limits
+func resourceLimits(where string, r *plan.Resources, w *warnings) yaml.MapSlice {
+	var out yaml.MapSlice
+	if memory := r.Memory(); memory != "" {
+		out = append(out, yaml.MapItem{Key: "memory", Value: memory})
+	}
+	if cpu := r.CPU(); cpu != "" {
+		out = append(out, yaml.MapItem{Key: "cpu", Value: cpu})
+	}
+	if r != nil && (r.CPULimit != "" && r.CPURequest != "" || r.MemoryLimit != "" && r.MemoryRequest != "") {
+		w.add(where, "resource requests are not converted; only limits are")
+	}
+	return out
+}
+
+func stageWarnings(where string, s *plan.Stage, w *warnings) {
+	if s.When != nil {
+		w.add(where, "when conditions are not converted; the stage always runs")
+	}
+	if s.Input != nil {
+		w.add(where, "input is not converted")
+	}
+	if len(s.Post) > 0 {
+		w.add(where, "post conditions are not converted")
+	}
+	if s.ChildMode == plan.Matrix {
+		w.add(where, "matrix stages are not converted")
+	}
+	if len(s.DependsOn) > 0 {
+		w.add(where, "dependsOn is not converted; the stage runs in sequence")
+	}
+	concurrencyWarnings(where, s.Concurrency, w)
+}
+
+// settingsWarnings records warnings for pipeline options which affect how Jenkins runs the pipeline but which no
+// target can express.
+func settingsWarnings(p *plan.Plan, w *warnings) {
+	for _, msg := range p.Settings.Warnings {
+		w.add("options", "%s", msg)
+	}
+	if p.Settings.QuietPeriod != nil {
+		w.add("options", "quietPeriod of %s is not converted; builds start immediately", *p.Settings.QuietPeriod)
+	}
+	if dir := p.Settings.CheckoutToSubdirectory; dir != "" {
+		w.add("options", "checkoutToSubdirectory is not converted; the repository is checked out to the working "+
+			"directory rather than %s/, so paths under %s/ must be adjusted", dir, dir)
+	}
+	concurrencyWarnings("options", p.Concurrency, w)
+}
+
+// concurrencyWarnings records warnings for locks, throttles, and disableConcurrentBuilds, which no target enforces,
+// so converted builds may run concurrently where Jenkins builds wouldn't.
+func concurrencyWarnings(where string, c *plan.Concurrency, w *warnings) {
+	if c == nil {
+		return
+	}
+	for _, msg := range c.Warnings {
+		w.add(where, "%s", msg)
+	}
+	switch {
+	case c.AbortPrevious:
+		w.add(where, "disableConcurrentBuilds(abortPrevious: true) is not converted; new builds don't abort running ones")
+	case c.Serial:
+		w.add(where, "disableConcurrentBuilds is not converted; builds may run concurrently")
+	}
+	for _, l := range c.Locks {
+		what := fmt.Sprintf("resource %s", l.Resource)
+		if l.Resource == "" {
+			what = fmt.Sprintf("resources labelled %s", l.Label)
+		}
+		w.add(where, "lock of %s is not converted; concurrent builds may use it at the same time", what)
+	}
+	for _, t := range c.Throttles {
+		w.add(where, "throttle of categories %s is not converted", strings.Join(t.Categories, ", "))
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, name string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestTargets(t *testing.T) {
+	assert.Equal(t, []string{"codefresh", "harness"}, Targets())
+
+	_, err := Convert("nope", loadRoot(t, "basic"), Options{})
+	assert.EqualError(t, err, `unknown conversion target "nope", must be one of: codefresh, harness`)
+}
+
+func TestConverters(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected string
+		path     string
+		warnings []string
+	}{
+		{
+			target:   "harness",
+			expected: "basic.harness.yaml",
+			path:     ".harness/pipeline.yaml",
+			warnings: []string{
+				"Tests/Unit: step junit has no equivalent and is skipped",
+				"Tests/Lint: label agent has no container image, using alpine:latest",
+			},
+		},
+		{
+			target:   "codefresh",
+			expected: "basic.codefresh.yml",
+			path:     "codefresh.yml",
+			warnings: []string{
+				"environment: TOKEN must be defined as a secret variable holding credential \"github-token\"",
+				"Tests/Unit: step junit has no equivalent and is skipped",
+				"Tests/Lint: label agent has no container image, using alpine:latest",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			result, err := Convert(tc.target, loadRoot(t, "basic"), Options{Name: "basic"})
+			require.NoError(t, err)
+
+			expected, err := ioutil.ReadFile(filepath.Join("testdata", tc.expected))
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(result.Content))
+			assert.Equal(t, tc.path, result.Path)
+			assert.Equal(t, tc.warnings, result.Warnings)
+		})
+	}
+}
+
+func TestSettingsWarnings(t *testing.T) {
+	root := loadRoot(t, "basic")
+	require.NoError(t, json.Unmarshal([]byte(`{"options": [
+		{"name": "quietPeriod", "arguments": [{"isLiteral": true, "value": 10}]},
+		{"name": "checkoutToSubdirectory", "arguments": [{"isLiteral": true, "value": "src"}]},
+		{"name": "durabilityHint", "arguments": [{"isLiteral": true, "value": "FAST"}]}
+	]}`), &root.Pipeline.Options))
+
+	result, err := Convert("harness", root, Options{})
+	require.NoError(t, err)
+	assert.Subset(t, result.Warnings, []string{
+		`options: option durabilityHint: unknown hint "FAST"`,
+		"options: quietPeriod of 10s is not converted; builds start immediately",
+		"options: checkoutToSubdirectory is not converted; the repository is checked out to the working directory " +
+			"rather than src/, so paths under src/ must be adjusted",
+	})
+}
+
+func TestDependsOnWarning(t *testing.T) {
+	root := loadRoot(t, "basic")
+	last := root.Pipeline.Stages[len(root.Pipeline.Stages)-1]
+	last.DependsOn = []string{root.Pipeline.Stages[0].Name}
+
+	result, err := Convert("codefresh", root, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, result.Warnings, last.Name+": dependsOn is not converted; the stage runs in sequence")
+}
+
+func TestConcurrencyWarnings(t *testing.T) {
+	root := loadRoot(t, "basic")
+	require.NoError(t, json.Unmarshal([]byte(`{"options": [
+		{"name": "disableConcurrentBuilds"},
+		{"name": "lock", "arguments": [{"key": "label", "value": {"isLiteral": true, "value": "gpu"}}]}
+	]}`), &root.Pipeline.Options))
+	first := root.Pipeline.Stages[0]
+	require.NoError(t, json.Unmarshal([]byte(`{"options": [
+		{"name": "throttle", "arguments": [{"isLiteral": false, "value": "['db']"}]}
+	]}`), &first.Options))
+
+	result, err := Convert("codefresh", root, Options{})
+	require.NoError(t, err)
+	assert.Subset(t, result.Warnings, []string{
+		"options: disableConcurrentBuilds is not converted; builds may run concurrently",
+		"options: lock of resources labelled gpu is not converted; concurrent builds may use it at the same time",
+		first.Name + ": throttle of categories db is not converted",
+	})
+}
+
+func TestResources(t *testing.T) {
+	root := loadRoot(t, "basic")
+	first := root.Pipeline.Stages[0]
+	first.Annotations.Set(plan.AnnotationCPURequest, "500m")
+	first.Annotations.Set(plan.AnnotationCPULimit, "1")
+	first.Annotations.Set(plan.AnnotationMemoryRequest, "2Gi")
+
+	result, err := Convert("harness", root, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(result.Content), `
+                resources:
+                  limits:
+                    memory: 2Gi
+                    cpu: "1"
+`)
+	assert.Contains(t, result.Warnings, first.Name+": resource requests are not converted; only limits are")
+
+	result, err = Convert("codefresh", root, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, result.Warnings,
+		first.Name+": resources are not converted; Codefresh steps use the resources of the pipeline's runtime")
+}
+
+func TestUniqueIdentifiers(t *testing.T) {
+	sh := func(script string) []*model.Branch {
+		arg := &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: &script}}
+		return []*model.Branch{{Name: "default", Steps: []*model.AnyStep{
+			model.NewAnyStep(&model.Step{Name: "sh", Arguments: model.NewSingleArgument(arg)}),
+		}}}
+	}
+	root := &model.Root{Pipeline: &model.Pipeline{Agent: &model.Agent{Type: "any"}, Stages: []*model.Stage{
+		{Name: "Build & Test", Branches: sh("make")},
+		{Name: "Build + Test", Branches: sh("make test")},
+	}}}
+
+	result, err := Convert("harness", root, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(result.Content), "identifier: Build_Test\n")
+	assert.Contains(t, string(result.Content), "identifier: Build_Test_2\n")
+	assert.Contains(t, string(result.Content), "identifier: Build_Test_run\n")
+	assert.Contains(t, string(result.Content), "identifier: Build_Test_run_2\n")
+
+	result, err = Convert("codefresh", root, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(result.Content), "\n  Build_Test:\n")
+	assert.Contains(t, string(result.Content), "\n  Build_Test_2:\n")
+}
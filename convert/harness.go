@@ -0,0 +1,146 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+func init() {
+	Register(&harness{})
+}
+
+// harness converts to a Harness CI pipeline. Each top-level stage becomes a CI stage; nested sequential and parallel
+// stages become step groups and parallel step groups within it.
+type harness struct{}
+
+func (h *harness) Name() string {
+	return "harness"
+}
+
+func (h *harness) Convert(p *plan.Plan, opts Options) (*Result, error) {
+	w := &warnings{}
+	ids := identifiers{}
+	name := opts.Name
+	if name == "" {
+		name = "pipeline"
+	}
+	if len(p.Post) > 0 {
+		w.add("pipeline", "post conditions are not converted")
+	}
+	settingsWarnings(p, w)
+
+	var stages []interface{}
+	for _, s := range p.Stages {
+		stageWarnings(s.ID, s, w)
+		stages = append(stages, yaml.MapSlice{{Key: "stage", Value: yaml.MapSlice{
+			{Key: "name", Value: s.Name},
+			{Key: "identifier", Value: ids.unique(harnessIdentifier(s.ID))},
+			{Key: "type", Value: "CI"},
+			{Key: "spec", Value: yaml.MapSlice{
+				{Key: "cloneCodebase", Value: true},
+				{Key: "platform", Value: yaml.MapSlice{{Key: "os", Value: "Linux"}, {Key: "arch", Value: "Amd64"}}},
+				{Key: "runtime", Value: yaml.MapSlice{{Key: "type", Value: "Cloud"}, {Key: "spec", Value: yaml.MapSlice{}}}},
+				{Key: "execution", Value: yaml.MapSlice{{Key: "steps", Value: h.items(s, opts, w, ids)}}},
+			}},
+		}}})
+	}
+
+	out := yaml.MapSlice{{Key: "pipeline", Value: yaml.MapSlice{
+		{Key: "name", Value: name},
+		{Key: "identifier", Value: harnessIdentifier(name)},
+		{Key: "projectIdentifier", Value: "<+input>"},
+		{Key: "orgIdentifier", Value: "<+input>"},
+		{Key: "properties", Value: yaml.MapSlice{{Key: "ci", Value: yaml.MapSlice{{Key: "codebase", Value: yaml.MapSlice{
+			{Key: "connectorRef", Value: "<+input>"},
+			{Key: "build", Value: "<+input>"},
+		}}}}}},
+		{Key: "stages", Value: stages},
+	}}}
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Path: ".harness/pipeline.yaml", Content: b, Warnings: w.list}, nil
+}
+
+// items returns the execution items for a stage: a Run step for its own steps, followed by the items of its children.
+func (h *harness) items(s *plan.Stage, opts Options, w *warnings, ids identifiers) []interface{} {
+	var items []interface{}
+	if len(s.Steps) > 0 {
+		items = append(items, yaml.MapSlice{{Key: "step", Value: h.runStep(s, opts, w, ids)}})
+	}
+	switch s.ChildMode {
+	case plan.Sequential:
+		for _, c := range s.Children {
+			stageWarnings(c.ID, c, w)
+			items = append(items, h.group(c, opts, w, ids))
+		}
+	case plan.Parallel:
+		var parallel []interface{}
+		for _, c := range s.Children {
+			stageWarnings(c.ID, c, w)
+			parallel = append(parallel, h.group(c, opts, w, ids))
+		}
+		items = append(items, yaml.MapSlice{{Key: "parallel", Value: parallel}})
+	}
+	return items
+}
+
+func (h *harness) group(s *plan.Stage, opts Options, w *warnings, ids identifiers) yaml.MapSlice {
+	return yaml.MapSlice{{Key: "stepGroup", Value: yaml.MapSlice{
+		{Key: "name", Value: s.Name},
+		{Key: "identifier", Value: ids.unique(harnessIdentifier(s.ID))},
+		{Key: "steps", Value: h.items(s, opts, w, ids)},
+	}}}
+}
+
+func (h *harness) runStep(s *plan.Stage, opts Options, w *warnings, ids identifiers) yaml.MapSlice {
+	spec := yaml.MapSlice{
+		{Key: "connectorRef", Value: "<+input>"},
+		{Key: "image", Value: image(s.ID, s, opts, w)},
+		{Key: "shell", Value: "Sh"},
+		{Key: "command", Value: strings.Join(commands(s.ID, s.Steps, w), "\n")},
+	}
+	if len(s.Environment) > 0 {
+		env := yaml.MapSlice{}
+		for _, e := range s.Environment {
+			value := e.Value
+			switch {
+			case e.Credential != "":
+				value = fmt.Sprintf("<+secrets.getValue(%q)>", e.Credential)
+			case !e.Literal:
+				w.add(s.ID, "environment variable %s is a Groovy expression and is converted verbatim", e.Key)
+			}
+			env = append(env, yaml.MapItem{Key: e.Key, Value: value})
+		}
+		spec = append(spec, yaml.MapItem{Key: "envVariables", Value: env})
+	}
+	if limits := resourceLimits(s.ID, s.Resources, w); len(limits) > 0 {
+		spec = append(spec, yaml.MapItem{Key: "resources", Value: yaml.MapSlice{{Key: "limits", Value: limits}}})
+	}
+	return yaml.MapSlice{
+		{Key: "type", Value: "Run"},
+		{Key: "name", Value: s.Name},
+		{Key: "identifier", Value: ids.unique(harnessIdentifier(s.ID + "_run"))},
+		{Key: "spec", Value: spec},
+	}
+}
+
+var harnessInvalid = regexp.MustCompile(`[^0-9A-Za-z_]+`)
+
+// harnessIdentifier converts a name to a valid Harness identifier
+func harnessIdentifier(name string) string {
+	id := harnessInvalid.ReplaceAllString(name, "_")
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "_" + id
+	}
+	if len(id) > 128 {
+		id = id[:128]
+	}
+	return id
+}
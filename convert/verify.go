@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/transform"
+)
+
+// Importer is implemented by converters which can also read their target's configuration back into a pipeline, such
+// as for migrating back to Jenkins. Verify uses it to measure how much of a pipeline survives conversion.
+type Importer interface {
+	// Import reads configuration in the target's format, as written by Convert.
+	Import(content []byte) (*model.Root, error)
+}
+
+// Fidelity is how faithfully a pipeline survives converting it to a target and importing the result back
+type Fidelity struct {
+	Target string `json:"target"`
+	// Changes are the differences between the pipeline and the one imported back.
+	Changes *transform.Changes `json:"changes"`
+	// Compared counts the stages and pipeline sections, such as its agent and options, which were compared, and
+	// Preserved those which came back unchanged.
+	Compared  int `json:"compared"`
+	Preserved int `json:"preserved"`
+	// Warnings are those of the conversion.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Score is the fraction of the stages and sections compared which were preserved, from 0 to 1. A pipeline with
+// nothing to compare scores 1.
+func (strct *Fidelity) Score() float64 {
+	if strct.Compared == 0 {
+		return 1
+	}
+	return float64(strct.Preserved) / float64(strct.Compared)
+}
+
+// Verify converts the pipeline with the converter registered as target, imports the result back, and reports the
+// differences. The converter must implement Importer.
+func Verify(target string, root *model.Root, opts Options) (*Fidelity, error) {
+	c, ok := Get(target)
+	if !ok {
+		return nil, fmt.Errorf("unknown conversion target %q, must be one of: %s", target, strings.Join(Targets(), ", "))
+	}
+	return verify(c, root, opts)
+}
+
+func verify(c Converter, root *model.Root, opts Options) (*Fidelity, error) {
+	importer, ok := c.(Importer)
+	if !ok {
+		return nil, fmt.Errorf("conversion target %q can't import its configuration, so it can't be verified", c.Name())
+	}
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Convert(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := importer.Import(res.Content)
+	if err != nil {
+		return nil, fmt.Errorf("importing the converted configuration: %s", err)
+	}
+	changes, err := transform.Summarize(root, imported)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fidelity{Target: c.Name(), Changes: changes, Warnings: res.Warnings}
+
+	before, after := root.Pipeline, imported.Pipeline
+	for _, s := range []struct {
+		present bool
+		changed bool
+	}{
+		{before.Agent != nil || after.Agent != nil, changes.Agent},
+		{before.Options != nil || after.Options != nil, changes.Options != nil},
+		{len(before.Environment) > 0 || len(after.Environment) > 0, changes.Environment != nil},
+		{len(before.Tools) > 0 || len(after.Tools) > 0, changes.Tools != nil},
+		{before.Parameters != nil || after.Parameters != nil, changes.Parameters != nil},
+		{before.Triggers != nil || after.Triggers != nil, changes.Triggers != nil},
+		{before.Libraries != nil || after.Libraries != nil, changes.Libraries},
+		{before.Post != nil || after.Post != nil, changes.Post != nil},
+	} {
+		if s.present {
+			f.Compared++
+			if !s.changed {
+				f.Preserved++
+			}
+		}
+	}
+	// Stages are matched by path, so those the round trip added count against it as well as those it removed or
+	// changed.
+	walk.Stages(root.Pipeline.Stages, func(*model.Stage, []*model.Stage) {
+		f.Compared++
+		f.Preserved++
+	})
+	for _, s := range changes.Stages {
+		if s.Change == transform.StageAdded {
+			f.Compared++
+		} else {
+			f.Preserved--
+		}
+	}
+	return f, nil
+}
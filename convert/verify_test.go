@@ -0,0 +1,61 @@
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lossyConverter writes the pipeline as Kyoto JSON without its environment or parallel stages after the first, and
+// imports that JSON back
+type lossyConverter struct{}
+
+func (lossyConverter) Name() string {
+	return "lossy"
+}
+
+func (lossyConverter) Convert(p *plan.Plan, opts Options) (*Result, error) {
+	root := p.Source.DeepCopy()
+	root.Pipeline.Environment = nil
+	for _, s := range root.Pipeline.Stages {
+		if len(s.Parallel) > 1 {
+			s.Parallel = s.Parallel[:1]
+		}
+	}
+	b, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Path: "pipeline.json", Content: b, Warnings: []string{"environment is not converted"}}, nil
+}
+
+func (lossyConverter) Import(content []byte) (*model.Root, error) {
+	return model.Decode(content)
+}
+
+func TestVerify(t *testing.T) {
+	f, err := verify(lossyConverter{}, loadRoot(t, "basic"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "lossy", f.Target)
+	assert.Equal(t, []string{"environment is not converted"}, f.Warnings)
+	assert.Equal(t, &transform.NameChanges{Removed: []string{"GOFLAGS", "TOKEN"}}, f.Changes.Environment)
+	require.Len(t, f.Changes.Stages, 1)
+	assert.Equal(t, "Tests/Lint", f.Changes.Stages[0].Stage)
+	assert.Equal(t, transform.StageRemoved, f.Changes.Stages[0].Change)
+	assert.Equal(t, 6, f.Compared)
+	assert.Equal(t, 4, f.Preserved)
+	assert.InDelta(t, 4.0/6.0, f.Score(), 0.0001)
+}
+
+func TestVerifyWithoutImporter(t *testing.T) {
+	_, err := Verify("harness", loadRoot(t, "basic"), Options{})
+	assert.EqualError(t, err, `conversion target "harness" can't import its configuration, so it can't be verified`)
+
+	_, err = Verify("nope", loadRoot(t, "basic"), Options{})
+	assert.EqualError(t, err, `unknown conversion target "nope", must be one of: codefresh, harness`)
+}
@@ -0,0 +1,353 @@
+// Package cron parses and evaluates the schedules of Jenkins cron and pollSCM triggers. Schedules use Jenkins' cron
+// syntax: five fields (minute, hour, day of month, month, and day of week) supporting *, ranges, steps, lists, and
+// H for a value spread by a hash, one schedule per line. A TZ=Region/City line sets the time zone for the lines after
+// it; otherwise they use the controller's zone.
+package cron
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron spec, which fires whenever any of its lines do
+type Schedule struct {
+	Entries []*Entry
+}
+
+// Entry is a single line of a schedule
+type Entry struct {
+	// Spec is the line as written.
+	Spec string
+	// Location is the zone the line is evaluated in, from a preceding TZ= line, or nil for the controller's zone.
+	Location *time.Location
+
+	minute, hour, dom, month, dow uint64
+	// domRestricted and dowRestricted are false for fields written as *.
+	domRestricted, dowRestricted bool
+}
+
+// aliases are the @ shortcuts Jenkins accepts, with H spreading their start times
+var aliases = map[string]string{
+	"@yearly":   "H H H H *",
+	"@annually": "H H H H *",
+	"@monthly":  "H H H * *",
+	"@weekly":   "H H * * H",
+	"@daily":    "H H * * *",
+	"@midnight": "H H(0-2) * * *",
+	"@hourly":   "H * * * *",
+}
+
+// field describes one of the five fields
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12},
+	{"day of week", 0, 7}}
+
+// hashRanges are the ranges H picks from, which for days of the month avoids days some months lack
+var hashRanges = []field{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 28}, {"month", 1, 12},
+	{"day of week", 0, 6}}
+
+// Parse parses a cron spec. H picks values from a hash of seed, as Jenkins does from the job's name, though not
+// necessarily the same values Jenkins would pick.
+func Parse(spec string, seed string) (*Schedule, error) {
+	s := &Schedule{}
+	var loc *time.Location
+	for i, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "TZ=") {
+			zone := strings.TrimPrefix(line, "TZ=")
+			l, err := LoadZone(zone)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			loc = l
+			continue
+		}
+		e, err := parseEntry(line, seed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		e.Location = loc
+		s.Entries = append(s.Entries, e)
+	}
+	return s, nil
+}
+
+// LoadZone loads a time zone given in a TZ= line. Like Jenkins, it only accepts zone IDs, such as "Europe/London",
+// and "UTC", not abbreviations such as "EST".
+func LoadZone(zone string) (*time.Location, error) {
+	if zone != "UTC" && !strings.Contains(zone, "/") {
+		return nil, fmt.Errorf("invalid or unsupported time zone %q", zone)
+	}
+	l, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or unsupported time zone %q", zone)
+	}
+	return l, nil
+}
+
+func parseEntry(line string, seed string) (*Entry, error) {
+	spec := line
+	if strings.HasPrefix(line, "@") {
+		expanded, ok := aliases[line]
+		if !ok {
+			return nil, fmt.Errorf("unknown alias %s", line)
+		}
+		spec = expanded
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected 5 fields but found %d", len(parts))
+	}
+	e := &Entry{Spec: line}
+	targets := []*uint64{&e.minute, &e.hour, &e.dom, &e.month, &e.dow}
+	for i, part := range parts {
+		bits, err := parseField(part, i, seed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fields[i].name, err)
+		}
+		*targets[i] = bits
+	}
+	// Sunday is both 0 and 7.
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domRestricted = parts[2] != "*"
+	e.dowRestricted = parts[4] != "*"
+	return e, nil
+}
+
+// parseField parses a comma-separated field into a bit set of the values it matches
+func parseField(s string, index int, seed string) (uint64, error) {
+	f := fields[index]
+	var bits uint64
+	for _, term := range strings.Split(s, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(term, "/"); i >= 0 {
+			n, err := strconv.Atoi(term[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", term)
+			}
+			step, stepped = n, true
+			term = term[:i]
+		}
+		var lo, hi int
+		switch {
+		case term == "*":
+			lo, hi = f.min, f.max
+			if index == 4 {
+				hi = 6
+			}
+		case strings.HasPrefix(term, "H"):
+			r := hashRanges[index]
+			lo, hi = r.min, r.max
+			if rest := term[1:]; rest != "" {
+				var err error
+				if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+					return 0, fmt.Errorf("invalid term %q", term)
+				}
+				if lo, hi, err = parseRange(rest[1:len(rest)-1], f); err != nil {
+					return 0, err
+				}
+			}
+			h := hash(seed, index)
+			if !stepped {
+				lo = lo + h%(hi-lo+1)
+				hi = lo
+			} else {
+				// H/step starts at a hashed offset within the first step.
+				span := step
+				if span > hi-lo+1 {
+					span = hi - lo + 1
+				}
+				lo += h % span
+			}
+		default:
+			var err error
+			if lo, hi, err = parseRange(term, f); err != nil {
+				return 0, err
+			}
+			if stepped && lo == hi {
+				hi = f.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseRange(s string, f field) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	lo, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", s)
+	}
+	hi := lo
+	if len(parts) == 2 {
+		if hi, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid value %q", s)
+		}
+	}
+	if lo < f.min || hi > f.max || lo > hi {
+		return 0, 0, fmt.Errorf("%q is outside %d-%d", s, f.min, f.max)
+	}
+	return lo, hi, nil
+}
+
+func hash(seed string, index int) int {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	h.Write([]byte{byte(index)})
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// Matches returns whether the entry fires at the minute containing t, in the entry's zone or else t's zone
+func (e *Entry) Matches(t time.Time) bool {
+	if e.Location != nil {
+		t = t.In(e.Location)
+	}
+	return e.matchesClock(t)
+}
+
+// matchesClock checks t's wall clock fields, whatever its zone
+func (e *Entry) matchesClock(t time.Time) bool {
+	return e.month&(1<<uint(t.Month())) != 0 && e.dayMatches(t) && e.hour&(1<<uint(t.Hour())) != 0 &&
+		e.minute&(1<<uint(t.Minute())) != 0
+}
+
+// dayMatches checks the day of month and day of week, both of which must match, as in Jenkins
+func (e *Entry) dayMatches(t time.Time) bool {
+	return e.dom&(1<<uint(t.Day())) != 0 && e.dow&(1<<uint(t.Weekday())) != 0
+}
+
+// maxSearch bounds the search for the next fire time, for schedules such as February 30th which never fire
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns when the entry next fires after t, in the entry's zone or else t's zone, or the zero time if it never
+// does. Local times skipped by a daylight saving change never fire, and those repeated fire twice.
+func (e *Entry) Next(t time.Time) time.Time {
+	if e.Location != nil {
+		t = t.In(e.Location)
+	}
+	loc := t.Location()
+	limit := t.Add(maxSearch)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case e.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !e.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case e.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case e.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// Next returns when the schedule next fires after t, or the zero time if it never does
+func (s *Schedule) Next(t time.Time) time.Time {
+	var earliest time.Time
+	for _, e := range s.Entries {
+		if n := e.Next(t); !n.IsZero() && (earliest.IsZero() || n.Before(earliest)) {
+			earliest = n
+		}
+	}
+	return earliest
+}
+
+// Shift is a daylight saving change which affects an entry's fire times
+type Shift struct {
+	// At is when the change happens.
+	At time.Time
+	// Skipped is true when clocks go forward, so fire times in the skipped wall clock times don't happen, and false
+	// when they go back, so fire times in the repeated ones happen twice.
+	Skipped bool
+	// Times are the affected fire times on the wall clock, such as "02:30".
+	Times []string
+}
+
+// Shifts returns the daylight saving changes between from and to which skip or repeat the entry's fire times, in the
+// entry's zone or else from's zone.
+func (e *Entry) Shifts(from, to time.Time) []*Shift {
+	loc := from.Location()
+	if e.Location != nil {
+		loc = e.Location
+	}
+	var shifts []*Shift
+	for _, at := range transitions(from.In(loc), to) {
+		_, before := at.Add(-time.Second).Zone()
+		_, after := at.Zone()
+		shift := &Shift{At: at, Skipped: after > before}
+		// Walk the affected wall clock times, represented in UTC so adding minutes can't skip or repeat any.
+		var wall time.Time
+		var length time.Duration
+		if shift.Skipped {
+			length = time.Duration(after-before) * time.Second
+			wall = clock(at).Add(-length)
+		} else {
+			wall = clock(at)
+			length = time.Duration(before-after) * time.Second
+		}
+		for t := wall; t.Before(wall.Add(length)); t = t.Add(time.Minute) {
+			if e.matchesClock(t) {
+				shift.Times = append(shift.Times, t.Format("15:04"))
+			}
+		}
+		if len(shift.Times) > 0 {
+			shifts = append(shifts, shift)
+		}
+	}
+	return shifts
+}
+
+// clock returns t's wall clock time in UTC
+func clock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// transitions returns when t's zone changes its UTC offset before to
+func transitions(t time.Time, to time.Time) []time.Time {
+	var out []time.Time
+	t = t.Truncate(time.Second)
+	_, offset := t.Zone()
+	for t.Before(to) {
+		next := t.Add(time.Hour)
+		if _, o := next.Zone(); o != offset {
+			// Narrow down to the second the offset changes.
+			lo, hi := t, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add((hi.Sub(lo) / 2).Truncate(time.Second))
+				if _, o := mid.Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			out = append(out, hi)
+			offset = o
+		}
+		t = next
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse("# nightly\nTZ=Europe/London\n\n0 2 * * 1-5\nH/15 * * * *", "job")
+	require.NoError(t, err)
+	require.Len(t, s.Entries, 2)
+	assert.Equal(t, "0 2 * * 1-5", s.Entries[0].Spec)
+	assert.Equal(t, "Europe/London", s.Entries[0].Location.String())
+	assert.Equal(t, "Europe/London", s.Entries[1].Location.String())
+
+	s, err = Parse("@daily", "job")
+	require.NoError(t, err)
+	assert.Nil(t, s.Entries[0].Location)
+
+	for spec, msg := range map[string]string{
+		"TZ=EST\n0 2 * * *":          `line 1: invalid or unsupported time zone "EST"`,
+		"TZ=Mars/Olympus\n* * * * *": `line 1: invalid or unsupported time zone "Mars/Olympus"`,
+		"0 2 * *":                    `line 1: expected 5 fields but found 4`,
+		"\n60 * * * *":               `line 2: minute: "60" is outside 0-59`,
+		"* * * * 1/0":                `line 1: day of week: invalid step in "1/0"`,
+		"@fortnightly":               `line 1: unknown alias @fortnightly`,
+	} {
+		_, err := Parse(spec, "job")
+		assert.EqualError(t, err, msg, spec)
+	}
+}
+
+func TestHash(t *testing.T) {
+	a, err := Parse("H H(0-2) * * *", "a")
+	require.NoError(t, err)
+	again, err := Parse("H H(0-2) * * *", "a")
+	require.NoError(t, err)
+	assert.Equal(t, a, again)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := a.Next(from)
+	assert.True(t, next.Hour() <= 2)
+	assert.Equal(t, next.Add(24*time.Hour), a.Next(next))
+}
+
+func TestNext(t *testing.T) {
+	s, err := Parse("TZ=America/New_York\n30 2 * * *", "job")
+	require.NoError(t, err)
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	next := s.Next(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 1, 16, 2, 30, 0, 0, ny), next)
+	assert.Equal(t, 7, next.UTC().Hour())
+	next = s.Next(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, 6, next.UTC().Hour())
+
+	// 02:30 doesn't happen on the day clocks go forward.
+	next = s.Next(time.Date(2024, 3, 9, 12, 0, 0, 0, ny))
+	assert.Equal(t, time.Date(2024, 3, 11, 2, 30, 0, 0, ny), next)
+
+	s, err = Parse("0 0 30 2 *", "job")
+	require.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+
+	// Sunday is 0 or 7, and must also match the day of the month.
+	s, err = Parse("0 9 1-7 * 7", "job")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC), s.Next(time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestShifts(t *testing.T) {
+	s, err := Parse("TZ=America/New_York\n30 1,2 * * *", "job")
+	require.NoError(t, err)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	shifts := s.Entries[0].Shifts(from, from.AddDate(1, 0, 0))
+	require.Len(t, shifts, 2)
+	assert.True(t, shifts[0].Skipped)
+	assert.Equal(t, []string{"02:30"}, shifts[0].Times)
+	assert.Equal(t, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), shifts[0].At.UTC())
+	assert.False(t, shifts[1].Skipped)
+	assert.Equal(t, []string{"01:30"}, shifts[1].Times)
+
+	s, err = Parse("TZ=UTC\n30 2 * * *", "job")
+	require.NoError(t, err)
+	assert.Empty(t, s.Entries[0].Shifts(from, from.AddDate(1, 0, 0)))
+}
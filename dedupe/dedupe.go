@@ -0,0 +1,288 @@
+// Package dedupe finds pipelines which are copies, or near copies, of each other across many repositories.
+package dedupe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// DefaultThreshold is the similarity pipelines must have to be clustered together when FindClusters is given no
+// threshold
+const DefaultThreshold = 0.8
+
+// Cluster is a group of identical or near-identical pipelines
+type Cluster struct {
+	// Pipelines are the names of the pipelines in the cluster, sorted.
+	Pipelines []string `json:"pipelines"`
+	// Identical is true if all the pipelines have the same fingerprint.
+	Identical bool `json:"identical"`
+	// Similarity is the lowest similarity of a pipeline in the cluster to the first.
+	Similarity float64 `json:"similarity"`
+	// Differences are the values which aren't the same in every pipeline, in path order.
+	Differences []*Difference `json:"differences,omitempty"`
+}
+
+// Difference is a value which isn't the same in every pipeline of a cluster
+type Difference struct {
+	// Path locates the value, with stages, steps, and other named items given by name rather than index, such as
+	// "pipeline/stages/Build/branches/default/steps/sh/arguments/script/value/value". The second and later items with the
+	// same name have "#2", "#3", and so on appended.
+	Path string `json:"path"`
+	// Values are the value each pipeline has. Pipelines without the value are left out.
+	Values map[string]interface{} `json:"values"`
+}
+
+// unordered are the lists whose order doesn't matter to Jenkins, keyed by the key of their items' identifiers
+var unordered = map[string]string{
+	"environment": "key",
+	"tools":       "key",
+	"options":     "name",
+	"triggers":    "name",
+}
+
+// Fingerprint returns a hash of the pipeline's canonical form, which is the same for pipelines differing only in
+// annotations, the order of their environment variables, tools, options, and triggers, or the indentation of their
+// scripts.
+func Fingerprint(root *model.Root) (string, error) {
+	doc, err := canonical(root)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Similarity returns how alike two pipelines' canonical forms are, from 0 for nothing in common to 1 for identical:
+// the number of values they share, by path, over the number of values in either.
+func Similarity(a, b *model.Root) (float64, error) {
+	fa, err := features(a)
+	if err != nil {
+		return 0, err
+	}
+	fb, err := features(b)
+	if err != nil {
+		return 0, err
+	}
+	return similarity(fa, fb), nil
+}
+
+// FindClusters groups identical and near-identical pipelines, keyed by name, and reports the differences within each
+// group. Taking pipelines in name order, each joins the cluster whose first pipeline it's most similar to, if at
+// least threshold similar, or starts a new one. Pipelines which aren't similar to any other are left out. A threshold
+// of zero uses DefaultThreshold.
+func FindClusters(roots map[string]*model.Root, threshold float64) ([]*Cluster, error) {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	names := make([]string, 0, len(roots))
+	for name := range roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type member struct {
+		name        string
+		fingerprint string
+		features    map[string]interface{}
+	}
+	type group struct {
+		cluster *Cluster
+		members []*member
+	}
+	var groups []*group
+	for _, name := range names {
+		if roots[name] == nil || roots[name].Pipeline == nil {
+			return nil, fmt.Errorf("%s: a root with a pipeline is required", name)
+		}
+		fp, err := Fingerprint(roots[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		f, err := features(roots[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		m := &member{name: name, fingerprint: fp, features: f}
+
+		var best *group
+		bestScore := 0.0
+		for _, g := range groups {
+			score := 1.0
+			if g.members[0].fingerprint != fp {
+				score = similarity(g.members[0].features, f)
+			}
+			if score >= threshold && score > bestScore {
+				best, bestScore = g, score
+			}
+		}
+		if best == nil {
+			groups = append(groups, &group{cluster: &Cluster{Identical: true, Similarity: 1}, members: []*member{m}})
+			continue
+		}
+		best.members = append(best.members, m)
+		best.cluster.Identical = best.cluster.Identical && fp == best.members[0].fingerprint
+		if bestScore < best.cluster.Similarity {
+			best.cluster.Similarity = bestScore
+		}
+	}
+
+	out := []*Cluster{}
+	for _, g := range groups {
+		if len(g.members) < 2 {
+			continue
+		}
+		paths := map[string]bool{}
+		for _, m := range g.members {
+			g.cluster.Pipelines = append(g.cluster.Pipelines, m.name)
+			for p := range m.features {
+				paths[p] = true
+			}
+		}
+		sorted := make([]string, 0, len(paths))
+		for p := range paths {
+			sorted = append(sorted, p)
+		}
+		sort.Strings(sorted)
+		for _, p := range sorted {
+			d := &Difference{Path: p, Values: map[string]interface{}{}}
+			same := true
+			for _, m := range g.members {
+				v, ok := m.features[p]
+				if !ok || !reflect.DeepEqual(v, g.members[0].features[p]) {
+					same = false
+				}
+				if ok {
+					d.Values[m.name] = v
+				}
+			}
+			if !same {
+				g.cluster.Differences = append(g.cluster.Differences, d)
+			}
+		}
+		out = append(out, g.cluster)
+	}
+	return out, nil
+}
+
+// canonical returns the pipeline's JSON, decoded, with unordered lists sorted and the lines of strings trimmed
+func canonical(root *model.Root) (interface{}, error) {
+	if root == nil {
+		return nil, errors.New("a root is required")
+	}
+	b, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return canonicalize(doc, ""), nil
+}
+
+func canonicalize(v interface{}, key string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = canonicalize(e, k)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = canonicalize(e, "")
+		}
+		if id, ok := unordered[key]; ok {
+			sort.SliceStable(t, func(i, j int) bool {
+				return identifier(t[i], id) < identifier(t[j], id)
+			})
+		}
+	case string:
+		lines := strings.Split(t, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimSpace(l)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return v
+}
+
+// features flattens the pipeline's canonical form into its values, keyed by path
+func features(root *model.Root) (map[string]interface{}, error) {
+	doc, err := canonical(root)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	flatten(doc, "", out)
+	return out, nil
+}
+
+func flatten(v interface{}, path string, out map[string]interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			flatten(e, join(path, k), out)
+		}
+	case []interface{}:
+		seen := map[string]int{}
+		for i, e := range t {
+			segment := identifier(e, "name")
+			if segment == "" {
+				segment = identifier(e, "key")
+			}
+			if segment == "" {
+				segment = fmt.Sprint(i)
+			}
+			seen[segment]++
+			if n := seen[segment]; n > 1 {
+				segment = fmt.Sprintf("%s#%d", segment, n)
+			}
+			flatten(e, join(path, segment), out)
+		}
+	default:
+		out[path] = v
+	}
+}
+
+func join(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + "/" + segment
+}
+
+// identifier returns the string value of key in v, if v is an object which has one
+func identifier(v interface{}, key string) string {
+	if m, ok := v.(map[string]interface{}); ok {
+		if s, ok := m[key].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// similarity is the Jaccard index of two pipelines' features, as path and value pairs
+func similarity(a, b map[string]interface{}) float64 {
+	shared := 0
+	for p, v := range a {
+		if w, ok := b[p]; ok && reflect.DeepEqual(v, w) {
+			shared++
+		}
+	}
+	total := len(a) + len(b) - shared
+	if total == 0 {
+		return 1
+	}
+	return float64(shared) / float64(total)
+}
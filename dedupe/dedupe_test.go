@@ -0,0 +1,120 @@
+package dedupe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const service = `pipeline {
+    agent { label 'linux' }
+    environment {
+        IMAGE = 'maven:3.9'
+        REGISTRY = 'registry.example.com'
+    }
+    options { timeout(time: 1, unit: 'HOURS') }
+    stages {
+        stage('Build') {
+            steps {
+                sh 'mvn -B package'
+                archiveArtifacts 'target/*.jar'
+            }
+        }
+        stage('Test') {
+            steps {
+                sh 'mvn -B verify'
+            }
+        }
+    }
+}`
+
+func parse(t *testing.T, replacements ...string) *model.Root {
+	root, err := parser.Parse(strings.NewReader(strings.NewReplacer(replacements...).Replace(service)))
+	require.NoError(t, err)
+	return root
+}
+
+func TestFingerprint(t *testing.T) {
+	base, err := Fingerprint(parse(t))
+	require.NoError(t, err)
+	reordered := parse(t, "IMAGE = 'maven:3.9'\n        REGISTRY = 'registry.example.com'",
+		"REGISTRY = 'registry.example.com'\n        IMAGE = 'maven:3.9'")
+	reordered.Pipeline.Annotations.Set(model.AnnotationTicket, "OPS-1")
+	fp, err := Fingerprint(reordered)
+	require.NoError(t, err)
+	assert.Equal(t, base, fp)
+
+	fp, err = Fingerprint(parse(t, "maven:3.9", "maven:3.8"))
+	require.NoError(t, err)
+	assert.NotEqual(t, base, fp)
+}
+
+func TestSimilarity(t *testing.T) {
+	s, err := Similarity(parse(t), parse(t))
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, s)
+
+	s, err = Similarity(parse(t), parse(t, "maven:3.9", "maven:3.8"))
+	require.NoError(t, err)
+	assert.True(t, s > 0.8 && s < 1, "similarity %f", s)
+
+	s, err = Similarity(parse(t), parse(t, "stage('Test')", "stage('Verify')"))
+	require.NoError(t, err)
+	assert.True(t, s < 0.8, "similarity %f", s)
+}
+
+func TestFindClusters(t *testing.T) {
+	other, err := parser.Parse(strings.NewReader(`pipeline {
+    agent any
+    stages {
+        stage('Deploy') {
+            steps {
+                echo 'deploying'
+            }
+        }
+    }
+}`))
+	require.NoError(t, err)
+	clusters, err := FindClusters(map[string]*model.Root{
+		"billing":  parse(t),
+		"payments": parse(t, "        ", "    "),
+		"ledger":   parse(t, "maven:3.9", "maven:3.8"),
+		"invoices": parse(t, "sh 'mvn -B verify'", "sh 'mvn -B verify'\n                sh 'mvn -B site'"),
+		"docs":     other,
+	}, 0)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+
+	c := clusters[0]
+	assert.Equal(t, []string{"billing", "invoices", "ledger", "payments"}, c.Pipelines)
+	assert.False(t, c.Identical)
+	assert.True(t, c.Similarity >= DefaultThreshold && c.Similarity < 1)
+	paths := []string{}
+	for _, d := range c.Differences {
+		paths = append(paths, d.Path)
+	}
+	assert.Equal(t, []string{
+		"pipeline/environment/IMAGE/value/value",
+		"pipeline/stages/Test/branches/default/steps/sh#2/arguments/script/key",
+		"pipeline/stages/Test/branches/default/steps/sh#2/arguments/script/value/isLiteral",
+		"pipeline/stages/Test/branches/default/steps/sh#2/arguments/script/value/value",
+		"pipeline/stages/Test/branches/default/steps/sh#2/name",
+	}, paths)
+	assert.Equal(t, map[string]interface{}{
+		"billing": "maven:3.9", "invoices": "maven:3.9", "ledger": "maven:3.8", "payments": "maven:3.9",
+	}, c.Differences[0].Values)
+	assert.Equal(t, map[string]interface{}{"invoices": "mvn -B site"}, c.Differences[3].Values)
+
+	clusters, err = FindClusters(map[string]*model.Root{"billing": parse(t), "payments": parse(t, "        ", "    ")}, 1)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.True(t, clusters[0].Identical)
+	assert.Empty(t, clusters[0].Differences)
+
+	_, err = FindClusters(map[string]*model.Root{"empty": {}}, 0)
+	assert.EqualError(t, err, "empty: a root with a pipeline is required")
+}
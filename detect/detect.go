@@ -0,0 +1,122 @@
+// Package detect classifies files by the kind of pipeline they contain, so that bulk tooling can route each file to
+// the right handler rather than failing on files it can't parse.
+package detect
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+)
+
+// Kind is the kind of pipeline a file contains
+type Kind string
+
+const (
+	// Declarative is a Groovy Declarative pipeline, with a pipeline block.
+	Declarative Kind = "declarative"
+	// Scripted is a Groovy Scripted pipeline, built from node and stage blocks.
+	Scripted Kind = "scripted"
+	// KyotoJSON is the JSON representation of a Declarative pipeline used by this library.
+	KyotoJSON Kind = "kyoto-json"
+	// NotPipeline is a file which doesn't appear to be a pipeline.
+	NotPipeline Kind = "none"
+)
+
+// Classification is the result of classifying a file
+type Classification struct {
+	Kind Kind `json:"kind"`
+	// Confidence is between 0 and 1.
+	Confidence float64 `json:"confidence"`
+	// Reasons are the evidence the classification is based on.
+	Reasons []string `json:"reasons,omitempty"`
+	// Pipelines are the pipelines in a Declarative or Kyoto JSON file, with their models, if they all parse.
+	Pipelines []*model.Extracted `json:"-"`
+}
+
+var (
+	comments      = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	nodeBlock     = regexp.MustCompile(`(?m)^\s*node\s*(\([^)]*\))?\s*\{`)
+	stageCall     = regexp.MustCompile(`\bstage\s*\(\s*['"]`)
+	stagesBlock   = regexp.MustCompile(`\bstages\s*\{`)
+	agentDecl     = regexp.MustCompile(`\bagent\s*(any|none|\{)`)
+	pipelineSteps = regexp.MustCompile(`\b(sh|bat|powershell|checkout|git|echo|timestamps|withCredentials|parallel)\b\s*[('"\[{]`)
+)
+
+// Classify determines whether src is a Declarative pipeline, a Scripted pipeline, Kyoto JSON, or not a pipeline at
+// all.
+func Classify(src []byte) *Classification {
+	trimmed := bytes.TrimSpace(src)
+	if len(trimmed) == 0 {
+		return &Classification{Kind: NotPipeline, Confidence: 1, Reasons: []string{"file is empty"}}
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return classifyJSON(trimmed)
+	}
+
+	c := &Classification{}
+	if docs, err := model.ExtractAll(src); err == nil {
+		c.Kind, c.Confidence = Declarative, 0.8
+		c.Reasons = append(c.Reasons, "contains a pipeline block")
+		body := comments.ReplaceAll(docs[0].Source, nil)
+		if stagesBlock.Match(body) {
+			c.Confidence += 0.1
+			c.Reasons = append(c.Reasons, "pipeline block has a stages block")
+		}
+		if agentDecl.Match(body) {
+			c.Confidence += 0.1
+			c.Reasons = append(c.Reasons, "pipeline block declares an agent")
+		}
+		if c.Pipelines, err = parser.ExtractAll(src); err != nil {
+			c.Reasons = append(c.Reasons, "pipeline block does not parse: "+err.Error())
+		}
+		return c
+	}
+
+	code := comments.ReplaceAll(src, nil)
+	hasNode := nodeBlock.Match(code)
+	hasStage := stageCall.Match(code)
+	hasSteps := pipelineSteps.Match(code)
+	switch {
+	case hasNode:
+		c.Kind, c.Confidence = Scripted, 0.8
+		c.Reasons = append(c.Reasons, "contains a node block")
+		if hasStage {
+			c.Confidence += 0.15
+			c.Reasons = append(c.Reasons, "contains stage blocks")
+		}
+	case hasStage:
+		c.Kind, c.Confidence = Scripted, 0.6
+		c.Reasons = append(c.Reasons, "contains stage blocks but no node block")
+	case hasSteps:
+		c.Kind, c.Confidence = Scripted, 0.3
+		c.Reasons = append(c.Reasons, "contains pipeline steps but no node or stage blocks")
+	default:
+		c.Kind, c.Confidence = NotPipeline, 0.8
+		c.Reasons = append(c.Reasons, "no pipeline, node, or stage blocks found")
+	}
+	return c
+}
+
+func classifyJSON(src []byte) *Classification {
+	docs, err := model.ExtractAll(src)
+	if err == nil {
+		reason := "decodes as a pipeline"
+		if len(docs) > 1 {
+			reason = "decodes as multiple pipelines"
+		}
+		for _, d := range docs {
+			if d.Root.Pipeline == nil {
+				return &Classification{Kind: NotPipeline, Confidence: 0.9, Reasons: []string{"JSON has no pipeline"}}
+			}
+		}
+		return &Classification{Kind: KyotoJSON, Confidence: 1, Reasons: []string{reason}, Pipelines: docs}
+	}
+	if bytes.Contains(src, []byte(`"pipeline"`)) && bytes.Contains(src, []byte(`"stages"`)) {
+		return &Classification{Kind: KyotoJSON, Confidence: 0.6,
+			Reasons: []string{"JSON has pipeline and stages keys but does not decode: " + err.Error()}}
+	}
+	return &Classification{Kind: NotPipeline, Confidence: 0.9, Reasons: []string{"JSON is not a pipeline"}}
+}
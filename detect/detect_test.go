@@ -0,0 +1,53 @@
+package detect
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	kyoto, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", "simpleParameters.json"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		src        string
+		kind       Kind
+		confidence float64
+	}{
+		{name: "kyoto", src: string(kyoto), kind: KyotoJSON, confidence: 1},
+		{name: "kyoto array", src: "[" + string(kyoto) + "," + string(kyoto) + "]", kind: KyotoJSON, confidence: 1},
+		{name: "invalid kyoto", src: `{"pipeline": {"stages": [], "bogus": 1}}`, kind: KyotoJSON, confidence: 0.6},
+		{name: "other json", src: `{"name": "package", "version": "1.0.0"}`, kind: NotPipeline, confidence: 0.9},
+		{name: "declarative", src: "pipeline {\n  agent any\n  stages {\n    stage('Build') { steps { sh 'make' } }\n  }\n}\n",
+			kind: Declarative, confidence: 1},
+		{name: "scripted", src: "node('linux') {\n  stage('Build') {\n    sh 'make'\n  }\n}\n", kind: Scripted, confidence: 0.95},
+		{name: "scripted in comment", src: "// node { stage('x') {} }\nprintln 'hello'\n", kind: NotPipeline, confidence: 0.8},
+		{name: "library step", src: "def call() {\n  sh 'make'\n}\n", kind: Scripted, confidence: 0.3},
+		{name: "empty", src: "\n", kind: NotPipeline, confidence: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Classify([]byte(tc.src))
+			assert.Equal(t, tc.kind, c.Kind, c.Reasons)
+			assert.InDelta(t, tc.confidence, c.Confidence, 0.001)
+			assert.NotEmpty(t, c.Reasons)
+		})
+	}
+}
+
+func TestClassifyPipelines(t *testing.T) {
+	c := Classify([]byte("def call() {\n  pipeline {\n    agent any\n    stages { stage('Build') { steps { sh 'make' } } }\n  }\n}\n"))
+	assert.Equal(t, Declarative, c.Kind)
+	require.Len(t, c.Pipelines, 1)
+	assert.Equal(t, "Build", c.Pipelines[0].Root.Pipeline.Stages[0].Name)
+
+	c = Classify([]byte("pipeline {\n  agent some\n}\n"))
+	assert.Equal(t, Declarative, c.Kind)
+	assert.Empty(t, c.Pipelines)
+	assert.Contains(t, c.Reasons, "pipeline block does not parse: line 2, column 3: agent must be any, none, or a block")
+}
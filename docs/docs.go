@@ -0,0 +1,279 @@
+// Package docs holds short documentation for the directives, steps, options, triggers, parameters, when conditions,
+// post conditions, and agent types of Declarative Pipelines, for hover text in editors and terminal interfaces.
+// Shared libraries can document their own steps, or replace the built-in documentation, with overrides.
+package docs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Kinds of symbol, in the order unqualified symbols are looked up. They match the completion kinds of the catalog
+// package.
+var Kinds = []string{"section", "step", "option", "trigger", "parameter", "whenCondition", "postCondition", "agent"}
+
+// Doc is the documentation of a symbol
+type Doc struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Summary is a sentence or two describing the symbol.
+	Summary string `json:"summary"`
+	// Override is true for documentation which came from an override rather than being built in.
+	Override bool `json:"override,omitempty"`
+}
+
+// Set is a set of documentation, safe for concurrent use
+type Set struct {
+	lock sync.RWMutex
+	docs map[string]*Doc
+}
+
+// NewSet creates a set holding the built-in documentation.
+func NewSet() *Set {
+	s := &Set{docs: map[string]*Doc{}}
+	for key, summary := range builtin {
+		kind, name := split(key)
+		s.docs[key] = &Doc{Kind: kind, Name: name, Summary: summary}
+	}
+	return s
+}
+
+var shared = NewSet()
+
+// For looks up a symbol in the shared set. See Set.For.
+func For(symbol string) *Doc {
+	return shared.For(symbol)
+}
+
+// Override adds or replaces documentation in the shared set. See Set.Override.
+func Override(symbol, summary string) error {
+	return shared.Override(symbol, summary)
+}
+
+// LoadOverrides adds or replaces documentation in the shared set from a file. See Set.LoadOverrides.
+func LoadOverrides(b []byte) error {
+	return shared.LoadOverrides(b)
+}
+
+// For returns the documentation of a symbol, or nil if there's none. Symbols are names, which are looked up in the
+// order of Kinds, such as "timeout" for the timeout step, or are qualified by their kind, such as "option:timeout".
+func (strct *Set) For(symbol string) *Doc {
+	strct.lock.RLock()
+	defer strct.lock.RUnlock()
+	if strings.Contains(symbol, ":") {
+		return strct.docs[symbol]
+	}
+	for _, kind := range Kinds {
+		if d, ok := strct.docs[kind+":"+symbol]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// Override adds or replaces the documentation of a symbol, such as a shared library step. Unqualified symbols are
+// taken to be steps. It returns an error if the kind is unknown.
+func (strct *Set) Override(symbol, summary string) error {
+	if !strings.Contains(symbol, ":") {
+		symbol = "step:" + symbol
+	}
+	kind, name := split(symbol)
+	if !known(kind) {
+		return fmt.Errorf("unknown kind %q, must be one of: %s", kind, strings.Join(Kinds, ", "))
+	}
+	if name == "" {
+		return fmt.Errorf("%s: a name is required", symbol)
+	}
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	strct.docs[symbol] = &Doc{Kind: kind, Name: name, Summary: summary, Override: true}
+	return nil
+}
+
+// LoadOverrides applies the overrides in a YAML or JSON file mapping symbols to summaries, such as:
+//
+//	deployApp: Deploys the application with Helm.
+//	option:timeout: Fails the build after a time limit, which is 2 hours at most here.
+//
+// No overrides are applied if any are invalid.
+func (strct *Set) LoadOverrides(b []byte) error {
+	overrides := map[string]string{}
+	if err := yaml.UnmarshalStrict(b, &overrides); err != nil {
+		return err
+	}
+	symbols := make([]string, 0, len(overrides))
+	for symbol := range overrides {
+		if strings.Contains(symbol, ":") {
+			if kind, _ := split(symbol); !known(kind) {
+				return fmt.Errorf("%s: unknown kind %q", symbol, kind)
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		if err := strct.Override(symbol, overrides[symbol]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(symbol string) (string, string) {
+	i := strings.Index(symbol, ":")
+	return symbol[:i], symbol[i+1:]
+}
+
+func known(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+var builtin = map[string]string{
+	"section:agent":              "Where the pipeline or stage runs: any agent, an agent with a label, or a container.",
+	"section:environment":        "Environment variables for the steps of the pipeline or stage, including credentials.",
+	"section:libraries":          "Shared libraries to load, as name@version identifiers.",
+	"section:options":            "Options configuring the pipeline or stage, such as timeouts and retries.",
+	"section:parameters":         "Parameters users supply when starting a build, available as params.NAME.",
+	"section:post":               "Steps run when the pipeline or stage finishes, depending on its result.",
+	"section:stages":             "The stages of the pipeline, or the stages nested in a stage, which run in order.",
+	"section:tools":              "Tools installed by Jenkins and added to the PATH, such as maven or jdk.",
+	"section:triggers":           "When the pipeline runs automatically, such as on a schedule or after another job.",
+	"section:steps":              "The steps a stage runs, in order.",
+	"section:parallel":           "Stages which run at the same time.",
+	"section:matrix":             "Stages run once for each combination of the values of its axes.",
+	"section:axes":               "The axes of a matrix, each with a name and values.",
+	"section:axis":               "An axis of a matrix.",
+	"section:name":               "The name of a matrix axis.",
+	"section:values":             "The values of a matrix axis, or those an exclude leaves out.",
+	"section:message":            "The question an input asks.",
+	"section:id":                 "The ID of an input, used to answer it through the REST API.",
+	"section:ok":                 "The label of the button approving an input.",
+	"section:submitter":          "The users and groups allowed to answer an input, separated by commas.",
+	"section:submitterParameter": "The variable set to the ID of the user who answered an input.",
+	"section:excludes":           "Combinations of axis values a matrix leaves out.",
+	"section:when":               "Conditions which must be met for the stage to run.",
+	"section:input":              "Waits for a user to approve the stage, optionally supplying parameters, before it runs.",
+	"section:failFast":           "Aborts the other parallel stages as soon as one fails.",
+	"section:beforeAgent":        "Evaluates the when conditions before allocating the stage's agent.",
+	"section:beforeInput":        "Evaluates the when conditions before the stage's input.",
+	"section:beforeOptions": "Evaluates the when conditions before applying the stage's options, such as its " +
+		"timeout.",
+
+	"step:archiveArtifacts": "Archives files from the workspace with the build.",
+	"step:bat":              "Runs a Windows batch script.",
+	"step:build":            "Triggers a build of another job, by default waiting for it to finish.",
+	"step:catchError": "Catches errors thrown by its steps, sets the build and stage results, and carries on with " +
+		"the rest of the stage.",
+	"step:checkout":    "Checks out source code, usually with checkout scm for the pipeline's own repository.",
+	"step:cleanWs":     "Deletes the workspace, or the files in it matching patterns.",
+	"step:deleteDir":   "Deletes the current directory and its contents.",
+	"step:dir":         "Runs its steps in another directory.",
+	"step:echo":        "Prints a message to the build log.",
+	"step:emailext":    "Sends an email with the Email Extension plugin.",
+	"step:error":       "Fails the build with a message.",
+	"step:fileExists":  "Returns whether a file exists in the workspace.",
+	"step:fingerprint": "Records fingerprints of files, to track them across jobs.",
+	"step:git":         "Checks out a Git repository.",
+	"step:input":       "Pauses the build until a user answers a question.",
+	"step:isUnix":      "Returns whether the agent runs a Unix-like operating system.",
+	"step:junit":       "Records JUnit test results, marking the build unstable if tests failed.",
+	"step:library":     "Loads a shared library dynamically.",
+	"step:load":        "Runs a Groovy file from the workspace and returns its result.",
+	"step:lock":        "Locks a resource while its steps run, waiting if another build holds it.",
+	"step:mail":        "Sends an email.",
+	"step:milestone":   "Aborts older builds which haven't passed this point once a newer build passes it.",
+	"step:node":        "Allocates an agent and workspace for its steps.",
+	"step:powershell":  "Runs a Windows PowerShell script.",
+	"step:pwsh":        "Runs a PowerShell Core script.",
+	"step:readFile":    "Returns the contents of a file in the workspace.",
+	"step:retry":       "Runs its steps again if they fail, up to a number of attempts.",
+	"step:script":      "Runs a block of Scripted Pipeline.",
+	"step:sh":          "Runs a shell script.",
+	"step:slackSend":   "Sends a message to Slack.",
+	"step:sleep":       "Pauses the build for a time.",
+	"step:stash":       "Saves files for use later in the build, on another agent, with unstash.",
+	"step:timeout":     "Aborts its steps if they take longer than a time limit.",
+	"step:tool":        "Installs a tool and returns its home directory.",
+	"step:unstable":    "Marks the build and stage unstable with a message.",
+	"step:unstash":     "Restores files saved by stash.",
+	"step:waitUntil":   "Runs its steps repeatedly until they return true.",
+	"step:warnError": "Catches errors thrown by its steps, marks the build and stage unstable, and carries on with " +
+		"the rest of the stage.",
+	"step:withCredentials": "Binds credentials to environment variables while its steps run.",
+	"step:withEnv":         "Sets environment variables while its steps run.",
+	"step:writeFile":       "Writes a file to the workspace.",
+
+	"option:buildDiscarder":          "Keeps the logs and artifacts of a limited number of builds.",
+	"option:checkoutToSubdirectory":  "Checks the source out into a subdirectory of the workspace.",
+	"option:disableConcurrentBuilds": "Stops builds of the pipeline running at the same time.",
+	"option:disableResume":           "Stops the pipeline resuming after the controller restarts.",
+	"option:durabilityHint":          "Trades the pipeline's ability to survive restarts for performance.",
+	"option:lock":                    "Locks a resource for the whole pipeline or stage.",
+	"option:newContainerPerStage":    "Runs each stage in a new container of the pipeline's docker agent.",
+	"option:overrideIndexTriggers":   "Overrides whether branch indexing triggers builds.",
+	"option:parallelsAlwaysFailFast": "Sets failFast on every parallel stage in the pipeline.",
+	"option:preserveStashes":         "Keeps the stashes of completed builds, so stages can be restarted.",
+	"option:quietPeriod":             "Waits a number of seconds before starting a build.",
+	"option:retry":                   "Retries the whole pipeline or stage if it fails.",
+	"option:skipDefaultCheckout":     "Skips checking out the source automatically.",
+	"option:skipStagesAfterUnstable": "Skips the remaining stages once the build is unstable.",
+	"option:throttle":                "Limits concurrent builds with throttle categories.",
+	"option:timeout":                 "Aborts the pipeline or stage if it takes longer than a time limit.",
+	"option:timestamps":              "Prefixes each line of the build log with the time.",
+
+	"trigger:cron":       "Runs the pipeline on a schedule, in cron syntax with H for hashed values.",
+	"trigger:githubPush": "Runs the pipeline when GitHub reports a push.",
+	"trigger:pollSCM":    "Polls the repository on a schedule and runs the pipeline if it changed.",
+	"trigger:upstream":   "Runs the pipeline when other jobs finish with at least a given result.",
+
+	"parameter:booleanParam": "A checkbox, available as a boolean.",
+	"parameter:choice":       "A choice from a list, the first of which is the default.",
+	"parameter:credentials":  "A credential ID chosen from those available to the job.",
+	"parameter:file":         "A file uploaded when the build starts.",
+	"parameter:password":     "A string which is masked in the build log.",
+	"parameter:string":       "A single line of text.",
+	"parameter:text":         "Multiple lines of text.",
+
+	"whenCondition:allOf":         "Met if all of its conditions are met.",
+	"whenCondition:anyOf":         "Met if any of its conditions is met.",
+	"whenCondition:branch":        "Met if the branch being built matches a pattern.",
+	"whenCondition:buildingTag":   "Met if a tag is being built.",
+	"whenCondition:changeRequest": "Met if a change request, such as a pull request, is being built.",
+	"whenCondition:changelog":     "Met if a commit message in the build's changes matches a regular expression.",
+	"whenCondition:changeset":     "Met if a file changed in the build's changes matches a pattern.",
+	"whenCondition:environment":   "Met if an environment variable has a value.",
+	"whenCondition:equals":        "Met if two values are equal.",
+	"whenCondition:expression":    "Met if a Groovy expression is true.",
+	"whenCondition:not":           "Met if its condition isn't met.",
+	"whenCondition:tag":           "Met if the tag being built matches a pattern.",
+	"whenCondition:triggeredBy":   "Met if the build was started by a cause, such as TimerTrigger or UserIdCause.",
+
+	"postCondition:always":       "Runs whatever the result.",
+	"postCondition:changed":      "Runs if the result differs from the previous build's.",
+	"postCondition:fixed":        "Runs if the build succeeded and the previous build failed or was unstable.",
+	"postCondition:regression":   "Runs if the result is worse than the previous build's.",
+	"postCondition:aborted":      "Runs if the build was aborted.",
+	"postCondition:success":      "Runs if the build succeeded.",
+	"postCondition:unsuccessful": "Runs if the build didn't succeed.",
+	"postCondition:unstable":     "Runs if the build is unstable, such as from failed tests.",
+	"postCondition:failure":      "Runs if the build failed.",
+	"postCondition:notBuilt":     "Runs if the build wasn't built, such as when a stage was skipped.",
+	"postCondition:cleanup":      "Runs after every other post condition, whatever the result.",
+
+	"agent:any":        "Runs on any available agent.",
+	"agent:docker":     "Runs in a container from an image, on an agent with Docker.",
+	"agent:dockerfile": "Runs in a container built from a Dockerfile in the repository.",
+	"agent:kubernetes": "Runs in a pod on Kubernetes, described by a pod template.",
+	"agent:label":      "Runs on an agent with a label, or matching a label expression.",
+	"agent:node":       "Runs on an agent with a label, optionally in a custom workspace.",
+	"agent:none":       "Allocates no agent for the pipeline, so each stage needs its own.",
+}
@@ -0,0 +1,51 @@
+package docs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFor(t *testing.T) {
+	assert.Equal(t, &Doc{Kind: "step", Name: "timeout", Summary: "Aborts its steps if they take longer than a time limit."},
+		For("timeout"))
+	assert.Equal(t, "option", For("option:timeout").Kind)
+	assert.Equal(t, "section", For("agent").Kind)
+	assert.Equal(t, "agent", For("agent:docker").Kind)
+	assert.Nil(t, For("deployApp"))
+	assert.Nil(t, For("step:nonexistent"))
+}
+
+func TestOverride(t *testing.T) {
+	s := NewSet()
+	require.NoError(t, s.Override("deployApp", "Deploys the application."))
+	assert.Equal(t, &Doc{Kind: "step", Name: "deployApp", Summary: "Deploys the application.", Override: true},
+		s.For("deployApp"))
+	assert.Nil(t, For("deployApp"))
+
+	assert.EqualError(t, s.Override("widget:x", ""), `unknown kind "widget", must be one of: section, step, option, `+
+		`trigger, parameter, whenCondition, postCondition, agent`)
+	assert.EqualError(t, s.Override("step:", ""), "step:: a name is required")
+}
+
+func TestLoadOverrides(t *testing.T) {
+	s := NewSet()
+	require.NoError(t, s.LoadOverrides([]byte("notify: Posts the build result to chat.\n"+
+		"option:timeout: Limited to 2 hours here.\n")))
+	assert.Equal(t, "Posts the build result to chat.", s.For("step:notify").Summary)
+	assert.Equal(t, "Limited to 2 hours here.", s.For("option:timeout").Summary)
+	assert.Equal(t, "Aborts its steps if they take longer than a time limit.", s.For("timeout").Summary)
+
+	assert.EqualError(t, s.LoadOverrides([]byte("notify: x\nwidget:y: z\n")), `widget:y: unknown kind "widget"`)
+	assert.Nil(t, s.For("widget:y"))
+	assert.Error(t, s.LoadOverrides([]byte("- a\n")))
+}
+
+func TestBuiltinKinds(t *testing.T) {
+	for key := range builtin {
+		kind, name := split(key)
+		assert.True(t, known(kind), key)
+		assert.NotEmpty(t, name, key)
+	}
+}
@@ -0,0 +1,339 @@
+// Package explain describes pipelines in plain English, for onboarding and review summaries. The wording comes from
+// templates, which can be replaced to customize it or to describe pipelines in other languages.
+package explain
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/abayer/go-jenkinsfile/matrix"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// DefaultMessages are the English templates for each part of an explanation, by key. Each is a text/template executed
+// with the data described in its comment. Phrases are lower case, and sentences start with a capital letter and end
+// with a full stop.
+var DefaultMessages = map[string]string{
+	// Lists of phrases, with .Init the phrases but the last, and .Last the last.
+	"list.and": `{{range $i, $p := .Init}}{{if $i}}, {{end}}{{$p}}{{end}} and {{.Last}}`,
+	"list.or":  `{{range $i, $p := .Init}}{{if $i}}, {{end}}{{$p}}{{end}} or {{.Last}}`,
+
+	// Agents, with .Type, .Label, and .Image from plan.Agent.
+	"agent.any":        `any agent`,
+	"agent.label":      `an agent labelled '{{.Label}}'`,
+	"agent.docker":     `a Docker container from the '{{.Image}}' image{{if .Label}} on an agent labelled '{{.Label}}'{{end}}`,
+	"agent.dockerfile": `a container built from a Dockerfile`,
+	"agent.kubernetes": `a Kubernetes pod`,
+	"agent.other":      `a '{{.Type}}' agent`,
+
+	// The pipeline, with .Agent the agent phrase, and .Conditions the post condition phrases as a list.
+	"pipeline.agent":   `Runs on {{.Agent}}.`,
+	"pipeline.noAgent": `Has no agent of its own, so each stage chooses one.`,
+	"pipeline.post":    `When the build finishes, it runs post steps {{.Conditions}}.`,
+
+	// Stages, with .Name the stage's name, .When the when condition phrase, .Agent the agent phrase for stages with
+	// their own, .Message the input's message, .Steps the step phrases as a list, .Count the number of nested stages,
+	// .FailFast whether failFast is set, .Cells the number of matrix cells, .Axes the matrix axes' names as a list, and
+	// .Conditions the post condition phrases as a list.
+	"stage":            `Stage '{{.Name}}'{{if .When}} runs when {{.When}}{{else}} always runs{{end}}{{if .Agent}}, on {{.Agent}}{{end}}.`,
+	"stage.input":      `It waits for someone to answer "{{.Message}}".`,
+	"stage.steps":      `It runs {{.Steps}}.`,
+	"stage.sequential": `It runs {{.Count}} stages in order:`,
+	"stage.parallel":   `It runs {{.Count}} stages in parallel{{if .FailFast}}, stopping them all if one fails{{end}}:`,
+	"stage.matrix":     `It runs the following for each of {{.Cells}} combinations of {{.Axes}}{{if .FailFast}}, stopping them all if one fails{{end}}:`,
+	"stage.post":       `When it finishes, it runs post steps {{.Conditions}}.`,
+
+	// Steps, with .Name the step's name, .Script the first line of a script, and .Count the number of steps left out.
+	"step":        `{{.Name}}`,
+	"step.script": `'{{.Script}}'`,
+	"step.more":   `{{.Count}} more steps`,
+
+	// When conditions, with .Pattern, .Name, .Value, .Expected, .Actual, .Expression, and .Cause from the
+	// condition's arguments, and .Conditions the nested conditions' phrases for allOf and anyOf, as a list, or for not.
+	"when.branch":        `the branch matches '{{.Pattern}}'`,
+	"when.buildingTag":   `building a tag`,
+	"when.tag":           `building a tag{{if .Pattern}} matching '{{.Pattern}}'{{end}}`,
+	"when.changeset":     `files matching '{{.Pattern}}' changed`,
+	"when.changelog":     `a commit message matches '{{.Pattern}}'`,
+	"when.changeRequest": `building a change request`,
+	"when.environment":   `{{.Name}} is '{{.Value}}'`,
+	"when.equals":        `{{.Actual}} equals {{.Expected}}`,
+	"when.expression":    `'{{.Expression}}' is true`,
+	"when.triggeredBy":   `triggered by {{.Cause}}`,
+	"when.other":         `the {{.Name}} condition is met`,
+	"when.allOf":         `{{.Conditions}}`,
+	"when.anyOf":         `either {{.Conditions}}`,
+	"when.not":           `it's not the case that {{.Conditions}}`,
+
+	// Post conditions.
+	"condition.always":       `in every case`,
+	"condition.changed":      `if the result changed`,
+	"condition.fixed":        `if it fixed the previous build`,
+	"condition.regression":   `if it's worse than the previous build`,
+	"condition.aborted":      `if it was aborted`,
+	"condition.success":      `on success`,
+	"condition.unsuccessful": `if it didn't succeed`,
+	"condition.unstable":     `if it's unstable`,
+	"condition.failure":      `on failure`,
+	"condition.notBuilt":     `if it wasn't built`,
+	"condition.cleanup":      `to clean up`,
+}
+
+// maxSteps is the most steps described for a stage
+const maxSteps = 4
+
+// Explainer explains pipelines using a set of templates
+type Explainer struct {
+	templates *template.Template
+}
+
+var defaultExplainer, defaultErr = New(nil)
+
+// New creates an Explainer using DefaultMessages, with the given messages replacing them by key. It returns an error
+// if a key is unknown or a template can't be parsed.
+func New(messages map[string]string) (*Explainer, error) {
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		if _, ok := DefaultMessages[k]; !ok {
+			return nil, fmt.Errorf("unknown message %q", k)
+		}
+		keys = append(keys, k)
+	}
+	root := template.New("").Option("missingkey=error")
+	for k, m := range DefaultMessages {
+		if _, err := root.New(k).Parse(m); err != nil {
+			return nil, fmt.Errorf("message %s: %s", k, err)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := root.New(k).Parse(messages[k]); err != nil {
+			return nil, fmt.Errorf("message %s: %s", k, err)
+		}
+	}
+	return &Explainer{templates: root}, nil
+}
+
+// Text explains the pipeline in English with DefaultMessages.
+func Text(root *model.Root) (string, error) {
+	if defaultErr != nil {
+		return "", defaultErr
+	}
+	return defaultExplainer.Text(root)
+}
+
+// Text explains the pipeline: its agent, then each stage in order, with nested stages indented beneath their parent,
+// then its post conditions. Each stage gets a line saying when it runs and on what, followed by lines for its input,
+// steps, nested stages, and post conditions, if it has them.
+func (strct *Explainer) Text(root *model.Root) (string, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return "", err
+	}
+	e := &explanation{templates: strct.templates, buf: &bytes.Buffer{}}
+	if p.Agent == nil || p.Agent.Type == "none" {
+		e.line(0, "pipeline.noAgent", nil)
+	} else {
+		e.line(0, "pipeline.agent", map[string]interface{}{"Agent": e.agent(p.Agent)})
+	}
+	e.stages(p.Stages, 0)
+	if len(p.Post) > 0 {
+		e.line(0, "pipeline.post", map[string]interface{}{"Conditions": e.conditions(p.Post)})
+	}
+	if e.err != nil {
+		return "", e.err
+	}
+	return e.buf.String(), nil
+}
+
+// explanation accumulates the lines of an explanation, and the first error rendering them
+type explanation struct {
+	templates *template.Template
+	buf       *bytes.Buffer
+	err       error
+}
+
+// render executes a template, returning "" once an error has occurred
+func (e *explanation) render(key string, data interface{}) string {
+	if e.err != nil {
+		return ""
+	}
+	out := &bytes.Buffer{}
+	if err := e.templates.ExecuteTemplate(out, key, data); err != nil {
+		e.err = fmt.Errorf("message %s: %s", key, err)
+		return ""
+	}
+	return out.String()
+}
+
+func (e *explanation) line(depth int, key string, data interface{}) {
+	text := e.render(key, data)
+	if text != "" {
+		e.buf.WriteString(strings.Repeat("  ", depth) + text + "\n")
+	}
+}
+
+// list joins phrases with the given list message
+func (e *explanation) list(key string, phrases []string) string {
+	switch len(phrases) {
+	case 0:
+		return ""
+	case 1:
+		return phrases[0]
+	}
+	return e.render(key, map[string]interface{}{"Init": phrases[:len(phrases)-1], "Last": phrases[len(phrases)-1]})
+}
+
+func (e *explanation) agent(a *plan.Agent) string {
+	key := "agent." + a.Type
+	switch a.Type {
+	case "any", "docker", "dockerfile", "kubernetes":
+	case "label", "node":
+		key = "agent.label"
+	default:
+		key = "agent.other"
+	}
+	return e.render(key, a)
+}
+
+func (e *explanation) stages(stages []*plan.Stage, depth int) {
+	for _, s := range stages {
+		data := map[string]interface{}{"Name": s.Name, "When": "", "Agent": ""}
+		if s.When != nil {
+			data["When"] = e.when(s.When.Conditions, "list.and")
+		}
+		if s.Agent != nil && !s.Agent.Inherited {
+			data["Agent"] = e.agent(s.Agent)
+		}
+		e.line(depth, "stage", data)
+		if s.Input != nil {
+			e.line(depth+1, "stage.input", map[string]interface{}{"Message": s.Input.Message.String()})
+		}
+		if steps := e.steps(s.Steps); len(steps) > 0 {
+			e.line(depth+1, "stage.steps", map[string]interface{}{"Steps": e.list("list.and", steps)})
+		}
+		if len(s.Children) > 0 {
+			data := map[string]interface{}{"Count": len(s.Children), "FailFast": s.FailFast}
+			switch s.ChildMode {
+			case plan.Sequential:
+				e.line(depth+1, "stage.sequential", data)
+			case plan.Parallel:
+				e.line(depth+1, "stage.parallel", data)
+			case plan.Matrix:
+				var axes []string
+				for _, a := range s.Axes {
+					if a != nil {
+						axes = append(axes, a.Name)
+					}
+				}
+				data["Cells"] = 0
+				if s.Source != nil {
+					data["Cells"] = len(matrix.Cells(s.Source.Matrix))
+				}
+				data["Axes"] = e.list("list.and", axes)
+				e.line(depth+1, "stage.matrix", data)
+			}
+			e.stages(s.Children, depth+2)
+		}
+		if len(s.Post) > 0 {
+			e.line(depth+1, "stage.post", map[string]interface{}{"Conditions": e.conditions(s.Post)})
+		}
+	}
+}
+
+// steps describes the steps a stage runs, descending into block steps such as dir and withEnv
+func (e *explanation) steps(steps []*plan.Step) []string {
+	var out []string
+	count := 0
+	var visit func(steps []*plan.Step)
+	visit = func(steps []*plan.Step) {
+		for _, st := range steps {
+			if len(st.Children) > 0 {
+				visit(st.Children)
+				continue
+			}
+			count++
+			if count > maxSteps {
+				continue
+			}
+			if st.Script != "" {
+				line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(st.Script), "\n", 2)[0])
+				out = append(out, e.render("step.script", map[string]interface{}{"Name": st.Name, "Script": line}))
+			} else {
+				out = append(out, e.render("step", map[string]interface{}{"Name": st.Name}))
+			}
+		}
+	}
+	visit(steps)
+	if count > maxSteps {
+		out = append(out, e.render("step.more", map[string]interface{}{"Count": count - maxSteps}))
+	}
+	return out
+}
+
+func (e *explanation) conditions(blocks []*plan.PostBlock) string {
+	var phrases []string
+	for _, condition := range model.PostConditions {
+		for _, b := range blocks {
+			if b.Condition == condition {
+				phrases = append(phrases, e.render("condition."+condition, nil))
+			}
+		}
+	}
+	return e.list("list.and", phrases)
+}
+
+// when describes when conditions, joining them with the given list message
+func (e *explanation) when(conditions []*model.StepOrNestedWhenCondition, list string) string {
+	var phrases []string
+	for _, c := range conditions {
+		switch {
+		case c == nil:
+		case c.Step != nil:
+			// Step is only decoded when the condition isn't a nested one, so check it first.
+			phrases = append(phrases, e.condition(c.Step))
+		case c.Nested != nil:
+			key := "when." + c.Nested.Name
+			switch c.Nested.Name {
+			case "allOf", "not":
+				phrases = append(phrases, e.render(key, map[string]interface{}{
+					"Conditions": e.when(c.Nested.Children, "list.and")}))
+			case "anyOf":
+				phrases = append(phrases, e.render(key, map[string]interface{}{
+					"Conditions": e.when(c.Nested.Children, "list.or")}))
+			default:
+				phrases = append(phrases, e.render("when.other", map[string]interface{}{"Name": c.Nested.Name}))
+			}
+		}
+	}
+	return e.list(list, phrases)
+}
+
+func (e *explanation) condition(s *model.Step) string {
+	args := s.Arguments
+	arg := func(key string) string {
+		return args.Get(key).String()
+	}
+	data := map[string]interface{}{"Name": s.Name}
+	switch s.Name {
+	case "branch", "tag", "changeset", "changelog":
+		data["Pattern"] = arg("pattern")
+	case "environment":
+		data["Value"] = arg("value")
+		data["Name"] = arg("name")
+	case "equals":
+		data["Expected"], data["Actual"] = arg("expected"), arg("actual")
+	case "expression":
+		data["Expression"] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(arg("scriptBlock")), "return "))
+	case "triggeredBy":
+		data["Cause"] = arg("cause")
+	case "buildingTag", "changeRequest":
+	default:
+		return e.render("when.other", data)
+	}
+	return e.render("when."+s.Name, data)
+}
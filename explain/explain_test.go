@@ -0,0 +1,66 @@
+package explain
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, path ...string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join(path...))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestText(t *testing.T) {
+	text, err := Text(loadRoot(t, "testdata", "pipeline.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `Runs on any agent.
+Stage 'Build' always runs.
+  It runs 'make' and archiveArtifacts.
+Stage 'Test' always runs.
+  It runs 2 stages in parallel, stopping them all if one fails:
+    Stage 'Unit' always runs.
+      It runs 'make test'.
+    Stage 'Lint' always runs, on a Docker container from the 'golangci/golangci-lint' image.
+      It runs 'golangci-lint run'.
+Stage 'Deploy' runs when the branch matches 'main' and it's not the case that building a change request.
+  It waits for someone to answer "Deploy to production?".
+  It runs './deploy.sh'.
+  When it finishes, it runs post steps in every case and on failure.
+When the build finishes, it runs post steps on failure.
+`, text)
+
+	text, err = Text(loadRoot(t, "..", "model", "testdata", "json", "matrix", "matrixPipelineTwoAxisTwoExcludes.json"))
+	require.NoError(t, err)
+	assert.Contains(t, text, "It runs the following for each of 9 combinations of OS_VALUE and BROWSER_VALUE:\n")
+}
+
+func TestNew(t *testing.T) {
+	e, err := New(map[string]string{
+		"pipeline.agent": `Läuft auf {{.Agent}}.`,
+		"agent.any":      `einem beliebigen Agenten`,
+	})
+	require.NoError(t, err)
+	text, err := e.Text(loadRoot(t, "testdata", "pipeline.json"))
+	require.NoError(t, err)
+	assert.Contains(t, text, "Läuft auf einem beliebigen Agenten.\n")
+
+	_, err = New(map[string]string{"stage.summary": "x"})
+	assert.EqualError(t, err, `unknown message "stage.summary"`)
+	_, err = New(map[string]string{"stage": "{{.Name"})
+	assert.Error(t, err)
+
+	e, err = New(map[string]string{"stage": "{{.Missing}}"})
+	require.NoError(t, err)
+	_, err = e.Text(loadRoot(t, "testdata", "pipeline.json"))
+	assert.EqualError(t, err, `message stage: template: stage:1:2: executing "stage" at <.Missing>: map has no entry `+
+		`for key "Missing"`)
+}
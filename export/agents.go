@@ -0,0 +1,144 @@
+package export
+
+import (
+	"github.com/abayer/go-jenkinsfile/matrix"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// AgentReport is the agents a pipeline needs, in a form capacity planning tools can aggregate across pipelines to size
+// agent pools.
+type AgentReport struct {
+	Agents []*AgentRequirement `json:"agents"`
+	// Executors is the most executors the pipeline holds at once, of all its agents together.
+	Executors int `json:"executors"`
+}
+
+// AgentRequirement is an agent a pipeline allocates executors on
+type AgentRequirement struct {
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+	Image string `json:"image,omitempty"`
+	// Dynamic is true if the label or image is an expression, so the agent isn't known until the pipeline runs.
+	Dynamic bool `json:"dynamic,omitempty"`
+	// Pipeline is true if the pipeline's agent directive requests the agent.
+	Pipeline bool `json:"pipeline,omitempty"`
+	// Stages are the IDs of the stages which request the agent, in execution order.
+	Stages []string `json:"stages,omitempty"`
+	// Executors is the most executors of the agent the pipeline holds at once, counting parallel branches and matrix
+	// cells.
+	Executors int `json:"executors"`
+	// Allocations is the number of times a run of the pipeline allocates the agent, if every stage runs.
+	Allocations int `json:"allocations"`
+}
+
+// usage counts the executors held at once, or allocated, for each agent requirement. The nil key counts them for
+// all agents together.
+type usage map[*AgentRequirement]int
+
+// AgentRequirements reports the agents a pipeline needs and how many executors of each it holds at once. A pipeline
+// agent is held for the whole run, and a stage agent for the whole stage, including while its child stages hold
+// agents of their own. Parallel stages and matrix cells hold their agents at the same time, so count once per branch
+// or cell; cells are counted after excludes. Stages with agent none, and stages inheriting their agent, allocate
+// nothing.
+func AgentRequirements(root *model.Root) (*AgentReport, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	report := &AgentReport{Agents: []*AgentRequirement{}}
+	peak, allocated := usage{}, usage{}
+	for _, s := range p.Stages {
+		sp, sa := report.stage(s)
+		peak.max(sp)
+		allocated.add(sa, 1)
+	}
+	if req := report.require(p.Agent); req != nil {
+		req.Pipeline = true
+		peak.add(usage{req: 1, nil: 1}, 1)
+		allocated.add(usage{req: 1, nil: 1}, 1)
+	}
+	for _, req := range report.Agents {
+		req.Executors, req.Allocations = peak[req], allocated[req]
+	}
+	report.Executors = peak[nil]
+	return report, nil
+}
+
+// stage returns the executors a stage holds at once and allocates in all
+func (strct *AgentReport) stage(s *plan.Stage) (usage, usage) {
+	peak, allocated := usage{}, usage{}
+	for _, c := range s.Children {
+		cp, ca := strct.stage(c)
+		if s.ChildMode == plan.Parallel {
+			peak.add(cp, 1)
+		} else {
+			peak.max(cp)
+		}
+		allocated.add(ca, 1)
+	}
+	var req *AgentRequirement
+	if s.Agent != nil && !s.Agent.Inherited {
+		if req = strct.require(s.Agent); req != nil {
+			req.Stages = append(req.Stages, s.ID)
+			peak.add(usage{req: 1, nil: 1}, 1)
+			allocated.add(usage{req: 1, nil: 1}, 1)
+		}
+	}
+	if s.ChildMode == plan.Matrix {
+		// The matrix's agent and stages run once for each cell.
+		cells := len(matrix.Cells(s.Source.Matrix))
+		cellPeak, cellAllocated := peak, allocated
+		peak, allocated = usage{}, usage{}
+		peak.add(cellPeak, cells)
+		allocated.add(cellAllocated, cells)
+	}
+	return peak, allocated
+}
+
+// require returns the requirement for an agent, adding it to the report if it's new, or nil if the agent doesn't
+// allocate an executor
+func (strct *AgentReport) require(a *plan.Agent) *AgentRequirement {
+	if a == nil || a.Type == "none" {
+		return nil
+	}
+	for _, req := range strct.Agents {
+		if req.Type == a.Type && req.Label == a.Label && req.Image == a.Image {
+			return req
+		}
+	}
+	req := &AgentRequirement{Type: a.Type, Label: a.Label, Image: a.Image, Dynamic: dynamicAgent(a.Source)}
+	strct.Agents = append(strct.Agents, req)
+	return req
+}
+
+// dynamicAgent returns true if an agent's label or image isn't a literal
+func dynamicAgent(a *model.Agent) bool {
+	if a == nil {
+		return false
+	}
+	if a.Argument != nil && !a.Argument.IsLiteral {
+		return true
+	}
+	for _, arg := range a.Arguments {
+		if arg != nil && (arg.Key == "label" || arg.Key == "image") && arg.Value != nil && arg.Value.Raw != nil &&
+			!arg.Value.Raw.IsLiteral {
+			return true
+		}
+	}
+	return false
+}
+
+func (strct usage) add(other usage, times int) {
+	for k, n := range other {
+		strct[k] += n * times
+	}
+}
+
+func (strct usage) max(other usage) {
+	for k, n := range other {
+		if n > strct[k] {
+			strct[k] = n
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const agentsPipeline = `pipeline {
+    agent { label 'controller-light' }
+    stages {
+        stage('Build') {
+            agent { docker 'maven:3' }
+            steps {
+                sh 'mvn -B package'
+            }
+        }
+        stage('Test') {
+            parallel {
+                stage('Linux') {
+                    agent { label 'linux' }
+                    steps {
+                        sh 'make test'
+                    }
+                }
+                stage('Windows') {
+                    agent { label 'windows' }
+                    steps {
+                        bat 'make test'
+                    }
+                }
+                stage('Lint') {
+                    steps {
+                        sh 'make lint'
+                    }
+                }
+            }
+        }
+        stage('Matrix') {
+            matrix {
+                agent { label 'linux' }
+                axes {
+                    axis {
+                        name 'JDK'
+                        values '11', '17', '21'
+                    }
+                    axis {
+                        name 'DB'
+                        values 'mysql', 'postgres'
+                    }
+                }
+                excludes {
+                    exclude {
+                        axis {
+                            name 'JDK'
+                            values '11'
+                        }
+                        axis {
+                            name 'DB'
+                            values 'postgres'
+                        }
+                    }
+                }
+                stages {
+                    stage('Unit') {
+                        steps {
+                            sh 'make unit'
+                        }
+                    }
+                    stage('Integration') {
+                        steps {
+                            sh 'make integration'
+                        }
+                    }
+                }
+            }
+        }
+        stage('Deploy') {
+            agent { label "deploy-${env.REGION}" }
+            steps {
+                sh './deploy.sh'
+            }
+        }
+    }
+}`
+
+func TestAgentRequirements(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(agentsPipeline))
+	require.NoError(t, err)
+
+	report, err := AgentRequirements(root)
+	require.NoError(t, err)
+
+	expected := []*AgentRequirement{
+		{Type: "docker", Image: "maven:3", Stages: []string{"Build"}, Executors: 1, Allocations: 1},
+		{Type: "label", Label: "linux", Stages: []string{"Test/Linux", "Matrix"}, Executors: 5, Allocations: 6},
+		{Type: "label", Label: "windows", Stages: []string{"Test/Windows"}, Executors: 1, Allocations: 1},
+		{Type: "label", Label: `"deploy-${env.REGION}"`, Dynamic: true, Stages: []string{"Deploy"}, Executors: 1,
+			Allocations: 1},
+		{Type: "label", Label: "controller-light", Pipeline: true, Executors: 1, Allocations: 1},
+	}
+	assert.Equal(t, expected, report.Agents)
+	// The pipeline's agent and the five matrix cells
+	assert.Equal(t, 6, report.Executors)
+}
+
+func TestAgentRequirementsNone(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(`pipeline {
+    agent none
+    stages {
+        stage('Build') {
+            steps {
+                echo 'hello'
+            }
+        }
+    }
+}`))
+	require.NoError(t, err)
+
+	report, err := AgentRequirements(root)
+	require.NoError(t, err)
+	assert.Empty(t, report.Agents)
+	assert.Equal(t, 0, report.Executors)
+}
+
+func TestAgentRequirementsRequiresPipeline(t *testing.T) {
+	_, err := AgentRequirements(nil)
+	assert.EqualError(t, err, "a root with a pipeline is required")
+}
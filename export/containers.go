@@ -0,0 +1,237 @@
+// Package export converts pipeline models into formats consumed by other CI and build systems.
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// ContainerPipeline is a generic, container-oriented description of a linear pipeline: an ordered list of steps, each
+// running shell commands in a container image.
+type ContainerPipeline struct {
+	Steps    []*ContainerStep `json:"steps"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// ContainerStep is a single stage of a ContainerPipeline
+type ContainerStep struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Secrets  map[string]string `json:"secrets,omitempty"`
+	Commands []string          `json:"commands"`
+}
+
+// Containers converts a pipeline into a ContainerPipeline. Docker agents become container images, literal environment
+// variables become env entries and credentials become secrets keyed by credential ID. Constructs with no container
+// equivalent, such as Jenkins-specific steps, are skipped and reported in Warnings.
+func Containers(root *model.Root) (*ContainerPipeline, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	c := &containerConverter{out: &ContainerPipeline{Steps: []*ContainerStep{}}}
+	p := root.Pipeline
+
+	if p.Post != nil {
+		c.warn("pipeline", "post conditions are not exported")
+	}
+	walk.Stages(p.Stages, func(stage *model.Stage, parents []*model.Stage) {
+		c.stage(p, stage, parents)
+	})
+	return c.out, nil
+}
+
+type containerConverter struct {
+	out *ContainerPipeline
+}
+
+func (c *containerConverter) warn(where string, format string, args ...interface{}) {
+	c.out.Warnings = append(c.out.Warnings, fmt.Sprintf("%s: %s", where, fmt.Sprintf(format, args...)))
+}
+
+func (c *containerConverter) stage(p *model.Pipeline, stage *model.Stage, parents []*model.Stage) {
+	name := strings.Join(walk.Path(stage, parents), " / ")
+	for _, parent := range parents {
+		if parent.Matrix != nil {
+			// Reported once, on the matrix stage itself.
+			return
+		}
+	}
+	switch {
+	case stage.Matrix != nil:
+		c.warn(name, "matrix stages are not exported")
+		return
+	case len(stage.Parallel) > 0:
+		c.warn(name, "parallel stages are exported sequentially")
+	}
+	if stage.When != nil {
+		c.warn(name, "when conditions are not exported; the step always runs")
+	}
+	if stage.Input != nil {
+		c.warn(name, "input is not exported")
+	}
+	if stage.Post != nil {
+		c.warn(name, "post conditions are not exported")
+	}
+	if len(stage.Branches) == 0 {
+		return
+	}
+
+	step := &ContainerStep{Name: name, Commands: []string{}}
+	step.Image = c.image(name, p, stage, parents)
+	c.env(name, step, p.Environment)
+	for _, parent := range parents {
+		c.env(name, step, parent.Environment)
+	}
+	c.env(name, step, stage.Environment)
+
+	walk.StageSteps(stage, func(s *model.AnyStep, trees []*model.TreeStep) {
+		if s.Tree != nil {
+			if s.Tree.Name != "dir" {
+				c.warn(name, "%s block is not exported; its contents are", s.Tree.Name)
+			}
+			return
+		}
+		if s.Step == nil {
+			return
+		}
+		cmd := ""
+		switch s.Step.Name {
+		case "sh":
+			cmd = s.Step.Arguments.Get("script").String()
+		case "echo":
+			cmd = "echo " + shellQuote(s.Step.Arguments.Get("message").String())
+		default:
+			c.warn(name, "step %s has no container equivalent", s.Step.Name)
+			return
+		}
+		for i := len(trees) - 1; i >= 0; i-- {
+			if trees[i].Name == "dir" {
+				cmd = "cd " + shellQuote(trees[i].Arguments.Get("path").String()) + " && " + cmd
+			}
+		}
+		step.Commands = append(step.Commands, cmd)
+	})
+
+	c.out.Steps = append(c.out.Steps, step)
+}
+
+func (c *containerConverter) image(name string, p *model.Pipeline, stage *model.Stage, parents []*model.Stage) string {
+	agent := p.Agent
+	for _, s := range append(append([]*model.Stage{}, parents...), stage) {
+		if s.Agent != nil && s.Agent.Type != "none" {
+			agent = s.Agent
+		}
+	}
+	if agent == nil {
+		return ""
+	}
+	switch agent.Type {
+	case "docker":
+		if agent.Argument != nil {
+			return agent.Argument.String()
+		}
+		for _, a := range agent.Arguments {
+			if a.Key == "image" && a.Value != nil && a.Value.Raw != nil {
+				return a.Value.Raw.String()
+			}
+		}
+	case "none":
+		return ""
+	}
+	c.warn(name, "%s agent has no container image", agent.Type)
+	return ""
+}
+
+func (c *containerConverter) env(name string, step *ContainerStep, entries []*model.EnvironmentEntry) {
+	for _, e := range entries {
+		if e == nil || e.Value == nil {
+			continue
+		}
+		if f := e.Value.Function; f != nil {
+			if id, ok := e.Value.Credentials(); ok {
+				if step.Secrets == nil {
+					step.Secrets = make(map[string]string)
+				}
+				step.Secrets[e.Key] = id
+			} else if f.Name == model.CredentialsFunction {
+				c.warn(name, "environment variable %s binds a credential whose ID isn't a literal", e.Key)
+			} else {
+				c.warn(name, "environment variable %s uses unsupported function %s", e.Key, f.Name)
+			}
+			continue
+		}
+		if step.Env == nil {
+			step.Env = make(map[string]string)
+		}
+		step.Env[e.Key] = e.Value.Single.String()
+		if e.Value.Single != nil && !e.Value.Single.IsLiteral {
+			c.warn(name, "environment variable %s is a Groovy expression and is exported verbatim", e.Key)
+		}
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// Dagger renders the pipeline as a skeleton Go program using the Dagger SDK, with one container per step. Secrets are
+// read from host environment variables of the same name. Warnings are included as comments for follow-up.
+func (strct *ContainerPipeline) Dagger() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("package main\n\n")
+	for _, w := range strct.Warnings {
+		buf.WriteString("// TODO: " + w + "\n")
+	}
+	if len(strct.Warnings) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("import (\n\"context\"\n\"os\"\n\n\"dagger.io/dagger\"\n)\n\n")
+	buf.WriteString("func main() {\n")
+	buf.WriteString("ctx := context.Background()\n")
+	buf.WriteString("client, err := dagger.Connect(ctx, dagger.WithLogOutput(os.Stderr))\n")
+	buf.WriteString("if err != nil {\npanic(err)\n}\n")
+	buf.WriteString("defer client.Close()\n\n")
+	buf.WriteString("src := client.Host().Directory(\".\")\n")
+
+	for _, s := range strct.Steps {
+		image := s.Image
+		if image == "" {
+			image = "alpine:latest"
+		}
+		fmt.Fprintf(buf, "\n// %s\n", s.Name)
+		fmt.Fprintf(buf, "if _, err := client.Container().From(%s).\n", strconv.Quote(image))
+		buf.WriteString("WithDirectory(\"/src\", src).\nWithWorkdir(\"/src\").\n")
+		for _, k := range sortedKeys(s.Env) {
+			fmt.Fprintf(buf, "WithEnvVariable(%s, %s).\n", strconv.Quote(k), strconv.Quote(s.Env[k]))
+		}
+		for _, k := range sortedKeys(s.Secrets) {
+			fmt.Fprintf(buf, "WithSecretVariable(%s, client.SetSecret(%s, os.Getenv(%s))).\n",
+				strconv.Quote(k), strconv.Quote(s.Secrets[k]), strconv.Quote(k))
+		}
+		for _, cmd := range s.Commands {
+			fmt.Fprintf(buf, "WithExec([]string{\"sh\", \"-c\", %s}).\n", strconv.Quote(cmd))
+		}
+		buf.WriteString("Sync(ctx); err != nil {\npanic(err)\n}\n")
+	}
+	buf.WriteString("}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
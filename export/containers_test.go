@@ -0,0 +1,78 @@
+package export
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const containerPipeline = `{"pipeline": {
+  "agent": {"type": "docker", "argument": {"isLiteral": true, "value": "golang:1.14"}},
+  "environment": [
+    {"key": "GOFLAGS", "value": {"isLiteral": true, "value": "-mod=vendor"}},
+    {"key": "TOKEN", "value": {"name": "credentials", "arguments": [{"isLiteral": true, "value": "github-token"}]}}
+  ],
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make build"}}]},
+      {"name": "dir", "arguments": {"isLiteral": true, "value": "docs"}, "children": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]}
+      ]}
+    ]}]},
+    {"name": "Test", "agent": {"type": "docker", "arguments": [{"key": "image", "value": {"isLiteral": true, "value": "golang:1.15"}}]},
+      "branches": [{"name": "default", "steps": [
+        {"name": "echo", "arguments": [{"key": "message", "value": {"isLiteral": true, "value": "it's testing"}}]},
+        {"name": "junit", "arguments": [{"key": "testResults", "value": {"isLiteral": true, "value": "*.xml"}}]}
+      ]}]}
+  ]
+}}`
+
+func TestContainers(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(containerPipeline), root))
+
+	got, err := Containers(root)
+	require.NoError(t, err)
+	require.Len(t, got.Steps, 2)
+
+	build := got.Steps[0]
+	assert.Equal(t, "Build", build.Name)
+	assert.Equal(t, "golang:1.14", build.Image)
+	assert.Equal(t, map[string]string{"GOFLAGS": "-mod=vendor"}, build.Env)
+	assert.Equal(t, map[string]string{"TOKEN": "github-token"}, build.Secrets)
+	assert.Equal(t, []string{"make build", "cd 'docs' && make"}, build.Commands)
+
+	test := got.Steps[1]
+	assert.Equal(t, "golang:1.15", test.Image)
+	assert.Equal(t, []string{`echo 'it'"'"'s testing'`}, test.Commands)
+
+	assert.Equal(t, []string{"Test: step junit has no container equivalent"}, got.Warnings)
+
+	src, err := got.Dagger()
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", src, parser.AllErrors)
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), `client.Container().From("golang:1.15")`)
+	assert.Contains(t, string(src), `WithSecretVariable("TOKEN", client.SetSecret("github-token", os.Getenv("TOKEN")))`)
+	assert.Contains(t, string(src), "// TODO: Test: step junit has no container equivalent")
+}
+
+func TestContainersWarnsOnMatrix(t *testing.T) {
+	root := &model.Root{Pipeline: &model.Pipeline{
+		Agent: &model.Agent{Type: "none"},
+		Stages: []*model.Stage{{
+			Name:   "cells",
+			Matrix: &model.Matrix{Stages: []*model.Stage{{Name: "inner", Branches: []*model.Branch{{Name: "default"}}}}},
+		}},
+	}}
+
+	got, err := Containers(root)
+	require.NoError(t, err)
+	assert.Empty(t, got.Steps)
+	assert.Equal(t, []string{"cells: matrix stages are not exported"}, got.Warnings)
+}
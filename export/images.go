@@ -0,0 +1,320 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// ImageReport proposes a container image for each stage of a pipeline, to help move stages from static agents to
+// ephemeral Kubernetes pods.
+type ImageReport struct {
+	Stages []*StageImage `json:"stages"`
+}
+
+// StageImage is the image proposed for a single stage
+type StageImage struct {
+	// Stage is the stage's path, joined with " / ".
+	Stage string `json:"stage"`
+	// Agent describes the agent the stage runs on now, such as "label linux" or "docker maven:3".
+	Agent string `json:"agent"`
+	// Image is the image the stage already runs in, for stages with docker agents.
+	Image string `json:"image,omitempty"`
+	// Base is the proposed base image. It's empty if no image is proposed, such as for stages already running in a
+	// container.
+	Base string `json:"base,omitempty"`
+	// Tools are the tools the stage needs, from its tools directive and the commands its scripts run.
+	Tools []*ToolLayer `json:"tools,omitempty"`
+	// Notes explain why no image was proposed, or what needs checking by hand.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// ToolLayer is a tool a stage needs
+type ToolLayer struct {
+	Name string `json:"name"`
+	// Version is the version from the tools directive's installation name, if it has one.
+	Version string `json:"version,omitempty"`
+	// Evidence is why the tool is needed, such as "tools directive" or "sh: mvn".
+	Evidence string `json:"evidence"`
+	// Install is the Dockerfile instruction adding the tool to the base image. It's empty if the base image provides
+	// it.
+	Install string `json:"install,omitempty"`
+}
+
+// toolImage describes how a tool is provided in an image
+type toolImage struct {
+	// image is the official image with the tool, and tag its default tag, for tools which can be a base image. The
+	// tool's version replaces the tag when it's known.
+	image string
+	tag   string
+	// provides are the other tools the image includes.
+	provides []string
+	// packages are the Debian packages installing the tool, and copy the instructions copying it from another image
+	// instead.
+	packages string
+	copy     string
+}
+
+// baseTools are the tools which can provide a base image, in order of preference
+var baseTools = []string{"maven", "gradle", "jdk", "nodejs", "go", "python"}
+
+var toolImages = map[string]*toolImage{
+	"maven":  {image: "maven", tag: "3-eclipse-temurin-17", provides: []string{"jdk", "git", "curl"}, packages: "maven"},
+	"gradle": {image: "gradle", tag: "jdk17", provides: []string{"jdk", "git", "curl"}, packages: "gradle"},
+	"jdk":    {image: "eclipse-temurin", tag: "17", provides: []string{"curl"}, packages: "openjdk-17-jdk-headless"},
+	"nodejs": {image: "node", tag: "lts", provides: []string{"git", "curl", "make", "python"}, packages: "nodejs npm"},
+	"go": {image: "golang", tag: "1", provides: []string{"git", "curl", "make"},
+		copy: "COPY --from=golang:1 /usr/local/go /usr/local/go\nENV PATH=/usr/local/go/bin:$PATH"},
+	"python":    {image: "python", tag: "3", provides: []string{"git", "curl", "make"}, packages: "python3 python3-pip"},
+	"git":       {packages: "git"},
+	"make":      {packages: "make"},
+	"curl":      {packages: "curl ca-certificates"},
+	"jq":        {packages: "jq"},
+	"zip":       {packages: "zip unzip"},
+	"docker":    {copy: "COPY --from=docker:cli /usr/local/bin/docker /usr/local/bin/docker"},
+	"kubectl":   {copy: "COPY --from=bitnami/kubectl:latest /opt/bitnami/kubectl/bin/kubectl /usr/local/bin/kubectl"},
+	"helm":      {copy: "COPY --from=alpine/helm:latest /usr/bin/helm /usr/local/bin/helm"},
+	"terraform": {copy: "COPY --from=hashicorp/terraform:latest /bin/terraform /usr/local/bin/terraform"},
+}
+
+// DefaultBaseImage is the base image proposed for stages which don't need a language toolchain
+const DefaultBaseImage = "debian:bookworm-slim"
+
+// commandTools maps the commands scripts run to the tools providing them
+var commandTools = map[string]string{
+	"mvn": "maven", "./mvnw": "jdk", "gradle": "gradle", "./gradlew": "jdk", "java": "jdk", "javac": "jdk",
+	"node": "nodejs", "npm": "nodejs", "npx": "nodejs", "yarn": "nodejs", "pnpm": "nodejs",
+	"go": "go", "gofmt": "go",
+	"python": "python", "python3": "python", "pip": "python", "pip3": "python", "pytest": "python",
+	"git": "git", "make": "make", "curl": "curl", "jq": "jq", "zip": "zip", "unzip": "zip",
+	"docker": "docker", "kubectl": "kubectl", "helm": "helm", "terraform": "terraform",
+}
+
+var (
+	commandSeparator = regexp.MustCompile(`&&|\|\||[;|\n]`)
+	envAssignment    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+	toolVersion      = regexp.MustCompile(`\d+(\.\d+)*`)
+)
+
+// ProposeImages analyzes each stage's agent, tools directive, and scripts to propose a container image for it: a base
+// image with the stage's main toolchain, plus layers adding its other tools. Tools are found from the first word of
+// each script command, so tools run by scripts the pipeline calls, or by shared library steps, are missed.
+func ProposeImages(root *model.Root) (*ImageReport, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	report := &ImageReport{Stages: []*StageImage{}}
+	var visit func(stages []*plan.Stage, tools []*model.ArgumentValue)
+	visit = func(stages []*plan.Stage, tools []*model.ArgumentValue) {
+		for _, s := range stages {
+			stageTools := tools
+			if s.Source != nil {
+				stageTools = append(append([]*model.ArgumentValue{}, tools...), s.Source.Tools...)
+			}
+			if len(s.Steps) > 0 {
+				report.Stages = append(report.Stages, proposeImage(s, stageTools))
+			}
+			visit(s.Children, stageTools)
+		}
+	}
+	visit(p.Stages, root.Pipeline.Tools)
+	return report, nil
+}
+
+func proposeImage(s *plan.Stage, tools []*model.ArgumentValue) *StageImage {
+	img := &StageImage{Stage: strings.Join(s.Path, " / "), Agent: describeAgent(s.Agent)}
+	found := map[string]*ToolLayer{}
+	var order []string
+	need := func(name, version, evidence string) {
+		if t, ok := found[name]; ok {
+			if t.Version == "" {
+				t.Version = version
+			}
+			return
+		}
+		found[name] = &ToolLayer{Name: name, Version: version, Evidence: evidence}
+		order = append(order, name)
+	}
+	for _, t := range tools {
+		if t == nil || t.Value == nil {
+			continue
+		}
+		name := t.Key
+		if toolImages[name] == nil {
+			img.Notes = append(img.Notes, fmt.Sprintf("tool type %s has no known image", t.Key))
+			continue
+		}
+		need(name, toolVersion.FindString(t.Value.String()), "tools directive")
+	}
+	windows := false
+	var visitSteps func(steps []*plan.Step)
+	visitSteps = func(steps []*plan.Step) {
+		for _, st := range steps {
+			switch st.Name {
+			case "sh":
+				for _, cmd := range commands(st.Script) {
+					if tool, ok := commandTools[cmd]; ok {
+						need(tool, "", "sh: "+cmd)
+					}
+				}
+			case "bat", "powershell":
+				windows = true
+			}
+			visitSteps(st.Children)
+		}
+	}
+	visitSteps(s.Steps)
+	for _, name := range order {
+		img.Tools = append(img.Tools, found[name])
+	}
+
+	switch {
+	case s.Agent != nil && s.Agent.Type == "docker":
+		img.Image = s.Agent.Image
+		img.Notes = append(img.Notes, "already runs in a container")
+		return img
+	case s.Agent != nil && s.Agent.Type == "dockerfile":
+		img.Notes = append(img.Notes, "already builds its own image from a Dockerfile")
+		return img
+	case s.Agent != nil && s.Agent.Type == "kubernetes":
+		img.Notes = append(img.Notes, "already runs in a Kubernetes pod")
+		return img
+	case windows || (s.Agent != nil && strings.Contains(strings.ToLower(s.Agent.Label), "windows")):
+		img.Notes = append(img.Notes, "runs Windows steps or agents, so it needs a Windows container image")
+		return img
+	}
+
+	img.Base = DefaultBaseImage
+	provided := map[string]bool{}
+	for _, name := range baseTools {
+		if t, ok := found[name]; ok {
+			ti := toolImages[name]
+			tag := ti.tag
+			if t.Version != "" {
+				tag = t.Version
+			}
+			img.Base = ti.image + ":" + tag
+			provided[name] = true
+			for _, p := range ti.provides {
+				provided[p] = true
+			}
+			break
+		}
+	}
+	var packages []string
+	for _, t := range img.Tools {
+		ti := toolImages[t.Name]
+		switch {
+		case provided[t.Name]:
+		case ti.copy != "":
+			t.Install = ti.copy
+		default:
+			packages = append(packages, ti.packages)
+		}
+	}
+	if len(packages) > 0 {
+		install := "RUN apt-get update && apt-get install -y --no-install-recommends " + strings.Join(packages, " ") +
+			" && rm -rf /var/lib/apt/lists/*"
+		for _, t := range img.Tools {
+			if !provided[t.Name] && toolImages[t.Name].copy == "" {
+				t.Install = install
+			}
+		}
+	}
+	if len(img.Tools) == 0 {
+		img.Notes = append(img.Notes, "no tools were found, so the base image is a guess")
+	}
+	return img
+}
+
+func describeAgent(a *plan.Agent) string {
+	if a == nil {
+		return "none"
+	}
+	switch {
+	case a.Image != "":
+		return a.Type + " " + a.Image
+	case a.Label != "":
+		return a.Type + " " + a.Label
+	}
+	return a.Type
+}
+
+// commands returns the command names run by a shell script, skipping leading variable assignments and sudo
+func commands(script string) []string {
+	var out []string
+	for _, part := range commandSeparator.Split(script, -1) {
+		fields := strings.Fields(strings.Trim(strings.TrimSpace(part), `"'`))
+		for len(fields) > 0 && (envAssignment.MatchString(fields[0]) || fields[0] == "sudo") {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			out = append(out, strings.Trim(fields[0], `"'`))
+		}
+	}
+	return out
+}
+
+// Dockerfile renders the proposed image as a Dockerfile, or returns nil if no image is proposed.
+func (strct *StageImage) Dockerfile() []byte {
+	if strct.Base == "" {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Proposed image for stage %q, which runs on agent %s.\n", strct.Stage, strct.Agent)
+	for _, n := range strct.Notes {
+		fmt.Fprintf(buf, "# Note: %s\n", n)
+	}
+	fmt.Fprintf(buf, "FROM %s\n", strct.Base)
+	seen := map[string]bool{}
+	for _, t := range strct.Tools {
+		// Tools installed from packages share a single instruction.
+		if t.Install != "" && !seen[t.Install] {
+			seen[t.Install] = true
+			buf.WriteString(t.Install + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+var nonSlug = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WriteDockerfiles writes the Dockerfile of each stage with a proposed image to its own directory under dir, named
+// after the stage. It returns the paths written.
+func (strct *ImageReport) WriteDockerfiles(dir string) ([]string, error) {
+	if strct == nil {
+		return nil, errors.New("a report is required")
+	}
+	var paths []string
+	used := map[string]bool{}
+	for _, s := range strct.Stages {
+		df := s.Dockerfile()
+		if df == nil {
+			continue
+		}
+		base := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s.Stage), "-"), "-")
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[name] = true
+		path := filepath.Join(dir, name, "Dockerfile")
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return paths, err
+		}
+		if err := ioutil.WriteFile(path, df, 0600); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
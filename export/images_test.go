@@ -0,0 +1,103 @@
+package export
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const imagesPipeline = `{"pipeline": {
+  "agent": {"type": "label", "argument": {"isLiteral": true, "value": "linux"}},
+  "tools": [{"key": "maven", "value": {"isLiteral": true, "value": "apache-maven-3.9.6"}}],
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true,
+        "value": "MAVEN_OPTS=-Xmx1g mvn -B package && jq . target/report.json"}}]}
+    ]}]},
+    {"name": "Deploy", "tools": [{"key": "allure", "value": {"isLiteral": true, "value": "allure-2"}}],
+      "branches": [{"name": "default", "steps": [
+        {"name": "dir", "arguments": {"isLiteral": true, "value": "deploy"}, "children": [
+          {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true,
+            "value": "helm upgrade app ./chart\nkubectl rollout status deploy/app | tee status.txt"}}]}
+        ]}
+      ]}]},
+    {"name": "Scan", "agent": {"type": "docker", "argument": {"isLiteral": true, "value": "aquasec/trivy"}},
+      "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "trivy fs ."}}]}
+      ]}]},
+    {"name": "Windows", "branches": [{"name": "default", "steps": [
+      {"name": "bat", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "build.cmd"}}]}
+    ]}]}
+  ]
+}}`
+
+func TestProposeImages(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(imagesPipeline), root))
+
+	report, err := ProposeImages(root)
+	require.NoError(t, err)
+	require.Len(t, report.Stages, 4)
+
+	build := report.Stages[0]
+	assert.Equal(t, "Build", build.Stage)
+	assert.Equal(t, "label linux", build.Agent)
+	assert.Equal(t, "maven:3.9.6", build.Base)
+	assert.Equal(t, []*ToolLayer{
+		{Name: "maven", Version: "3.9.6", Evidence: "tools directive"},
+		{Name: "jq", Evidence: "sh: jq",
+			Install: "RUN apt-get update && apt-get install -y --no-install-recommends jq && rm -rf /var/lib/apt/lists/*"},
+	}, build.Tools)
+
+	deploy := report.Stages[1]
+	assert.Equal(t, "maven:3.9.6", deploy.Base)
+	assert.Equal(t, []string{"tool type allure has no known image"}, deploy.Notes)
+	require.Len(t, deploy.Tools, 3)
+	assert.Equal(t, "helm", deploy.Tools[1].Name)
+	assert.Equal(t, "kubectl", deploy.Tools[2].Name)
+	assert.Equal(t, `# Proposed image for stage "Deploy", which runs on agent label linux.
+# Note: tool type allure has no known image
+FROM maven:3.9.6
+COPY --from=alpine/helm:latest /usr/bin/helm /usr/local/bin/helm
+COPY --from=bitnami/kubectl:latest /opt/bitnami/kubectl/bin/kubectl /usr/local/bin/kubectl
+`, string(deploy.Dockerfile()))
+
+	scan := report.Stages[2]
+	assert.Equal(t, "aquasec/trivy", scan.Image)
+	assert.Empty(t, scan.Base)
+	assert.Nil(t, scan.Dockerfile())
+
+	windows := report.Stages[3]
+	assert.Empty(t, windows.Base)
+	assert.Equal(t, []string{"runs Windows steps or agents, so it needs a Windows container image"}, windows.Notes)
+}
+
+func TestProposeImagesDefaultBase(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(containerPipeline), root))
+	root.Pipeline.Agent = &model.Agent{Type: "any"}
+	root.Pipeline.Stages[1].Agent = nil
+
+	report, err := ProposeImages(root)
+	require.NoError(t, err)
+	require.Len(t, report.Stages, 2)
+	assert.Equal(t, DefaultBaseImage, report.Stages[0].Base)
+	assert.Equal(t, "make", report.Stages[0].Tools[0].Name)
+	assert.Equal(t, []string{"no tools were found, so the base image is a guess"}, report.Stages[1].Notes)
+
+	dir, err := ioutil.TempDir("", "images")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	paths, err := report.WriteDockerfiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "build", "Dockerfile"), filepath.Join(dir, "test", "Dockerfile")}, paths)
+	df, err := ioutil.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(df), "FROM debian:bookworm-slim\nRUN apt-get update")
+}
@@ -0,0 +1,151 @@
+// Package extract pulls embedded content, such as shell scripts, out of pipeline models for analysis by other tools.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// DefaultShebang is the interpreter Jenkins uses for sh steps whose script does not start with its own shebang line.
+const DefaultShebang = "#!/bin/sh -xe"
+
+// Script is the body of a single sh, bat, powershell, or pwsh step
+type Script struct {
+	// StagePath is the names of the stages enclosing the step, outermost first. It is empty for the pipeline's post section.
+	StagePath []string `json:"stagePath,omitempty"`
+	// Post is the post condition the step runs under, if it is in a post section.
+	Post string `json:"post,omitempty"`
+	// Step is the name of the step the script came from.
+	Step string `json:"step"`
+	// Shebang is the interpreter line the script runs with. It is empty for Windows scripts.
+	Shebang string `json:"shebang,omitempty"`
+	// Body is the script itself, without any surrounding Groovy quotes.
+	Body string `json:"body"`
+	// Interpolated is true if the body is a Groovy GString, so ${...} expressions are substituted by Groovy before the
+	// shell sees them.
+	Interpolated bool `json:"interpolated,omitempty"`
+	// Expression is true if the script is a Groovy expression other than a string, such as a concatenation or a
+	// variable, so the body is its Groovy source rather than the script.
+	Expression bool `json:"expression,omitempty"`
+}
+
+var extensions = map[string]string{
+	"sh":         ".sh",
+	"bat":        ".bat",
+	"powershell": ".ps1",
+	"pwsh":       ".ps1",
+}
+
+// Scripts returns every script step in the pipeline, in the order they appear.
+func Scripts(root *model.Root) ([]*Script, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	var scripts []*Script
+	collect := func(path []string) func(post string) walk.StepFunc {
+		return func(post string) walk.StepFunc {
+			return func(s *model.AnyStep, _ []*model.TreeStep) {
+				if s.Step == nil {
+					return
+				}
+				if _, ok := extensions[s.Step.Name]; !ok {
+					return
+				}
+				scripts = append(scripts, newScript(path, post, s.Step))
+			}
+		}
+	}
+
+	walk.Stages(root.Pipeline.Stages, func(stage *model.Stage, parents []*model.Stage) {
+		path := walk.Path(stage, parents)
+		walk.StageSteps(stage, collect(path)(""))
+		walk.StagePostSteps(stage, collect(path))
+	})
+	walk.PostConditionSteps(root.Pipeline.Post, collect(nil))
+	return scripts, nil
+}
+
+func newScript(path []string, post string, step *model.Step) *Script {
+	arg := step.Arguments.Get("script")
+	s := &Script{
+		StagePath: path,
+		Post:      post,
+		Step:      step.Name,
+		Body:      arg.String(),
+	}
+	if arg != nil && !arg.IsLiteral {
+		if body, interpolated, ok := groovyString(s.Body); ok {
+			s.Body, s.Interpolated = body, interpolated
+		} else {
+			s.Expression = true
+		}
+	}
+	if step.Name == "sh" {
+		s.Shebang = DefaultShebang
+		if strings.HasPrefix(s.Body, "#!") {
+			s.Shebang = strings.SplitN(s.Body, "\n", 2)[0]
+		}
+	}
+	return s
+}
+
+// groovyString returns the contents of a Groovy string expression, with its escapes decoded, and whether it is an
+// interpolated GString. It returns false for any other expression, such as a concatenation of strings.
+func groovyString(expr string) (string, bool, bool) {
+	e, err := groovy.ParseExpr(expr)
+	if err != nil || e.Kind != groovy.ExprString {
+		return "", false, false
+	}
+	return e.Value, e.Interpolated, true
+}
+
+// FileName returns a file name for the script, unique within the given position in the pipeline's scripts.
+func (strct *Script) FileName(index int) string {
+	parts := append([]string{}, strct.StagePath...)
+	if strct.Post != "" {
+		parts = append(parts, "post", strct.Post)
+	}
+	if len(parts) == 0 {
+		parts = []string{"pipeline"}
+	}
+	return fmt.Sprintf("%03d-%s%s", index, slug(strings.Join(parts, "-")), extensions[strct.Step])
+}
+
+var nonSlug = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func slug(s string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(s, "_"), "_")
+}
+
+// WriteScripts writes each script to its own file in dir, creating dir if necessary. sh scripts are written with
+// their shebang line so shellcheck can determine the dialect. It returns the paths written.
+func WriteScripts(dir string, scripts []*Script) ([]string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i, s := range scripts {
+		body := s.Body
+		if s.Shebang != "" && !strings.HasPrefix(body, "#!") {
+			body = s.Shebang + "\n" + body
+		}
+		if !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		path := filepath.Join(dir, s.FileName(i))
+		if err := ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
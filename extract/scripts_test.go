@@ -0,0 +1,102 @@
+package extract
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scriptsPipeline = `{"pipeline": {
+  "agent": {"type": "any"},
+  "stages": [
+    {"name": "Build", "stages": [
+      {"name": "Unix", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]},
+        {"name": "dir", "arguments": {"isLiteral": true, "value": "sub"}, "children": [
+          {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": false, "value": "\"\"\"#!/bin/bash\necho ${FOO}\"\"\""}}]}
+        ]}
+      ]}]},
+      {"name": "Windows", "branches": [{"name": "default", "steps": [
+        {"name": "bat", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "build.cmd"}}]},
+        {"name": "powershell", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "Get-ChildItem"}}]}
+      ]}]}
+    ]},
+    {"name": "Test", "matrix": {
+      "axes": [{"name": "OS", "values": [{"isLiteral": true, "value": "linux"}, {"isLiteral": true, "value": "mac"}]}],
+      "stages": [{"name": "Unit", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make test"}}]}
+      ]}]}],
+      "post": {"conditions": [{"condition": "failure", "branch": {"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make report"}}]}
+      ]}}]}
+    }}
+  ],
+  "post": {"conditions": [{"condition": "always", "branch": {"name": "default", "steps": [
+    {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make clean"}}]}
+  ]}}]}
+}}`
+
+func TestScripts(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(scriptsPipeline), root))
+
+	scripts, err := Scripts(root)
+	require.NoError(t, err)
+	assert.Equal(t, []*Script{
+		{StagePath: []string{"Build", "Unix"}, Step: "sh", Shebang: DefaultShebang, Body: "make"},
+		{StagePath: []string{"Build", "Unix"}, Step: "sh", Shebang: "#!/bin/bash", Body: "#!/bin/bash\necho ${FOO}", Interpolated: true},
+		{StagePath: []string{"Build", "Windows"}, Step: "bat", Body: "build.cmd"},
+		{StagePath: []string{"Build", "Windows"}, Step: "powershell", Body: "Get-ChildItem"},
+		{StagePath: []string{"Test"}, Post: "failure", Step: "sh", Shebang: DefaultShebang, Body: "make report"},
+		{StagePath: []string{"Test", "Unit"}, Step: "sh", Shebang: DefaultShebang, Body: "make test"},
+		{Post: "always", Step: "sh", Shebang: DefaultShebang, Body: "make clean"},
+	}, scripts)
+
+	dir, err := ioutil.TempDir("", "scripts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	paths, err := WriteScripts(dir, scripts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "000-Build-Unix.sh"),
+		filepath.Join(dir, "001-Build-Unix.sh"),
+		filepath.Join(dir, "002-Build-Windows.bat"),
+		filepath.Join(dir, "003-Build-Windows.ps1"),
+		filepath.Join(dir, "004-Test-post-failure.sh"),
+		filepath.Join(dir, "005-Test-Unit.sh"),
+		filepath.Join(dir, "006-post-always.sh"),
+	}, paths)
+
+	contents, err := ioutil.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh -xe\nmake\n", string(contents))
+}
+
+func TestScriptsNonLiteral(t *testing.T) {
+	sh := func(source string) *model.AnyStep {
+		arg := &model.RawArgument{Value: &model.RawArgumentValue{AsString: &source}}
+		return model.NewAnyStep(&model.Step{Name: "sh", Arguments: model.NewSingleArgument(arg)})
+	}
+	root := &model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{{Name: "Build", Branches: []*model.Branch{{
+		Name:  "default",
+		Steps: []*model.AnyStep{sh(`"echo \${HOME} \\ \'${VERSION}"`), sh(`'make ' + target`), sh(`"a" + "b"`)},
+	}}}}}}
+
+	scripts, err := Scripts(root)
+	require.NoError(t, err)
+	require.Len(t, scripts, 3)
+	assert.Equal(t, `echo ${HOME} \ '${VERSION}`, scripts[0].Body)
+	assert.True(t, scripts[0].Interpolated)
+	assert.False(t, scripts[0].Expression)
+	assert.Equal(t, `'make ' + target`, scripts[1].Body)
+	assert.True(t, scripts[1].Expression)
+	assert.Equal(t, `"a" + "b"`, scripts[2].Body)
+	assert.True(t, scripts[2].Expression)
+	assert.False(t, scripts[2].Interpolated)
+}
@@ -0,0 +1,325 @@
+// Package features reports which Declarative features a pipeline uses, such as matrices, input directives, and when
+// conditions evaluated before the agent. Only whether each feature is used is reported, not names, values, or
+// counts, so reports can be gathered across an organization to judge which converters or Jenkins versions its
+// pipelines are ready for, without sharing anything about the pipelines themselves.
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Feature is a Declarative feature
+type Feature uint64
+
+// The features Detect reports. Their values are stable, so sets may be stored and compared as numbers; new features
+// are only ever added at the end.
+const (
+	// Environment is an environment directive on the pipeline or a stage.
+	Environment Feature = 1 << iota
+	// Credentials is an environment variable bound with credentials().
+	Credentials
+	// Parameters is a parameters directive.
+	Parameters
+	// Triggers is a triggers directive.
+	Triggers
+	// Libraries is a libraries directive loading shared libraries.
+	Libraries
+	// Tools is a tools directive on the pipeline or a stage.
+	Tools
+	// PipelineOptions is an options directive on the pipeline.
+	PipelineOptions
+	// StageOptions is an options directive on a stage.
+	StageOptions
+	// AgentNone is the pipeline having no agent, leaving stages to choose their own.
+	AgentNone
+	// StageAgent is an agent directive on a stage.
+	StageAgent
+	// DockerAgent is a docker or dockerfile agent.
+	DockerAgent
+	// KubernetesAgent is a kubernetes agent.
+	KubernetesAgent
+	// SequentialStages is a stage with nested sequential stages.
+	SequentialStages
+	// ParallelStages is a stage with parallel stages.
+	ParallelStages
+	// NestedParallel is parallel stages nested inside sequential stages.
+	NestedParallel
+	// FailFast is failFast set on parallel stages or a matrix.
+	FailFast
+	// Matrix is a matrix stage.
+	Matrix
+	// MatrixExcludes is a matrix with excludes.
+	MatrixExcludes
+	// When is a when directive.
+	When
+	// NestedWhen is a when condition combining others with allOf, anyOf, or not.
+	NestedWhen
+	// WhenExpression is an expression when condition, which runs Groovy.
+	WhenExpression
+	// BeforeAgent is a when directive evaluated before the stage's agent is allocated.
+	BeforeAgent
+	// BeforeInput is a when directive evaluated before the stage's input.
+	BeforeInput
+	// BeforeOptions is a when directive evaluated before the stage's options.
+	BeforeOptions
+	// Input is an input directive on a stage.
+	Input
+	// InputParameters is an input directive asking for parameters.
+	InputParameters
+	// PipelinePost is a post section on the pipeline.
+	PipelinePost
+	// StagePost is a post section on a stage.
+	StagePost
+	// ScriptBlock is a script step running Scripted Pipeline code.
+	ScriptBlock
+	// RawGroovy is Groovy source which couldn't be modelled, kept as a script step.
+	RawGroovy
+)
+
+// names are the names of the features, in the order of their values
+var names = []string{
+	"environment", "credentials", "parameters", "triggers", "libraries", "tools", "pipeline-options",
+	"stage-options", "agent-none", "stage-agent", "docker-agent", "kubernetes-agent", "sequential-stages",
+	"parallel-stages", "nested-parallel", "fail-fast", "matrix", "matrix-excludes", "when", "nested-when",
+	"when-expression", "before-agent", "before-input", "before-options", "input", "input-parameters",
+	"pipeline-post", "stage-post", "script-block", "raw-groovy",
+}
+
+// String returns the feature's name, such as "nested-parallel"
+func (f Feature) String() string {
+	for i, name := range names {
+		if f == 1<<uint(i) {
+			return name
+		}
+	}
+	return fmt.Sprintf("Feature(%d)", uint64(f))
+}
+
+// All returns every feature, in the order of their values.
+func All() []Feature {
+	out := make([]Feature, len(names))
+	for i := range names {
+		out[i] = 1 << uint(i)
+	}
+	return out
+}
+
+// Parse returns the feature with the given name.
+func Parse(name string) (Feature, error) {
+	for i, n := range names {
+		if n == name {
+			return 1 << uint(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown feature %q", name)
+}
+
+// Set is a set of features, as a bitmap of their values. It marshals to JSON as the list of their names.
+type Set uint64
+
+// Has returns true if the set contains every one of the features.
+func (s Set) Has(features ...Feature) bool {
+	for _, f := range features {
+		if uint64(s)&uint64(f) != uint64(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add returns the set with the features added.
+func (s Set) Add(features ...Feature) Set {
+	for _, f := range features {
+		s |= Set(f)
+	}
+	return s
+}
+
+// Features returns the features in the set, in the order of their values.
+func (s Set) Features() []Feature {
+	var out []Feature
+	for _, f := range All() {
+		if s.Has(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Missing returns the features in the set which aren't in supported, such as those a converter can't handle.
+func (s Set) Missing(supported Set) Set {
+	return s &^ supported
+}
+
+// String returns the names of the features in the set, separated by commas.
+func (s Set) String() string {
+	var out []string
+	for _, f := range s.Features() {
+		out = append(out, f.String())
+	}
+	return strings.Join(out, ",")
+}
+
+// MarshalJSON marshals the set as the list of its features' names
+func (s Set) MarshalJSON() ([]byte, error) {
+	out := []string{}
+	for _, f := range s.Features() {
+		out = append(out, f.String())
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON unmarshals a list of feature names
+func (s *Set) UnmarshalJSON(b []byte) error {
+	var in []string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	*s = 0
+	for _, name := range in {
+		f, err := Parse(name)
+		if err != nil {
+			return err
+		}
+		*s = s.Add(f)
+	}
+	return nil
+}
+
+// Detect returns the features the pipeline uses.
+func Detect(root *model.Root) (Set, error) {
+	if root == nil || root.Pipeline == nil {
+		return 0, errors.New("a root with a pipeline is required")
+	}
+	p := root.Pipeline
+	var s Set
+	s = s.add(Environment, len(p.Environment) > 0)
+	s = s.environment(p.Environment)
+	s = s.add(Parameters, p.Parameters != nil && len(p.Parameters.Parameters) > 0)
+	s = s.add(Triggers, p.Triggers != nil && len(p.Triggers.Triggers) > 0)
+	s = s.add(Libraries, p.Libraries != nil && len(p.Libraries.Libraries) > 0)
+	s = s.add(Tools, len(p.Tools) > 0)
+	s = s.add(PipelineOptions, p.Options != nil && len(p.Options.Options) > 0)
+	s = s.add(AgentNone, p.Agent != nil && p.Agent.Type == "none")
+	s = s.agent(p.Agent)
+	s = s.add(PipelinePost, p.Post != nil && len(p.Post.Conditions) > 0)
+	s = s.post(p.Post)
+
+	walk.Stages(p.Stages, func(stage *model.Stage, parents []*model.Stage) {
+		s = s.add(StageAgent, stage.Agent != nil)
+		s = s.agent(stage.Agent)
+		s = s.add(Environment, len(stage.Environment) > 0)
+		s = s.environment(stage.Environment)
+		s = s.add(Tools, len(stage.Tools) > 0)
+		s = s.add(StageOptions, stage.Options != nil && len(stage.Options.Options) > 0)
+		s = s.add(SequentialStages, len(stage.Stages) > 0)
+		s = s.add(ParallelStages, len(stage.Parallel) > 0)
+		s = s.add(NestedParallel, len(stage.Parallel) > 0 && len(parents) > 0)
+		s = s.add(FailFast, stage.FailFast)
+		s = s.when(stage.When)
+		s = s.input(stage.Input)
+		s = s.add(StagePost, stage.Post != nil && len(stage.Post.Conditions) > 0)
+		s = s.post(stage.Post)
+		walk.StageSteps(stage, func(step *model.AnyStep, _ []*model.TreeStep) {
+			s = s.step(step)
+		})
+		if m := stage.Matrix; m != nil {
+			s = s.Add(Matrix)
+			s = s.add(MatrixExcludes, len(m.Excludes) > 0)
+			s = s.add(StageAgent, m.Agent != nil)
+			s = s.agent(m.Agent)
+			s = s.add(Environment, len(m.Environment) > 0)
+			s = s.environment(m.Environment)
+			s = s.add(Tools, len(m.Tools) > 0)
+			s = s.add(StageOptions, m.Options != nil && len(m.Options.Options) > 0)
+			s = s.when(m.When)
+			s = s.input(m.Input)
+			s = s.add(StagePost, m.Post != nil && len(m.Post.Conditions) > 0)
+			s = s.post(m.Post)
+		}
+	})
+	return s, nil
+}
+
+// add returns the set with f added if used is true
+func (s Set) add(f Feature, used bool) Set {
+	if used {
+		return s.Add(f)
+	}
+	return s
+}
+
+func (s Set) environment(entries []*model.EnvironmentEntry) Set {
+	for _, e := range entries {
+		if e != nil && e.Value != nil && e.Value.Function != nil && e.Value.Function.Name == "credentials" {
+			s = s.Add(Credentials)
+		}
+	}
+	return s
+}
+
+func (s Set) agent(a *model.Agent) Set {
+	if a == nil {
+		return s
+	}
+	switch a.Type {
+	case "docker", "dockerfile":
+		return s.Add(DockerAgent)
+	case "kubernetes":
+		return s.Add(KubernetesAgent)
+	}
+	return s
+}
+
+func (s Set) when(w *model.When) Set {
+	if w == nil {
+		return s
+	}
+	s = s.Add(When)
+	s = s.add(BeforeAgent, w.BeforeAgent)
+	s = s.add(BeforeInput, w.BeforeInput)
+	s = s.add(BeforeOptions, w.BeforeOptions)
+	var conditions func(cs []*model.StepOrNestedWhenCondition)
+	conditions = func(cs []*model.StepOrNestedWhenCondition) {
+		for _, c := range cs {
+			switch {
+			case c == nil:
+			case c.Step != nil:
+				s = s.add(WhenExpression, c.Step.Name == "expression")
+			case c.Nested != nil:
+				s = s.Add(NestedWhen)
+				conditions(c.Nested.Children)
+			}
+		}
+	}
+	conditions(w.Conditions)
+	return s
+}
+
+func (s Set) input(in *model.Input) Set {
+	if in == nil {
+		return s
+	}
+	return s.Add(Input).add(InputParameters, in.Parameters != nil && len(in.Parameters.Parameters) > 0)
+}
+
+func (s Set) post(p *model.Post) Set {
+	walk.PostSteps(p, func(step *model.AnyStep, _ []*model.TreeStep) {
+		s = s.step(step)
+	})
+	return s
+}
+
+func (s Set) step(step *model.AnyStep) Set {
+	if step == nil || step.Step == nil {
+		return s
+	}
+	s = s.add(ScriptBlock, step.Step.Name == "script")
+	return s.add(RawGroovy, step.Step.Raw != nil)
+}
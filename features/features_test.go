@@ -0,0 +1,133 @@
+package features
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const featuresPipeline = `pipeline {
+  agent none
+  environment {
+    DEPLOY_KEY = credentials('deploy-key')
+  }
+  parameters {
+    booleanParam(name: 'RELEASE', defaultValue: false)
+  }
+  stages {
+    stage('Build') {
+      agent { docker { image 'golang:1.14' } }
+      steps {
+        sh 'make'
+      }
+    }
+    stage('Checks') {
+      stages {
+        stage('Tests') {
+          failFast true
+          parallel {
+            stage('Unit') {
+              agent any
+              steps { sh 'make test' }
+            }
+            stage('Platforms') {
+              matrix {
+                agent any
+                axes {
+                  axis {
+                    name 'OS'
+                    values 'linux', 'windows'
+                  }
+                }
+                excludes {
+                  exclude {
+                    axis {
+                      name 'OS'
+                      values 'windows'
+                    }
+                  }
+                }
+                stages {
+                  stage('Test') { steps { sh 'make test' } }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+    stage('Release') {
+      agent any
+      when {
+        beforeAgent true
+        anyOf {
+          branch 'main'
+          expression { params.RELEASE }
+        }
+      }
+      input {
+        message 'Release?'
+      }
+      steps {
+        script {
+          echo 'releasing'
+        }
+      }
+      post {
+        failure { echo 'release failed' }
+      }
+    }
+  }
+}`
+
+func TestDetect(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(featuresPipeline))
+	require.NoError(t, err)
+	s, err := Detect(root)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Feature{Environment, Credentials, Parameters, AgentNone, StageAgent, DockerAgent,
+		SequentialStages, ParallelStages, NestedParallel, FailFast, Matrix, MatrixExcludes, When, NestedWhen,
+		WhenExpression, BeforeAgent, Input, StagePost, ScriptBlock}, s.Features())
+	assert.True(t, s.Has(Matrix, Input))
+	assert.False(t, s.Has(Matrix, Triggers))
+	assert.Equal(t, Set(0).Add(Triggers), Set(0).Add(Matrix, Triggers).Missing(s))
+
+	_, err = Detect(nil)
+	assert.EqualError(t, err, "a root with a pipeline is required")
+	empty, err := Detect(&model.Root{Pipeline: &model.Pipeline{}})
+	require.NoError(t, err)
+	assert.Equal(t, Set(0), empty)
+}
+
+func TestSetJSON(t *testing.T) {
+	s := Set(0).Add(NestedParallel, Matrix, BeforeAgent)
+	assert.Equal(t, "nested-parallel,matrix,before-agent", s.String())
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["nested-parallel", "matrix", "before-agent"]`, string(b))
+	var decoded Set
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, s, decoded)
+
+	b, err = json.Marshal(Set(0))
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(b))
+	assert.EqualError(t, json.Unmarshal([]byte(`["teleport"]`), &decoded), `unknown feature "teleport"`)
+}
+
+func TestNames(t *testing.T) {
+	assert.Len(t, names, len(All()))
+	for _, f := range All() {
+		parsed, err := Parse(f.String())
+		require.NoError(t, err)
+		assert.Equal(t, f, parsed)
+	}
+	assert.Equal(t, "Feature(3)", Feature(3).String())
+}
@@ -0,0 +1,130 @@
+package features
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Plugins providing features
+const (
+	// Declarative is the Pipeline: Declarative plugin, pipeline-model-definition.
+	Declarative       = "pipeline-model-definition"
+	DockerWorkflow    = "docker-workflow"
+	Kubernetes        = "kubernetes"
+	CredentialsBinder = "credentials-binding"
+)
+
+// Requirement is a plugin a feature needs
+type Requirement struct {
+	Feature Feature `json:"feature"`
+	Plugin  string  `json:"plugin"`
+	// Version is the first version of the plugin with the feature, or empty if every version has it.
+	Version string `json:"version,omitempty"`
+}
+
+// Requirements are the plugins features need, from the plugins' changelogs. Features without a requirement are in
+// every version of Declarative.
+var Requirements = []Requirement{
+	{Feature: Libraries, Plugin: Declarative, Version: "1.1"},
+	{Feature: ParallelStages, Plugin: Declarative, Version: "1.2"},
+	{Feature: FailFast, Plugin: Declarative, Version: "1.2"},
+	{Feature: NestedWhen, Plugin: Declarative, Version: "1.2"},
+	{Feature: BeforeAgent, Plugin: Declarative, Version: "1.2.6"},
+	{Feature: Input, Plugin: Declarative, Version: "1.2.6"},
+	{Feature: InputParameters, Plugin: Declarative, Version: "1.2.6"},
+	{Feature: SequentialStages, Plugin: Declarative, Version: "1.3"},
+	{Feature: NestedParallel, Plugin: Declarative, Version: "1.3"},
+	{Feature: BeforeInput, Plugin: Declarative, Version: "1.4.0"},
+	{Feature: Matrix, Plugin: Declarative, Version: "1.5.0"},
+	{Feature: MatrixExcludes, Plugin: Declarative, Version: "1.5.0"},
+	{Feature: BeforeOptions, Plugin: Declarative, Version: "1.7.0"},
+	{Feature: DockerAgent, Plugin: DockerWorkflow},
+	{Feature: KubernetesAgent, Plugin: Kubernetes},
+	{Feature: Credentials, Plugin: CredentialsBinder},
+}
+
+// Minimum is the oldest version of a plugin a pipeline can run with
+type Minimum struct {
+	Plugin string `json:"plugin"`
+	// Version is empty if any version of the plugin will do.
+	Version string `json:"version,omitempty"`
+	// Features are the features needing the plugin, and Requiring those needing this version of it.
+	Features  []Feature `json:"features"`
+	Requiring []Feature `json:"requiring,omitempty"`
+}
+
+// Minimums returns the oldest versions of the plugins the features need, sorted by plugin. Declarative is always
+// needed.
+func (s Set) Minimums() []*Minimum {
+	byPlugin := map[string]*Minimum{Declarative: {Plugin: Declarative, Features: []Feature{}}}
+	for _, r := range Requirements {
+		if !s.Has(r.Feature) {
+			continue
+		}
+		m, ok := byPlugin[r.Plugin]
+		if !ok {
+			m = &Minimum{Plugin: r.Plugin}
+			byPlugin[r.Plugin] = m
+		}
+		m.Features = append(m.Features, r.Feature)
+		switch c := compareVersions(r.Version, m.Version); {
+		case r.Version == "":
+		case c > 0:
+			m.Version = r.Version
+			m.Requiring = []Feature{r.Feature}
+		case c == 0:
+			m.Requiring = append(m.Requiring, r.Feature)
+		}
+	}
+	out := make([]*Minimum, 0, len(byPlugin))
+	for _, m := range byPlugin {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Plugin < out[j].Plugin
+	})
+	return out
+}
+
+// Check returns the minimums the installed plugins don't meet, given their versions by short name, such as
+// {"pipeline-model-definition": "1.3.9"}: those of plugins which aren't installed or are older.
+func (s Set) Check(installed map[string]string) []*Minimum {
+	var out []*Minimum
+	for _, m := range s.Minimums() {
+		v, ok := installed[m.Plugin]
+		if !ok || compareVersions(v, m.Version) < 0 {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// compareVersions compares dotted version numbers, such as "1.2.6" and "1.10", returning -1, 0, or 1. Missing parts
+// count as 0, and so does an empty version. Qualifiers after a number, as in "1.3.9-beta", are ignored.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := versionPart(as, i), versionPart(bs, i)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	p := parts[i]
+	end := 0
+	for end < len(p) && p[end] >= '0' && p[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(p[:end])
+	return n
+}
@@ -0,0 +1,49 @@
+package features
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinimums(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(featuresPipeline))
+	require.NoError(t, err)
+	s, err := Detect(root)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Minimum{
+		{Plugin: CredentialsBinder, Features: []Feature{Credentials}},
+		{Plugin: DockerWorkflow, Features: []Feature{DockerAgent}},
+		{Plugin: Declarative, Version: "1.5.0",
+			Features: []Feature{ParallelStages, FailFast, NestedWhen, BeforeAgent, Input, SequentialStages,
+				NestedParallel, Matrix, MatrixExcludes},
+			Requiring: []Feature{Matrix, MatrixExcludes}},
+	}, s.Minimums())
+
+	assert.Equal(t, []*Minimum{{Plugin: Declarative, Features: []Feature{}}}, Set(0).Add(When).Minimums())
+}
+
+func TestCheck(t *testing.T) {
+	s := Set(0).Add(SequentialStages, BeforeAgent, KubernetesAgent)
+	missing := s.Check(map[string]string{Declarative: "1.2.9", Kubernetes: "1.21.3"})
+	require.Len(t, missing, 1)
+	assert.Equal(t, Declarative, missing[0].Plugin)
+	assert.Equal(t, "1.3", missing[0].Version)
+
+	missing = s.Check(map[string]string{Declarative: "1.3.9-beta"})
+	require.Len(t, missing, 1)
+	assert.Equal(t, Kubernetes, missing[0].Plugin)
+
+	assert.Empty(t, s.Check(map[string]string{Declarative: "1.10", Kubernetes: "1.0"}))
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.3", "1.3.0"))
+	assert.Equal(t, -1, compareVersions("1.2.6", "1.3"))
+	assert.Equal(t, 1, compareVersions("1.10", "1.9.2"))
+	assert.Equal(t, 1, compareVersions("1.0", ""))
+}
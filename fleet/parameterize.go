@@ -0,0 +1,274 @@
+// Package fleet analyzes many pipelines together, such as every Jenkinsfile in an organization, to find what they
+// have in common.
+package fleet
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"sigs.k8s.io/yaml"
+)
+
+// Parameter is a literal value which varies between the pipelines a template covers
+type Parameter struct {
+	Name string `json:"name"`
+	// Path is the JSON pointer of the value in the pipelines' JSON.
+	Path string `json:"path"`
+	// Values are the distinct values the pipelines have, in the order of the first pipeline to have each.
+	Values []interface{} `json:"values"`
+}
+
+// Template is a pipeline which several pipelines are instances of, differing only in literal values
+type Template struct {
+	// Pipelines are the names of the pipelines the template covers, sorted.
+	Pipelines  []string     `json:"pipelines"`
+	Parameters []*Parameter `json:"parameters"`
+	// Root is the template, with each parameter's values replaced by a placeholder string such as "{{ .image }}".
+	Root *model.Root `json:"root"`
+	// Values are each pipeline's values, keyed by pipeline name and then by parameter name.
+	Values map[string]map[string]interface{} `json:"values"`
+}
+
+// Parameterization is the templates a fleet of pipelines can be consolidated into
+type Parameterization struct {
+	// Templates are sorted by the number of pipelines they cover, most first, then by their first pipeline's name.
+	Templates []*Template `json:"templates"`
+	// Unique are the names of the pipelines which have no structural twin, sorted.
+	Unique []string `json:"unique,omitempty"`
+}
+
+// placeholder matches the placeholders in templates
+var placeholder = regexp.MustCompile(`^\{\{ \.([A-Za-z][A-Za-z0-9_]*) \}\}$`)
+
+// nonIdentifier matches the characters not allowed in parameter names
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// Parameterize groups pipelines which are identical apart from their literal values, such as image tags, agent
+// labels, and repository URLs, and suggests a template for each group, with the values that vary as its parameters.
+// Non-literal values, such as interpolated strings, are part of a pipeline's structure. Parameters are named after
+// where their values are, such as "Build_sh_script" for the script of a sh step in the Build stage.
+func Parameterize(pipelines map[string]*model.Root) (*Parameterization, error) {
+	names := make([]string, 0, len(pipelines))
+	for name := range pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	docs := map[string]interface{}{}
+	groups := map[string][]string{}
+	var shapes []string
+	for _, name := range names {
+		doc, err := toDoc(pipelines[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		docs[name] = doc
+		shape, err := json.Marshal(visitLiterals(doc, "", nil, func(*literal) interface{} { return nil }))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if groups[string(shape)] == nil {
+			shapes = append(shapes, string(shape))
+		}
+		groups[string(shape)] = append(groups[string(shape)], name)
+	}
+
+	out := &Parameterization{Templates: []*Template{}}
+	for _, shape := range shapes {
+		group := groups[shape]
+		if len(group) == 1 {
+			out.Unique = append(out.Unique, group[0])
+			continue
+		}
+		t, err := template(group, docs)
+		if err != nil {
+			return nil, err
+		}
+		out.Templates = append(out.Templates, t)
+	}
+	sort.SliceStable(out.Templates, func(i, j int) bool {
+		return len(out.Templates[i].Pipelines) > len(out.Templates[j].Pipelines)
+	})
+	return out, nil
+}
+
+func template(group []string, docs map[string]interface{}) (*Template, error) {
+	t := &Template{Pipelines: group, Parameters: []*Parameter{}, Values: map[string]map[string]interface{}{}}
+	values := map[string][]interface{}{}
+	for _, name := range group {
+		visitLiterals(docs[name], "", nil, func(l *literal) interface{} {
+			values[l.path] = append(values[l.path], l.value)
+			return l.value
+		})
+		t.Values[name] = map[string]interface{}{}
+	}
+
+	used := map[string]bool{}
+	doc := visitLiterals(docs[group[0]], "", nil, func(l *literal) interface{} {
+		vs := values[l.path]
+		p := &Parameter{Path: l.path}
+		for _, v := range vs {
+			if !containsValue(p.Values, v) {
+				p.Values = append(p.Values, v)
+			}
+		}
+		if len(p.Values) == 1 {
+			return l.value
+		}
+		p.Name = parameterName(l.labels, used)
+		t.Parameters = append(t.Parameters, p)
+		for i, name := range group {
+			t.Values[name][p.Name] = vs[i]
+		}
+		return "{{ ." + p.Name + " }}"
+	})
+	root, err := fromDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	t.Root = root
+	return t, nil
+}
+
+// Render returns the pipeline the template makes with the given parameter values. It returns an error if a value is
+// missing.
+func (strct *Template) Render(values map[string]interface{}) (*model.Root, error) {
+	doc, err := toDoc(strct.Root)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	doc = visitLiterals(doc, "", nil, func(l *literal) interface{} {
+		s, ok := l.value.(string)
+		if !ok {
+			return l.value
+		}
+		m := placeholder.FindStringSubmatch(s)
+		if m == nil {
+			return l.value
+		}
+		v, ok := values[m[1]]
+		if !ok {
+			missing = append(missing, m[1])
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing values for %s", strings.Join(missing, ", "))
+	}
+	return fromDoc(doc)
+}
+
+// ValuesYAML returns a pipeline's values as a YAML values file.
+func (strct *Template) ValuesYAML(pipeline string) ([]byte, error) {
+	values, ok := strct.Values[pipeline]
+	if !ok {
+		return nil, fmt.Errorf("the template doesn't cover pipeline %s", pipeline)
+	}
+	return yaml.Marshal(values)
+}
+
+// literal is a literal value in a pipeline's JSON
+type literal struct {
+	path  string
+	value interface{}
+	// labels are the names of the stages, steps, arguments, and so on enclosing the value.
+	labels []string
+}
+
+// visitLiterals copies a decoded JSON document, replacing the value of each literal argument with the result of fn
+func visitLiterals(v interface{}, path string, labels []string, fn func(*literal) interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		labels = append(labels[:len(labels):len(labels)], label(t)...)
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		// Visit in a fixed order so parameter names and their order don't change between runs.
+		sort.Strings(keys)
+		out := map[string]interface{}{}
+		for _, k := range keys {
+			e := t[k]
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			if k == "value" && t["isLiteral"] == true {
+				out[k] = fn(&literal{path: p, value: e, labels: labels})
+				continue
+			}
+			childLabels := labels
+			if k == "agent" || k == "environment" && len(labels) == 0 {
+				childLabels = append(labels[:len(labels):len(labels)], k)
+			}
+			out[k] = visitLiterals(e, p, childLabels, fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			out[i] = visitLiterals(e, fmt.Sprintf("%s/%d", path, i), labels, fn)
+		}
+		return out
+	}
+	return v
+}
+
+// label returns what identifies a JSON object in parameter names: a stage, step, or method's name, an argument or
+// environment variable's key, or a post condition
+func label(m map[string]interface{}) []string {
+	for _, k := range []string{"name", "key", "condition"} {
+		if s, ok := m[k].(string); ok && s != "" && s != "default" {
+			return []string{s}
+		}
+	}
+	return nil
+}
+
+// parameterName makes a unique parameter name from labels
+func parameterName(labels []string, used map[string]bool) string {
+	name := strings.Trim(nonIdentifier.ReplaceAllString(strings.Join(labels, "_"), "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "value_" + name
+	}
+	name = strings.TrimSuffix(name, "_")
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func toDoc(root *model.Root) (interface{}, error) {
+	b, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(b, &doc)
+	return doc, err
+}
+
+func fromDoc(doc interface{}) (*model.Root, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	root := &model.Root{}
+	if err := json.Unmarshal(b, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
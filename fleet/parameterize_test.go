@@ -0,0 +1,85 @@
+package fleet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const service = `pipeline {
+    agent { docker { image '%IMAGE%' } }
+    environment {
+        REPO = '%REPO%'
+        BRANCH = "${env.BRANCH_NAME}"
+    }
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make build'
+                git url: '%REPO%', branch: 'main'
+            }
+        }
+    }
+}`
+
+func parse(t *testing.T, replacements ...string) *model.Root {
+	root, err := parser.Parse(strings.NewReader(strings.NewReplacer(replacements...).Replace(service)))
+	require.NoError(t, err)
+	return root
+}
+
+func TestParameterize(t *testing.T) {
+	pipelines := map[string]*model.Root{
+		"billing":  parse(t, "%IMAGE%", "maven:3.8-jdk11", "%REPO%", "https://git.example.com/billing.git"),
+		"payments": parse(t, "%IMAGE%", "maven:3.9-jdk17", "%REPO%", "https://git.example.com/payments.git"),
+		"frontend": parse(t, "%IMAGE%", "node:18", "%REPO%", "https://git.example.com/frontend.git",
+			"sh 'make build'", "sh 'npm ci'\n                sh 'npm run build'"),
+	}
+	p, err := Parameterize(pipelines)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frontend"}, p.Unique)
+	require.Len(t, p.Templates, 1)
+
+	tmpl := p.Templates[0]
+	assert.Equal(t, []string{"billing", "payments"}, tmpl.Pipelines)
+	assert.Equal(t, []*Parameter{
+		{Name: "agent_image", Path: "/pipeline/agent/arguments/0/value/value",
+			Values: []interface{}{"maven:3.8-jdk11", "maven:3.9-jdk17"}},
+		{Name: "environment_REPO", Path: "/pipeline/environment/0/value/value",
+			Values: []interface{}{"https://git.example.com/billing.git", "https://git.example.com/payments.git"}},
+		{Name: "Build_git_url", Path: "/pipeline/stages/0/branches/0/steps/1/arguments/0/value/value",
+			Values: []interface{}{"https://git.example.com/billing.git", "https://git.example.com/payments.git"}},
+	}, tmpl.Parameters)
+	assert.Equal(t, map[string]interface{}{
+		"agent_image":      "maven:3.9-jdk17",
+		"environment_REPO": "https://git.example.com/payments.git",
+		"Build_git_url":    "https://git.example.com/payments.git",
+	}, tmpl.Values["payments"])
+
+	b, err := json.Marshal(tmpl.Root)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"value":"{{ .agent_image }}"`)
+	assert.Contains(t, string(b), `"value":"make build"`)
+	assert.Contains(t, string(b), `"value":"\"${env.BRANCH_NAME}\""`)
+
+	values, err := tmpl.ValuesYAML("billing")
+	require.NoError(t, err)
+	assert.Equal(t, "Build_git_url: https://git.example.com/billing.git\nagent_image: maven:3.8-jdk11\n"+
+		"environment_REPO: https://git.example.com/billing.git\n", string(values))
+	_, err = tmpl.ValuesYAML("frontend")
+	assert.Error(t, err)
+
+	for _, name := range tmpl.Pipelines {
+		rendered, err := tmpl.Render(tmpl.Values[name])
+		require.NoError(t, err)
+		model.ClearUIDs(pipelines[name])
+		assert.Equal(t, pipelines[name], rendered, name)
+	}
+	_, err = tmpl.Render(map[string]interface{}{"agent_image": "maven"})
+	assert.EqualError(t, err, "missing values for environment_REPO, Build_git_url")
+}
@@ -0,0 +1,299 @@
+package gen
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Providers of consumed environment variables, besides the IDs of the stages providing them
+const (
+	// ProviderPipeline variables are set by the pipeline's environment directive.
+	ProviderPipeline = "pipeline"
+	// ProviderJenkins variables are set by Jenkins for every build, such as BUILD_NUMBER.
+	ProviderJenkins = "jenkins"
+	// ProviderAgent variables are set by the agent's own environment, such as PATH.
+	ProviderAgent = "agent"
+)
+
+// jenkinsVariables are the variables Jenkins sets for builds, or for multibranch builds
+var jenkinsVariables = map[string]bool{
+	"BUILD_ID": true, "BUILD_NUMBER": true, "BUILD_TAG": true, "BUILD_URL": true, "BUILD_DISPLAY_NAME": true,
+	"EXECUTOR_NUMBER": true, "JENKINS_HOME": true, "JENKINS_URL": true, "JOB_NAME": true, "JOB_BASE_NAME": true,
+	"JOB_URL": true, "NODE_NAME": true, "NODE_LABELS": true, "WORKSPACE": true, "WORKSPACE_TMP": true,
+	"BRANCH_NAME": true, "BRANCH_IS_PRIMARY": true, "TAG_NAME": true, "TAG_TIMESTAMP": true, "TAG_DATE": true,
+	"CHANGE_ID": true, "CHANGE_URL": true, "CHANGE_TITLE": true, "CHANGE_AUTHOR": true,
+	"CHANGE_AUTHOR_DISPLAY_NAME": true, "CHANGE_AUTHOR_EMAIL": true, "CHANGE_TARGET": true, "CHANGE_BRANCH": true,
+	"CHANGE_FORK": true, "GIT_COMMIT": true, "GIT_PREVIOUS_COMMIT": true, "GIT_PREVIOUS_SUCCESSFUL_COMMIT": true,
+	"GIT_BRANCH": true, "GIT_LOCAL_BRANCH": true, "GIT_URL": true, "GIT_COMMITTER_NAME": true,
+	"GIT_AUTHOR_NAME": true, "GIT_COMMITTER_EMAIL": true, "GIT_AUTHOR_EMAIL": true, "STAGE_NAME": true,
+}
+
+// agentVariables are variables set by the agent's operating system
+var agentVariables = map[string]bool{"PATH": true, "HOME": true, "USER": true, "PWD": true, "SHELL": true,
+	"TMPDIR": true, "HOSTNAME": true, "LANG": true, "TEMP": true, "TMP": true, "USERPROFILE": true}
+
+// EnvVariable is an environment variable a stage sets or reads
+type EnvVariable struct {
+	Name string `json:"name"`
+	// Source is where the variable is set or read: "environment", "credentials", "withEnv", "script" for script
+	// steps, or the name of the sh, bat, powershell, or pwsh step reading it.
+	Source string `json:"source"`
+	// Provider is what sets a variable the stage reads: ProviderPipeline, ProviderJenkins, ProviderAgent, or the ID
+	// of the stage setting it. It's empty if nothing in the pipeline does, so it must come from outside.
+	Provider string `json:"provider,omitempty"`
+	// Credential is the ID of the credential bound to the variable, if any.
+	Credential string `json:"credential,omitempty"`
+}
+
+// StageContract is the environment variables a stage reads and sets
+type StageContract struct {
+	// Stage is the stage's ID, its path joined with "/".
+	Stage string `json:"stage"`
+	// Consumes are the variables the stage reads but doesn't set itself.
+	Consumes []*EnvVariable `json:"consumes,omitempty"`
+	// Produces are the variables the stage sets: its own environment entries, withEnv variables, and variables
+	// assigned to env in script steps, which stay set for later stages.
+	Produces []*EnvVariable `json:"produces,omitempty"`
+}
+
+// EnvContract is the environment variable interface of a pipeline, so systems it deploys to can check it's
+// compatible with what they provide and expect. It's built with heuristics: variables are found from literal names,
+// and references in scripts and Groovy expressions, so ones whose names are computed are missed.
+type EnvContract struct {
+	// Pipeline are the variables the pipeline's environment directive sets.
+	Pipeline []*EnvVariable   `json:"pipeline,omitempty"`
+	Stages   []*StageContract `json:"stages"`
+}
+
+var (
+	// shellReference matches $NAME and ${NAME...} references, and Groovy ${a.b} ones, to be skipped
+	shellReference = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)(\.)?`)
+	// shellAssignment matches variables assigned in shell scripts
+	shellAssignment = regexp.MustCompile(`(?m)(?:^|[\s;&|(])(?:export\s+|local\s+|readonly\s+)?([A-Za-z_][A-Za-z0-9_]*)=|\bfor\s+([A-Za-z_][A-Za-z0-9_]*)\s+in\b|\bread\s+(?:-\w+\s+)*([A-Za-z_][A-Za-z0-9_]*)`)
+	// batchReference matches %NAME% references
+	batchReference = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+	// powershellReference matches $env:NAME references
+	powershellReference = regexp.MustCompile(`(?i)\$env:([A-Za-z_][A-Za-z0-9_]*)`)
+	// groovyEnv matches env.NAME, with the operator after it to tell assignments from reads
+	groovyEnv = regexp.MustCompile(`\benv\.([A-Za-z_][A-Za-z0-9_]*)\s*(==|=~|=)?`)
+)
+
+// NewEnvContract builds the environment variable contract of a pipeline.
+func NewEnvContract(root *model.Root) (*EnvContract, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	contract := &EnvContract{Stages: []*StageContract{}}
+	pipelineVars := map[string]bool{}
+	for _, v := range environmentVariables(root.Pipeline.Environment) {
+		contract.Pipeline = append(contract.Pipeline, v)
+		pipelineVars[v.Name] = true
+	}
+	// stageVars are the variables each stage sets with its environment directive, and scriptVars those set by script
+	// steps so far, which are visible to every later stage.
+	stageVars := map[*model.Stage]map[string]bool{}
+	scriptVars := map[string]string{}
+
+	walk.Stages(root.Pipeline.Stages, func(stage *model.Stage, parents []*model.Stage) {
+		sc := &StageContract{Stage: strings.Join(walk.Path(stage, parents), "/")}
+		own := map[string]bool{}
+		stageVars[stage] = own
+		produce := func(v *EnvVariable) {
+			if !own[v.Name] {
+				own[v.Name] = true
+				sc.Produces = append(sc.Produces, v)
+			}
+		}
+		var reads []*EnvVariable
+		read := func(name string, source string) {
+			reads = append(reads, &EnvVariable{Name: name, Source: source})
+		}
+
+		for _, v := range environmentVariables(stage.Environment) {
+			produce(v)
+		}
+		for _, e := range stage.Environment {
+			if e != nil && e.Value != nil && e.Value.Function == nil && e.Value.Single != nil && !e.Value.Single.IsLiteral {
+				for _, name := range groovyReads(e.Value.Single.String()) {
+					read(name, "environment")
+				}
+			}
+		}
+		steps := func(s *model.AnyStep, _ []*model.TreeStep) {
+			switch {
+			case s.Tree != nil && s.Tree.Name == "withEnv":
+				for _, kv := range withEnvEntries(s.Tree.Arguments) {
+					produce(&EnvVariable{Name: kv[0], Source: "withEnv"})
+					for _, name := range shellReads(kv[1]) {
+						read(name, "withEnv")
+					}
+				}
+			case s.Step != nil && s.Step.Name == "script":
+				src := s.Step.Arguments.Get("scriptBlock").String()
+				for _, m := range groovyEnv.FindAllStringSubmatch(src, -1) {
+					if m[2] == "=" {
+						produce(&EnvVariable{Name: m[1], Source: "script"})
+					}
+				}
+				for _, name := range groovyReads(src) {
+					read(name, "script")
+				}
+			case s.Step != nil:
+				script := s.Step.Arguments.Get("script").String()
+				var names []string
+				switch s.Step.Name {
+				case "sh":
+					names = shellReads(script)
+				case "bat":
+					names = matches(batchReference, script)
+				case "powershell", "pwsh":
+					names = matches(powershellReference, script)
+				default:
+					return
+				}
+				for _, name := range append(groovyReads(script), names...) {
+					read(name, s.Step.Name)
+				}
+			}
+		}
+		walk.StageSteps(stage, steps)
+		walk.StagePostSteps(stage, func(string) walk.StepFunc {
+			return steps
+		})
+
+		seen := map[string]bool{}
+		for _, r := range reads {
+			if own[r.Name] || seen[r.Name] {
+				continue
+			}
+			seen[r.Name] = true
+			r.Provider = provider(r.Name, parents, stageVars, pipelineVars, scriptVars)
+			sc.Consumes = append(sc.Consumes, r)
+		}
+		for _, v := range sc.Produces {
+			if v.Source == "script" {
+				scriptVars[v.Name] = sc.Stage
+			}
+		}
+		contract.Stages = append(contract.Stages, sc)
+	})
+	return contract, nil
+}
+
+// EnvContractJSON generates the environment variable contract of a pipeline as JSON.
+func EnvContractJSON(root *model.Root) ([]byte, error) {
+	contract, err := NewEnvContract(root)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(contract, "", "  ")
+}
+
+// provider returns what sets a variable a stage reads, looking at the closest enclosing stage first
+func provider(name string, parents []*model.Stage, stageVars map[*model.Stage]map[string]bool,
+	pipelineVars map[string]bool, scriptVars map[string]string) string {
+	for i := len(parents) - 1; i >= 0; i-- {
+		if stageVars[parents[i]][name] {
+			return strings.Join(walk.Path(parents[i], parents[:i]), "/")
+		}
+	}
+	switch {
+	case pipelineVars[name]:
+		return ProviderPipeline
+	case scriptVars[name] != "":
+		return scriptVars[name]
+	case jenkinsVariables[name]:
+		return ProviderJenkins
+	case agentVariables[name]:
+		return ProviderAgent
+	}
+	return ""
+}
+
+// environmentVariables returns the variables an environment directive sets
+func environmentVariables(entries []*model.EnvironmentEntry) []*EnvVariable {
+	var vars []*EnvVariable
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		v := &EnvVariable{Name: e.Key, Source: "environment"}
+		if id, ok := e.Value.Credentials(); ok {
+			v.Source, v.Credential = "credentials", id
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// withEnvEntries returns the names and values of the variables set by a withEnv step with a list literal. Names such
+// as PATH+MAVEN, which prepend to a variable, are returned as the variable's name.
+func withEnvEntries(args *model.ArgumentList) [][2]string {
+	arg := args.Get("overrides")
+	if arg == nil {
+		return nil
+	}
+	var items []string
+	if arg.IsLiteral {
+		items = []string{arg.String()}
+	} else if e, err := groovy.ParseExpr(arg.String()); err == nil && e.Kind == groovy.ExprList {
+		for _, el := range e.Elements {
+			if el.Value != nil && el.Value.Kind == groovy.ExprString {
+				items = append(items, el.Value.Value)
+			}
+		}
+	}
+	var out [][2]string
+	for _, item := range items {
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			continue
+		}
+		name := item[:i]
+		if plus := strings.Index(name, "+"); plus > 0 {
+			name = name[:plus]
+		}
+		out = append(out, [2]string{name, item[i+1:]})
+	}
+	return out
+}
+
+// shellReads returns the variables a shell script reads and doesn't assign itself, in order of first appearance
+func shellReads(script string) []string {
+	assigned := map[string]bool{}
+	for _, m := range shellAssignment.FindAllStringSubmatch(script, -1) {
+		assigned[m[1]+m[2]+m[3]] = true
+	}
+	var names []string
+	for _, m := range shellReference.FindAllStringSubmatch(script, -1) {
+		if m[2] == "" && !assigned[m[1]] {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// groovyReads returns the variables Groovy source reads with env.NAME
+func groovyReads(src string) []string {
+	var names []string
+	for _, m := range groovyEnv.FindAllStringSubmatch(src, -1) {
+		if m[2] != "=" {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+func matches(re *regexp.Regexp, s string) []string {
+	var names []string
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
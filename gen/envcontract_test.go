@@ -0,0 +1,107 @@
+package gen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envContractPipeline = `{"pipeline": {
+  "agent": {"type": "any"},
+  "environment": [
+    {"key": "REGISTRY", "value": {"isLiteral": true, "value": "registry.example.com"}}
+  ],
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "withEnv", "arguments": {"isLiteral": false, "value": "[\"GOFLAGS=-mod=vendor\", \"PATH+GO=$HOME/go/bin\"]"},
+        "children": [
+          {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": false,
+            "value": "\"go build -o bin/app-${env.BUILD_NUMBER} && echo $GOFLAGS\""}}]}
+        ]},
+      {"name": "script", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true,
+        "value": "env.IMAGE_TAG = \"${env.REGISTRY}/app:${env.GIT_COMMIT}\""}}]}
+    ]}]},
+    {"name": "Deploy", "environment": [
+      {"key": "KUBECONFIG", "value": {"name": "credentials", "arguments": [{"isLiteral": true, "value": "kubeconfig"}]}}
+    ], "stages": [
+      {"name": "Staging", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true,
+          "value": "for f in deploy/*.yaml; do\n  ns=staging\n  kubectl --kubeconfig $KUBECONFIG -n $ns apply -f $f --set image=${IMAGE_TAG}\ndone\necho $SLACK_CHANNEL"}}]}
+      ]}]},
+      {"name": "Windows", "branches": [{"name": "default", "steps": [
+        {"name": "bat", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "echo %USERPROFILE% %TARGET%"}}]},
+        {"name": "powershell", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "Write-Host $env:IMAGE_TAG"}}]}
+      ]}]}
+    ]}
+  ]
+}}`
+
+func TestEnvContract(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(envContractPipeline), root))
+
+	contract, err := NewEnvContract(root)
+	require.NoError(t, err)
+	assert.Equal(t, []*EnvVariable{{Name: "REGISTRY", Source: "environment"}}, contract.Pipeline)
+	require.Len(t, contract.Stages, 4)
+
+	build := contract.Stages[0]
+	assert.Equal(t, "Build", build.Stage)
+	assert.Equal(t, []*EnvVariable{
+		{Name: "GOFLAGS", Source: "withEnv"},
+		{Name: "PATH", Source: "withEnv"},
+		{Name: "IMAGE_TAG", Source: "script"},
+	}, build.Produces)
+	assert.Equal(t, []*EnvVariable{
+		{Name: "HOME", Source: "withEnv", Provider: ProviderAgent},
+		{Name: "BUILD_NUMBER", Source: "sh", Provider: ProviderJenkins},
+		{Name: "REGISTRY", Source: "script", Provider: ProviderPipeline},
+		{Name: "GIT_COMMIT", Source: "script", Provider: ProviderJenkins},
+	}, build.Consumes)
+
+	deploy := contract.Stages[1]
+	assert.Equal(t, "Deploy", deploy.Stage)
+	assert.Equal(t, []*EnvVariable{{Name: "KUBECONFIG", Source: "credentials", Credential: "kubeconfig"}}, deploy.Produces)
+	assert.Empty(t, deploy.Consumes)
+
+	staging := contract.Stages[2]
+	assert.Equal(t, "Deploy/Staging", staging.Stage)
+	assert.Empty(t, staging.Produces)
+	assert.Equal(t, []*EnvVariable{
+		{Name: "KUBECONFIG", Source: "sh", Provider: "Deploy"},
+		{Name: "IMAGE_TAG", Source: "sh", Provider: "Build"},
+		{Name: "SLACK_CHANNEL", Source: "sh"},
+	}, staging.Consumes)
+
+	windows := contract.Stages[3]
+	assert.Equal(t, []*EnvVariable{
+		{Name: "USERPROFILE", Source: "bat", Provider: ProviderAgent},
+		{Name: "TARGET", Source: "bat"},
+		{Name: "IMAGE_TAG", Source: "powershell", Provider: "Build"},
+	}, windows.Consumes)
+}
+
+func TestEnvContractJSON(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(tasksPipeline), root))
+
+	out, err := EnvContractJSON(root)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "pipeline": [
+    {"name": "GOFLAGS", "source": "environment"},
+    {"name": "TOKEN", "source": "credentials", "credential": "github-token"}
+  ],
+  "stages": [
+    {"stage": "Build"},
+    {"stage": "Unit Tests", "consumes": [{"name": "PKGS", "source": "sh"}],
+      "produces": [{"name": "CGO_ENABLED", "source": "environment"}]}
+  ]
+}`, string(out))
+
+	_, err = EnvContractJSON(&model.Root{})
+	assert.EqualError(t, err, "a root with a pipeline is required")
+}
@@ -0,0 +1,172 @@
+// Package gen generates source code and build files from pipeline models.
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+const modelImport = "github.com/abayer/go-jenkinsfile/model"
+
+// GoBuilder generates a Go source file containing a Pipeline function which constructs the given model.Root, so that a
+// pipeline can be maintained as Go code from then on.
+func GoBuilder(root *model.Root) ([]byte, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+
+	g := &goBuilder{helpers: make(map[string]bool)}
+	body := &bytes.Buffer{}
+	if err := g.value(body, reflect.ValueOf(root)); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Generated by go-jenkinsfile from an existing pipeline definition.\n\n")
+	buf.WriteString("package pipeline\n\n")
+	fmt.Fprintf(buf, "import %q\n\n", modelImport)
+	buf.WriteString("// Pipeline returns the pipeline definition.\n")
+	buf.WriteString("func Pipeline() *model.Root {\n\treturn ")
+	buf.Write(body.Bytes())
+	buf.WriteString("\n}\n")
+
+	var helpers []string
+	for h := range g.helpers {
+		helpers = append(helpers, h)
+	}
+	sort.Strings(helpers)
+	for _, h := range helpers {
+		fmt.Fprintf(buf, "\nfunc %sPtr(v %s) *%s {\n\treturn &v\n}\n", h, h, h)
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated source: %v", err)
+	}
+	return out, nil
+}
+
+type goBuilder struct {
+	helpers map[string]bool
+}
+
+func (g *goBuilder) value(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return nil
+		}
+		elem := v.Elem()
+		if elem.Kind() == reflect.Struct {
+			buf.WriteString("&")
+			return g.value(buf, elem)
+		}
+		name := elem.Type().Name()
+		g.helpers[name] = true
+		buf.WriteString(name + "Ptr(")
+		if err := g.value(buf, elem); err != nil {
+			return err
+		}
+		buf.WriteString(")")
+	case reflect.Struct:
+		buf.WriteString(typeName(v.Type()) + "{\n")
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || v.Field(i).IsZero() {
+				continue
+			}
+			buf.WriteString(field.Name + ": ")
+			if err := g.value(buf, v.Field(i)); err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return nil
+		}
+		buf.WriteString(typeName(v.Type()) + "{")
+		for i := 0; i < v.Len(); i++ {
+			buf.WriteString("\n")
+			if err := g.elem(buf, v.Index(i)); err != nil {
+				return err
+			}
+			buf.WriteString(",")
+		}
+		if v.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}")
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		buf.WriteString(typeName(v.Type()) + "{")
+		for _, k := range keys {
+			buf.WriteString("\n" + strconv.Quote(k.String()) + ": ")
+			if err := g.elem(buf, v.MapIndex(k)); err != nil {
+				return err
+			}
+			buf.WriteString(",")
+		}
+		if len(keys) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}")
+	case reflect.String:
+		buf.WriteString(strconv.Quote(v.String()))
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Float64:
+		buf.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
+
+// elem writes a slice or map element, eliding the redundant "&model.X" prefix the composite literal already implies.
+func (g *goBuilder) elem(buf *bytes.Buffer, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		inner := &bytes.Buffer{}
+		if err := g.value(inner, v.Elem()); err != nil {
+			return err
+		}
+		buf.Write(bytes.TrimPrefix(inner.Bytes(), []byte(typeName(v.Elem().Type()))))
+		return nil
+	}
+	return g.value(buf, v)
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	}
+	if t.PkgPath() == modelImport {
+		return "model." + t.Name()
+	}
+	return t.Name()
+}
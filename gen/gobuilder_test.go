@@ -0,0 +1,64 @@
+package gen
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, path string) *model.Root {
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestGoBuilder(t *testing.T) {
+	root := loadRoot(t, filepath.Join("..", "model", "testdata", "json", "simpleParameters.json"))
+
+	out, err := GoBuilder(root)
+	require.NoError(t, err)
+
+	src := string(out)
+	assert.Contains(t, src, "package pipeline")
+	assert.Contains(t, src, "func Pipeline() *model.Root {")
+	assert.Contains(t, src, `Agent: &model.Agent{
+				Type: "none",
+			},`)
+	assert.Contains(t, src, `AsBool: boolPtr(true)`)
+	assert.Contains(t, src, "func boolPtr(v bool) *bool {")
+	assert.NotContains(t, src, "float64Ptr")
+}
+
+func TestGoBuilderParsesForAllFixtures(t *testing.T) {
+	testBase := filepath.Join("..", "model", "testdata", "json")
+	err := filepath.Walk(testBase, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		name, _ := filepath.Rel(testBase, path)
+		t.Run(strings.TrimSuffix(name, ".json"), func(t *testing.T) {
+			out, err := GoBuilder(loadRoot(t, path))
+			require.NoError(t, err)
+			_, err = parser.ParseFile(token.NewFileSet(), "pipeline.go", out, parser.AllErrors)
+			assert.NoError(t, err)
+		})
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestGoBuilderRequiresPipeline(t *testing.T) {
+	_, err := GoBuilder(&model.Root{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/abayer/go-jenkinsfile/extract"
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// task is the commands and environment for a single stage, independent of the build tool it's rendered for
+type task struct {
+	name     string
+	stage    string
+	env      map[string]string
+	secrets  map[string]string
+	commands []string
+}
+
+// Makefile generates a GNU Makefile with one target per stage containing sh steps, plus an "all" target running them
+// in pipeline order. Literal environment variables are exported in each target; credentials are left for the caller
+// to provide and noted in a comment.
+func Makefile(root *model.Root) ([]byte, error) {
+	tasks, err := stageTasks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Generated by go-jenkinsfile from the pipeline's stages.\n")
+	buf.WriteString("SHELL := /bin/sh\n.SHELLFLAGS := -ec\n.ONESHELL:\n\n")
+	names := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		names = append(names, t.name)
+	}
+	fmt.Fprintf(buf, ".PHONY: all %s\nall: %s\n", strings.Join(names, " "), strings.Join(names, " "))
+
+	for _, t := range tasks {
+		fmt.Fprintf(buf, "\n# Stage: %s\n", t.stage)
+		for _, k := range sortedKeys(t.secrets) {
+			fmt.Fprintf(buf, "# %s must be set to the value of credential %q\n", k, t.secrets[k])
+		}
+		fmt.Fprintf(buf, "%s:\n", t.name)
+		for _, k := range sortedKeys(t.env) {
+			fmt.Fprintf(buf, "\texport %s=%s\n", k, makeEscape(shellQuote(t.env[k])))
+		}
+		for _, cmd := range t.commands {
+			for _, line := range strings.Split(cmd, "\n") {
+				fmt.Fprintf(buf, "\t%s\n", makeEscape(line))
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Taskfile generates a Taskfile (https://taskfile.dev) with one task per stage containing sh steps, plus a "default"
+// task running them in pipeline order.
+func Taskfile(root *model.Root) ([]byte, error) {
+	tasks, err := stageTasks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	type taskCmd struct {
+		Task string `json:"task,omitempty"`
+		Cmd  string `json:"cmd,omitempty"`
+	}
+	type taskDef struct {
+		Desc string            `json:"desc,omitempty"`
+		Env  map[string]string `json:"env,omitempty"`
+		Cmds []taskCmd         `json:"cmds"`
+	}
+	out := struct {
+		Version string              `json:"version"`
+		Tasks   map[string]*taskDef `json:"tasks"`
+	}{Version: "3", Tasks: map[string]*taskDef{}}
+
+	all := &taskDef{Desc: "Run all stages in pipeline order"}
+	for _, t := range tasks {
+		def := &taskDef{Desc: t.stage, Env: t.env}
+		for _, cmd := range t.commands {
+			def.Cmds = append(def.Cmds, taskCmd{Cmd: cmd})
+		}
+		out.Tasks[t.name] = def
+		all.Cmds = append(all.Cmds, taskCmd{Task: t.name})
+	}
+	out.Tasks["default"] = all
+
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("# Generated by go-jenkinsfile from the pipeline's stages.\n"), b...), nil
+}
+
+func stageTasks(root *model.Root) ([]*task, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	scripts, err := extract.Scripts(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*task
+	seen := make(map[string]int)
+	walk.Stages(root.Pipeline.Stages, func(stage *model.Stage, parents []*model.Stage) {
+		path := walk.Path(stage, parents)
+		t := &task{stage: strings.Join(path, " / ")}
+		for _, s := range scripts {
+			if s.Step == "sh" && s.Post == "" && equalPaths(s.StagePath, path) {
+				t.commands = append(t.commands, s.Body)
+			}
+		}
+		if len(t.commands) == 0 {
+			return
+		}
+		t.name = targetName(path)
+		if n := seen[t.name]; n > 0 {
+			seen[t.name]++
+			t.name = fmt.Sprintf("%s-%d", t.name, n+1)
+		} else {
+			seen[t.name] = 1
+		}
+		t.env, t.secrets = literalEnv(root.Pipeline.Environment, nil, nil)
+		for _, p := range parents {
+			t.env, t.secrets = literalEnv(p.Environment, t.env, t.secrets)
+		}
+		t.env, t.secrets = literalEnv(stage.Environment, t.env, t.secrets)
+		tasks = append(tasks, t)
+	})
+	return tasks, nil
+}
+
+func literalEnv(entries []*model.EnvironmentEntry, env map[string]string, secrets map[string]string) (map[string]string, map[string]string) {
+	for _, e := range entries {
+		if e == nil || e.Value == nil {
+			continue
+		}
+		if e.Value.Function != nil {
+			if id, ok := e.Value.Credentials(); ok {
+				if secrets == nil {
+					secrets = make(map[string]string)
+				}
+				secrets[e.Key] = id
+			}
+			continue
+		}
+		if e.Value.Single != nil && e.Value.Single.IsLiteral {
+			if env == nil {
+				env = make(map[string]string)
+			}
+			env[e.Key] = e.Value.Single.String()
+		}
+	}
+	return env, secrets
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var nonTarget = regexp.MustCompile(`[^a-z0-9]+`)
+
+func targetName(path []string) string {
+	name := strings.Trim(nonTarget.ReplaceAllString(strings.ToLower(strings.Join(path, "-")), "-"), "-")
+	if name == "" || name == "all" || name == "default" {
+		name = "stage-" + name
+	}
+	return strings.TrimSuffix(name, "-")
+}
+
+func makeEscape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
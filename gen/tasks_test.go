@@ -0,0 +1,90 @@
+package gen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tasksPipeline = `{"pipeline": {
+  "agent": {"type": "any"},
+  "environment": [
+    {"key": "GOFLAGS", "value": {"isLiteral": true, "value": "-mod=vendor"}},
+    {"key": "TOKEN", "value": {"name": "credentials", "arguments": [{"isLiteral": true, "value": "github-token"}]}}
+  ],
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make build"}}]}
+    ]}]},
+    {"name": "Unit Tests", "environment": [{"key": "CGO_ENABLED", "value": {"isLiteral": true, "value": "0"}}],
+      "branches": [{"name": "default", "steps": [
+        {"name": "echo", "arguments": [{"key": "message", "value": {"isLiteral": true, "value": "testing"}}]},
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "go test $PKGS\ngo vet"}}]}
+      ]}]}
+  ]
+}}`
+
+func TestMakefile(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(tasksPipeline), root))
+
+	out, err := Makefile(root)
+	require.NoError(t, err)
+	assert.Equal(t, `# Generated by go-jenkinsfile from the pipeline's stages.
+SHELL := /bin/sh
+.SHELLFLAGS := -ec
+.ONESHELL:
+
+.PHONY: all build unit-tests
+all: build unit-tests
+
+# Stage: Build
+# TOKEN must be set to the value of credential "github-token"
+build:
+	export GOFLAGS='-mod=vendor'
+	make build
+
+# Stage: Unit Tests
+# TOKEN must be set to the value of credential "github-token"
+unit-tests:
+	export CGO_ENABLED='0'
+	export GOFLAGS='-mod=vendor'
+	go test $$PKGS
+	go vet
+`, string(out))
+}
+
+func TestTaskfile(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(tasksPipeline), root))
+
+	out, err := Taskfile(root)
+	require.NoError(t, err)
+	assert.Equal(t, `# Generated by go-jenkinsfile from the pipeline's stages.
+tasks:
+  build:
+    cmds:
+    - cmd: make build
+    desc: Build
+    env:
+      GOFLAGS: -mod=vendor
+  default:
+    cmds:
+    - task: build
+    - task: unit-tests
+    desc: Run all stages in pipeline order
+  unit-tests:
+    cmds:
+    - cmd: |-
+        go test $PKGS
+        go vet
+    desc: Unit Tests
+    env:
+      CGO_ENABLED: "0"
+      GOFLAGS: -mod=vendor
+version: "3"
+`, string(out))
+}
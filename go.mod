@@ -4,5 +4,6 @@ go 1.14
 
 require (
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v2 v2.2.8
 	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,123 @@
+// Package groovyq quotes strings as Groovy string literals, for generators which embed values in Groovy source such
+// as Jenkinsfiles. Every function returns a literal which evaluates to exactly the given string: nothing is
+// interpolated unless requested.
+package groovyq
+
+import (
+	"strings"
+)
+
+// Quote returns s as a single-quoted string, or a triple-single-quoted string if it contains line breaks, which is
+// how Jenkinsfiles conventionally quote literal values.
+func Quote(s string) string {
+	if strings.Contains(s, "\n") {
+		return TripleSingle(s)
+	}
+	return Single(s)
+}
+
+var singleEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`)
+
+// Single returns s as a single-quoted string, which is never interpolated.
+func Single(s string) string {
+	return "'" + singleEscaper.Replace(s) + "'"
+}
+
+var doubleEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+
+// Double returns s as a double-quoted string, with dollar signs escaped so it is not interpolated.
+func Double(s string) string {
+	return `"` + doubleEscaper.Replace(s) + `"`
+}
+
+var gstringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// GString returns s as a double-quoted string whose ${expression} and $name placeholders are interpolated when the
+// Groovy runs. Only use it for strings which are meant to contain placeholders.
+func GString(s string) string {
+	return `"` + gstringEscaper.Replace(s) + `"`
+}
+
+// TripleSingle returns s as a triple-single-quoted string, keeping line breaks as they are. It is never interpolated.
+func TripleSingle(s string) string {
+	return "'''" + escapeTriple(s, '\'', false) + "'''"
+}
+
+// TripleDouble returns s as a triple-double-quoted string, keeping line breaks as they are, with dollar signs escaped
+// so it is not interpolated.
+func TripleDouble(s string) string {
+	return `"""` + escapeTriple(s, '"', true) + `"""`
+}
+
+// escapeTriple escapes backslashes, and quote characters which would otherwise end a triple-quoted string: runs of
+// three or more, and any at the end of the string.
+func escapeTriple(s string, quote byte, dollar bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '$' && dollar:
+			b.WriteString(`\$`)
+		case c == quote:
+			n := i
+			for n < len(s) && s[n] == quote {
+				n++
+			}
+			run := s[i:n]
+			if n-i >= 3 || n == len(s) {
+				run = strings.Repeat(`\`+string(quote), n-i)
+			}
+			b.WriteString(run)
+			i = n - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// EscapeDollars escapes the dollar signs in s, for embedding literal text in a GString.
+func EscapeDollars(s string) string {
+	return strings.ReplaceAll(s, "$", `\$`)
+}
+
+// IsIdentifier returns true if s can be written as a bare Groovy identifier, such as a map key or named argument,
+// without quoting.
+func IsIdentifier(s string) bool {
+	if s == "" || keywords[s] {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		letter := c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns s as a map key or named argument: bare if it is an identifier, otherwise quoted.
+func Key(s string) string {
+	if IsIdentifier(s) {
+		return s
+	}
+	return Single(s)
+}
+
+// keywords are Groovy's reserved words, which can't be used as bare identifiers
+var keywords = map[string]bool{
+	"abstract": true, "as": true, "assert": true, "boolean": true, "break": true, "byte": true, "case": true,
+	"catch": true, "char": true, "class": true, "const": true, "continue": true, "def": true, "default": true,
+	"do": true, "double": true, "else": true, "enum": true, "extends": true, "false": true, "final": true,
+	"finally": true, "float": true, "for": true, "goto": true, "if": true, "implements": true, "import": true,
+	"in": true, "instanceof": true, "int": true, "interface": true, "long": true, "native": true, "new": true,
+	"null": true, "package": true, "private": true, "protected": true, "public": true, "return": true,
+	"short": true, "static": true, "strictfp": true, "super": true, "switch": true, "synchronized": true,
+	"this": true, "threadsafe": true, "throw": true, "throws": true, "trait": true, "transient": true, "true": true,
+	"try": true, "var": true, "void": true, "volatile": true, "while": true,
+}
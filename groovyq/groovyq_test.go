@@ -0,0 +1,47 @@
+package groovyq
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoting(t *testing.T) {
+	assert.Equal(t, `'it\'s C:\\tmp'`, Single(`it's C:\tmp`))
+	assert.Equal(t, `"cost: \$5 \"each\""`, Double(`cost: $5 "each"`))
+	assert.Equal(t, `"hello ${env.USER}\n"`, GString("hello ${env.USER}\n"))
+	assert.Equal(t, "'''a\nb'''", Quote("a\nb"))
+	assert.Equal(t, "'a b'", Quote("a b"))
+	assert.Equal(t, `'''x''y\'\'\'z\''''`, TripleSingle(`x''y'''z'`))
+	assert.Equal(t, "\"\"\"echo \\$HOME\n\\\"\"\"\"", TripleDouble("echo $HOME\n\""))
+	assert.Equal(t, `a\$b`, EscapeDollars("a$b"))
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "script", Key("script"))
+	assert.Equal(t, "'if'", Key("if"))
+	assert.Equal(t, "'my-key'", Key("my-key"))
+	assert.Equal(t, "'1st'", Key("1st"))
+	assert.False(t, IsIdentifier(""))
+	assert.True(t, IsIdentifier("_a1$"))
+}
+
+// TestRoundTrip checks that the Groovy lexer decodes each quoted string back to the original.
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"", "plain", `back\slash`, "it's", `"quoted"`, "$HOME and ${x}", "multi\nline\r\n", "'''", `'`, `''`,
+		`trailing '`, `"""`, `trailing "`, "tab\there", `\'`, `\$`, "'\n'",
+	}
+	for _, quote := range []func(string) string{Quote, Single, Double, TripleSingle, TripleDouble} {
+		for _, in := range inputs {
+			tokens, err := groovy.Lex(quote(in))
+			require.NoError(t, err, quote(in))
+			require.Len(t, tokens, 2, quote(in))
+			assert.Equal(t, groovy.String, tokens[0].Kind, quote(in))
+			assert.Equal(t, in, tokens[0].Value, quote(in))
+			assert.False(t, tokens[0].Interpolated, quote(in))
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package groovy is a lexer and parser for the subset of Groovy used by Jenkinsfiles: nested method calls with
+// closures, as in pipeline DSLs, plus assignments. Anything else, such as control flow and variable declarations, is
+// kept as raw source text so callers can preserve or report it.
+package groovy
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind is the kind of a token
+type TokenKind int
+
+const (
+	// EOF is the end of the input
+	EOF TokenKind = iota
+	// Newline is one or more line breaks
+	Newline
+	// Ident is an identifier or keyword
+	Ident
+	// String is a string literal of any quoting style
+	String
+	// Number is a numeric literal
+	Number
+	// Punct is an operator or punctuation
+	Punct
+)
+
+// Pos is a position in the source
+type Pos struct {
+	// Offset is the byte offset, and Line and Column the 1-based line and byte column.
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Token is a single token
+type Token struct {
+	Kind TokenKind
+	// Text is the token's source text.
+	Text string
+	// Value is the decoded contents of a String token.
+	Value string
+	// Quote is the delimiter of a String token: ', ", ''', """, or /.
+	Quote string
+	// Interpolated is true for double-quoted and slashy strings containing ${} or $name placeholders.
+	Interpolated bool
+	Pos          Pos
+	// End is the byte offset after the token.
+	End int
+}
+
+// Error is a syntax error
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// operators are the multi-character operators, longest first
+var operators = []string{"==~", "<=>", "**=", "?.", "*.", ".&", "?:", "->", "==", "!=", "<=", ">=", "&&", "||", "=~",
+	"++", "--", "+=", "-=", "*=", "/=", "<<", ">>", "..", "::", "**"}
+
+type lexer struct {
+	src    string
+	offset int
+	line   int
+	col    int
+	tokens []*Token
+}
+
+// Lex splits src into tokens. Comments are dropped, and consecutive line breaks are collapsed into a single Newline
+// token.
+func Lex(src string) ([]*Token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	if strings.HasPrefix(src, "#!") {
+		for l.offset < len(src) && src[l.offset] != '\n' {
+			l.advance(1)
+		}
+	}
+	for {
+		if err := l.skipSpace(); err != nil {
+			return nil, err
+		}
+		if l.offset >= len(l.src) {
+			l.emit(EOF, l.pos(), "")
+			return l.tokens, nil
+		}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Offset: l.offset, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.offset < len(l.src); i++ {
+		if l.src[l.offset] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.offset++
+	}
+}
+
+func (l *lexer) emit(kind TokenKind, start Pos, value string) *Token {
+	t := &Token{Kind: kind, Text: l.src[start.Offset:l.offset], Value: value, Pos: start, End: l.offset}
+	l.tokens = append(l.tokens, t)
+	return t
+}
+
+func (l *lexer) last() *Token {
+	if len(l.tokens) == 0 {
+		return nil
+	}
+	return l.tokens[len(l.tokens)-1]
+}
+
+// skipSpace skips whitespace and comments, emitting a Newline token for line breaks
+func (l *lexer) skipSpace() error {
+	for l.offset < len(l.src) {
+		c := l.src[l.offset]
+		switch {
+		case c == '\n':
+			start := l.pos()
+			l.advance(1)
+			if last := l.last(); last != nil && last.Kind != Newline {
+				l.emit(Newline, start, "")
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			l.advance(1)
+		case c == '\\' && strings.HasPrefix(l.src[l.offset:], "\\\n"):
+			// Line continuation
+			l.advance(2)
+		case strings.HasPrefix(l.src[l.offset:], "//"):
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.advance(1)
+			}
+		case strings.HasPrefix(l.src[l.offset:], "/*"):
+			start := l.pos()
+			end := strings.Index(l.src[l.offset+2:], "*/")
+			if end < 0 {
+				return &Error{Pos: start, Msg: "unterminated comment"}
+			}
+			l.advance(end + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) next() error {
+	start := l.pos()
+	rest := l.src[l.offset:]
+	c := rest[0]
+	switch {
+	case isIdentStart(c):
+		n := 0
+		for n < len(rest) && isIdentPart(rest[n]) {
+			n++
+		}
+		l.advance(n)
+		l.emit(Ident, start, "")
+	case c >= '0' && c <= '9':
+		l.advance(numberLength(rest))
+		l.emit(Number, start, "")
+	case c == '\'' || c == '"':
+		return l.lexString(start)
+	case c == '/' && l.slashyAllowed():
+		return l.lexString(start)
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				l.advance(len(op))
+				l.emit(Punct, start, "")
+				return nil
+			}
+		}
+		_, size := utf8.DecodeRuneInString(rest)
+		l.advance(size)
+		l.emit(Punct, start, "")
+	}
+	return nil
+}
+
+// slashyAllowed returns true if a / at the current position starts a slashy string rather than being division
+func (l *lexer) slashyAllowed() bool {
+	last := l.last()
+	if last == nil || last.Kind == Newline {
+		return true
+	}
+	if last.Kind != Punct {
+		return false
+	}
+	switch last.Text {
+	case ")", "]", "}":
+		return false
+	}
+	return true
+}
+
+func numberLength(s string) int {
+	n := 0
+	for n < len(s) && (s[n] >= '0' && s[n] <= '9' || s[n] == '_') {
+		n++
+	}
+	if n+1 < len(s) && s[n] == '.' && s[n+1] >= '0' && s[n+1] <= '9' {
+		n++
+		for n < len(s) && (s[n] >= '0' && s[n] <= '9' || s[n] == '_') {
+			n++
+		}
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if m < len(s) && s[m] >= '0' && s[m] <= '9' {
+			n = m
+			for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+				n++
+			}
+		}
+	}
+	if n < len(s) && strings.IndexByte("lLgGiIdDfF", s[n]) >= 0 {
+		n++
+	}
+	return n
+}
+
+func (l *lexer) lexString(start Pos) error {
+	rest := l.src[l.offset:]
+	quote := rest[:1]
+	if strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''") {
+		quote = rest[:3]
+	}
+	l.advance(len(quote))
+	interpolating := quote[0] == '"' || quote == "/"
+	var value strings.Builder
+	interpolated := false
+	for {
+		if l.offset >= len(l.src) {
+			return &Error{Pos: start, Msg: "unterminated string"}
+		}
+		rest = l.src[l.offset:]
+		if strings.HasPrefix(rest, quote) {
+			l.advance(len(quote))
+			break
+		}
+		c := rest[0]
+		switch {
+		case c == '\n' && len(quote) == 1 && quote != "/":
+			return &Error{Pos: start, Msg: "unterminated string"}
+		case c == '\\' && len(rest) > 1:
+			if quote == "/" {
+				// Slashy strings only escape the slash itself.
+				if rest[1] == '/' {
+					value.WriteByte('/')
+				} else {
+					value.WriteString(rest[:2])
+				}
+				l.advance(2)
+				continue
+			}
+			value.WriteString(unescape(rest[1]))
+			l.advance(2)
+		case c == '$' && interpolating && len(rest) > 1 && rest[1] == '{':
+			interpolated = true
+			n, err := placeholderLength(rest)
+			if err != nil {
+				return &Error{Pos: l.pos(), Msg: err.Error()}
+			}
+			value.WriteString(rest[:n])
+			l.advance(n)
+		case c == '$' && interpolating && len(rest) > 1 && isIdentStart(rest[1]) && rest[1] != '$':
+			interpolated = true
+			value.WriteByte('$')
+			l.advance(1)
+		default:
+			value.WriteByte(c)
+			l.advance(1)
+		}
+	}
+	t := l.emit(String, start, value.String())
+	t.Quote = quote
+	t.Interpolated = interpolated
+	return nil
+}
+
+// placeholderLength returns the length of the ${...} placeholder at the start of s, allowing nested braces and
+// strings
+func placeholderLength(s string) (int, error) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case '\'', '"':
+			q := s[i]
+			for i++; i < len(s) && s[i] != q; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated placeholder")
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case 'b':
+		return "\b"
+	case 'f':
+		return "\f"
+	case '\n':
+		return ""
+	}
+	return string(c)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
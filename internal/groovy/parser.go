@@ -0,0 +1,558 @@
+package groovy
+
+import (
+	"strings"
+)
+
+// Statement is a statement in a block: a *Call, *Assign, or *Raw
+type Statement interface {
+	Position() Pos
+	Source() string
+}
+
+// Block is a closure body or the top level of a script
+type Block struct {
+	Statements []Statement
+	// Raw is the source between the braces, or the whole script for the top level.
+	Raw string
+	Pos Pos
+}
+
+// Call is a method call statement or expression, such as `sh 'make'`, `stage('Build') { ... }`, or `agent any`.
+type Call struct {
+	Name string
+	Args []*Arg
+	// Closure is the trailing closure, if any.
+	Closure *Block
+	// Parens is true if the arguments were enclosed in parentheses.
+	Parens bool
+	Raw    string
+	Pos    Pos
+}
+
+// Assign is an assignment to a name, as in environment blocks
+type Assign struct {
+	Name  string
+	Value *Expr
+	Raw   string
+	Pos   Pos
+}
+
+// Raw is a statement outside the supported subset, kept as source text
+type Raw struct {
+	Text string
+	Pos  Pos
+}
+
+// Position returns the position of the statement
+func (c *Call) Position() Pos { return c.Pos }
+
+// Position returns the position of the statement
+func (a *Assign) Position() Pos { return a.Pos }
+
+// Position returns the position of the statement
+func (r *Raw) Position() Pos { return r.Pos }
+
+// Source returns the source text of the statement
+func (c *Call) Source() string { return c.Raw }
+
+// Source returns the source text of the statement
+func (a *Assign) Source() string { return a.Raw }
+
+// Source returns the source text of the statement
+func (r *Raw) Source() string { return r.Text }
+
+// Arg is a positional or named argument, or an element of a list or map
+type Arg struct {
+	// Key is the name of a named argument or map entry.
+	Key   string
+	Value *Expr
+}
+
+// ExprKind is the kind of an expression
+type ExprKind int
+
+const (
+	// ExprRaw is any expression outside the supported subset
+	ExprRaw ExprKind = iota
+	// ExprString is a string literal. Interpolated GStrings are also ExprString, with Interpolated set.
+	ExprString
+	// ExprNumber is a numeric literal
+	ExprNumber
+	// ExprBool is true or false
+	ExprBool
+	// ExprNull is null
+	ExprNull
+	// ExprIdent is a bare name or dotted property reference, such as scm or env.BRANCH_NAME
+	ExprIdent
+	// ExprList is a list literal
+	ExprList
+	// ExprMap is a map literal
+	ExprMap
+	// ExprCall is a method call, such as credentials('id')
+	ExprCall
+	// ExprClosure is a closure literal
+	ExprClosure
+)
+
+// Expr is an expression
+type Expr struct {
+	Kind ExprKind
+	// Raw is the expression's source text.
+	Raw string
+	// Value is the decoded contents of a string, or the source of a number, boolean, or name.
+	Value        string
+	Interpolated bool
+	// Elements are the entries of a list or map.
+	Elements []*Arg
+	Call     *Call
+	Closure  *Block
+	Pos      Pos
+}
+
+// keywords start statements which are kept as Raw
+var keywords = map[string]bool{
+	"def": true, "if": true, "else": true, "for": true, "while": true, "do": true, "return": true, "try": true,
+	"catch": true, "finally": true, "switch": true, "case": true, "default": true, "import": true, "package": true,
+	"class": true, "interface": true, "enum": true, "throw": true, "break": true, "continue": true, "final": true,
+	"static": true, "private": true, "public": true, "protected": true, "var": true, "assert": true, "new": true,
+	"this": true, "super": true, "synchronized": true,
+}
+
+// continuations are tokens which continue a statement onto the next line
+var continuations = map[string]bool{"else": true, "catch": true, "finally": true, ".": true, "?.": true, "*.": true}
+
+type parser struct {
+	src    string
+	tokens []*Token
+	i      int
+}
+
+// Parse parses a script.
+func Parse(src string) (*Block, error) {
+	tokens, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+	block, err := p.block(Pos{Line: 1, Column: 1}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.Kind != EOF {
+		return nil, &Error{Pos: t.Pos, Msg: "unexpected " + describe(t)}
+	}
+	block.Raw = src
+	return block, nil
+}
+
+// ParseExpr parses a single expression, such as the Groovy source of a non-literal argument.
+func ParseExpr(src string) (*Expr, error) {
+	tokens, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+	p.skipNewlines()
+	e, err := p.expr(true)
+	if err != nil {
+		return nil, err
+	}
+	p.skipNewlines()
+	if t := p.peek(); t.Kind != EOF {
+		return nil, &Error{Pos: t.Pos, Msg: "unexpected " + describe(t)}
+	}
+	return e, nil
+}
+
+func (p *parser) peek() *Token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) peekAt(n int) *Token {
+	if p.i+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.i+n]
+}
+
+func (p *parser) next() *Token {
+	t := p.tokens[p.i]
+	if t.Kind != EOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().Kind == Newline || p.peek().Text == ";" {
+		p.i++
+	}
+}
+
+// text returns the source from the start of one token to the end of the token before the current one
+func (p *parser) text(from int) string {
+	if p.i <= from {
+		return ""
+	}
+	return p.src[p.tokens[from].Pos.Offset:p.tokens[p.i-1].End]
+}
+
+func isPunct(t *Token, text string) bool {
+	return t.Kind == Punct && t.Text == text
+}
+
+func describe(t *Token) string {
+	switch t.Kind {
+	case EOF:
+		return "end of input"
+	case Newline:
+		return "line break"
+	}
+	return "\"" + t.Text + "\""
+}
+
+// block parses statements up to a closing brace or the end of the input. start is the offset the block's raw source
+// starts at.
+func (p *parser) block(pos Pos, start int) (*Block, error) {
+	b := &Block{Pos: pos}
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.Kind == EOF || isPunct(t, "}") {
+			b.Raw = p.src[start:t.Pos.Offset]
+			return b, nil
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		b.Statements = append(b.Statements, s)
+	}
+}
+
+// closure parses a closure, with the current token being its opening brace
+func (p *parser) closure() (*Block, error) {
+	open := p.next()
+	b, err := p.block(open.Pos, open.End)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); !isPunct(t, "}") {
+		return nil, &Error{Pos: open.Pos, Msg: "unclosed {"}
+	}
+	return b, nil
+}
+
+func (p *parser) statement() (Statement, error) {
+	start := p.i
+	t := p.peek()
+	if t.Kind != Ident || keywords[t.Text] {
+		return p.raw(start)
+	}
+	name := p.dottedName()
+	next := p.peek()
+	switch {
+	case isPunct(next, "(") || isPunct(next, "{"):
+		call, err := p.call(start, name)
+		if err != nil {
+			return nil, err
+		}
+		if !p.atStatementEnd() {
+			// The call is part of a larger expression, such as a method chain.
+			p.i = start
+			return p.raw(start)
+		}
+		return call, nil
+	case isPunct(next, "="):
+		p.next()
+		value, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		if !p.atStatementEnd() {
+			p.i = start
+			return p.raw(start)
+		}
+		return &Assign{Name: name, Value: value, Raw: p.text(start), Pos: t.Pos}, nil
+	case p.atStatementEnd():
+		return &Call{Name: name, Raw: p.text(start), Pos: t.Pos}, nil
+	case startsValue(next):
+		call := &Call{Name: name, Pos: t.Pos}
+		args, err := p.args(false)
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+		if !p.atStatementEnd() {
+			p.i = start
+			return p.raw(start)
+		}
+		call.Raw = p.text(start)
+		return call, nil
+	}
+	p.i = start
+	return p.raw(start)
+}
+
+// dottedName consumes a name such as a.b.c
+func (p *parser) dottedName() string {
+	name := p.next().Text
+	for isPunct(p.peek(), ".") && p.peekAt(1).Kind == Ident {
+		p.next()
+		name += "." + p.next().Text
+	}
+	return name
+}
+
+func startsValue(t *Token) bool {
+	switch t.Kind {
+	case String, Number:
+		return true
+	case Ident:
+		return !keywords[t.Text] || t.Text == "new"
+	case Punct:
+		return t.Text == "[" || t.Text == "-" || t.Text == "!"
+	}
+	return false
+}
+
+func (p *parser) atStatementEnd() bool {
+	t := p.peek()
+	return t.Kind == EOF || t.Kind == Newline || t.Text == ";" || isPunct(t, "}")
+}
+
+// call parses the arguments and closure of a call whose name has been consumed
+func (p *parser) call(start int, name string) (*Call, error) {
+	call := &Call{Name: name, Pos: p.tokens[start].Pos}
+	if isPunct(p.peek(), "(") {
+		p.next()
+		call.Parens = true
+		args, err := p.args(true)
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+		if t := p.next(); !isPunct(t, ")") {
+			return nil, &Error{Pos: t.Pos, Msg: "expected ) but found " + describe(t)}
+		}
+	}
+	if isPunct(p.peek(), "{") {
+		closure, err := p.closure()
+		if err != nil {
+			return nil, err
+		}
+		call.Closure = closure
+	}
+	call.Raw = p.text(start)
+	return call, nil
+}
+
+// args parses a comma separated argument list, either up to a closing parenthesis or to the end of the statement
+func (p *parser) args(parens bool) ([]*Arg, error) {
+	var args []*Arg
+	for {
+		if parens {
+			p.skipNewlinesOnly()
+			if isPunct(p.peek(), ")") {
+				return args, nil
+			}
+		}
+		arg := &Arg{}
+		if (p.peek().Kind == Ident || p.peek().Kind == String) && isPunct(p.peekAt(1), ":") {
+			k := p.next()
+			arg.Key = k.Text
+			if k.Kind == String {
+				arg.Key = k.Value
+			}
+			p.next()
+		}
+		value, err := p.expr(parens)
+		if err != nil {
+			return nil, err
+		}
+		arg.Value = value
+		args = append(args, arg)
+		if parens {
+			p.skipNewlinesOnly()
+		}
+		if !isPunct(p.peek(), ",") {
+			return args, nil
+		}
+		p.next()
+		p.skipNewlinesOnly()
+	}
+}
+
+func (p *parser) skipNewlinesOnly() {
+	for p.peek().Kind == Newline {
+		p.i++
+	}
+}
+
+// expr parses an expression up to a comma or closing bracket, or the end of the statement when not nested in
+// brackets
+func (p *parser) expr(nested bool) (*Expr, error) {
+	start := p.i
+	depth := 0
+	for {
+		t := p.peek()
+		if t.Kind == EOF {
+			break
+		}
+		if depth == 0 {
+			if isPunct(t, ",") || isPunct(t, ")") || isPunct(t, "]") || isPunct(t, "}") || t.Text == ";" {
+				break
+			}
+			if t.Kind == Newline && !nested && !p.continuesOnNextLine() {
+				break
+			}
+		}
+		if t.Kind == Punct {
+			switch t.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			}
+		}
+		p.next()
+	}
+	if p.i == start {
+		return nil, &Error{Pos: p.peek().Pos, Msg: "expected an expression but found " + describe(p.peek())}
+	}
+	end := p.i
+	e, err := p.classify(start, end)
+	p.i = end
+	return e, err
+}
+
+// continuesOnNextLine returns true if the statement continues after the newline at the current position
+func (p *parser) continuesOnNextLine() bool {
+	if p.i > 0 {
+		if prev := p.tokens[p.i-1]; prev.Kind == Punct && !isPunct(prev, ")") && !isPunct(prev, "]") &&
+			!isPunct(prev, "}") && !isPunct(prev, "++") && !isPunct(prev, "--") {
+			return true
+		}
+	}
+	next := p.peekAt(1)
+	return continuations[next.Text] || (next.Kind == Punct && strings.HasPrefix(next.Text, "?:"))
+}
+
+// classify determines the kind of the expression made of tokens start to end
+func (p *parser) classify(start, end int) (*Expr, error) {
+	first := p.tokens[start]
+	for end > start && p.tokens[end-1].Kind == Newline {
+		end--
+	}
+	e := &Expr{Kind: ExprRaw, Raw: p.src[first.Pos.Offset:p.tokens[end-1].End], Pos: first.Pos}
+	n := end - start
+	switch {
+	case n == 1 && first.Kind == String:
+		e.Kind, e.Value, e.Interpolated = ExprString, first.Value, first.Interpolated
+	case n == 1 && first.Kind == Number:
+		e.Kind, e.Value = ExprNumber, first.Text
+	case n == 2 && isPunct(first, "-") && p.tokens[start+1].Kind == Number:
+		e.Kind, e.Value = ExprNumber, e.Raw
+	case n == 1 && (first.Text == "true" || first.Text == "false"):
+		e.Kind, e.Value = ExprBool, first.Text
+	case n == 1 && first.Text == "null":
+		e.Kind = ExprNull
+	case first.Kind == Ident && !keywords[first.Text]:
+		p.i = start
+		name := p.dottedName()
+		switch {
+		case p.i == end:
+			e.Kind, e.Value = ExprIdent, name
+		case isPunct(p.peek(), "(") || isPunct(p.peek(), "{"):
+			call, err := p.call(start, name)
+			if err != nil {
+				return nil, err
+			}
+			if p.i == end {
+				e.Kind, e.Call = ExprCall, call
+			}
+		}
+	case n == 3 && isPunct(first, "[") && isPunct(p.tokens[start+1], ":") && isPunct(p.tokens[start+2], "]"):
+		e.Kind = ExprMap
+	case n == 2 && isPunct(first, "[") && isPunct(p.tokens[start+1], "]"):
+		e.Kind = ExprList
+	case isPunct(first, "[") && p.matching(start) == end-1:
+		p.i = start + 1
+		elements, err := p.args(true)
+		if err != nil {
+			return nil, err
+		}
+		if !isPunct(p.peek(), "]") {
+			// Something like a subscript or an operator inside the brackets we don't parse, so keep it raw.
+			return e, nil
+		}
+		e.Kind, e.Elements = ExprList, elements
+		for _, el := range elements {
+			if el.Key != "" {
+				e.Kind = ExprMap
+			}
+		}
+	case isPunct(first, "{") && p.matching(start) == end-1:
+		p.i = start
+		closure, err := p.closure()
+		if err != nil {
+			return nil, err
+		}
+		e.Kind, e.Closure = ExprClosure, closure
+	}
+	return e, nil
+}
+
+// matching returns the index of the token closing the bracket at i, or -1
+func (p *parser) matching(i int) int {
+	depth := 0
+	for j := i; j < len(p.tokens); j++ {
+		t := p.tokens[j]
+		if t.Kind != Punct {
+			continue
+		}
+		switch t.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// raw consumes a statement outside the supported subset
+func (p *parser) raw(start int) (Statement, error) {
+	p.i = start
+	depth := 0
+	for {
+		t := p.peek()
+		if t.Kind == EOF {
+			break
+		}
+		if depth == 0 && (t.Text == ";" || isPunct(t, "}")) {
+			break
+		}
+		if depth == 0 && t.Kind == Newline && !p.continuesOnNextLine() {
+			break
+		}
+		if t.Kind == Punct {
+			switch t.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			}
+		}
+		p.next()
+	}
+	if p.i == start {
+		t := p.next()
+		return nil, &Error{Pos: t.Pos, Msg: "unexpected " + describe(t)}
+	}
+	return &Raw{Text: p.text(start), Pos: p.tokens[start].Pos}, nil
+}
@@ -0,0 +1,151 @@
+package groovy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLexStrings(t *testing.T) {
+	tokens, err := Lex(`'it\'s' "a ${b.c("}")} $d" """x
+y""" =~ /a\/b$/ 'e\$f'`)
+	require.NoError(t, err)
+	require.Len(t, tokens, 7)
+
+	assert.Equal(t, "it's", tokens[0].Value)
+	assert.False(t, tokens[0].Interpolated)
+	assert.Equal(t, `a ${b.c("}")} $d`, tokens[1].Value)
+	assert.True(t, tokens[1].Interpolated)
+	assert.Equal(t, "x\ny", tokens[2].Value)
+	assert.Equal(t, `"""`, tokens[2].Quote)
+	assert.Equal(t, "=~", tokens[3].Text)
+	assert.Equal(t, "a/b$", tokens[4].Value)
+	assert.Equal(t, "/", tokens[4].Quote)
+	assert.Equal(t, "e$f", tokens[5].Value)
+	assert.Equal(t, EOF, tokens[6].Kind)
+}
+
+func TestLexErrors(t *testing.T) {
+	_, err := Lex("sh 'unterminated\n")
+	assert.EqualError(t, err, "line 1, column 4: unterminated string")
+	_, err = Lex("/* open")
+	assert.EqualError(t, err, "line 1, column 1: unterminated comment")
+}
+
+func TestParse(t *testing.T) {
+	src := `#!groovy
+// A scripted pipeline
+node('linux') {
+    stage('Build') {
+        checkout scm
+        sh script: 'make', returnStdout: true
+        withEnv(["A=1", 'B=2']) {
+            sh "echo ${env.A}"
+        }
+    }
+    def x = 1
+    if (x > 0) {
+        echo 'positive'
+    }
+    else {
+        echo 'not'
+    }
+    docker.image('maven').inside {
+        sh 'mvn'
+    }
+    FOO = credentials('secret')
+    parallel a: { echo 'a' }, b: { echo 'b' }
+    properties([:])
+}
+`
+	root, err := Parse(src)
+	require.NoError(t, err)
+	require.Len(t, root.Statements, 1)
+
+	node := root.Statements[0].(*Call)
+	assert.Equal(t, "node", node.Name)
+	assert.True(t, node.Parens)
+	require.Len(t, node.Args, 1)
+	assert.Equal(t, ExprString, node.Args[0].Value.Kind)
+	assert.Equal(t, "linux", node.Args[0].Value.Value)
+	assert.Equal(t, 3, node.Pos.Line)
+	require.Len(t, node.Closure.Statements, 7)
+
+	stage := node.Closure.Statements[0].(*Call)
+	assert.Equal(t, "stage", stage.Name)
+	require.Len(t, stage.Closure.Statements, 3)
+
+	checkout := stage.Closure.Statements[0].(*Call)
+	assert.Equal(t, "checkout", checkout.Name)
+	assert.Equal(t, ExprIdent, checkout.Args[0].Value.Kind)
+	assert.Equal(t, "scm", checkout.Args[0].Value.Value)
+
+	sh := stage.Closure.Statements[1].(*Call)
+	assert.False(t, sh.Parens)
+	require.Len(t, sh.Args, 2)
+	assert.Equal(t, "script", sh.Args[0].Key)
+	assert.Equal(t, "returnStdout", sh.Args[1].Key)
+	assert.Equal(t, ExprBool, sh.Args[1].Value.Kind)
+
+	withEnv := stage.Closure.Statements[2].(*Call)
+	assert.Equal(t, ExprList, withEnv.Args[0].Value.Kind)
+	assert.Len(t, withEnv.Args[0].Value.Elements, 2)
+	inner := withEnv.Closure.Statements[0].(*Call)
+	assert.True(t, inner.Args[0].Value.Interpolated)
+	assert.Equal(t, `"echo ${env.A}"`, inner.Args[0].Value.Raw)
+
+	assert.Equal(t, &Raw{Text: "def x = 1", Pos: Pos{Offset: 221, Line: 11, Column: 5}}, node.Closure.Statements[1])
+	ifStmt := node.Closure.Statements[2].(*Raw)
+	assert.Contains(t, ifStmt.Text, "else {")
+	chain := node.Closure.Statements[3].(*Raw)
+	assert.Equal(t, "docker.image('maven').inside {\n        sh 'mvn'\n    }", chain.Text)
+
+	assign := node.Closure.Statements[4].(*Assign)
+	assert.Equal(t, "FOO", assign.Name)
+	assert.Equal(t, ExprCall, assign.Value.Kind)
+	assert.Equal(t, "credentials", assign.Value.Call.Name)
+	assert.Equal(t, "secret", assign.Value.Call.Args[0].Value.Value)
+
+	parallel := node.Closure.Statements[5].(*Call)
+	require.Len(t, parallel.Args, 2)
+	assert.Equal(t, "a", parallel.Args[0].Key)
+	assert.Equal(t, ExprClosure, parallel.Args[0].Value.Kind)
+	assert.Len(t, parallel.Args[1].Value.Closure.Statements, 1)
+
+	props := node.Closure.Statements[6].(*Call)
+	assert.Equal(t, ExprMap, props.Args[0].Value.Kind)
+}
+
+func TestParseClosureRaw(t *testing.T) {
+	root, err := Parse("script {\n    if (isUnix()) { sh 'a' }\n}")
+	require.NoError(t, err)
+	script := root.Statements[0].(*Call)
+	assert.Equal(t, "\n    if (isUnix()) { sh 'a' }\n", script.Closure.Raw)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("node {\n  sh 'a'\n")
+	assert.EqualError(t, err, "line 1, column 6: unclosed {")
+	_, err = Parse("stage('a'")
+	assert.EqualError(t, err, "line 1, column 10: expected ) but found end of input")
+	_, err = Parse("}")
+	assert.EqualError(t, err, `line 1, column 1: unexpected "}"`)
+}
+
+func TestParseExpr(t *testing.T) {
+	e, err := ParseExpr("[a: 1, 'b-c': ['x', \"y\"],\n  d: true]")
+	require.NoError(t, err)
+	assert.Equal(t, ExprMap, e.Kind)
+	require.Len(t, e.Elements, 3)
+	assert.Equal(t, "b-c", e.Elements[1].Key)
+	assert.Equal(t, ExprList, e.Elements[1].Value.Kind)
+	assert.Equal(t, ExprBool, e.Elements[2].Value.Kind)
+
+	e, err = ParseExpr("env.FOO")
+	require.NoError(t, err)
+	assert.Equal(t, ExprIdent, e.Kind)
+
+	_, err = ParseExpr("a, b")
+	assert.EqualError(t, err, `line 1, column 2: unexpected ","`)
+}
@@ -0,0 +1,136 @@
+// Package groovymodel converts parsed Groovy into model values: arguments and steps, the parts shared by Declarative
+// and Scripted pipelines.
+package groovymodel
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// defaultParameters are the names of the parameters steps take when called with a single unnamed argument
+var defaultParameters = map[string]string{
+	"sh": "script", "bat": "script", "powershell": "script", "pwsh": "script",
+	"echo": "message", "error": "message", "input": "message", "unstable": "message", "warnError": "message",
+	"stash": "name", "unstash": "name", "git": "url", "sleep": "time", "archiveArtifacts": "artifacts",
+	"archive": "includes", "junit": "testResults", "readFile": "file", "fileExists": "file", "build": "job",
+	"milestone": "ordinal", "load": "path", "tool": "name", "checkout": "scm", "library": "identifier",
+}
+
+// DefaultParameter returns the name of the parameter a step takes when called with a single unnamed argument, or an
+// empty string if it isn't known.
+func DefaultParameter(step string) string {
+	return defaultParameters[step]
+}
+
+// Argument converts an expression to a model argument. String, number, and boolean literals are literal arguments, as
+// is null, with no value; anything else, including interpolated GStrings, is a non-literal argument holding the
+// expression's Groovy source.
+func Argument(e *groovy.Expr) *model.RawArgument {
+	switch e.Kind {
+	case groovy.ExprString:
+		if !e.Interpolated {
+			return &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: stringPtr(e.Value)}}
+		}
+	case groovy.ExprNumber:
+		if f, err := strconv.ParseFloat(strings.TrimRight(e.Value, "lLgGiIdDfF"), 64); err == nil {
+			return &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsFloat: &f}}
+		}
+	case groovy.ExprBool:
+		b := e.Value == "true"
+		return &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsBool: &b}}
+	case groovy.ExprNull:
+		return &model.RawArgument{IsLiteral: true}
+	}
+	return &model.RawArgument{IsLiteral: false, Value: &model.RawArgumentValue{AsString: stringPtr(e.Raw)}}
+}
+
+// Arguments converts the arguments of a step call. A single unnamed argument is named after the step's default
+// parameter if it's known, and is otherwise kept as the single argument.
+func Arguments(step string, args []*groovy.Arg) *model.ArgumentList {
+	list := &model.ArgumentList{}
+	if len(args) == 1 && args[0].Key == "" {
+		if name := DefaultParameter(step); name != "" {
+			list.Named = []*model.ArgumentValue{{Key: name, Value: Argument(args[0].Value)}}
+		} else {
+			list.Single = Argument(args[0].Value)
+		}
+		return list
+	}
+	named := true
+	for _, a := range args {
+		if a.Key == "" {
+			named = false
+		}
+	}
+	if !named {
+		for _, a := range args {
+			list.Positional = append(list.Positional, Argument(a.Value))
+		}
+		return list
+	}
+	list.Named = []*model.ArgumentValue{}
+	for _, a := range args {
+		list.Named = append(list.Named, &model.ArgumentValue{Key: a.Key, Value: Argument(a.Value)})
+	}
+	return list
+}
+
+// Steps converts the statements of a steps block. Calls become steps, or tree steps if they have a closure. Anything
+// else becomes a raw Groovy step, as made by model.RawStep, and is passed to wrapped if it isn't nil.
+func Steps(stmts []groovy.Statement, wrapped func(groovy.Statement)) []*model.AnyStep {
+	var out []*model.AnyStep
+	for _, s := range stmts {
+		call, ok := s.(*groovy.Call)
+		switch {
+		case ok && call.Name == "script" && call.Closure != nil && len(call.Args) == 0:
+			out = append(out, ScriptStep(call.Closure.Raw))
+		case ok && !strings.Contains(call.Name, ".") && call.Closure != nil:
+			args := Arguments(call.Name, call.Args)
+			if len(call.Args) == 1 && call.Args[0].Key == "" {
+				// Block steps keep a single unnamed argument as it is.
+				args = &model.ArgumentList{Single: Argument(call.Args[0].Value)}
+			}
+			out = append(out, &model.AnyStep{Tree: &model.TreeStep{
+				Name:      call.Name,
+				Arguments: args,
+				Children:  Steps(call.Closure.Statements, wrapped),
+			}})
+		case ok && !strings.Contains(call.Name, "."):
+			out = append(out, &model.AnyStep{Step: &model.Step{Name: call.Name, Arguments: Arguments(call.Name, call.Args)}})
+		default:
+			if wrapped != nil {
+				wrapped(s)
+			}
+			out = append(out, model.RawStep(strings.TrimSpace(s.Source()), rawReason(s)))
+		}
+	}
+	return out
+}
+
+// ScriptStep returns a script step running the given Groovy source
+func ScriptStep(source string) *model.AnyStep {
+	return &model.AnyStep{Step: &model.Step{Name: "script", Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{{
+		Key:   "scriptBlock",
+		Value: &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: stringPtr(strings.TrimSpace(source))}},
+	}}}}}
+}
+
+// rawReason says why a statement couldn't be converted to a step
+func rawReason(s groovy.Statement) string {
+	switch s := s.(type) {
+	case *groovy.Call:
+		if i := strings.LastIndex(s.Name, "."); i > 0 {
+			return "method call on " + s.Name[:i]
+		}
+	case *groovy.Assign:
+		return "assignment"
+	}
+	return "not a step call"
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
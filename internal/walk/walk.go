@@ -0,0 +1,126 @@
+// Package walk provides depth-first traversal helpers over pipeline stages and steps. They're built on model.Walk, for
+// callers which want a stage's enclosing stages or a step's enclosing tree steps rather than JSON pointers.
+package walk
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// StageFunc is called for each stage visited, along with its enclosing stages, outermost first.
+type StageFunc func(stage *model.Stage, parents []*model.Stage)
+
+// StepFunc is called for each step visited, along with the tree steps enclosing it, outermost first.
+type StepFunc func(step *model.AnyStep, parents []*model.TreeStep)
+
+// Stages visits the given stages and every stage nested beneath them via sequential stages, parallel stages, or
+// matrix stages.
+func Stages(stages []*model.Stage, fn StageFunc) {
+	var paths []string
+	var parents []*model.Stage
+	_ = model.Walk(&model.Root{Pipeline: &model.Pipeline{Stages: stages}}, model.Visitor{
+		Stage: func(path string, s *model.Stage) error {
+			n := enclosing(paths, path)
+			paths, parents = paths[:n], parents[:n]
+			fn(s, append([]*model.Stage(nil), parents...))
+			paths, parents = append(paths, path), append(parents, s)
+			return nil
+		},
+	})
+}
+
+// Steps visits the given steps and every step nested beneath them in tree steps.
+func Steps(steps []*model.AnyStep, fn StepFunc) {
+	// lists are the step lists enclosing the step being visited, with their paths: the given steps, then the children
+	// of each of the parent tree steps.
+	var lists [][]*model.AnyStep
+	var paths []string
+	var parents []*model.TreeStep
+	visit := func(path string) {
+		n := enclosing(paths, path)
+		lists, paths, parents = lists[:n], paths[:n], parents[:n-1]
+		i, _ := strconv.Atoi(path[len(paths[n-1])+1:])
+		fn(lists[n-1][i], append([]*model.TreeStep(nil), parents...))
+	}
+	root := &model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{{Branches: []*model.Branch{{Steps: steps}}}}}}
+	_ = model.Walk(root, model.Visitor{
+		Branch: func(path string, b *model.Branch) error {
+			lists, paths = [][]*model.AnyStep{b.Steps}, []string{path + "/steps"}
+			return nil
+		},
+		Step: func(path string, _ *model.Step) error {
+			visit(path)
+			return model.SkipChildren
+		},
+		TreeStep: func(path string, t *model.TreeStep) error {
+			visit(path)
+			lists, paths, parents = append(lists, t.Children), append(paths, path+"/children"), append(parents, t)
+			return nil
+		},
+	})
+}
+
+// enclosing returns how many of paths, the paths of nested nodes, outermost first, are of nodes enclosing the node at
+// path
+func enclosing(paths []string, path string) int {
+	n := len(paths)
+	for n > 0 && !strings.HasPrefix(path, paths[n-1]+"/") {
+		n--
+	}
+	return n
+}
+
+// StageSteps visits every step in the given stage's branches, without descending into nested stages.
+func StageSteps(stage *model.Stage, fn StepFunc) {
+	if stage == nil {
+		return
+	}
+	for _, b := range stage.Branches {
+		if b != nil {
+			Steps(b.Steps, fn)
+		}
+	}
+}
+
+// PostSteps visits every step in the given post section's conditions.
+func PostSteps(post *model.Post, fn StepFunc) {
+	PostConditionSteps(post, func(string) StepFunc {
+		return fn
+	})
+}
+
+// PostConditionSteps visits every step in the given post section's conditions, with the function fn returns for the
+// condition, such as "always".
+func PostConditionSteps(post *model.Post, fn func(condition string) StepFunc) {
+	if post == nil {
+		return
+	}
+	for _, c := range post.Conditions {
+		if c != nil && c.Branch != nil {
+			Steps(c.Branch.Steps, fn(c.Condition))
+		}
+	}
+}
+
+// StagePostSteps visits every step in the post sections of the given stage and, for a matrix stage, its matrix, with
+// the function fn returns for the condition. Like StageSteps, it doesn't descend into nested stages.
+func StagePostSteps(stage *model.Stage, fn func(condition string) StepFunc) {
+	if stage == nil {
+		return
+	}
+	PostConditionSteps(stage.Post, fn)
+	if stage.Matrix != nil {
+		PostConditionSteps(stage.Matrix.Post, fn)
+	}
+}
+
+// Path returns the names of the given parents followed by the stage's own name.
+func Path(stage *model.Stage, parents []*model.Stage) []string {
+	path := make([]string, 0, len(parents)+1)
+	for _, p := range parents {
+		path = append(path, p.Name)
+	}
+	return append(path, stage.Name)
+}
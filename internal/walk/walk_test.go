@@ -0,0 +1,73 @@
+package walk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func step(name string) *model.AnyStep {
+	return model.NewAnyStep(&model.Step{Name: name})
+}
+
+func tree(name string, children ...*model.AnyStep) *model.AnyStep {
+	return &model.AnyStep{Tree: &model.TreeStep{Name: name, Children: children}}
+}
+
+func TestStages(t *testing.T) {
+	stages := []*model.Stage{
+		{Name: "Build", Stages: []*model.Stage{{Name: "Unix"}, {Name: "Windows", Parallel: []*model.Stage{{Name: "x86"}}}}},
+		{Name: "Test", Matrix: &model.Matrix{Stages: []*model.Stage{{Name: "Unit"}}}},
+	}
+	var visited []string
+	Stages(stages, func(stage *model.Stage, parents []*model.Stage) {
+		visited = append(visited, strings.Join(Path(stage, parents), "/"))
+	})
+	assert.Equal(t, []string{"Build", "Build/Unix", "Build/Windows", "Build/Windows/x86", "Test", "Test/Unit"}, visited)
+}
+
+func TestSteps(t *testing.T) {
+	steps := []*model.AnyStep{
+		step("checkout"),
+		tree("dir", tree("withEnv", step("sh")), step("bat")),
+		nil,
+		step("echo"),
+	}
+	var visited []string
+	Steps(steps, func(s *model.AnyStep, parents []*model.TreeStep) {
+		var name string
+		if s.Tree != nil {
+			name = s.Tree.Name
+		} else {
+			name = s.Step.Name
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			name = parents[i].Name + "/" + name
+		}
+		visited = append(visited, name)
+	})
+	assert.Equal(t, []string{"checkout", "dir", "dir/withEnv", "dir/withEnv/sh", "dir/bat", "echo"}, visited)
+}
+
+func TestStagePostSteps(t *testing.T) {
+	post := func(condition string, name string) *model.Post {
+		return &model.Post{Conditions: []*model.BuildCondition{
+			{Condition: condition, Branch: &model.Branch{Name: "default", Steps: []*model.AnyStep{step(name)}}}}}
+	}
+	stage := &model.Stage{Name: "Test", Post: post("always", "junit"), Matrix: &model.Matrix{
+		Post:   post("failure", "mail"),
+		Stages: []*model.Stage{{Name: "Unit", Post: post("cleanup", "cleanWs")}},
+	}}
+	var visited []string
+	StagePostSteps(stage, func(condition string) StepFunc {
+		return func(s *model.AnyStep, _ []*model.TreeStep) {
+			visited = append(visited, condition+": "+s.Step.Name)
+		}
+	})
+	assert.Equal(t, []string{"always: junit", "failure: mail"}, visited)
+
+	StagePostSteps(nil, nil)
+	PostSteps(nil, nil)
+}
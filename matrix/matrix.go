@@ -0,0 +1,176 @@
+// Package matrix expands matrix stages into their cells and estimates how many executors they consume, so that
+// runaway matrices can be caught before they reach Jenkins.
+package matrix
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+func init() {
+	for _, rule := range []string{"matrix-cells", "matrix-total-cells", "matrix-cost"} {
+		analysis.RegisterRule(rule, analysis.Rule{Source: analysis.SourcePolicy, Severity: analysis.Error})
+	}
+}
+
+// Cell is one combination of axis values, keyed by axis name
+type Cell map[string]string
+
+// Cells returns the cells of a matrix after excludes are applied, varying the last axis fastest.
+func Cells(m *model.Matrix) []Cell {
+	if m == nil || len(m.Axes) == 0 {
+		return nil
+	}
+	cells := []Cell{{}}
+	for _, axis := range m.Axes {
+		if axis == nil {
+			continue
+		}
+		var next []Cell
+		for _, c := range cells {
+			for _, v := range axis.Values {
+				cell := Cell{}
+				for k, existing := range c {
+					cell[k] = existing
+				}
+				cell[axis.Name] = v.String()
+				next = append(next, cell)
+			}
+		}
+		cells = next
+	}
+
+	var out []Cell
+	for _, c := range cells {
+		if !excluded(c, m.Excludes) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// excluded returns true if any exclude matches the cell. An exclude matches if the cell's value for each of its axes
+// is among the axis values, or for an inverse ("notValues") axis, is not among them.
+func excluded(c Cell, excludes [][]*model.ExcludeAxis) bool {
+	for _, exclude := range excludes {
+		matches := len(exclude) > 0
+		for _, axis := range exclude {
+			if axis == nil || axis.Name == nil {
+				continue
+			}
+			found := false
+			for _, v := range axis.Values {
+				if v.String() == c[*axis.Name] {
+					found = true
+					break
+				}
+			}
+			if axis.Inverse != nil && *axis.Inverse {
+				found = !found
+			}
+			if !found {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// StageCost is the estimated cost of a single matrix stage
+type StageCost struct {
+	// Stage is the ID of the matrix stage.
+	Stage string `json:"stage"`
+	Cells int    `json:"cells"`
+	// CellWeight is the sum of the weights of the stages run in each cell.
+	CellWeight float64 `json:"cellWeight"`
+	// Cost is Cells multiplied by CellWeight.
+	Cost float64 `json:"cost"`
+}
+
+// Estimate is the estimated cost of all the matrix stages in a pipeline
+type Estimate struct {
+	Matrices []*StageCost `json:"matrices"`
+	Cells    int          `json:"cells"`
+	Cost     float64      `json:"cost"`
+}
+
+// EstimateCost computes the cells of each matrix stage in the pipeline, and estimates their cost by multiplying the
+// number of cells by the total weight of the stages each cell runs. weights maps stage IDs, such as "Test/Unit" for
+// the Unit stage of the Test matrix, to their weight; stages without a weight count as 1.
+func EstimateCost(root *model.Root, weights map[string]float64) (*Estimate, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	est := &Estimate{Matrices: []*StageCost{}}
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			if s.ChildMode != plan.Matrix {
+				visit(s.Children)
+				continue
+			}
+			sc := &StageCost{Stage: s.ID, Cells: len(Cells(s.Source.Matrix))}
+			var weigh func(stages []*plan.Stage)
+			weigh = func(stages []*plan.Stage) {
+				for _, c := range stages {
+					if len(c.Children) > 0 {
+						weigh(c.Children)
+						continue
+					}
+					w, ok := weights[c.ID]
+					if !ok {
+						w = 1
+					}
+					sc.CellWeight += w
+				}
+			}
+			weigh(s.Children)
+			sc.Cost = float64(sc.Cells) * sc.CellWeight
+			est.Matrices = append(est.Matrices, sc)
+			est.Cells += sc.Cells
+			est.Cost += sc.Cost
+		}
+	}
+	visit(p.Stages)
+	return est, nil
+}
+
+// Policy caps the size of matrices. Zero values are not enforced.
+type Policy struct {
+	// MaxCells caps the total number of cells across all matrices in the pipeline.
+	MaxCells int `json:"maxCells,omitempty"`
+	// MaxCellsPerMatrix caps the number of cells in any one matrix.
+	MaxCellsPerMatrix int `json:"maxCellsPerMatrix,omitempty"`
+	// MaxCost caps the total estimated cost.
+	MaxCost float64 `json:"maxCost,omitempty"`
+}
+
+// Check returns findings for each cap in the policy the estimate exceeds.
+func (strct *Policy) Check(est *Estimate) []*analysis.Finding {
+	var findings []*analysis.Finding
+	if strct.MaxCellsPerMatrix > 0 {
+		for _, m := range est.Matrices {
+			if m.Cells > strct.MaxCellsPerMatrix {
+				findings = append(findings, &analysis.Finding{Rule: "matrix-cells", Stage: m.Stage,
+					Message: fmt.Sprintf("matrix has %d cells, more than the limit of %d", m.Cells, strct.MaxCellsPerMatrix)})
+			}
+		}
+	}
+	if strct.MaxCells > 0 && est.Cells > strct.MaxCells {
+		findings = append(findings, &analysis.Finding{Rule: "matrix-total-cells",
+			Message: fmt.Sprintf("pipeline has %d matrix cells, more than the limit of %d", est.Cells, strct.MaxCells)})
+	}
+	if strct.MaxCost > 0 && est.Cost > strct.MaxCost {
+		findings = append(findings, &analysis.Finding{Rule: "matrix-cost",
+			Message: fmt.Sprintf("estimated matrix cost %g is more than the limit of %g", est.Cost, strct.MaxCost)})
+	}
+	return findings
+}
@@ -0,0 +1,54 @@
+package matrix
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, name string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestCells(t *testing.T) {
+	root := loadRoot(t, "matrix/matrixPipelineTwoAxisTwoExcludes")
+	cells := Cells(root.Pipeline.Stages[0].Matrix)
+	assert.Len(t, cells, 9)
+	assert.Equal(t, Cell{"OS_VALUE": "linux", "BROWSER_VALUE": "firefox"}, cells[0])
+	for _, c := range cells {
+		assert.False(t, c["OS_VALUE"] == "linux" && c["BROWSER_VALUE"] == "safari")
+		assert.False(t, c["OS_VALUE"] != "windows" && c["BROWSER_VALUE"] == "ie")
+	}
+
+	root = loadRoot(t, "matrix/matrixPipelineTwoAxisExcludeNot")
+	cells = Cells(root.Pipeline.Stages[0].Matrix)
+	assert.Len(t, cells, 9)
+	assert.Contains(t, cells, Cell{"OS_VALUE": "windows", "BROWSER_VALUE": "ie"})
+}
+
+func TestEstimateCost(t *testing.T) {
+	est, err := EstimateCost(loadRoot(t, "matrix/matrixPipelineTwoAxisTwoExcludes"), map[string]float64{"foo/second": 2.5})
+	require.NoError(t, err)
+	assert.Equal(t, &Estimate{
+		Matrices: []*StageCost{{Stage: "foo", Cells: 9, CellWeight: 3.5, Cost: 31.5}},
+		Cells:    9,
+		Cost:     31.5,
+	}, est)
+
+	policy := &Policy{MaxCells: 8, MaxCellsPerMatrix: 6, MaxCost: 100}
+	assert.Equal(t, []*analysis.Finding{
+		{Rule: "matrix-cells", Stage: "foo", Message: "matrix has 9 cells, more than the limit of 6"},
+		{Rule: "matrix-total-cells", Message: "pipeline has 9 matrix cells, more than the limit of 8"},
+	}, policy.Check(est))
+	assert.Empty(t, (&Policy{}).Check(est))
+}
@@ -0,0 +1,80 @@
+// Package metrics defines the instrumentation hooks reported by the library, such as parse durations, validation
+// findings by rule, and conversion warnings by target. Metrics are sent to a Sink, which discards them by default;
+// programs running as a server or processing pipelines in bulk set a Sink such as a Prometheus registry to collect
+// them.
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ParseDuration is a histogram of the seconds taken to parse a pipeline, labelled by "format".
+	ParseDuration = "jenkinsfile_parse_duration_seconds"
+	// Findings is a counter of analysis findings, labelled by "rule". Validation errors are counted with the rule
+	// "validate".
+	Findings = "jenkinsfile_findings_total"
+	// ConversionDuration is a histogram of the seconds taken to convert a pipeline, labelled by "target".
+	ConversionDuration = "jenkinsfile_conversion_duration_seconds"
+	// ConversionWarnings is a counter of conversion warnings, labelled by "target".
+	ConversionWarnings = "jenkinsfile_conversion_warnings_total"
+)
+
+// Labels are the label names and values of a metric
+type Labels map[string]string
+
+// Sink receives metrics. Implementations must be safe for concurrent use.
+type Sink interface {
+	// Add adds delta to a counter.
+	Add(name string, labels Labels, delta float64)
+	// Observe records a value in a histogram.
+	Observe(name string, labels Labels, value float64)
+}
+
+type discard struct{}
+
+func (discard) Add(string, Labels, float64)     {}
+func (discard) Observe(string, Labels, float64) {}
+
+// Discard is a Sink which drops all metrics
+var Discard Sink = discard{}
+
+var (
+	sinkLock sync.RWMutex
+	sink     = Discard
+)
+
+// SetSink sets the Sink metrics are reported to. Passing nil restores Discard.
+func SetSink(s Sink) {
+	if s == nil {
+		s = Discard
+	}
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	sink = s
+}
+
+// CurrentSink returns the Sink metrics are reported to.
+func CurrentSink() Sink {
+	sinkLock.RLock()
+	defer sinkLock.RUnlock()
+	return sink
+}
+
+// Add adds delta to a counter on the current Sink.
+func Add(name string, labels Labels, delta float64) {
+	CurrentSink().Add(name, labels, delta)
+}
+
+// Observe records a value in a histogram on the current Sink.
+func Observe(name string, labels Labels, value float64) {
+	CurrentSink().Observe(name, labels, value)
+}
+
+// Since records the seconds elapsed since start in a histogram on the current Sink. It is intended to be deferred:
+//
+//	defer metrics.Since(metrics.ParseDuration, metrics.Labels{"format": "json"}, time.Now())
+func Since(name string, labels Labels, start time.Time) {
+	Observe(name, labels, time.Since(start).Seconds())
+}
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram bucket upper bounds used by NewPrometheus, in seconds
+var DefaultBuckets = []float64{.001, .005, .01, .05, .1, .5, 1, 5}
+
+// Prometheus is a Sink which accumulates metrics in memory and exposes them in the Prometheus text exposition format.
+// It is an http.Handler, so it can be served directly as a /metrics endpoint.
+type Prometheus struct {
+	buckets []float64
+
+	lock       sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheus creates an empty Prometheus sink. Histograms use the given bucket upper bounds, which must be sorted,
+// or DefaultBuckets if none are given.
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Prometheus{
+		buckets:    buckets,
+		counters:   map[string]map[string]float64{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+// Add adds delta to a counter.
+func (strct *Prometheus) Add(name string, labels Labels, delta float64) {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	series, ok := strct.counters[name]
+	if !ok {
+		series = map[string]float64{}
+		strct.counters[name] = series
+	}
+	series[formatLabels(labels)] += delta
+}
+
+// Observe records a value in a histogram.
+func (strct *Prometheus) Observe(name string, labels Labels, value float64) {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	series, ok := strct.histograms[name]
+	if !ok {
+		series = map[string]*histogram{}
+		strct.histograms[name] = series
+	}
+	key := formatLabels(labels)
+	h, ok := series[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(strct.buckets))}
+		series[key] = h
+	}
+	for i, upper := range strct.buckets {
+		if value <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format, sorted by name and labels.
+func (strct *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	for _, name := range sortedNames(strct.counters) {
+		fmt.Fprintf(cw, "# TYPE %s counter\n", name)
+		series := strct.counters[name]
+		for _, labels := range sortedNames(series) {
+			fmt.Fprintf(cw, "%s%s %s\n", name, labels, formatFloat(series[labels]))
+		}
+	}
+	for _, name := range sortedNames(strct.histograms) {
+		fmt.Fprintf(cw, "# TYPE %s histogram\n", name)
+		series := strct.histograms[name]
+		for _, labels := range sortedNames(series) {
+			h := series[labels]
+			for i, upper := range strct.buckets {
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(upper)), h.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), h.count)
+			fmt.Fprintf(cw, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+			fmt.Fprintf(cw, "%s_count%s %d\n", name, labels, h.count)
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// Sample is the current value of a single series
+type Sample struct {
+	Name string `json:"name"`
+	// Labels are the series' labels, formatted as in the text exposition format.
+	Labels string  `json:"labels,omitempty"`
+	Value  float64 `json:"value"`
+}
+
+// Samples returns the current value of every counter, and the sum and count of every histogram, sorted by name and
+// labels.
+func (strct *Prometheus) Samples() []*Sample {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	var out []*Sample
+	for _, name := range sortedNames(strct.counters) {
+		series := strct.counters[name]
+		for _, labels := range sortedNames(series) {
+			out = append(out, &Sample{Name: name, Labels: labels, Value: series[labels]})
+		}
+	}
+	for _, name := range sortedNames(strct.histograms) {
+		series := strct.histograms[name]
+		for _, labels := range sortedNames(series) {
+			h := series[labels]
+			out = append(out, &Sample{Name: name + "_sum", Labels: labels, Value: h.sum},
+				&Sample{Name: name + "_count", Labels: labels, Value: float64(h.count)})
+		}
+	}
+	return out
+}
+
+// ServeHTTP writes the metrics as the response.
+func (strct *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = strct.WriteTo(w)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// formatLabels renders labels as {k="v",...} sorted by name, or an empty string if there are none.
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, k+"="+escapeLabel(labels[k]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// withLabel adds a label to already formatted labels
+func withLabel(labels string, name string, value string) string {
+	l := name + "=" + escapeLabel(value)
+	if labels == "" {
+		return "{" + l + "}"
+	}
+	return labels[:len(labels)-1] + "," + l + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedNames(m interface{}) []string {
+	var names []string
+	switch t := m.(type) {
+	case map[string]map[string]float64:
+		for k := range t {
+			names = append(names, k)
+		}
+	case map[string]map[string]*histogram:
+		for k := range t {
+			names = append(names, k)
+		}
+	case map[string]float64:
+		for k := range t {
+			names = append(names, k)
+		}
+	case map[string]*histogram:
+		for k := range t {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
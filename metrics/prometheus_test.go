@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus(0.1, 1)
+	p.Add(Findings, Labels{"rule": "unused-stash"}, 1)
+	p.Add(Findings, Labels{"rule": "unused-stash"}, 2)
+	p.Add(Findings, Labels{"rule": `say "hi"`}, 1)
+	p.Observe(ConversionDuration, Labels{"target": "harness"}, 0.05)
+	p.Observe(ConversionDuration, Labels{"target": "harness"}, 0.5)
+	p.Observe(ConversionDuration, Labels{"target": "harness"}, 2)
+
+	buf := &bytes.Buffer{}
+	n, err := p.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, `# TYPE jenkinsfile_findings_total counter
+jenkinsfile_findings_total{rule="say \"hi\""} 1
+jenkinsfile_findings_total{rule="unused-stash"} 3
+# TYPE jenkinsfile_conversion_duration_seconds histogram
+jenkinsfile_conversion_duration_seconds_bucket{target="harness",le="0.1"} 1
+jenkinsfile_conversion_duration_seconds_bucket{target="harness",le="1"} 2
+jenkinsfile_conversion_duration_seconds_bucket{target="harness",le="+Inf"} 3
+jenkinsfile_conversion_duration_seconds_sum{target="harness"} 2.55
+jenkinsfile_conversion_duration_seconds_count{target="harness"} 3
+`, buf.String())
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, buf.String(), rec.Body.String())
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestSetSink(t *testing.T) {
+	p := NewPrometheus()
+	SetSink(p)
+	defer SetSink(nil)
+
+	Add(ConversionWarnings, Labels{"target": "codefresh"}, 2)
+	assert.Equal(t, 2.0, p.counters[ConversionWarnings][`{target="codefresh"}`])
+
+	SetSink(nil)
+	assert.Equal(t, Discard, CurrentSink())
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// The agent types of Declarative Pipelines and the plugins most pipelines use
+const (
+	AgentAny        = "any"
+	AgentNone       = "none"
+	AgentLabel      = "label"
+	AgentNode       = "node"
+	AgentDocker     = "docker"
+	AgentDockerfile = "dockerfile"
+	AgentKubernetes = "kubernetes"
+)
+
+// AnyAgent returns an agent running on any available node
+func AnyAgent() *Agent {
+	return &Agent{Type: AgentAny}
+}
+
+// NoAgent returns the agent of pipelines whose stages each declare their own
+func NoAgent() *Agent {
+	return &Agent{Type: AgentNone}
+}
+
+// The typed agents are views of an agent's arguments, for reading and writing them without walking its map arguments.
+// Arguments with a Groovy expression as their value, such as "${REGISTRY}/app", are read as the expression's source;
+// writing the agent back keeps them as expressions unless they're changed. Arguments the view has no field for are
+// kept in Other, so converting an agent to a view and back loses nothing, though the arguments are written in the order
+// of the view's fields, followed by Other.
+
+// NodeAgent is a label or node agent, running on a node with the given label
+type NodeAgent struct {
+	Label           string `jenkins:"label"`
+	CustomWorkspace string `jenkins:"customWorkspace"`
+	Other           []*MapArgumentValue
+
+	expressions map[string]string
+}
+
+// DockerAgent is a docker agent, running in a container of the given image
+type DockerAgent struct {
+	Image                 string `jenkins:"image"`
+	Args                  string `jenkins:"args"`
+	AlwaysPull            bool   `jenkins:"alwaysPull"`
+	ReuseNode             bool   `jenkins:"reuseNode"`
+	Label                 string `jenkins:"label"`
+	CustomWorkspace       string `jenkins:"customWorkspace"`
+	RegistryURL           string `jenkins:"registryUrl"`
+	RegistryCredentialsID string `jenkins:"registryCredentialsId"`
+	Other                 []*MapArgumentValue
+
+	expressions map[string]string
+}
+
+// DockerfileAgent is a dockerfile agent, running in a container of an image built from a Dockerfile in the source
+// repository
+type DockerfileAgent struct {
+	// Filename defaults to Dockerfile.
+	Filename            string `jenkins:"filename"`
+	Dir                 string `jenkins:"dir"`
+	AdditionalBuildArgs string `jenkins:"additionalBuildArgs"`
+	Args                string `jenkins:"args"`
+	ReuseNode           bool   `jenkins:"reuseNode"`
+	Label               string `jenkins:"label"`
+	CustomWorkspace     string `jenkins:"customWorkspace"`
+	Other               []*MapArgumentValue
+
+	expressions map[string]string
+}
+
+// KubernetesAgent is a kubernetes agent, running in a pod on a Kubernetes cloud
+type KubernetesAgent struct {
+	Cloud string `jenkins:"cloud"`
+	Label string `jenkins:"label"`
+	// Yaml is the pod's definition, and YamlFile the path of a file in the source repository holding it.
+	Yaml             string `jenkins:"yaml"`
+	YamlFile         string `jenkins:"yamlFile"`
+	DefaultContainer string `jenkins:"defaultContainer"`
+	InheritFrom      string `jenkins:"inheritFrom"`
+	Namespace        string `jenkins:"namespace"`
+	ServiceAccount   string `jenkins:"serviceAccount"`
+	NodeSelector     string `jenkins:"nodeSelector"`
+	CustomWorkspace  string `jenkins:"customWorkspace"`
+	Other            []*MapArgumentValue
+
+	expressions map[string]string
+}
+
+// AsNode returns a label or node agent's arguments, and false for other agents.
+func (strct *Agent) AsNode() (*NodeAgent, bool) {
+	if strct == nil || strct.Type != AgentLabel && strct.Type != AgentNode {
+		return nil, false
+	}
+	out := &NodeAgent{}
+	out.Other, out.expressions = fromAgentArgs(strct, "label", out)
+	return out, true
+}
+
+// AsDocker returns a docker agent's arguments, and false for other agents.
+func (strct *Agent) AsDocker() (*DockerAgent, bool) {
+	if strct == nil || strct.Type != AgentDocker {
+		return nil, false
+	}
+	out := &DockerAgent{}
+	out.Other, out.expressions = fromAgentArgs(strct, "image", out)
+	return out, true
+}
+
+// AsDockerfile returns a dockerfile agent's arguments, and false for other agents.
+func (strct *Agent) AsDockerfile() (*DockerfileAgent, bool) {
+	if strct == nil || strct.Type != AgentDockerfile {
+		return nil, false
+	}
+	out := &DockerfileAgent{}
+	// "dockerfile true" builds the default Dockerfile, so its argument isn't a value of any field.
+	out.Other, out.expressions = fromAgentArgs(strct, "", out)
+	return out, true
+}
+
+// AsKubernetes returns a kubernetes agent's arguments, and false for other agents.
+func (strct *Agent) AsKubernetes() (*KubernetesAgent, bool) {
+	if strct == nil || strct.Type != AgentKubernetes {
+		return nil, false
+	}
+	out := &KubernetesAgent{}
+	out.Other, out.expressions = fromAgentArgs(strct, "", out)
+	return out, true
+}
+
+// Agent returns the agent. It's the short form, such as "label 'linux'", if the label is its only argument.
+func (strct *NodeAgent) Agent() *Agent {
+	args := toAgentArgs(strct, strct.Other, strct.expressions)
+	if len(args) == 1 && args[0].Key == "label" && args[0].Value.Raw != nil {
+		return &Agent{Type: AgentLabel, Argument: args[0].Value.Raw}
+	}
+	return &Agent{Type: AgentNode, Arguments: args}
+}
+
+// Agent returns the agent
+func (strct *DockerAgent) Agent() *Agent {
+	return &Agent{Type: AgentDocker, Arguments: toAgentArgs(strct, strct.Other, strct.expressions)}
+}
+
+// Agent returns the agent. It's "dockerfile true" if it has no arguments.
+func (strct *DockerfileAgent) Agent() *Agent {
+	args := toAgentArgs(strct, strct.Other, strct.expressions)
+	if len(args) == 0 {
+		t := true
+		return &Agent{Type: AgentDockerfile, Argument: &RawArgument{IsLiteral: true, Value: &RawArgumentValue{AsBool: &t}}}
+	}
+	return &Agent{Type: AgentDockerfile, Arguments: args}
+}
+
+// Agent returns the agent
+func (strct *KubernetesAgent) Agent() *Agent {
+	return &Agent{Type: AgentKubernetes, Arguments: toAgentArgs(strct, strct.Other, strct.expressions)}
+}
+
+// fromAgentArgs sets the tagged string and bool fields of the struct dst points to from the agent's arguments. A
+// single argument, as in "docker 'maven:3'", sets the field tagged with single. It returns the arguments with no
+// field, and the Groovy source of the fields set from expressions, by tag.
+func fromAgentArgs(a *Agent, single string, dst interface{}) ([]*MapArgumentValue, map[string]string) {
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(dst).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name, _ := parseTag(v.Type().Field(i).Tag.Get("jenkins")); name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+	var other []*MapArgumentValue
+	expressions := map[string]string{}
+	set := func(key string, raw *RawArgument) bool {
+		f, ok := fields[key]
+		if !ok || raw == nil || raw.Value == nil {
+			return false
+		}
+		switch {
+		case f.Kind() == reflect.String && (raw.Value.AsString != nil || !raw.IsLiteral):
+			f.SetString(raw.String())
+			if !raw.IsLiteral {
+				expressions[key] = raw.String()
+			}
+		case f.Kind() == reflect.Bool && raw.Value.AsBool != nil:
+			f.SetBool(*raw.Value.AsBool)
+		case f.Kind() == reflect.Bool && !raw.IsLiteral:
+			b, err := strconv.ParseBool(raw.String())
+			if err != nil {
+				return false
+			}
+			f.SetBool(b)
+		default:
+			return false
+		}
+		return true
+	}
+	if a.Argument != nil && !set(single, a.Argument) && !(a.Type == AgentDockerfile && a.Argument.Value != nil &&
+		a.Argument.Value.AsBool != nil && *a.Argument.Value.AsBool) {
+		other = append(other, &MapArgumentValue{Key: single, Value: &MapArgumentValueRawOrList{Raw: a.Argument}})
+	}
+	for _, arg := range a.Arguments {
+		if arg == nil {
+			continue
+		}
+		if arg.Value == nil || !set(arg.Key, arg.Value.Raw) {
+			other = append(other, arg)
+		}
+	}
+	return other, expressions
+}
+
+// toAgentArgs returns the map arguments of the tagged fields of v which aren't empty, followed by other. Fields still
+// holding the source of the expression they were read from are written as that expression.
+func toAgentArgs(v interface{}, other []*MapArgumentValue, expressions map[string]string) []*MapArgumentValue {
+	var out []*MapArgumentValue
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, _ := parseTag(rv.Type().Field(i).Tag.Get("jenkins"))
+		f := rv.Field(i)
+		if name == "" || isZero(f) {
+			continue
+		}
+		value := &RawArgumentValue{}
+		raw := &RawArgument{IsLiteral: true, Value: value}
+		switch f.Kind() {
+		case reflect.String:
+			s := f.String()
+			value.AsString = &s
+			if source, ok := expressions[name]; ok && source == s {
+				raw.IsLiteral = false
+			}
+		case reflect.Bool:
+			b := f.Bool()
+			value.AsBool = &b
+		}
+		out = append(out, &MapArgumentValue{Key: name, Value: &MapArgumentValueRawOrList{Raw: raw}})
+	}
+	return append(out, other...)
+}
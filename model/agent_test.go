@@ -0,0 +1,126 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsDocker(t *testing.T) {
+	a := &Agent{}
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "docker", "arguments": [
+		{"key": "image", "value": {"isLiteral": false, "value": "\"${REGISTRY}/builder:${TAG}\""}},
+		{"key": "args", "value": {"isLiteral": true, "value": "-v /tmp:/tmp"}},
+		{"key": "reuseNode", "value": {"isLiteral": true, "value": true}},
+		{"key": "containerPerStageRoot", "value": {"isLiteral": true, "value": true}}
+	]}`), a))
+
+	d, ok := a.AsDocker()
+	require.True(t, ok)
+	assert.Equal(t, `"${REGISTRY}/builder:${TAG}"`, d.Image)
+	assert.True(t, d.ReuseNode)
+	assert.Equal(t, "-v /tmp:/tmp", d.Args)
+	require.Len(t, d.Other, 1)
+	assert.Equal(t, "containerPerStageRoot", d.Other[0].Key)
+
+	// Converting back gives the same agent, with the unchanged image still an expression.
+	before, err := json.Marshal(a)
+	require.NoError(t, err)
+	after, err := json.Marshal(d.Agent())
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after))
+
+	d.Image = "maven:3"
+	d.Args = ""
+	b, err := json.Marshal(d.Agent())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "docker", "arguments": [
+		{"key": "image", "value": {"isLiteral": true, "value": "maven:3"}},
+		{"key": "reuseNode", "value": {"isLiteral": true, "value": true}},
+		{"key": "containerPerStageRoot", "value": {"isLiteral": true, "value": true}}
+	]}`, string(b))
+
+	short, ok := (&Agent{Type: "docker", Argument: &RawArgument{IsLiteral: true,
+		Value: &RawArgumentValue{AsString: stringPointer("node:14")}}}).AsDocker()
+	require.True(t, ok)
+	assert.Equal(t, &DockerAgent{Image: "node:14", expressions: map[string]string{}}, short)
+
+	_, ok = AnyAgent().AsDocker()
+	assert.False(t, ok)
+	_, ok = (*Agent)(nil).AsDocker()
+	assert.False(t, ok)
+}
+
+func TestAsNode(t *testing.T) {
+	n, ok := (&Agent{Type: "label", Argument: &RawArgument{IsLiteral: true,
+		Value: &RawArgumentValue{AsString: stringPointer("linux")}}}).AsNode()
+	require.True(t, ok)
+	assert.Equal(t, "linux", n.Label)
+	b, err := json.Marshal(n.Agent())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "label", "argument": {"isLiteral": true, "value": "linux"}}`, string(b))
+
+	n.CustomWorkspace = "/ws"
+	b, err = json.Marshal(n.Agent())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "node", "arguments": [
+		{"key": "label", "value": {"isLiteral": true, "value": "linux"}},
+		{"key": "customWorkspace", "value": {"isLiteral": true, "value": "/ws"}}
+	]}`, string(b))
+
+	_, ok = NoAgent().AsNode()
+	assert.False(t, ok)
+}
+
+func TestAsDockerfile(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		yes := true
+		a := &Agent{Type: "dockerfile", Argument: &RawArgument{IsLiteral: true, Value: &RawArgumentValue{AsBool: &yes}}}
+		d, ok := a.AsDockerfile()
+		require.True(t, ok)
+		assert.Empty(t, d.Other)
+		assert.Equal(t, a, d.Agent())
+	})
+	t.Run("arguments", func(t *testing.T) {
+		d := &DockerfileAgent{Filename: "Dockerfile.build", Dir: "build", AdditionalBuildArgs: "--build-arg V=1"}
+		a := d.Agent()
+		b, err := json.Marshal(a)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "dockerfile", "arguments": [
+			{"key": "filename", "value": {"isLiteral": true, "value": "Dockerfile.build"}},
+			{"key": "dir", "value": {"isLiteral": true, "value": "build"}},
+			{"key": "additionalBuildArgs", "value": {"isLiteral": true, "value": "--build-arg V=1"}}
+		]}`, string(b))
+		back, ok := a.AsDockerfile()
+		require.True(t, ok)
+		assert.Equal(t, d.Filename, back.Filename)
+		assert.Equal(t, d.AdditionalBuildArgs, back.AdditionalBuildArgs)
+	})
+}
+
+func TestAsKubernetes(t *testing.T) {
+	a := &Agent{}
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "kubernetes", "arguments": [
+		{"key": "yamlFile", "value": {"isLiteral": true, "value": "ci/pod.yaml"}},
+		{"key": "defaultContainer", "value": {"isLiteral": true, "value": "maven"}},
+		{"key": "idleMinutes", "value": {"isLiteral": true, "value": 5}}
+	]}`), a))
+	k, ok := a.AsKubernetes()
+	require.True(t, ok)
+	assert.Equal(t, "ci/pod.yaml", k.YamlFile)
+	assert.Equal(t, "maven", k.DefaultContainer)
+	require.Len(t, k.Other, 1)
+	assert.Equal(t, "idleMinutes", k.Other[0].Key)
+
+	k.Cloud = "eks"
+	b, err := json.Marshal(k.Agent())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "kubernetes", "arguments": [
+		{"key": "cloud", "value": {"isLiteral": true, "value": "eks"}},
+		{"key": "yamlFile", "value": {"isLiteral": true, "value": "ci/pod.yaml"}},
+		{"key": "defaultContainer", "value": {"isLiteral": true, "value": "maven"}},
+		{"key": "idleMinutes", "value": {"isLiteral": true, "value": 5}}
+	]}`, string(b))
+}
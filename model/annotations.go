@@ -0,0 +1,186 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Well-known annotation keys. Tools may use any other keys; prefixing them with a domain name avoids clashes.
+const (
+	// AnnotationGeneratedBy names the tool which generated a node.
+	AnnotationGeneratedBy = "generated-by"
+	// AnnotationTemplateVersion is the version of the template a node was generated from.
+	AnnotationTemplateVersion = "template-version"
+	// AnnotationTicket is the ID of the ticket a node was added or changed for.
+	AnnotationTicket = "ticket"
+	// AnnotationProvenance lists the rules, templates, and transformations which produced or modified a node, one per
+	// line, oldest first. Use AddProvenance and Provenance rather than setting it directly.
+	AnnotationProvenance = "provenance"
+)
+
+// Annotations are key/value metadata which tools attach to pipelines, stages, and steps, such as where a stage came
+// from. They live on the nodes themselves, so they move with them when a pipeline is transformed. Jenkins knows
+// nothing of them, so MarshalJSON leaves them out; MarshalExtended and UnmarshalExtended keep them.
+type Annotations map[string]string
+
+// Get returns the value of an annotation, or "" if it isn't set
+func (a Annotations) Get(key string) string {
+	return a[key]
+}
+
+// Set sets an annotation, creating the map if needed
+func (a *Annotations) Set(key string, value string) {
+	if *a == nil {
+		*a = Annotations{}
+	}
+	(*a)[key] = value
+}
+
+// AddProvenance records that a rule, template, or transformation produced or modified the node. Entries should name
+// the tool and what it did, such as "scripted: stage block at line 4".
+func (a *Annotations) AddProvenance(entry string) {
+	if prev := a.Get(AnnotationProvenance); prev != "" {
+		entry = prev + "\n" + entry
+	}
+	a.Set(AnnotationProvenance, entry)
+}
+
+// Provenance returns the entries recorded by AddProvenance, oldest first
+func (a Annotations) Provenance() []string {
+	if a.Get(AnnotationProvenance) == "" {
+		return nil
+	}
+	return strings.Split(a.Get(AnnotationProvenance), "\n")
+}
+
+// StageProvenance is the provenance of a single stage
+type StageProvenance struct {
+	Stage string `json:"stage"`
+	// Path is the JSON pointer of the stage, as used by MarshalExtended.
+	Path       string   `json:"path"`
+	Provenance []string `json:"provenance,omitempty"`
+}
+
+// ProvenanceReport lists every stage in a pipeline, in depth-first order, with the provenance recorded for it
+func ProvenanceReport(root *Root) []*StageProvenance {
+	var report []*StageProvenance
+	eachAnnotated(root, func(path string, node interface{}, a *Annotations) {
+		if s, ok := node.(*Stage); ok {
+			report = append(report, &StageProvenance{Stage: s.Name, Path: path, Provenance: a.Provenance()})
+		}
+	})
+	return report
+}
+
+// extendedRoot is the extended serialization of a Root: the Kyoto AST, plus the annotations of its nodes keyed by the
+// JSON pointer of each node within it
+type extendedRoot struct {
+	Pipeline    *Pipeline              `json:"pipeline"`
+	Annotations map[string]Annotations `json:"annotations,omitempty"`
+}
+
+// MarshalExtended marshals a Root along with its nodes' annotations. The result is the usual JSON with an extra
+// "annotations" property, mapping JSON pointers such as "/pipeline/stages/0" to the annotations of the node there.
+// Jenkins rejects it, so use MarshalJSON for anything Jenkins reads.
+func MarshalExtended(root *Root) ([]byte, error) {
+	ext := &extendedRoot{Pipeline: root.Pipeline, Annotations: map[string]Annotations{}}
+	eachAnnotated(root, func(path string, node interface{}, a *Annotations) {
+		if found := withDependsOn(node, withRawGroovy(node, *a)); len(found) > 0 {
+			ext.Annotations[path] = found
+		}
+	})
+	return json.Marshal(ext)
+}
+
+// UnmarshalExtended unmarshals a Root marshaled by MarshalExtended, restoring its nodes' annotations, and assigns
+// UIDs to any nodes without them. Plain JSON without annotations is accepted too.
+func UnmarshalExtended(b []byte, root *Root) error {
+	ext := &extendedRoot{}
+	if err := json.Unmarshal(b, ext); err != nil {
+		return err
+	}
+	if ext.Pipeline == nil {
+		return errors.New("\"pipeline\" is required but was not present")
+	}
+	root.Pipeline = ext.Pipeline
+	eachAnnotated(root, func(path string, node interface{}, a *Annotations) {
+		if found, ok := ext.Annotations[path]; ok {
+			*a = found
+			restoreRawGroovy(node, a)
+			restoreDependsOn(node, a)
+			delete(ext.Annotations, path)
+		}
+	})
+	if len(ext.Annotations) > 0 {
+		var paths []string
+		for path := range ext.Annotations {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return fmt.Errorf("annotations for %q: no pipeline, stage, or step there", paths[0])
+	}
+	AssignUIDs(root)
+	return nil
+}
+
+// eachAnnotated calls fn with the JSON pointer, node, and annotations of the pipeline and each of its stages and
+// steps
+func eachAnnotated(root *Root, fn func(path string, node interface{}, a *Annotations)) {
+	p := root.Pipeline
+	if p == nil {
+		return
+	}
+	fn("/pipeline", p, &p.Annotations)
+	annotatedPost("/pipeline/post", p.Post, fn)
+	annotatedStages("/pipeline/stages", p.Stages, fn)
+}
+
+func annotatedStages(path string, stages []*Stage, fn func(path string, node interface{}, a *Annotations)) {
+	for i, s := range stages {
+		if s == nil {
+			continue
+		}
+		at := fmt.Sprintf("%s/%d", path, i)
+		fn(at, s, &s.Annotations)
+		for j, b := range s.Branches {
+			if b != nil {
+				annotatedSteps(fmt.Sprintf("%s/branches/%d/steps", at, j), b.Steps, fn)
+			}
+		}
+		annotatedPost(at+"/post", s.Post, fn)
+		annotatedStages(at+"/stages", s.Stages, fn)
+		annotatedStages(at+"/parallel", s.Parallel, fn)
+		if s.Matrix != nil {
+			annotatedPost(at+"/matrix/post", s.Matrix.Post, fn)
+			annotatedStages(at+"/matrix/stages", s.Matrix.Stages, fn)
+		}
+	}
+}
+
+func annotatedPost(path string, post *Post, fn func(path string, node interface{}, a *Annotations)) {
+	if post == nil {
+		return
+	}
+	for i, c := range post.Conditions {
+		if c != nil && c.Branch != nil {
+			annotatedSteps(fmt.Sprintf("%s/conditions/%d/branch/steps", path, i), c.Branch.Steps, fn)
+		}
+	}
+}
+
+func annotatedSteps(path string, steps []*AnyStep, fn func(path string, node interface{}, a *Annotations)) {
+	for i, s := range steps {
+		at := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case s == nil:
+		case s.Step != nil:
+			fn(at, s.Step, &s.Step.Annotations)
+		case s.Tree != nil:
+			fn(at, s.Tree, &s.Tree.Annotations)
+			annotatedSteps(at+"/children", s.Tree.Children, fn)
+		}
+	}
+}
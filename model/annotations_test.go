@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "json", "parallel", "parallelPipelineWithFailFast.json"))
+	require.NoError(t, err)
+	root := &Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+
+	root.Pipeline.Annotations.Set(AnnotationGeneratedBy, "templater")
+	stage := root.Pipeline.Stages[0]
+	stage.Annotations.Set(AnnotationTemplateVersion, "1.2.0")
+	step := stage.Branches[0].Steps[0].Step
+	step.Annotations.Set(AnnotationTicket, "OPS-12")
+
+	plain, err := json.Marshal(root)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(contents), string(plain))
+
+	extended, err := MarshalExtended(root)
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(extended, &doc))
+	assert.Equal(t, map[string]interface{}{
+		"/pipeline":                             map[string]interface{}{"generated-by": "templater"},
+		"/pipeline/stages/0":                    map[string]interface{}{"template-version": "1.2.0"},
+		"/pipeline/stages/0/branches/0/steps/0": map[string]interface{}{"ticket": "OPS-12"},
+	}, doc["annotations"])
+
+	decoded := &Root{}
+	require.NoError(t, UnmarshalExtended(extended, decoded))
+	eachAnnotated(decoded, func(path string, _ interface{}, a *Annotations) {
+		assert.NotEmpty(t, a.Get(AnnotationUID), path)
+		delete(*a, AnnotationUID)
+		if len(*a) == 0 {
+			*a = nil
+		}
+	})
+	assert.Equal(t, root, decoded)
+	assert.Equal(t, "OPS-12", decoded.Pipeline.Stages[0].Branches[0].Steps[0].Step.Annotations.Get(AnnotationTicket))
+
+	decoded = &Root{}
+	require.NoError(t, UnmarshalExtended(contents, decoded))
+	assert.Equal(t, []string{AnnotationUID}, annotationKeys(decoded.Pipeline.Annotations))
+
+	err = UnmarshalExtended([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": []},
+		"annotations": {"/pipeline/stages/3": {"a": "b"}}}`), &Root{})
+	assert.EqualError(t, err, `annotations for "/pipeline/stages/3": no pipeline, stage, or step there`)
+}
+
+func TestDependsOn(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "extended", "dependsOn.json"))
+	require.NoError(t, err)
+	root := &Root{}
+	require.NoError(t, UnmarshalExtended(contents, root))
+	deploy := root.Pipeline.Stages[3]
+	assert.Equal(t, []string{"Test", "Docs"}, deploy.DependsOn)
+	assert.Empty(t, deploy.Annotations.Get(AnnotationDependsOn))
+
+	plain, err := json.Marshal(root)
+	require.NoError(t, err)
+	assert.NotContains(t, string(plain), "dependsOn")
+	assert.NotContains(t, string(plain), AnnotationDependsOn)
+
+	extended, err := MarshalExtended(root)
+	require.NoError(t, err)
+	decoded := &Root{}
+	require.NoError(t, UnmarshalExtended(extended, decoded))
+	assert.Equal(t, root, decoded)
+
+	err = json.Unmarshal([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Deploy",
+		"dependsOn": ["Build"], "branches": []}]}}`), &Root{})
+	assert.EqualError(t, err, `/pipeline/stages/0: additional property not allowed: "dependsOn"`)
+}
+
+func TestDependsOnLenient(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "extended", "dependsOn.json"))
+	require.NoError(t, err)
+	root := &Root{}
+	require.NoError(t, UnmarshalExtended(contents, root))
+	ClearUIDs(root)
+
+	b, err := Marshal(root, MarshalDependsOn())
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"name":"Deploy","dependsOn":["Test","Docs"]}`)
+	_, err = Decode(b)
+	assert.EqualError(t, err, `/pipeline/stages/2: additional property not allowed: "dependsOn"`)
+
+	unknown := map[string]json.RawMessage{}
+	decoded, err := Decode(b, CollectUnknownFields(unknown))
+	require.NoError(t, err)
+	assert.Equal(t, root, decoded)
+	assert.Empty(t, unknown)
+
+	decoded, err = Decode([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Test", "matrix": {
+		"axes": [], "stages": [{"name": "Unit", "dependsOn": ["Lint"], "branches": []}]}, "dependsOn": "Build"}]}}`),
+		CollectUnknownFields(unknown))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Lint"}, decoded.Pipeline.Stages[0].Matrix.Stages[0].DependsOn)
+	assert.Empty(t, decoded.Pipeline.Stages[0].DependsOn)
+	assert.Equal(t, map[string]json.RawMessage{"/pipeline/stages/0/dependsOn": json.RawMessage(`"Build"`)}, unknown)
+}
+
+func TestProvenanceReport(t *testing.T) {
+	inner := &Stage{Name: "inner"}
+	inner.Annotations.AddProvenance("template: build@1.0")
+	inner.Annotations.AddProvenance("rule: add-timeout")
+	root := &Root{Pipeline: &Pipeline{Stages: []*Stage{
+		{Name: "outer", Stages: []*Stage{inner}},
+	}}}
+
+	assert.Equal(t, []string{"template: build@1.0", "rule: add-timeout"}, inner.Annotations.Provenance())
+	assert.Equal(t, []*StageProvenance{
+		{Stage: "outer", Path: "/pipeline/stages/0"},
+		{Stage: "inner", Path: "/pipeline/stages/0/stages/0", Provenance: []string{"template: build@1.0", "rule: add-timeout"}},
+	}, ProvenanceReport(root))
+}
+
+func annotationKeys(a Annotations) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	return keys
+}
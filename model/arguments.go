@@ -0,0 +1,48 @@
+package model
+
+import (
+	"strconv"
+)
+
+// String returns the value formatted as a string, with integral floats rendered without a fractional part
+func (strct *RawArgumentValue) String() string {
+	if strct == nil {
+		return ""
+	}
+	switch {
+	case strct.AsString != nil:
+		return *strct.AsString
+	case strct.AsBool != nil:
+		return strconv.FormatBool(*strct.AsBool)
+	case strct.AsInteger != nil:
+		return strconv.FormatInt(*strct.AsInteger, 10)
+	case strct.AsFloat != nil:
+		return strconv.FormatFloat(*strct.AsFloat, 'f', -1, 64)
+	}
+	return ""
+}
+
+// String returns the argument's value formatted as a string. Non-literal values are returned as their Groovy source.
+func (strct *RawArgument) String() string {
+	if strct == nil {
+		return ""
+	}
+	return strct.Value.String()
+}
+
+// Get returns the named argument with the given key, or nil if it is not present. If the list holds a single unnamed
+// argument, that argument is returned for any key, since it is the step's default parameter.
+func (strct *ArgumentList) Get(key string) *RawArgument {
+	if strct == nil {
+		return nil
+	}
+	if strct.Single != nil {
+		return strct.Single
+	}
+	for _, a := range strct.Named {
+		if a != nil && a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+)
+
+var rawArgumentType = reflect.TypeOf(RawArgument{})
+
+// Bind decodes the arguments into the struct dst points to. Fields are matched to arguments by their jenkins tag, as
+// in `jenkins:"returnStdout"`; fields without a tag, or tagged "-", are left alone. The tag options "default" and
+// "required" mark the field a single unnamed argument is decoded into, and a field whose argument must be present.
+//
+// Literal values are converted to the field's type. Non-literal values are Groovy source, and are decoded if they are
+// constant expressions: uninterpolated strings, numbers, booleans, null, and lists and maps of them, which can be
+// decoded into slices, maps, structs, and interface{} fields. Other expressions, such as variable references, can
+// only be decoded into RawArgument or *RawArgument fields, which receive any argument as it is.
+func (strct *ArgumentList) Bind(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("a non-nil pointer to a struct is required")
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, opts := parseTag(t.Field(i).Tag.Get("jenkins"))
+		if name == "" || name == "-" || t.Field(i).PkgPath != "" {
+			continue
+		}
+		var arg *RawArgument
+		switch {
+		case strct == nil:
+		case strct.Single != nil:
+			if opts["default"] {
+				arg = strct.Single
+			}
+		default:
+			arg = strct.Get(name)
+		}
+		if arg == nil {
+			if opts["required"] {
+				return fmt.Errorf("argument %q is required", name)
+			}
+			continue
+		}
+		if err := bindRaw(v.Field(i), arg); err != nil {
+			return fmt.Errorf("argument %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func parseTag(tag string) (string, map[string]bool) {
+	parts := strings.Split(tag, ",")
+	opts := map[string]bool{}
+	for _, o := range parts[1:] {
+		opts[o] = true
+	}
+	return parts[0], opts
+}
+
+func bindRaw(v reflect.Value, arg *RawArgument) error {
+	switch {
+	case v.Type() == rawArgumentType:
+		v.Set(reflect.ValueOf(*arg))
+		return nil
+	case v.Type() == reflect.PtrTo(rawArgumentType):
+		v.Set(reflect.ValueOf(arg))
+		return nil
+	case !arg.IsLiteral:
+		e, err := groovy.ParseExpr(arg.String())
+		if err != nil {
+			return err
+		}
+		return bindExpr(v, e)
+	}
+	val := arg.Value
+	if val == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch {
+	case val.AsString != nil:
+		return bindScalar(v, reflect.ValueOf(*val.AsString))
+	case val.AsBool != nil:
+		return bindScalar(v, reflect.ValueOf(*val.AsBool))
+	case val.AsInteger != nil:
+		return bindScalar(v, reflect.ValueOf(float64(*val.AsInteger)))
+	case val.AsFloat != nil:
+		return bindScalar(v, reflect.ValueOf(*val.AsFloat))
+	}
+	v.Set(reflect.Zero(v.Type()))
+	return nil
+}
+
+// bindScalar sets v to a string, bool, or float64 value, converting it to v's type
+func bindScalar(v reflect.Value, x reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := bindScalar(p.Elem(), x); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Kind() == reflect.Interface && x.Type().AssignableTo(v.Type()) {
+		v.Set(x)
+		return nil
+	}
+	switch x.Kind() {
+	case reflect.String:
+		s := x.String()
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(s)
+			return nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("cannot decode %q as a boolean", s)
+			}
+			v.SetBool(b)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("cannot decode %q as a number", s)
+			}
+			return bindScalar(v, reflect.ValueOf(f))
+		}
+	case reflect.Bool:
+		switch v.Kind() {
+		case reflect.Bool:
+			v.SetBool(x.Bool())
+			return nil
+		case reflect.String:
+			v.SetString(strconv.FormatBool(x.Bool()))
+			return nil
+		}
+	case reflect.Float64:
+		f := x.Float()
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f != float64(int64(f)) || v.OverflowInt(int64(f)) {
+				return fmt.Errorf("cannot decode %v as %s", f, v.Type())
+			}
+			v.SetInt(int64(f))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f < 0 || f != float64(uint64(f)) || v.OverflowUint(uint64(f)) {
+				return fmt.Errorf("cannot decode %v as %s", f, v.Type())
+			}
+			v.SetUint(uint64(f))
+			return nil
+		case reflect.String:
+			v.SetString(strconv.FormatFloat(f, 'f', -1, 64))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %s value into %s", x.Kind(), v.Type())
+}
+
+// bindExpr sets v from a constant Groovy expression
+func bindExpr(v reflect.Value, e *groovy.Expr) error {
+	switch e.Kind {
+	case groovy.ExprNull:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case groovy.ExprString:
+		if e.Interpolated {
+			return fmt.Errorf("%s is an interpolated string", e.Raw)
+		}
+		return bindScalar(v, reflect.ValueOf(e.Value))
+	case groovy.ExprBool:
+		return bindScalar(v, reflect.ValueOf(e.Value == "true"))
+	case groovy.ExprNumber:
+		s := strings.TrimRight(strings.ReplaceAll(e.Value, "_", ""), "lLgGiIdDfF")
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("cannot decode %s as a number", e.Raw)
+		}
+		return bindScalar(v, reflect.ValueOf(f))
+	case groovy.ExprList, groovy.ExprMap:
+		if v.Kind() == reflect.Ptr {
+			p := reflect.New(v.Type().Elem())
+			if err := bindExpr(p.Elem(), e); err != nil {
+				return err
+			}
+			v.Set(p)
+			return nil
+		}
+		if e.Kind == groovy.ExprList {
+			return bindList(v, e)
+		}
+		return bindMap(v, e)
+	}
+	return fmt.Errorf("%s is not a constant value", e.Raw)
+}
+
+func bindList(v reflect.Value, e *groovy.Expr) error {
+	t := v.Type()
+	if v.Kind() == reflect.Interface {
+		t = reflect.TypeOf([]interface{}{})
+	} else if v.Kind() != reflect.Slice {
+		return fmt.Errorf("cannot decode list into %s", v.Type())
+	}
+	s := reflect.MakeSlice(t, len(e.Elements), len(e.Elements))
+	for i, el := range e.Elements {
+		if err := bindExpr(s.Index(i), el.Value); err != nil {
+			return fmt.Errorf("element %d: %s", i, err)
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+func bindMap(v reflect.Value, e *groovy.Expr) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Map:
+		t := v.Type()
+		if v.Kind() == reflect.Interface {
+			t = reflect.TypeOf(map[string]interface{}{})
+		} else if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot decode map into %s", t)
+		}
+		m := reflect.MakeMap(t)
+		for _, el := range e.Elements {
+			val := reflect.New(t.Elem()).Elem()
+			if err := bindExpr(val, el.Value); err != nil {
+				return fmt.Errorf("key %q: %s", el.Key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(el.Key).Convert(t.Key()), val)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		t := v.Type()
+		fields := map[string]int{}
+		for i := 0; i < t.NumField(); i++ {
+			if name, _ := parseTag(t.Field(i).Tag.Get("jenkins")); name != "" && name != "-" && t.Field(i).PkgPath == "" {
+				fields[name] = i
+			}
+		}
+		for _, el := range e.Elements {
+			i, ok := fields[el.Key]
+			if !ok {
+				continue
+			}
+			if err := bindExpr(v.Field(i), el.Value); err != nil {
+				return fmt.Errorf("key %q: %s", el.Key, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot decode map into %s", v.Type())
+}
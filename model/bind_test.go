@@ -0,0 +1,98 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseArgs(t *testing.T, src string) *ArgumentList {
+	args := &ArgumentList{}
+	require.NoError(t, json.Unmarshal([]byte(src), args))
+	return args
+}
+
+type checkoutArgs struct {
+	Branches []struct {
+		Name string `jenkins:"name"`
+	} `jenkins:"branches"`
+	Extensions []interface{} `jenkins:"extensions"`
+}
+
+type shArgs struct {
+	Script       string            `jenkins:"script,default,required"`
+	ReturnStdout bool              `jenkins:"returnStdout"`
+	Label        *string           `jenkins:"label"`
+	Encoding     *RawArgument      `jenkins:"encoding"`
+	Retries      int               `jenkins:"retries"`
+	Env          map[string]string `jenkins:"env"`
+	Targets      []string          `jenkins:"targets"`
+	Ignored      string
+}
+
+func TestBind(t *testing.T) {
+	args := parseArgs(t, `[
+		{"key": "script", "value": {"isLiteral": true, "value": "make"}},
+		{"key": "returnStdout", "value": {"isLiteral": true, "value": true}},
+		{"key": "label", "value": {"isLiteral": false, "value": "'build'"}},
+		{"key": "encoding", "value": {"isLiteral": false, "value": "env.ENCODING"}},
+		{"key": "retries", "value": {"isLiteral": true, "value": 3}},
+		{"key": "env", "value": {"isLiteral": false, "value": "[A: '1', 'B-C': \"2\"]"}},
+		{"key": "targets", "value": {"isLiteral": false, "value": "['all', 'test']"}}
+	]`)
+	got := &shArgs{}
+	require.NoError(t, args.Bind(got))
+	label := "build"
+	assert.Equal(t, &shArgs{
+		Script:       "make",
+		ReturnStdout: true,
+		Label:        &label,
+		Encoding:     args.Get("encoding"),
+		Retries:      3,
+		Env:          map[string]string{"A": "1", "B-C": "2"},
+		Targets:      []string{"all", "test"},
+	}, got)
+
+	got = &shArgs{}
+	require.NoError(t, parseArgs(t, `{"isLiteral": true, "value": "make"}`).Bind(got))
+	assert.Equal(t, &shArgs{Script: "make"}, got)
+}
+
+func TestBindNested(t *testing.T) {
+	args := parseArgs(t, `[{"key": "branches", "value": {"isLiteral": false,
+		"value": "[[name: '*/main'], [name: 'dev']]"}},
+		{"key": "extensions", "value": {"isLiteral": false, "value": "[[depth: 1, shallow: true], 'x', []]"}}]`)
+	got := &checkoutArgs{}
+	require.NoError(t, args.Bind(got))
+	require.Len(t, got.Branches, 2)
+	assert.Equal(t, "*/main", got.Branches[0].Name)
+	assert.Equal(t, "dev", got.Branches[1].Name)
+	assert.Equal(t, []interface{}{map[string]interface{}{"depth": 1.0, "shallow": true}, "x", []interface{}{}},
+		got.Extensions)
+}
+
+func TestBindErrors(t *testing.T) {
+	assert.EqualError(t, (&ArgumentList{}).Bind(shArgs{}), "a non-nil pointer to a struct is required")
+	assert.EqualError(t, (&ArgumentList{Named: []*ArgumentValue{}}).Bind(&shArgs{}), `argument "script" is required`)
+
+	bad := []struct {
+		src string
+		err string
+	}{
+		{`[{"key": "script", "value": {"isLiteral": false, "value": "\"${x}\""}}]`,
+			`argument "script": "${x}" is an interpolated string`},
+		{`[{"key": "script", "value": {"isLiteral": false, "value": "env.X"}}]`,
+			`argument "script": env.X is not a constant value`},
+		{`[{"key": "script", "value": {"isLiteral": true, "value": "x"}}, {"key": "retries", "value": {"isLiteral": true, "value": 1.5}}]`,
+			`argument "retries": cannot decode 1.5 as int`},
+		{`[{"key": "script", "value": {"isLiteral": true, "value": "x"}}, {"key": "returnStdout", "value": {"isLiteral": true, "value": "yes"}}]`,
+			`argument "returnStdout": cannot decode "yes" as a boolean`},
+		{`[{"key": "script", "value": {"isLiteral": true, "value": "x"}}, {"key": "targets", "value": {"isLiteral": false, "value": "['a', b]"}}]`,
+			`argument "targets": element 1: b is not a constant value`},
+	}
+	for _, tc := range bad {
+		assert.EqualError(t, parseArgs(t, tc.src).Bind(&shArgs{}), tc.err)
+	}
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// defaultFalse are the properties which mean the same when false as when left out
+var defaultFalse = map[string]bool{
+	"failFast":      true,
+	"beforeAgent":   true,
+	"beforeInput":   true,
+	"beforeOptions": true,
+	"inverse":       true,
+}
+
+// MarshalCanonical marshals a pipeline's JSON in a canonical form, which is byte-for-byte the same for the same
+// pipeline, for diffing, hashing, and signing. Object keys are sorted, rather than in the order MarshalJSON writes
+// them, and there is no whitespace between tokens. Properties which mean the same when false as when left out, such
+// as failFast and beforeAgent, are left out when false. Numbers with integer values are written as integers, so 1,
+// 1.0, and 1.000000 are all written 1, and others in the shortest form which reads back the same. Strings are escaped
+// as by encoding/json, but without escaping <, >, and &. Unions with none of their alternatives set are errors, as
+// with StrictMarshal.
+func MarshalCanonical(root *Root) ([]byte, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	b, err := Marshal(root, StrictMarshal())
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc, err = normalize(doc); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// normalize drops properties set to their default of false from a decoded document, and rewrites its numbers as
+// integers where they have integer values, and otherwise in the shortest form which reads back the same. Objects are
+// maps, which encoding/json writes with their keys sorted.
+func normalize(v interface{}) (interface{}, error) {
+	var err error
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if defaultFalse[k] && e == false {
+				delete(t, k)
+				continue
+			}
+			if t[k], err = normalize(e); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			if t[i], err = normalize(e); err != nil {
+				return nil, err
+			}
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return json.Number(strconv.FormatInt(int64(f), 10)), nil
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+	return v, nil
+}
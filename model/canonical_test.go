@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	threshold := 2.50
+	root := &Root{Pipeline: &Pipeline{
+		Agent: AnyAgent(),
+		Stages: []*Stage{{
+			Name: "Build <main>",
+			When: &When{BeforeAgent: false, Conditions: []*StepOrNestedWhenCondition{
+				NewWhenStep(&Step{Name: "branch", Arguments: NewSingleArgument(&RawArgument{IsLiteral: true,
+					Value: &RawArgumentValue{AsString: strPtr("main")}})}),
+			}},
+			Branches: []*Branch{{Name: "default", Steps: []*AnyStep{NewAnyStep(&Step{Name: "check",
+				Arguments: NewSingleArgument(&RawArgument{IsLiteral: true,
+					Value: &RawArgumentValue{AsFloat: &threshold}})})}}},
+		}},
+	}}
+	b, err := MarshalCanonical(root)
+	require.NoError(t, err)
+	assert.Equal(t, `{"pipeline":{"agent":{"type":"any"},"stages":[{"branches":[{"name":"default","steps":[{"arguments":`+
+		`{"isLiteral":true,"value":2.5},"name":"check"}]}],"name":"Build <main>","when":{"conditions":[{"arguments":`+
+		`{"isLiteral":true,"value":"main"},"name":"branch"}]}}]}}`, string(b))
+
+	again, err := MarshalCanonical(root)
+	require.NoError(t, err)
+	assert.Equal(t, b, again)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// TestMarshalCanonicalRoundTrip checks that the canonical form of every test pipeline reads back as a pipeline with
+// the same canonical form.
+func TestMarshalCanonicalRoundTrip(t *testing.T) {
+	err := filepath.Walk(filepath.Join("testdata", "json"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		t.Run(path, func(t *testing.T) {
+			contents, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			root := &Root{}
+			require.NoError(t, json.Unmarshal(contents, root))
+			b, err := MarshalCanonical(root)
+			require.NoError(t, err)
+			assert.NotContains(t, string(b), `"failFast":false`)
+
+			read := &Root{}
+			require.NoError(t, json.Unmarshal(b, read))
+			again, err := MarshalCanonical(read)
+			require.NoError(t, err)
+			assert.Equal(t, string(b), string(again))
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
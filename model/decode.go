@@ -0,0 +1,251 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/metrics"
+)
+
+// DecodeError is an error decoding a pipeline's JSON, with where in the JSON it happened. Decoding a Root returns
+// one for any error in its pipeline.
+type DecodeError struct {
+	// Path is the JSON pointer of the value which couldn't be decoded, such as "/pipeline/stages/3/branches/0/steps/2".
+	Path string
+	Err  error
+
+	// unknown are all the properties the model doesn't have, when they're the only errors. Objects carry on decoding
+	// past them, so Decode can ignore or collect every one in a single pass; Err is then the first of them.
+	unknown unknownProperties
+	// lenient is the error to report instead if unknown properties are being ignored, when an object with some is
+	// missing a required property as well.
+	lenient error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// unknownProperty is a property the model doesn't have
+type unknownProperty struct {
+	// path is the JSON pointer of the object with the property
+	path     string
+	property string
+	value    json.RawMessage
+}
+
+// unknownProperties collects the unknown properties found while decoding an object or list
+type unknownProperties []*unknownProperty
+
+// add adds the unknown properties of an error decoding a value, returning false if err is any other error
+func (u *unknownProperties) add(err error) bool {
+	if err == nil {
+		return true
+	}
+	de, ok := err.(*DecodeError)
+	if !ok || de.unknown == nil {
+		return false
+	}
+	*u = append(*u, de.unknown...)
+	return true
+}
+
+// property adds a property of the object being decoded
+func (u *unknownProperties) property(name string, value json.RawMessage) {
+	*u = append(*u, &unknownProperty{property: name, value: value})
+}
+
+// error returns a *DecodeError for the first of the unknown properties, by path, or nil if there are none
+func (u unknownProperties) error() error {
+	if len(u) == 0 {
+		return nil
+	}
+	sort.SliceStable(u, func(i, j int) bool {
+		if u[i].path != u[j].path {
+			return u[i].path < u[j].path
+		}
+		return u[i].property < u[j].property
+	})
+	return &DecodeError{Path: u[0].path, Err: &UnknownPropertyError{Property: u[0].property}, unknown: u}
+}
+
+// missing returns err, the error for an object missing a required property. If the object has unknown properties too,
+// it returns the error for the first of them instead, as decoding strictly always has, keeping err for Decode to
+// report when it's ignoring them.
+func (u unknownProperties) missing(err error) error {
+	if len(u) == 0 {
+		return err
+	}
+	first := u.error().(*DecodeError)
+	return &DecodeError{Path: first.Path, Err: first.Err, lenient: err}
+}
+
+// decoded returns true if err is nil or only reports unknown properties, so everything else was decoded
+func decoded(err error) bool {
+	de, ok := err.(*DecodeError)
+	return err == nil || ok && de.unknown != nil
+}
+
+// unmarshalProperty unmarshals the value of an object's property, adding the property to the path of any error
+func unmarshalProperty(b []byte, v interface{}, property string) error {
+	if err := unmarshalValue(b, v); err != nil {
+		return atPath(err, property)
+	}
+	return nil
+}
+
+// unmarshalValue unmarshals a value. Lists are unmarshaled an element at a time, so an error's path has the index of
+// the element which couldn't be decoded, and the unknown properties of every element are kept.
+func unmarshalValue(b []byte, v interface{}) error {
+	list := reflect.ValueOf(v).Elem()
+	if list.Kind() != reflect.Slice || list.Type().Elem().Kind() == reflect.Uint8 {
+		return json.Unmarshal(b, v)
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(b, &elements); err != nil || elements == nil {
+		// Let json report values which aren't lists, and decode null.
+		return json.Unmarshal(b, v)
+	}
+	decoded := reflect.MakeSlice(list.Type(), len(elements), len(elements))
+	var unknown unknownProperties
+	for i, e := range elements {
+		if err := unmarshalValue(e, decoded.Index(i).Addr().Interface()); err != nil {
+			if err = atPath(err, strconv.Itoa(i)); !unknown.add(err) {
+				return err
+			}
+		}
+	}
+	list.Set(decoded)
+	return unknown.error()
+}
+
+// atPath adds a property name or list index to the start of an error's path, making it a *DecodeError if it isn't
+// one already
+func atPath(err error, segment string) error {
+	prefix := "/" + escape(segment)
+	de, ok := err.(*DecodeError)
+	if !ok {
+		return &DecodeError{Path: prefix, Err: err}
+	}
+	var unknown unknownProperties
+	for _, u := range de.unknown {
+		unknown = append(unknown, &unknownProperty{path: prefix + u.path, property: u.property, value: u.value})
+	}
+	var lenient error
+	if de.lenient != nil {
+		lenient = atPath(de.lenient, segment)
+	}
+	return &DecodeError{Path: prefix + de.Path, Err: de.Err, unknown: unknown, lenient: lenient}
+}
+
+// UnknownPropertyError is an error decoding an object with a property its type doesn't have, such as one added by a
+// newer version of the Kyoto AST
+type UnknownPropertyError struct {
+	Property string
+}
+
+func (e *UnknownPropertyError) Error() string {
+	return "additional property not allowed: \"" + e.Property + "\""
+}
+
+// DecodeOption changes how Decode decodes a pipeline
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	allowUnknown bool
+	unknown      map[string]json.RawMessage
+}
+
+// AllowUnknownFields makes Decode ignore properties the model doesn't have, rather than failing. It reads the dependsOn
+// property of stages, which Jenkins doesn't have either, into their DependsOn field.
+func AllowUnknownFields() DecodeOption {
+	return func(o *decodeOptions) {
+		o.allowUnknown = true
+	}
+}
+
+// CollectUnknownFields makes Decode ignore properties the model doesn't have, like AllowUnknownFields, and add their
+// values to fields, keyed by their JSON pointers, such as "/pipeline/stages/0/retries".
+func CollectUnknownFields(fields map[string]json.RawMessage) DecodeOption {
+	return func(o *decodeOptions) {
+		o.allowUnknown = true
+		o.unknown = fields
+	}
+}
+
+// Decode decodes a pipeline's JSON. By default it's strict, like json.Unmarshal, and fails on any property the model
+// doesn't have; options can make it lenient, so pipelines from newer versions of Jenkins, whose Kyoto AST has more
+// properties, can still be read.
+func Decode(b []byte, opts ...DecodeOption) (*Root, error) {
+	defer metrics.Since(metrics.ParseDuration, metrics.Labels{"format": FormatJSON}, time.Now())
+	o := &decodeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	root := &Root{}
+	err := json.Unmarshal(b, root)
+	if err == nil || !o.allowUnknown {
+		return root, err
+	}
+	de, ok := err.(*DecodeError)
+	if ok && de.lenient != nil {
+		return nil, de.lenient
+	}
+	if !ok || de.unknown == nil {
+		return nil, err
+	}
+	unknown := readDependsOn(root, de.unknown)
+	if o.unknown != nil {
+		for _, u := range unknown {
+			var value bytes.Buffer
+			if err := json.Compact(&value, u.value); err != nil {
+				return nil, err
+			}
+			o.unknown[u.path+"/"+escape(u.property)] = value.Bytes()
+		}
+	}
+	return root, nil
+}
+
+// escape escapes a property name for use in a JSON pointer
+func escape(segment string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(segment)
+}
+
+// hasProperty returns whether b is an object with the given property. Unions use it to report the error of the
+// variant the JSON is meant to be, when it's none of them.
+func hasProperty(b []byte, name string) bool {
+	var m map[string]json.RawMessage
+	if json.Unmarshal(b, &m) != nil {
+		return false
+	}
+	_, ok := m[name]
+	return ok
+}
+
+func isArray(b []byte) bool {
+	b = bytes.TrimSpace(b)
+	return len(b) > 0 && b[0] == '['
+}
+
+// firstElement returns the first element of an array, or nil if b isn't an array or is empty
+func firstElement(b []byte) []byte {
+	var elements []json.RawMessage
+	if json.Unmarshal(b, &elements) != nil || len(elements) == 0 {
+		return nil
+	}
+	return elements[0]
+}
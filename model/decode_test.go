@@ -0,0 +1,157 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeErrorPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		path     string
+		expected string
+	}{
+		{
+			name: "step",
+			json: `{"pipeline": {"agent": {"type": "any"}, "stages": [
+  {"name": "a", "branches": [{"name": "default", "steps": []}]},
+  {"name": "b", "branches": [{"name": "default", "steps": [
+    {"name": "echo", "arguments": []},
+    {"name": "sh", "arguments": [], "foo": true}
+  ]}]}
+]}}`,
+			path:     "/pipeline/stages/1/branches/0/steps/1",
+			expected: `additional property not allowed: "foo"`,
+		},
+		{
+			name: "matrix exclude",
+			json: `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "a", "matrix": {
+  "axes": [{"name": "OS", "values": [{"isLiteral": true, "value": "linux"}]}],
+  "excludes": [[{"name": "OS", "values": []}], [{"values": []}]],
+  "stages": []
+}}]}}`,
+			path:     "/pipeline/stages/0/matrix/excludes/1/0",
+			expected: `"name" is required but was not present`,
+		},
+		{
+			name:     "type",
+			json:     `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": 3}]}}`,
+			path:     "/pipeline/stages/0/name",
+			expected: "json: cannot unmarshal number into Go value of type string",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := json.Unmarshal([]byte(tc.json), &Root{})
+			var de *DecodeError
+			require.True(t, errors.As(err, &de), "%v", err)
+			assert.Equal(t, tc.path, de.Path)
+			assert.EqualError(t, de.Err, tc.expected)
+			assert.EqualError(t, err, tc.path+": "+tc.expected)
+		})
+	}
+}
+
+const newerPipeline = `{"pipeline": {
+  "agent": {"type": "any"},
+  "stages": [{
+    "name": "Build",
+    "retries": 3,
+    "when": {"conditions": [{"name": "allOf", "children": [
+      {"name": "branch", "arguments": {"isLiteral": true, "value": "main"}, "since": "2.0"}
+    ]}]},
+    "branches": [{"name": "default", "steps": [
+      {"name": "dir", "arguments": {"isLiteral": true, "value": "src"}, "children": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}], "label": "Make"}
+      ], "location": {"line": 9}}
+    ]}]
+  }]
+}, "version": 2}`
+
+func TestDecode(t *testing.T) {
+	_, err := Decode([]byte(newerPipeline))
+	var unknown *UnknownPropertyError
+	require.True(t, errors.As(err, &unknown), "%v", err)
+
+	root, err := Decode([]byte(newerPipeline), AllowUnknownFields())
+	require.NoError(t, err)
+	stage := root.Pipeline.Stages[0]
+	assert.Equal(t, "Build", stage.Name)
+	require.NotNil(t, stage.When.Conditions[0].Nested)
+	assert.Equal(t, "branch", stage.When.Conditions[0].Nested.Children[0].Step.Name)
+	require.NotNil(t, stage.Branches[0].Steps[0].Tree)
+	assert.Equal(t, "make", stage.Branches[0].Steps[0].Tree.Children[0].Step.Arguments.Get("script").String())
+
+	fields := map[string]json.RawMessage{}
+	_, err = Decode([]byte(newerPipeline), CollectUnknownFields(fields))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{
+		"/version":                   json.RawMessage(`2`),
+		"/pipeline/stages/0/retries": json.RawMessage(`3`),
+		"/pipeline/stages/0/when/conditions/0/children/0/since":  json.RawMessage(`"2.0"`),
+		"/pipeline/stages/0/branches/0/steps/0/location":         json.RawMessage(`{"line":9}`),
+		"/pipeline/stages/0/branches/0/steps/0/children/0/label": json.RawMessage(`"Make"`),
+	}, fields)
+}
+
+func TestDecodeOtherErrors(t *testing.T) {
+	_, err := Decode([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "a", "extra": 1, "when": 3}]}}`),
+		AllowUnknownFields())
+	var de *DecodeError
+	require.True(t, errors.As(err, &de), "%v", err)
+	assert.Equal(t, "/pipeline/stages/0/when", de.Path)
+}
+
+// TestDecodeManyUnknownFields checks that a pipeline with a lot of unknown properties decodes in one pass, with every
+// one of them collected.
+func TestDecodeManyUnknownFields(t *testing.T) {
+	const count = 5000
+	steps := make([]string, count)
+	for i := range steps {
+		steps[i] = fmt.Sprintf(`{"name": "sh", "arguments": {"isLiteral": true, "value": "make %d"}, "location": {"line": %d}}`,
+			i, i+5)
+	}
+	b := []byte(`{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build", "branches": [{"name": "default", ` +
+		`"steps": [` + strings.Join(steps, ",") + `]}]}]}}`)
+
+	_, err := Decode(b)
+	assert.EqualError(t, err, `/pipeline/stages/0/branches/0/steps/0: additional property not allowed: "location"`)
+
+	fields := map[string]json.RawMessage{}
+	root, err := Decode(b, CollectUnknownFields(fields))
+	require.NoError(t, err)
+	require.Len(t, root.Pipeline.Stages[0].Branches[0].Steps, count)
+	assert.Equal(t, "make 4999", root.Pipeline.Stages[0].Branches[0].Steps[4999].Step.Arguments.Single.Value.String())
+	require.Len(t, fields, count)
+	assert.Equal(t, json.RawMessage(`{"line":5004}`), fields["/pipeline/stages/0/branches/0/steps/4999/location"])
+}
+
+func TestDecodeUnknownAndMissingFields(t *testing.T) {
+	b := []byte(`{"pipeline": {"stages": [], "retries": 3}}`)
+	_, err := Decode(b)
+	assert.EqualError(t, err, `/pipeline: additional property not allowed: "retries"`)
+
+	_, err = Decode(b, AllowUnknownFields())
+	assert.EqualError(t, err, `/pipeline: "agent" is required but was not present`)
+}
+
+func TestDecodeUnionsSetOneAlternative(t *testing.T) {
+	arg := &MethodArg{}
+	require.NoError(t, json.Unmarshal([]byte(`{"key": "time", "value": {"isLiteral": true, "value": 10}}`), arg))
+	assert.Nil(t, arg.Single)
+	require.NotNil(t, arg.WithKey.Value.Single)
+	assert.Nil(t, arg.WithKey.Value.Call)
+
+	condition := &StepOrNestedWhenCondition{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "branch", "arguments": {"isLiteral": true, "value": "main"}}`),
+		condition))
+	assert.NotNil(t, condition.Step)
+	assert.Nil(t, condition.Nested)
+}
@@ -0,0 +1,180 @@
+package model
+
+// DeepCopy methods copy a node and everything it refers to, including annotations, so the copy can be changed without
+// affecting the original. Copies keep the UIDs of the originals, so a copy added to the same pipeline needs new ones.
+// They return nil for a nil node.
+
+// DeepCopy returns a deep copy of the Agent
+func (strct *Agent) DeepCopy() *Agent {
+	return deepCopy(strct).(*Agent)
+}
+
+// DeepCopy returns a deep copy of the AnyStep
+func (strct *AnyStep) DeepCopy() *AnyStep {
+	return deepCopy(strct).(*AnyStep)
+}
+
+// DeepCopy returns a deep copy of the ArgumentList
+func (strct *ArgumentList) DeepCopy() *ArgumentList {
+	return deepCopy(strct).(*ArgumentList)
+}
+
+// DeepCopy returns a deep copy of the ArgumentValue
+func (strct *ArgumentValue) DeepCopy() *ArgumentValue {
+	return deepCopy(strct).(*ArgumentValue)
+}
+
+// DeepCopy returns a deep copy of the Axis
+func (strct *Axis) DeepCopy() *Axis {
+	return deepCopy(strct).(*Axis)
+}
+
+// DeepCopy returns a deep copy of the Branch
+func (strct *Branch) DeepCopy() *Branch {
+	return deepCopy(strct).(*Branch)
+}
+
+// DeepCopy returns a deep copy of the BuildCondition
+func (strct *BuildCondition) DeepCopy() *BuildCondition {
+	return deepCopy(strct).(*BuildCondition)
+}
+
+// DeepCopy returns a deep copy of the EnvironmentEntry
+func (strct *EnvironmentEntry) DeepCopy() *EnvironmentEntry {
+	return deepCopy(strct).(*EnvironmentEntry)
+}
+
+// DeepCopy returns a deep copy of the EnvironmentValue
+func (strct *EnvironmentValue) DeepCopy() *EnvironmentValue {
+	return deepCopy(strct).(*EnvironmentValue)
+}
+
+// DeepCopy returns a deep copy of the ExcludeAxis
+func (strct *ExcludeAxis) DeepCopy() *ExcludeAxis {
+	return deepCopy(strct).(*ExcludeAxis)
+}
+
+// DeepCopy returns a deep copy of the Input
+func (strct *Input) DeepCopy() *Input {
+	return deepCopy(strct).(*Input)
+}
+
+// DeepCopy returns a deep copy of the InternalFunction
+func (strct *InternalFunction) DeepCopy() *InternalFunction {
+	return deepCopy(strct).(*InternalFunction)
+}
+
+// DeepCopy returns a deep copy of the KeyAndValueOrMethodCall
+func (strct *KeyAndValueOrMethodCall) DeepCopy() *KeyAndValueOrMethodCall {
+	return deepCopy(strct).(*KeyAndValueOrMethodCall)
+}
+
+// DeepCopy returns a deep copy of the Libraries
+func (strct *Libraries) DeepCopy() *Libraries {
+	return deepCopy(strct).(*Libraries)
+}
+
+// DeepCopy returns a deep copy of the MapArgumentValue
+func (strct *MapArgumentValue) DeepCopy() *MapArgumentValue {
+	return deepCopy(strct).(*MapArgumentValue)
+}
+
+// DeepCopy returns a deep copy of the MapArgumentValueRawOrList
+func (strct *MapArgumentValueRawOrList) DeepCopy() *MapArgumentValueRawOrList {
+	return deepCopy(strct).(*MapArgumentValueRawOrList)
+}
+
+// DeepCopy returns a deep copy of the Matrix
+func (strct *Matrix) DeepCopy() *Matrix {
+	return deepCopy(strct).(*Matrix)
+}
+
+// DeepCopy returns a deep copy of the MethodArg
+func (strct *MethodArg) DeepCopy() *MethodArg {
+	return deepCopy(strct).(*MethodArg)
+}
+
+// DeepCopy returns a deep copy of the MethodCall
+func (strct *MethodCall) DeepCopy() *MethodCall {
+	return deepCopy(strct).(*MethodCall)
+}
+
+// DeepCopy returns a deep copy of the NestedWhenCondition
+func (strct *NestedWhenCondition) DeepCopy() *NestedWhenCondition {
+	return deepCopy(strct).(*NestedWhenCondition)
+}
+
+// DeepCopy returns a deep copy of the Options
+func (strct *Options) DeepCopy() *Options {
+	return deepCopy(strct).(*Options)
+}
+
+// DeepCopy returns a deep copy of the Parameters
+func (strct *Parameters) DeepCopy() *Parameters {
+	return deepCopy(strct).(*Parameters)
+}
+
+// DeepCopy returns a deep copy of the Pipeline
+func (strct *Pipeline) DeepCopy() *Pipeline {
+	return deepCopy(strct).(*Pipeline)
+}
+
+// DeepCopy returns a deep copy of the Post
+func (strct *Post) DeepCopy() *Post {
+	return deepCopy(strct).(*Post)
+}
+
+// DeepCopy returns a deep copy of the RawArgument
+func (strct *RawArgument) DeepCopy() *RawArgument {
+	return deepCopy(strct).(*RawArgument)
+}
+
+// DeepCopy returns a deep copy of the RawArgumentValue
+func (strct *RawArgumentValue) DeepCopy() *RawArgumentValue {
+	return deepCopy(strct).(*RawArgumentValue)
+}
+
+// DeepCopy returns a deep copy of the RawGroovy
+func (strct *RawGroovy) DeepCopy() *RawGroovy {
+	return deepCopy(strct).(*RawGroovy)
+}
+
+// DeepCopy returns a deep copy of the Root
+func (strct *Root) DeepCopy() *Root {
+	return deepCopy(strct).(*Root)
+}
+
+// DeepCopy returns a deep copy of the Stage
+func (strct *Stage) DeepCopy() *Stage {
+	return deepCopy(strct).(*Stage)
+}
+
+// DeepCopy returns a deep copy of the Step
+func (strct *Step) DeepCopy() *Step {
+	return deepCopy(strct).(*Step)
+}
+
+// DeepCopy returns a deep copy of the StepOrNestedWhenCondition
+func (strct *StepOrNestedWhenCondition) DeepCopy() *StepOrNestedWhenCondition {
+	return deepCopy(strct).(*StepOrNestedWhenCondition)
+}
+
+// DeepCopy returns a deep copy of the TreeStep
+func (strct *TreeStep) DeepCopy() *TreeStep {
+	return deepCopy(strct).(*TreeStep)
+}
+
+// DeepCopy returns a deep copy of the Triggers
+func (strct *Triggers) DeepCopy() *Triggers {
+	return deepCopy(strct).(*Triggers)
+}
+
+// DeepCopy returns a deep copy of the ValueOrMethodCall
+func (strct *ValueOrMethodCall) DeepCopy() *ValueOrMethodCall {
+	return deepCopy(strct).(*ValueOrMethodCall)
+}
+
+// DeepCopy returns a deep copy of the When
+func (strct *When) DeepCopy() *When {
+	return deepCopy(strct).(*When)
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepCopy(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "json", "matrix", "matrixPipeline.json"))
+	require.NoError(t, err)
+	root, err := Decode(b)
+	require.NoError(t, err)
+	root.Pipeline.Stages[0].Annotations.Set(AnnotationTicket, "CI-1")
+	original, err := json.Marshal(root)
+	require.NoError(t, err)
+
+	c := root.DeepCopy()
+	assert.Equal(t, root, c)
+	c.Pipeline.Stages[0].Name = "Renamed"
+	c.Pipeline.Stages[0].Annotations.Set(AnnotationTicket, "CI-2")
+	c.Pipeline.Stages[0].Matrix.Axes[0].Values[0].Value.AsString = nil
+	after, err := json.Marshal(root)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(after), "the original is unchanged")
+	assert.Equal(t, "CI-1", root.Pipeline.Stages[0].Annotations.Get(AnnotationTicket))
+
+	stage := root.Pipeline.Stages[0].DeepCopy()
+	assert.Equal(t, root.Pipeline.Stages[0], stage)
+	assert.False(t, stage == root.Pipeline.Stages[0])
+
+	var nilStep *Step
+	assert.Nil(t, nilStep.DeepCopy())
+}
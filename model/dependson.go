@@ -0,0 +1,155 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AnnotationDependsOn holds the DependsOn field of a stage in the extended serialization, as the names of the stages
+// it depends on, one per line. MarshalExtended and UnmarshalExtended set and read it from the DependsOn field of Stage.
+// Plain JSON can carry it as a dependsOn property instead, with MarshalDependsOn and lenient decoding.
+const AnnotationDependsOn = "depends-on"
+
+// withDependsOn returns a node's annotations with AnnotationDependsOn added if it's a stage depending on others,
+// leaving the node's own annotations alone
+func withDependsOn(node interface{}, a Annotations) Annotations {
+	s, ok := node.(*Stage)
+	if !ok || len(s.DependsOn) == 0 {
+		return a
+	}
+	out := Annotations{}
+	for k, v := range a {
+		out[k] = v
+	}
+	out[AnnotationDependsOn] = strings.Join(s.DependsOn, "\n")
+	return out
+}
+
+// restoreDependsOn sets the DependsOn field of a stage whose annotations have AnnotationDependsOn, and removes it
+func restoreDependsOn(node interface{}, a *Annotations) {
+	names, ok := (*a)[AnnotationDependsOn]
+	s, isStage := node.(*Stage)
+	if !ok || !isStage {
+		return
+	}
+	s.DependsOn = strings.Split(names, "\n")
+	delete(*a, AnnotationDependsOn)
+	if len(*a) == 0 {
+		*a = nil
+	}
+}
+
+// dependsOnProperty is the stage property lenient decoding reads DependsOn from, and MarshalDependsOn writes it to
+const dependsOnProperty = "dependsOn"
+
+// MarshalDependsOn makes Marshal write the DependsOn field of each stage depending on others as a dependsOn property.
+// Jenkins rejects it, as does Decode unless it allows unknown fields, when it reads the property back into DependsOn.
+func MarshalDependsOn() MarshalOption {
+	return func(o *marshalOptions) {
+		o.dependsOn = true
+	}
+}
+
+// readDependsOn sets the DependsOn field of the stages with a dependsOn property among the unknown properties, and
+// returns the others
+func readDependsOn(root *Root, unknown unknownProperties) unknownProperties {
+	stages := map[string]*Stage{}
+	_ = Walk(root, Visitor{Stage: func(path string, s *Stage) error {
+		stages[path] = s
+		return nil
+	}})
+	var rest unknownProperties
+	for _, u := range unknown {
+		if s := stages[u.path]; s != nil && u.property == dependsOnProperty {
+			var names []string
+			if json.Unmarshal(u.value, &names) == nil {
+				s.DependsOn = names
+				continue
+			}
+		}
+		rest = append(rest, u)
+	}
+	return rest
+}
+
+// writeDependsOn adds a dependsOn property to the JSON of each stage depending on others
+func writeDependsOn(root *Root, b []byte) ([]byte, error) {
+	err := Walk(root, Visitor{Stage: func(path string, s *Stage) error {
+		if len(s.DependsOn) == 0 {
+			return nil
+		}
+		names, err := json.Marshal(s.DependsOn)
+		if err == nil {
+			b, err = addProperty(b, strings.Split(path, "/")[1:], dependsOnProperty, names)
+		}
+		return err
+	}})
+	return b, err
+}
+
+// addProperty adds a property to the object at path, given as its segments, in a JSON document, keeping the order of
+// everything else
+func addProperty(b []byte, path []string, key string, value []byte) ([]byte, error) {
+	if len(path) > 0 && isArray(b) {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(b, &elements); err != nil {
+			return nil, err
+		}
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(elements) {
+			return nil, fmt.Errorf("no element %s to add %s to", path[0], key)
+		}
+		if elements[i], err = addProperty(elements[i], path[1:], key, value); err != nil {
+			return nil, err
+		}
+		return json.Marshal(elements)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("can't add %s to a value which isn't an object", key)
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	found := len(path) == 0
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if len(path) > 0 && t == path[0] {
+			if v, err = addProperty(v, path[1:], key, value); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+		if buf.Len() > 1 {
+			buf.WriteString(",")
+		}
+		k, _ := json.Marshal(t)
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(v)
+	}
+	if !found {
+		return nil, fmt.Errorf("no property %s to add %s to", path[0], key)
+	}
+	if len(path) == 0 {
+		if buf.Len() > 1 {
+			buf.WriteString(",")
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/groovyq"
+)
+
+// CredentialsFunction is the internal function which binds a credential to an environment variable
+const CredentialsFunction = "credentials"
+
+// Source returns the value as Groovy source, as it would appear on the right of an environment entry.
+func (strct *EnvironmentValue) Source() string {
+	switch {
+	case strct == nil:
+		return ""
+	case strct.Function != nil:
+		return strct.Function.Source()
+	}
+	return argumentSource(strct.Single)
+}
+
+// Source returns the call as Groovy source, with nested calls as calls rather than their arguments' values.
+func (strct *InternalFunction) Source() string {
+	if strct == nil {
+		return ""
+	}
+	args := make([]string, len(strct.Arguments))
+	for i, a := range strct.Arguments {
+		if call := strct.Call(i); call != nil {
+			args[i] = call.Source()
+		} else {
+			args[i] = argumentSource(a)
+		}
+	}
+	return strct.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// argumentSource returns an argument as Groovy source, quoting literal strings
+func argumentSource(a *RawArgument) string {
+	if a != nil && a.IsLiteral && a.Value != nil && a.Value.AsString != nil {
+		return groovyq.Quote(*a.Value.AsString)
+	}
+	return a.String()
+}
+
+// Call returns the internal function call which is the argument at index i, or nil if that argument isn't one.
+func (strct *InternalFunction) Call(i int) *InternalFunction {
+	if strct == nil || i < 0 || i >= len(strct.Calls) {
+		return nil
+	}
+	return strct.Calls[i]
+}
+
+// AddCall adds an argument which is an internal function call itself, setting its entry in Calls and its source in
+// Arguments.
+func (strct *InternalFunction) AddCall(call *InternalFunction) {
+	for len(strct.Calls) < len(strct.Arguments) {
+		strct.Calls = append(strct.Calls, nil)
+	}
+	source := call.Source()
+	strct.Arguments = append(strct.Arguments, &RawArgument{Value: &RawArgumentValue{AsString: &source}})
+	strct.Calls = append(strct.Calls, call)
+}
+
+// marshalArguments marshals the arguments of a call, with the nested calls in Calls in place of their sources
+func (strct *InternalFunction) marshalArguments() ([]byte, error) {
+	if len(strct.Calls) == 0 {
+		return json.Marshal(strct.Arguments)
+	}
+	args := make([]interface{}, len(strct.Arguments))
+	for i, a := range strct.Arguments {
+		if call := strct.Call(i); call != nil {
+			args[i] = call
+		} else {
+			args[i] = a
+		}
+	}
+	return json.Marshal(args)
+}
+
+// unmarshalArguments unmarshals the arguments of a call. Those which are calls themselves are added with AddCall.
+func (strct *InternalFunction) unmarshalArguments(b []byte) error {
+	var elements []json.RawMessage
+	if err := unmarshalProperty(b, &elements, "arguments"); err != nil {
+		return err
+	}
+	strct.Arguments, strct.Calls = nil, nil
+	var unknown unknownProperties
+	for i, e := range elements {
+		var err error
+		if hasProperty(e, "name") {
+			call := &InternalFunction{}
+			if err = json.Unmarshal(e, call); decoded(err) {
+				strct.AddCall(call)
+			}
+		} else {
+			var arg *RawArgument
+			if err = json.Unmarshal(e, &arg); decoded(err) {
+				strct.Arguments = append(strct.Arguments, arg)
+			}
+		}
+		if err != nil {
+			if err = atPath(atPath(err, strconv.Itoa(i)), "arguments"); !unknown.add(err) {
+				return err
+			}
+		}
+	}
+	return unknown.error()
+}
+
+// Credentials returns the credential ID bound by a credentials() value, and false if the value isn't a call of
+// credentials with a single literal ID, such as one whose ID is computed by an expression.
+func (strct *EnvironmentValue) Credentials() (string, bool) {
+	if strct == nil || strct.Function == nil || strct.Function.Name != CredentialsFunction ||
+		len(strct.Function.Arguments) != 1 {
+		return "", false
+	}
+	id := strct.Function.Arguments[0]
+	if id == nil || !id.IsLiteral {
+		return "", false
+	}
+	return id.String(), true
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentValue(t *testing.T) {
+	var env []*EnvironmentEntry
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"key": "TOKEN", "value": {"name": "credentials", "arguments": [{"isLiteral": true, "value": "github-token"}]}},
+		{"key": "COMPUTED", "value": {"name": "credentials", "arguments": [{"isLiteral": false, "value": "\"${env.TEAM}-key\""}]}},
+		{"key": "NESTED", "value": {"name": "credentials", "arguments": [
+			{"name": "lookup", "arguments": [{"isLiteral": true, "value": "it's"}, {"isLiteral": true, "value": 2}]}]}},
+		{"key": "PLAIN", "value": {"isLiteral": true, "value": "x"}}
+	]`), &env))
+
+	id, ok := env[0].Value.Credentials()
+	assert.True(t, ok)
+	assert.Equal(t, "github-token", id)
+	assert.Equal(t, "credentials('github-token')", env[0].Value.Source())
+
+	_, ok = env[1].Value.Credentials()
+	assert.False(t, ok)
+	assert.Equal(t, `credentials("${env.TEAM}-key")`, env[1].Value.Source())
+
+	nested := env[2].Value.Function.Call(0)
+	require.NotNil(t, nested)
+	assert.Equal(t, "lookup", nested.Name)
+	assert.Equal(t, "lookup('it\\'s', 2)", env[2].Value.Function.Arguments[0].String())
+	assert.False(t, env[2].Value.Function.Arguments[0].IsLiteral)
+	assert.Nil(t, env[0].Value.Function.Call(0))
+	_, ok = env[2].Value.Credentials()
+	assert.False(t, ok)
+	assert.Equal(t, `credentials(lookup('it\'s', 2))`, env[2].Value.Source())
+
+	_, ok = env[3].Value.Credentials()
+	assert.False(t, ok)
+	assert.Equal(t, "'x'", env[3].Value.Source())
+
+	out, err := json.Marshal(env[2])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key": "NESTED", "value": {"name": "credentials", "arguments": [
+		{"name": "lookup", "arguments": [{"isLiteral": true, "value": "it's"}, {"isLiteral": true, "value": 2}]}]}}`,
+		string(out))
+
+	f := &InternalFunction{Name: CredentialsFunction}
+	f.AddCall(&InternalFunction{Name: "lookup"})
+	f.Arguments = append(f.Arguments, env[0].Value.Function.Arguments[0])
+	assert.Equal(t, "credentials(lookup(), 'github-token')", f.Source())
+	out, err = json.Marshal(f)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "credentials", "arguments": [{"name": "lookup", "arguments": null},
+		{"isLiteral": true, "value": "github-token"}]}`, string(out))
+}
@@ -0,0 +1,232 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/metrics"
+)
+
+// Source formats ExtractAll recognizes
+const (
+	FormatJSON   = "json"
+	FormatGroovy = "groovy"
+)
+
+// Extracted is a single pipeline found by ExtractAll
+type Extracted struct {
+	// Root is the decoded pipeline. ExtractAll leaves it nil for pipelines found in Groovy sources, which need a Groovy
+	// parser to decode; parser.ExtractAll parses them as well.
+	Root *Root
+	// Format is FormatJSON or FormatGroovy.
+	Format string
+	// Source is the text of the pipeline: the JSON document, or the Groovy pipeline block.
+	Source []byte
+	// Offset is the byte offset of the pipeline in the input, and Line and Column its 1-based position.
+	Offset int
+	Line   int
+	Column int
+}
+
+// ExtractAll finds every pipeline in src. JSON input may be a single Root, a sequence of Roots, or an array of Roots.
+// Any other input is treated as Groovy, and each "pipeline { ... }" block in it is returned, such as the one in a
+// shared library var's call method. Use parser.ExtractAll to have those parsed too.
+func ExtractAll(src []byte) ([]*Extracted, error) {
+	trimmed := bytes.TrimSpace(src)
+	if len(trimmed) == 0 {
+		return nil, errors.New("no pipeline found in empty input")
+	}
+	var out []*Extracted
+	var err error
+	switch trimmed[0] {
+	case '[':
+		out, err = extractJSONArray(src)
+	case '{':
+		out, err = extractJSONStream(src)
+	default:
+		out = extractGroovy(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no pipeline found")
+	}
+	return out, nil
+}
+
+func extractJSONStream(src []byte) ([]*Extracted, error) {
+	var out []*Extracted
+	dec := json.NewDecoder(bytes.NewReader(src))
+	for {
+		start := skipJSONSeparators(src, int(dec.InputOffset()))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, err
+		}
+		doc, err := extractedJSON(src, start, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, doc)
+	}
+}
+
+func extractJSONArray(src []byte) ([]*Extracted, error) {
+	var out []*Extracted
+	dec := json.NewDecoder(bytes.NewReader(src))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		start := skipJSONSeparators(src, int(dec.InputOffset()))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		doc, err := extractedJSON(src, start, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, doc)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func extractedJSON(src []byte, start int, raw json.RawMessage) (*Extracted, error) {
+	defer metrics.Since(metrics.ParseDuration, metrics.Labels{"format": FormatJSON}, time.Now())
+	line, col := position(src, start)
+	root := &Root{}
+	if err := json.Unmarshal(raw, root); err != nil {
+		return nil, fmt.Errorf("pipeline at line %d, column %d: %s", line, col, err)
+	}
+	return &Extracted{Root: root, Format: FormatJSON, Source: raw, Offset: start, Line: line, Column: col}, nil
+}
+
+func skipJSONSeparators(src []byte, i int) int {
+	for i < len(src) {
+		switch src[i] {
+		case ' ', '\t', '\r', '\n', ',':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// position returns the 1-based line and column of a byte offset
+func position(src []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(src[:offset], []byte("\n"))
+	return line, offset - bytes.LastIndexByte(src[:offset], '\n')
+}
+
+// extractGroovy finds the top-level "pipeline { ... }" blocks in Groovy source, skipping comments and strings
+func extractGroovy(src []byte) []*Extracted {
+	var out []*Extracted
+	for i := 0; i < len(src); {
+		if next, skipped := skipGroovyLiteral(src, i); skipped {
+			i = next
+			continue
+		}
+		if isIdentStart(src[i]) {
+			j := i
+			for j < len(src) && isIdentPart(src[j]) {
+				j++
+			}
+			if string(src[i:j]) == "pipeline" && (i == 0 || src[i-1] != '.') {
+				k := j
+				for k < len(src) && (src[k] == ' ' || src[k] == '\t' || src[k] == '\r' || src[k] == '\n') {
+					k++
+				}
+				if k < len(src) && src[k] == '{' {
+					if end, ok := matchBrace(src, k); ok {
+						line, col := position(src, i)
+						out = append(out, &Extracted{Format: FormatGroovy, Source: src[i : end+1], Offset: i, Line: line,
+							Column: col})
+						i = end + 1
+						continue
+					}
+				}
+			}
+			i = j
+			continue
+		}
+		i++
+	}
+	return out
+}
+
+// matchBrace returns the offset of the brace closing the one at open
+func matchBrace(src []byte, open int) (int, bool) {
+	depth := 0
+	for i := open; i < len(src); {
+		if next, skipped := skipGroovyLiteral(src, i); skipped {
+			i = next
+			continue
+		}
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		}
+		i++
+	}
+	return 0, false
+}
+
+// skipGroovyLiteral returns the offset after the comment or string starting at i, if there is one
+func skipGroovyLiteral(src []byte, i int) (int, bool) {
+	rest := src[i:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("//")):
+		if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+			return i + end, true
+		}
+		return len(src), true
+	case bytes.HasPrefix(rest, []byte("/*")):
+		if end := bytes.Index(rest[2:], []byte("*/")); end >= 0 {
+			return i + 2 + end + 2, true
+		}
+		return len(src), true
+	case bytes.HasPrefix(rest, []byte(`"""`)), bytes.HasPrefix(rest, []byte("'''")):
+		return skipQuoted(src, i, rest[:3]), true
+	case rest[0] == '"' || rest[0] == '\'':
+		return skipQuoted(src, i, rest[:1]), true
+	}
+	return i, false
+}
+
+func skipQuoted(src []byte, i int, quote []byte) int {
+	for j := i + len(quote); j < len(src); j++ {
+		if src[j] == '\\' {
+			j++
+			continue
+		}
+		if bytes.HasPrefix(src[j:], quote) {
+			return j + len(quote)
+		}
+	}
+	return len(src)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
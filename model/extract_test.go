@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const extractStage = `{"name": "Build", "branches": [{"name": "default", "steps": [
+  {"name": "echo", "arguments": [{"key": "message", "value": {"isLiteral": true, "value": "hi"}}]}]}]}`
+
+func TestExtractAllJSONArray(t *testing.T) {
+	src := "[\n  {\"pipeline\": {\"agent\": {\"type\": \"any\"}, \"stages\": [" + extractStage + "]}},\n" +
+		"  {\"pipeline\": {\"agent\": {\"type\": \"none\"}, \"stages\": [" + extractStage + "]}}\n]\n"
+	docs, err := ExtractAll([]byte(src))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	assert.Equal(t, FormatJSON, docs[0].Format)
+	assert.Equal(t, "any", docs[0].Root.Pipeline.Agent.Type)
+	assert.Equal(t, 2, docs[0].Line)
+	assert.Equal(t, 3, docs[0].Column)
+	assert.Equal(t, "none", docs[1].Root.Pipeline.Agent.Type)
+	assert.Equal(t, 4, docs[1].Line)
+	assert.Equal(t, 3, docs[1].Column)
+	assert.Equal(t, byte('{'), src[docs[1].Offset])
+}
+
+func TestExtractAllJSONStream(t *testing.T) {
+	src := "{\"pipeline\": {\"agent\": {\"type\": \"any\"}, \"stages\": [" + extractStage + "]}}\n" +
+		"{\"pipeline\": {\"agent\": {\"type\": \"none\"}, \"stages\": [" + extractStage + "]}}"
+	docs, err := ExtractAll([]byte(src))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, 1, docs[0].Line)
+	assert.Equal(t, 3, docs[1].Line)
+	assert.Equal(t, 1, docs[1].Column)
+}
+
+func TestExtractAllInvalidJSON(t *testing.T) {
+	_, err := ExtractAll([]byte(`[{"pipeline": {"bogus": true}}]`))
+	assert.EqualError(t, err, `pipeline at line 1, column 2: /pipeline: additional property not allowed: "bogus"`)
+}
+
+func TestExtractAllGroovy(t *testing.T) {
+	src := `// vars/standardBuild.groovy
+def call(Map config) {
+    /* not a pipeline { */
+    echo "pipeline { is in a string"
+    pipeline {
+        agent any
+        stages {
+            stage('Build') {
+                steps {
+                    sh "make ${config.target} # }"
+                }
+            }
+        }
+    }
+}
+`
+	docs, err := ExtractAll([]byte(src))
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, FormatGroovy, docs[0].Format)
+	assert.Nil(t, docs[0].Root)
+	assert.Equal(t, 5, docs[0].Line)
+	assert.Equal(t, 5, docs[0].Column)
+	assert.Equal(t, `pipeline {
+        agent any
+        stages {
+            stage('Build') {
+                steps {
+                    sh "make ${config.target} # }"
+                }
+            }
+        }
+    }`, string(docs[0].Source))
+}
+
+func TestExtractAllNoPipeline(t *testing.T) {
+	_, err := ExtractAll([]byte("node { sh 'make' }"))
+	assert.EqualError(t, err, "no pipeline found")
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FrozenRoot is a read-only pipeline, safe to share between goroutines, such as a parsed pipeline cached by a server.
+// Nothing stops callers modifying the tree Root returns, so they must not; instead, Update and UpdateStage return a
+// new FrozenRoot with the changes, leaving the original as it was.
+type FrozenRoot struct {
+	root *Root
+}
+
+// Freeze returns a frozen copy of the root. Later changes to the root don't affect the copy.
+func (strct *Root) Freeze() *FrozenRoot {
+	return &FrozenRoot{root: strct.DeepCopy()}
+}
+
+// Root returns the frozen tree, which must not be modified
+func (f *FrozenRoot) Root() *Root {
+	return f.root
+}
+
+// Thaw returns a copy of the frozen tree, which may be modified
+func (f *FrozenRoot) Thaw() *Root {
+	return f.root.DeepCopy()
+}
+
+// Update calls fn with a copy of the tree and returns the result, frozen
+func (f *FrozenRoot) Update(fn func(root *Root)) *FrozenRoot {
+	root := f.Thaw()
+	fn(root)
+	return &FrozenRoot{root: root}
+}
+
+// UpdateStage calls fn with a copy of the named stage, which may be nested, and returns a new FrozenRoot with the
+// stage replaced. Only the stage and those enclosing it are copied; the rest of the tree is shared with the original.
+func (f *FrozenRoot) UpdateStage(name string, fn func(stage *Stage)) (*FrozenRoot, error) {
+	if f.root.Pipeline == nil {
+		return nil, fmt.Errorf("no stage named %q", name)
+	}
+	stages, ok := updateStage(f.root.Pipeline.Stages, name, fn)
+	if !ok {
+		return nil, fmt.Errorf("no stage named %q", name)
+	}
+	pipeline := *f.root.Pipeline
+	pipeline.Stages = stages
+	return &FrozenRoot{root: &Root{Pipeline: &pipeline}}, nil
+}
+
+// updateStage returns a copy of stages with the named stage updated, copying only the stages on the way to it, and
+// whether the stage was found
+func updateStage(stages []*Stage, name string, fn func(stage *Stage)) ([]*Stage, bool) {
+	for i, s := range stages {
+		if s == nil {
+			continue
+		}
+		var updated *Stage
+		if s.Name == name {
+			updated = deepCopy(s).(*Stage)
+			fn(updated)
+		} else {
+			copied := *s
+			nested, ok := updateStage(s.Stages, name, fn)
+			if ok {
+				copied.Stages = nested
+			} else if nested, ok = updateStage(s.Parallel, name, fn); ok {
+				copied.Parallel = nested
+			} else if s.Matrix != nil {
+				if nested, ok = updateStage(s.Matrix.Stages, name, fn); ok {
+					matrix := *s.Matrix
+					matrix.Stages = nested
+					copied.Matrix = &matrix
+				}
+			}
+			if !ok {
+				continue
+			}
+			updated = &copied
+		}
+		out := append([]*Stage{}, stages...)
+		out[i] = updated
+		return out, true
+	}
+	return stages, false
+}
+
+// deepCopy copies a model value, which must be a pointer, and everything it refers to
+func deepCopy(v interface{}) interface{} {
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(copyValue(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			out.Field(i).Set(copyValue(v.Field(i)))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
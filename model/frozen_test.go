@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeze(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "json", "parallel", "parallelPipelineWithFailFast.json"))
+	require.NoError(t, err)
+	root := &Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+
+	frozen := root.Freeze()
+	root.Pipeline.Stages[0].Name = "changed"
+	assert.Equal(t, "foo", frozen.Root().Pipeline.Stages[0].Name)
+
+	thawed := frozen.Thaw()
+	assert.Equal(t, frozen.Root(), thawed)
+	thawed.Pipeline.Agent.Type = "label"
+	assert.Equal(t, "none", frozen.Root().Pipeline.Agent.Type)
+
+	updated := frozen.Update(func(root *Root) {
+		root.Pipeline.Stages = nil
+	})
+	assert.Len(t, frozen.Root().Pipeline.Stages, 1)
+	assert.Nil(t, updated.Root().Pipeline.Stages)
+}
+
+func TestUpdateStage(t *testing.T) {
+	leaf := func(name string) *Stage {
+		return &Stage{Name: name, Branches: []*Branch{{Name: "default", Steps: []*AnyStep{{Step: &Step{Name: "echo"}}}}}}
+	}
+	root := &Root{Pipeline: &Pipeline{Agent: &Agent{Type: "any"}, Stages: []*Stage{
+		leaf("build"),
+		{Name: "test", Parallel: []*Stage{leaf("unit"), leaf("integration")}},
+	}}}
+	frozen := root.Freeze()
+
+	var wg sync.WaitGroup
+	results := make([]*FrozenRoot, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = frozen.UpdateStage("integration", func(s *Stage) {
+				s.Annotations.Set(AnnotationTicket, "OPS-1")
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	before, after := frozen.Root().Pipeline, results[0].Root().Pipeline
+	assert.Same(t, before.Stages[0], after.Stages[0])
+	assert.Same(t, before.Stages[1].Parallel[0], after.Stages[1].Parallel[0])
+	assert.NotSame(t, before.Stages[1], after.Stages[1])
+	assert.Equal(t, "OPS-1", after.Stages[1].Parallel[1].Annotations.Get(AnnotationTicket))
+	assert.Nil(t, before.Stages[1].Parallel[1].Annotations)
+
+	_, err := frozen.UpdateStage("deploy", func(*Stage) {})
+	assert.EqualError(t, err, `no stage named "deploy"`)
+}
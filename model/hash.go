@@ -0,0 +1,18 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Hash returns the hex-encoded SHA-256 of the pipeline's canonical encoding, from MarshalCanonical, so pipelines which
+// differ only in the order of object keys, properties set to their default of false, or how numbers are written hash
+// the same. Annotations aren't marshaled, so they don't change the hash either.
+func Hash(root *Root) (string, error) {
+	b, err := MarshalCanonical(root)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashOf(t *testing.T, doc string) string {
+	root := &Root{}
+	require.NoError(t, json.Unmarshal([]byte(doc), root))
+	h, err := Hash(root)
+	require.NoError(t, err)
+	return h
+}
+
+func TestHash(t *testing.T) {
+	base := `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build", "branches": [{"name": "default",
+		"steps": [{"name": "retry", "arguments": [{"key": "count", "value": {"isLiteral": true, "value": 3}}]}]}]}]}}`
+	h := hashOf(t, base)
+	assert.Len(t, h, 64)
+
+	assert.Equal(t, h, hashOf(t, `{"pipeline": {"stages": [{"failFast": false, "branches": [{"steps": [{"arguments":
+		[{"value": {"value": 3.0, "isLiteral": true}, "key": "count"}], "name": "retry"}], "name": "default"}],
+		"name": "Build"}], "agent": {"type": "any"}}}`), "key order, default booleans, and number forms don't matter")
+
+	assert.NotEqual(t, h, hashOf(t, `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build",
+		"failFast": true, "branches": [{"name": "default", "steps": [{"name": "retry", "arguments": [{"key": "count",
+		"value": {"isLiteral": true, "value": 3}}]}]}]}]}}`))
+	assert.NotEqual(t, h, hashOf(t, `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build",
+		"branches": [{"name": "default", "steps": [{"name": "retry", "arguments": [{"key": "count",
+		"value": {"isLiteral": true, "value": 3.5}}]}]}]}]}}`))
+}
+
+func TestHashIgnoresAnnotations(t *testing.T) {
+	root := lossyRoot()
+	root.Pipeline.Environment = root.Pipeline.Environment[:1]
+	root.Pipeline.Stages[0].Branches[0].Steps = root.Pipeline.Stages[0].Branches[0].Steps[1:2]
+	before, err := Hash(root)
+	require.NoError(t, err)
+
+	root.Pipeline.Stages[0].Annotations.AddProvenance("transform: test")
+	after, err := Hash(root)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	integer := int64(2)
+	root.Pipeline.Stages[0].Branches[0].Steps[0].Step.Arguments.Single.Value = &RawArgumentValue{AsInteger: &integer}
+	asInteger, err := Hash(root)
+	require.NoError(t, err)
+	float := 2.0
+	root.Pipeline.Stages[0].Branches[0].Steps[0].Step.Arguments.Single.Value = &RawArgumentValue{AsFloat: &float}
+	asFloat, err := Hash(root)
+	require.NoError(t, err)
+	assert.Equal(t, asInteger, asFloat)
+}
+
+func TestHashErrors(t *testing.T) {
+	_, err := Hash(&Root{})
+	assert.EqualError(t, err, "a root with a pipeline is required")
+	_, err = Hash(lossyRoot())
+	assert.EqualError(t, err, "/pipeline/environment/1/value: EnvironmentValue has none of its alternatives set")
+}
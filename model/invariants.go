@@ -0,0 +1,253 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PostConditions are the post conditions in the order Jenkins runs them, whatever order they are declared in
+var PostConditions = []string{"always", "changed", "fixed", "regression", "aborted", "success", "unsuccessful",
+	"unstable", "failure", "notBuilt", "cleanup"}
+
+// InvariantError lists the invariants a pipeline violates
+type InvariantError struct {
+	Violations []string
+}
+
+func (e *InvariantError) Error() string {
+	return "pipeline violates model invariants: " + strings.Join(e.Violations, "; ")
+}
+
+// CheckInvariants checks that a pipeline is well-formed in ways tools rely on, beyond what decoding checks, and
+// returns an *InvariantError listing every violation. Builders and transformers should check their output with it.
+// The invariants are:
+//
+//   - lists of stages, branches, steps, arguments, environment entries, and post conditions contain no nil entries
+//   - stage names are unique within the pipeline, as Declarative requires
+//   - each stage has exactly one of steps, sequential stages, parallel stages, or a matrix
+//   - branch names are unique within a stage
+//   - named arguments and environment variables have unique keys
+//   - credentials() in environment values has exactly one argument, and nested calls match arguments
+//   - post conditions are known, unique, and in the order Jenkins runs them, as given by PostConditions
+//   - node UIDs, where assigned, are unique, so copies of nodes need new ones
+//   - unions, such as AnyStep and EnvironmentValue, have exactly one of their alternatives set, as their
+//     constructors and setters ensure
+//
+// Order is otherwise significant and preserved throughout the model: stages, steps, arguments, and environment
+// entries are kept in declaration order, which is execution order for stages and steps.
+func CheckInvariants(root *Root) error {
+	c := &invariantChecker{names: map[string]bool{}}
+	if root == nil || root.Pipeline == nil {
+		c.violation("pipeline", "is missing")
+		return c.err()
+	}
+	p := root.Pipeline
+	c.environment("pipeline", p.Environment)
+	c.post("pipeline", p.Post)
+	c.stages("pipeline", p.Stages)
+	uids := map[string]bool{}
+	eachAnnotated(root, func(path string, _ interface{}, a *Annotations) {
+		if uid := a.Get(AnnotationUID); uid != "" {
+			if uids[uid] {
+				c.violation(path, "has UID %s, which another node has too", uid)
+			}
+			uids[uid] = true
+		}
+	})
+	eachUnion(reflect.ValueOf(root), "", func(path string, typ string, u union) {
+		if v := unionViolation(typ, u); v != "" {
+			c.violation(path, "%s", v)
+		}
+	})
+	return c.err()
+}
+
+type invariantChecker struct {
+	names      map[string]bool
+	violations []string
+}
+
+func (c *invariantChecker) violation(where string, format string, args ...interface{}) {
+	c.violations = append(c.violations, where+" "+fmt.Sprintf(format, args...))
+}
+
+func (c *invariantChecker) err() error {
+	if len(c.violations) == 0 {
+		return nil
+	}
+	return &InvariantError{Violations: c.violations}
+}
+
+func (c *invariantChecker) stages(where string, stages []*Stage) {
+	for i, s := range stages {
+		if s == nil {
+			c.violation(where, "has a nil stage at index %d", i)
+			continue
+		}
+		at := fmt.Sprintf("stage %q", s.Name)
+		if c.names[s.Name] {
+			c.violation(at, "is not the only stage with its name")
+		}
+		c.names[s.Name] = true
+
+		kinds := 0
+		for _, present := range []bool{len(s.Branches) > 0, len(s.Stages) > 0, len(s.Parallel) > 0, s.Matrix != nil} {
+			if present {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			c.violation(at, "must have exactly one of steps, stages, parallel, or matrix")
+		}
+		branches := map[string]bool{}
+		for i, b := range s.Branches {
+			if b == nil {
+				c.violation(at, "has a nil branch at index %d", i)
+				continue
+			}
+			if branches[b.Name] {
+				c.violation(at, "has more than one branch %q", b.Name)
+			}
+			branches[b.Name] = true
+			c.steps(at, b.Steps)
+		}
+		c.environment(at, s.Environment)
+		c.post(at, s.Post)
+		c.stages(at, s.Stages)
+		c.stages(at, s.Parallel)
+		if s.Matrix != nil {
+			c.environment(at+" matrix", s.Matrix.Environment)
+			c.stages(at+" matrix", s.Matrix.Stages)
+		}
+	}
+}
+
+func (c *invariantChecker) steps(where string, steps []*AnyStep) {
+	for i, s := range steps {
+		switch {
+		case s == nil:
+			c.violation(where, "has a nil step at index %d", i)
+		case s.Step != nil:
+			c.arguments(fmt.Sprintf("%s step %s", where, s.Step.Name), s.Step.Arguments)
+		case s.Tree != nil:
+			at := fmt.Sprintf("%s step %s", where, s.Tree.Name)
+			c.arguments(at, s.Tree.Arguments)
+			c.steps(at, s.Tree.Children)
+		}
+	}
+}
+
+func (c *invariantChecker) arguments(where string, args *ArgumentList) {
+	if args == nil {
+		return
+	}
+	seen := map[string]bool{}
+	for i, a := range args.Named {
+		if a == nil {
+			c.violation(where, "has a nil argument at index %d", i)
+			continue
+		}
+		if a.Key != "" && seen[a.Key] {
+			c.violation(where, "has more than one argument %q", a.Key)
+		}
+		seen[a.Key] = true
+	}
+}
+
+func (c *invariantChecker) environment(where string, env []*EnvironmentEntry) {
+	seen := map[string]bool{}
+	for i, e := range env {
+		if e == nil {
+			c.violation(where, "has a nil environment entry at index %d", i)
+			continue
+		}
+		if seen[e.Key] {
+			c.violation(where, "sets environment variable %s more than once", e.Key)
+		}
+		seen[e.Key] = true
+		if e.Value != nil && e.Value.Function != nil {
+			c.function(where, e.Key, e.Value.Function)
+		}
+	}
+}
+
+// function checks an internal function call in an environment value, and those nested in its arguments
+func (c *invariantChecker) function(where string, key string, f *InternalFunction) {
+	if f.Name == CredentialsFunction && len(f.Arguments) != 1 {
+		c.violation(where, "environment variable %s calls credentials() with %d arguments instead of one", key,
+			len(f.Arguments))
+	}
+	if len(f.Calls) > len(f.Arguments) {
+		c.violation(where, "environment variable %s calls %s() with more nested calls than arguments", key, f.Name)
+	}
+	for i, a := range f.Arguments {
+		if a == nil {
+			c.violation(where, "environment variable %s calls %s() with a nil argument at index %d", key, f.Name, i)
+			continue
+		}
+		if call := f.Call(i); call != nil {
+			c.function(where, key, call)
+		}
+	}
+}
+
+func (c *invariantChecker) post(where string, post *Post) {
+	if post == nil {
+		return
+	}
+	last := -1
+	seen := map[string]bool{}
+	for i, b := range post.Conditions {
+		if b == nil {
+			c.violation(where, "has a nil post condition at index %d", i)
+			continue
+		}
+		rank := postRank(b.Condition)
+		switch {
+		case rank < 0:
+			c.violation(where, "has unknown post condition %q", b.Condition)
+		case seen[b.Condition]:
+			c.violation(where, "has more than one %s post condition", b.Condition)
+		case rank < last:
+			c.violation(where, "has post condition %s after %s", b.Condition, PostConditions[last])
+		}
+		seen[b.Condition] = true
+		if rank > last {
+			last = rank
+		}
+		if b.Branch != nil {
+			c.steps(fmt.Sprintf("%s post %s", where, b.Condition), b.Branch.Steps)
+		}
+	}
+}
+
+func postRank(condition string) int {
+	for i, c := range PostConditions {
+		if c == condition {
+			return i
+		}
+	}
+	return -1
+}
+
+// SortConditions sorts the post conditions into the order Jenkins runs them. Unknown conditions are kept, after the
+// known ones.
+func (strct *Post) SortConditions() {
+	if strct == nil {
+		return
+	}
+	rank := func(b *BuildCondition) int {
+		if b == nil {
+			return len(PostConditions)
+		}
+		if r := postRank(b.Condition); r >= 0 {
+			return r
+		}
+		return len(PostConditions)
+	}
+	sort.SliceStable(strct.Conditions, func(i, j int) bool {
+		return rank(strct.Conditions[i]) < rank(strct.Conditions[j])
+	})
+}
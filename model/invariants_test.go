@@ -0,0 +1,74 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInvariantsFixtures(t *testing.T) {
+	err := filepath.Walk(filepath.Join("testdata", "json"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		root := &Root{}
+		require.NoError(t, json.Unmarshal(contents, root))
+		assert.NoError(t, CheckInvariants(root), path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCheckInvariantsViolations(t *testing.T) {
+	steps := []*Branch{{Name: "default", Steps: []*AnyStep{{Step: &Step{Name: "echo", Arguments: &ArgumentList{
+		Named: []*ArgumentValue{{Key: "message"}, {Key: "message"}}}}}}}}
+	root := &Root{Pipeline: &Pipeline{
+		Environment: []*EnvironmentEntry{{Key: "A"}, {Key: "A"},
+			{Key: "B", Value: &EnvironmentValue{Function: &InternalFunction{Name: "credentials"}}}},
+		Post: &Post{Conditions: []*BuildCondition{{Condition: "failure"}, {Condition: "always"},
+			{Condition: "always"}, {Condition: "sometimes"}}},
+		Stages: []*Stage{
+			{Name: "a", Branches: steps},
+			{Name: "a", Branches: steps, Stages: []*Stage{{Name: "b", Branches: steps}}},
+			{Name: "c"},
+			nil,
+		},
+	}}
+
+	err := CheckInvariants(root)
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"pipeline sets environment variable A more than once",
+		"pipeline environment variable B calls credentials() with 0 arguments instead of one",
+		"pipeline has post condition always after failure",
+		"pipeline has more than one always post condition",
+		`pipeline has unknown post condition "sometimes"`,
+		`stage "a" step echo has more than one argument "message"`,
+		`stage "a" is not the only stage with its name`,
+		`stage "a" must have exactly one of steps, stages, parallel, or matrix`,
+		`stage "a" step echo has more than one argument "message"`,
+		`stage "b" step echo has more than one argument "message"`,
+		`stage "c" must have exactly one of steps, stages, parallel, or matrix`,
+		"pipeline has a nil stage at index 3",
+	}, err.(*InvariantError).Violations)
+
+	assert.EqualError(t, CheckInvariants(&Root{}), "pipeline violates model invariants: pipeline is missing")
+}
+
+func TestSortConditions(t *testing.T) {
+	post := &Post{Conditions: []*BuildCondition{{Condition: "cleanup"}, {Condition: "sometimes"},
+		{Condition: "failure"}, {Condition: "always"}}}
+	post.SortConditions()
+	var conditions []string
+	for _, c := range post.Conditions {
+		conditions = append(conditions, c.Condition)
+	}
+	assert.Equal(t, []string{"always", "failure", "cleanup", "sometimes"}, conditions)
+}
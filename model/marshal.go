@@ -0,0 +1,226 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// EmptyUnionError is the error marshaling a union type, such as AnyStep or EnvironmentValue, with none of its
+// alternatives set. There is nothing to write for it, so marshaling it would lose the node.
+type EmptyUnionError struct {
+	// Type is the union's type, such as "AnyStep".
+	Type string
+	// Path is the JSON pointer of the union within the pipeline, if known.
+	Path string
+}
+
+func (e *EmptyUnionError) Error() string {
+	msg := e.Type + " has none of its alternatives set"
+	if e.Path == "" {
+		return msg
+	}
+	return e.Path + ": " + msg
+}
+
+// MarshalWarning records a node Marshal left out, because there was nothing to write for it
+type MarshalWarning struct {
+	// Path is the JSON pointer of the node in the pipeline as given, before anything was left out.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (w *MarshalWarning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// MarshalOption changes how Marshal marshals a pipeline
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	strict   bool
+	warnings *[]*MarshalWarning
+	encoding *EncoderOptions
+	// dependsOn writes the DependsOn field of stages, as MarshalDependsOn says.
+	dependsOn bool
+}
+
+// EncoderOptions change the layout of the JSON Marshal writes, but not its content. Object keys are always in the
+// order the model's MarshalJSON methods write them.
+type EncoderOptions struct {
+	// Pretty writes each array element and object property on its own line, starting with Prefix and indented by
+	// Indent for each level of nesting. Otherwise the JSON is compact, with no whitespace between tokens.
+	Pretty bool
+	Prefix string
+	Indent string
+	// TrailingNewline ends the JSON with a newline, as json.Encoder does.
+	TrailingNewline bool
+	// EscapeHTML escapes <, >, and & in strings as \u003c, \u003e, and \u0026, as json.Marshal does, so the JSON
+	// can be embedded in HTML.
+	EscapeHTML bool
+}
+
+// StrictMarshal makes Marshal fail with an *EmptyUnionError, giving its path, if any union in the pipeline has none
+// of its alternatives set, rather than leaving it out.
+func StrictMarshal() MarshalOption {
+	return func(o *marshalOptions) {
+		o.strict = true
+	}
+}
+
+// CollectMarshalWarnings makes Marshal add a warning to warnings for each node it leaves out.
+func CollectMarshalWarnings(warnings *[]*MarshalWarning) MarshalOption {
+	return func(o *marshalOptions) {
+		o.warnings = warnings
+	}
+}
+
+// Encoding makes Marshal lay out the JSON as opts say. Without it, Marshal writes compact JSON with HTML escaped and
+// no trailing newline.
+func Encoding(opts EncoderOptions) MarshalOption {
+	return func(o *marshalOptions) {
+		o.encoding = &opts
+	}
+}
+
+// Marshal marshals a pipeline's JSON. Unions with none of their alternatives set, such as an AnyStep with neither a
+// step nor a tree step, make json.Marshal fail with an *EmptyUnionError, but without saying where they are. Marshal
+// instead leaves them out: empty unions in lists are removed from them, and others are written as if they weren't
+// set at all. The root itself is left untouched. Use CollectMarshalWarnings to find out what was left out, or
+// StrictMarshal to fail instead, and Encoding to indent the JSON.
+func Marshal(root *Root, opts ...MarshalOption) ([]byte, error) {
+	o := &marshalOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var found []*EmptyUnionError
+	emptyUnions(reflect.ValueOf(root), "", false, &found)
+	if len(found) == 0 {
+		return encode(root, o)
+	}
+	if o.strict {
+		return nil, found[0]
+	}
+	found = nil
+	pruned := deepCopy(root).(*Root)
+	emptyUnions(reflect.ValueOf(pruned), "", true, &found)
+	if o.warnings != nil {
+		for _, e := range found {
+			*o.warnings = append(*o.warnings, &MarshalWarning{
+				Path:    e.Path,
+				Message: e.Type + " has none of its alternatives set, so it was left out",
+			})
+		}
+	}
+	return encode(pruned, o)
+}
+
+// MarshalIndent is like Marshal, with each array element and object property on its own line, starting with prefix
+// and indented by indent for each level of nesting, as json.MarshalIndent does. It leaves out unions with none of
+// their alternatives set, as Marshal does.
+func MarshalIndent(root *Root, prefix, indent string) ([]byte, error) {
+	return Marshal(root, Encoding(EncoderOptions{Pretty: true, Prefix: prefix, Indent: indent, EscapeHTML: true}))
+}
+
+// encode marshals a root with the layout o gives, if any, and the dependsOn properties of stages if o asks for them.
+// json.Marshal compacts the output of the MarshalJSON methods, which separate keys from values inconsistently, and
+// escapes HTML; the layout is applied after that.
+func encode(root *Root, o *marshalOptions) ([]byte, error) {
+	b, err := json.Marshal(root)
+	if err == nil && o.dependsOn {
+		b, err = writeDependsOn(root, b)
+	}
+	opts := o.encoding
+	if err != nil || opts == nil {
+		return b, err
+	}
+	if !opts.EscapeHTML {
+		b = unescapeHTML(b)
+	}
+	if opts.Pretty {
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, b, opts.Prefix, opts.Indent); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	if opts.TrailingNewline {
+		b = append(b, '\n')
+	}
+	return b, nil
+}
+
+// htmlEscapes are the escapes json.Marshal uses for characters which are special in HTML
+var htmlEscapes = map[string]byte{`\u003c`: '<', `\u003e`: '>', `\u0026`: '&'}
+
+// unescapeHTML replaces the escapes of <, >, and & in the strings of compact JSON with the characters themselves
+func unescapeHTML(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case !inString:
+			inString = c == '"'
+		case c == '"':
+			inString = false
+		case c == '\\':
+			if i+6 <= len(b) {
+				if unescaped, ok := htmlEscapes[string(b[i:i+6])]; ok {
+					out = append(out, unescaped)
+					i += 5
+					continue
+				}
+			}
+			// Copy the escaped character too, so an escaped backslash isn't taken to start another escape.
+			out = append(out, c, b[i+1])
+			i++
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// emptyUnions adds the unions with none of their alternatives set in v to found, and if prune is true, removes them
+// from v, which must be settable. It returns true if v itself is an empty union, for its container to remove.
+func emptyUnions(v reflect.Value, path string, prune bool, found *[]*EmptyUnionError) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false
+		}
+		u, isUnion := v.Interface().(union)
+		if !isUnion || !isEmpty(u) {
+			emptyUnions(v.Elem(), path, prune, found)
+		}
+		// Removing an empty union within the set alternative can leave this one empty too.
+		if isUnion && isEmpty(u) {
+			*found = append(*found, &EmptyUnionError{Type: v.Elem().Type().Name(), Path: path})
+			return true
+		}
+		return false
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			at, ok := fieldPath(path, v.Type().Field(i))
+			if ok && emptyUnions(v.Field(i), at, prune, found) && prune {
+				v.Field(i).Set(reflect.Zero(v.Type().Field(i).Type))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+		kept := reflect.MakeSlice(v.Type(), 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if !emptyUnions(v.Index(i), path+"/"+strconv.Itoa(i), prune, found) {
+				kept = reflect.Append(kept, v.Index(i))
+			}
+		}
+		if prune {
+			v.Set(kept)
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lossyRoot() *Root {
+	literal := func(s string) *RawArgument {
+		return &RawArgument{IsLiteral: true, Value: &RawArgumentValue{AsString: &s}}
+	}
+	return &Root{Pipeline: &Pipeline{
+		Agent: AnyAgent(),
+		Environment: []*EnvironmentEntry{
+			{Key: "A", Value: &EnvironmentValue{Single: literal("a")}},
+			{Key: "B", Value: &EnvironmentValue{}},
+		},
+		Stages: []*Stage{{
+			Name: "Build",
+			Branches: []*Branch{{Name: "default", Steps: []*AnyStep{
+				{},
+				{Step: &Step{Name: "sh", Arguments: &ArgumentList{Single: literal("make")}}},
+				{Step: &Step{Name: "deleteDir", Arguments: &ArgumentList{}}},
+			}}},
+		}},
+	}}
+}
+
+func TestMarshalEmptyUnions(t *testing.T) {
+	root := lossyRoot()
+
+	_, err := json.Marshal(root)
+	var empty *EmptyUnionError
+	require.True(t, errors.As(err, &empty), "%v", err)
+	assert.Equal(t, "EnvironmentValue", empty.Type)
+
+	_, err = Marshal(root, StrictMarshal())
+	assert.EqualError(t, err, "/pipeline/environment/1/value: EnvironmentValue has none of its alternatives set")
+
+	var warnings []*MarshalWarning
+	b, err := Marshal(root, CollectMarshalWarnings(&warnings))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"pipeline": {
+		"agent": {"type": "any"},
+		"environment": [
+			{"key": "A", "value": {"isLiteral": true, "value": "a"}},
+			{"key": "B", "value": null}
+		],
+		"stages": [{"name": "Build", "failFast": false, "branches": [{"name": "default", "steps": [
+			{"name": "sh", "arguments": {"isLiteral": true, "value": "make"}},
+			{"name": "deleteDir", "arguments": null}
+		]}]}]
+	}}`, string(b))
+	var paths []string
+	for _, w := range warnings {
+		paths = append(paths, w.String())
+	}
+	assert.Equal(t, []string{
+		"/pipeline/environment/1/value: EnvironmentValue has none of its alternatives set, so it was left out",
+		"/pipeline/stages/0/branches/0/steps/0: AnyStep has none of its alternatives set, so it was left out",
+		"/pipeline/stages/0/branches/0/steps/2/arguments: ArgumentList has none of its alternatives set, so it was " +
+			"left out",
+	}, paths)
+	assert.Len(t, root.Pipeline.Stages[0].Branches[0].Steps, 3, "the root is untouched")
+}
+
+func TestMarshalNestedEmptyUnion(t *testing.T) {
+	root := &Root{Pipeline: &Pipeline{
+		Agent:   AnyAgent(),
+		Options: &Options{Options: []*MethodCall{{Name: "timeout", Arguments: []*MethodArg{{Single: &ValueOrMethodCall{}}}}}},
+		Stages:  []*Stage{},
+	}}
+	var warnings []*MarshalWarning
+	b, err := Marshal(root, CollectMarshalWarnings(&warnings))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"options":{"options":[{"name":"timeout"}]}`)
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "/pipeline/options/options/0/arguments/0", warnings[0].Path)
+	assert.Equal(t, "/pipeline/options/options/0/arguments/0", warnings[1].Path)
+}
+
+func TestMarshalWithoutEmptyUnions(t *testing.T) {
+	root := &Root{Pipeline: &Pipeline{Agent: AnyAgent(), Stages: []*Stage{}}}
+	expected, err := json.Marshal(root)
+	require.NoError(t, err)
+	b, err := Marshal(root, StrictMarshal())
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	name := `Build <"a\b" & c>`
+	root := &Root{Pipeline: &Pipeline{Agent: AnyAgent(), Stages: []*Stage{{Name: name, Branches: []*Branch{{
+		Name: "default", Steps: []*AnyStep{NewAnyStep(&Step{Name: "deleteDir"})},
+	}}}}}}
+
+	b, err := Marshal(root)
+	require.NoError(t, err)
+	assert.Equal(t, `{"pipeline":{"agent":{"type":"any"},"stages":[{"branches":[{"name":"default","steps":[`+
+		`{"arguments":null,"name":"deleteDir"}]}],"failFast":false,"name":"Build \u003c\"a\\b\" \u0026 c\u003e"}]}}`, string(b))
+
+	b, err = Marshal(root, Encoding(EncoderOptions{TrailingNewline: true}))
+	require.NoError(t, err)
+	assert.Equal(t, `{"pipeline":{"agent":{"type":"any"},"stages":[{"branches":[{"name":"default","steps":[`+
+		`{"arguments":null,"name":"deleteDir"}]}],"failFast":false,"name":"Build <\"a\\b\" & c>"}]}}`+"\n", string(b))
+
+	b, err = MarshalIndent(root, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "pipeline": {
+    "agent": {
+      "type": "any"
+    },
+    "stages": [
+      {
+        "branches": [
+          {
+            "name": "default",
+            "steps": [
+              {
+                "arguments": null,
+                "name": "deleteDir"
+              }
+            ]
+          }
+        ],
+        "failFast": false,
+        "name": "Build \u003c\"a\\b\" \u0026 c\u003e"
+      }
+    ]
+  }
+}`, string(b))
+	again, err := MarshalIndent(root, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, b, again)
+
+	read := &Root{}
+	require.NoError(t, json.Unmarshal(b, read))
+	assert.Equal(t, name, read.Pipeline.Stages[0].Name)
+}
+
+func TestUnescapeHTML(t *testing.T) {
+	in := `["\u003c\u003e\u0026","\\u003c","\"\u2028"]`
+	assert.Equal(t, `["<>&","\\u003c","\"\u2028"]`, string(unescapeHTML([]byte(in))))
+}
@@ -0,0 +1,104 @@
+package model
+
+// Get returns the first option with the given name, or nil if there is none.
+func (strct *Options) Get(name string) *MethodCall {
+	if strct == nil {
+		return nil
+	}
+	for _, o := range strct.Options {
+		if o != nil && o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// Set adds an option, replacing any existing options with the same name rather than adding a duplicate. A replaced
+// option keeps its position; a new option is appended. Setting a nil option, or an option of nil Options, which have
+// nowhere to keep it, does nothing, so callers with a stage which may have no options need to create them first.
+func (strct *Options) Set(call *MethodCall) {
+	if strct == nil || call == nil {
+		return
+	}
+	replaced := false
+	out := strct.Options[:0]
+	for _, o := range strct.Options {
+		if o != nil && o.Name == call.Name {
+			if replaced {
+				continue
+			}
+			o = call
+			replaced = true
+		}
+		out = append(out, o)
+	}
+	if !replaced {
+		out = append(out, call)
+	}
+	strct.Options = out
+}
+
+// Remove removes all options with the given name, and returns whether there were any.
+func (strct *Options) Remove(name string) bool {
+	if strct == nil {
+		return false
+	}
+	removed := false
+	out := strct.Options[:0]
+	for _, o := range strct.Options {
+		if o != nil && o.Name == name {
+			removed = true
+			continue
+		}
+		out = append(out, o)
+	}
+	strct.Options = out
+	return removed
+}
+
+// Duplicates returns the names of options which appear more than once, in the order of their first appearance.
+func (strct *Options) Duplicates() []string {
+	if strct == nil {
+		return nil
+	}
+	counts := make(map[string]int)
+	var names []string
+	for _, o := range strct.Options {
+		if o == nil {
+			continue
+		}
+		counts[o.Name]++
+		if counts[o.Name] == 2 {
+			names = append(names, o.Name)
+		}
+	}
+	return names
+}
+
+// Args returns the call's arguments as an argument list, so they can be decoded with Bind. A single unnamed argument
+// becomes the list's Single argument. Arguments which are method calls themselves have no raw value, and are left out.
+func (strct *MethodCall) Args() *ArgumentList {
+	if strct == nil {
+		return nil
+	}
+	args := &ArgumentList{}
+	var unnamed []*RawArgument
+	for _, a := range strct.Arguments {
+		switch {
+		case a == nil:
+		case a.WithKey != nil && a.WithKey.Key != "":
+			if a.WithKey.Value != nil && a.WithKey.Value.Single != nil {
+				args.Named = append(args.Named, &ArgumentValue{Key: a.WithKey.Key, Value: a.WithKey.Value.Single})
+			}
+		case a.Single != nil && a.Single.Single != nil:
+			unnamed = append(unnamed, a.Single.Single)
+		}
+	}
+	switch {
+	case len(unnamed) == 1 && len(args.Named) == 0:
+		args.Single = unnamed[0]
+	case len(unnamed) > 0:
+		args.Positional = unnamed
+	}
+	return args
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func optionNames(o *Options) []string {
+	var names []string
+	for _, c := range o.Options {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestOptionsSet(t *testing.T) {
+	o := &Options{Options: []*MethodCall{{Name: "timestamps"}, {Name: "retry"}, {Name: "skipDefaultCheckout"}, {Name: "retry"}}}
+	assert.Equal(t, []string{"retry"}, o.Duplicates())
+
+	retry := &MethodCall{Name: "retry", Arguments: []*MethodArg{{Single: &ValueOrMethodCall{Single: &RawArgument{IsLiteral: true}}}}}
+	o.Set(retry)
+	assert.Equal(t, []string{"timestamps", "retry", "skipDefaultCheckout"}, optionNames(o))
+	assert.Same(t, retry, o.Get("retry"))
+	assert.Empty(t, o.Duplicates())
+
+	o.Set(&MethodCall{Name: "disableConcurrentBuilds"})
+	assert.Equal(t, []string{"timestamps", "retry", "skipDefaultCheckout", "disableConcurrentBuilds"}, optionNames(o))
+
+	o.Set(nil)
+	assert.Equal(t, []string{"timestamps", "retry", "skipDefaultCheckout", "disableConcurrentBuilds"}, optionNames(o))
+	var none *Options
+	none.Set(retry)
+	assert.Nil(t, none.Get("retry"))
+}
+
+func TestOptionsRemove(t *testing.T) {
+	o := &Options{Options: []*MethodCall{{Name: "timestamps"}, {Name: "retry"}, {Name: "retry"}}}
+	assert.True(t, o.Remove("retry"))
+	assert.Equal(t, []string{"timestamps"}, optionNames(o))
+	assert.False(t, o.Remove("retry"))
+	assert.Nil(t, o.Get("retry"))
+
+	var none *Options
+	assert.False(t, none.Remove("retry"))
+	assert.Nil(t, none.Duplicates())
+}
+
+func TestMethodCallArgs(t *testing.T) {
+	call := &MethodCall{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "lock", "arguments": [
+		{"key": "resource", "value": {"isLiteral": true, "value": "db"}},
+		{"key": "quantity", "value": {"isLiteral": true, "value": 2}},
+		{"key": "extra", "value": {"name": "nested", "arguments": []}}
+	]}`), call))
+	var lock struct {
+		Resource string `jenkins:"resource,default"`
+		Quantity int    `jenkins:"quantity"`
+	}
+	require.NoError(t, call.Args().Bind(&lock))
+	assert.Equal(t, "db", lock.Resource)
+	assert.Equal(t, 2, lock.Quantity)
+	assert.Len(t, call.Args().Named, 2)
+
+	call = &MethodCall{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "lock", "arguments": [{"isLiteral": true, "value": "db"}]}`), call))
+	assert.Equal(t, "db", call.Args().Single.String())
+
+	var none *MethodCall
+	assert.Nil(t, none.Args())
+}
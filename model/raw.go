@@ -0,0 +1,56 @@
+package model
+
+// AnnotationRawGroovy marks steps holding raw Groovy in the extended serialization, with the reason it wasn't
+// modelled as its value. MarshalExtended and UnmarshalExtended set and read it from the Raw field of Step.
+const AnnotationRawGroovy = "raw-groovy"
+
+// RawGroovy is Groovy source which couldn't be modelled, such as an arbitrary method chain or a closure in an unusual
+// position. It's kept so that writing the pipeline back out re-emits it verbatim instead of dropping it.
+type RawGroovy struct {
+	Source string `json:"source"`
+	// Reason says why the source wasn't modelled, if known.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RawStep returns a step holding raw Groovy. The Kyoto AST has no place for it, so it's a script step running the
+// source, which is how Jenkins would run it and how tools looking at steps see it; writers emit the source verbatim
+// instead.
+func RawStep(source string, reason string) *AnyStep {
+	return &AnyStep{Step: &Step{
+		Name: "script",
+		Arguments: &ArgumentList{Named: []*ArgumentValue{{
+			Key:   "scriptBlock",
+			Value: &RawArgument{IsLiteral: true, Value: &RawArgumentValue{AsString: &source}},
+		}}},
+		Raw: &RawGroovy{Source: source, Reason: reason},
+	}}
+}
+
+// withRawGroovy returns a node's annotations with AnnotationRawGroovy added if it's a raw step, leaving the node's own
+// annotations alone
+func withRawGroovy(node interface{}, a Annotations) Annotations {
+	s, ok := node.(*Step)
+	if !ok || s.Raw == nil {
+		return a
+	}
+	out := Annotations{}
+	for k, v := range a {
+		out[k] = v
+	}
+	out[AnnotationRawGroovy] = s.Raw.Reason
+	return out
+}
+
+// restoreRawGroovy sets the Raw field of a step whose annotations mark it as raw, and removes the mark
+func restoreRawGroovy(node interface{}, a *Annotations) {
+	reason, ok := (*a)[AnnotationRawGroovy]
+	s, isStep := node.(*Step)
+	if !ok || !isStep {
+		return
+	}
+	s.Raw = &RawGroovy{Source: s.Arguments.Get("scriptBlock").String(), Reason: reason}
+	delete(*a, AnnotationRawGroovy)
+	if len(*a) == 0 {
+		*a = nil
+	}
+}
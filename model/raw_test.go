@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawStep(t *testing.T) {
+	raw := RawStep("docker.image('maven').inside {\n    sh 'mvn'\n}", "method chain")
+	b, err := json.Marshal(raw)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "script", "arguments": [{"key": "scriptBlock",
+		"value": {"isLiteral": true, "value": "docker.image('maven').inside {\n    sh 'mvn'\n}"}}]}`, string(b))
+
+	root := &Root{Pipeline: &Pipeline{Agent: &Agent{Type: "any"}, Stages: []*Stage{{Name: "Build", Branches: []*Branch{{
+		Name: "default", Steps: []*AnyStep{raw}}}}}}}
+	b, err = MarshalExtended(root)
+	require.NoError(t, err)
+	assert.Nil(t, raw.Step.Annotations, "marshalling must not annotate the step itself")
+
+	decoded := &Root{}
+	require.NoError(t, UnmarshalExtended(b, decoded))
+	step := decoded.Pipeline.Stages[0].Branches[0].Steps[0].Step
+	assert.Equal(t, raw.Step.Raw, step.Raw)
+	assert.Empty(t, step.Annotations.Get(AnnotationRawGroovy))
+
+	// Plain JSON can't tell raw Groovy from a script step.
+	decoded = &Root{}
+	b, err = json.Marshal(root)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, decoded))
+	assert.Nil(t, decoded.Pipeline.Stages[0].Branches[0].Steps[0].Step.Raw)
+}
@@ -84,7 +84,10 @@ type Input struct {
 // InternalFunction An internal function call
 type InternalFunction struct {
 	Arguments []*RawArgument `json:"arguments,omitempty"`
-	Name      string         `json:"name,omitempty"`
+	// Calls are the arguments which are internal function calls themselves, by index, with nil for the others. The
+	// Arguments at their indexes are non-literal, holding the Groovy source of each call. See AddCall.
+	Calls []*InternalFunction `json:"-"`
+	Name  string              `json:"name,omitempty"`
 }
 
 // KeyAndValueOrMethodCall A key/value pair that can either have a value or method call
@@ -166,7 +169,10 @@ type Parameters struct {
 
 // Pipeline defines the actual pipeline
 type Pipeline struct {
-	Agent       *Agent              `json:"agent"`
+	Agent *Agent `json:"agent"`
+	// Annotations are tool metadata about the pipeline. They are not part of the Kyoto AST, so only MarshalExtended
+	// serializes them.
+	Annotations Annotations         `json:"-"`
 	Environment []*EnvironmentEntry `json:"environment,omitempty"`
 	Libraries   *Libraries          `json:"libraries,omitempty"`
 	Options     *Options            `json:"options,omitempty"`
@@ -203,8 +209,15 @@ type Root struct {
 
 // Stage A single Pipeline stage, with a name and either one or more branches or one or more nested stages
 type Stage struct {
-	Agent       *Agent              `json:"agent,omitempty"`
-	Branches    []*Branch           `json:"branches,omitempty"`
+	Agent *Agent `json:"agent,omitempty"`
+	// Annotations are tool metadata about the stage. They are not part of the Kyoto AST, so only MarshalExtended
+	// serializes them.
+	Annotations Annotations `json:"-"`
+	Branches    []*Branch   `json:"branches,omitempty"`
+	// DependsOn names the stages this stage must run after, for tools which run stages as a graph rather than in
+	// sequence. It is not part of the Kyoto AST, and Jenkins rejects it, so only MarshalExtended serializes it, unless
+	// Marshal is given MarshalDependsOn. Decode reads it when allowing unknown fields.
+	DependsOn   []string            `json:"-"`
 	Environment []*EnvironmentEntry `json:"environment,omitempty"`
 	FailFast    bool                `json:"failFast,omitempty"`
 	Input       *Input              `json:"input,omitempty"`
@@ -220,15 +233,23 @@ type Stage struct {
 
 // Step A single step with parameters
 type Step struct {
-	Arguments *ArgumentList `json:"arguments"`
-	Name      string        `json:"name"`
+	// Annotations are tool metadata about the step. They are not part of the Kyoto AST, so only MarshalExtended
+	// serializes them.
+	Annotations Annotations   `json:"-"`
+	Arguments   *ArgumentList `json:"arguments"`
+	Name        string        `json:"name"`
+	// Raw is set on script steps holding Groovy which couldn't be modelled. See RawStep.
+	Raw *RawGroovy `json:"-"`
 }
 
 // TreeStep A block-scoped step with parameters containing 1 or more other steps
 type TreeStep struct {
-	Arguments *ArgumentList `json:"arguments"`
-	Children  []*AnyStep    `json:"children"`
-	Name      string        `json:"name"`
+	// Annotations are tool metadata about the step. They are not part of the Kyoto AST, so only MarshalExtended
+	// serializes them.
+	Annotations Annotations   `json:"-"`
+	Arguments   *ArgumentList `json:"arguments"`
+	Children    []*AnyStep    `json:"children"`
+	Name        string        `json:"name"`
 }
 
 // Triggers One or more triggers
@@ -302,31 +323,32 @@ func (strct *Agent) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "argument":
-			if err := json.Unmarshal([]byte(v), &strct.Argument); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Argument, k); !unknown.add(err) {
 				return err
 			}
 		case "arguments":
-			if err := json.Unmarshal([]byte(v), &strct.Arguments); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Arguments, k); !unknown.add(err) {
 				return err
 			}
 		case "type":
-			if err := json.Unmarshal([]byte(v), &strct.Type); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Type, k); !unknown.add(err) {
 				return err
 			}
 			typeReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if type (a required property) was received
 	if !typeReceived {
-		return errors.New("\"type\" is required but was not present")
+		return unknown.missing(errors.New("\"type\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -370,22 +392,23 @@ func (strct *ArgumentValue) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "key":
-			if err := json.Unmarshal([]byte(v), &strct.Key); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Key, k); !unknown.add(err) {
 				return err
 			}
 		case "value":
-			if err := json.Unmarshal([]byte(v), &strct.Value); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Value, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -433,32 +456,33 @@ func (strct *Axis) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		case "values":
-			if err := json.Unmarshal([]byte(v), &strct.Values); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Values, k); !unknown.add(err) {
 				return err
 			}
 			valuesReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
 	// check if values (a required property) was received
 	if !valuesReceived {
-		return errors.New("\"values\" is required but was not present")
+		return unknown.missing(errors.New("\"values\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -506,32 +530,33 @@ func (strct *Branch) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		case "steps":
-			if err := json.Unmarshal([]byte(v), &strct.Steps); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Steps, k); !unknown.add(err) {
 				return err
 			}
 			stepsReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
 	// check if steps (a required property) was received
 	if !stepsReceived {
-		return errors.New("\"steps\" is required but was not present")
+		return unknown.missing(errors.New("\"steps\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -581,32 +606,33 @@ func (strct *BuildCondition) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "branch":
-			if err := json.Unmarshal([]byte(v), &strct.Branch); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Branch, k); !unknown.add(err) {
 				return err
 			}
 			branchReceived = true
 		case "condition":
-			if err := json.Unmarshal([]byte(v), &strct.Condition); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Condition, k); !unknown.add(err) {
 				return err
 			}
 			conditionReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if branch (a required property) was received
 	if !branchReceived {
-		return errors.New("\"branch\" is required but was not present")
+		return unknown.missing(errors.New("\"branch\" is required but was not present"))
 	}
 	// check if condition (a required property) was received
 	if !conditionReceived {
-		return errors.New("\"condition\" is required but was not present")
+		return unknown.missing(errors.New("\"condition\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -648,22 +674,23 @@ func (strct *EnvironmentEntry) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "key":
-			if err := json.Unmarshal([]byte(v), &strct.Key); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Key, k); !unknown.add(err) {
 				return err
 			}
 		case "value":
-			if err := json.Unmarshal([]byte(v), &strct.Value); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Value, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -723,36 +750,37 @@ func (strct *ExcludeAxis) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "inverse":
-			if err := json.Unmarshal([]byte(v), &strct.Inverse); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Inverse, k); !unknown.add(err) {
 				return err
 			}
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		case "values":
-			if err := json.Unmarshal([]byte(v), &strct.Values); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Values, k); !unknown.add(err) {
 				return err
 			}
 			valuesReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
 	// check if values (a required property) was received
 	if !valuesReceived {
-		return errors.New("\"values\" is required but was not present")
+		return unknown.missing(errors.New("\"values\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -849,43 +877,44 @@ func (strct *Input) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "id":
-			if err := json.Unmarshal([]byte(v), &strct.ID); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.ID, k); !unknown.add(err) {
 				return err
 			}
 		case "message":
-			if err := json.Unmarshal([]byte(v), &strct.Message); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Message, k); !unknown.add(err) {
 				return err
 			}
 			messageReceived = true
 		case "ok":
-			if err := json.Unmarshal([]byte(v), &strct.Ok); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Ok, k); !unknown.add(err) {
 				return err
 			}
 		case "parameters":
-			if err := json.Unmarshal([]byte(v), &strct.Parameters); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Parameters, k); !unknown.add(err) {
 				return err
 			}
 		case "submitter":
-			if err := json.Unmarshal([]byte(v), &strct.Submitter); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Submitter, k); !unknown.add(err) {
 				return err
 			}
 		case "submitterParameter":
-			if err := json.Unmarshal([]byte(v), &strct.SubmitterParameter); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.SubmitterParameter, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if message (a required property) was received
 	if !messageReceived {
-		return errors.New("\"message\" is required but was not present")
+		return unknown.missing(errors.New("\"message\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -900,7 +929,7 @@ func (strct *InternalFunction) MarshalJSON() ([]byte, error) {
 		buf.WriteString(",")
 	}
 	buf.WriteString("\"arguments\": ")
-	if tmp, err = json.Marshal(strct.Arguments); err != nil {
+	if tmp, err = strct.marshalArguments(); err != nil {
 		return nil, err
 	}
 	buf.Write(tmp)
@@ -927,22 +956,23 @@ func (strct *InternalFunction) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "arguments":
-			if err := json.Unmarshal([]byte(v), &strct.Arguments); err != nil {
+			if err := strct.unmarshalArguments([]byte(v)); !unknown.add(err) {
 				return err
 			}
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -984,22 +1014,23 @@ func (strct *KeyAndValueOrMethodCall) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "key":
-			if err := json.Unmarshal([]byte(v), &strct.Key); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Key, k); !unknown.add(err) {
 				return err
 			}
 		case "value":
-			if err := json.Unmarshal([]byte(v), &strct.Value); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Value, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1031,18 +1062,19 @@ func (strct *Libraries) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "libraries":
-			if err := json.Unmarshal([]byte(v), &strct.Libraries); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Libraries, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1084,22 +1116,23 @@ func (strct *MapArgumentValue) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "key":
-			if err := json.Unmarshal([]byte(v), &strct.Key); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Key, k); !unknown.add(err) {
 				return err
 			}
 		case "value":
-			if err := json.Unmarshal([]byte(v), &strct.Value); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Value, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1109,6 +1142,9 @@ func (strct *MapArgumentValueRawOrList) MarshalJSON() ([]byte, error) {
 	if strct.Raw != nil {
 		return strct.Raw.MarshalJSON()
 	}
+	if strct.List == nil {
+		return nil, &EmptyUnionError{Type: "MapArgumentValueRawOrList"}
+	}
 
 	buf := bytes.NewBuffer(make([]byte, 0))
 	if tmp, err = json.Marshal(strct.List); err != nil {
@@ -1123,11 +1159,25 @@ func (strct *MapArgumentValueRawOrList) MarshalJSON() ([]byte, error) {
 func (strct *MapArgumentValueRawOrList) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.List); err == nil {
+	var listErr error
+	if listErr = unmarshalValue(b, &strct.List); listErr == nil {
+		strct.Raw = nil
+		return nil
+	}
+	if err = unmarshalValue(b, &strct.Raw); err == nil {
+		strct.List = nil
 		return nil
 	}
-	err = json.Unmarshal(b, &strct.Raw)
 
+	if isArray(b) {
+		if decoded(listErr) {
+			strct.Raw = nil
+		}
+		return listErr
+	}
+	if decoded(err) {
+		strct.List = nil
+	}
 	return err
 }
 
@@ -1143,6 +1193,8 @@ func (strct *ArgumentList) MarshalJSON() ([]byte, error) {
 		tmp, err = json.Marshal(strct.Named)
 	} else if strct.Positional != nil {
 		tmp, err = json.Marshal(strct.Positional)
+	} else {
+		return nil, &EmptyUnionError{Type: "ArgumentList"}
 	}
 	if err != nil {
 		return nil, err
@@ -1154,16 +1206,35 @@ func (strct *ArgumentList) MarshalJSON() ([]byte, error) {
 func (strct *ArgumentList) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.Named); err == nil {
+	var namedErr, positionalErr error
+	if namedErr = unmarshalValue(b, &strct.Named); namedErr == nil {
+		strct.Single, strct.Positional = nil, nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Positional); err == nil {
+	if positionalErr = unmarshalValue(b, &strct.Positional); positionalErr == nil {
+		strct.Single, strct.Named = nil, nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Single); err == nil {
+	if err = unmarshalValue(b, &strct.Single); err == nil {
+		strct.Named, strct.Positional = nil, nil
 		return nil
 	}
 
+	switch {
+	case isArray(b) && hasProperty(firstElement(b), "key"):
+		if decoded(namedErr) {
+			strct.Single, strct.Positional = nil, nil
+		}
+		return namedErr
+	case isArray(b):
+		if decoded(positionalErr) {
+			strct.Single, strct.Named = nil, nil
+		}
+		return positionalErr
+	}
+	if decoded(err) {
+		strct.Named, strct.Positional = nil, nil
+	}
 	return err
 }
 
@@ -1175,22 +1246,32 @@ func (strct *AnyStep) MarshalJSON() ([]byte, error) {
 	if strct.Tree != nil {
 		return strct.Tree.MarshalJSON()
 	}
-	return nil, nil
+	return nil, &EmptyUnionError{Type: "AnyStep"}
 }
 
 // UnmarshalJSON unmarshals the struct
 func (strct *AnyStep) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.Tree); err == nil {
+	var treeErr error
+	if treeErr = unmarshalValue(b, &strct.Tree); treeErr == nil {
 		strct.Step = nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Step); err == nil {
+	if err = unmarshalValue(b, &strct.Step); err == nil {
 		strct.Tree = nil
 		return nil
 	}
 
+	if hasProperty(b, "children") {
+		if decoded(treeErr) {
+			strct.Step = nil
+		}
+		return treeErr
+	}
+	if decoded(err) {
+		strct.Tree = nil
+	}
 	return err
 }
 
@@ -1202,22 +1283,32 @@ func (strct *EnvironmentValue) MarshalJSON() ([]byte, error) {
 	if strct.Single != nil {
 		return strct.Single.MarshalJSON()
 	}
-	return nil, nil
+	return nil, &EmptyUnionError{Type: "EnvironmentValue"}
 }
 
 // UnmarshalJSON unmarshals the struct
 func (strct *EnvironmentValue) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.Function); err == nil {
+	var functionErr error
+	if functionErr = unmarshalValue(b, &strct.Function); functionErr == nil {
 		strct.Single = nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Single); err == nil {
+	if err = unmarshalValue(b, &strct.Single); err == nil {
 		strct.Function = nil
 		return nil
 	}
 
+	if !hasProperty(b, "isLiteral") {
+		if decoded(functionErr) {
+			strct.Single = nil
+		}
+		return functionErr
+	}
+	if decoded(err) {
+		strct.Function = nil
+	}
 	return err
 }
 
@@ -1229,20 +1320,32 @@ func (strct *ValueOrMethodCall) MarshalJSON() ([]byte, error) {
 	if strct.Single != nil {
 		return strct.Single.MarshalJSON()
 	}
-	return nil, nil
+	return nil, &EmptyUnionError{Type: "ValueOrMethodCall"}
 }
 
 // UnmarshalJSON unmarshals the struct
 func (strct *ValueOrMethodCall) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.Call); err == nil {
+	var callErr error
+	if callErr = unmarshalValue(b, &strct.Call); callErr == nil {
+		strct.Single = nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Single); err == nil {
+	if err = unmarshalValue(b, &strct.Single); err == nil {
+		strct.Call = nil
 		return nil
 	}
 
+	if !hasProperty(b, "isLiteral") {
+		if decoded(callErr) {
+			strct.Single = nil
+		}
+		return callErr
+	}
+	if decoded(err) {
+		strct.Call = nil
+	}
 	return err
 }
 
@@ -1254,20 +1357,32 @@ func (strct *MethodArg) MarshalJSON() ([]byte, error) {
 	if strct.WithKey != nil {
 		return strct.WithKey.MarshalJSON()
 	}
-	return nil, nil
+	return nil, &EmptyUnionError{Type: "MethodArg"}
 }
 
 // UnmarshalJSON unmarshals the struct
 func (strct *MethodArg) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.WithKey); err == nil {
+	var withKeyErr error
+	if withKeyErr = unmarshalValue(b, &strct.WithKey); withKeyErr == nil {
+		strct.Single = nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Single); err == nil {
+	if err = unmarshalValue(b, &strct.Single); err == nil {
+		strct.WithKey = nil
 		return nil
 	}
 
+	if hasProperty(b, "key") {
+		if decoded(withKeyErr) {
+			strct.Single = nil
+		}
+		return withKeyErr
+	}
+	if decoded(err) {
+		strct.WithKey = nil
+	}
 	return err
 }
 
@@ -1279,20 +1394,32 @@ func (strct *StepOrNestedWhenCondition) MarshalJSON() ([]byte, error) {
 	if strct.Nested != nil {
 		return strct.Nested.MarshalJSON()
 	}
-	return nil, nil
+	return nil, &EmptyUnionError{Type: "StepOrNestedWhenCondition"}
 }
 
 // UnmarshalJSON unmarshals the struct
 func (strct *StepOrNestedWhenCondition) UnmarshalJSON(b []byte) error {
 	var err error
 
-	if err = json.Unmarshal(b, &strct.Nested); err == nil {
+	var nestedErr error
+	if nestedErr = unmarshalValue(b, &strct.Nested); nestedErr == nil {
+		strct.Step = nil
 		return nil
 	}
-	if err = json.Unmarshal(b, &strct.Step); err == nil {
+	if err = unmarshalValue(b, &strct.Step); err == nil {
+		strct.Nested = nil
 		return nil
 	}
 
+	if hasProperty(b, "children") {
+		if decoded(nestedErr) {
+			strct.Step = nil
+		}
+		return nestedErr
+	}
+	if decoded(err) {
+		strct.Nested = nil
+	}
 	return err
 }
 
@@ -1437,64 +1564,65 @@ func (strct *Matrix) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "agent":
-			if err := json.Unmarshal([]byte(v), &strct.Agent); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Agent, k); !unknown.add(err) {
 				return err
 			}
 		case "axes":
-			if err := json.Unmarshal([]byte(v), &strct.Axes); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Axes, k); !unknown.add(err) {
 				return err
 			}
 			axesReceived = true
 		case "environment":
-			if err := json.Unmarshal([]byte(v), &strct.Environment); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Environment, k); !unknown.add(err) {
 				return err
 			}
 		case "excludes":
-			if err := json.Unmarshal([]byte(v), &strct.Excludes); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Excludes, k); !unknown.add(err) {
 				return err
 			}
 		case "input":
-			if err := json.Unmarshal([]byte(v), &strct.Input); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Input, k); !unknown.add(err) {
 				return err
 			}
 		case "options":
-			if err := json.Unmarshal([]byte(v), &strct.Options); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Options, k); !unknown.add(err) {
 				return err
 			}
 		case "post":
-			if err := json.Unmarshal([]byte(v), &strct.Post); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Post, k); !unknown.add(err) {
 				return err
 			}
 		case "stages":
-			if err := json.Unmarshal([]byte(v), &strct.Stages); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Stages, k); !unknown.add(err) {
 				return err
 			}
 			stagesReceived = true
 		case "tools":
-			if err := json.Unmarshal([]byte(v), &strct.Tools); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Tools, k); !unknown.add(err) {
 				return err
 			}
 		case "when":
-			if err := json.Unmarshal([]byte(v), &strct.When); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.When, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if axes (a required property) was received
 	if !axesReceived {
-		return errors.New("\"axes\" is required but was not present")
+		return unknown.missing(errors.New("\"axes\" is required but was not present"))
 	}
 	// check if stages (a required property) was received
 	if !stagesReceived {
-		return errors.New("\"stages\" is required but was not present")
+		return unknown.missing(errors.New("\"stages\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1538,22 +1666,23 @@ func (strct *MethodCall) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "arguments":
-			if err := json.Unmarshal([]byte(v), &strct.Arguments); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Arguments, k); !unknown.add(err) {
 				return err
 			}
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1601,32 +1730,33 @@ func (strct *NestedWhenCondition) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "children":
-			if err := json.Unmarshal([]byte(v), &strct.Children); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Children, k); !unknown.add(err) {
 				return err
 			}
 			childrenReceived = true
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if children (a required property) was received
 	if !childrenReceived {
-		return errors.New("\"children\" is required but was not present")
+		return unknown.missing(errors.New("\"children\" is required but was not present"))
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1658,18 +1788,19 @@ func (strct *Options) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "options":
-			if err := json.Unmarshal([]byte(v), &strct.Options); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Options, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1701,18 +1832,19 @@ func (strct *Parameters) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "parameters":
-			if err := json.Unmarshal([]byte(v), &strct.Parameters); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Parameters, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1846,60 +1978,61 @@ func (strct *Pipeline) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "agent":
-			if err := json.Unmarshal([]byte(v), &strct.Agent); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Agent, k); !unknown.add(err) {
 				return err
 			}
 			agentReceived = true
 		case "environment":
-			if err := json.Unmarshal([]byte(v), &strct.Environment); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Environment, k); !unknown.add(err) {
 				return err
 			}
 		case "libraries":
-			if err := json.Unmarshal([]byte(v), &strct.Libraries); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Libraries, k); !unknown.add(err) {
 				return err
 			}
 		case "options":
-			if err := json.Unmarshal([]byte(v), &strct.Options); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Options, k); !unknown.add(err) {
 				return err
 			}
 		case "parameters":
-			if err := json.Unmarshal([]byte(v), &strct.Parameters); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Parameters, k); !unknown.add(err) {
 				return err
 			}
 		case "post":
-			if err := json.Unmarshal([]byte(v), &strct.Post); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Post, k); !unknown.add(err) {
 				return err
 			}
 		case "stages":
-			if err := json.Unmarshal([]byte(v), &strct.Stages); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Stages, k); !unknown.add(err) {
 				return err
 			}
 			stagesReceived = true
 		case "tools":
-			if err := json.Unmarshal([]byte(v), &strct.Tools); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Tools, k); !unknown.add(err) {
 				return err
 			}
 		case "triggers":
-			if err := json.Unmarshal([]byte(v), &strct.Triggers); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Triggers, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if agent (a required property) was received
 	if !agentReceived {
-		return errors.New("\"agent\" is required but was not present")
+		return unknown.missing(errors.New("\"agent\" is required but was not present"))
 	}
 	// check if stages (a required property) was received
 	if !stagesReceived {
-		return errors.New("\"stages\" is required but was not present")
+		return unknown.missing(errors.New("\"stages\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1934,23 +2067,24 @@ func (strct *Post) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "conditions":
-			if err := json.Unmarshal([]byte(v), &strct.Conditions); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Conditions, k); !unknown.add(err) {
 				return err
 			}
 			conditionsReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if conditions (a required property) was received
 	if !conditionsReceived {
-		return errors.New("\"conditions\" is required but was not present")
+		return unknown.missing(errors.New("\"conditions\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -1998,32 +2132,33 @@ func (strct *RawArgument) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "isLiteral":
-			if err := json.Unmarshal([]byte(v), &strct.IsLiteral); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.IsLiteral, k); !unknown.add(err) {
 				return err
 			}
 			isLiteralReceived = true
 		case "value":
-			if err := json.Unmarshal([]byte(v), &strct.Value); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Value, k); !unknown.add(err) {
 				return err
 			}
 			valueReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if isLiteral (a required property) was received
 	if !isLiteralReceived {
-		return errors.New("\"isLiteral\" is required but was not present")
+		return unknown.missing(errors.New("\"isLiteral\" is required but was not present"))
 	}
 	// check if value (a required property) was received
 	if !valueReceived {
-		return errors.New("\"value\" is required but was not present")
+		return unknown.missing(errors.New("\"value\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2100,23 +2235,24 @@ func (strct *Root) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "pipeline":
-			if err := json.Unmarshal([]byte(v), &strct.Pipeline); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Pipeline, k); !unknown.add(err) {
 				return err
 			}
 			pipelineReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if pipeline (a required property) was received
 	if !pipelineReceived {
-		return errors.New("\"pipeline\" is required but was not present")
+		return unknown.missing(errors.New("\"pipeline\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2293,71 +2429,72 @@ func (strct *Stage) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "agent":
-			if err := json.Unmarshal([]byte(v), &strct.Agent); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Agent, k); !unknown.add(err) {
 				return err
 			}
 		case "branches":
-			if err := json.Unmarshal([]byte(v), &strct.Branches); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Branches, k); !unknown.add(err) {
 				return err
 			}
 		case "environment":
-			if err := json.Unmarshal([]byte(v), &strct.Environment); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Environment, k); !unknown.add(err) {
 				return err
 			}
 		case "failFast":
-			if err := json.Unmarshal([]byte(v), &strct.FailFast); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.FailFast, k); !unknown.add(err) {
 				return err
 			}
 		case "input":
-			if err := json.Unmarshal([]byte(v), &strct.Input); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Input, k); !unknown.add(err) {
 				return err
 			}
 		case "matrix":
-			if err := json.Unmarshal([]byte(v), &strct.Matrix); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Matrix, k); !unknown.add(err) {
 				return err
 			}
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		case "options":
-			if err := json.Unmarshal([]byte(v), &strct.Options); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Options, k); !unknown.add(err) {
 				return err
 			}
 		case "parallel":
-			if err := json.Unmarshal([]byte(v), &strct.Parallel); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Parallel, k); !unknown.add(err) {
 				return err
 			}
 		case "post":
-			if err := json.Unmarshal([]byte(v), &strct.Post); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Post, k); !unknown.add(err) {
 				return err
 			}
 		case "stages":
-			if err := json.Unmarshal([]byte(v), &strct.Stages); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Stages, k); !unknown.add(err) {
 				return err
 			}
 		case "tools":
-			if err := json.Unmarshal([]byte(v), &strct.Tools); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Tools, k); !unknown.add(err) {
 				return err
 			}
 		case "when":
-			if err := json.Unmarshal([]byte(v), &strct.When); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.When, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2405,32 +2542,33 @@ func (strct *Step) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "arguments":
-			if err := json.Unmarshal([]byte(v), &strct.Arguments); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Arguments, k); !unknown.add(err) {
 				return err
 			}
 			argumentsReceived = true
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if arguments (a required property) was received
 	if !argumentsReceived {
-		return errors.New("\"arguments\" is required but was not present")
+		return unknown.missing(errors.New("\"arguments\" is required but was not present"))
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2491,41 +2629,42 @@ func (strct *TreeStep) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "arguments":
-			if err := json.Unmarshal([]byte(v), &strct.Arguments); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Arguments, k); !unknown.add(err) {
 				return err
 			}
 			argumentsReceived = true
 		case "children":
-			if err := json.Unmarshal([]byte(v), &strct.Children); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Children, k); !unknown.add(err) {
 				return err
 			}
 			childrenReceived = true
 		case "name":
-			if err := json.Unmarshal([]byte(v), &strct.Name); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Name, k); !unknown.add(err) {
 				return err
 			}
 			nameReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if arguments (a required property) was received
 	if !argumentsReceived {
-		return errors.New("\"arguments\" is required but was not present")
+		return unknown.missing(errors.New("\"arguments\" is required but was not present"))
 	}
 	// check if children (a required property) was received
 	if !childrenReceived {
-		return errors.New("\"children\" is required but was not present")
+		return unknown.missing(errors.New("\"children\" is required but was not present"))
 	}
 	// check if name (a required property) was received
 	if !nameReceived {
-		return errors.New("\"name\" is required but was not present")
+		return unknown.missing(errors.New("\"name\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2557,18 +2696,19 @@ func (strct *Triggers) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "triggers":
-			if err := json.Unmarshal([]byte(v), &strct.Triggers); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Triggers, k); !unknown.add(err) {
 				return err
 			}
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
-	return nil
+	return unknown.error()
 }
 
 // MarshalJSON marshals the struct
@@ -2633,33 +2773,34 @@ func (strct *When) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &jsonMap); err != nil {
 		return err
 	}
-	// parse all the defined properties
+	// parse all the defined properties, collecting unknown ones
+	var unknown unknownProperties
 	for k, v := range jsonMap {
 		switch k {
 		case "beforeAgent":
-			if err := json.Unmarshal([]byte(v), &strct.BeforeAgent); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.BeforeAgent, k); !unknown.add(err) {
 				return err
 			}
 		case "beforeInput":
-			if err := json.Unmarshal([]byte(v), &strct.BeforeInput); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.BeforeInput, k); !unknown.add(err) {
 				return err
 			}
 		case "beforeOptions":
-			if err := json.Unmarshal([]byte(v), &strct.BeforeOptions); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.BeforeOptions, k); !unknown.add(err) {
 				return err
 			}
 		case "conditions":
-			if err := json.Unmarshal([]byte(v), &strct.Conditions); err != nil {
+			if err := unmarshalProperty([]byte(v), &strct.Conditions, k); !unknown.add(err) {
 				return err
 			}
 			conditionsReceived = true
 		default:
-			return fmt.Errorf("additional property not allowed: \"" + k + "\"")
+			unknown.property(k, v)
 		}
 	}
 	// check if conditions (a required property) was received
 	if !conditionsReceived {
-		return errors.New("\"conditions\" is required but was not present")
+		return unknown.missing(errors.New("\"conditions\" is required but was not present"))
 	}
-	return nil
+	return unknown.error()
 }
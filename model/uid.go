@@ -0,0 +1,53 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// AnnotationUID is the stable identity of a node, which stays the same when the node is renamed or moved. Tools such
+// as editors and diff and merge tools use it to track stages and steps across edits.
+const AnnotationUID = "uid"
+
+// AssignUIDs gives the pipeline and each of its stages and steps which doesn't have a UID a new random one, and keeps
+// existing ones. parser.Parse and UnmarshalExtended call it, so UIDs are stable as long as pipelines are stored in the
+// extended format; plain JSON has nowhere to keep them.
+func AssignUIDs(root *Root) {
+	eachAnnotated(root, func(_ string, _ interface{}, a *Annotations) {
+		if a.Get(AnnotationUID) == "" {
+			a.Set(AnnotationUID, newUID())
+		}
+	})
+}
+
+// ClearUIDs removes the UIDs of the pipeline and each of its stages and steps, such as to compare pipelines parsed
+// separately, which have different ones.
+func ClearUIDs(root *Root) {
+	eachAnnotated(root, func(_ string, _ interface{}, a *Annotations) {
+		delete(*a, AnnotationUID)
+		if len(*a) == 0 {
+			*a = nil
+		}
+	})
+}
+
+// FindUID returns the JSON pointer and node, a *Pipeline, *Stage, *Step, or *TreeStep, with the given UID, or "" and
+// nil if there isn't one
+func FindUID(root *Root, uid string) (string, interface{}) {
+	var path string
+	var node interface{}
+	eachAnnotated(root, func(p string, n interface{}, a *Annotations) {
+		if node == nil && uid != "" && a.Get(AnnotationUID) == uid {
+			path, node = p, n
+		}
+	})
+	return path, node
+}
+
+func newUID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
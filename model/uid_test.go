@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIDs(t *testing.T) {
+	steps := []*Branch{{Name: "default", Steps: []*AnyStep{{Step: &Step{Name: "echo"}}}}}
+	root := &Root{Pipeline: &Pipeline{Agent: &Agent{Type: "any"}, Stages: []*Stage{{Name: "a", Branches: steps},
+		{Name: "b", Branches: steps}}}}
+	root.Pipeline.Stages[1].Annotations.Set(AnnotationUID, "kept")
+	AssignUIDs(root)
+
+	a := root.Pipeline.Stages[0]
+	uid := a.Annotations.Get(AnnotationUID)
+	assert.Len(t, uid, 16)
+	assert.NotEqual(t, uid, a.Branches[0].Steps[0].Step.Annotations.Get(AnnotationUID))
+	assert.Equal(t, "kept", root.Pipeline.Stages[1].Annotations.Get(AnnotationUID))
+
+	// Renaming and moving a stage keeps its UID.
+	a.Name = "renamed"
+	root.Pipeline.Stages[0], root.Pipeline.Stages[1] = root.Pipeline.Stages[1], a
+	b, err := MarshalExtended(root)
+	require.NoError(t, err)
+	decoded := &Root{}
+	require.NoError(t, UnmarshalExtended(b, decoded))
+	path, node := FindUID(decoded, uid)
+	assert.Equal(t, "/pipeline/stages/1", path)
+	assert.Equal(t, "renamed", node.(*Stage).Name)
+
+	path, node = FindUID(decoded, "missing")
+	assert.Equal(t, "", path)
+	assert.Nil(t, node)
+
+	root.Pipeline.Stages[0].Annotations.Set(AnnotationUID, uid)
+	err = CheckInvariants(root)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/pipeline/stages/1 has UID "+uid+", which another node has too")
+}
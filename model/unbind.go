@@ -0,0 +1,233 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/groovyq"
+)
+
+// ArgsFromStruct builds named arguments from the struct v, or a pointer to it, using the same jenkins tags as Bind.
+// Fields with zero values are left out, so Jenkins applies the step's defaults. Strings, booleans, and numbers
+// become literal arguments; RawArgument fields are used as they are; slices, maps, and structs become Groovy list and
+// map literals.
+func ArgsFromStruct(v interface{}) (*ArgumentList, error) {
+	args := &ArgumentList{Named: []*ArgumentValue{}}
+	err := eachTaggedField(v, func(name string, f reflect.Value) error {
+		raw, err := rawFromValue(f)
+		if err != nil {
+			return fmt.Errorf("argument %q: %s", name, err)
+		}
+		args.Named = append(args.Named, &ArgumentValue{Key: name, Value: raw})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// MapArgsFromStruct builds map arguments, as used by agents, from the struct v, or a pointer to it. It is like
+// ArgsFromStruct, except that nested structs and maps become nested map arguments rather than Groovy map literals.
+func MapArgsFromStruct(v interface{}) ([]*MapArgumentValue, error) {
+	out := []*MapArgumentValue{}
+	err := eachTaggedField(v, func(name string, f reflect.Value) error {
+		value, err := mapArgFromValue(f)
+		if err != nil {
+			return fmt.Errorf("argument %q: %s", name, err)
+		}
+		out = append(out, &MapArgumentValue{Key: name, Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// eachTaggedField calls fn with the non-zero tagged fields of a struct, in field order
+func eachTaggedField(v interface{}, fn func(name string, f reflect.Value) error) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("a struct or pointer to a struct is required")
+	}
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _ := parseTag(t.Field(i).Tag.Get("jenkins"))
+		f := rv.Field(i)
+		if name == "" || name == "-" || t.Field(i).PkgPath != "" || isZero(f) {
+			continue
+		}
+		if err := fn(name, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func mapArgFromValue(v reflect.Value) (*MapArgumentValueRawOrList, error) {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() &&
+		v.Type() != reflect.PtrTo(rawArgumentType) {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == rawArgumentType {
+			break
+		}
+		list, err := MapArgsFromStruct(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return &MapArgumentValueRawOrList{List: list}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot encode %s", v.Type())
+		}
+		list := []*MapArgumentValue{}
+		for _, k := range sortedKeys(v) {
+			value, err := mapArgFromValue(v.MapIndex(k))
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", k.String(), err)
+			}
+			list = append(list, &MapArgumentValue{Key: k.String(), Value: value})
+		}
+		return &MapArgumentValueRawOrList{List: list}, nil
+	}
+	raw, err := rawFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return &MapArgumentValueRawOrList{Raw: raw}, nil
+}
+
+func rawFromValue(v reflect.Value) (*RawArgument, error) {
+	switch {
+	case v.Type() == rawArgumentType:
+		raw := v.Interface().(RawArgument)
+		return &raw, nil
+	case v.Type() == reflect.PtrTo(rawArgumentType):
+		return v.Interface().(*RawArgument), nil
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface:
+		if v.IsNil() {
+			return &RawArgument{IsLiteral: false, Value: &RawArgumentValue{AsString: stringPointer("null")}}, nil
+		}
+		return rawFromValue(v.Elem())
+	}
+	value := &RawArgumentValue{}
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		value.AsString = &s
+	case reflect.Bool:
+		b := v.Bool()
+		value.AsBool = &b
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f := float64(v.Int())
+		value.AsFloat = &f
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f := float64(v.Uint())
+		value.AsFloat = &f
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		value.AsFloat = &f
+	default:
+		src, err := groovyValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return &RawArgument{IsLiteral: false, Value: &RawArgumentValue{AsString: &src}}, nil
+	}
+	return &RawArgument{IsLiteral: true, Value: value}, nil
+}
+
+// groovyValue renders a value as a Groovy literal
+func groovyValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "null", nil
+		}
+		return groovyValue(v.Elem())
+	case reflect.String:
+		return groovyq.Quote(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			s, err := groovyValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("cannot encode %s", v.Type())
+		}
+		var parts []string
+		for _, k := range sortedKeys(v) {
+			s, err := groovyValue(v.MapIndex(k))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, groovyq.Key(k.String())+": "+s)
+		}
+		return groovyMap(parts), nil
+	case reflect.Struct:
+		var parts []string
+		err := eachTaggedField(v.Interface(), func(name string, f reflect.Value) error {
+			s, err := groovyValue(f)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, groovyq.Key(name)+": "+s)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		return groovyMap(parts), nil
+	}
+	return "", fmt.Errorf("cannot encode %s", v.Type())
+}
+
+func groovyMap(entries []string) string {
+	if len(entries) == 0 {
+		return "[:]"
+	}
+	return "[" + strings.Join(entries, ", ") + "]"
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
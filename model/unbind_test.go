@@ -0,0 +1,89 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dockerAgent struct {
+	Image      string            `jenkins:"image"`
+	Args       string            `jenkins:"args"`
+	ReuseNode  bool              `jenkins:"reuseNode"`
+	Registry   *registry         `jenkins:"registry"`
+	Labels     map[string]string `jenkins:"labels"`
+	unexported string            `jenkins:"hidden"`
+}
+
+type registry struct {
+	URL           string `jenkins:"url"`
+	CredentialsID string `jenkins:"credentialsId"`
+}
+
+func TestArgsFromStruct(t *testing.T) {
+	label := "build it"
+	args, err := ArgsFromStruct(&shArgs{
+		Script:   "make",
+		Label:    &label,
+		Retries:  2,
+		Encoding: &RawArgument{IsLiteral: false, Value: &RawArgumentValue{AsString: stringPointer("env.ENC")}},
+		Env:      map[string]string{"B": "it's", "A": "1"},
+		Targets:  []string{"all"},
+		Ignored:  "x",
+	})
+	require.NoError(t, err)
+	b, err := json.Marshal(args)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"key": "script", "value": {"isLiteral": true, "value": "make"}},
+		{"key": "label", "value": {"isLiteral": true, "value": "build it"}},
+		{"key": "encoding", "value": {"isLiteral": false, "value": "env.ENC"}},
+		{"key": "retries", "value": {"isLiteral": true, "value": 2}},
+		{"key": "env", "value": {"isLiteral": false, "value": "[A: '1', B: 'it\\'s']"}},
+		{"key": "targets", "value": {"isLiteral": false, "value": "['all']"}}
+	]`, string(b))
+
+	// Binding the arguments gives back the struct, less untagged fields.
+	got := &shArgs{}
+	require.NoError(t, args.Bind(got))
+	assert.Equal(t, map[string]string{"B": "it's", "A": "1"}, got.Env)
+	assert.Equal(t, 2, got.Retries)
+
+	args, err = ArgsFromStruct(shArgs{})
+	require.NoError(t, err)
+	b, err = json.Marshal(args)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(b))
+
+	_, err = ArgsFromStruct("nope")
+	assert.EqualError(t, err, "a struct or pointer to a struct is required")
+}
+
+func TestMapArgsFromStruct(t *testing.T) {
+	args, err := MapArgsFromStruct(dockerAgent{
+		Image:      "maven:3",
+		ReuseNode:  true,
+		Registry:   &registry{URL: "https://registry.example.com", CredentialsID: "reg"},
+		Labels:     map[string]string{"team": "core"},
+		unexported: "x",
+	})
+	require.NoError(t, err)
+	agent := &Agent{Type: "docker", Arguments: args}
+	b, err := json.Marshal(agent)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "docker", "arguments": [
+		{"key": "image", "value": {"isLiteral": true, "value": "maven:3"}},
+		{"key": "reuseNode", "value": {"isLiteral": true, "value": true}},
+		{"key": "registry", "value": [
+			{"key": "url", "value": {"isLiteral": true, "value": "https://registry.example.com"}},
+			{"key": "credentialsId", "value": {"isLiteral": true, "value": "reg"}}
+		]},
+		{"key": "labels", "value": [{"key": "team", "value": {"isLiteral": true, "value": "core"}}]}
+	]}`, string(b))
+
+	decoded := &Agent{}
+	require.NoError(t, json.Unmarshal(b, decoded))
+	assert.Equal(t, agent, decoded)
+}
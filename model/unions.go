@@ -0,0 +1,286 @@
+package model
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// union is implemented by the union types, such as AnyStep, which hold exactly one of their alternatives and marshal
+// as it
+type union interface {
+	alternatives() []alternative
+}
+
+// alternative is one of the alternatives of a union, and whether it's set
+type alternative struct {
+	name string
+	set  bool
+}
+
+// isEmpty returns true if none of a union's alternatives are set
+func isEmpty(u union) bool {
+	for _, a := range u.alternatives() {
+		if a.set {
+			return false
+		}
+	}
+	return true
+}
+
+// unionViolation describes a union without exactly one of its alternatives set, or returns "" if it has one
+func unionViolation(typ string, u union) string {
+	var all, set []string
+	for _, a := range u.alternatives() {
+		all = append(all, a.name)
+		if a.set {
+			set = append(set, a.name)
+		}
+	}
+	switch len(set) {
+	case 0:
+		return typ + " has none of " + joinAlternatives(all, "or") + " set"
+	case 1:
+		return ""
+	}
+	return typ + " has " + joinAlternatives(set, "and") + " set, but only one may be"
+}
+
+func joinAlternatives(names []string, conjunction string) string {
+	if len(names) == 2 {
+		return names[0] + " " + conjunction + " " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", " + conjunction + " " + names[len(names)-1]
+}
+
+// eachUnion calls fn with each union in v and its JSON pointer, outermost first
+func eachUnion(v reflect.Value, path string, fn func(path string, typ string, u union)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if u, ok := v.Interface().(union); ok {
+			fn(path, v.Elem().Type().Name(), u)
+		}
+		eachUnion(v.Elem(), path, fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if at, ok := fieldPath(path, v.Type().Field(i)); ok {
+				eachUnion(v.Field(i), at, fn)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			eachUnion(v.Index(i), path+"/"+strconv.Itoa(i), fn)
+		}
+	}
+}
+
+// fieldPath returns the JSON pointer of a struct field, given that of the struct, and false if the field isn't
+// marshaled
+func fieldPath(path string, field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if field.PkgPath != "" || name == "-" {
+		return "", false
+	}
+	// The alternatives of unions have no names of their own, as the union is written as one of them.
+	if name == "" {
+		return path, true
+	}
+	return path + "/" + escape(name), true
+}
+
+// NewAnyStep returns an AnyStep holding a step
+func NewAnyStep(step *Step) *AnyStep {
+	return &AnyStep{Step: step}
+}
+
+// NewAnyTree returns an AnyStep holding a tree step
+func NewAnyTree(tree *TreeStep) *AnyStep {
+	return &AnyStep{Tree: tree}
+}
+
+// SetStep makes the AnyStep hold a step, clearing any tree step
+func (strct *AnyStep) SetStep(step *Step) {
+	strct.Step, strct.Tree = step, nil
+}
+
+// SetTree makes the AnyStep hold a tree step, clearing any step
+func (strct *AnyStep) SetTree(tree *TreeStep) {
+	strct.Step, strct.Tree = nil, tree
+}
+
+func (strct *AnyStep) alternatives() []alternative {
+	return []alternative{{"Step", strct.Step != nil}, {"Tree", strct.Tree != nil}}
+}
+
+// NewSingleArgument returns an argument list holding a single unnamed argument
+func NewSingleArgument(arg *RawArgument) *ArgumentList {
+	return &ArgumentList{Single: arg}
+}
+
+// NewNamedArguments returns an argument list holding named arguments, which may be none
+func NewNamedArguments(args ...*ArgumentValue) *ArgumentList {
+	list := &ArgumentList{}
+	list.SetNamed(args)
+	return list
+}
+
+// NewPositionalArguments returns an argument list holding unnamed arguments, which may be none
+func NewPositionalArguments(args ...*RawArgument) *ArgumentList {
+	list := &ArgumentList{}
+	list.SetPositional(args)
+	return list
+}
+
+// SetSingle makes the list hold a single unnamed argument, clearing any others
+func (strct *ArgumentList) SetSingle(arg *RawArgument) {
+	strct.Single, strct.Named, strct.Positional = arg, nil, nil
+}
+
+// SetNamed makes the list hold named arguments, clearing any others. A nil list sets no arguments.
+func (strct *ArgumentList) SetNamed(args []*ArgumentValue) {
+	if args == nil {
+		args = []*ArgumentValue{}
+	}
+	strct.Single, strct.Named, strct.Positional = nil, args, nil
+}
+
+// SetPositional makes the list hold unnamed arguments, clearing any others. A nil list sets no arguments.
+func (strct *ArgumentList) SetPositional(args []*RawArgument) {
+	if args == nil {
+		args = []*RawArgument{}
+	}
+	strct.Single, strct.Named, strct.Positional = nil, nil, args
+}
+
+func (strct *ArgumentList) alternatives() []alternative {
+	return []alternative{{"Named", strct.Named != nil}, {"Single", strct.Single != nil},
+		{"Positional", strct.Positional != nil}}
+}
+
+// NewEnvironmentSingle returns an environment value holding a raw value
+func NewEnvironmentSingle(arg *RawArgument) *EnvironmentValue {
+	return &EnvironmentValue{Single: arg}
+}
+
+// NewEnvironmentFunction returns an environment value holding a function call, such as credentials()
+func NewEnvironmentFunction(fn *InternalFunction) *EnvironmentValue {
+	return &EnvironmentValue{Function: fn}
+}
+
+// SetSingle makes the value hold a raw value, clearing any function call
+func (strct *EnvironmentValue) SetSingle(arg *RawArgument) {
+	strct.Single, strct.Function = arg, nil
+}
+
+// SetFunction makes the value hold a function call, clearing any raw value
+func (strct *EnvironmentValue) SetFunction(fn *InternalFunction) {
+	strct.Single, strct.Function = nil, fn
+}
+
+func (strct *EnvironmentValue) alternatives() []alternative {
+	return []alternative{{"Single", strct.Single != nil}, {"Function", strct.Function != nil}}
+}
+
+// NewMapRaw returns a map argument value holding a raw value
+func NewMapRaw(arg *RawArgument) *MapArgumentValueRawOrList {
+	return &MapArgumentValueRawOrList{Raw: arg}
+}
+
+// NewMapList returns a map argument value holding further map arguments, which may be none
+func NewMapList(list ...*MapArgumentValue) *MapArgumentValueRawOrList {
+	v := &MapArgumentValueRawOrList{}
+	v.SetList(list)
+	return v
+}
+
+// SetRaw makes the value hold a raw value, clearing any list
+func (strct *MapArgumentValueRawOrList) SetRaw(arg *RawArgument) {
+	strct.Raw, strct.List = arg, nil
+}
+
+// SetList makes the value hold further map arguments, clearing any raw value. A nil list sets no arguments.
+func (strct *MapArgumentValueRawOrList) SetList(list []*MapArgumentValue) {
+	if list == nil {
+		list = []*MapArgumentValue{}
+	}
+	strct.Raw, strct.List = nil, list
+}
+
+func (strct *MapArgumentValueRawOrList) alternatives() []alternative {
+	return []alternative{{"Raw", strct.Raw != nil}, {"List", strct.List != nil}}
+}
+
+// NewMethodArgSingle returns an unnamed method argument
+func NewMethodArgSingle(v *ValueOrMethodCall) *MethodArg {
+	return &MethodArg{Single: v}
+}
+
+// NewMethodArgWithKey returns a named method argument
+func NewMethodArgWithKey(key string, v *ValueOrMethodCall) *MethodArg {
+	return &MethodArg{WithKey: &KeyAndValueOrMethodCall{Key: key, Value: v}}
+}
+
+// SetSingle makes the argument unnamed, clearing any named value
+func (strct *MethodArg) SetSingle(v *ValueOrMethodCall) {
+	strct.Single, strct.WithKey = v, nil
+}
+
+// SetWithKey makes the argument named, clearing any unnamed value
+func (strct *MethodArg) SetWithKey(kv *KeyAndValueOrMethodCall) {
+	strct.Single, strct.WithKey = nil, kv
+}
+
+func (strct *MethodArg) alternatives() []alternative {
+	return []alternative{{"Single", strct.Single != nil}, {"WithKey", strct.WithKey != nil}}
+}
+
+// NewWhenStep returns a when condition which is a step, such as branch 'main'
+func NewWhenStep(step *Step) *StepOrNestedWhenCondition {
+	return &StepOrNestedWhenCondition{Step: step}
+}
+
+// NewWhenNested returns a when condition holding others, such as allOf
+func NewWhenNested(nested *NestedWhenCondition) *StepOrNestedWhenCondition {
+	return &StepOrNestedWhenCondition{Nested: nested}
+}
+
+// SetStep makes the condition a step, clearing any nested condition
+func (strct *StepOrNestedWhenCondition) SetStep(step *Step) {
+	strct.Step, strct.Nested = step, nil
+}
+
+// SetNested makes the condition a nested condition, clearing any step
+func (strct *StepOrNestedWhenCondition) SetNested(nested *NestedWhenCondition) {
+	strct.Step, strct.Nested = nil, nested
+}
+
+func (strct *StepOrNestedWhenCondition) alternatives() []alternative {
+	return []alternative{{"Step", strct.Step != nil}, {"Nested", strct.Nested != nil}}
+}
+
+// NewValueSingle returns a value which is a raw value
+func NewValueSingle(arg *RawArgument) *ValueOrMethodCall {
+	return &ValueOrMethodCall{Single: arg}
+}
+
+// NewValueCall returns a value which is a method call, such as logRotator(...)
+func NewValueCall(call *MethodCall) *ValueOrMethodCall {
+	return &ValueOrMethodCall{Call: call}
+}
+
+// SetSingle makes the value a raw value, clearing any method call
+func (strct *ValueOrMethodCall) SetSingle(arg *RawArgument) {
+	strct.Single, strct.Call = arg, nil
+}
+
+// SetCall makes the value a method call, clearing any raw value
+func (strct *ValueOrMethodCall) SetCall(call *MethodCall) {
+	strct.Single, strct.Call = nil, call
+}
+
+func (strct *ValueOrMethodCall) alternatives() []alternative {
+	return []alternative{{"Single", strct.Single != nil}, {"Call", strct.Call != nil}}
+}
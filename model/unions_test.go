@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnionSetters(t *testing.T) {
+	literal := &RawArgument{IsLiteral: true}
+	s := NewAnyStep(&Step{Name: "sh"})
+	s.SetTree(&TreeStep{Name: "dir"})
+	assert.Nil(t, s.Step)
+	assert.Equal(t, "dir", s.Tree.Name)
+	s.SetStep(&Step{Name: "echo"})
+	assert.Nil(t, s.Tree)
+
+	args := NewSingleArgument(&RawArgument{IsLiteral: true})
+	args.SetNamed(nil)
+	assert.Nil(t, args.Single)
+	assert.Equal(t, []*ArgumentValue{}, args.Named, "no arguments is still named arguments")
+	args.SetPositional([]*RawArgument{{IsLiteral: true}})
+	assert.Nil(t, args.Named)
+	assert.Len(t, args.Positional, 1)
+	assert.Equal(t, []*RawArgument{}, NewPositionalArguments().Positional)
+
+	env := NewEnvironmentFunction(&InternalFunction{Name: CredentialsFunction})
+	env.SetSingle(&RawArgument{IsLiteral: true})
+	assert.Nil(t, env.Function)
+
+	arg := NewMethodArgWithKey("time", NewValueSingle(&RawArgument{IsLiteral: true}))
+	arg.SetSingle(NewValueCall(&MethodCall{Name: "logRotator"}))
+	assert.Nil(t, arg.WithKey)
+	assert.Equal(t, "logRotator", arg.Single.Call.Name)
+
+	when := NewWhenStep(&Step{Name: "branch"})
+	when.SetNested(&NestedWhenCondition{Name: "not"})
+	assert.Nil(t, when.Step)
+
+	m := NewMapRaw(&RawArgument{IsLiteral: true})
+	m.SetList(nil)
+	assert.Nil(t, m.Raw)
+	assert.Equal(t, []*MapArgumentValue{}, m.List)
+
+	for _, u := range []union{s, args, env, arg, arg.Single, when, m, NewNamedArguments(), NewMapList(),
+		NewMethodArgSingle(NewValueSingle(literal)), NewEnvironmentSingle(&RawArgument{})} {
+		assert.Equal(t, "", unionViolation("union", u), "%#v", u)
+	}
+}
+
+func TestCheckInvariantsUnions(t *testing.T) {
+	literal := &RawArgument{IsLiteral: true}
+	root := &Root{Pipeline: &Pipeline{
+		Environment: []*EnvironmentEntry{{Key: "A", Value: &EnvironmentValue{}}},
+		Stages: []*Stage{{Name: "Build", Branches: []*Branch{{Name: "default", Steps: []*AnyStep{
+			{},
+			{Step: &Step{Name: "echo"}, Tree: &TreeStep{Name: "dir"}},
+			NewAnyStep(&Step{Name: "retry", Arguments: &ArgumentList{Named: []*ArgumentValue{}, Single: literal,
+				Positional: []*RawArgument{}}}),
+		}}}}},
+	}}
+
+	err := CheckInvariants(root)
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"/pipeline/environment/0/value EnvironmentValue has none of Single or Function set",
+		"/pipeline/stages/0/branches/0/steps/0 AnyStep has none of Step or Tree set",
+		"/pipeline/stages/0/branches/0/steps/1 AnyStep has Step and Tree set, but only one may be",
+		"/pipeline/stages/0/branches/0/steps/2/arguments ArgumentList has Named, Single, and Positional set, " +
+			"but only one may be",
+	}, err.(*InvariantError).Violations)
+}
@@ -0,0 +1,448 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+)
+
+// SkipChildren is returned by a Visitor callback to skip the nodes within the node it was called with. Walk carries
+// on with the node's siblings.
+var SkipChildren = errors.New("skip children")
+
+// Visitor has the callbacks Walk calls for each kind of node, with the node's path, its JSON pointer within the root
+// such as "/pipeline/stages/0/branches/0/steps/2". Nil callbacks are skipped. A callback may return SkipChildren to
+// skip the nodes within its node, or any other error to stop the walk.
+type Visitor struct {
+	Pipeline func(path string, p *Pipeline) error
+	// Agent is called for the agents of the pipeline, stages, and matrices.
+	Agent func(path string, a *Agent) error
+	// Environment is called for the environment entries of the pipeline, stages, and matrices.
+	Environment func(path string, e *EnvironmentEntry) error
+	Stage       func(path string, s *Stage) error
+	Matrix      func(path string, m *Matrix) error
+	Input       func(path string, i *Input) error
+	// WhenCondition is called for each condition of a when section, including those nested in allOf, anyOf, and
+	// not. The steps of when conditions aren't passed to Step.
+	WhenCondition func(path string, c *StepOrNestedWhenCondition) error
+	// Branch is called for the branches of stages and post conditions.
+	Branch        func(path string, b *Branch) error
+	Step          func(path string, s *Step) error
+	TreeStep      func(path string, t *TreeStep) error
+	PostCondition func(path string, c *BuildCondition) error
+	// MethodCall is called for options, parameters, and triggers, and method calls within their arguments.
+	MethodCall func(path string, c *MethodCall) error
+	// RawArgument is called for every raw argument: those of steps, agents, when conditions, method calls, and
+	// inputs, environment values, tools, libraries, and the values of matrix axes and excludes.
+	RawArgument func(path string, a *RawArgument) error
+}
+
+// Walk visits every node of the pipeline depth first, calling the visitor's callback for each, before the nodes
+// within it. Nodes are visited in the order they appear in the model, and a node's sections in a fixed order. It
+// returns the first error returned by a callback, other than SkipChildren.
+func Walk(root *Root, v Visitor) error {
+	if root == nil || root.Pipeline == nil {
+		return nil
+	}
+	w := &walker{v: v}
+	return w.pipeline("/pipeline", root.Pipeline)
+}
+
+type walker struct {
+	v Visitor
+}
+
+// descend returns whether to visit the nodes within a node, given what its callback returned, and the error stopping
+// the walk, if any
+func descend(err error) (bool, error) {
+	if err == SkipChildren {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// each calls fn for each index of a list of n nodes, until one returns an error
+func each(path string, n int, fn func(path string, i int) error) error {
+	for i := 0; i < n; i++ {
+		if err := fn(path+"/"+strconv.Itoa(i), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// all calls each of fns in turn, until one returns an error
+func all(fns ...func() error) error {
+	for _, fn := range fns {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) pipeline(path string, p *Pipeline) error {
+	if w.v.Pipeline != nil {
+		if ok, err := descend(w.v.Pipeline(path, p)); !ok {
+			return err
+		}
+	}
+	return all(
+		func() error { return w.agent(path+"/agent", p.Agent) },
+		func() error { return w.environment(path+"/environment", p.Environment) },
+		func() error {
+			if p.Libraries == nil {
+				return nil
+			}
+			return w.raws(path+"/libraries/libraries", p.Libraries.Libraries)
+		},
+		func() error {
+			if p.Options == nil {
+				return nil
+			}
+			return w.methodCalls(path+"/options/options", p.Options.Options)
+		},
+		func() error {
+			if p.Parameters == nil {
+				return nil
+			}
+			return w.methodCalls(path+"/parameters/parameters", p.Parameters.Parameters)
+		},
+		func() error {
+			if p.Triggers == nil {
+				return nil
+			}
+			return w.methodCalls(path+"/triggers/triggers", p.Triggers.Triggers)
+		},
+		func() error { return w.argumentValues(path+"/tools", p.Tools) },
+		func() error { return w.stages(path+"/stages", p.Stages) },
+		func() error { return w.post(path+"/post", p.Post) },
+	)
+}
+
+func (w *walker) stages(path string, stages []*Stage) error {
+	return each(path, len(stages), func(at string, i int) error {
+		return w.stage(at, stages[i])
+	})
+}
+
+func (w *walker) stage(path string, s *Stage) error {
+	if s == nil {
+		return nil
+	}
+	if w.v.Stage != nil {
+		if ok, err := descend(w.v.Stage(path, s)); !ok {
+			return err
+		}
+	}
+	return all(
+		func() error { return w.agent(path+"/agent", s.Agent) },
+		func() error { return w.environment(path+"/environment", s.Environment) },
+		func() error {
+			if s.Options == nil {
+				return nil
+			}
+			return w.methodCalls(path+"/options/options", s.Options.Options)
+		},
+		func() error { return w.argumentValues(path+"/tools", s.Tools) },
+		func() error { return w.input(path+"/input", s.Input) },
+		func() error { return w.when(path+"/when", s.When) },
+		func() error { return w.branches(path+"/branches", s.Branches) },
+		func() error { return w.stages(path+"/stages", s.Stages) },
+		func() error { return w.stages(path+"/parallel", s.Parallel) },
+		func() error { return w.matrix(path+"/matrix", s.Matrix) },
+		func() error { return w.post(path+"/post", s.Post) },
+	)
+}
+
+func (w *walker) matrix(path string, m *Matrix) error {
+	if m == nil {
+		return nil
+	}
+	if w.v.Matrix != nil {
+		if ok, err := descend(w.v.Matrix(path, m)); !ok {
+			return err
+		}
+	}
+	return all(
+		func() error {
+			return each(path+"/axes", len(m.Axes), func(at string, i int) error {
+				if m.Axes[i] == nil {
+					return nil
+				}
+				return w.raws(at+"/values", m.Axes[i].Values)
+			})
+		},
+		func() error {
+			return each(path+"/excludes", len(m.Excludes), func(at string, i int) error {
+				return each(at, len(m.Excludes[i]), func(at string, j int) error {
+					if m.Excludes[i][j] == nil {
+						return nil
+					}
+					return w.raws(at+"/values", m.Excludes[i][j].Values)
+				})
+			})
+		},
+		func() error { return w.agent(path+"/agent", m.Agent) },
+		func() error { return w.environment(path+"/environment", m.Environment) },
+		func() error {
+			if m.Options == nil {
+				return nil
+			}
+			return w.methodCalls(path+"/options/options", m.Options.Options)
+		},
+		func() error { return w.argumentValues(path+"/tools", m.Tools) },
+		func() error { return w.input(path+"/input", m.Input) },
+		func() error { return w.when(path+"/when", m.When) },
+		func() error { return w.stages(path+"/stages", m.Stages) },
+		func() error { return w.post(path+"/post", m.Post) },
+	)
+}
+
+func (w *walker) agent(path string, a *Agent) error {
+	if a == nil {
+		return nil
+	}
+	if w.v.Agent != nil {
+		if ok, err := descend(w.v.Agent(path, a)); !ok {
+			return err
+		}
+	}
+	if err := w.raw(path+"/argument", a.Argument); err != nil {
+		return err
+	}
+	return w.mapArguments(path+"/arguments", a.Arguments)
+}
+
+func (w *walker) environment(path string, entries []*EnvironmentEntry) error {
+	return each(path, len(entries), func(at string, i int) error {
+		e := entries[i]
+		if e == nil {
+			return nil
+		}
+		if w.v.Environment != nil {
+			if ok, err := descend(w.v.Environment(at, e)); !ok {
+				return err
+			}
+		}
+		return w.environmentValue(at+"/value", e.Value)
+	})
+}
+
+func (w *walker) environmentValue(path string, v *EnvironmentValue) error {
+	switch {
+	case v == nil:
+		return nil
+	case v.Function != nil:
+		return w.function(path, v.Function)
+	}
+	return w.raw(path, v.Single)
+}
+
+func (w *walker) function(path string, f *InternalFunction) error {
+	return each(path+"/arguments", len(f.Arguments), func(at string, i int) error {
+		if call := f.Call(i); call != nil {
+			return w.function(at, call)
+		}
+		return w.raw(at, f.Arguments[i])
+	})
+}
+
+func (w *walker) input(path string, in *Input) error {
+	if in == nil {
+		return nil
+	}
+	if w.v.Input != nil {
+		if ok, err := descend(w.v.Input(path, in)); !ok {
+			return err
+		}
+	}
+	for _, f := range []struct {
+		name string
+		arg  *RawArgument
+	}{{"id", in.ID}, {"message", in.Message}, {"ok", in.Ok}, {"submitter", in.Submitter},
+		{"submitterParameter", in.SubmitterParameter}} {
+		if err := w.raw(path+"/"+f.name, f.arg); err != nil {
+			return err
+		}
+	}
+	if in.Parameters == nil {
+		return nil
+	}
+	return w.methodCalls(path+"/parameters/parameters", in.Parameters.Parameters)
+}
+
+func (w *walker) when(path string, when *When) error {
+	if when == nil {
+		return nil
+	}
+	return w.whenConditions(path+"/conditions", when.Conditions)
+}
+
+func (w *walker) whenConditions(path string, conditions []*StepOrNestedWhenCondition) error {
+	return each(path, len(conditions), func(at string, i int) error {
+		c := conditions[i]
+		if c == nil {
+			return nil
+		}
+		if w.v.WhenCondition != nil {
+			if ok, err := descend(w.v.WhenCondition(at, c)); !ok {
+				return err
+			}
+		}
+		switch {
+		case c.Nested != nil:
+			return w.whenConditions(at+"/children", c.Nested.Children)
+		case c.Step != nil:
+			return w.arguments(at+"/arguments", c.Step.Arguments)
+		}
+		return nil
+	})
+}
+
+func (w *walker) branches(path string, branches []*Branch) error {
+	return each(path, len(branches), func(at string, i int) error {
+		return w.branch(at, branches[i])
+	})
+}
+
+func (w *walker) branch(path string, b *Branch) error {
+	if b == nil {
+		return nil
+	}
+	if w.v.Branch != nil {
+		if ok, err := descend(w.v.Branch(path, b)); !ok {
+			return err
+		}
+	}
+	return w.steps(path+"/steps", b.Steps)
+}
+
+func (w *walker) steps(path string, steps []*AnyStep) error {
+	return each(path, len(steps), func(at string, i int) error {
+		s := steps[i]
+		switch {
+		case s == nil:
+		case s.Step != nil:
+			if w.v.Step != nil {
+				if ok, err := descend(w.v.Step(at, s.Step)); !ok {
+					return err
+				}
+			}
+			return w.arguments(at+"/arguments", s.Step.Arguments)
+		case s.Tree != nil:
+			if w.v.TreeStep != nil {
+				if ok, err := descend(w.v.TreeStep(at, s.Tree)); !ok {
+					return err
+				}
+			}
+			if err := w.arguments(at+"/arguments", s.Tree.Arguments); err != nil {
+				return err
+			}
+			return w.steps(at+"/children", s.Tree.Children)
+		}
+		return nil
+	})
+}
+
+func (w *walker) post(path string, post *Post) error {
+	if post == nil {
+		return nil
+	}
+	return each(path+"/conditions", len(post.Conditions), func(at string, i int) error {
+		c := post.Conditions[i]
+		if c == nil {
+			return nil
+		}
+		if w.v.PostCondition != nil {
+			if ok, err := descend(w.v.PostCondition(at, c)); !ok {
+				return err
+			}
+		}
+		return w.branch(at+"/branch", c.Branch)
+	})
+}
+
+func (w *walker) methodCalls(path string, calls []*MethodCall) error {
+	return each(path, len(calls), func(at string, i int) error {
+		return w.methodCall(at, calls[i])
+	})
+}
+
+func (w *walker) methodCall(path string, c *MethodCall) error {
+	if c == nil {
+		return nil
+	}
+	if w.v.MethodCall != nil {
+		if ok, err := descend(w.v.MethodCall(path, c)); !ok {
+			return err
+		}
+	}
+	return each(path+"/arguments", len(c.Arguments), func(at string, i int) error {
+		a := c.Arguments[i]
+		switch {
+		case a == nil:
+			return nil
+		case a.WithKey != nil:
+			return w.valueOrMethodCall(at+"/value", a.WithKey.Value)
+		}
+		return w.valueOrMethodCall(at, a.Single)
+	})
+}
+
+func (w *walker) valueOrMethodCall(path string, v *ValueOrMethodCall) error {
+	switch {
+	case v == nil:
+		return nil
+	case v.Call != nil:
+		return w.methodCall(path, v.Call)
+	}
+	return w.raw(path, v.Single)
+}
+
+func (w *walker) arguments(path string, args *ArgumentList) error {
+	switch {
+	case args == nil:
+		return nil
+	case args.Single != nil:
+		return w.raw(path, args.Single)
+	case args.Named != nil:
+		return w.argumentValues(path, args.Named)
+	}
+	return w.raws(path, args.Positional)
+}
+
+func (w *walker) argumentValues(path string, values []*ArgumentValue) error {
+	return each(path, len(values), func(at string, i int) error {
+		if values[i] == nil {
+			return nil
+		}
+		return w.raw(at+"/value", values[i].Value)
+	})
+}
+
+func (w *walker) mapArguments(path string, args []*MapArgumentValue) error {
+	return each(path, len(args), func(at string, i int) error {
+		a := args[i]
+		switch {
+		case a == nil || a.Value == nil:
+			return nil
+		case a.Value.Raw != nil:
+			return w.raw(at+"/value", a.Value.Raw)
+		}
+		return w.mapArguments(at+"/value", a.Value.List)
+	})
+}
+
+func (w *walker) raws(path string, args []*RawArgument) error {
+	return each(path, len(args), func(at string, i int) error {
+		return w.raw(at, args[i])
+	})
+}
+
+func (w *walker) raw(path string, a *RawArgument) error {
+	if a == nil || w.v.RawArgument == nil {
+		return nil
+	}
+	_, err := descend(w.v.RawArgument(path, a))
+	return err
+}
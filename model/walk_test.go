@@ -0,0 +1,168 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const walkPipeline = `{"pipeline": {
+  "agent": {"type": "label", "argument": {"isLiteral": true, "value": "linux"}},
+  "environment": [{"key": "TOKEN", "value": {"name": "credentials", "arguments": [
+    {"isLiteral": true, "value": "token"}]}}],
+  "options": {"options": [{"name": "timeout", "arguments": [
+    {"key": "time", "value": {"isLiteral": true, "value": 10}}]}]},
+  "stages": [{
+    "name": "Build",
+    "when": {"conditions": [{"name": "not", "children": [
+      {"name": "branch", "arguments": {"isLiteral": true, "value": "main"}}]}]},
+    "branches": [{"name": "default", "steps": [
+      {"name": "dir", "arguments": {"isLiteral": true, "value": "src"}, "children": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]}]}]}],
+    "post": {"conditions": [{"condition": "always", "branch": {"name": "default", "steps": [
+      {"name": "junit", "arguments": {"isLiteral": true, "value": "*.xml"}}]}}]}
+  }]
+}}`
+
+// recordAll returns a visitor recording the kind and path of every node visited
+func recordAll(visited *[]string) Visitor {
+	record := func(kind string) func(string) error {
+		return func(path string) error {
+			*visited = append(*visited, kind+" "+path)
+			return nil
+		}
+	}
+	pipeline, agent, env, stage, matrix := record("pipeline"), record("agent"), record("environment"),
+		record("stage"), record("matrix")
+	input, when, branch, step, tree := record("input"), record("when"), record("branch"), record("step"),
+		record("tree")
+	post, call, raw := record("post"), record("call"), record("raw")
+	return Visitor{
+		Pipeline:      func(path string, _ *Pipeline) error { return pipeline(path) },
+		Agent:         func(path string, _ *Agent) error { return agent(path) },
+		Environment:   func(path string, _ *EnvironmentEntry) error { return env(path) },
+		Stage:         func(path string, _ *Stage) error { return stage(path) },
+		Matrix:        func(path string, _ *Matrix) error { return matrix(path) },
+		Input:         func(path string, _ *Input) error { return input(path) },
+		WhenCondition: func(path string, _ *StepOrNestedWhenCondition) error { return when(path) },
+		Branch:        func(path string, _ *Branch) error { return branch(path) },
+		Step:          func(path string, _ *Step) error { return step(path) },
+		TreeStep:      func(path string, _ *TreeStep) error { return tree(path) },
+		PostCondition: func(path string, _ *BuildCondition) error { return post(path) },
+		MethodCall:    func(path string, _ *MethodCall) error { return call(path) },
+		RawArgument:   func(path string, _ *RawArgument) error { return raw(path) },
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root, err := Decode([]byte(walkPipeline))
+	require.NoError(t, err)
+	var visited []string
+	require.NoError(t, Walk(root, recordAll(&visited)))
+	assert.Equal(t, []string{
+		"pipeline /pipeline",
+		"agent /pipeline/agent",
+		"raw /pipeline/agent/argument",
+		"environment /pipeline/environment/0",
+		"raw /pipeline/environment/0/value/arguments/0",
+		"call /pipeline/options/options/0",
+		"raw /pipeline/options/options/0/arguments/0/value",
+		"stage /pipeline/stages/0",
+		"when /pipeline/stages/0/when/conditions/0",
+		"when /pipeline/stages/0/when/conditions/0/children/0",
+		"raw /pipeline/stages/0/when/conditions/0/children/0/arguments",
+		"branch /pipeline/stages/0/branches/0",
+		"tree /pipeline/stages/0/branches/0/steps/0",
+		"raw /pipeline/stages/0/branches/0/steps/0/arguments",
+		"step /pipeline/stages/0/branches/0/steps/0/children/0",
+		"raw /pipeline/stages/0/branches/0/steps/0/children/0/arguments/0/value",
+		"post /pipeline/stages/0/post/conditions/0",
+		"branch /pipeline/stages/0/post/conditions/0/branch",
+		"step /pipeline/stages/0/post/conditions/0/branch/steps/0",
+		"raw /pipeline/stages/0/post/conditions/0/branch/steps/0/arguments",
+	}, visited)
+}
+
+func TestWalkSkipChildrenAndErrors(t *testing.T) {
+	root, err := Decode([]byte(walkPipeline))
+	require.NoError(t, err)
+
+	var steps []string
+	err = Walk(root, Visitor{
+		TreeStep: func(path string, _ *TreeStep) error { return SkipChildren },
+		Step: func(path string, s *Step) error {
+			steps = append(steps, s.Name)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"junit"}, steps)
+
+	stop := errors.New("stop")
+	var stages int
+	err = Walk(root, Visitor{
+		Stage: func(string, *Stage) error {
+			stages++
+			return nil
+		},
+		Agent: func(string, *Agent) error { return stop },
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 0, stages)
+}
+
+// TestWalkPaths checks that the path of every node visited in the test pipelines points at it in their JSON
+func TestWalkPaths(t *testing.T) {
+	err := filepath.Walk(filepath.Join("testdata", "json"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		root, err := Decode(b)
+		if err != nil {
+			return nil
+		}
+		var doc interface{}
+		require.NoError(t, json.Unmarshal(b, &doc))
+		var visited []string
+		require.NoError(t, Walk(root, recordAll(&visited)))
+		for _, v := range visited {
+			pointed := pointer(doc, strings.SplitN(v, " ", 2)[1])
+			assert.NotNil(t, pointed, "%s: %s", path, v)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// pointer returns the value at a JSON pointer within a decoded JSON document, or nil if there's none
+func pointer(doc interface{}, path string) interface{} {
+	if path == "" {
+		return doc
+	}
+	for _, segment := range strings.Split(path[1:], "/") {
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		switch t := doc.(type) {
+		case map[string]interface{}:
+			doc = t[segment]
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			doc = t[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// ExtractAll finds every pipeline in src, as model.ExtractAll does, and also parses the pipeline blocks found in
+// Groovy sources, so each one has its Root. Errors parsing a pipeline block give its position in src.
+func ExtractAll(src []byte) ([]*model.Extracted, error) {
+	docs, err := model.ExtractAll(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		if d.Format != model.FormatGroovy {
+			continue
+		}
+		if d.Root, err = Parse(bytes.NewReader(d.Source)); err != nil {
+			if pe, ok := err.(*Error); ok {
+				// The block's first line starts at its column in src, and the rest at the start of their lines.
+				if pe.Line == 1 {
+					pe.Column += d.Column - 1
+				}
+				pe.Line += d.Line - 1
+				return nil, pe
+			}
+			return nil, fmt.Errorf("pipeline at line %d, column %d: %s", d.Line, d.Column, err)
+		}
+	}
+	return docs, nil
+}
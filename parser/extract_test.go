@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractAll(t *testing.T) {
+	src := `// vars/standardBuild.groovy
+def call(Map config) {
+    pipeline {
+        agent any
+        stages {
+            stage('Build') {
+                steps {
+                    sh "make ${config.target}"
+                }
+            }
+        }
+    }
+}
+`
+	docs, err := ExtractAll([]byte(src))
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, model.FormatGroovy, docs[0].Format)
+	require.NotNil(t, docs[0].Root)
+	assert.Equal(t, "Build", docs[0].Root.Pipeline.Stages[0].Name)
+
+	docs, err = ExtractAll([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": []}}`))
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "any", docs[0].Root.Pipeline.Agent.Type)
+}
+
+func TestExtractAllErrors(t *testing.T) {
+	_, err := ExtractAll([]byte("def call() {\n  pipeline {\n    agent some\n  }\n}\n"))
+	assert.EqualError(t, err, "line 3, column 5: agent must be any, none, or a block")
+
+	_, err = ExtractAll([]byte("def call() {\n  pipeline { agent some }\n}\n"))
+	assert.EqualError(t, err, "line 2, column 14: agent must be any, none, or a block")
+}
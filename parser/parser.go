@@ -0,0 +1,829 @@
+// Package parser parses Declarative Jenkinsfiles into pipeline models, without a Jenkins to convert them to the Kyoto
+// AST JSON. Steps are parsed like Jenkins does, with anything which can't be modelled, such as method chains, kept as
+// raw Groovy steps (see model.RawStep) so it isn't lost.
+package parser
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/internal/groovymodel"
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Error is an error in a Jenkinsfile, at a position in it
+type Error struct {
+	// Line and Column are 1-based, with Column counted in bytes.
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func errorAt(pos groovy.Pos, format string, args ...interface{}) *Error {
+	return &Error{Line: pos.Line, Column: pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Sections allowed in each block
+var (
+	pipelineSections = []string{"agent", "environment", "libraries", "options", "parameters", "post", "stages", "tools",
+		"triggers"}
+	stageSections = []string{"agent", "environment", "failFast", "input", "matrix", "options", "parallel", "post",
+		"stages", "steps", "tools", "when"}
+	matrixSections = []string{"agent", "axes", "environment", "excludes", "input", "options", "post", "stages", "tools",
+		"when"}
+	inputSections = []string{"id", "message", "ok", "parameters", "submitter", "submitterParameter"}
+)
+
+// postConditions are the conditions allowed in post blocks
+var postConditions = map[string]bool{"always": true, "changed": true, "fixed": true, "regression": true,
+	"aborted": true, "success": true, "unsuccessful": true, "unstable": true, "failure": true, "notBuilt": true,
+	"cleanup": true}
+
+// nestedConditions are the when conditions which hold other conditions
+var nestedConditions = map[string]bool{"allOf": true, "anyOf": true, "not": true}
+
+// Parse parses a Declarative Jenkinsfile. Statements outside the pipeline block, such as imports and @Library
+// annotations, are ignored. It returns an *Error for Jenkinsfiles which aren't valid Declarative pipelines, and a
+// *model.InvariantError for pipelines which break the model's invariants, such as having two stages of the same name.
+// Post conditions are sorted into the order Jenkins runs them, as by model.Post.SortConditions. The pipeline and each
+// of its stages and steps get a UID, as from model.AssignUIDs.
+func Parse(r io.Reader) (*model.Root, error) {
+	defer metrics.Since(metrics.ParseDuration, metrics.Labels{"format": model.FormatGroovy}, time.Now())
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	script, err := groovy.Parse(string(b))
+	if err != nil {
+		if ge, ok := err.(*groovy.Error); ok {
+			return nil, errorAt(ge.Pos, "%s", ge.Msg)
+		}
+		return nil, err
+	}
+	var block *groovy.Call
+	for _, s := range script.Statements {
+		call, ok := s.(*groovy.Call)
+		if !ok || call.Name != "pipeline" {
+			continue
+		}
+		if block != nil {
+			return nil, errorAt(call.Pos, "only one pipeline block is allowed")
+		}
+		if call.Closure == nil || len(call.Args) > 0 {
+			return nil, errorAt(call.Pos, "pipeline must be a block")
+		}
+		block = call
+	}
+	if block == nil {
+		return nil, &Error{Line: 1, Column: 1, Msg: "no pipeline block found"}
+	}
+	p, err := pipeline(block.Closure)
+	if err != nil {
+		return nil, err
+	}
+	root := &model.Root{Pipeline: p}
+	if err := model.CheckInvariants(root); err != nil {
+		return nil, err
+	}
+	model.AssignUIDs(root)
+	return root, nil
+}
+
+func pipeline(b *groovy.Block) (*model.Pipeline, error) {
+	secs, err := sections(b, "pipeline", pipelineSections)
+	if err != nil {
+		return nil, err
+	}
+	p := &model.Pipeline{}
+	if c := secs["agent"]; c != nil {
+		if p.Agent, err = agent(c); err != nil {
+			return nil, err
+		}
+	}
+	if c := secs["environment"]; c != nil {
+		if p.Environment, err = environment(c); err != nil {
+			return nil, err
+		}
+	}
+	if c := secs["libraries"]; c != nil {
+		if p.Libraries, err = libraries(c); err != nil {
+			return nil, err
+		}
+	}
+	if c := secs["options"]; c != nil {
+		calls, err := methodCalls(c)
+		if err != nil {
+			return nil, err
+		}
+		p.Options = &model.Options{Options: calls}
+	}
+	if c := secs["parameters"]; c != nil {
+		calls, err := methodCalls(c)
+		if err != nil {
+			return nil, err
+		}
+		p.Parameters = &model.Parameters{Parameters: calls}
+	}
+	if c := secs["triggers"]; c != nil {
+		calls, err := methodCalls(c)
+		if err != nil {
+			return nil, err
+		}
+		p.Triggers = &model.Triggers{Triggers: calls}
+	}
+	if c := secs["tools"]; c != nil {
+		if p.Tools, err = tools(c); err != nil {
+			return nil, err
+		}
+	}
+	if c := secs["post"]; c != nil {
+		if p.Post, err = post(c); err != nil {
+			return nil, err
+		}
+	}
+	c := secs["stages"]
+	if c == nil {
+		return nil, errorAt(b.Pos, "pipeline must have a stages section")
+	}
+	if p.Stages, err = stages(c); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// sections returns the sections of a block, which must all be calls of the allowed names, at most once each
+func sections(b *groovy.Block, where string, allowed []string) (map[string]*groovy.Call, error) {
+	out := map[string]*groovy.Call{}
+	for _, s := range b.Statements {
+		call, ok := s.(*groovy.Call)
+		if !ok {
+			return nil, errorAt(s.Position(), "unexpected statement in %s: %s", where, firstLine(s.Source()))
+		}
+		if !contains(allowed, call.Name) {
+			return nil, errorAt(call.Pos, "unknown section %q in %s", call.Name, where)
+		}
+		if out[call.Name] != nil {
+			return nil, errorAt(call.Pos, "multiple %s sections in %s", call.Name, where)
+		}
+		out[call.Name] = call
+	}
+	return out, nil
+}
+
+// block returns the closure of a section, which mustn't have arguments
+func block(c *groovy.Call) (*groovy.Block, error) {
+	if c.Closure == nil || len(c.Args) > 0 {
+		return nil, errorAt(c.Pos, "%s must be a block", c.Name)
+	}
+	return c.Closure, nil
+}
+
+// calls returns the statements of a section's block, which must all be calls
+func calls(c *groovy.Call) ([]*groovy.Call, error) {
+	b, err := block(c)
+	if err != nil {
+		return nil, err
+	}
+	var out []*groovy.Call
+	for _, s := range b.Statements {
+		call, ok := s.(*groovy.Call)
+		if !ok {
+			return nil, errorAt(s.Position(), "unexpected statement in %s: %s", c.Name, firstLine(s.Source()))
+		}
+		out = append(out, call)
+	}
+	return out, nil
+}
+
+// value returns the single argument of a call, such as the 'x' of message 'x'
+func value(c *groovy.Call) (*groovy.Expr, error) {
+	if len(c.Args) != 1 || c.Args[0].Key != "" || c.Closure != nil {
+		return nil, errorAt(c.Pos, "%s must have a single value", c.Name)
+	}
+	return c.Args[0].Value, nil
+}
+
+func stringValue(c *groovy.Call) (string, error) {
+	v, err := value(c)
+	if err != nil {
+		return "", err
+	}
+	if v.Kind != groovy.ExprString || v.Interpolated {
+		return "", errorAt(v.Pos, "%s must be a constant string", c.Name)
+	}
+	return v.Value, nil
+}
+
+func boolValue(c *groovy.Call) (bool, error) {
+	v, err := value(c)
+	if err != nil {
+		return false, err
+	}
+	if v.Kind != groovy.ExprBool {
+		return false, errorAt(v.Pos, "%s must be true or false", c.Name)
+	}
+	return v.Value == "true", nil
+}
+
+func agent(c *groovy.Call) (*model.Agent, error) {
+	if c.Closure == nil {
+		v, err := value(c)
+		if err != nil || v.Kind != groovy.ExprIdent || (v.Value != "any" && v.Value != "none") {
+			return nil, errorAt(c.Pos, "agent must be any, none, or a block")
+		}
+		return &model.Agent{Type: v.Value}, nil
+	}
+	types, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) != 1 {
+		return nil, errorAt(c.Pos, "agent block must have exactly one agent type")
+	}
+	t := types[0]
+	a := &model.Agent{Type: t.Name}
+	switch {
+	case t.Closure != nil:
+		if len(t.Args) > 0 {
+			return nil, errorAt(t.Pos, "agent %s can't have both arguments and a block", t.Name)
+		}
+		a.Arguments, err = mapArguments(t.Closure)
+	case len(t.Args) == 1 && t.Args[0].Key == "":
+		a.Argument = groovymodel.Argument(t.Args[0].Value)
+	default:
+		for _, arg := range t.Args {
+			if arg.Key == "" {
+				return nil, errorAt(arg.Value.Pos, "agent %s arguments must all be named", t.Name)
+			}
+			a.Arguments = append(a.Arguments, &model.MapArgumentValue{Key: arg.Key,
+				Value: &model.MapArgumentValueRawOrList{Raw: groovymodel.Argument(arg.Value)}})
+		}
+	}
+	return a, err
+}
+
+// mapArguments converts a block such as { label 'x'; customWorkspace 'y' }, where nested blocks are nested maps
+func mapArguments(b *groovy.Block) ([]*model.MapArgumentValue, error) {
+	out := []*model.MapArgumentValue{}
+	for _, s := range b.Statements {
+		call, ok := s.(*groovy.Call)
+		if !ok {
+			return nil, errorAt(s.Position(), "unexpected statement in agent: %s", firstLine(s.Source()))
+		}
+		v := &model.MapArgumentValue{Key: call.Name, Value: &model.MapArgumentValueRawOrList{}}
+		if call.Closure != nil && len(call.Args) == 0 {
+			list, err := mapArguments(call.Closure)
+			if err != nil {
+				return nil, err
+			}
+			v.Value.List = list
+		} else {
+			e, err := value(call)
+			if err != nil {
+				return nil, err
+			}
+			v.Value.Raw = groovymodel.Argument(e)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func environment(c *groovy.Call) ([]*model.EnvironmentEntry, error) {
+	b, err := block(c)
+	if err != nil {
+		return nil, err
+	}
+	var out []*model.EnvironmentEntry
+	for _, s := range b.Statements {
+		assign, ok := s.(*groovy.Assign)
+		if !ok || strings.Contains(assign.Name, ".") {
+			return nil, errorAt(s.Position(), "environment entries must be NAME = value, not: %s",
+				firstLine(s.Source()))
+		}
+		out = append(out, &model.EnvironmentEntry{Key: assign.Name, Value: environmentValue(assign.Value)})
+	}
+	return out, nil
+}
+
+func environmentValue(e *groovy.Expr) *model.EnvironmentValue {
+	if e.Kind == groovy.ExprCall && e.Call.Name == "credentials" && e.Call.Closure == nil {
+		f := &model.InternalFunction{Name: e.Call.Name}
+		for _, a := range e.Call.Args {
+			if v := environmentValue(a.Value); v.Function != nil {
+				f.AddCall(v.Function)
+			} else {
+				f.Arguments = append(f.Arguments, v.Single)
+			}
+		}
+		return &model.EnvironmentValue{Function: f}
+	}
+	return &model.EnvironmentValue{Single: groovymodel.Argument(e)}
+}
+
+func libraries(c *groovy.Call) (*model.Libraries, error) {
+	libs, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.Libraries{}
+	for _, l := range libs {
+		if l.Name != "lib" {
+			return nil, errorAt(l.Pos, "libraries must be given with lib, not %s", l.Name)
+		}
+		v, err := value(l)
+		if err != nil {
+			return nil, err
+		}
+		out.Libraries = append(out.Libraries, groovymodel.Argument(v))
+	}
+	return out, nil
+}
+
+// methodCalls converts the calls in an options, parameters, or triggers block
+func methodCalls(c *groovy.Call) ([]*model.MethodCall, error) {
+	list, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	var out []*model.MethodCall
+	for _, call := range list {
+		if call.Closure != nil {
+			return nil, errorAt(call.Pos, "%s in %s can't have a block", call.Name, c.Name)
+		}
+		out = append(out, methodCall(call))
+	}
+	return out, nil
+}
+
+func methodCall(c *groovy.Call) *model.MethodCall {
+	out := &model.MethodCall{Name: c.Name, Arguments: []*model.MethodArg{}}
+	for _, a := range c.Args {
+		v := valueOrMethodCall(a.Value)
+		if a.Key == "" {
+			out.Arguments = append(out.Arguments, &model.MethodArg{Single: v})
+		} else {
+			out.Arguments = append(out.Arguments, &model.MethodArg{WithKey: &model.KeyAndValueOrMethodCall{Key: a.Key,
+				Value: v}})
+		}
+	}
+	return out
+}
+
+func valueOrMethodCall(e *groovy.Expr) *model.ValueOrMethodCall {
+	if e.Kind == groovy.ExprCall && e.Call.Closure == nil && !strings.Contains(e.Call.Name, ".") {
+		return &model.ValueOrMethodCall{Call: methodCall(e.Call)}
+	}
+	return &model.ValueOrMethodCall{Single: groovymodel.Argument(e)}
+}
+
+func tools(c *groovy.Call) ([]*model.ArgumentValue, error) {
+	list, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	var out []*model.ArgumentValue
+	for _, t := range list {
+		v, err := value(t)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &model.ArgumentValue{Key: t.Name, Value: groovymodel.Argument(v)})
+	}
+	return out, nil
+}
+
+func post(c *groovy.Call) (*model.Post, error) {
+	list, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.Post{Conditions: []*model.BuildCondition{}}
+	for _, cond := range list {
+		if !postConditions[cond.Name] {
+			return nil, errorAt(cond.Pos, "unknown post condition %q", cond.Name)
+		}
+		b, err := block(cond)
+		if err != nil {
+			return nil, err
+		}
+		out.Conditions = append(out.Conditions, &model.BuildCondition{Condition: cond.Name, Branch: branch(b)})
+	}
+	// Jenkins runs post conditions in its own order, whatever order they're declared in
+	out.SortConditions()
+	return out, nil
+}
+
+func branch(b *groovy.Block) *model.Branch {
+	steps := groovymodel.Steps(b.Statements, nil)
+	if steps == nil {
+		steps = []*model.AnyStep{}
+	}
+	return &model.Branch{Name: "default", Steps: steps}
+}
+
+// parallelBranches converts a steps block holding just a parallel step whose arguments are all named closures, with
+// an optional failFast, into the branches Jenkins makes of it. It returns false for any other steps block.
+func parallelBranches(b *groovy.Block) ([]*model.Branch, bool, bool) {
+	if len(b.Statements) != 1 {
+		return nil, false, false
+	}
+	call, ok := b.Statements[0].(*groovy.Call)
+	if !ok || call.Name != "parallel" || call.Closure != nil || len(call.Args) == 0 {
+		return nil, false, false
+	}
+	var branches []*model.Branch
+	failFast := false
+	for _, a := range call.Args {
+		switch {
+		case a.Key == "failFast" && a.Value.Kind == groovy.ExprBool:
+			failFast = a.Value.Value == "true"
+		case a.Key != "" && a.Value.Kind == groovy.ExprClosure:
+			br := branch(a.Value.Closure)
+			br.Name = a.Key
+			branches = append(branches, br)
+		default:
+			return nil, false, false
+		}
+	}
+	if len(branches) == 0 {
+		return nil, false, false
+	}
+	return branches, failFast, true
+}
+
+// stages converts a stages or parallel block
+func stages(c *groovy.Call) ([]*model.Stage, error) {
+	list, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errorAt(c.Pos, "%s must have at least one stage", c.Name)
+	}
+	var out []*model.Stage
+	for _, s := range list {
+		if s.Name != "stage" {
+			return nil, errorAt(s.Pos, "%s can only contain stages, not %s", c.Name, s.Name)
+		}
+		st, err := stage(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func stage(c *groovy.Call) (*model.Stage, error) {
+	if len(c.Args) != 1 || c.Args[0].Key != "" || c.Closure == nil {
+		return nil, errorAt(c.Pos, "stage must have a name and a block")
+	}
+	if v := c.Args[0].Value; v.Kind != groovy.ExprString || v.Interpolated {
+		return nil, errorAt(v.Pos, "stage name must be a constant string")
+	}
+	s := &model.Stage{Name: c.Args[0].Value.Value}
+	where := fmt.Sprintf("stage %q", s.Name)
+	secs, err := sections(c.Closure, where, stageSections)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []string
+	for _, name := range []string{"steps", "stages", "parallel", "matrix"} {
+		if secs[name] != nil {
+			content = append(content, name)
+		}
+	}
+	if len(content) != 1 {
+		return nil, errorAt(c.Pos, "%s must have exactly one of steps, stages, parallel, or matrix", where)
+	}
+	switch content[0] {
+	case "steps":
+		b, err := block(secs["steps"])
+		if err != nil {
+			return nil, err
+		}
+		s.Branches = []*model.Branch{branch(b)}
+		if branches, failFast, ok := parallelBranches(b); ok {
+			s.Branches, s.FailFast = branches, failFast
+		}
+	case "stages":
+		s.Stages, err = stages(secs["stages"])
+	case "parallel":
+		s.Parallel, err = stages(secs["parallel"])
+	case "matrix":
+		s.Matrix, err = matrix(secs["matrix"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c := secs["failFast"]; c != nil {
+		if s.FailFast, err = boolValue(c); err != nil {
+			return nil, err
+		}
+	}
+	d := &directives{}
+	if err := d.parse(secs); err != nil {
+		return nil, err
+	}
+	s.Agent, s.Environment, s.Options, s.Tools, s.Input, s.When, s.Post = d.agent, d.environment, d.options, d.tools,
+		d.input, d.when, d.post
+	return s, nil
+}
+
+// directives are the directives stages and matrices share
+type directives struct {
+	agent       *model.Agent
+	environment []*model.EnvironmentEntry
+	options     *model.Options
+	tools       []*model.ArgumentValue
+	input       *model.Input
+	when        *model.When
+	post        *model.Post
+}
+
+func (d *directives) parse(secs map[string]*groovy.Call) error {
+	var err error
+	if c := secs["agent"]; c != nil {
+		if d.agent, err = agent(c); err != nil {
+			return err
+		}
+	}
+	if c := secs["environment"]; c != nil {
+		if d.environment, err = environment(c); err != nil {
+			return err
+		}
+	}
+	if c := secs["options"]; c != nil {
+		calls, err := methodCalls(c)
+		if err != nil {
+			return err
+		}
+		d.options = &model.Options{Options: calls}
+	}
+	if c := secs["tools"]; c != nil {
+		if d.tools, err = tools(c); err != nil {
+			return err
+		}
+	}
+	if c := secs["input"]; c != nil {
+		if d.input, err = input(c); err != nil {
+			return err
+		}
+	}
+	if c := secs["when"]; c != nil {
+		if d.when, err = when(c); err != nil {
+			return err
+		}
+	}
+	if c := secs["post"]; c != nil {
+		if d.post, err = post(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func input(c *groovy.Call) (*model.Input, error) {
+	b, err := block(c)
+	if err != nil {
+		return nil, err
+	}
+	secs, err := sections(b, "input", inputSections)
+	if err != nil {
+		return nil, err
+	}
+	in := &model.Input{}
+	fields := map[string]**model.RawArgument{"id": &in.ID, "message": &in.Message, "ok": &in.Ok,
+		"submitter": &in.Submitter, "submitterParameter": &in.SubmitterParameter}
+	for name, field := range fields {
+		if c := secs[name]; c != nil {
+			v, err := value(c)
+			if err != nil {
+				return nil, err
+			}
+			*field = groovymodel.Argument(v)
+		}
+	}
+	if in.Message == nil {
+		return nil, errorAt(c.Pos, "input must have a message")
+	}
+	if c := secs["parameters"]; c != nil {
+		calls, err := methodCalls(c)
+		if err != nil {
+			return nil, err
+		}
+		in.Parameters = &model.Parameters{Parameters: calls}
+	}
+	return in, nil
+}
+
+func when(c *groovy.Call) (*model.When, error) {
+	list, err := calls(c)
+	if err != nil {
+		return nil, err
+	}
+	w := &model.When{Conditions: []*model.StepOrNestedWhenCondition{}}
+	for _, cond := range list {
+		switch cond.Name {
+		case "beforeAgent":
+			w.BeforeAgent, err = boolValue(cond)
+		case "beforeInput":
+			w.BeforeInput, err = boolValue(cond)
+		case "beforeOptions":
+			w.BeforeOptions, err = boolValue(cond)
+		default:
+			var wc *model.StepOrNestedWhenCondition
+			if wc, err = whenCondition(cond); err == nil {
+				w.Conditions = append(w.Conditions, wc)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(w.Conditions) == 0 {
+		return nil, errorAt(c.Pos, "when must have at least one condition")
+	}
+	return w, nil
+}
+
+func whenCondition(c *groovy.Call) (*model.StepOrNestedWhenCondition, error) {
+	switch {
+	case nestedConditions[c.Name]:
+		children, err := calls(c)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c.Name == "not" && len(children) != 1:
+			return nil, errorAt(c.Pos, "not must have exactly one condition")
+		case len(children) == 0:
+			return nil, errorAt(c.Pos, "%s must have at least one condition", c.Name)
+		}
+		n := &model.NestedWhenCondition{Name: c.Name, Children: []*model.StepOrNestedWhenCondition{}}
+		for _, child := range children {
+			wc, err := whenCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, wc)
+		}
+		return &model.StepOrNestedWhenCondition{Nested: n}, nil
+	case c.Name == "expression":
+		b, err := block(c)
+		if err != nil {
+			return nil, err
+		}
+		script := strings.TrimSpace(b.Raw)
+		return &model.StepOrNestedWhenCondition{Step: &model.Step{Name: c.Name, Arguments: &model.ArgumentList{
+			Named: []*model.ArgumentValue{{Key: "scriptBlock", Value: &model.RawArgument{IsLiteral: true,
+				Value: &model.RawArgumentValue{AsString: &script}}}},
+		}}}, nil
+	case c.Closure != nil:
+		return nil, errorAt(c.Pos, "when condition %s can't have a block", c.Name)
+	}
+	return &model.StepOrNestedWhenCondition{Step: &model.Step{Name: c.Name,
+		Arguments: groovymodel.Arguments(c.Name, c.Args)}}, nil
+}
+
+func matrix(c *groovy.Call) (*model.Matrix, error) {
+	b, err := block(c)
+	if err != nil {
+		return nil, err
+	}
+	secs, err := sections(b, "matrix", matrixSections)
+	if err != nil {
+		return nil, err
+	}
+	m := &model.Matrix{}
+	if secs["axes"] == nil {
+		return nil, errorAt(c.Pos, "matrix must have an axes section")
+	}
+	list, err := calls(secs["axes"])
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		if a.Name != "axis" {
+			return nil, errorAt(a.Pos, "axes can only contain axis, not %s", a.Name)
+		}
+		name, values, _, err := axis(a, false)
+		if err != nil {
+			return nil, err
+		}
+		m.Axes = append(m.Axes, &model.Axis{Name: name, Values: values})
+	}
+	if len(m.Axes) == 0 {
+		return nil, errorAt(secs["axes"].Pos, "axes must have at least one axis")
+	}
+	if c := secs["excludes"]; c != nil {
+		excludes, err := calls(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range excludes {
+			if e.Name != "exclude" {
+				return nil, errorAt(e.Pos, "excludes can only contain exclude, not %s", e.Name)
+			}
+			axes, err := calls(e)
+			if err != nil {
+				return nil, err
+			}
+			var exclude []*model.ExcludeAxis
+			for _, a := range axes {
+				if a.Name != "axis" {
+					return nil, errorAt(a.Pos, "exclude can only contain axis, not %s", a.Name)
+				}
+				name, values, inverse, err := axis(a, true)
+				if err != nil {
+					return nil, err
+				}
+				ea := &model.ExcludeAxis{Name: &name, Values: values}
+				if inverse {
+					ea.Inverse = &inverse
+				}
+				exclude = append(exclude, ea)
+			}
+			m.Excludes = append(m.Excludes, exclude)
+		}
+	}
+	if secs["stages"] == nil {
+		return nil, errorAt(c.Pos, "matrix must have a stages section")
+	}
+	if m.Stages, err = stages(secs["stages"]); err != nil {
+		return nil, err
+	}
+	d := &directives{}
+	if err := d.parse(secs); err != nil {
+		return nil, err
+	}
+	m.Agent, m.Environment, m.Options, m.Tools, m.Input, m.When, m.Post = d.agent, d.environment, d.options, d.tools,
+		d.input, d.when, d.post
+	return m, nil
+}
+
+// axis converts an axis block, returning its name and values, and whether they're notValues, which are only allowed
+// in excludes
+func axis(c *groovy.Call, exclude bool) (string, []*model.RawArgument, bool, error) {
+	list, err := calls(c)
+	if err != nil {
+		return "", nil, false, err
+	}
+	var name string
+	var values []*model.RawArgument
+	inverse := false
+	for _, field := range list {
+		switch {
+		case field.Name == "name":
+			if name, err = stringValue(field); err != nil {
+				return "", nil, false, err
+			}
+		case field.Name == "values" || (exclude && field.Name == "notValues"):
+			if values != nil {
+				return "", nil, false, errorAt(field.Pos, "axis can only have one of values or notValues")
+			}
+			values = []*model.RawArgument{}
+			for _, a := range field.Args {
+				if a.Key != "" {
+					return "", nil, false, errorAt(a.Value.Pos, "axis values can't be named")
+				}
+				values = append(values, groovymodel.Argument(a.Value))
+			}
+			inverse = field.Name == "notValues"
+		default:
+			return "", nil, false, errorAt(field.Pos, "unknown section %q in axis", field.Name)
+		}
+	}
+	if name == "" || len(values) == 0 {
+		return "", nil, false, errorAt(c.Pos, "axis must have a name and values")
+	}
+	return name, values, inverse, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
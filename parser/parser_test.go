@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance parses each Jenkinsfile in testdata and compares it with the JSON Jenkins converts it to, from the
+// model package's corpus.
+func TestConformance(t *testing.T) {
+	err := filepath.Walk("testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".groovy") {
+			return err
+		}
+		rel, _ := filepath.Rel("testdata", strings.TrimSuffix(path, ".groovy")+".json")
+		t.Run(rel, func(t *testing.T) {
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+			root, err := Parse(f)
+			require.NoError(t, err)
+
+			expected, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", rel))
+			require.NoError(t, err)
+			actual, err := json.Marshal(root)
+			require.NoError(t, err)
+			assert.Equal(t, withoutFalse(t, expected), withoutFalse(t, actual))
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// alwaysMarshaled are the boolean fields models marshal even when false
+var alwaysMarshaled = []string{"failFast", "beforeAgent", "beforeInput", "beforeOptions"}
+
+// withoutFalse decodes JSON, dropping the fields models always marshal when they're false
+func withoutFalse(t *testing.T, b []byte) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal(b, &v))
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, k := range alwaysMarshaled {
+				if v[k] == false {
+					delete(v, k)
+				}
+			}
+			for _, e := range v {
+				visit(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				visit(e)
+			}
+		}
+	}
+	visit(v)
+	return v
+}
+
+func TestRawGroovy(t *testing.T) {
+	root, err := Parse(strings.NewReader(`@Library('utils') _
+import groovy.json.JsonOutput
+
+pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make'
+                currentBuild.description = "built"
+            }
+        }
+    }
+}
+`))
+	require.NoError(t, err)
+	steps := root.Pipeline.Stages[0].Branches[0].Steps
+	require.Len(t, steps, 2)
+	assert.Equal(t, "sh", steps[0].Step.Name)
+	require.NotNil(t, steps[1].Step.Raw)
+	assert.Equal(t, `currentBuild.description = "built"`, steps[1].Step.Raw.Source)
+}
+
+func TestParseAssignsUIDs(t *testing.T) {
+	root, err := Parse(strings.NewReader(`pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps {
+                dir('src') {
+                    sh 'make'
+                }
+            }
+        }
+    }
+}
+`))
+	require.NoError(t, err)
+	stage := root.Pipeline.Stages[0]
+	tree := stage.Branches[0].Steps[0].Tree
+	uids := []string{root.Pipeline.Annotations.Get(model.AnnotationUID), stage.Annotations.Get(model.AnnotationUID),
+		tree.Annotations.Get(model.AnnotationUID), tree.Children[0].Step.Annotations.Get(model.AnnotationUID)}
+	for _, uid := range uids {
+		assert.NotEmpty(t, uid)
+	}
+
+	b, err := model.MarshalExtended(root)
+	require.NoError(t, err)
+	read := &model.Root{}
+	require.NoError(t, model.UnmarshalExtended(b, read))
+	assert.Equal(t, root, read)
+	path, node := model.FindUID(read, uids[3])
+	assert.Equal(t, "/pipeline/stages/0/branches/0/steps/0/children/0", path)
+	assert.Equal(t, read.Pipeline.Stages[0].Branches[0].Steps[0].Tree.Children[0].Step, node)
+}
+
+func TestParseMetrics(t *testing.T) {
+	p := metrics.NewPrometheus()
+	metrics.SetSink(p)
+	defer metrics.SetSink(nil)
+
+	_, err := Parse(strings.NewReader("pipeline {\n  agent any\n  stages { stage('a') { steps { echo 'a' } } }\n}"))
+	require.NoError(t, err)
+	_, err = model.Decode([]byte(`{"pipeline": {"agent": {"type": "any"}, "stages": []}}`))
+	require.NoError(t, err)
+	var counts []*metrics.Sample
+	for _, s := range p.Samples() {
+		if s.Name == metrics.ParseDuration+"_count" {
+			counts = append(counts, s)
+		}
+	}
+	assert.Equal(t, []*metrics.Sample{
+		{Name: metrics.ParseDuration + "_count", Labels: `{format="groovy"}`, Value: 1},
+		{Name: metrics.ParseDuration + "_count", Labels: `{format="json"}`, Value: 1},
+	}, counts)
+}
+
+func TestParseSortsPostConditions(t *testing.T) {
+	root, src, err := ParseSource(strings.NewReader(`pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps { sh 'make' }
+            post {
+                failure { echo 'failed' }
+                always { echo 'done' }
+            }
+        }
+    }
+    post {
+        success { echo 'passed' }
+        changed { echo 'changed' }
+    }
+}
+`))
+	require.NoError(t, err)
+	var conditions []string
+	for _, b := range root.Pipeline.Stages[0].Post.Conditions {
+		conditions = append(conditions, b.Condition)
+	}
+	for _, b := range root.Pipeline.Post.Conditions {
+		conditions = append(conditions, b.Condition)
+	}
+	assert.Equal(t, []string{"always", "failure", "changed", "success"}, conditions)
+
+	text, ok := src.Original(root.Pipeline.Stages[0].Post.Conditions[0], "")
+	assert.True(t, ok)
+	assert.Equal(t, "                always { echo 'done' }", text)
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"node { }": "line 1, column 1: no pipeline block found",
+		"pipeline { agent any\n stages { stage('a') { steps { echo 'a' } } } }\npipeline { }": "line 3, column 1: " +
+			"only one pipeline block is allowed",
+		"pipeline {\n  agent any\n}": "line 1, column 10: pipeline must have a stages section",
+		"pipeline {\n  agent some\n  stages { stage('a') { steps { echo 'a' } } }\n}": "line 2, column 3: agent must " +
+			"be any, none, or a block",
+		"pipeline {\n  agent any\n  stages {\n    stage('a') { echo 'a' }\n  }\n}": "line 4, column 18: unknown " +
+			`section "echo" in stage "a"`,
+		"pipeline {\n  agent any\n  stages {\n    stage('a') { }\n  }\n}": `line 4, column 5: stage "a" must have ` +
+			"exactly one of steps, stages, parallel, or matrix",
+		"pipeline {\n  agent any\n  stages {\n    stage(\"${x}\") { steps { echo 'a' } }\n  }\n}": "line 4, column 11: " +
+			"stage name must be a constant string",
+		"pipeline {\n  agent any\n  environment { env.FOO = 'x' }\n  stages { stage('a') { steps { echo 'a' } } }\n}": "line " +
+			"3, column 17: environment entries must be NAME = value, not: env.FOO = 'x'",
+		"pipeline {\n  agent any\n  stages { stage('a') { steps { echo 'a' } } }\n  post { sometimes { echo 'a' } }\n}": "line " +
+			`4, column 10: unknown post condition "sometimes"`,
+		"pipeline {\n  agent any\n  stages { stage('a') { steps { echo 'a' } } }\n  agent none\n}": "line 4, column 3: " +
+			"multiple agent sections in pipeline",
+		"pipeline { stages { stage('a') { steps { sh 'x } } } }": "line 1, column 45: unterminated string",
+	} {
+		_, err := Parse(strings.NewReader(src))
+		assert.EqualError(t, err, msg, src)
+		if err != nil && !strings.Contains(msg, "unterminated") {
+			assert.IsType(t, &Error{}, err, src)
+		}
+	}
+
+	_, err := Parse(strings.NewReader("pipeline {\n  agent any\n  stages {\n    stage('a') { steps { echo 'a' } }\n" +
+		"    stage('a') { steps { echo 'b' } }\n  }\n}"))
+	assert.IsType(t, &model.InvariantError{}, err)
+}
+
+func TestParseSource(t *testing.T) {
+	root, src, err := ParseSource(strings.NewReader(`pipeline {
+	agent any
+	stages {
+		// Compiles everything
+		stage('Build') {
+			steps {
+				sh 'make' // the default target
+			}
+		}
+		stage('Test') {
+			steps { sh 'make test' }
+		}
+	}
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "\t", src.Indent())
+
+	build := root.Pipeline.Stages[0]
+	text, ok := src.Original(build, "")
+	assert.True(t, ok)
+	assert.Equal(t, "\t\t// Compiles everything\n\t\tstage('Build') {\n\t\t\tsteps {\n\t\t\t\tsh 'make' // the default target\n"+
+		"\t\t\t}\n\t\t}", text)
+	assert.Equal(t, "\t\t// Compiles everything\n", src.Leading(build, ""))
+	text, ok = src.Original(root.Pipeline, "agent")
+	assert.True(t, ok)
+	assert.Equal(t, "\tagent any", text)
+
+	// Steps sharing a line with their block can't be reused on their own.
+	_, ok = src.Original(root.Pipeline.Stages[1].Branches[0].Steps[0], "")
+	assert.False(t, ok)
+	_, ok = src.Original(root.Pipeline.Stages[1], "steps")
+	assert.True(t, ok)
+
+	build.Name = "Compile"
+	_, ok = src.Original(build, "")
+	assert.False(t, ok)
+	_, ok = src.Original(build, "steps")
+	assert.True(t, ok)
+	_, ok = src.Original(&model.Stage{Name: "Test"}, "")
+	assert.False(t, ok)
+
+	before, after, ok := src.Surrounding(root.Pipeline)
+	assert.True(t, ok)
+	assert.Equal(t, "", before)
+	assert.Equal(t, "", after)
+}
@@ -0,0 +1,280 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Source is the Jenkinsfile a pipeline was parsed from, with where its stages, steps, post conditions, and sections
+// came from, so that writers can reuse the original text of the parts which haven't changed since.
+type Source struct {
+	// Text is the whole Jenkinsfile.
+	Text  string
+	spans map[sourceKey]*span
+}
+
+// sourceKey identifies a node, or one of its sections when section isn't empty
+type sourceKey struct {
+	node    interface{}
+	section string
+}
+
+// span is where a node or section came from, and its JSON when it was parsed
+type span struct {
+	// start is the start of the comment lines directly above the node, and line the start of its first line.
+	start, line, end int
+	parsed           []byte
+}
+
+// ParseSource parses a Declarative Jenkinsfile like Parse, also returning its source.
+func ParseSource(r io.Reader) (*model.Root, *Source, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	src := &Source{Text: string(b), spans: map[sourceKey]*span{}}
+	// Parse has already checked the script parses, and is a valid pipeline.
+	script, _ := groovy.Parse(src.Text)
+	for _, s := range script.Statements {
+		if call, ok := s.(*groovy.Call); ok && call.Name == "pipeline" {
+			src.record(root.Pipeline, "", call)
+			src.pipeline(root.Pipeline, call.Closure)
+		}
+	}
+	return root, src, nil
+}
+
+// Original returns the original text of a node, such as a *model.Stage or *model.AnyStep, or of one of its sections,
+// such as "agent" or "steps", if it was parsed from the source and hasn't changed since. The text runs from the start
+// of the first line, including any comment lines directly above it, to the end of the last line, including any
+// comment after it, without the final line break. Nodes are matched by identity, so copies of them don't match.
+func (strct *Source) Original(node interface{}, section string) (string, bool) {
+	if strct == nil {
+		return "", false
+	}
+	sp := strct.spans[sourceKey{node, section}]
+	if sp == nil {
+		return "", false
+	}
+	if b, err := json.Marshal(sectionValue(node, section)); err != nil || !bytes.Equal(b, sp.parsed) {
+		return "", false
+	}
+	return strct.Text[sp.start:sp.end], true
+}
+
+// Leading returns the comment lines directly above a node or section, such as a *model.Stage or its "agent", with
+// their line breaks, whether or not it has changed since it was parsed, so that writers can keep them when rendering
+// it anew.
+func (strct *Source) Leading(node interface{}, section string) string {
+	if strct == nil {
+		return ""
+	}
+	if sp := strct.spans[sourceKey{node, section}]; sp != nil {
+		return strct.Text[sp.start:sp.line]
+	}
+	return ""
+}
+
+// Indent returns the indentation of the first indented line of the source, such as two spaces or a tab, or an empty
+// string if no line is indented.
+func (strct *Source) Indent() string {
+	if strct == nil {
+		return ""
+	}
+	for _, l := range strings.Split(strct.Text, "\n") {
+		trimmed := strings.TrimLeft(l, " \t")
+		if trimmed != "" && trimmed != l {
+			return l[:len(l)-len(trimmed)]
+		}
+	}
+	return ""
+}
+
+// Surrounding returns the text before and after the pipeline block, such as imports and @Library annotations, if
+// pipeline was parsed from the source.
+func (strct *Source) Surrounding(pipeline *model.Pipeline) (string, string, bool) {
+	if strct == nil {
+		return "", "", false
+	}
+	sp := strct.spans[sourceKey{pipeline, ""}]
+	if sp == nil {
+		return "", "", false
+	}
+	return strct.Text[:sp.start], strings.TrimPrefix(strct.Text[sp.end:], "\n"), true
+}
+
+// record records where a node or section came from. Nodes which share their first or last line with other code, such
+// as those in one-line blocks, aren't recorded, since their text can't be reused on its own.
+func (strct *Source) record(node interface{}, section string, stmt groovy.Statement) {
+	text := strct.Text
+	start := stmt.Position().Offset
+	end := start + len(stmt.Source())
+	lineStart := strings.LastIndex(text[:start], "\n") + 1
+	if strings.TrimSpace(text[lineStart:start]) != "" {
+		return
+	}
+	lineEnd := len(text)
+	if i := strings.Index(text[end:], "\n"); i >= 0 {
+		lineEnd = end + i
+	}
+	switch rest := strings.TrimSpace(text[end:lineEnd]); {
+	case strings.HasPrefix(rest, "//"):
+		end = lineEnd
+	case rest != "" && rest != ";":
+		return
+	}
+	start = lineStart
+	for start > 0 {
+		prev := strings.LastIndex(text[:start-1], "\n") + 1
+		if !strings.HasPrefix(strings.TrimSpace(text[prev:start-1]), "//") {
+			break
+		}
+		start = prev
+	}
+	b, err := json.Marshal(sectionValue(node, section))
+	if err != nil {
+		return
+	}
+	strct.spans[sourceKey{node, section}] = &span{start: start, line: lineStart, end: end, parsed: b}
+}
+
+func (strct *Source) pipeline(p *model.Pipeline, b *groovy.Block) {
+	for _, call := range blockCalls(b) {
+		switch call.Name {
+		case "stages":
+			strct.stages(p.Stages, call)
+		case "post":
+			strct.record(p, call.Name, call)
+			strct.post(p.Post, call)
+		default:
+			strct.record(p, call.Name, call)
+		}
+	}
+}
+
+func (strct *Source) stages(stages []*model.Stage, c *groovy.Call) {
+	for i, call := range blockCalls(c.Closure) {
+		if i < len(stages) {
+			strct.record(stages[i], "", call)
+			strct.stage(stages[i], call.Closure)
+		}
+	}
+}
+
+func (strct *Source) stage(s *model.Stage, b *groovy.Block) {
+	for _, call := range blockCalls(b) {
+		switch call.Name {
+		case "steps":
+			strct.record(s, call.Name, call)
+			if len(s.Branches) == 1 {
+				strct.steps(s.Branches[0].Steps, call.Closure.Statements)
+			} else if parallel, ok := call.Closure.Statements[0].(*groovy.Call); ok {
+				// The branches of a parallel step
+				i := 0
+				for _, a := range parallel.Args {
+					if a.Value.Kind == groovy.ExprClosure && i < len(s.Branches) {
+						strct.steps(s.Branches[i].Steps, a.Value.Closure.Statements)
+						i++
+					}
+				}
+			}
+		case "stages":
+			strct.stages(s.Stages, call)
+		case "parallel":
+			strct.stages(s.Parallel, call)
+		case "matrix":
+			strct.record(s.Matrix, "", call)
+			strct.matrix(s.Matrix, call.Closure)
+		case "post":
+			strct.record(s, call.Name, call)
+			strct.post(s.Post, call)
+		default:
+			strct.record(s, call.Name, call)
+		}
+	}
+}
+
+func (strct *Source) matrix(m *model.Matrix, b *groovy.Block) {
+	for _, call := range blockCalls(b) {
+		switch call.Name {
+		case "stages":
+			strct.stages(m.Stages, call)
+		case "post":
+			strct.record(m, call.Name, call)
+			strct.post(m.Post, call)
+		default:
+			strct.record(m, call.Name, call)
+		}
+	}
+}
+
+// post records post conditions, which the parser sorts into the order Jenkins runs them, so they're matched by name
+func (strct *Source) post(p *model.Post, c *groovy.Call) {
+	for _, call := range blockCalls(c.Closure) {
+		for _, b := range p.Conditions {
+			if b != nil && b.Condition == call.Name {
+				strct.record(b, "", call)
+				strct.steps(b.Branch.Steps, call.Closure.Statements)
+				break
+			}
+		}
+	}
+}
+
+// steps records steps, which groovymodel.Steps converts one for one from statements
+func (strct *Source) steps(steps []*model.AnyStep, stmts []groovy.Statement) {
+	for i, stmt := range stmts {
+		if i >= len(steps) {
+			return
+		}
+		strct.record(steps[i], "", stmt)
+		if call, ok := stmt.(*groovy.Call); ok && steps[i].Tree != nil && call.Closure != nil {
+			strct.steps(steps[i].Tree.Children, call.Closure.Statements)
+		}
+	}
+}
+
+func blockCalls(b *groovy.Block) []*groovy.Call {
+	if b == nil {
+		return nil
+	}
+	var out []*groovy.Call
+	for _, s := range b.Statements {
+		if call, ok := s.(*groovy.Call); ok {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+// sectionValue returns a node, or the model value of one of its sections
+func sectionValue(node interface{}, section string) interface{} {
+	if section == "" {
+		return node
+	}
+	switch n := node.(type) {
+	case *model.Pipeline:
+		return map[string]interface{}{"agent": n.Agent, "environment": n.Environment, "libraries": n.Libraries,
+			"options": n.Options, "parameters": n.Parameters, "triggers": n.Triggers, "tools": n.Tools,
+			"post": n.Post}[section]
+	case *model.Stage:
+		return map[string]interface{}{"agent": n.Agent, "environment": n.Environment, "tools": n.Tools,
+			"options": n.Options, "when": n.When, "input": n.Input, "failFast": n.FailFast, "post": n.Post,
+			"steps": n.Branches}[section]
+	case *model.Matrix:
+		return map[string]interface{}{"agent": n.Agent, "environment": n.Environment, "tools": n.Tools,
+			"options": n.Options, "when": n.When, "input": n.Input, "axes": n.Axes, "excludes": n.Excludes,
+			"post": n.Post}[section]
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+pipeline {
+    agent any
+    stages {
+        stage("foo") {
+            steps {
+                script {
+                    if (isUnix()) {
+                        sh('echo "THIS WORKS"')
+                    } else {
+                        bat('echo "THIS WORKS"')
+                    }
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,15 @@
+pipeline {
+    agent {
+        node {
+            label ""
+            customWorkspace "some-sub-dir"
+        }
+    }
+    stages {
+        stage("foo") {
+            steps {
+                echo "Workspace dir is ${pwd()}"
+            }
+        }
+    }
+}
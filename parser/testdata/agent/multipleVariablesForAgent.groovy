@@ -0,0 +1,25 @@
+pipeline {
+    agent {
+        otherField {
+            label "some-label"
+            otherField "banana"
+            nested {
+                foo "monkey"
+                bar false
+            }
+        }
+    }
+    stages {
+        stage("foo") {
+            steps {
+                script {
+                    if (isUnix()) {
+                        sh('echo ONAGENT=$ONAGENT')
+                    } else {
+                        bat('echo ONAGENT=%ONAGENT%')
+                    }
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,11 @@
+pipeline {
+    agent none
+    stages {
+        stage("foo") {
+            steps {
+                milestone null
+                echo "Null is no problem"
+            }
+        }
+    }
+}
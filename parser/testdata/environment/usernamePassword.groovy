@@ -0,0 +1,21 @@
+pipeline {
+    environment {
+        FOO = credentials("FOOcredentials")
+    }
+    agent any
+    stages {
+        stage("foo") {
+            steps {
+                echo "FOO is $FOO"
+                echo "FOO_USR is $FOO_USR"
+                echo "FOO_PSW is $FOO_PSW"
+                dir("combined") {
+                    writeFile file: 'foo.txt', text: "${FOO}"
+                }
+                writeFile file: 'foo_psw.txt', text: "${FOO_PSW}"
+                writeFile file: 'foo_usr.txt', text: "${FOO_USR}"
+                archive "**/*.txt"
+            }
+        }
+    }
+}
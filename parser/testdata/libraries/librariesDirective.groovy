@@ -0,0 +1,15 @@
+pipeline {
+    agent none
+    libraries {
+        lib("echo-utils@master")
+        lib("whereFrom")
+    }
+    stages {
+        stage("foo") {
+            steps {
+                myecho()
+                whereFrom()
+            }
+        }
+    }
+}
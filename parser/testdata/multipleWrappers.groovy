@@ -0,0 +1,14 @@
+pipeline {
+    agent none
+    options {
+        timeout(time: 5, unit: 'MINUTES')
+        retry(3)
+    }
+    stages {
+        stage("foo") {
+            steps {
+                echo "hello"
+            }
+        }
+    }
+}
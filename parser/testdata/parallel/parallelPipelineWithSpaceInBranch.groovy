@@ -0,0 +1,15 @@
+pipeline {
+    agent none
+    stages {
+        stage("foo") {
+            steps {
+                parallel('first one': {
+                    echo "First branch"
+                },
+                'second one': {
+                    echo "Second branch"
+                })
+            }
+        }
+    }
+}
@@ -0,0 +1,37 @@
+pipeline {
+    agent none
+    stages {
+        stage("foo") {
+            failFast true
+            parallel {
+                stage("first") {
+                    steps {
+                        error "First branch"
+                    }
+                    post {
+                        aborted {
+                            echo "FIRST STAGE ABORTED"
+                        }
+                        failure {
+                            echo "FIRST STAGE FAILURE"
+                        }
+                    }
+                }
+                stage("second") {
+                    steps {
+                        sleep 10
+                        echo "Second branch"
+                    }
+                    post {
+                        aborted {
+                            echo "SECOND STAGE ABORTED"
+                        }
+                        failure {
+                            echo "SECOND STAGE FAILURE"
+                        }
+                    }
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,18 @@
+pipeline {
+    agent none
+    stages {
+        stage("foo") {
+            input {
+                message "Continue?"
+                id "simple-input"
+                parameters {
+                    booleanParam(defaultValue: true, description: '', name: 'flag')
+                    string(defaultValue: 'banana', description: '', name: 'fruit')
+                }
+            }
+            steps {
+                echo "Would I like to eat a ${fruit}? ${flag}"
+            }
+        }
+    }
+}
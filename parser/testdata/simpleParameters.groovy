@@ -0,0 +1,13 @@
+pipeline {
+    agent none
+    parameters {
+        booleanParam(defaultValue: true, description: '', name: 'flag')
+    }
+    stages {
+        stage("foo") {
+            steps {
+                echo "hello"
+            }
+        }
+    }
+}
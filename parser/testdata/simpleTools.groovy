@@ -0,0 +1,21 @@
+pipeline {
+    agent {
+        label "some-label"
+    }
+    tools {
+        maven "apache-maven-3.0.1"
+    }
+    stages {
+        stage("foo") {
+            steps {
+                script {
+                    if (isUnix()) {
+                        sh 'mvn --version'
+                    } else {
+                        bat 'mvn --version'
+                    }
+                }
+            }
+        }
+    }
+}
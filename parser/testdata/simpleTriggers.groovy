@@ -0,0 +1,13 @@
+pipeline {
+    agent none
+    triggers {
+        cron('@daily')
+    }
+    stages {
+        stage("foo") {
+            steps {
+                echo "hello"
+            }
+        }
+    }
+}
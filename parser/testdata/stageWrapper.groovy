@@ -0,0 +1,13 @@
+pipeline {
+    agent none
+    stages {
+        stage("foo") {
+            options {
+                timeout(time: 5, unit: 'MINUTES')
+            }
+            steps {
+                echo "hello"
+            }
+        }
+    }
+}
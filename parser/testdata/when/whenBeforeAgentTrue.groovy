@@ -0,0 +1,27 @@
+pipeline {
+    agent none
+    stages {
+        stage("One") {
+            steps {
+                echo "Hello"
+            }
+        }
+        stage("Two") {
+            agent {
+                label "here"
+            }
+            when {
+                beforeAgent true
+                expression {
+                    return getContext(hudson.FilePath) == null
+                }
+            }
+            steps {
+                script {
+                    echo "World"
+                    echo "Heal it"
+                }
+            }
+        }
+    }
+}
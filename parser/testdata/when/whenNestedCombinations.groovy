@@ -0,0 +1,49 @@
+pipeline {
+    agent any
+    environment {
+        BRANCH_NAME = "master"
+    }
+    stages {
+        stage("One") {
+            steps {
+                echo "First stage has no condition"
+            }
+        }
+        stage("Two") {
+            when {
+                allOf {
+                    branch "master"
+                }
+            }
+            steps {
+                echo "Second stage meets condition"
+            }
+        }
+        stage("Three") {
+            when {
+                allOf {
+                    branch "master"
+                    expression { "a" == "a" }
+                    expression { false }
+                }
+            }
+            steps {
+                echo "Third stage meets condition"
+            }
+        }
+        stage("Four") {
+            when {
+                anyOf {
+                    allOf {
+                        not { branch "SOME_OTHER_BRANCH" }
+                        expression { true }
+                    }
+                    expression { false }
+                }
+            }
+            steps {
+                echo "Fourth stage meets condition"
+            }
+        }
+    }
+}
@@ -0,0 +1,142 @@
+package plan
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Lock is a lock option or step from the Lockable Resources plugin. Either Resource or Label is set.
+type Lock struct {
+	// Resource is the name of the resource locked.
+	Resource string `json:"resource,omitempty" jenkins:"resource,default"`
+	// Label locks Quantity of the resources with this label, or all of them if Quantity is zero.
+	Label    string `json:"label,omitempty" jenkins:"label"`
+	Quantity int    `json:"quantity,omitempty" jenkins:"quantity"`
+	// Variable is the environment variable the names of the locked resources are stored in.
+	Variable string `json:"variable,omitempty" jenkins:"variable"`
+	// InversePrecedence gives the lock to the newest waiting build rather than the oldest.
+	InversePrecedence bool `json:"inversePrecedence,omitempty" jenkins:"inversePrecedence"`
+	// SkipIfLocked skips the locked block, rather than waiting, if the resource is already locked.
+	SkipIfLocked bool `json:"skipIfLocked,omitempty" jenkins:"skipIfLocked"`
+	// Step is true for lock steps, which hold the lock only while their block runs, rather than for the whole stage
+	// or pipeline.
+	Step bool `json:"step,omitempty"`
+}
+
+// Throttle is a throttle option or step from the Throttle Concurrent Builds plugin
+type Throttle struct {
+	// Categories are the throttle categories, whose limits on concurrent builds are configured in Jenkins.
+	Categories []string `json:"categories" jenkins:"categories,default"`
+	// Step is true for throttle steps, which only throttle their block.
+	Step bool `json:"step,omitempty"`
+}
+
+// Concurrency is the constraints on running a pipeline or stage concurrently with other builds
+type Concurrency struct {
+	// Serial is true if builds of the pipeline never run concurrently, from the disableConcurrentBuilds option.
+	Serial bool `json:"serial,omitempty"`
+	// AbortPrevious is true if a new build aborts the running build, rather than waiting for it to finish.
+	AbortPrevious bool `json:"abortPrevious,omitempty"`
+	// Locks are the resources locked while the pipeline or stage runs, in the order they're acquired.
+	Locks []*Lock `json:"locks,omitempty"`
+	// Throttles are the throttle categories the pipeline or stage counts against.
+	Throttles []*Throttle `json:"throttles,omitempty"`
+	// Warnings describe concurrency options and steps whose arguments couldn't be interpreted.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// disableConcurrentBuilds is the arguments of the disableConcurrentBuilds option
+type disableConcurrentBuilds struct {
+	AbortPrevious bool `jenkins:"abortPrevious"`
+}
+
+// ParseConcurrency interprets the concurrency options of a pipeline or stage, and the lock and throttle steps among
+// its steps. It returns nil if there are none. disableConcurrentBuilds is only allowed as a pipeline option, so it's
+// ignored in stage options.
+func ParseConcurrency(options []*model.MethodCall, steps []*Step) *Concurrency {
+	c := &Concurrency{}
+	found := false
+	for _, o := range options {
+		if o == nil {
+			continue
+		}
+		switch o.Name {
+		case "disableConcurrentBuilds":
+			found = true
+			var args disableConcurrentBuilds
+			if err := o.Args().Bind(&args); err != nil {
+				c.warn("option", o.Name, err)
+			}
+			c.Serial, c.AbortPrevious = true, args.AbortPrevious
+		case "lock":
+			found = true
+			c.lock("option", o.Name, o.Args(), false)
+		case "throttle":
+			found = true
+			c.throttle("option", o.Name, o.Args(), false)
+		}
+	}
+	var visit func(steps []*Step)
+	visit = func(steps []*Step) {
+		for _, s := range steps {
+			if s.Source != nil && s.Source.Tree != nil {
+				switch s.Name {
+				case "lock":
+					found = true
+					c.lock("step", s.Name, s.Source.Tree.Arguments, true)
+				case "throttle":
+					found = true
+					c.throttle("step", s.Name, s.Source.Tree.Arguments, true)
+				}
+			}
+			visit(s.Children)
+		}
+	}
+	visit(steps)
+	if !found {
+		return nil
+	}
+	return c
+}
+
+func (strct *Concurrency) lock(kind, name string, args *model.ArgumentList, step bool) {
+	l := &Lock{Step: step}
+	if err := args.Bind(l); err != nil {
+		strct.warn(kind, name, err)
+		return
+	}
+	if l.Resource == "" && l.Label == "" {
+		strct.warn(kind, name, fmt.Errorf("a literal resource or label is required"))
+		return
+	}
+	strct.Locks = append(strct.Locks, l)
+}
+
+func (strct *Concurrency) throttle(kind, name string, args *model.ArgumentList, step bool) {
+	t := &Throttle{Step: step}
+	if err := args.Bind(t); err != nil {
+		strct.warn(kind, name, err)
+		return
+	}
+	strct.Throttles = append(strct.Throttles, t)
+}
+
+func (strct *Concurrency) warn(kind, name string, err error) {
+	strct.Warnings = append(strct.Warnings, fmt.Sprintf("%s %s: %s", kind, name, err))
+}
+
+// Resources returns the names of the resources locked for the whole pipeline or stage, excluding lock steps and
+// locks by label, which can't be known until the build runs.
+func (strct *Concurrency) Resources() []string {
+	if strct == nil {
+		return nil
+	}
+	var out []string
+	for _, l := range strct.Locks {
+		if !l.Step && l.Resource != "" {
+			out = append(out, l.Resource)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrency(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(`{"pipeline": {
+  "agent": {"type": "any"},
+  "options": {"options": [
+    {"name": "disableConcurrentBuilds", "arguments": [{"key": "abortPrevious", "value": {"isLiteral": true, "value": true}}]},
+    {"name": "throttle", "arguments": [{"isLiteral": false, "value": "['deploy', 'heavy']"}]}
+  ]},
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]}
+    ]}]},
+    {"name": "Deploy",
+      "options": {"options": [
+        {"name": "lock", "arguments": [{"isLiteral": true, "value": "staging-env"}]}
+      ]},
+      "branches": [{"name": "default", "steps": [
+        {"name": "lock", "arguments": [
+          {"key": "label", "value": {"isLiteral": true, "value": "printer"}},
+          {"key": "quantity", "value": {"isLiteral": true, "value": 1}},
+          {"key": "variable", "value": {"isLiteral": true, "value": "PRINTER"}}
+        ], "children": [
+          {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "print $PRINTER"}}]}
+        ]},
+        {"name": "lock", "arguments": {"isLiteral": false, "value": "\"${env.TARGET}\""}, "children": []}
+      ]}]}
+  ]
+}}`), root))
+
+	p, err := Build(root)
+	require.NoError(t, err)
+	assert.Equal(t, &Concurrency{Serial: true, AbortPrevious: true,
+		Throttles: []*Throttle{{Categories: []string{"deploy", "heavy"}}}}, p.Concurrency)
+	assert.Nil(t, p.Stages[0].Concurrency)
+
+	deploy := p.Stages[1].Concurrency
+	require.NotNil(t, deploy)
+	assert.Equal(t, []*Lock{
+		{Resource: "staging-env"},
+		{Label: "printer", Quantity: 1, Variable: "PRINTER", Step: true},
+	}, deploy.Locks)
+	assert.Equal(t, []string{`step lock: argument "resource": "${env.TARGET}" is an interpolated string`},
+		deploy.Warnings)
+	assert.Equal(t, []string{"staging-env"}, deploy.Resources())
+	assert.False(t, deploy.Serial)
+}
+
+func TestParseConcurrencyWarnings(t *testing.T) {
+	c := ParseConcurrency(parseOptions(t, `{"options": [
+		{"name": "disableConcurrentBuilds", "arguments": [{"key": "abortPrevious", "value": {"isLiteral": true, "value": "maybe"}}]},
+		{"name": "lock", "arguments": [{"key": "quantity", "value": {"isLiteral": true, "value": 2}}]},
+		{"name": "throttle", "arguments": [{"isLiteral": true, "value": "deploy"}]}
+	]}`), nil)
+	assert.Equal(t, []string{
+		`option disableConcurrentBuilds: argument "abortPrevious": cannot decode "maybe" as a boolean`,
+		"option lock: a literal resource or label is required",
+		`option throttle: argument "categories": cannot decode string value into []string`,
+	}, c.Warnings)
+	assert.True(t, c.Serial)
+	assert.Empty(t, c.Locks)
+
+	assert.Nil(t, ParseConcurrency(parseOptions(t, `{"options": [{"name": "timestamps"}]}`), nil))
+	var none *Concurrency
+	assert.Nil(t, none.Resources())
+}
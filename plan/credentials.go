@@ -0,0 +1,76 @@
+package plan
+
+// CredentialType is the kind of a Jenkins credential, which determines the environment variables bound for it
+type CredentialType string
+
+const (
+	// SecretText binds the secret itself to the variable.
+	SecretText CredentialType = "secretText"
+	// SecretFile binds the path of a temporary file holding the secret.
+	SecretFile CredentialType = "secretFile"
+	// UsernamePassword binds "username:password", plus the username to NAME_USR and the password to NAME_PSW.
+	UsernamePassword CredentialType = "usernamePassword"
+	// SSHUserPrivateKey binds the path of a temporary key file, plus the username to NAME_USR and the passphrase to
+	// NAME_PSW.
+	SSHUserPrivateKey CredentialType = "sshUserPrivateKey"
+	// Certificate binds the path of a temporary keystore file, plus the keystore password to NAME_PSW.
+	Certificate CredentialType = "certificate"
+)
+
+// Parts of a credential bound to an environment variable
+const (
+	PartSecret     = "secret"
+	PartFile       = "file"
+	PartUsername   = "username"
+	PartPassword   = "password"
+	PartPassphrase = "passphrase"
+	PartUserPass   = "username:password"
+	PartKeyFile    = "keyFile"
+	PartKeystore   = "keystore"
+)
+
+type binding struct {
+	suffix string
+	part   string
+}
+
+var credentialBindings = map[CredentialType][]binding{
+	SecretText:        {{"", PartSecret}},
+	SecretFile:        {{"", PartFile}},
+	UsernamePassword:  {{"", PartUserPass}, {"_USR", PartUsername}, {"_PSW", PartPassword}},
+	SSHUserPrivateKey: {{"", PartKeyFile}, {"_USR", PartUsername}, {"_PSW", PartPassphrase}},
+	Certificate:       {{"", PartKeystore}, {"_PSW", PartPassword}},
+}
+
+// ExpandCredentials returns env with the variables Jenkins implicitly binds for each credentials() entry added after
+// it, such as NAME_USR and NAME_PSW for username and password credentials. types maps credential IDs to their types;
+// entries for credentials whose type isn't known are left as they are. The returned variables have CredentialPart set
+// to the part of the credential they hold.
+func ExpandCredentials(env []*EnvVar, types map[string]CredentialType) []*EnvVar {
+	var out []*EnvVar
+	for _, e := range env {
+		bindings, ok := credentialBindings[types[e.Credential]]
+		if e.Credential == "" || !ok {
+			out = append(out, e)
+			continue
+		}
+		for _, b := range bindings {
+			out = append(out, &EnvVar{Key: e.Key + b.suffix, Credential: e.Credential, CredentialPart: b.part})
+		}
+	}
+	return out
+}
+
+// ExpandCredentials expands the credential bindings in the environment of the plan and all its stages, as with the
+// ExpandCredentials function.
+func (strct *Plan) ExpandCredentials(types map[string]CredentialType) {
+	strct.Environment = ExpandCredentials(strct.Environment, types)
+	var visit func(stages []*Stage)
+	visit = func(stages []*Stage) {
+		for _, s := range stages {
+			s.Environment = ExpandCredentials(s.Environment, types)
+			visit(s.Children)
+		}
+	}
+	visit(strct.Stages)
+}
@@ -0,0 +1,41 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCredentials(t *testing.T) {
+	p, err := Build(loadRoot(t, "environment/usernamePassword"))
+	require.NoError(t, err)
+
+	p.ExpandCredentials(map[string]CredentialType{"FOOcredentials": UsernamePassword})
+	expected := []*EnvVar{
+		{Key: "FOO", Credential: "FOOcredentials", CredentialPart: PartUserPass},
+		{Key: "FOO_USR", Credential: "FOOcredentials", CredentialPart: PartUsername},
+		{Key: "FOO_PSW", Credential: "FOOcredentials", CredentialPart: PartPassword},
+	}
+	assert.Equal(t, expected, p.Environment)
+	for _, s := range p.Leaves() {
+		assert.Subset(t, s.Environment, expected)
+	}
+}
+
+func TestExpandCredentialsTypes(t *testing.T) {
+	env := []*EnvVar{
+		{Key: "PLAIN", Value: "x", Literal: true},
+		{Key: "KEY", Credential: "deploy-key"},
+		{Key: "STORE", Credential: "signing"},
+		{Key: "OTHER", Credential: "unknown"},
+	}
+	got := ExpandCredentials(env, map[string]CredentialType{"deploy-key": SSHUserPrivateKey, "signing": Certificate})
+	var keys []string
+	for _, e := range got {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{"PLAIN", "KEY", "KEY_USR", "KEY_PSW", "STORE", "STORE_PSW", "OTHER"}, keys)
+	assert.Equal(t, PartKeystore, got[4].CredentialPart)
+	assert.Empty(t, got[6].CredentialPart)
+}
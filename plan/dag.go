@@ -0,0 +1,158 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveDependencies sets the DependsOn IDs of each stage from the stage names in its dependsOn extension. Stage
+// names are unique within a Declarative pipeline, so names identify stages anywhere in the plan.
+func resolveDependencies(p *Plan) error {
+	ids := map[string]string{}
+	var index func(stages []*Stage)
+	index = func(stages []*Stage) {
+		for _, s := range stages {
+			ids[s.Name] = s.ID
+			index(s.Children)
+		}
+	}
+	index(p.Stages)
+
+	var resolve func(stages []*Stage) error
+	resolve = func(stages []*Stage) error {
+		for _, s := range stages {
+			for _, name := range s.Source.DependsOn {
+				id, ok := ids[name]
+				if !ok {
+					return fmt.Errorf("stage %q depends on unknown stage %q", s.ID, name)
+				}
+				s.DependsOn = append(s.DependsOn, id)
+			}
+			if err := resolve(s.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return resolve(p.Stages)
+}
+
+// Needs returns the plan as a graph of its leaf stages, for targets which run jobs as a graph: each leaf stage's ID
+// maps to the sorted IDs of the leaf stages which must finish before it starts. By default a stage runs after the
+// stage before it in a sequence, and parallel and matrix children start together; a stage's dependsOn extension
+// replaces that default with the stages it names. It returns an error if the dependencies form a cycle.
+func (strct *Plan) Needs() (map[string][]string, error) {
+	// exits are the leaf stages which have finished once a stage has.
+	exits := map[string][]string{}
+	var index func(s *Stage) []string
+	index = func(s *Stage) []string {
+		var out []string
+		switch {
+		case len(s.Children) == 0:
+			out = []string{s.ID}
+		case s.ChildMode == Sequential:
+			for _, c := range s.Children {
+				out = index(c)
+			}
+		default:
+			for _, c := range s.Children {
+				out = append(out, index(c)...)
+			}
+		}
+		exits[s.ID] = out
+		return out
+	}
+	for _, s := range strct.Stages {
+		index(s)
+	}
+
+	needs := map[string][]string{}
+	var visit func(s *Stage, after []string)
+	visit = func(s *Stage, after []string) {
+		if s.DependsOn != nil {
+			after = nil
+			for _, id := range s.DependsOn {
+				after = append(after, exits[id]...)
+			}
+		}
+		if len(s.Children) == 0 {
+			needs[s.ID] = unique(after)
+			return
+		}
+		for _, c := range s.Children {
+			visit(c, after)
+			if s.ChildMode == Sequential {
+				after = exits[c.ID]
+			}
+		}
+	}
+	var after []string
+	for _, s := range strct.Stages {
+		visit(s, after)
+		after = exits[s.ID]
+	}
+	if cycle := findCycle(needs); cycle != nil {
+		return nil, fmt.Errorf("stage dependencies form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return needs, nil
+}
+
+func unique(ids []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// findCycle returns the stages of a cycle in the graph, starting and ending with the same stage, or nil if there is
+// none
+func findCycle(needs map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			for i, s := range stack {
+				if s == id {
+					return append(append([]string{}, stack[i:]...), id)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, n := range needs[id] {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+	ids := make([]string, 0, len(needs))
+	for id := range needs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if cycle := visit(id); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
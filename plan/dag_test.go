@@ -0,0 +1,56 @@
+package plan
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeeds(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "extended", "dependsOn.json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, model.UnmarshalExtended(contents, root))
+	p, err := Build(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test", "Docs"}, p.Find("Deploy").DependsOn)
+
+	needs, err := p.Needs()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"Build":     {},
+		"Test/Unit": {"Build"},
+		"Test/Lint": {"Build"},
+		"Docs":      {"Build"},
+		"Deploy":    {"Docs", "Test/Lint", "Test/Unit"},
+	}, needs)
+
+	p, err = Build(loadRoot(t, "parallel/parallelStagesGroupsAndStages"))
+	require.NoError(t, err)
+	needs, err = p.Needs()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"foo/first":               {},
+		"foo/second/inner-first":  {},
+		"foo/second/inner-second": {"foo/second/inner-first"},
+	}, needs)
+}
+
+func TestNeedsErrors(t *testing.T) {
+	build := stepsStage("Build", "sh")
+	build.DependsOn = []string{"Deploy"}
+	deploy := stepsStage("Deploy", "sh")
+	root := &model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{build, deploy}}}
+	p, err := Build(root)
+	require.NoError(t, err)
+	_, err = p.Needs()
+	assert.EqualError(t, err, "stage dependencies form a cycle: Build -> Deploy -> Build")
+
+	build.DependsOn = []string{"Nope"}
+	_, err = Build(root)
+	assert.EqualError(t, err, `stage "Build" depends on unknown stage "Nope"`)
+}
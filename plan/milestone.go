@@ -0,0 +1,64 @@
+package plan
+
+// Milestone is a milestone step. Builds pass milestones in order: when a build passes one, older builds which haven't
+// passed it yet are aborted, so a superseded build never runs what follows it.
+type Milestone struct {
+	// Stage is the ID of the stage the step is in.
+	Stage string `json:"stage"`
+	// Ordinal is the step's ordinal argument, or if it has none, one more than the previous milestone's, which is how
+	// Jenkins numbers them.
+	Ordinal int `json:"ordinal"`
+	// Explicit is true if the step has an ordinal argument.
+	Explicit bool   `json:"explicit,omitempty"`
+	Label    string `json:"label,omitempty"`
+	// Concurrent is true if the step is in a parallel branch or matrix cell, where Jenkins doesn't allow milestones.
+	Concurrent bool `json:"concurrent,omitempty"`
+	// Valid is false if the step's arguments couldn't be interpreted, in which case Ordinal is a guess.
+	Valid bool  `json:"valid"`
+	Step  *Step `json:"-"`
+}
+
+// milestoneArgs is the arguments of the milestone step
+type milestoneArgs struct {
+	Ordinal *int   `jenkins:"ordinal,default"`
+	Label   string `jenkins:"label"`
+}
+
+// Milestones returns the milestone steps in the plan, in the order a build reaches them: each stage's steps, then its
+// children, then its post blocks.
+func (strct *Plan) Milestones() []*Milestone {
+	var out []*Milestone
+	previous := 0
+	var visitSteps func(s *Stage, steps []*Step, concurrent bool)
+	visitSteps = func(s *Stage, steps []*Step, concurrent bool) {
+		for _, st := range steps {
+			if st.Name == "milestone" && st.Source != nil && st.Source.Step != nil {
+				m := &Milestone{Stage: s.ID, Ordinal: previous + 1, Concurrent: concurrent, Valid: true, Step: st}
+				var args milestoneArgs
+				if err := st.Source.Step.Arguments.Bind(&args); err != nil {
+					m.Valid = false
+				} else if args.Ordinal != nil {
+					m.Ordinal, m.Explicit = *args.Ordinal, true
+				}
+				m.Label = args.Label
+				previous = m.Ordinal
+				out = append(out, m)
+			}
+			visitSteps(s, st.Children, concurrent)
+		}
+	}
+	var visitStage func(s *Stage, concurrent bool)
+	visitStage = func(s *Stage, concurrent bool) {
+		visitSteps(s, s.Steps, concurrent)
+		for _, c := range s.Children {
+			visitStage(c, concurrent || s.ChildMode == Parallel || s.ChildMode == Matrix)
+		}
+		for _, b := range s.Post {
+			visitSteps(s, b.Steps, concurrent)
+		}
+	}
+	for _, s := range strct.Stages {
+		visitStage(s, false)
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+// Package plan builds an execution plan from a pipeline model: the stages that will run, in order, with their
+// effective agent and environment resolved. It is the intermediate representation converters to other CI systems
+// are written against.
+package plan
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Mode describes how a stage's children are run
+type Mode string
+
+const (
+	// Sequential children run one after the other
+	Sequential Mode = "sequential"
+	// Parallel children run concurrently
+	Parallel Mode = "parallel"
+	// Matrix children run once per matrix cell, with cells running concurrently
+	Matrix Mode = "matrix"
+)
+
+// Plan is the execution plan for a pipeline
+type Plan struct {
+	Agent       *Agent              `json:"agent,omitempty"`
+	Environment []*EnvVar           `json:"environment,omitempty"`
+	Options     []*model.MethodCall `json:"-"`
+	// Settings are the pipeline options which change how it runs.
+	Settings *Settings `json:"settings,omitempty"`
+	// Concurrency is the constraints on running builds of the pipeline concurrently, from its options.
+	Concurrency *Concurrency `json:"concurrency,omitempty"`
+	Stages      []*Stage     `json:"stages"`
+	Post        []*PostBlock `json:"post,omitempty"`
+	// Timing is the observed duration of the whole pipeline, if attached with AttachDurations.
+	Timing *Timing     `json:"timing,omitempty"`
+	Source *model.Root `json:"-"`
+}
+
+// Stage is a single stage of the plan. A stage either has Steps, or Children run according to ChildMode.
+type Stage struct {
+	// ID is the stage's path as given by ID, unique within the plan
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Path []string `json:"path"`
+	// Agent is the effective agent the stage runs on, inherited from its enclosing stages or the pipeline if it
+	// doesn't declare its own.
+	Agent *Agent `json:"agent,omitempty"`
+	// Environment is the effective environment of the stage, with inherited entries first.
+	Environment []*EnvVar     `json:"environment,omitempty"`
+	Steps       []*Step       `json:"steps,omitempty"`
+	Children    []*Stage      `json:"children,omitempty"`
+	ChildMode   Mode          `json:"childMode,omitempty"`
+	Axes        []*model.Axis `json:"-"`
+	FailFast    bool          `json:"failFast,omitempty"`
+	Post        []*PostBlock  `json:"post,omitempty"`
+	// MatrixPost is the post section of a matrix stage's matrix, run for each cell, where Post is run once for the
+	// stage.
+	MatrixPost []*PostBlock `json:"matrixPost,omitempty"`
+	// DependsOn are the IDs of the stages named by the stage's dependsOn extension, if any.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Timing is the observed duration of the stage, if attached with AttachDurations.
+	Timing *Timing `json:"timing,omitempty"`
+	// Concurrency is the locks and throttles the stage holds, from its options and steps.
+	Concurrency *Concurrency `json:"concurrency,omitempty"`
+	// Resources are the CPU and memory the stage needs, from resource annotations or a kubernetes agent, inherited
+	// from its enclosing stages or the pipeline like its agent.
+	Resources *Resources `json:"resources,omitempty"`
+
+	When    *model.When         `json:"-"`
+	Input   *model.Input        `json:"-"`
+	Options []*model.MethodCall `json:"-"`
+	Source  *model.Stage        `json:"-"`
+}
+
+// Agent is a resolved agent
+type Agent struct {
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+	Image string `json:"image,omitempty"`
+	// Inherited is true if the agent was declared by an enclosing stage or the pipeline rather than the stage itself.
+	Inherited bool         `json:"inherited,omitempty"`
+	Source    *model.Agent `json:"-"`
+}
+
+// EnvVar is a single environment variable. Exactly one of Value or Credential is set.
+type EnvVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	// Literal is false if Value is a Groovy expression rather than a constant.
+	Literal bool `json:"literal,omitempty"`
+	// Credential is the credential ID for values bound with credentials().
+	Credential string `json:"credential,omitempty"`
+	// CredentialPart is the part of the credential the variable holds, once expanded with ExpandCredentials.
+	CredentialPart string `json:"credentialPart,omitempty"`
+}
+
+// Step is a single step. Tree steps have Children.
+type Step struct {
+	Name string `json:"name"`
+	// Script is the script body for sh, bat, powershell, and pwsh steps.
+	Script   string         `json:"script,omitempty"`
+	Children []*Step        `json:"children,omitempty"`
+	Source   *model.AnyStep `json:"-"`
+}
+
+// PostBlock is the steps run for a post condition
+type PostBlock struct {
+	Condition string  `json:"condition"`
+	Steps     []*Step `json:"steps"`
+}
+
+var scriptSteps = map[string]bool{"sh": true, "bat": true, "powershell": true, "pwsh": true}
+
+// Build creates the execution plan for the given pipeline.
+func Build(root *model.Root) (*Plan, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	p := root.Pipeline
+	out := &Plan{
+		Agent:       resolveAgent(p.Agent),
+		Environment: envVars(nil, p.Environment),
+		Post:        postBlocks(p.Post),
+		Source:      root,
+		Stages:      []*Stage{},
+	}
+	if p.Options != nil {
+		out.Options = p.Options.Options
+	}
+	out.Settings = ParseSettings(out.Options)
+	out.Concurrency = ParseConcurrency(out.Options, nil)
+	resources := resolveResources(nil, p.Agent, p.Annotations)
+	for _, s := range p.Stages {
+		out.Stages = append(out.Stages, buildStage(s, nil, out.Agent, out.Environment, resources))
+	}
+	if err := resolveDependencies(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func buildStage(s *model.Stage, parentPath []string, parentAgent *Agent, parentEnv []*EnvVar,
+	parentResources *Resources) *Stage {
+	path := append(append([]string{}, parentPath...), s.Name)
+	st := &Stage{
+		ID:          ID(path),
+		Name:        s.Name,
+		Path:        path,
+		Agent:       inherit(parentAgent),
+		Environment: envVars(parentEnv, s.Environment),
+		FailFast:    s.FailFast,
+		Post:        postBlocks(s.Post),
+		When:        s.When,
+		Input:       s.Input,
+		Source:      s,
+	}
+	if s.Agent != nil {
+		st.Agent = resolveAgent(s.Agent)
+	}
+	if s.Options != nil {
+		st.Options = s.Options.Options
+	}
+	for _, b := range s.Branches {
+		if b != nil {
+			st.Steps = append(st.Steps, steps(b.Steps)...)
+		}
+	}
+	st.Concurrency = ParseConcurrency(st.Options, st.Steps)
+
+	var children []*model.Stage
+	switch {
+	case len(s.Stages) > 0:
+		st.ChildMode, children = Sequential, s.Stages
+	case len(s.Parallel) > 0:
+		st.ChildMode, children = Parallel, s.Parallel
+	case s.Matrix != nil:
+		st.ChildMode, children, st.Axes = Matrix, s.Matrix.Stages, s.Matrix.Axes
+		st.MatrixPost = postBlocks(s.Matrix.Post)
+		if s.Matrix.Agent != nil {
+			st.Agent = resolveAgent(s.Matrix.Agent)
+		}
+		st.Environment = envVars(st.Environment, s.Matrix.Environment)
+	}
+	agent := s.Agent
+	if s.Matrix != nil && s.Matrix.Agent != nil {
+		agent = s.Matrix.Agent
+	}
+	st.Resources = resolveResources(parentResources, agent, s.Annotations)
+	for _, c := range children {
+		st.Children = append(st.Children, buildStage(c, path, st.Agent, st.Environment, st.Resources))
+	}
+	return st
+}
+
+// idEscaper escapes stage names as JSON pointers escape property names, so names containing "/" can't make IDs which
+// collide
+var idEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// ID returns the ID of the stage with the given path: the stage names, with "~" written "~0" and "/" written "~1",
+// joined with "/". A stage named "a/b" has the ID "a~1b", which is different from that of stage "b" inside "a".
+func ID(path []string) string {
+	escaped := make([]string, len(path))
+	for i, name := range path {
+		escaped[i] = idEscaper.Replace(name)
+	}
+	return strings.Join(escaped, "/")
+}
+
+func inherit(a *Agent) *Agent {
+	if a == nil {
+		return nil
+	}
+	inherited := *a
+	inherited.Inherited = true
+	return &inherited
+}
+
+func resolveAgent(a *model.Agent) *Agent {
+	if a == nil {
+		return nil
+	}
+	out := &Agent{Type: a.Type, Source: a}
+	switch a.Type {
+	case "label":
+		out.Label = a.Argument.String()
+	case "docker":
+		out.Image = a.Argument.String()
+	}
+	for _, arg := range a.Arguments {
+		if arg == nil || arg.Value == nil || arg.Value.Raw == nil {
+			continue
+		}
+		switch arg.Key {
+		case "label":
+			out.Label = arg.Value.Raw.String()
+		case "image":
+			out.Image = arg.Value.Raw.String()
+		}
+	}
+	return out
+}
+
+func envVars(parent []*EnvVar, entries []*model.EnvironmentEntry) []*EnvVar {
+	out := append([]*EnvVar{}, parent...)
+	for _, e := range entries {
+		if e == nil || e.Value == nil {
+			continue
+		}
+		v := &EnvVar{Key: e.Key}
+		if id, ok := e.Value.Credentials(); ok {
+			v.Credential = id
+		} else if e.Value.Function != nil {
+			// Other functions, and credentials with computed IDs, can't be resolved, so keep them as expressions.
+			v.Value = e.Value.Source()
+		} else if e.Value.Single != nil {
+			v.Value = e.Value.Single.String()
+			v.Literal = e.Value.Single.IsLiteral
+		}
+		// Later entries override earlier ones of the same name.
+		for i, existing := range out {
+			if existing.Key == v.Key {
+				out = append(out[:i], out[i+1:]...)
+				break
+			}
+		}
+		out = append(out, v)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func steps(in []*model.AnyStep) []*Step {
+	var out []*Step
+	for _, s := range in {
+		switch {
+		case s == nil:
+		case s.Step != nil:
+			st := &Step{Name: s.Step.Name, Source: s}
+			if scriptSteps[s.Step.Name] {
+				st.Script = s.Step.Arguments.Get("script").String()
+			}
+			out = append(out, st)
+		case s.Tree != nil:
+			out = append(out, &Step{Name: s.Tree.Name, Children: steps(s.Tree.Children), Source: s})
+		}
+	}
+	return out
+}
+
+func postBlocks(post *model.Post) []*PostBlock {
+	if post == nil {
+		return nil
+	}
+	var out []*PostBlock
+	for _, c := range post.Conditions {
+		if c == nil {
+			continue
+		}
+		b := &PostBlock{Condition: c.Condition}
+		if c.Branch != nil {
+			b.Steps = steps(c.Branch.Steps)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// Leaves returns the stages of the plan which have steps rather than children, in execution order.
+func (strct *Plan) Leaves() []*Stage {
+	var out []*Stage
+	var visit func([]*Stage)
+	visit = func(stages []*Stage) {
+		for _, s := range stages {
+			if len(s.Children) == 0 {
+				out = append(out, s)
+			}
+			visit(s.Children)
+		}
+	}
+	visit(strct.Stages)
+	return out
+}
+
+// Find returns the stage with the given ID, or nil if there is none.
+func (strct *Plan) Find(id string) *Stage {
+	var found *Stage
+	var visit func([]*Stage)
+	visit = func(stages []*Stage) {
+		for _, s := range stages {
+			if found != nil {
+				return
+			}
+			if s.ID == id {
+				found = s
+				return
+			}
+			visit(s.Children)
+		}
+	}
+	visit(strct.Stages)
+	return found
+}
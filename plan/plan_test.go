@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRoot(t *testing.T, name string) *model.Root {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	return root
+}
+
+func TestBuildInheritsAgents(t *testing.T) {
+	p, err := Build(loadRoot(t, "agent/agentOnGroup"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "some-label", p.Agent.Label)
+	require.Len(t, p.Stages, 1)
+	foo := p.Stages[0]
+	assert.Equal(t, Parallel, foo.ChildMode)
+	assert.True(t, foo.Agent.Inherited)
+
+	var ids []string
+	for _, s := range p.Leaves() {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"foo/solo", "foo/other-agent/first-other", "foo/other-agent/second-other"}, ids)
+
+	assert.Equal(t, "other-label", p.Find("foo/other-agent").Agent.Label)
+	assert.False(t, p.Find("foo/other-agent").Agent.Inherited)
+	assert.Equal(t, "other-label", p.Find("foo/other-agent/first-other").Agent.Label)
+	assert.True(t, p.Find("foo/other-agent/first-other").Agent.Inherited)
+	assert.Equal(t, "some-label", p.Find("foo/other-agent/second-other").Agent.Label)
+	assert.Nil(t, p.Find("missing"))
+}
+
+func TestBuildEnvironment(t *testing.T) {
+	p, err := Build(loadRoot(t, "environment/usernamePassword"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*EnvVar{{Key: "FOO", Credential: "FOOcredentials"}}, p.Environment)
+	assert.Equal(t, p.Environment, p.Stages[0].Environment)
+
+	steps := p.Stages[0].Steps
+	require.Len(t, steps, 7)
+	assert.Equal(t, "dir", steps[3].Name)
+	require.Len(t, steps[3].Children, 1)
+	assert.Equal(t, "writeFile", steps[3].Children[0].Name)
+}
+
+func TestBuildEnvironmentOverrides(t *testing.T) {
+	p, err := Build(loadRoot(t, "environment/environmentInStage"))
+	require.NoError(t, err)
+
+	for _, s := range p.Leaves() {
+		seen := map[string]bool{}
+		for _, e := range s.Environment {
+			assert.False(t, seen[e.Key], "duplicate key %s in %s", e.Key, s.ID)
+			seen[e.Key] = true
+		}
+	}
+}
+
+func TestBuildIDs(t *testing.T) {
+	steps := []*model.Branch{{Name: "default", Steps: []*model.AnyStep{}}}
+	p, err := Build(&model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{
+		{Name: "a/b", Branches: steps},
+		{Name: "a", Stages: []*model.Stage{{Name: "b", Branches: steps}, {Name: "~1", Branches: steps}}},
+	}}})
+	require.NoError(t, err)
+	assert.Equal(t, "a~1b", p.Stages[0].ID)
+	assert.Equal(t, "a/b", p.Stages[1].Children[0].ID)
+	assert.Equal(t, "a/~01", p.Stages[1].Children[1].ID)
+	assert.Equal(t, p.Stages[0], p.Find("a~1b"))
+	assert.Equal(t, p.Stages[1].Children[0], p.Find(ID([]string{"a", "b"})))
+}
+
+func TestBuildMatrixPost(t *testing.T) {
+	echo := func(message string) *model.Branch {
+		arg := &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: &message}}
+		return &model.Branch{Name: "default", Steps: []*model.AnyStep{
+			model.NewAnyStep(&model.Step{Name: "echo", Arguments: model.NewSingleArgument(arg)}),
+		}}
+	}
+	p, err := Build(&model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{{
+		Name: "Test",
+		Post: &model.Post{Conditions: []*model.BuildCondition{{Condition: "always", Branch: echo("stage")}}},
+		Matrix: &model.Matrix{
+			Stages: []*model.Stage{{Name: "Unit", Branches: []*model.Branch{echo("unit")}}},
+			Post:   &model.Post{Conditions: []*model.BuildCondition{{Condition: "failure", Branch: echo("cell")}}},
+		},
+	}}}})
+	require.NoError(t, err)
+	test := p.Stages[0]
+	require.Len(t, test.Post, 1)
+	assert.Equal(t, "always", test.Post[0].Condition)
+	require.Len(t, test.MatrixPost, 1)
+	assert.Equal(t, "failure", test.MatrixPost[0].Condition)
+	assert.Equal(t, "echo", test.MatrixPost[0].Steps[0].Name)
+}
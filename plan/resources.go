@@ -0,0 +1,96 @@
+package plan
+
+import (
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Annotations giving the resources a stage needs, for targets which run stages in containers. Their values are
+// Kubernetes quantities, such as "500m" or "2Gi". Like agents, annotations on the pipeline or a stage apply to its
+// nested stages too, unless they declare their own agent.
+const (
+	AnnotationCPURequest    = "resources.requests.cpu"
+	AnnotationCPULimit      = "resources.limits.cpu"
+	AnnotationMemoryRequest = "resources.requests.memory"
+	AnnotationMemoryLimit   = "resources.limits.memory"
+)
+
+// Resources are the CPU and memory a stage requests and is limited to
+type Resources struct {
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
+// CPU returns the CPU limit, or the request if there's no limit, for targets which only have one setting.
+func (strct *Resources) CPU() string {
+	if strct == nil {
+		return ""
+	}
+	if strct.CPULimit != "" {
+		return strct.CPULimit
+	}
+	return strct.CPURequest
+}
+
+// Memory returns the memory limit, or the request if there's no limit, for targets which only have one setting.
+func (strct *Resources) Memory() string {
+	if strct == nil {
+		return ""
+	}
+	if strct.MemoryLimit != "" {
+		return strct.MemoryLimit
+	}
+	return strct.MemoryRequest
+}
+
+// kubernetesResources are the arguments of kubernetes agents, and of their containerTemplate, giving the resources
+// of the agent's container
+var kubernetesResources = map[string]func(r *Resources) *string{
+	"resourceRequestCpu":    func(r *Resources) *string { return &r.CPURequest },
+	"resourceLimitCpu":      func(r *Resources) *string { return &r.CPULimit },
+	"resourceRequestMemory": func(r *Resources) *string { return &r.MemoryRequest },
+	"resourceLimitMemory":   func(r *Resources) *string { return &r.MemoryLimit },
+}
+
+// resolveResources returns the resources of a stage or pipeline: those of the agent it declares, or of its parent if
+// it doesn't declare one, overridden by its annotations. It returns nil if there are none.
+func resolveResources(parent *Resources, a *model.Agent, annotations model.Annotations) *Resources {
+	out := &Resources{}
+	switch {
+	case a != nil && a.Type == "kubernetes":
+		agentResources(out, a.Arguments)
+	case a == nil && parent != nil:
+		*out = *parent
+	}
+	for key, field := range map[string]*string{
+		AnnotationCPURequest:    &out.CPURequest,
+		AnnotationCPULimit:      &out.CPULimit,
+		AnnotationMemoryRequest: &out.MemoryRequest,
+		AnnotationMemoryLimit:   &out.MemoryLimit,
+	} {
+		if v := annotations.Get(key); v != "" {
+			*field = v
+		}
+	}
+	if *out == (Resources{}) {
+		return nil
+	}
+	return out
+}
+
+func agentResources(r *Resources, args []*model.MapArgumentValue) {
+	for _, arg := range args {
+		if arg == nil || arg.Value == nil {
+			continue
+		}
+		if field, ok := kubernetesResources[arg.Key]; ok && arg.Value.Raw != nil && arg.Value.Raw.IsLiteral {
+			if v := arg.Value.Raw.String(); v != "" {
+				*field(r) = v
+			}
+		}
+		if arg.Key == "containerTemplate" {
+			agentResources(r, arg.Value.List)
+		}
+	}
+}
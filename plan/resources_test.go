@@ -0,0 +1,56 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildResources(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(`pipeline {
+  agent {
+    kubernetes {
+      yamlFile 'pod.yaml'
+      containerTemplate {
+        name 'build'
+        image 'maven:3'
+        resourceRequestCpu '500m'
+        resourceLimitMemory '1Gi'
+      }
+    }
+  }
+  stages {
+    stage('Build') {
+      steps { sh 'mvn package' }
+    }
+    stage('Tests') {
+      stages {
+        stage('Integration') {
+          steps { sh 'mvn verify' }
+        }
+      }
+    }
+    stage('Docs') {
+      agent { label 'docs' }
+      steps { sh 'make docs' }
+    }
+  }
+}`))
+	require.NoError(t, err)
+	tests := root.Pipeline.Stages[1]
+	tests.Annotations.Set(AnnotationMemoryLimit, "4Gi")
+	tests.Annotations.Set(AnnotationCPULimit, "2")
+
+	p, err := Build(root)
+	require.NoError(t, err)
+	agentResources := &Resources{CPURequest: "500m", MemoryLimit: "1Gi"}
+	assert.Equal(t, agentResources, p.Find("Build").Resources)
+	assert.Equal(t, &Resources{CPURequest: "500m", CPULimit: "2", MemoryLimit: "4Gi"}, p.Find("Tests/Integration").Resources)
+	assert.Nil(t, p.Find("Docs").Resources, "stages with their own agent don't inherit resources")
+	assert.Equal(t, "2", p.Find("Tests").Resources.CPU())
+	assert.Equal(t, "500m", p.Find("Build").Resources.CPU())
+	assert.Equal(t, "", p.Find("Docs").Resources.Memory())
+}
@@ -0,0 +1,57 @@
+package plan
+
+import (
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// RestartPoint is a stage a completed build can be restarted from, using Declarative's "Restart from Stage"
+type RestartPoint struct {
+	Stage *Stage `json:"-"`
+	ID    string `json:"id"`
+	// Skipped are the IDs of the stages which are skipped when restarting from this one.
+	Skipped []string `json:"skipped,omitempty"`
+	// Caveats describe why restarting from the stage may not behave as the original build did.
+	Caveats []string `json:"caveats,omitempty"`
+}
+
+// RestartableStages returns the stages a build of the pipeline can be restarted from. Declarative only allows
+// restarting from top-level stages; stages nested in sequential, parallel, or matrix stages are restarted with their
+// top-level stage.
+func RestartableStages(root *model.Root) ([]*RestartPoint, error) {
+	p, err := Build(root)
+	if err != nil {
+		return nil, err
+	}
+	var out []*RestartPoint
+	var skipped []string
+	for _, s := range p.Stages {
+		r := &RestartPoint{Stage: s, ID: s.ID, Skipped: append([]string{}, skipped...)}
+		if len(skipped) > 0 && p.Settings.PreserveStashes == 0 && unstashes(s) {
+			r.Caveats = append(r.Caveats, "the stage unstashes files, which are only kept for restarts with the "+
+				"preserveStashes option")
+		}
+		out = append(out, r)
+		skipped = append(skipped, s.ID)
+	}
+	return out, nil
+}
+
+func unstashes(s *Stage) bool {
+	found := false
+	var visit func(steps []*Step)
+	visit = func(steps []*Step) {
+		for _, st := range steps {
+			if st.Name == "unstash" {
+				found = true
+			}
+			visit(st.Children)
+		}
+	}
+	visit(s.Steps)
+	for _, c := range s.Children {
+		if unstashes(c) {
+			return true
+		}
+	}
+	return found
+}
@@ -0,0 +1,45 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stepsStage(name string, stepNames ...string) *model.Stage {
+	var steps []*model.AnyStep
+	for _, n := range stepNames {
+		steps = append(steps, &model.AnyStep{Step: &model.Step{Name: n}})
+	}
+	return &model.Stage{Name: name, Branches: []*model.Branch{{Name: "default", Steps: steps}}}
+}
+
+func TestRestartableStages(t *testing.T) {
+	root := &model.Root{Pipeline: &model.Pipeline{
+		Agent: &model.Agent{Type: "any"},
+		Stages: []*model.Stage{
+			stepsStage("Build", "sh", "stash"),
+			{Name: "Test", Parallel: []*model.Stage{stepsStage("Unit", "unstash", "sh"), stepsStage("Lint", "sh")}},
+			stepsStage("Deploy", "sh"),
+		},
+	}}
+	points, err := RestartableStages(root)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, &RestartPoint{Stage: points[0].Stage, ID: "Build", Skipped: []string{}}, points[0])
+	assert.Equal(t, []string{"Build"}, points[1].Skipped)
+	assert.Equal(t, []string{"the stage unstashes files, which are only kept for restarts with the preserveStashes option"},
+		points[1].Caveats)
+	assert.Equal(t, []string{"Build", "Test"}, points[2].Skipped)
+	assert.Empty(t, points[2].Caveats)
+
+	root.Pipeline.Options = &model.Options{Options: []*model.MethodCall{{Name: "preserveStashes"}}}
+	points, err = RestartableStages(root)
+	require.NoError(t, err)
+	assert.Empty(t, points[1].Caveats)
+
+	_, err = RestartableStages(&model.Root{})
+	assert.Error(t, err)
+}
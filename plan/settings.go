@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Durability hints accepted by the durabilityHint option
+const (
+	MaxSurvivability     = "MAX_SURVIVABILITY"
+	SurvivableNonatomic  = "SURVIVABLE_NONATOMIC"
+	PerformanceOptimized = "PERFORMANCE_OPTIMIZED"
+)
+
+// Settings are the pipeline options which change how the pipeline runs, interpreted from the options directive.
+// Options whose arguments aren't literals are reported in Warnings and otherwise ignored.
+type Settings struct {
+	// PreserveStashes is the number of completed builds whose stashes are kept for restarting stages, or zero if
+	// stashes are discarded when the build completes.
+	PreserveStashes int `json:"preserveStashes,omitempty"`
+	// DurabilityHint is the durability setting, such as PerformanceOptimized, or empty for Jenkins' default.
+	DurabilityHint string `json:"durabilityHint,omitempty"`
+	// QuietPeriod is how long a triggered build waits in the queue, if set.
+	QuietPeriod *time.Duration `json:"quietPeriod,omitempty"`
+	// CheckoutToSubdirectory is the workspace subdirectory the repository is checked out to, or empty for the
+	// workspace itself.
+	CheckoutToSubdirectory string   `json:"checkoutToSubdirectory,omitempty"`
+	Warnings               []string `json:"warnings,omitempty"`
+}
+
+// Durable returns whether the pipeline's state is persisted after every step, so a build resumes exactly where it
+// was after a controller restart. Only PERFORMANCE_OPTIMIZED builds may not resume.
+func (strct *Settings) Durable() bool {
+	return strct.DurabilityHint != PerformanceOptimized
+}
+
+// ParseSettings interprets pipeline options.
+func ParseSettings(options []*model.MethodCall) *Settings {
+	s := &Settings{}
+	for _, o := range options {
+		if o == nil {
+			continue
+		}
+		switch o.Name {
+		case "preserveStashes":
+			s.PreserveStashes = 1
+			if v, ok := optionArg(o, "buildCount"); ok {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					s.warn(o, "buildCount must be a positive integer")
+					continue
+				}
+				s.PreserveStashes = n
+			} else if optionHasArg(o, "buildCount") {
+				s.warn(o, "buildCount is not a literal")
+			}
+		case "durabilityHint":
+			v, ok := optionArg(o, "hint")
+			switch {
+			case !ok:
+				s.warn(o, "hint is not a literal")
+			case v == MaxSurvivability || v == SurvivableNonatomic || v == PerformanceOptimized:
+				s.DurabilityHint = v
+			default:
+				s.warn(o, "unknown hint %q", v)
+			}
+		case "quietPeriod":
+			v, ok := optionArg(o, "quietPeriod")
+			n, err := strconv.Atoi(v)
+			if !ok || err != nil || n < 0 {
+				s.warn(o, "quietPeriod must be a literal number of seconds")
+				continue
+			}
+			d := time.Duration(n) * time.Second
+			s.QuietPeriod = &d
+		case "checkoutToSubdirectory":
+			v, ok := optionArg(o, "subdirectory")
+			if !ok {
+				s.warn(o, "subdirectory is not a literal")
+				continue
+			}
+			s.CheckoutToSubdirectory = v
+		}
+	}
+	return s
+}
+
+func (strct *Settings) warn(o *model.MethodCall, format string, args ...interface{}) {
+	strct.Warnings = append(strct.Warnings, fmt.Sprintf("option %s: %s", o.Name, fmt.Sprintf(format, args...)))
+}
+
+// optionArg returns the literal value of an option's named argument, or of its single unnamed argument, which is
+// the default parameter of the options interpreted here.
+func optionArg(o *model.MethodCall, key string) (string, bool) {
+	for _, a := range o.Arguments {
+		var v *model.ValueOrMethodCall
+		switch {
+		case a == nil:
+		case a.WithKey != nil && a.WithKey.Key != "":
+			if a.WithKey.Key == key {
+				v = a.WithKey.Value
+			}
+		default:
+			v = a.Single
+		}
+		if v != nil && v.Single != nil {
+			if !v.Single.IsLiteral {
+				return "", false
+			}
+			return v.Single.String(), true
+		}
+	}
+	return "", false
+}
+
+func optionHasArg(o *model.MethodCall, key string) bool {
+	for _, a := range o.Arguments {
+		if a != nil && (a.WithKey == nil || a.WithKey.Key == "" || a.WithKey.Key == key) {
+			return true
+		}
+	}
+	return false
+}
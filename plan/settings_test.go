@@ -0,0 +1,51 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseOptions(t *testing.T, src string) []*model.MethodCall {
+	options := &model.Options{}
+	require.NoError(t, json.Unmarshal([]byte(src), options))
+	return options.Options
+}
+
+func TestParseSettings(t *testing.T) {
+	s := ParseSettings(parseOptions(t, `{"options": [
+		{"name": "preserveStashes", "arguments": [{"key": "buildCount", "value": {"isLiteral": true, "value": 5}}]},
+		{"name": "durabilityHint", "arguments": [{"isLiteral": true, "value": "PERFORMANCE_OPTIMIZED"}]},
+		{"name": "quietPeriod", "arguments": [{"isLiteral": true, "value": 30}]},
+		{"name": "checkoutToSubdirectory", "arguments": [{"isLiteral": true, "value": "src"}]},
+		{"name": "timestamps"}
+	]}`))
+	quiet := 30 * time.Second
+	assert.Equal(t, &Settings{PreserveStashes: 5, DurabilityHint: PerformanceOptimized, QuietPeriod: &quiet,
+		CheckoutToSubdirectory: "src"}, s)
+	assert.False(t, s.Durable())
+
+	s = ParseSettings(parseOptions(t, `{"options": [{"name": "preserveStashes"}]}`))
+	assert.Equal(t, &Settings{PreserveStashes: 1}, s)
+	assert.True(t, s.Durable())
+}
+
+func TestParseSettingsWarnings(t *testing.T) {
+	s := ParseSettings(parseOptions(t, `{"options": [
+		{"name": "preserveStashes", "arguments": [{"key": "buildCount", "value": {"isLiteral": false, "value": "params.KEEP"}}]},
+		{"name": "durabilityHint", "arguments": [{"key": "hint", "value": {"isLiteral": true, "value": "FAST"}}]},
+		{"name": "quietPeriod", "arguments": [{"isLiteral": true, "value": "soon"}]}
+	]}`))
+	assert.Equal(t, []string{
+		"option preserveStashes: buildCount is not a literal",
+		`option durabilityHint: unknown hint "FAST"`,
+		"option quietPeriod: quietPeriod must be a literal number of seconds",
+	}, s.Warnings)
+	assert.Equal(t, 1, s.PreserveStashes)
+	assert.Empty(t, s.DurabilityHint)
+	assert.Nil(t, s.QuietPeriod)
+}
@@ -0,0 +1,140 @@
+package plan
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Timing summarises the observed durations of a stage, or of the whole pipeline, across past builds
+type Timing struct {
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P95     time.Duration `json:"p95"`
+	Max     time.Duration `json:"max"`
+}
+
+// NewTiming summarises observed durations, using nearest-rank percentiles. It returns nil if there are none.
+func NewTiming(durations []time.Duration) *Timing {
+	if len(durations) == 0 {
+		return nil
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+	return &Timing{Samples: len(sorted), P50: rank(0.5), P95: rank(0.95), Max: sorted[len(sorted)-1]}
+}
+
+// AttachDurations sets the Timing of the plan and its stages from observed durations, keyed by stage ID. The
+// duration of the whole pipeline is keyed by the empty string. Stages without observations are left as they are.
+func (strct *Plan) AttachDurations(observed map[string][]time.Duration) {
+	if t := NewTiming(observed[""]); t != nil {
+		strct.Timing = t
+	}
+	var visit func(stages []*Stage)
+	visit = func(stages []*Stage) {
+		for _, s := range stages {
+			if t := NewTiming(observed[s.ID]); t != nil {
+				s.Timing = t
+			}
+			visit(s.Children)
+		}
+	}
+	visit(strct.Stages)
+}
+
+// timeUnits are the java.util.concurrent.TimeUnit names accepted by the timeout option
+var timeUnits = map[string]time.Duration{
+	"NANOSECONDS":  time.Nanosecond,
+	"MICROSECONDS": time.Microsecond,
+	"MILLISECONDS": time.Millisecond,
+	"SECONDS":      time.Second,
+	"MINUTES":      time.Minute,
+	"HOURS":        time.Hour,
+	"DAYS":         24 * time.Hour,
+}
+
+// Timeout returns the duration of the pipeline's timeout option, and false if it has none or its duration isn't a
+// literal.
+func (strct *Plan) Timeout() (time.Duration, bool) {
+	return timeout(strct.Options)
+}
+
+// Timeout returns the duration of the stage's timeout option, and false if it has none or its duration isn't a
+// literal.
+func (strct *Stage) Timeout() (time.Duration, bool) {
+	return timeout(strct.Options)
+}
+
+func timeout(options []*model.MethodCall) (time.Duration, bool) {
+	var call *model.MethodCall
+	for _, o := range options {
+		if o != nil && o.Name == "timeout" {
+			call = o
+			break
+		}
+	}
+	if call == nil {
+		return 0, false
+	}
+	var amount, unit *model.RawArgument
+	for _, a := range call.Arguments {
+		switch {
+		case a == nil:
+		case a.WithKey != nil && a.WithKey.Key != "":
+			if a.WithKey.Value == nil {
+				continue
+			}
+			switch a.WithKey.Key {
+			case "time":
+				amount = a.WithKey.Value.Single
+			case "unit":
+				unit = a.WithKey.Value.Single
+			}
+		case a.Single != nil && amount == nil:
+			// timeout(5) passes the time as its only argument.
+			amount = a.Single.Single
+		}
+	}
+	return timeoutDuration(amount, unit)
+}
+
+// StepTimeout returns the duration of a timeout step's time limit, and false if it isn't a literal.
+func StepTimeout(args *model.ArgumentList) (time.Duration, bool) {
+	if args == nil {
+		return 0, false
+	}
+	var unit *model.RawArgument
+	if args.Single == nil {
+		unit = args.Get("unit")
+	}
+	return timeoutDuration(args.Get("time"), unit)
+}
+
+// timeoutDuration returns the duration of a timeout's time and unit arguments, defaulting to minutes
+func timeoutDuration(amount, unit *model.RawArgument) (time.Duration, bool) {
+	if amount == nil || !amount.IsLiteral {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(amount.String(), 64)
+	if err != nil {
+		return 0, false
+	}
+	scale := time.Minute
+	if unit != nil {
+		if !unit.IsLiteral {
+			return 0, false
+		}
+		var ok bool
+		if scale, ok = timeUnits[strings.ToUpper(unit.String())]; !ok {
+			return 0, false
+		}
+	}
+	return time.Duration(n * float64(scale)), true
+}
@@ -0,0 +1,51 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTiming(t *testing.T) {
+	var durations []time.Duration
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	assert.Equal(t, &Timing{Samples: 20, P50: 10 * time.Second, P95: 19 * time.Second, Max: 20 * time.Second},
+		NewTiming(durations))
+	assert.Equal(t, &Timing{Samples: 1, P50: time.Second, P95: time.Second, Max: time.Second},
+		NewTiming([]time.Duration{time.Second}))
+	assert.Nil(t, NewTiming(nil))
+}
+
+func TestTimeout(t *testing.T) {
+	p, err := Build(loadRoot(t, "options/simpleWrapper"))
+	require.NoError(t, err)
+	timeout, ok := p.Timeout()
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, timeout)
+	_, ok = p.Stages[0].Timeout()
+	assert.False(t, ok)
+
+	p, err = Build(loadRoot(t, "stageWrapper"))
+	require.NoError(t, err)
+	timeout, ok = p.Stages[0].Timeout()
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, timeout)
+}
+
+func TestAttachDurations(t *testing.T) {
+	p, err := Build(loadRoot(t, "agent/agentOnGroup"))
+	require.NoError(t, err)
+	leaf := p.Leaves()[0]
+	p.AttachDurations(map[string][]time.Duration{
+		"":       {time.Minute},
+		leaf.ID:  {time.Second, 3 * time.Second},
+		"nope/x": {time.Hour},
+	})
+	assert.Equal(t, &Timing{Samples: 1, P50: time.Minute, P95: time.Minute, Max: time.Minute}, p.Timing)
+	assert.Equal(t, &Timing{Samples: 2, P50: time.Second, P95: 3 * time.Second, Max: 3 * time.Second}, leaf.Timing)
+	assert.Nil(t, p.Stages[0].Timing)
+}
@@ -0,0 +1,209 @@
+// Package profiles generates several pipelines from one, such as a nightly and a release pipeline, by tagging the
+// stages, options, and triggers which belong only to some of them with the names of those profiles.
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// AnnotationProfiles is the annotation tagging a stage with the profiles it belongs to, separated by commas, such as
+// "nightly,release". A profile prefixed with "!", such as "!nightly", excludes the stage from that profile instead;
+// a stage tagged only with exclusions belongs to every other profile. Untagged stages belong to every profile.
+const AnnotationProfiles = "profiles"
+
+// optionPrefix and triggerPrefix prefix the annotations of pipelines and stages tagging their options and triggers,
+// which can't be annotated themselves, followed by the option or trigger's name
+const (
+	optionPrefix  = AnnotationProfiles + ".option."
+	triggerPrefix = AnnotationProfiles + ".trigger."
+)
+
+// Tag tags a stage or pipeline's annotations with profiles, replacing any it had.
+func Tag(a *model.Annotations, profiles ...string) {
+	a.Set(AnnotationProfiles, strings.Join(profiles, ","))
+}
+
+// TagOption tags the option with the given name, in the options of the pipeline or stage with the annotations, with
+// profiles.
+func TagOption(a *model.Annotations, option string, profiles ...string) {
+	a.Set(optionPrefix+option, strings.Join(profiles, ","))
+}
+
+// TagTrigger tags the pipeline trigger with the given name with profiles.
+func TagTrigger(a *model.Annotations, trigger string, profiles ...string) {
+	a.Set(triggerPrefix+trigger, strings.Join(profiles, ","))
+}
+
+// Names returns the profiles a pipeline's tags name, sorted.
+func Names(root *model.Root) []string {
+	found := map[string]bool{}
+	collect := func(a model.Annotations) {
+		for k, v := range a {
+			if k != AnnotationProfiles && !strings.HasPrefix(k, optionPrefix) && !strings.HasPrefix(k, triggerPrefix) {
+				continue
+			}
+			for _, p := range split(v) {
+				found[strings.TrimPrefix(p, "!")] = true
+			}
+		}
+	}
+	if root != nil && root.Pipeline != nil {
+		collect(root.Pipeline.Annotations)
+		eachStage(root.Pipeline.Stages, func(s *model.Stage) {
+			collect(s.Annotations)
+		})
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the pipeline for a profile: the stages, options, and triggers which belong to it, without their
+// profile tags. Stages left with no nested stages are dropped too. root isn't changed, though the result shares the
+// nodes it leaves as they were, such as steps, with it. It returns an error if no tag names the profile, or the
+// profile has no stages.
+func Select(root *model.Root, profile string) (*model.Root, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	if !contains(Names(root), profile) {
+		return nil, fmt.Errorf("no stage, option, or trigger is tagged with profile %s", profile)
+	}
+	p := *root.Pipeline
+	p.Options = options(p.Options, p.Annotations, profile)
+	if p.Triggers != nil {
+		triggers := &model.Triggers{}
+		for _, t := range p.Triggers.Triggers {
+			if t == nil || belongs(p.Annotations.Get(triggerPrefix+t.Name), profile) {
+				triggers.Triggers = append(triggers.Triggers, t)
+			}
+		}
+		p.Triggers = nil
+		if len(triggers.Triggers) > 0 {
+			p.Triggers = triggers
+		}
+	}
+	p.Annotations = untagged(p.Annotations)
+	p.Stages = stages(p.Stages, profile)
+	if len(p.Stages) == 0 {
+		return nil, fmt.Errorf("profile %s has no stages", profile)
+	}
+	out := &model.Root{Pipeline: &p}
+	return out, model.CheckInvariants(out)
+}
+
+// stages returns copies of the stages which belong to the profile, with their nested stages selected too
+func stages(in []*model.Stage, profile string) []*model.Stage {
+	var out []*model.Stage
+	for _, s := range in {
+		if s == nil || !belongs(s.Annotations.Get(AnnotationProfiles), profile) {
+			continue
+		}
+		c := *s
+		c.Options = options(c.Options, c.Annotations, profile)
+		c.Annotations = untagged(c.Annotations)
+		c.Stages = stages(s.Stages, profile)
+		c.Parallel = stages(s.Parallel, profile)
+		if s.Matrix != nil {
+			m := *s.Matrix
+			m.Stages = stages(s.Matrix.Stages, profile)
+			c.Matrix = &m
+		}
+		if len(s.Stages) > 0 && len(c.Stages) == 0 || len(s.Parallel) > 0 && len(c.Parallel) == 0 ||
+			s.Matrix != nil && len(c.Matrix.Stages) == 0 {
+			continue
+		}
+		out = append(out, &c)
+	}
+	return out
+}
+
+// options returns the options which belong to the profile, given the annotations of the pipeline or stage they're in
+func options(in *model.Options, a model.Annotations, profile string) *model.Options {
+	if in == nil {
+		return nil
+	}
+	out := &model.Options{}
+	for _, o := range in.Options {
+		if o == nil || belongs(a.Get(optionPrefix+o.Name), profile) {
+			out.Options = append(out.Options, o)
+		}
+	}
+	if len(out.Options) == 0 {
+		return nil
+	}
+	return out
+}
+
+// belongs returns whether something with the given tag belongs to the profile
+func belongs(tag string, profile string) bool {
+	profiles := split(tag)
+	if len(profiles) == 0 {
+		return true
+	}
+	included := true
+	for _, p := range profiles {
+		switch {
+		case p == profile:
+			return true
+		case p == "!"+profile:
+			return false
+		case !strings.HasPrefix(p, "!"):
+			// Tagged with other profiles, so it only belongs to those.
+			included = false
+		}
+	}
+	return included
+}
+
+// untagged returns a copy of annotations without the profile tags
+func untagged(a model.Annotations) model.Annotations {
+	var out model.Annotations
+	for k, v := range a {
+		if k != AnnotationProfiles && !strings.HasPrefix(k, optionPrefix) && !strings.HasPrefix(k, triggerPrefix) {
+			out.Set(k, v)
+		}
+	}
+	return out
+}
+
+func split(tag string) []string {
+	var out []string
+	for _, p := range strings.Split(tag, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func eachStage(stages []*model.Stage, fn func(*model.Stage)) {
+	for _, s := range stages {
+		if s == nil {
+			continue
+		}
+		fn(s)
+		eachStage(s.Stages, fn)
+		eachStage(s.Parallel, fn)
+		if s.Matrix != nil {
+			eachStage(s.Matrix.Stages, fn)
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package profiles
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `{"pipeline": {
+  "agent": {"type": "any"},
+  "options": {"options": [
+    {"name": "timeout", "arguments": [{"key": "time", "value": {"isLiteral": true, "value": 1}}]},
+    {"name": "disableConcurrentBuilds"}
+  ]},
+  "triggers": {"triggers": [{"name": "cron", "arguments": [{"isLiteral": true, "value": "H 2 * * *"}]}]},
+  "stages": [
+    {"name": "Build", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make"}}]}]}]},
+    {"name": "Checks", "parallel": [
+      {"name": "Unit", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make test"}}]}]}]},
+      {"name": "Soak", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make soak"}}]}]}]}
+    ]},
+    {"name": "Nightly", "stages": [
+      {"name": "Fuzz", "branches": [{"name": "default", "steps": [
+        {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make fuzz"}}]}]}]}
+    ]},
+    {"name": "Publish", "branches": [{"name": "default", "steps": [
+      {"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "make publish"}}]}]}]}
+  ]
+}}`
+
+func load(t *testing.T) *model.Root {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(source), root))
+	p := root.Pipeline
+	TagOption(&p.Annotations, "timeout", "release")
+	TagTrigger(&p.Annotations, "cron", "nightly")
+	p.Annotations.Set(model.AnnotationTicket, "CI-1")
+	Tag(&p.Stages[1].Parallel[1].Annotations, "nightly")
+	Tag(&p.Stages[2].Stages[0].Annotations, "nightly")
+	Tag(&p.Stages[3].Annotations, "!nightly")
+	return root
+}
+
+func stageNames(stages []*model.Stage) []string {
+	var out []string
+	eachStage(stages, func(s *model.Stage) {
+		out = append(out, s.Name)
+	})
+	return out
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, []string{"nightly", "release"}, Names(load(t)))
+	assert.Empty(t, Names(&model.Root{Pipeline: &model.Pipeline{}}))
+}
+
+func TestSelect(t *testing.T) {
+	root := load(t)
+	before, err := json.Marshal(root)
+	require.NoError(t, err)
+
+	release, err := Select(root, "release")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Build", "Checks", "Unit", "Publish"}, stageNames(release.Pipeline.Stages))
+	require.NotNil(t, release.Pipeline.Options)
+	assert.Len(t, release.Pipeline.Options.Options, 2)
+	assert.Nil(t, release.Pipeline.Triggers)
+	assert.Equal(t, model.Annotations{model.AnnotationTicket: "CI-1"}, release.Pipeline.Annotations)
+	assert.Empty(t, release.Pipeline.Stages[2].Annotations)
+
+	nightly, err := Select(root, "nightly")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Build", "Checks", "Unit", "Soak", "Nightly", "Fuzz"}, stageNames(nightly.Pipeline.Stages))
+	assert.Equal(t, "disableConcurrentBuilds", nightly.Pipeline.Options.Options[0].Name)
+	assert.Len(t, nightly.Pipeline.Options.Options, 1)
+	assert.Len(t, nightly.Pipeline.Triggers.Triggers, 1)
+
+	after, err := json.Marshal(root)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after), "the source pipeline is unchanged")
+	assert.Equal(t, "nightly", root.Pipeline.Stages[2].Stages[0].Annotations.Get(AnnotationProfiles))
+}
+
+func TestSelectErrors(t *testing.T) {
+	_, err := Select(load(t), "weekly")
+	assert.EqualError(t, err, "no stage, option, or trigger is tagged with profile weekly")
+
+	root := load(t)
+	for _, s := range root.Pipeline.Stages {
+		Tag(&s.Annotations, "release")
+	}
+	_, err = Select(root, "nightly")
+	assert.EqualError(t, err, "profile nightly has no stages")
+
+	_, err = Select(nil, "release")
+	assert.EqualError(t, err, "a root with a pipeline is required")
+}
@@ -0,0 +1,81 @@
+package project
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+)
+
+// Classes of Groovy file
+const (
+	// ClassScript files are run for their statements, like a Scripted pipeline.
+	ClassScript = "script"
+	// ClassFunctions files only define functions, usually ending with "return this" so the object load returns can
+	// call them.
+	ClassFunctions = "functions"
+	// ClassMixed files both define functions and run statements.
+	ClassMixed = "mixed"
+	// ClassUnknown files couldn't be parsed.
+	ClassUnknown = "unknown"
+)
+
+var (
+	// functionDefinition matches the start of a method definition, such as "def deploy(String env) {"
+	functionDefinition = regexp.MustCompile(`^(?:(?:public|private|protected|static|final|def|void|[A-Za-z_][\w.]*(?:<[^>]*>)?(?:\[\])?)\s+)+(\w+)\s*\([^)]*\)\s*\{`)
+	// loadCall matches load steps with a literal path
+	loadCall = regexp.MustCompile(`\bload\s*\(?\s*(?:path\s*:\s*)?(?:'([^'$]+)'|"([^"$]+)")`)
+	// evaluateCall matches evaluate calls of files read by readFile
+	evaluateCall = regexp.MustCompile(`\bevaluate\s*\(\s*readFile\s*\(?\s*(?:file\s*:\s*)?(?:'([^'$]+)'|"([^"$]+)")`)
+	// scriptCall matches sh, bat, powershell, and pwsh steps with a literal script on one line
+	scriptCall = regexp.MustCompile(`\b(sh|bat|powershell|pwsh)\s*\(?\s*(?:script\s*:\s*)?(?:'([^']*)'|"([^"]*)")`)
+)
+
+// groovyFiles returns the files Groovy source refers to with load steps, evaluate calls, and sh, bat, powershell, and
+// pwsh steps. Like scriptPaths, it's a heuristic, and only finds literal paths.
+func groovyFiles(src string, cwd string) []fileRef {
+	var refs []fileRef
+	for _, m := range loadCall.FindAllStringSubmatch(src, -1) {
+		if p, err := Resolve(cwd, m[1]+m[2]); err == nil {
+			refs = append(refs, fileRef{p, KindGroovy, "load step"})
+		}
+	}
+	for _, m := range evaluateCall.FindAllStringSubmatch(src, -1) {
+		if p, err := Resolve(cwd, m[1]+m[2]); err == nil {
+			refs = append(refs, fileRef{p, KindGroovy, "evaluate"})
+		}
+	}
+	for _, m := range scriptCall.FindAllStringSubmatch(src, -1) {
+		for _, p := range scriptPaths(m[2]+m[3], cwd) {
+			refs = append(refs, fileRef{p, kindOf(p), m[1] + " step"})
+		}
+	}
+	return refs
+}
+
+// classify returns the class of a Groovy file, and the names of the functions it defines
+func classify(src string) (string, []string) {
+	script, err := groovy.Parse(src)
+	if err != nil {
+		return ClassUnknown, nil
+	}
+	var functions []string
+	statements := 0
+	for _, s := range script.Statements {
+		text := s.Source()
+		switch {
+		case strings.HasPrefix(text, "import ") || strings.HasPrefix(text, "package ") || text == "return this":
+		case functionDefinition.MatchString(text):
+			functions = append(functions, functionDefinition.FindStringSubmatch(text)[1])
+		default:
+			statements++
+		}
+	}
+	switch {
+	case len(functions) > 0 && statements > 0:
+		return ClassMixed, functions
+	case len(functions) > 0:
+		return ClassFunctions, functions
+	}
+	return ClassScript, nil
+}
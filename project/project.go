@@ -0,0 +1,346 @@
+// Package project models a repository's whole CI surface: its Jenkinsfile, and the files the pipeline refers to, such
+// as pod templates for Kubernetes agents, Dockerfiles for dockerfile agents, scripts run by sh steps, and Groovy files
+// run by load steps. It checks that they exist, and builds a dependency graph between them.
+package project
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Kinds of file
+const (
+	KindJenkinsfile = "jenkinsfile"
+	KindPodTemplate = "pod-template"
+	KindDockerfile  = "dockerfile"
+	KindScript      = "script"
+	KindGroovy      = "groovy"
+)
+
+// File is a file in the project
+type File struct {
+	// Path is slash-separated and relative to the repository.
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Exists bool   `json:"exists"`
+	// Class is how a Groovy file is written, once read by Classify: ClassScript, ClassFunctions, or ClassMixed.
+	Class string `json:"class,omitempty"`
+	// Functions are the names of the functions a Groovy file defines, once read by Classify.
+	Functions []string `json:"functions,omitempty"`
+}
+
+// Reference is an edge of the dependency graph: one file referring to another
+type Reference struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Stage is the ID of the stage making the reference, for references from the Jenkinsfile's stages.
+	Stage string `json:"stage,omitempty"`
+	// Via is how the file is referred to, such as "agent", "sh step", "load step", or "script".
+	Via string `json:"via"`
+}
+
+// Project is a pipeline and the files it depends on
+type Project struct {
+	// Dir is the repository's directory.
+	Dir string `json:"-"`
+	// Jenkinsfile is the path of the Jenkinsfile, which is also the first of Files.
+	Jenkinsfile string      `json:"jenkinsfile"`
+	Root        *model.Root `json:"-"`
+	// Files are the Jenkinsfile followed by the files it depends on, directly or not, sorted by path.
+	Files      []*File      `json:"files"`
+	References []*Reference `json:"references,omitempty"`
+
+	files   map[string]*File
+	scanned map[string]bool
+	// groovy is set by Classify, to search Groovy files.
+	groovy bool
+}
+
+// Load builds the project for the pipeline in the Jenkinsfile at the given path, relative to the repository in dir.
+// The Jenkinsfile must already be parsed into root. Files are found by literal arguments of agents and load steps,
+// by looking for paths in the scripts of sh, bat, powershell, and pwsh steps, and by looking for load, evaluate, and
+// script steps in script blocks. Scripts which exist are searched for further scripts they run, in the same way. Use
+// Classify to also search the Groovy files the pipeline loads.
+func Load(dir string, jenkinsfile string, root *model.Root) (*Project, error) {
+	p, err := plan.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	jenkinsfile = filepath.ToSlash(jenkinsfile)
+	proj := &Project{Dir: dir, Jenkinsfile: jenkinsfile, Root: root, files: map[string]*File{},
+		scanned: map[string]bool{}}
+	proj.files[jenkinsfile] = &File{Path: jenkinsfile, Kind: KindJenkinsfile, Exists: proj.exists(jenkinsfile)}
+
+	agent := func(a *plan.Agent, stage string) {
+		if a == nil || a.Inherited {
+			return
+		}
+		for _, ref := range agentFiles(a.Source) {
+			proj.add(jenkinsfile, ref.path, ref.kind, stage, ref.via)
+		}
+	}
+	steps := func(steps []*plan.Step, stage string) {
+		visitSteps(steps, "", func(st *plan.Step, cwd string) {
+			for _, ref := range stepFiles(st, cwd) {
+				proj.add(jenkinsfile, ref.path, ref.kind, stage, ref.via)
+			}
+		})
+	}
+	agent(p.Agent, "")
+	var visitStage func(s *plan.Stage)
+	visitStage = func(s *plan.Stage) {
+		agent(s.Agent, s.ID)
+		steps(s.Steps, s.ID)
+		for _, b := range s.Post {
+			steps(b.Steps, s.ID)
+		}
+		for _, c := range s.Children {
+			visitStage(c)
+		}
+	}
+	for _, s := range p.Stages {
+		visitStage(s)
+	}
+	for _, b := range p.Post {
+		steps(b.Steps, "")
+	}
+	if err := proj.follow(); err != nil {
+		return nil, err
+	}
+	return proj, nil
+}
+
+// add adds a reference, and the file it refers to if it's new
+func (strct *Project) add(from string, to string, kind string, stage string, via string) {
+	strct.References = append(strct.References, &Reference{From: from, To: to, Stage: stage, Via: via})
+	if strct.files[to] == nil {
+		strct.files[to] = &File{Path: to, Kind: kind, Exists: strct.exists(to)}
+	}
+}
+
+// follow searches the files referred to for the files they refer to in turn, breadth first, and updates Files
+func (strct *Project) follow() error {
+	for i := 0; i < len(strct.References); i++ {
+		to := strct.files[strct.References[i].To]
+		if !to.Exists || strct.scanned[to.Path] || (to.Kind != KindScript && (to.Kind != KindGroovy || !strct.groovy)) {
+			continue
+		}
+		strct.scanned[to.Path] = true
+		b, err := ioutil.ReadFile(filepath.Join(strct.Dir, filepath.FromSlash(to.Path)))
+		if err != nil {
+			return err
+		}
+		if to.Kind == KindGroovy {
+			to.Class, to.Functions = classify(string(b))
+			for _, ref := range groovyFiles(string(b), "") {
+				strct.add(to.Path, ref.path, ref.kind, "", ref.via)
+			}
+			continue
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			for _, ref := range scriptPaths(line, "") {
+				strct.add(to.Path, ref, kindOf(ref), "", "script")
+			}
+		}
+	}
+
+	strct.Files = nil
+	for _, f := range strct.files {
+		if f.Path != strct.Jenkinsfile {
+			strct.Files = append(strct.Files, f)
+		}
+	}
+	sort.Slice(strct.Files, func(i, j int) bool { return strct.Files[i].Path < strct.Files[j].Path })
+	strct.Files = append([]*File{strct.files[strct.Jenkinsfile]}, strct.Files...)
+	return nil
+}
+
+// Classify reads the Groovy files the pipeline loads, setting their Class and Functions, and adds the files they
+// refer to, as Load does for the Jenkinsfile.
+func (strct *Project) Classify() error {
+	strct.groovy = true
+	return strct.follow()
+}
+
+func (strct *Project) exists(p string) bool {
+	_, err := os.Stat(filepath.Join(strct.Dir, filepath.FromSlash(p)))
+	return err == nil
+}
+
+// File returns the file with the given path, or nil if it isn't part of the project
+func (strct *Project) File(p string) *File {
+	for _, f := range strct.Files {
+		if f.Path == p {
+			return f
+		}
+	}
+	return nil
+}
+
+// Missing returns the files which are referred to but don't exist
+func (strct *Project) Missing() []*File {
+	var missing []*File
+	for _, f := range strct.Files {
+		if !f.Exists {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// Dependencies returns the paths of the files a file depends on, directly or not, sorted
+func (strct *Project) Dependencies(p string) []string {
+	seen := map[string]bool{p: true}
+	queue := []string{p}
+	var deps []string
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, r := range strct.References {
+			if r.From == from && !seen[r.To] {
+				seen[r.To] = true
+				deps = append(deps, r.To)
+				queue = append(queue, r.To)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// ErrOutsideRepository is returned by Resolve for paths outside the repository
+var ErrOutsideRepository = errors.New("path is outside the repository")
+
+// Resolve resolves a path used by a step, relative to the directory the step runs in, to a slash-separated path
+// relative to the repository.
+func Resolve(cwd string, p string) (string, error) {
+	p = strings.ReplaceAll(p, `\`, "/")
+	if path.IsAbs(p) {
+		return "", ErrOutsideRepository
+	}
+	p = path.Clean(path.Join(cwd, p))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrOutsideRepository
+	}
+	return p, nil
+}
+
+// agentFiles returns the files an agent refers to
+func agentFiles(a *model.Agent) []fileRef {
+	if a == nil {
+		return nil
+	}
+	args := map[string]string{}
+	for _, arg := range a.Arguments {
+		if arg != nil && arg.Value != nil && arg.Value.Raw != nil && arg.Value.Raw.IsLiteral {
+			args[arg.Key] = arg.Value.Raw.String()
+		}
+	}
+	switch a.Type {
+	case "kubernetes":
+		if p, err := Resolve("", args["yamlFile"]); err == nil && args["yamlFile"] != "" {
+			return []fileRef{{p, KindPodTemplate, "agent"}}
+		}
+	case "dockerfile":
+		name := args["filename"]
+		if name == "" {
+			name = "Dockerfile"
+		}
+		if p, err := Resolve(args["dir"], name); err == nil {
+			return []fileRef{{p, KindDockerfile, "agent"}}
+		}
+	}
+	return nil
+}
+
+// visitSteps calls fn for each step with the directory it runs in, following dir steps with literal paths
+func visitSteps(steps []*plan.Step, cwd string, fn func(st *plan.Step, cwd string)) {
+	for _, st := range steps {
+		fn(st, cwd)
+		inner := cwd
+		if st.Name == "dir" && st.Source != nil && st.Source.Tree != nil {
+			if arg := st.Source.Tree.Arguments.Get("path"); arg != nil && arg.IsLiteral {
+				if p, err := Resolve(cwd, arg.String()); err == nil {
+					inner = p
+				}
+			}
+		}
+		visitSteps(st.Children, inner, fn)
+	}
+}
+
+// fileRef is a file referred to by a step or Groovy source
+type fileRef struct {
+	path string
+	kind string
+	via  string
+}
+
+// stepFiles returns the files a step refers to
+func stepFiles(st *plan.Step, cwd string) []fileRef {
+	var refs []fileRef
+	switch {
+	case st.Name == "load" && st.Source != nil && st.Source.Step != nil:
+		if arg := st.Source.Step.Arguments.Get("path"); arg != nil && arg.IsLiteral {
+			if p, err := Resolve(cwd, arg.String()); err == nil {
+				refs = append(refs, fileRef{p, KindGroovy, "load step"})
+			}
+		}
+	case st.Name == "script" && st.Source != nil && st.Source.Step != nil:
+		if arg := st.Source.Step.Arguments.Get("scriptBlock"); arg != nil {
+			refs = groovyFiles(arg.String(), cwd)
+		}
+	case st.Script != "":
+		for _, p := range scriptPaths(st.Script, cwd) {
+			refs = append(refs, fileRef{p, kindOf(p), st.Name + " step"})
+		}
+	}
+	return refs
+}
+
+// scriptExtensions are the extensions of files which are taken to be scripts when they appear in a script
+var scriptExtensions = map[string]bool{".sh": true, ".bash": true, ".py": true, ".rb": true, ".pl": true,
+	".ps1": true, ".bat": true, ".cmd": true, ".groovy": true}
+
+// scriptPaths returns the paths in a script which look like files in the repository: words starting with ./ or ../,
+// or ending with a script extension. It's a heuristic, so it misses paths built from variables and is confused by
+// commands which change directory.
+func scriptPaths(script string, cwd string) []string {
+	words := strings.FieldsFunc(script, func(r rune) bool {
+		return strings.ContainsRune(" \t\r\n;&|()<>'\"`=", r)
+	})
+	var paths []string
+	seen := map[string]bool{}
+	for _, w := range words {
+		w = strings.ReplaceAll(w, `\`, "/")
+		if strings.ContainsAny(w, "$*?{}") || strings.Contains(w, "://") || strings.HasPrefix(w, "-") {
+			continue
+		}
+		if !strings.HasPrefix(w, "./") && !strings.HasPrefix(w, "../") && !scriptExtensions[path.Ext(w)] {
+			continue
+		}
+		if p, err := Resolve(cwd, w); err == nil && p != "." && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func kindOf(p string) string {
+	if path.Ext(p) == ".groovy" {
+		return KindGroovy
+	}
+	return KindScript
+}
@@ -0,0 +1,85 @@
+package project
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "pipeline.json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(b, root))
+
+	proj, err := Load(filepath.Join("testdata", "repo"), "Jenkinsfile", root)
+	require.NoError(t, err)
+	assert.Equal(t, []*File{
+		{Path: "Jenkinsfile", Kind: KindJenkinsfile, Exists: true},
+		{Path: "ci/build.sh", Kind: KindScript, Exists: true},
+		{Path: "ci/common.sh", Kind: KindScript, Exists: true},
+		{Path: "ci/deploy.sh", Kind: KindScript, Exists: false},
+		{Path: "ci/env.groovy", Kind: KindGroovy, Exists: true},
+		{Path: "ci/pod.yaml", Kind: KindPodTemplate, Exists: true},
+		{Path: "docker/Dockerfile", Kind: KindDockerfile, Exists: false},
+		{Path: "vars/utils.groovy", Kind: KindGroovy, Exists: true},
+	}, proj.Files)
+	assert.Equal(t, []*Reference{
+		{From: "Jenkinsfile", To: "ci/pod.yaml", Via: "agent"},
+		{From: "Jenkinsfile", To: "ci/build.sh", Stage: "Build", Via: "sh step"},
+		{From: "Jenkinsfile", To: "docker/Dockerfile", Stage: "Image", Via: "agent"},
+		{From: "Jenkinsfile", To: "ci/env.groovy", Stage: "Deploy", Via: "evaluate"},
+		{From: "Jenkinsfile", To: "vars/utils.groovy", Stage: "Deploy", Via: "load step"},
+		{From: "Jenkinsfile", To: "ci/deploy.sh", Stage: "Deploy", Via: "sh step"},
+		{From: "ci/build.sh", To: "ci/common.sh", Via: "script"},
+	}, proj.References)
+
+	assert.Equal(t, []string{"ci/deploy.sh", "docker/Dockerfile"}, []string{proj.Missing()[0].Path, proj.Missing()[1].Path})
+	assert.Equal(t, []string{"ci/common.sh"}, proj.Dependencies("ci/build.sh"))
+	assert.Len(t, proj.Dependencies("Jenkinsfile"), 7)
+	assert.Nil(t, proj.File("README.md"))
+}
+
+func TestClassify(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "pipeline.json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(b, root))
+	proj, err := Load(filepath.Join("testdata", "repo"), "Jenkinsfile", root)
+	require.NoError(t, err)
+	assert.Empty(t, proj.File("vars/utils.groovy").Class)
+
+	require.NoError(t, proj.Classify())
+	utils := proj.File("vars/utils.groovy")
+	assert.Equal(t, ClassFunctions, utils.Class)
+	assert.Equal(t, []string{"deploy", "version"}, utils.Functions)
+	assert.Equal(t, ClassScript, proj.File("ci/env.groovy").Class)
+	assert.Equal(t, &File{Path: "ci/release.sh", Kind: KindScript, Exists: true}, proj.File("ci/release.sh"))
+	// Paths starting with ./ are taken to be files, even when the command uses them as directories.
+	assert.Equal(t, []string{"chart", "ci/release.sh"}, proj.Dependencies("vars/utils.groovy"))
+}
+
+func TestClassifySource(t *testing.T) {
+	class, functions := classify("import groovy.json.JsonOutput\n\ndef notify(msg) {\n  echo msg\n}\nnotify('loaded')\n")
+	assert.Equal(t, ClassMixed, class)
+	assert.Equal(t, []string{"notify"}, functions)
+	class, _ = classify("node {\n  sh 'make'\n}\n")
+	assert.Equal(t, ClassScript, class)
+	class, _ = classify("node {\n")
+	assert.Equal(t, ClassUnknown, class)
+}
+
+func TestResolve(t *testing.T) {
+	p, err := Resolve("ci", `..\tools\.\run.bat`)
+	require.NoError(t, err)
+	assert.Equal(t, "tools/run.bat", p)
+	_, err = Resolve("ci", "../../x.sh")
+	assert.Equal(t, ErrOutsideRepository, err)
+	_, err = Resolve("", "/etc/init.sh")
+	assert.Equal(t, ErrOutsideRepository, err)
+}
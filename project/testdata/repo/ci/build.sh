@@ -0,0 +1,5 @@
+#!/bin/sh
+# ./ci/ignored.sh is only mentioned in a comment
+set -e
+. ./ci/common.sh
+mvn -B verify
@@ -0,0 +1,2 @@
+#!/bin/sh
+export MAVEN_OPTS=-Xmx1g
@@ -0,0 +1,2 @@
+env.REGION = "eu-west-1"
+echo "loaded environment"
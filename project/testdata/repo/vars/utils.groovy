@@ -0,0 +1,7 @@
+def deploy(String env) {
+  sh "./ci/release.sh ${env}"
+}
+
+String version() { readFile("VERSION").trim() }
+
+return this
@@ -0,0 +1,444 @@
+// Package redact masks secrets written into pipelines, such as tokens, passwords, and URLs with credentials, so that
+// pipelines can be shared in bug reports or collected for analysis without leaking them. Only the secret text is
+// masked; the pipeline's structure, and so everything else about it, is left as it was.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultMask replaces secret text when Rules doesn't set a mask
+const DefaultMask = "****"
+
+// Rule finds secret text. At least one of Key and Value is required.
+type Rule struct {
+	Name string `json:"name"`
+	// Key is a regular expression matched against the names of arguments, environment variables, and parameters.
+	// The whole of their literal values is masked; values computed by Groovy expressions aren't, since the expression
+	// is code rather than a secret.
+	Key string `json:"key,omitempty"`
+	// Value is a regular expression matched against string values, literal or not. The text it matches is masked, or
+	// if it has a group, the text the first group matches. Matches referring to variables, such as "${TOKEN}", are
+	// left alone, since the secret is elsewhere.
+	Value string `json:"value,omitempty"`
+
+	key   *regexp.Regexp
+	value *regexp.Regexp
+}
+
+// Rules are the rules Apply masks secrets with
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+	// Mask replaces secret text. It defaults to DefaultMask.
+	Mask string `json:"mask,omitempty"`
+}
+
+// Redaction is a value Apply masked
+type Redaction struct {
+	// Path is the JSON pointer of the value in the pipeline's JSON, such as
+	// "/pipeline/stages/0/branches/0/steps/1/arguments/0/value".
+	Path string `json:"path"`
+	// Rule is the name of the rule which found the secret.
+	Rule string `json:"rule"`
+}
+
+// DefaultRules returns rules for common secrets: arguments and variables named like passwords, tokens, and keys,
+// passwords in URLs, authorization headers, and command line flags, and tokens with well-known formats, such as
+// GitHub and AWS ones.
+func DefaultRules() *Rules {
+	return &Rules{Rules: []*Rule{
+		{Name: "secret-name", Key: `(?i)(passw(or)?d|secret|token|(api|access|private|secret)[_-]?key)$`},
+		{Name: "url-credentials", Value: `[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@'"]+:([^/\s@'"]+)@`},
+		{Name: "authorization-header", Value: `(?i)authorization:\s*(?:basic|bearer|token)\s+([^\s'"]+)`},
+		{Name: "secret-flag", Value: `(?i)(?:^|\s)--?(?:password|passwd|token|secret|api-key)(?:=|\s+)([^\s'"-][^\s'"]*)`},
+		{Name: "secret-assignment", Value: `\b[A-Za-z0-9_]*(?:PASSWORD|PASSWD|TOKEN|SECRET)=([^\s'";]+)`},
+		{Name: "github-token", Value: `\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{22,})\b`},
+		{Name: "aws-access-key", Value: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+		{Name: "slack-token", Value: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+		{Name: "private-key", Value: `(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`},
+	}}
+}
+
+// ParseRules parses a YAML or JSON rule file, such as:
+//
+//	mask: "[redacted]"
+//	rules:
+//	- name: vault-token
+//	  value: '\bhvs\.[A-Za-z0-9_-]{24,}'
+//	- name: deploy-key
+//	  key: '^DEPLOY_KEY$'
+func ParseRules(b []byte) (*Rules, error) {
+	rules := &Rules{}
+	if err := yaml.UnmarshalStrict(b, rules); err != nil {
+		return nil, err
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *Rules) compile() error {
+	for i, rule := range r.Rules {
+		if rule == nil {
+			continue
+		}
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i)
+		}
+		if rule.Key == "" && rule.Value == "" {
+			return fmt.Errorf("%s: a key or value pattern is required", name)
+		}
+		var err error
+		rule.key, rule.value = nil, nil
+		if rule.Key != "" {
+			if rule.key, err = regexp.Compile(rule.Key); err != nil {
+				return fmt.Errorf("%s: key: %v", name, err)
+			}
+		}
+		if rule.Value != "" {
+			if rule.value, err = regexp.Compile(rule.Value); err != nil {
+				return fmt.Errorf("%s: value: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply masks the secrets the rules find in the pipeline's arguments, environment, options, triggers, parameters,
+// and so on, and returns what it masked, in the order it appears in the pipeline. It uses DefaultRules if rules is
+// nil. Masked values keep their kind, so a literal stays a literal, and raw Groovy steps are masked along with the
+// script they run. The pipeline is changed in place; redact a copy made with DeepCopy to keep the original.
+func Apply(root *model.Root, rules *Rules) ([]Redaction, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	r := &redactor{rules: rules, mask: rules.Mask}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+	p := root.Pipeline
+	r.agent("/pipeline/agent", p.Agent)
+	r.environment("/pipeline/environment", p.Environment)
+	r.options("/pipeline/options/options", p.Options)
+	if p.Triggers != nil {
+		r.calls("/pipeline/triggers/triggers", p.Triggers.Triggers)
+	}
+	r.parameters("/pipeline/parameters", p.Parameters)
+	if p.Libraries != nil {
+		for i, l := range p.Libraries.Libraries {
+			r.value(fmt.Sprintf("/pipeline/libraries/libraries/%d", i), nil, l)
+		}
+	}
+	r.tools("/pipeline/tools", p.Tools)
+	r.stages("/pipeline/stages", p.Stages)
+	r.post("/pipeline/post", p.Post)
+	return r.redactions, nil
+}
+
+type redactor struct {
+	rules      *Rules
+	mask       string
+	redactions []Redaction
+}
+
+// value masks the secrets in an argument. keys are the names the value is known by, such as its argument's key.
+func (r *redactor) value(path string, keys []string, arg *model.RawArgument) {
+	if arg == nil || arg.Value == nil || arg.Value.AsString == nil {
+		return
+	}
+	s := *arg.Value.AsString
+	for _, rule := range r.rules.Rules {
+		if rule == nil {
+			continue
+		}
+		if rule.key != nil && arg.IsLiteral && s != "" && s != r.mask && matchesAny(rule.key, keys) {
+			s = r.mask
+			r.redactions = append(r.redactions, Redaction{Path: path, Rule: rule.Name})
+			break
+		}
+		if rule.value != nil {
+			if masked := r.maskMatches(rule.value, s); masked != s {
+				s = masked
+				r.redactions = append(r.redactions, Redaction{Path: path, Rule: rule.Name})
+			}
+		}
+	}
+	if s != *arg.Value.AsString {
+		arg.Value = &model.RawArgumentValue{AsString: &s}
+	}
+}
+
+// maskMatches masks the text re matches in s, or the text its first group matches, unless it refers to a variable or
+// is already masked
+func (r *redactor) maskMatches(re *regexp.Regexp, s string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		start, end := m[0], m[1]
+		if len(m) > 2 {
+			start, end = m[2], m[3]
+		}
+		if start < 0 {
+			continue
+		}
+		if secret := s[start:end]; secret == "" || secret == r.mask || strings.Contains(secret, "$") {
+			continue
+		}
+		out.WriteString(s[last:start])
+		out.WriteString(r.mask)
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+func matchesAny(re *regexp.Regexp, keys []string) bool {
+	for _, k := range keys {
+		if k != "" && re.MatchString(k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) stages(path string, stages []*model.Stage) {
+	for i, s := range stages {
+		if s == nil {
+			continue
+		}
+		at := fmt.Sprintf("%s/%d", path, i)
+		r.agent(at+"/agent", s.Agent)
+		r.environment(at+"/environment", s.Environment)
+		r.options(at+"/options/options", s.Options)
+		r.when(at+"/when", s.When)
+		r.input(at+"/input", s.Input)
+		r.tools(at+"/tools", s.Tools)
+		for j, b := range s.Branches {
+			if b != nil {
+				r.steps(fmt.Sprintf("%s/branches/%d/steps", at, j), b.Steps)
+			}
+		}
+		r.stages(at+"/stages", s.Stages)
+		r.stages(at+"/parallel", s.Parallel)
+		if m := s.Matrix; m != nil {
+			for j, a := range m.Axes {
+				if a == nil {
+					continue
+				}
+				for k, v := range a.Values {
+					r.value(fmt.Sprintf("%s/matrix/axes/%d/values/%d", at, j, k), []string{a.Name}, v)
+				}
+			}
+			r.agent(at+"/matrix/agent", m.Agent)
+			r.environment(at+"/matrix/environment", m.Environment)
+			r.options(at+"/matrix/options/options", m.Options)
+			r.when(at+"/matrix/when", m.When)
+			r.input(at+"/matrix/input", m.Input)
+			r.tools(at+"/matrix/tools", m.Tools)
+			r.stages(at+"/matrix/stages", m.Stages)
+			r.post(at+"/matrix/post", m.Post)
+		}
+		r.post(at+"/post", s.Post)
+	}
+}
+
+func (r *redactor) steps(path string, steps []*model.AnyStep) {
+	for i, step := range steps {
+		at := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case step == nil:
+		case step.Step != nil:
+			r.arguments(at+"/arguments", step.Step.Arguments)
+			if raw := step.Step.Raw; raw != nil {
+				// Writers emit the raw source rather than the script argument, so it must match the masked script.
+				raw.Source = step.Step.Arguments.Get("scriptBlock").String()
+			}
+		case step.Tree != nil:
+			r.arguments(at+"/arguments", step.Tree.Arguments)
+			r.steps(at+"/children", step.Tree.Children)
+		}
+	}
+}
+
+func (r *redactor) arguments(path string, args *model.ArgumentList) {
+	if args == nil {
+		return
+	}
+	r.value(path, nil, args.Single)
+	for i, a := range args.Named {
+		if a != nil {
+			r.value(fmt.Sprintf("%s/%d/value", path, i), []string{a.Key}, a.Value)
+		}
+	}
+	for i, a := range args.Positional {
+		r.value(fmt.Sprintf("%s/%d", path, i), nil, a)
+	}
+}
+
+func (r *redactor) environment(path string, entries []*model.EnvironmentEntry) {
+	for i, e := range entries {
+		if e != nil {
+			r.environmentValue(fmt.Sprintf("%s/%d/value", path, i), e.Key, e.Value)
+		}
+	}
+}
+
+func (r *redactor) environmentValue(path string, key string, v *model.EnvironmentValue) {
+	switch {
+	case v == nil:
+	case v.Single != nil:
+		r.value(path, []string{key}, v.Single)
+	case v.Function != nil:
+		r.function(path, v.Function)
+	}
+}
+
+// function masks the secrets in the arguments of an internal function call. Arguments of functions such as
+// credentials are IDs rather than the value, so they're only checked for values which look secret.
+func (r *redactor) function(path string, f *model.InternalFunction) {
+	for i, a := range f.Arguments {
+		at := fmt.Sprintf("%s/arguments/%d", path, i)
+		if call := f.Call(i); call != nil {
+			r.function(at, call)
+		} else {
+			r.value(at, []string{""}, a)
+		}
+	}
+}
+
+func (r *redactor) options(path string, o *model.Options) {
+	if o != nil {
+		r.calls(path, o.Options)
+	}
+}
+
+func (r *redactor) parameters(path string, p *model.Parameters) {
+	if p != nil {
+		r.calls(path+"/parameters", p.Parameters)
+	}
+}
+
+// calls masks the secrets in the arguments of options, triggers, and parameters. A parameter's default value is also
+// known by the parameter's name, so a password parameter's default is masked.
+func (r *redactor) calls(path string, calls []*model.MethodCall) {
+	for i, c := range calls {
+		if c == nil {
+			continue
+		}
+		name := ""
+		if n := c.Args().Get("name"); n != nil && n.IsLiteral {
+			name = n.String()
+		}
+		r.methodArgs(fmt.Sprintf("%s/%d/arguments", path, i), name, c.Arguments)
+	}
+}
+
+func (r *redactor) methodArgs(path string, name string, args []*model.MethodArg) {
+	for i, a := range args {
+		at := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case a == nil:
+		case a.Single != nil:
+			r.valueOrCall(at, nil, a.Single)
+		case a.WithKey != nil:
+			keys := []string{a.WithKey.Key}
+			if a.WithKey.Key == "defaultValue" {
+				keys = append(keys, name)
+			}
+			r.valueOrCall(at+"/value", keys, a.WithKey.Value)
+		}
+	}
+}
+
+func (r *redactor) valueOrCall(path string, keys []string, v *model.ValueOrMethodCall) {
+	switch {
+	case v == nil:
+	case v.Single != nil:
+		r.value(path, keys, v.Single)
+	case v.Call != nil:
+		r.methodArgs(path+"/arguments", "", v.Call.Arguments)
+	}
+}
+
+func (r *redactor) agent(path string, a *model.Agent) {
+	if a == nil {
+		return
+	}
+	r.value(path+"/argument", nil, a.Argument)
+	r.mapArguments(path+"/arguments", a.Arguments)
+}
+
+func (r *redactor) mapArguments(path string, args []*model.MapArgumentValue) {
+	for i, a := range args {
+		if a == nil || a.Value == nil {
+			continue
+		}
+		at := fmt.Sprintf("%s/%d/value", path, i)
+		r.value(at, []string{a.Key}, a.Value.Raw)
+		r.mapArguments(at, a.Value.List)
+	}
+}
+
+func (r *redactor) tools(path string, tools []*model.ArgumentValue) {
+	for i, t := range tools {
+		if t != nil {
+			r.value(fmt.Sprintf("%s/%d/value", path, i), nil, t.Value)
+		}
+	}
+}
+
+func (r *redactor) when(path string, w *model.When) {
+	if w != nil {
+		r.conditions(path+"/conditions", w.Conditions)
+	}
+}
+
+func (r *redactor) conditions(path string, conditions []*model.StepOrNestedWhenCondition) {
+	for i, c := range conditions {
+		at := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case c == nil:
+		case c.Step != nil:
+			r.arguments(at+"/arguments", c.Step.Arguments)
+		case c.Nested != nil:
+			r.conditions(at+"/children", c.Nested.Children)
+		}
+	}
+}
+
+func (r *redactor) input(path string, in *model.Input) {
+	if in == nil {
+		return
+	}
+	r.value(path+"/message", nil, in.Message)
+	r.value(path+"/ok", nil, in.Ok)
+	r.parameters(path+"/parameters", in.Parameters)
+}
+
+func (r *redactor) post(path string, p *model.Post) {
+	if p == nil {
+		return
+	}
+	for i, c := range p.Conditions {
+		if c != nil && c.Branch != nil {
+			r.steps(fmt.Sprintf("%s/conditions/%d/branch/steps", path, i), c.Branch.Steps)
+		}
+	}
+}
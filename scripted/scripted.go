@@ -0,0 +1,255 @@
+// Package scripted converts simple Scripted pipelines into Declarative pipeline models. The conversion is best effort:
+// node and stage blocks, parallel branches, and steps are translated, and everything else is either kept in a script
+// step or reported as a construct that could not be translated.
+package scripted
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/internal/groovy"
+	"github.com/abayer/go-jenkinsfile/internal/groovymodel"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Note describes part of the Scripted pipeline which was not translated directly
+type Note struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// Result is the outcome of a conversion
+type Result struct {
+	Root *model.Root `json:"root"`
+	// Notes are the constructs that were dropped, or kept in script steps, and need reviewing by hand.
+	Notes []*Note `json:"notes,omitempty"`
+}
+
+type converter struct {
+	notes []*Note
+	// generated counts the stages created for steps outside stages, to give them unique names.
+	generated int
+	// parallels counts the stages created for parallel steps outside stages, likewise.
+	parallels int
+}
+
+func (c *converter) note(s groovy.Statement, format string, args ...interface{}) {
+	pos := s.Position()
+	c.notes = append(c.notes, &Note{Line: pos.Line, Column: pos.Column, Source: s.Source(),
+		Reason: fmt.Sprintf(format, args...)})
+}
+
+// ToDeclarative converts a Scripted pipeline. If the pipeline has a single top-level node block and no stages outside
+// it, its agent becomes the pipeline's agent; otherwise the pipeline has no agent and each stage runs on the agent of the node block enclosing
+// it. Pipelines which would convert to an invalid model, such as ones with two stages of the same name, return a
+// *model.InvariantError.
+func ToDeclarative(src []byte) (*Result, error) {
+	script, err := groovy.Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	c := &converter{}
+	pipeline := &model.Pipeline{Agent: &model.Agent{Type: "none"}, Stages: []*model.Stage{}}
+	pipeline.Annotations.AddProvenance("scripted: converted from a Scripted pipeline")
+
+	nodes, stages := 0, 0
+	for _, s := range script.Statements {
+		if call, ok := s.(*groovy.Call); ok {
+			switch {
+			case call.Name == "node" && call.Closure != nil:
+				nodes++
+			case call.Name == "stage" || call.Name == "parallel":
+				stages++
+			}
+		}
+	}
+	single := nodes == 1 && stages == 0
+	var loose []groovy.Statement
+	for _, s := range script.Statements {
+		call, _ := s.(*groovy.Call)
+		switch {
+		case call != nil && call.Name == "node" && call.Closure != nil:
+			agent := nodeAgent(call)
+			if single {
+				pipeline.Agent = agent
+				agent = nil
+			}
+			pipeline.Stages = append(pipeline.Stages, c.stages(call.Closure.Statements, agent)...)
+		case call != nil && call.Name == "properties":
+			c.note(s, "job properties are not converted; use the options, parameters, and triggers directives")
+		case call != nil && (call.Name == "stage" || call.Name == "parallel"):
+			loose = append(loose, s)
+		default:
+			c.note(s, "statement outside a node block is not converted")
+		}
+	}
+	if len(loose) > 0 {
+		for _, s := range loose {
+			c.note(s, "runs outside a node block, so the stage has no agent")
+		}
+		pipeline.Stages = append(pipeline.Stages, c.stages(loose, nil)...)
+	}
+	if len(pipeline.Stages) == 0 {
+		return nil, fmt.Errorf("no stages found")
+	}
+	root := &model.Root{Pipeline: pipeline}
+	model.AssignUIDs(root)
+	if err := model.CheckInvariants(root); err != nil {
+		return nil, err
+	}
+	return &Result{Root: root, Notes: c.notes}, nil
+}
+
+func nodeAgent(call *groovy.Call) *model.Agent {
+	if len(call.Args) == 0 {
+		return &model.Agent{Type: "any"}
+	}
+	return &model.Agent{Type: "label", Argument: groovymodel.Argument(call.Args[0].Value)}
+}
+
+// stages converts the contents of a node block to stages. Steps between stages are collected into stages of their
+// own, since Declarative pipelines only allow steps inside stages.
+func (c *converter) stages(stmts []groovy.Statement, agent *model.Agent) []*model.Stage {
+	var out []*model.Stage
+	var pending []groovy.Statement
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		c.generated++
+		name := fmt.Sprintf("Stage %d", c.generated)
+		for _, s := range pending {
+			c.note(s, "step outside a stage was placed in stage %q", name)
+		}
+		st := &model.Stage{Name: name, Agent: agent, Branches: c.branch(pending)}
+		st.Annotations.AddProvenance(fmt.Sprintf("scripted: steps outside a stage at line %d", pending[0].Position().Line))
+		out = append(out, st)
+		pending = nil
+	}
+	for _, s := range stmts {
+		call, _ := s.(*groovy.Call)
+		switch {
+		case call != nil && call.Name == "stage":
+			flush()
+			if st := c.stage(call); st != nil {
+				if st.Agent == nil {
+					st.Agent = agent
+				}
+				out = append(out, st)
+			}
+		case call != nil && call.Name == "parallel" && len(call.Args) > 0:
+			flush()
+			c.parallels++
+			name := "Parallel"
+			if c.parallels > 1 {
+				name = fmt.Sprintf("Parallel %d", c.parallels)
+			}
+			c.note(s, "parallel branches outside a stage were placed in stage %q", name)
+			st := &model.Stage{Name: name, Agent: agent}
+			st.Annotations.AddProvenance(fmt.Sprintf("scripted: parallel step outside a stage at line %d", call.Pos.Line))
+			st.Parallel, st.FailFast = c.parallel(call)
+			out = append(out, st)
+		case call != nil && call.Name == "checkout" && len(call.Args) == 1 && call.Args[0].Value.Raw == "scm":
+			// Declarative pipelines check out the repository implicitly.
+		default:
+			pending = append(pending, s)
+		}
+	}
+	flush()
+	return out
+}
+
+// stage converts a stage block. Its contents become steps, nested stages, parallel stages, or if it holds a single
+// node block, the stage's agent and steps.
+func (c *converter) stage(call *groovy.Call) *model.Stage {
+	if len(call.Args) == 0 || call.Args[0].Value.Kind != groovy.ExprString || call.Args[0].Value.Interpolated {
+		c.note(call, "stage without a constant name is not converted")
+		return nil
+	}
+	st := &model.Stage{Name: call.Args[0].Value.Value}
+	st.Annotations.AddProvenance(fmt.Sprintf("scripted: stage block at line %d", call.Pos.Line))
+	if call.Closure == nil {
+		c.note(call, "old-style stage without a block is not converted")
+		return nil
+	}
+	body := call.Closure.Statements
+	if len(body) == 1 {
+		if node, ok := body[0].(*groovy.Call); ok && node.Name == "node" && node.Closure != nil {
+			st.Agent = nodeAgent(node)
+			body = node.Closure.Statements
+		}
+	}
+
+	var nested []groovy.Statement
+	var steps []groovy.Statement
+	for _, s := range body {
+		if inner, ok := s.(*groovy.Call); ok && inner.Name == "stage" {
+			nested = append(nested, s)
+		} else {
+			steps = append(steps, s)
+		}
+	}
+	switch {
+	case len(steps) == 1 && len(nested) == 0 && isParallel(steps[0]):
+		st.Parallel, st.FailFast = c.parallel(steps[0].(*groovy.Call))
+	case len(nested) > 0 && len(steps) == 0:
+		for _, s := range nested {
+			if n := c.stage(s.(*groovy.Call)); n != nil {
+				st.Stages = append(st.Stages, n)
+			}
+		}
+	case len(nested) > 0:
+		st.Branches = c.branch(body)
+	default:
+		st.Branches = c.branch(steps)
+	}
+	return st
+}
+
+func isParallel(s groovy.Statement) bool {
+	call, ok := s.(*groovy.Call)
+	return ok && call.Name == "parallel" && len(call.Args) > 0
+}
+
+// parallel converts the branches of a parallel step to parallel stages, and returns whether failFast was set
+func (c *converter) parallel(call *groovy.Call) ([]*model.Stage, bool) {
+	var out []*model.Stage
+	failFast := false
+	args := call.Args
+	if len(args) == 1 && args[0].Key == "" && args[0].Value.Kind == groovy.ExprMap {
+		args = args[0].Value.Elements
+	}
+	for _, a := range args {
+		switch {
+		case a.Key == "failFast" && a.Value.Kind == groovy.ExprBool:
+			failFast = a.Value.Value == "true"
+		case a.Key == "" || a.Value.Kind != groovy.ExprClosure:
+			c.note(call, "parallel branch %q is not a closure literal and is not converted", a.Value.Raw)
+		default:
+			st := &model.Stage{Name: a.Key, Branches: c.branch(a.Value.Closure.Statements)}
+			st.Annotations.AddProvenance(fmt.Sprintf("scripted: parallel branch at line %d", a.Value.Pos.Line))
+			out = append(out, st)
+		}
+	}
+	return out, failFast
+}
+
+// scriptOnly are steps Declarative pipelines only allow inside script steps
+var scriptOnly = map[string]bool{"node": true, "stage": true, "parallel": true, "properties": true}
+
+func (c *converter) branch(stmts []groovy.Statement) []*model.Branch {
+	steps := []*model.AnyStep{}
+	wrapped := func(s groovy.Statement) {
+		c.note(s, "kept in a script step")
+	}
+	for _, s := range stmts {
+		if call, ok := s.(*groovy.Call); ok && scriptOnly[call.Name] {
+			wrapped(s)
+			steps = append(steps, groovymodel.ScriptStep(s.Source()))
+			continue
+		}
+		steps = append(steps, groovymodel.Steps([]groovy.Statement{s}, wrapped)...)
+	}
+	return []*model.Branch{{Name: "default", Steps: steps}}
+}
@@ -0,0 +1,99 @@
+package scripted
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+func TestToDeclarative(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "Jenkinsfile"))
+	require.NoError(t, err)
+	result, err := ToDeclarative(src)
+	require.NoError(t, err)
+
+	got, err := json.MarshalIndent(result.Root, "", "  ")
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "Jenkinsfile.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(got))
+
+	// The result must be a valid model.
+	require.NoError(t, json.Unmarshal(got, &model.Root{}))
+
+	assert.Equal(t, []*Note{
+		{Line: 2, Column: 1, Source: "properties([buildDiscarder(logRotator(numToKeepStr: '10'))])",
+			Reason: "job properties are not converted; use the options, parameters, and triggers directives"},
+		{Line: 6, Column: 5, Source: "sh 'git submodule update --init'", Reason: `step outside a stage was placed in stage "Stage 1"`},
+		{Line: 24, Column: 9, Source: "def target = env.BRANCH_NAME == 'main' ? 'prod' : 'staging'", Reason: "kept in a script step"},
+	}, result.Notes)
+}
+
+func TestToDeclarativeAgents(t *testing.T) {
+	result, err := ToDeclarative([]byte(`
+node('linux') {
+    stage('Build') { sh 'make' }
+}
+stage('Approve') {
+    node {
+        echo 'approved'
+    }
+}
+`))
+	require.NoError(t, err)
+	p := result.Root.Pipeline
+	assert.Equal(t, "none", p.Agent.Type)
+	require.Len(t, p.Stages, 2)
+	assert.Equal(t, "label", p.Stages[0].Agent.Type)
+	assert.Equal(t, "linux", p.Stages[0].Agent.Argument.String())
+	assert.Equal(t, "any", p.Stages[1].Agent.Type)
+	assert.Equal(t, "runs outside a node block, so the stage has no agent", result.Notes[0].Reason)
+}
+
+func TestToDeclarativeRawGroovy(t *testing.T) {
+	result, err := ToDeclarative([]byte("node {\n  stage('Build') {\n    def v = readFile('VERSION')\n    sh 'make'\n  }\n}"))
+	require.NoError(t, err)
+	steps := result.Root.Pipeline.Stages[0].Branches[0].Steps
+	require.Len(t, steps, 2)
+	assert.Equal(t, "script", steps[0].Step.Name)
+	assert.Equal(t, &model.RawGroovy{Source: "def v = readFile('VERSION')", Reason: "not a step call"}, steps[0].Step.Raw)
+	assert.Nil(t, steps[1].Step.Raw)
+}
+
+func TestToDeclarativeNoStages(t *testing.T) {
+	_, err := ToDeclarative([]byte("println 'hello'"))
+	assert.EqualError(t, err, "no stages found")
+}
+
+func TestToDeclarativeInvariants(t *testing.T) {
+	result, err := ToDeclarative([]byte("node('a') { sh 'a' }\nnode('b') { sh 'b' }"))
+	require.NoError(t, err)
+	require.Len(t, result.Root.Pipeline.Stages, 2)
+	assert.Equal(t, "Stage 2", result.Root.Pipeline.Stages[1].Name)
+
+	result, err = ToDeclarative([]byte("node {\n  parallel a: { sh 'a' }\n  parallel b: { sh 'b' }\n}"))
+	require.NoError(t, err)
+	require.Len(t, result.Root.Pipeline.Stages, 2)
+	assert.Equal(t, "Parallel", result.Root.Pipeline.Stages[0].Name)
+	assert.Equal(t, "Parallel 2", result.Root.Pipeline.Stages[1].Name)
+	assert.Equal(t, `parallel branches outside a stage were placed in stage "Parallel 2"`, result.Notes[1].Reason)
+
+	_, err = ToDeclarative([]byte("node {\n  stage('Build') { sh 'a' }\n  stage('Build') { sh 'b' }\n}"))
+	assert.EqualError(t, err, `pipeline violates model invariants: stage "Build" is not the only stage with its name`)
+}
+
+func TestToDeclarativeProvenance(t *testing.T) {
+	result, err := ToDeclarative([]byte("node {\n  sh 'a'\n  stage('Build') {\n    parallel a: { sh 'b' }\n  }\n}"))
+	require.NoError(t, err)
+	assert.Equal(t, []*model.StageProvenance{
+		{Stage: "Stage 1", Path: "/pipeline/stages/0", Provenance: []string{"scripted: steps outside a stage at line 2"}},
+		{Stage: "Build", Path: "/pipeline/stages/1", Provenance: []string{"scripted: stage block at line 3"}},
+		{Stage: "a", Path: "/pipeline/stages/1/parallel/0", Provenance: []string{"scripted: parallel branch at line 4"}},
+	}, model.ProvenanceReport(result.Root))
+}
@@ -0,0 +1,328 @@
+// Package simulate works out how a build of a pipeline ends for a hypothetical scenario, such as a given stage
+// failing: the result of each stage and of the build, which stages are skipped, and which post blocks run.
+package simulate
+
+import (
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+// Result is a build or stage result
+type Result string
+
+// Results, from best to worst
+const (
+	Success  Result = "SUCCESS"
+	Unstable Result = "UNSTABLE"
+	Failure  Result = "FAILURE"
+	NotBuilt Result = "NOT_BUILT"
+	Aborted  Result = "ABORTED"
+)
+
+var ordinals = map[Result]int{Success: 0, Unstable: 1, Failure: 2, NotBuilt: 3, Aborted: 4}
+
+// WorseThan returns whether r is worse than other, in the order Jenkins ranks results.
+func (r Result) WorseThan(other Result) bool {
+	return ordinals[r] > ordinals[other]
+}
+
+// worst returns the worse of two results, which is how Jenkins combines them: a result only ever gets worse.
+func worst(a, b Result) Result {
+	if b.WorseThan(a) {
+		return b
+	}
+	return a
+}
+
+// Reasons a stage is skipped
+const (
+	// SkippedWhen is for stages whose when conditions aren't met.
+	SkippedWhen = "when"
+	// SkippedFailure is for stages after an earlier stage failed.
+	SkippedFailure = "failure"
+)
+
+// Scenario describes a hypothetical build
+type Scenario struct {
+	// Name identifies the scenario in reports.
+	Name string `json:"name"`
+	// Failures are how stages' steps fail, by stage ID: Failure or Aborted for a step which throws an error, such as
+	// an sh step exiting non-zero, or Unstable for one marking the build unstable, such as junit finding failed tests.
+	// The failing step is the stage's first sh, bat, powershell, or pwsh step, or its first step if it has none.
+	Failures map[string]Result `json:"failures,omitempty"`
+	// Previous is the result of the previous build, for the changed, fixed, and regression conditions. If it's empty,
+	// the context's previous result is used, and if that's empty too, the build is taken to be the first, for which
+	// changed is met and fixed and regression aren't.
+	Previous Result `json:"previous,omitempty"`
+	// Context decides the stages' when conditions, which see the build's result so far and the previous build's
+	// result. If it's nil, or a condition can't be decided, the stage runs.
+	Context *when.Context `json:"context,omitempty"`
+}
+
+// StageRun is the outcome of a stage
+type StageRun struct {
+	ID string `json:"id"`
+	// Result is the stage's result, or empty if it was skipped.
+	Result Result `json:"result,omitempty"`
+	// Skipped is why the stage didn't run, SkippedWhen or SkippedFailure, or empty if it ran.
+	Skipped string `json:"skipped,omitempty"`
+	// Post are the stage's post conditions whose blocks run, in the order they run.
+	Post []string `json:"post,omitempty"`
+}
+
+// Run is the outcome of a simulated build
+type Run struct {
+	Scenario string `json:"scenario,omitempty"`
+	// Result is the build's result.
+	Result Result `json:"result"`
+	// Stages are the outcomes of every stage, parents before their children, in the order they appear.
+	Stages []*StageRun `json:"stages"`
+	// Post are the pipeline's post conditions whose blocks run, in the order they run.
+	Post []string `json:"post,omitempty"`
+	// Warnings describe steps whose effect on the results couldn't be worked out, and so were ignored.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Stage returns the outcome of the stage with the given ID, or nil if there's no such stage.
+func (strct *Run) Stage(id string) *StageRun {
+	for _, s := range strct.Stages {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// resultSteps are the steps which change the result of the build and stage, and so aren't taken to be the failing step
+var resultSteps = map[string]bool{"catchError": true, "warnError": true, "unstable": true, "error": true}
+
+type simulator struct {
+	scenario *Scenario
+	run      *Run
+	// build is the build's result so far.
+	build Result
+	// previous is the previous build's result, if there was one.
+	previous Result
+}
+
+// Simulate works out the outcome of a build of the pipeline in the given scenario.
+//
+// Stages run in order until one fails, and the rest are skipped. The branches of a parallel stage all run, and its
+// result is the worst of theirs; if one fails, the parallel stage fails once they finish, unless failFast is set, in
+// which case the branches which didn't fail themselves are aborted. Matrix cells are treated as parallel branches.
+// Steps which change results, as recognized by ParseResultStep, take effect when they're reached: errors thrown
+// inside catchError and warnError blocks are caught, setting the build and stage results as the block's arguments
+// say, and the stage carries on; the error step fails the stage unless it's caught; and the unstable step and
+// assignments to currentBuild.result make the results worse. Assignments are taken to run even if they're
+// conditional.
+//
+// Post conditions are evaluated against the worse of the build's result so far and the stage's result, as Jenkins
+// does, so a stage's success block doesn't run once an earlier stage has made the build unstable.
+func Simulate(p *plan.Plan, s *Scenario) *Run {
+	sim := &simulator{scenario: s, run: &Run{Scenario: s.Name, Stages: []*StageRun{}}, build: Success, previous: s.Previous}
+	if sim.previous == "" && s.Context != nil {
+		sim.previous = Result(s.Context.PreviousResult)
+	}
+	sim.sequence(p.Stages, "")
+	sim.run.Result = sim.build
+	sim.run.Post = sim.post(p.Post, sim.build)
+	return sim.run
+}
+
+// sequence runs stages in order, returning their combined result and whether one failed. Once one fails, the rest
+// are skipped; if skip is set, they're all skipped for that reason.
+func (sim *simulator) sequence(stages []*plan.Stage, skip string) (Result, bool) {
+	result, failed := Success, false
+	for _, s := range stages {
+		if failed && skip == "" {
+			skip = SkippedFailure
+		}
+		run, f := sim.stage(s, skip)
+		result = worst(result, run.Result)
+		failed = failed || f
+	}
+	return result, failed
+}
+
+// parallel runs stages as the branches of a parallel or matrix stage
+func (sim *simulator) parallel(stages []*plan.Stage, failFast bool, skip string) (Result, bool) {
+	if skip != "" {
+		return sim.sequence(stages, skip)
+	}
+	runs := make([]*StageRun, len(stages))
+	result, failed := Success, false
+	for i, s := range stages {
+		run, f := sim.stage(s, "")
+		runs[i] = run
+		result = worst(result, run.Result)
+		failed = failed || f
+	}
+	if failed && failFast {
+		// The other branches are aborted while they're running, so their post blocks see an aborted stage.
+		for i, run := range runs {
+			if run.Skipped == "" && !run.Result.WorseThan(Unstable) {
+				run.Result = Aborted
+				run.Post = sim.post(stages[i].Post, Aborted)
+			}
+		}
+	}
+	return result, failed
+}
+
+// stage runs a stage, returning its outcome and whether it failed. If skip is set, it and its nested stages are
+// skipped for that reason.
+func (sim *simulator) stage(s *plan.Stage, skip string) (*StageRun, bool) {
+	run := &StageRun{ID: s.ID, Skipped: skip}
+	sim.run.Stages = append(sim.run.Stages, run)
+	if skip == "" && sim.scenario.Context != nil {
+		// Conditions may compare the build's result so far.
+		ctx := *sim.scenario.Context
+		ctx.Result = string(sim.build)
+		ctx.PreviousResult = string(sim.previous)
+		if when.Evaluate(s.When, &ctx) == when.False {
+			run.Skipped = SkippedWhen
+		}
+	}
+	if run.Skipped != "" {
+		for _, c := range s.Children {
+			sim.stage(c, run.Skipped)
+		}
+		return run, false
+	}
+
+	result, failed := sim.steps(s)
+	skip = ""
+	if failed {
+		skip = SkippedFailure
+	}
+	switch s.ChildMode {
+	case plan.Sequential:
+		r, f := sim.sequence(s.Children, skip)
+		result, failed = worst(result, r), failed || f
+	case plan.Parallel, plan.Matrix:
+		r, f := sim.parallel(s.Children, s.FailFast, skip)
+		result, failed = worst(result, r), failed || f
+	}
+	if failed {
+		result = worst(result, Failure)
+	}
+	run.Result = result
+	sim.build = worst(sim.build, result)
+	run.Post = sim.post(s.Post, sim.build)
+	return run, failed
+}
+
+// steps runs a stage's own steps, returning its result and whether a step failed uncaught
+func (sim *simulator) steps(s *plan.Stage) (Result, bool) {
+	failure, ok := sim.scenario.Failures[s.ID]
+	var failing *plan.Step
+	if ok {
+		failing = failingStep(s.Steps)
+	}
+	result := Success
+	set := func(stage, build Result) {
+		result = worst(result, stage)
+		sim.build = worst(sim.build, build)
+	}
+	// visit runs steps until one throws an error its enclosing blocks don't catch, returning the error's result.
+	var visit func(steps []*plan.Step) Result
+	visit = func(steps []*plan.Step) Result {
+		for _, st := range steps {
+			rs, err := ParseResultStep(st.Source)
+			if err != nil {
+				sim.run.Warnings = append(sim.run.Warnings, fmt.Sprintf("%s: %s", s.ID, err))
+			}
+			switch {
+			case st == failing && failure == Unstable:
+				set(Unstable, Unstable)
+			case st == failing:
+				return failure
+			case rs != nil && rs.Throws:
+				return rs.BuildResult
+			case rs != nil && !rs.Catches:
+				set(rs.StageResult, rs.BuildResult)
+			}
+			if thrown := visit(st.Children); thrown != "" {
+				if rs == nil || !rs.Catches || (thrown == Aborted && !rs.CatchInterruptions) {
+					return thrown
+				}
+				// The rest of the catching block is skipped, but the stage carries on after it.
+				set(rs.StageResult, rs.BuildResult)
+			}
+		}
+		return ""
+	}
+	if thrown := visit(s.Steps); thrown != "" {
+		set(thrown, thrown)
+		return result, true
+	}
+	return result, false
+}
+
+// failingStep returns the step which fails in a stage: its first script step, or its first step if it has none
+func failingStep(steps []*plan.Step) *plan.Step {
+	var first *plan.Step
+	var visit func(steps []*plan.Step) *plan.Step
+	visit = func(steps []*plan.Step) *plan.Step {
+		for _, st := range steps {
+			if st.Script != "" {
+				return st
+			}
+			if first == nil && len(st.Children) == 0 && !resultSteps[st.Name] {
+				first = st
+			}
+			if found := visit(st.Children); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if found := visit(steps); found != nil {
+		return found
+	}
+	return first
+}
+
+// post returns the post conditions whose blocks run, given the result they're evaluated against
+func (sim *simulator) post(blocks []*plan.PostBlock, current Result) []string {
+	var out []string
+	for _, condition := range model.PostConditions {
+		for _, b := range blocks {
+			if b.Condition == condition && Met(condition, current, sim.previous) {
+				out = append(out, condition)
+			}
+		}
+	}
+	return out
+}
+
+// Met returns whether a post condition is met for a build with the current result, whose previous build's result was
+// previous. An empty previous result means there was no previous build.
+func Met(condition string, current, previous Result) bool {
+	switch condition {
+	case "always", "cleanup":
+		return true
+	case "changed":
+		return previous == "" || current != previous
+	case "fixed":
+		return current == Success && (previous == Failure || previous == Unstable)
+	case "regression":
+		return previous != "" && current.WorseThan(previous)
+	case "aborted":
+		return current == Aborted
+	case "success":
+		return current == Success
+	case "unsuccessful":
+		return current != Success
+	case "unstable":
+		return current == Unstable
+	case "failure":
+		return current == Failure
+	case "notBuilt":
+		return current == NotBuilt
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package simulate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/abayer/go-jenkinsfile/when"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadPlan(t *testing.T, name string) *plan.Plan {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(b, root))
+	p, err := plan.Build(root)
+	require.NoError(t, err)
+	return p
+}
+
+// outcomes summarizes the stages of a run as "result" or "skipped:reason", by stage ID
+func outcomes(run *Run) map[string]string {
+	out := map[string]string{}
+	for _, s := range run.Stages {
+		if s.Skipped != "" {
+			out[s.ID] = "skipped:" + s.Skipped
+		} else {
+			out[s.ID] = string(s.Result)
+		}
+	}
+	return out
+}
+
+func TestSimulate(t *testing.T) {
+	p := loadPlan(t, "pipeline")
+
+	run := Simulate(p, &Scenario{Name: "green", Previous: Failure})
+	assert.Equal(t, "green", run.Scenario)
+	assert.Equal(t, Success, run.Result)
+	assert.Equal(t, []string{"always", "changed", "fixed", "success"}, run.Post)
+	assert.Equal(t, []string{"success"}, run.Stage("Tests/Integration").Post)
+	assert.Equal(t, []string{"always"}, run.Stage("Tests/Unit").Post)
+
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Tests/Unit": Failure}, Previous: Failure})
+	assert.Equal(t, Failure, run.Result)
+	assert.Equal(t, map[string]string{
+		"Build": "SUCCESS", "Tests": "FAILURE", "Tests/Unit": "FAILURE", "Tests/Integration": "SUCCESS",
+		"Tests/Lint": "SUCCESS", "Deploy": "skipped:failure",
+	}, outcomes(run))
+	assert.Equal(t, []string{"always", "failure"}, run.Stage("Tests/Unit").Post)
+	// The build has already failed when Integration finishes, so its failure block runs rather than success.
+	assert.Equal(t, []string{"failure"}, run.Stage("Tests/Integration").Post)
+	assert.Equal(t, []string{"always", "failure"}, run.Post)
+
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Build": Aborted}})
+	assert.Equal(t, Aborted, run.Result)
+	assert.Equal(t, "skipped:failure", outcomes(run)["Tests/Lint"])
+	assert.Equal(t, []string{"always", "changed"}, run.Post)
+}
+
+func TestSimulateCaughtErrors(t *testing.T) {
+	p := loadPlan(t, "pipeline")
+
+	run := Simulate(p, &Scenario{Failures: map[string]Result{"Tests/Integration": Failure}})
+	assert.Equal(t, Failure, run.Result)
+	assert.Equal(t, "FAILURE", outcomes(run)["Tests/Integration"])
+	assert.Equal(t, "FAILURE", outcomes(run)["Tests"])
+	assert.Equal(t, "SUCCESS", outcomes(run)["Deploy"], "catchError lets the build carry on")
+
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Tests/Lint": Failure}, Previous: Success})
+	assert.Equal(t, Unstable, run.Result)
+	assert.Equal(t, map[string]string{
+		"Build": "SUCCESS", "Tests": "UNSTABLE", "Tests/Unit": "SUCCESS", "Tests/Integration": "SUCCESS",
+		"Tests/Lint": "UNSTABLE", "Deploy": "SUCCESS",
+	}, outcomes(run))
+	assert.Equal(t, []string{"always", "changed", "unstable"}, run.Post)
+
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Tests/Unit": Unstable}})
+	assert.Equal(t, Unstable, run.Result)
+	assert.Equal(t, "UNSTABLE", outcomes(run)["Tests"])
+}
+
+func TestSimulateFailFast(t *testing.T) {
+	p := loadPlan(t, "pipeline")
+	p.Stages[1].FailFast = true
+
+	run := Simulate(p, &Scenario{Failures: map[string]Result{"Tests/Lint": Failure, "Tests/Unit": Failure}})
+	assert.Equal(t, Failure, run.Result)
+	assert.Equal(t, map[string]string{
+		"Build": "SUCCESS", "Tests": "FAILURE", "Tests/Unit": "FAILURE", "Tests/Integration": "ABORTED",
+		"Tests/Lint": "ABORTED", "Deploy": "skipped:failure",
+	}, outcomes(run))
+	assert.Equal(t, []string{"aborted"}, run.Stage("Tests/Integration").Post)
+}
+
+func TestSimulateWhen(t *testing.T) {
+	p := loadPlan(t, "pipeline")
+
+	run := Simulate(p, &Scenario{Context: &when.Context{Branch: "feature"}})
+	assert.Equal(t, "skipped:when", outcomes(run)["Deploy"])
+	assert.Equal(t, Success, run.Result)
+
+	run = Simulate(p, &Scenario{Context: &when.Context{Branch: "main"}})
+	assert.Equal(t, "SUCCESS", outcomes(run)["Deploy"])
+	assert.Nil(t, run.Stage("Missing"))
+
+	// The context's previous result is shared with the post conditions.
+	run = Simulate(p, &Scenario{Context: &when.Context{Branch: "main", PreviousResult: "FAILURE"}})
+	assert.Equal(t, []string{"always", "changed", "fixed", "success"}, run.Post)
+}
+
+func TestMet(t *testing.T) {
+	assert.True(t, Met("regression", Unstable, Success))
+	assert.False(t, Met("regression", Success, Unstable))
+	assert.False(t, Met("regression", Failure, ""))
+	assert.True(t, Met("fixed", Success, Unstable))
+	assert.False(t, Met("fixed", Success, Aborted))
+	assert.True(t, Met("unsuccessful", NotBuilt, Success))
+	assert.True(t, Met("notBuilt", NotBuilt, Success))
+	assert.False(t, Met("changed", Success, Success))
+	assert.False(t, Met("bogus", Success, Success))
+	assert.True(t, Aborted.WorseThan(NotBuilt))
+}
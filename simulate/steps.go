@@ -0,0 +1,124 @@
+package simulate
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// CatchError is the arguments of a catchError block, which catches errors thrown by its steps and sets the build and
+// stage results instead
+type CatchError struct {
+	Message     string `jenkins:"message,default"`
+	BuildResult string `jenkins:"buildResult"`
+	StageResult string `jenkins:"stageResult"`
+	// CatchInterruptions is whether the block also catches the build being aborted. It's true unless set to false.
+	CatchInterruptions *bool `jenkins:"catchInterruptions"`
+}
+
+// WarnError is the arguments of a warnError block, which catches errors thrown by its steps and marks the build and
+// stage unstable instead
+type WarnError struct {
+	Message            string `jenkins:"message,default,required"`
+	CatchInterruptions *bool  `jenkins:"catchInterruptions"`
+}
+
+// MessageStep is the arguments of the error and unstable steps
+type MessageStep struct {
+	Message string `jenkins:"message,default,required"`
+}
+
+// ResultStep is a step which changes the build or stage result
+type ResultStep struct {
+	// Name is the step's name, or "script" for script steps setting currentBuild.result.
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+	// Catches is true for catchError and warnError blocks, which catch errors thrown by their steps and set the
+	// build's result to BuildResult and the stage's to StageResult instead. Other steps set them when they run.
+	Catches     bool   `json:"catches,omitempty"`
+	BuildResult Result `json:"buildResult,omitempty"`
+	StageResult Result `json:"stageResult,omitempty"`
+	// CatchInterruptions is whether a block also catches the build being aborted.
+	CatchInterruptions bool `json:"catchInterruptions,omitempty"`
+	// Throws is true for the error step, which always fails.
+	Throws bool `json:"throws,omitempty"`
+}
+
+// resultAssignment matches assignments of a constant to currentBuild.result in Groovy
+var resultAssignment = regexp.MustCompile(`\bcurrentBuild\.result\s*=\s*['"]([A-Z_]+)['"]`)
+
+// ParseResultStep recognizes steps which change the build or stage result: catchError, warnError, unstable, error,
+// and script steps assigning a constant to currentBuild.result. It returns nil for other steps, and an error if a
+// step's arguments can't be decoded or name an unknown result.
+func ParseResultStep(s *model.AnyStep) (*ResultStep, error) {
+	var name string
+	var args *model.ArgumentList
+	switch {
+	case s == nil:
+		return nil, nil
+	case s.Tree != nil:
+		name, args = s.Tree.Name, s.Tree.Arguments
+	case s.Step != nil:
+		name, args = s.Step.Name, s.Step.Arguments
+	default:
+		return nil, nil
+	}
+
+	switch name {
+	case "catchError":
+		var a CatchError
+		if err := args.Bind(&a); err != nil {
+			return nil, fmt.Errorf("catchError: %s", err)
+		}
+		rs := &ResultStep{Name: name, Message: a.Message, Catches: true, BuildResult: Failure, StageResult: Failure,
+			CatchInterruptions: a.CatchInterruptions == nil || *a.CatchInterruptions}
+		for _, r := range []struct {
+			value string
+			dst   *Result
+		}{{a.BuildResult, &rs.BuildResult}, {a.StageResult, &rs.StageResult}} {
+			if r.value == "" {
+				continue
+			}
+			if _, ok := ordinals[Result(r.value)]; !ok {
+				return nil, fmt.Errorf("catchError: unknown result %q", r.value)
+			}
+			*r.dst = Result(r.value)
+		}
+		return rs, nil
+	case "warnError":
+		var a WarnError
+		if err := args.Bind(&a); err != nil {
+			return nil, fmt.Errorf("warnError: %s", err)
+		}
+		return &ResultStep{Name: name, Message: a.Message, Catches: true, BuildResult: Unstable,
+			StageResult: Unstable, CatchInterruptions: a.CatchInterruptions == nil || *a.CatchInterruptions}, nil
+	case "unstable", "error":
+		if s.Step == nil {
+			return nil, nil
+		}
+		var a MessageStep
+		if err := args.Bind(&a); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if name == "error" {
+			return &ResultStep{Name: name, Message: a.Message, Throws: true, BuildResult: Failure,
+				StageResult: Failure}, nil
+		}
+		return &ResultStep{Name: name, Message: a.Message, BuildResult: Unstable, StageResult: Unstable}, nil
+	case "script":
+		if s.Step == nil {
+			return nil, nil
+		}
+		m := resultAssignment.FindStringSubmatch(args.Get("scriptBlock").String())
+		if m == nil {
+			return nil, nil
+		}
+		if _, ok := ordinals[Result(m[1])]; !ok {
+			return nil, fmt.Errorf("script: unknown result %q", m[1])
+		}
+		// Setting currentBuild.result only changes the build's result, not the stage's.
+		return &ResultStep{Name: name, BuildResult: Result(m[1])}, nil
+	}
+	return nil, nil
+}
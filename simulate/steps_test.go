@@ -0,0 +1,93 @@
+package simulate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/when"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseStep(t *testing.T, src string) *model.AnyStep {
+	s := &model.AnyStep{}
+	require.NoError(t, json.Unmarshal([]byte(src), s))
+	return s
+}
+
+func TestParseResultStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		step     string
+		expected *ResultStep
+		err      string
+	}{
+		{name: "catchError", step: `{"name": "catchError", "arguments": [], "children": []}`,
+			expected: &ResultStep{Name: "catchError", Catches: true, BuildResult: Failure, StageResult: Failure,
+				CatchInterruptions: true}},
+		{name: "catchError results", step: `{"name": "catchError", "arguments": [
+			{"key": "buildResult", "value": {"isLiteral": true, "value": "SUCCESS"}},
+			{"key": "stageResult", "value": {"isLiteral": true, "value": "ABORTED"}},
+			{"key": "catchInterruptions", "value": {"isLiteral": true, "value": false}}], "children": []}`,
+			expected: &ResultStep{Name: "catchError", Catches: true, BuildResult: Success, StageResult: Aborted}},
+		{name: "catchError bad result", step: `{"name": "catchError", "arguments": [
+			{"key": "buildResult", "value": {"isLiteral": true, "value": "BROKEN"}}], "children": []}`,
+			err: `catchError: unknown result "BROKEN"`},
+		{name: "warnError", step: `{"name": "warnError", "arguments": {"isLiteral": true, "value": "oops"}, "children": []}`,
+			expected: &ResultStep{Name: "warnError", Message: "oops", Catches: true, BuildResult: Unstable,
+				StageResult: Unstable, CatchInterruptions: true}},
+		{name: "warnError without message", step: `{"name": "warnError", "arguments": [], "children": []}`,
+			err: `warnError: argument "message" is required`},
+		{name: "unstable", step: `{"name": "unstable", "arguments": [{"key": "message", "value": {"isLiteral": true, "value": "flaky"}}]}`,
+			expected: &ResultStep{Name: "unstable", Message: "flaky", BuildResult: Unstable, StageResult: Unstable}},
+		{name: "error", step: `{"name": "error", "arguments": {"isLiteral": true, "value": "no"}}`,
+			expected: &ResultStep{Name: "error", Message: "no", Throws: true, BuildResult: Failure, StageResult: Failure}},
+		{name: "script", step: `{"name": "script", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true,
+			"value": "currentBuild.result = \"NOT_BUILT\""}}]}`,
+			expected: &ResultStep{Name: "script", BuildResult: NotBuilt}},
+		{name: "other script", step: `{"name": "script", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true,
+			"value": "echo currentBuild.result"}}]}`},
+		{name: "sh", step: `{"name": "sh", "arguments": [{"key": "script", "value": {"isLiteral": true, "value": "exit 1"}}]}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := ParseResultStep(parseStep(t, tc.step))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rs)
+		})
+	}
+}
+
+func TestSimulateResultSteps(t *testing.T) {
+	p := loadPlan(t, "results")
+
+	run := Simulate(p, &Scenario{Context: &when.Context{}})
+	assert.Equal(t, Failure, run.Result)
+	assert.Equal(t, map[string]string{
+		"Check": "SUCCESS", "Gate": "FAILURE", "Report": "SUCCESS", "Fail": "FAILURE",
+	}, outcomes(run))
+
+	// The check's failure is caught, and only marks the stage unstable.
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Check": Failure}, Context: &when.Context{}})
+	assert.Equal(t, "UNSTABLE", outcomes(run)["Check"])
+	assert.Equal(t, Failure, run.Result)
+
+	// catchInterruptions is false, so aborting the check aborts the build.
+	run = Simulate(p, &Scenario{Failures: map[string]Result{"Check": Aborted}, Context: &when.Context{}})
+	assert.Equal(t, Aborted, run.Result)
+	assert.Equal(t, "skipped:failure", outcomes(run)["Gate"])
+}
+
+func TestSimulateResultWhen(t *testing.T) {
+	p := loadPlan(t, "results")
+	p.Stages[1].Steps = p.Stages[1].Steps[:1]
+
+	run := Simulate(p, &Scenario{Context: &when.Context{}})
+	assert.Equal(t, "skipped:when", outcomes(run)["Report"], "the build is only unstable when Report's condition is checked")
+	assert.Equal(t, "SUCCESS", outcomes(run)["Gate"], "assigning currentBuild.result doesn't change the stage's result")
+}
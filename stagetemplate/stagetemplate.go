@@ -0,0 +1,411 @@
+// Package stagetemplate makes reusable stages: fragments of one or more stages with typed parameters, whose values
+// are substituted into the stages' literal arguments when they're instantiated.
+//
+// Parameters are referred to by placeholders such as "{{ .image }}", as in fleet templates. A literal which is just a
+// placeholder becomes the parameter's value with its type, so '{{ .replicas }}' becomes the number 3 rather than the
+// string "3". Placeholders within longer literals, such as 'deploy-{{ .env }}', are replaced by the value's text. List
+// parameters expand into several values, and so can only be used by themselves in lists of values: the values of a
+// matrix axis or exclude, and the unnamed arguments of a step. Placeholders may also be used in stage names.
+// Substituting into Groovy isn't safe, so placeholders in arguments which aren't literals, such as interpolated
+// strings, are errors.
+package stagetemplate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Type is the type of a parameter's values
+type Type string
+
+// The types of parameters
+const (
+	String Type = "string"
+	Int    Type = "int"
+	Bool   Type = "bool"
+	// List parameters are lists of strings, numbers, and booleans.
+	List Type = "list"
+)
+
+// Parameter is a value a template needs
+type Parameter struct {
+	Name        string `json:"name"`
+	Type        Type   `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Default is the value used when none is given. Parameters without one are required.
+	Default interface{} `json:"default,omitempty"`
+}
+
+// Template is a fragment of stages with parameters
+type Template struct {
+	Name       string       `json:"name"`
+	Parameters []*Parameter `json:"parameters,omitempty"`
+	// Stages are the fragment, with placeholders for the parameters' values.
+	Stages []*model.Stage `json:"stages"`
+}
+
+// placeholder matches a placeholder, capturing the parameter's name
+var placeholder = regexp.MustCompile(`\{\{ *\.([A-Za-z][A-Za-z0-9_]*) *\}\}`)
+
+var parameterName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Check returns an error if the template's parameters are invalid, or its stages use a parameter it doesn't declare
+func (strct *Template) Check() error {
+	params, err := strct.parameters()
+	if err != nil {
+		return err
+	}
+	_, err = strct.instantiate(params, nil)
+	return err
+}
+
+// Instantiate returns a copy of the template's stages with the given parameter values substituted, leaving the
+// template untouched. Values are given by parameter name, and may be of the parameter's type or, as decoded from JSON
+// or YAML, a number with an integer value for an int. It returns an error if a required value is missing, a value
+// has the wrong type or isn't a parameter, or the stages are invalid once instantiated, such as if two have the same
+// name.
+func (strct *Template) Instantiate(values map[string]interface{}) ([]*model.Stage, error) {
+	params, err := strct.parameters()
+	if err != nil {
+		return nil, err
+	}
+	resolved := map[string]interface{}{}
+	var unknown, missing []string
+	for name, v := range values {
+		p, ok := params[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if resolved[name], err = p.normalize(v); err != nil {
+			return nil, err
+		}
+	}
+	for name, p := range params {
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		if p.Default == nil {
+			missing = append(missing, name)
+			continue
+		}
+		if resolved[name], err = p.normalize(p.Default); err != nil {
+			return nil, fmt.Errorf("default: %s", err)
+		}
+	}
+	sort.Strings(unknown)
+	sort.Strings(missing)
+	switch {
+	case len(unknown) > 0:
+		return nil, fmt.Errorf("template %s has no parameters %s", strct.Name, strings.Join(unknown, ", "))
+	case len(missing) > 0:
+		return nil, fmt.Errorf("missing values for %s", strings.Join(missing, ", "))
+	}
+	return strct.instantiate(params, resolved)
+}
+
+// parameters checks the template's parameters, and returns them by name
+func (strct *Template) parameters() (map[string]*Parameter, error) {
+	params := map[string]*Parameter{}
+	for _, p := range strct.Parameters {
+		switch {
+		case p == nil || !parameterName.MatchString(p.Name):
+			return nil, fmt.Errorf("template %s has a parameter without a valid name", strct.Name)
+		case params[p.Name] != nil:
+			return nil, fmt.Errorf("template %s has more than one parameter %s", strct.Name, p.Name)
+		case p.Type != String && p.Type != Int && p.Type != Bool && p.Type != List:
+			return nil, fmt.Errorf("parameter %s has unknown type %q", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := p.normalize(p.Default); err != nil {
+				return nil, fmt.Errorf("default: %s", err)
+			}
+		}
+		params[p.Name] = p
+	}
+	return params, nil
+}
+
+// normalize checks a value has the parameter's type, and returns it as a string, int64, bool, or list of those and
+// float64s
+func (p *Parameter) normalize(v interface{}) (interface{}, error) {
+	switch p.Type {
+	case List:
+		var list []interface{}
+		switch t := v.(type) {
+		case []string:
+			for _, s := range t {
+				list = append(list, s)
+			}
+		case []interface{}:
+			for _, e := range t {
+				s, ok := scalar(e)
+				if !ok {
+					return nil, fmt.Errorf("parameter %s must be a list of strings, numbers, and booleans, not "+
+						"one holding %T", p.Name, e)
+				}
+				list = append(list, s)
+			}
+		default:
+			return nil, fmt.Errorf("parameter %s must be a list, not %T", p.Name, v)
+		}
+		return list, nil
+	case Int:
+		if s, ok := scalar(v); ok {
+			switch t := s.(type) {
+			case int64:
+				return t, nil
+			case float64:
+				if t == float64(int64(t)) {
+					return int64(t), nil
+				}
+			}
+		}
+	default:
+		if s, ok := scalar(v); ok && typeOf(s) == p.Type {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("parameter %s must be of type %s, not %T", p.Name, p.Type, v)
+}
+
+// scalar returns a string, number, or boolean as a string, int64, float64, or bool
+func scalar(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string, int64, float64, bool:
+		return t, true
+	case int:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case float32:
+		return float64(t), true
+	}
+	return nil, false
+}
+
+func typeOf(v interface{}) Type {
+	switch v.(type) {
+	case string:
+		return String
+	case bool:
+		return Bool
+	}
+	return Int
+}
+
+// raw returns a literal with a scalar value
+func raw(v interface{}) *model.RawArgument {
+	value := &model.RawArgumentValue{}
+	switch t := v.(type) {
+	case string:
+		value.AsString = &t
+	case int64:
+		value.AsInteger = &t
+	case float64:
+		value.AsFloat = &t
+	case bool:
+		value.AsBool = &t
+	}
+	return &model.RawArgument{IsLiteral: true, Value: value}
+}
+
+// text returns a scalar value as text for substituting into a string
+func text(v interface{}) string {
+	switch t := v.(type) {
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return v.(string)
+}
+
+// substituter substitutes parameter values into the template's stages. When values is nil, it only checks that the
+// placeholders name parameters, and are used where their parameters' values can go.
+type substituter struct {
+	params map[string]*Parameter
+	values map[string]interface{}
+}
+
+func (strct *Template) instantiate(params map[string]*Parameter, values map[string]interface{}) ([]*model.Stage,
+	error) {
+	s := &substituter{params: params, values: values}
+	root := &model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{}}}
+	for _, stage := range strct.Stages {
+		root.Pipeline.Stages = append(root.Pipeline.Stages, stage.DeepCopy())
+	}
+
+	// Expand lists first, so that any list placeholders left are in places a list can't go.
+	err := model.Walk(root, model.Visitor{
+		Matrix: func(path string, m *model.Matrix) error {
+			for i, axis := range m.Axes {
+				if axis == nil {
+					continue
+				}
+				var err error
+				if axis.Values, err = s.expand(fmt.Sprintf("%s/axes/%d/values", path, i), axis.Values); err != nil {
+					return err
+				}
+			}
+			for i, exclude := range m.Excludes {
+				for j, axis := range exclude {
+					if axis == nil {
+						continue
+					}
+					var err error
+					at := fmt.Sprintf("%s/excludes/%d/%d/values", path, i, j)
+					if axis.Values, err = s.expand(at, axis.Values); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Step: func(path string, step *model.Step) error {
+			return s.expandArguments(path+"/arguments", step.Arguments)
+		},
+		TreeStep: func(path string, tree *model.TreeStep) error {
+			return s.expandArguments(path+"/arguments", tree.Arguments)
+		},
+		WhenCondition: func(path string, c *model.StepOrNestedWhenCondition) error {
+			if c.Step == nil {
+				return nil
+			}
+			return s.expandArguments(path+"/arguments", c.Step.Arguments)
+		},
+	})
+	if err == nil {
+		err = model.Walk(root, model.Visitor{
+			Stage: func(path string, stage *model.Stage) error {
+				name, err := s.substitute(path+"/name", stage.Name)
+				stage.Name = name
+				return err
+			},
+			RawArgument: s.raw,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, nil
+	}
+	if err := model.CheckInvariants(root); err != nil {
+		return nil, err
+	}
+	return root.Pipeline.Stages, nil
+}
+
+// relative returns the path of a node within the template, rather than the pipeline holding its stages
+func relative(path string) string {
+	return strings.TrimPrefix(path, "/pipeline")
+}
+
+// expand replaces the literals which are just a list parameter's placeholder with the list's values
+func (s *substituter) expand(path string, args []*model.RawArgument) ([]*model.RawArgument, error) {
+	var out []*model.RawArgument
+	for i, a := range args {
+		name, ok := s.only(a)
+		if !ok || s.params[name] == nil || s.params[name].Type != List {
+			out = append(out, a)
+			continue
+		}
+		if s.values == nil {
+			continue
+		}
+		list := s.values[name].([]interface{})
+		if len(list) == 0 {
+			return nil, fmt.Errorf("%s/%d: list parameter %s is empty", relative(path), i, name)
+		}
+		for _, v := range list {
+			out = append(out, raw(v))
+		}
+	}
+	if out == nil && args != nil {
+		out = []*model.RawArgument{}
+	}
+	return out, nil
+}
+
+func (s *substituter) expandArguments(path string, args *model.ArgumentList) error {
+	if args == nil || args.Positional == nil {
+		return nil
+	}
+	var err error
+	args.Positional, err = s.expand(path, args.Positional)
+	return err
+}
+
+// only returns the name of the parameter a literal string is just the placeholder of
+func (s *substituter) only(a *model.RawArgument) (string, bool) {
+	if a == nil || !a.IsLiteral || a.Value == nil || a.Value.AsString == nil {
+		return "", false
+	}
+	v := strings.TrimSpace(*a.Value.AsString)
+	m := placeholder.FindStringSubmatch(v)
+	if m == nil || m[0] != v {
+		return "", false
+	}
+	return m[1], true
+}
+
+// raw substitutes values into a raw argument
+func (s *substituter) raw(path string, a *model.RawArgument) error {
+	if a.Value == nil || a.Value.AsString == nil {
+		return nil
+	}
+	if !a.IsLiteral {
+		if m := placeholder.FindStringSubmatch(*a.Value.AsString); m != nil {
+			return fmt.Errorf("%s: placeholder for %s in an argument which isn't a literal", relative(path), m[1])
+		}
+		return nil
+	}
+	if name, ok := s.only(a); ok {
+		p := s.params[name]
+		switch {
+		case p == nil:
+			return fmt.Errorf("%s: no parameter %s", relative(path), name)
+		case p.Type == List:
+			return fmt.Errorf("%s: list parameter %s can only be used in lists of values", relative(path), name)
+		case s.values != nil:
+			*a = *raw(s.values[name])
+		}
+		return nil
+	}
+	v, err := s.substitute(path, *a.Value.AsString)
+	a.Value.AsString = &v
+	return err
+}
+
+// substitute replaces the placeholders within a string with their values' text
+func (s *substituter) substitute(path string, in string) (string, error) {
+	var err error
+	out := placeholder.ReplaceAllStringFunc(in, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		p := s.params[name]
+		switch {
+		case err != nil:
+		case p == nil:
+			err = fmt.Errorf("%s: no parameter %s", relative(path), name)
+		case p.Type == List:
+			err = fmt.Errorf("%s: list parameter %s can only be used in lists of values", relative(path), name)
+		case s.values != nil:
+			return text(s.values[name])
+		}
+		return m
+	})
+	if err == nil && s.values != nil && placeholder.MatchString(out) && out != in {
+		// Values aren't substituted into again, but they mustn't look like placeholders either.
+		return out, errors.New(relative(path) + ": a value holds a placeholder")
+	}
+	return out, err
+}
@@ -0,0 +1,194 @@
+package stagetemplate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deploy = `pipeline {
+    agent any
+    stages {
+        stage('Deploy {{ .env }}') {
+            matrix {
+                axes {
+                    axis {
+                        name 'REGION'
+                        values '{{ .regions }}'
+                    }
+                }
+                stages {
+                    stage('Rollout') {
+                        steps {
+                            sh 'kubectl scale --replicas={{ .replicas }} deploy/app-{{ .env }}'
+                            retry('{{ .retries }}') {
+                                sh "./smoke.sh ${REGION}"
+                            }
+                            notify('{{ .channels }}', 'done')
+                        }
+                    }
+                }
+            }
+        }
+    }
+}`
+
+func template(t *testing.T, source string, params ...*Parameter) *Template {
+	root, err := parser.Parse(strings.NewReader(source))
+	require.NoError(t, err)
+	return &Template{Name: "deploy", Parameters: params, Stages: root.Pipeline.Stages}
+}
+
+func deployTemplate(t *testing.T) *Template {
+	return template(t, deploy,
+		&Parameter{Name: "env", Type: String},
+		&Parameter{Name: "regions", Type: List},
+		&Parameter{Name: "replicas", Type: Int, Default: 2},
+		&Parameter{Name: "retries", Type: Int, Default: 3},
+		&Parameter{Name: "channels", Type: List, Default: []string{"#deploys"}})
+}
+
+func render(t *testing.T, stages []*model.Stage) string {
+	buf := &bytes.Buffer{}
+	root := &model.Root{Pipeline: &model.Pipeline{Agent: &model.Agent{Type: "any"}, Stages: stages}}
+	require.NoError(t, writer.Write(root, buf))
+	return buf.String()
+}
+
+func TestInstantiate(t *testing.T) {
+	tmpl := deployTemplate(t)
+	require.NoError(t, tmpl.Check())
+	before := render(t, tmpl.Stages)
+
+	stages, err := tmpl.Instantiate(map[string]interface{}{
+		"env":      "prod",
+		"regions":  []interface{}{"eu", "us"},
+		"replicas": float64(5),
+	})
+	require.NoError(t, err)
+	out := render(t, stages)
+	assert.Contains(t, out, "stage('Deploy prod')")
+	assert.Contains(t, out, "values 'eu', 'us'")
+	assert.Contains(t, out, "sh 'kubectl scale --replicas=5 deploy/app-prod'")
+	assert.Contains(t, out, "retry(3)")
+	assert.Contains(t, out, "notify('#deploys', 'done')")
+	assert.NotContains(t, out, "{{")
+
+	assert.Equal(t, before, render(t, tmpl.Stages), "the template is left untouched")
+}
+
+func TestInstantiateValidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "missing",
+			values: map[string]interface{}{"env": "prod"},
+			err:    "missing values for regions",
+		},
+		{
+			name:   "unknown",
+			values: map[string]interface{}{"env": "prod", "regions": []string{"eu"}, "zone": "a"},
+			err:    "template deploy has no parameters zone",
+		},
+		{
+			name:   "wrong type",
+			values: map[string]interface{}{"env": "prod", "regions": []string{"eu"}, "replicas": "five"},
+			err:    "parameter replicas must be of type int, not string",
+		},
+		{
+			name:   "fractional int",
+			values: map[string]interface{}{"env": "prod", "regions": []string{"eu"}, "replicas": 2.5},
+			err:    "parameter replicas must be of type int, not float64",
+		},
+		{
+			name:   "empty list",
+			values: map[string]interface{}{"env": "prod", "regions": []string{}},
+			err:    "/stages/0/matrix/axes/0/values/0: list parameter regions is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := deployTemplate(t).Instantiate(tt.values)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		params []*Parameter
+		err    string
+	}{
+		{
+			name:   "undeclared",
+			source: strings.Replace(deploy, "{{ .retries }}", "{{ .attempts }}", 1),
+			params: deployTemplate(t).Parameters,
+			err:    "/stages/0/matrix/stages/0/branches/0/steps/1/arguments: no parameter attempts",
+		},
+		{
+			name:   "list in a single value",
+			source: strings.Replace(deploy, "'{{ .retries }}'", "'{{ .channels }}'", 1),
+			params: deployTemplate(t).Parameters,
+			err: "/stages/0/matrix/stages/0/branches/0/steps/1/arguments: list parameter channels can only " +
+				"be used in lists of values",
+		},
+		{
+			name:   "not a literal",
+			source: strings.Replace(deploy, `"./smoke.sh ${REGION}"`, `"./smoke.sh ${REGION} {{ .env }}"`, 1),
+			params: deployTemplate(t).Parameters,
+			err: "/stages/0/matrix/stages/0/branches/0/steps/1/children/0/arguments/0/value: placeholder for env " +
+				"in an argument which isn't a literal",
+		},
+		{
+			name:   "bad default",
+			source: deploy,
+			params: []*Parameter{{Name: "env", Type: Bool, Default: "yes"}},
+			err:    "default: parameter env must be of type bool, not string",
+		},
+		{
+			name:   "unknown type",
+			source: deploy,
+			params: []*Parameter{{Name: "env", Type: "map"}},
+			err:    `parameter env has unknown type "map"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, template(t, tt.source, tt.params...).Check(), tt.err)
+		})
+	}
+}
+
+func TestInstantiateDuplicateStages(t *testing.T) {
+	tmpl := template(t, `pipeline {
+    agent any
+    stages {
+        stage('Test {{ .suite }}') {
+            steps {
+                sh 'make test'
+            }
+        }
+        stage('Test unit') {
+            steps {
+                sh 'make unit'
+            }
+        }
+    }
+}`, &Parameter{Name: "suite", Type: String})
+	_, err := tmpl.Instantiate(map[string]interface{}{"suite": "unit"})
+	assert.Error(t, err)
+	stages, err := tmpl.Instantiate(map[string]interface{}{"suite": "e2e"})
+	require.NoError(t, err)
+	assert.Equal(t, "Test e2e", stages[0].Name)
+}
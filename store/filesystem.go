@@ -0,0 +1,106 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Filesystem is a Backend keeping each object in a file under a directory, with the segments of its name as
+// subdirectories
+type Filesystem struct {
+	dir string
+}
+
+// NewFilesystem returns a backend keeping objects under dir, which is created when the first object is stored
+func NewFilesystem(dir string) *Filesystem {
+	return &Filesystem{dir: dir}
+}
+
+func (strct *Filesystem) path(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(strct.dir, filepath.FromSlash(name)), nil
+}
+
+// Put writes the object to a temporary file and renames it into place, so readers never see part of it
+func (strct *Filesystem) Put(name string, content []byte) error {
+	path, err := strct.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Get reads an object's file
+func (strct *Filesystem) Get(name string) ([]byte, error) {
+	path, err := strct.path(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
+
+// List walks the directory for the objects starting with prefix
+func (strct *Filesystem) List(prefix string) ([]string, error) {
+	names := []string{}
+	err := filepath.Walk(strct.dir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err) && path == strct.dir:
+			return filepath.SkipDir
+		case err != nil:
+			return err
+		case info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-"):
+			return nil
+		}
+		rel, err := filepath.Rel(strct.dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes an object's file
+func (strct *Filesystem) Delete(name string) error {
+	path, err := strct.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
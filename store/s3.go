@@ -0,0 +1,228 @@
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3 backend
+type S3Config struct {
+	// Endpoint is the URL of the service, such as "https://s3.us-east-1.amazonaws.com" or a MinIO server's URL.
+	Endpoint string
+	// Region is the bucket's region. It defaults to "us-east-1", which most S3-compatible services accept.
+	Region string
+	Bucket string
+	// Prefix is prepended to the names of objects, so a bucket can be shared.
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Client sends the requests. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// S3 is a Backend keeping objects in an S3-compatible bucket, addressed by path rather than by virtual host, with
+// requests signed by AWS Signature Version 4
+type S3 struct {
+	config S3Config
+	now    func() time.Time
+}
+
+// NewS3 returns a backend keeping objects in the configured bucket
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("an endpoint and a bucket are required")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("an access key ID and a secret access key are required")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, err
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &S3{config: config, now: time.Now}, nil
+}
+
+// Put uploads an object
+func (strct *S3) Put(name string, content []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	_, err := strct.do(http.MethodPut, strct.config.Prefix+name, nil, content)
+	return err
+}
+
+// Get downloads an object
+func (strct *S3) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	return strct.do(http.MethodGet, strct.config.Prefix+name, nil, nil)
+}
+
+// listResult is the result of ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List lists the objects starting with prefix, a page at a time
+func (strct *S3) List(prefix string) ([]string, error) {
+	names := []string{}
+	query := url.Values{"list-type": {"2"}, "prefix": {strct.config.Prefix + prefix}}
+	for {
+		body, err := strct.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := &listResult{}
+		if err := xml.Unmarshal(body, result); err != nil {
+			return nil, fmt.Errorf("listing %s: %s", prefix, err)
+		}
+		for _, c := range result.Contents {
+			names = append(names, strings.TrimPrefix(c.Key, strct.config.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete deletes an object
+func (strct *S3) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	_, err := strct.do(http.MethodDelete, strct.config.Prefix+name, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// do sends a signed request for an object, or for the bucket if key is empty, and returns the response body. A 404
+// response is ErrNotFound.
+func (strct *S3) do(method, key string, query url.Values, content []byte) ([]byte, error) {
+	path := "/" + uriEncode(strct.config.Bucket, false)
+	if key != "" {
+		path += "/" + uriEncode(key, false)
+	}
+	rawQuery := canonicalQuery(query)
+	target := strct.config.Endpoint + path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	strct.sign(req, path, rawQuery, content)
+	resp, err := strct.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && key != "":
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// sign adds the headers signing a request with AWS Signature Version 4, given its path and query as sent
+func (strct *S3) sign(req *http.Request, path, rawQuery string, content []byte) {
+	now := strct.now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(content)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		rawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + stamp,
+		"",
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + strct.config.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + strct.config.SecretAccessKey)
+	for _, part := range []string{date, strct.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		strct.config.AccessKeyID, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes a query with its parameters sorted, as both sent and signed
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for k, values := range query {
+		for _, v := range values {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and "/" unless encodeSlash is set, as Signature
+// Version 4 requires
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~',
+			c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,280 @@
+// Package store persists parsed pipelines and their bundles, keyed by repository, branch, and fingerprint, so that
+// analyses of large fleets can skip pipelines which haven't changed since they were last stored. Objects are kept by
+// a Backend: in memory, in a directory, or in an S3-compatible bucket.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/abayer/go-jenkinsfile/bundle"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// ErrNotFound is the error getting an object which doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Backend keeps objects by name. Names are made of segments separated by "/", none of them empty, "." or "..".
+type Backend interface {
+	// Put stores an object, replacing any with the same name.
+	Put(name string, content []byte) error
+	// Get returns an object, or ErrNotFound if there is none with the name.
+	Get(name string) ([]byte, error)
+	// List returns the names of the objects starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+	// Delete removes an object. Deleting one which doesn't exist isn't an error.
+	Delete(name string) error
+}
+
+// checkName returns an error if name isn't a valid object name
+func checkName(name string) error {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid object name %q", name)
+		}
+	}
+	return nil
+}
+
+// Memory is a Backend keeping objects in memory, for tests and short-lived tools. It's safe for concurrent use.
+type Memory struct {
+	lock    sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemory returns an empty in-memory backend
+func NewMemory() *Memory {
+	return &Memory{objects: map[string][]byte{}}
+}
+
+// Put stores a copy of content
+func (strct *Memory) Put(name string, content []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	strct.objects[name] = append([]byte{}, content...)
+	return nil
+}
+
+// Get returns a copy of an object's content
+func (strct *Memory) Get(name string) ([]byte, error) {
+	strct.lock.RLock()
+	defer strct.lock.RUnlock()
+	content, ok := strct.objects[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, content...), nil
+}
+
+// List returns the names of the objects starting with prefix, sorted
+func (strct *Memory) List(prefix string) ([]string, error) {
+	strct.lock.RLock()
+	defer strct.lock.RUnlock()
+	names := []string{}
+	for name := range strct.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes an object
+func (strct *Memory) Delete(name string) error {
+	strct.lock.Lock()
+	defer strct.lock.Unlock()
+	delete(strct.objects, name)
+	return nil
+}
+
+// Kind is a kind of object the store keeps
+type Kind string
+
+const (
+	// Models are parsed pipelines, as model JSON
+	Models Kind = "models"
+	// Bundles are pipelines with their analyses, as from bundle.Build
+	Bundles Kind = "bundles"
+)
+
+// Key identifies a stored pipeline
+type Key struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// Fingerprint is the pipeline's fingerprint, such as from dedupe.Fingerprint or model.Hash.
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (k Key) String() string {
+	return k.Repo + "@" + k.Branch + "#" + k.Fingerprint
+}
+
+// name returns the name of the key's object of a kind. Each part of the key is escaped, so that repositories such
+// as "org/app" are a single segment.
+func (k Key) name(kind Kind) (string, error) {
+	if k.Repo == "" || k.Branch == "" || k.Fingerprint == "" {
+		return "", fmt.Errorf("key %s needs a repository, branch, and fingerprint", k)
+	}
+	return prefix(kind, k.Repo, k.Branch) + url.PathEscape(k.Fingerprint) + ".json", nil
+}
+
+// prefix returns the prefix of the names of objects of a kind, for a repository and branch if given
+func prefix(kind Kind, repo, branch string) string {
+	p := string(kind) + "/"
+	if repo != "" {
+		p += url.PathEscape(repo) + "/"
+		if branch != "" {
+			p += url.PathEscape(branch) + "/"
+		}
+	}
+	return p
+}
+
+// parseName returns the key of an object name
+func parseName(kind Kind, name string) (Key, error) {
+	parts := strings.Split(strings.TrimPrefix(name, string(kind)+"/"), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".json") {
+		return Key{}, fmt.Errorf("object %q isn't named for a key", name)
+	}
+	parts[2] = strings.TrimSuffix(parts[2], ".json")
+	for i, p := range parts {
+		var err error
+		if parts[i], err = url.PathUnescape(p); err != nil {
+			return Key{}, fmt.Errorf("object %q isn't named for a key: %s", name, err)
+		}
+	}
+	return Key{Repo: parts[0], Branch: parts[1], Fingerprint: parts[2]}, nil
+}
+
+// Store keeps models and bundles in a backend
+type Store struct {
+	backend Backend
+}
+
+// New returns a store keeping its objects in backend
+func New(backend Backend) *Store {
+	return &Store{backend: backend}
+}
+
+// PutModel stores a parsed pipeline. Unions with none of their alternatives set are errors, as with
+// model.StrictMarshal, rather than being left out of what's stored.
+func (strct *Store) PutModel(key Key, root *model.Root) error {
+	name, err := key.name(Models)
+	if err != nil {
+		return err
+	}
+	content, err := model.Marshal(root, model.StrictMarshal())
+	if err != nil {
+		return err
+	}
+	return strct.backend.Put(name, content)
+}
+
+// Model returns a stored pipeline, or ErrNotFound if there is none for the key.
+func (strct *Store) Model(key Key) (*model.Root, error) {
+	content, err := strct.get(Models, key)
+	if err != nil {
+		return nil, err
+	}
+	root := &model.Root{}
+	if err := json.Unmarshal(content, root); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+	return root, nil
+}
+
+// PutBundle stores a bundle.
+func (strct *Store) PutBundle(key Key, b *bundle.Bundle) error {
+	name, err := key.name(Bundles)
+	if err != nil {
+		return err
+	}
+	content, err := b.Marshal(bundle.JSON)
+	if err != nil {
+		return err
+	}
+	return strct.backend.Put(name, content)
+}
+
+// Bundle returns a stored bundle, or ErrNotFound if there is none for the key.
+func (strct *Store) Bundle(key Key) (*bundle.Bundle, error) {
+	content, err := strct.get(Bundles, key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := bundle.Unmarshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+	return b, nil
+}
+
+func (strct *Store) get(kind Kind, key Key) ([]byte, error) {
+	name, err := key.name(kind)
+	if err != nil {
+		return nil, err
+	}
+	return strct.backend.Get(name)
+}
+
+// Has returns true if an object of the kind is stored for the key, such as to skip analysing a pipeline whose
+// fingerprint has already been seen.
+func (strct *Store) Has(kind Kind, key Key) (bool, error) {
+	_, err := strct.get(kind, key)
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNotFound:
+		return false, nil
+	}
+	return false, err
+}
+
+// Keys returns the keys of the objects of a kind stored for a repository and branch, sorted. An empty branch lists
+// every branch of the repository, and an empty repository every repository.
+func (strct *Store) Keys(kind Kind, repo, branch string) ([]Key, error) {
+	if repo == "" && branch != "" {
+		return nil, errors.New("a branch can only be listed for a repository")
+	}
+	names, err := strct.backend.List(prefix(kind, repo, branch))
+	if err != nil {
+		return nil, err
+	}
+	keys := []Key{}
+	for _, name := range names {
+		key, err := parseName(kind, name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.Branch != b.Branch {
+			return a.Branch < b.Branch
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return keys, nil
+}
+
+// Delete removes the object of a kind stored for a key, if there is one.
+func (strct *Store) Delete(kind Kind, key Key) error {
+	name, err := key.name(kind)
+	if err != nil {
+		return err
+	}
+	return strct.backend.Delete(name)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/bundle"
+	"github.com/abayer/go-jenkinsfile/dedupe"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend checks the behaviour every backend shares
+func testBackend(t *testing.T, b Backend) {
+	names, err := b.List("")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	_, err = b.Get("models/app/main/abc.json")
+	assert.Equal(t, ErrNotFound, err)
+
+	for _, name := range []string{"models/app/main/b.json", "models/app/main/a.json", "models/lib/main/c.json",
+		"bundles/app/main/a.json", "models/org%2Fapp/dev/d.json"} {
+		require.NoError(t, b.Put(name, []byte(name)))
+	}
+	require.NoError(t, b.Put("models/app/main/a.json", []byte("replaced")))
+	content, err := b.Get("models/app/main/a.json")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", string(content))
+	content, err = b.Get("models/org%2Fapp/dev/d.json")
+	require.NoError(t, err)
+	assert.Equal(t, "models/org%2Fapp/dev/d.json", string(content))
+
+	names, err = b.List("models/app/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"models/app/main/a.json", "models/app/main/b.json"}, names)
+	names, err = b.List("")
+	require.NoError(t, err)
+	assert.Len(t, names, 5)
+
+	require.NoError(t, b.Delete("models/app/main/a.json"))
+	require.NoError(t, b.Delete("models/app/main/a.json"))
+	_, err = b.Get("models/app/main/a.json")
+	assert.Equal(t, ErrNotFound, err)
+
+	for _, name := range []string{"", "/models/a.json", "models//a.json", "models/../a.json"} {
+		assert.Error(t, b.Put(name, nil), name)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestFilesystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	testBackend(t, NewFilesystem(dir+"/objects"))
+}
+
+func TestStore(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(`pipeline {
+    agent any
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make'
+            }
+        }
+    }
+}`))
+	require.NoError(t, err)
+	fp, err := dedupe.Fingerprint(root)
+	require.NoError(t, err)
+	key := Key{Repo: "org/app", Branch: "main", Fingerprint: fp}
+
+	s := New(NewMemory())
+	has, err := s.Has(Models, key)
+	require.NoError(t, err)
+	assert.False(t, has)
+	_, err = s.Model(key)
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, s.PutModel(key, root))
+	has, err = s.Has(Models, key)
+	require.NoError(t, err)
+	assert.True(t, has)
+	stored, err := s.Model(key)
+	require.NoError(t, err)
+	storedFP, err := dedupe.Fingerprint(stored)
+	require.NoError(t, err)
+	assert.Equal(t, fp, storedFP)
+
+	b, err := bundle.Build(root, &bundle.Options{Name: "org/app"})
+	require.NoError(t, err)
+	require.NoError(t, s.PutBundle(key, b))
+	storedBundle, err := s.Bundle(key)
+	require.NoError(t, err)
+	assert.Equal(t, b.Fingerprint, storedBundle.Fingerprint)
+
+	other := Key{Repo: "org/app", Branch: "feature/x", Fingerprint: "0123"}
+	require.NoError(t, s.PutModel(other, root))
+	keys, err := s.Keys(Models, "org/app", "")
+	require.NoError(t, err)
+	assert.Equal(t, []Key{other, key}, keys)
+	keys, err = s.Keys(Models, "org/app", "main")
+	require.NoError(t, err)
+	assert.Equal(t, []Key{key}, keys)
+	keys, err = s.Keys(Bundles, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []Key{key}, keys)
+	_, err = s.Keys(Models, "", "main")
+	assert.Error(t, err)
+
+	require.NoError(t, s.Delete(Models, other))
+	has, err = s.Has(Models, other)
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	assert.EqualError(t, s.PutModel(Key{Repo: "app"}, root), "key app@# needs a repository, branch, and fingerprint")
+	assert.Error(t, s.PutModel(key, &model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{{Name: "x",
+		Branches: []*model.Branch{{Name: "default", Steps: []*model.AnyStep{{}}}}}}}}))
+}
@@ -0,0 +1,141 @@
+// Package tabular writes analyzer results as CSV or TSV, so audits can be done in a spreadsheet without custom
+// scripts.
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/aggregate"
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Format is a tabular output format
+type Format string
+
+const (
+	// CSV is comma-separated values, quoted as in RFC 4180
+	CSV Format = "csv"
+	// TSV is tab-separated values. Tabs, line breaks, and backslashes in fields are escaped as \t, \n, \r, and \\.
+	TSV Format = "tsv"
+)
+
+// Table is a header and rows of fields
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Write writes the table, header first, in the given format.
+func (strct *Table) Write(w io.Writer, format Format) error {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(strct.Header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(strct.Rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case TSV:
+		if err := writeTSV(w, strct.Header); err != nil {
+			return err
+		}
+		for _, row := range strct.Rows {
+			if err := writeTSV(w, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func writeTSV(w io.Writer, fields []string) error {
+	escaped := make([]string, len(fields))
+	for i, f := range fields {
+		escaped[i] = tsvEscaper.Replace(f)
+	}
+	_, err := io.WriteString(w, strings.Join(escaped, "\t")+"\n")
+	return err
+}
+
+// Inventories tabulates pipeline inventories, with one row per step, agent label, and plugin each pipeline uses.
+func Inventories(inventories []*aggregate.Inventory) *Table {
+	t := &Table{Header: []string{"pipeline", "category", "name", "count"}}
+	for _, inv := range inventories {
+		for _, section := range []struct {
+			category string
+			counts   map[string]int
+		}{
+			{"step", inv.Steps},
+			{"agentLabel", inv.AgentLabels},
+			{"plugin", inv.Plugins},
+		} {
+			names := make([]string, 0, len(section.counts))
+			for name := range section.counts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				t.Rows = append(t.Rows, []string{inv.Name, section.category, name, strconv.Itoa(section.counts[name])})
+			}
+		}
+	}
+	return t
+}
+
+// Credentials tabulates the credentials bound in a plan's environment, with one row per variable where it is
+// declared. Pipeline-level variables have an empty stage. Variables expanded by Plan.ExpandCredentials include the
+// part of the credential they hold.
+func Credentials(p *plan.Plan) *Table {
+	t := &Table{Header: []string{"stage", "variable", "credential", "part"}}
+	add := func(stage string, env []*plan.EnvVar, parent []*plan.EnvVar) {
+		inherited := map[plan.EnvVar]bool{}
+		for _, v := range parent {
+			inherited[*v] = true
+		}
+		for _, v := range env {
+			if v.Credential != "" && !inherited[*v] {
+				t.Rows = append(t.Rows, []string{stage, v.Key, v.Credential, v.CredentialPart})
+			}
+		}
+	}
+	add("", p.Environment, nil)
+	var visit func(stages []*plan.Stage, parent []*plan.EnvVar)
+	visit = func(stages []*plan.Stage, parent []*plan.EnvVar) {
+		for _, s := range stages {
+			add(s.ID, s.Environment, parent)
+			visit(s.Children, s.Environment)
+		}
+	}
+	visit(p.Stages, p.Environment)
+	return t
+}
+
+// Findings tabulates analyzer findings.
+func Findings(findings []*analysis.Finding) *Table {
+	t := &Table{Header: []string{"rule", "severity", "source", "stage", "message"}}
+	for _, f := range findings {
+		t.Rows = append(t.Rows, []string{f.Rule, string(f.Severity()), string(f.Source()), f.Stage, f.Message})
+	}
+	return t
+}
+
+// Metrics tabulates metric samples, such as those from metrics.Prometheus.Samples.
+func Metrics(samples []*metrics.Sample) *Table {
+	t := &Table{Header: []string{"name", "labels", "value"}}
+	for _, s := range samples {
+		t.Rows = append(t.Rows, []string{s.Name, s.Labels, strconv.FormatFloat(s.Value, 'g', -1, 64)})
+	}
+	return t
+}
@@ -0,0 +1,84 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/aggregate"
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func write(t *testing.T, table *Table, format Format) string {
+	var buf bytes.Buffer
+	require.NoError(t, table.Write(&buf, format))
+	return buf.String()
+}
+
+func TestWrite(t *testing.T) {
+	table := Findings([]*analysis.Finding{
+		{Rule: "unused-parameter", Message: `parameter "A" is never used`},
+		{Rule: "multi", Stage: "Build/Unit", Message: "two\tfields\nand lines"},
+	})
+	assert.Equal(t, `rule,severity,source,stage,message
+unused-parameter,info,lint,,"parameter ""A"" is never used"
+multi,warning,lint,Build/Unit,"two	fields
+and lines"
+`, write(t, table, CSV))
+	assert.Equal(t, `rule	severity	source	stage	message
+unused-parameter	info	lint		parameter "A" is never used
+multi	warning	lint	Build/Unit	two\tfields\nand lines
+`, write(t, table, TSV))
+
+	assert.EqualError(t, table.Write(&bytes.Buffer{}, "xlsx"), `unknown format "xlsx"`)
+}
+
+func TestInventories(t *testing.T) {
+	inv := &aggregate.Inventory{
+		Name:        "app",
+		Steps:       map[string]int{"sh": 2, "echo": 1},
+		AgentLabels: map[string]int{"linux": 1},
+		Plugins:     map[string]int{"workflow-basic-steps": 1, "workflow-durable-task-step": 2},
+	}
+	assert.Equal(t, `pipeline	category	name	count
+app	step	echo	1
+app	step	sh	2
+app	agentLabel	linux	1
+app	plugin	workflow-basic-steps	1
+app	plugin	workflow-durable-task-step	2
+`, write(t, Inventories([]*aggregate.Inventory{inv}), TSV))
+}
+
+func TestCredentials(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", "environment", "usernamePassword.json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	p, err := plan.Build(root)
+	require.NoError(t, err)
+	p.ExpandCredentials(map[string]plan.CredentialType{"FOOcredentials": plan.UsernamePassword})
+
+	assert.Equal(t, `stage,variable,credential,part
+,FOO,FOOcredentials,username:password
+,FOO_USR,FOOcredentials,username
+,FOO_PSW,FOOcredentials,password
+`, write(t, Credentials(p), CSV))
+}
+
+func TestMetrics(t *testing.T) {
+	p := metrics.NewPrometheus()
+	p.Add(metrics.Findings, metrics.Labels{"rule": "unused-stash"}, 2)
+	p.Observe(metrics.ParseDuration, nil, 0.25)
+	assert.Equal(t, `name,labels,value
+jenkinsfile_findings_total,"{rule=""unused-stash""}",2
+jenkinsfile_parse_duration_seconds_sum,,0.25
+jenkinsfile_parse_duration_seconds_count,,1
+`, write(t, Metrics(p.Samples()), CSV))
+}
@@ -0,0 +1,203 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Node is a node Apply passes to its function: a *model.Stage, *model.Step, *model.TreeStep,
+// *model.EnvironmentEntry, *model.MethodCall for options, parameters, and triggers, or *model.BuildCondition for
+// post conditions.
+type Node interface{}
+
+// Apply returns a copy of the pipeline rewritten by fn, leaving root untouched. fn is called with each node of the
+// copy, and returns the node to put in its place and true, or false to delete it. It may return the node it was given,
+// changed or not, or a new one of the same kind; steps and tree steps may replace each other. Nodes are rewritten after
+// the nodes within them, and replacements aren't rewritten again, so fn can wrap a node in one matching it too.
+func Apply(root *model.Root, fn func(n Node) (Node, bool)) (*model.Root, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	out := root.DeepCopy()
+	r := &rewriter{fn: fn}
+	p := out.Pipeline
+	p.Environment = r.environment(p.Environment)
+	if p.Options != nil {
+		p.Options.Options = r.methodCalls(p.Options.Options)
+	}
+	if p.Parameters != nil {
+		p.Parameters.Parameters = r.methodCalls(p.Parameters.Parameters)
+	}
+	if p.Triggers != nil {
+		p.Triggers.Triggers = r.methodCalls(p.Triggers.Triggers)
+	}
+	p.Stages = r.stages(p.Stages)
+	r.post(p.Post)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return out, model.CheckInvariants(out)
+}
+
+// rewriter applies a function to each node of a pipeline, recording the first replacement of the wrong kind
+type rewriter struct {
+	fn  func(n Node) (Node, bool)
+	err error
+}
+
+func (r *rewriter) mismatch(n Node, replacement Node) {
+	if r.err == nil {
+		r.err = fmt.Errorf("a %T can't be replaced with a %T", n, replacement)
+	}
+}
+
+func (r *rewriter) stages(stages []*model.Stage) []*model.Stage {
+	out := stages[:0]
+	for _, s := range stages {
+		if s == nil {
+			out = append(out, s)
+			continue
+		}
+		r.stage(s)
+		n, keep := r.fn(s)
+		if !keep {
+			continue
+		}
+		replacement, ok := n.(*model.Stage)
+		if !ok {
+			r.mismatch(s, n)
+			replacement = s
+		}
+		out = append(out, replacement)
+	}
+	return out
+}
+
+// stage rewrites the nodes within a stage
+func (r *rewriter) stage(s *model.Stage) {
+	s.Environment = r.environment(s.Environment)
+	if s.Options != nil {
+		s.Options.Options = r.methodCalls(s.Options.Options)
+	}
+	for _, b := range s.Branches {
+		if b != nil {
+			b.Steps = r.steps(b.Steps)
+		}
+	}
+	s.Stages = r.stages(s.Stages)
+	s.Parallel = r.stages(s.Parallel)
+	if m := s.Matrix; m != nil {
+		m.Environment = r.environment(m.Environment)
+		if m.Options != nil {
+			m.Options.Options = r.methodCalls(m.Options.Options)
+		}
+		m.Stages = r.stages(m.Stages)
+		r.post(m.Post)
+	}
+	r.post(s.Post)
+}
+
+func (r *rewriter) steps(steps []*model.AnyStep) []*model.AnyStep {
+	out := steps[:0]
+	for _, s := range steps {
+		var n Node
+		switch {
+		case s == nil:
+			out = append(out, s)
+			continue
+		case s.Tree != nil:
+			s.Tree.Children = r.steps(s.Tree.Children)
+			n = s.Tree
+		case s.Step != nil:
+			n = s.Step
+		default:
+			out = append(out, s)
+			continue
+		}
+		replacement, keep := r.fn(n)
+		if !keep {
+			continue
+		}
+		switch t := replacement.(type) {
+		case *model.Step:
+			out = append(out, model.NewAnyStep(t))
+		case *model.TreeStep:
+			out = append(out, model.NewAnyTree(t))
+		default:
+			r.mismatch(n, replacement)
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (r *rewriter) environment(entries []*model.EnvironmentEntry) []*model.EnvironmentEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		if e == nil {
+			out = append(out, e)
+			continue
+		}
+		n, keep := r.fn(e)
+		if !keep {
+			continue
+		}
+		replacement, ok := n.(*model.EnvironmentEntry)
+		if !ok {
+			r.mismatch(e, n)
+			replacement = e
+		}
+		out = append(out, replacement)
+	}
+	return out
+}
+
+func (r *rewriter) methodCalls(calls []*model.MethodCall) []*model.MethodCall {
+	out := calls[:0]
+	for _, c := range calls {
+		if c == nil {
+			out = append(out, c)
+			continue
+		}
+		n, keep := r.fn(c)
+		if !keep {
+			continue
+		}
+		replacement, ok := n.(*model.MethodCall)
+		if !ok {
+			r.mismatch(c, n)
+			replacement = c
+		}
+		out = append(out, replacement)
+	}
+	return out
+}
+
+func (r *rewriter) post(post *model.Post) {
+	if post == nil {
+		return
+	}
+	out := post.Conditions[:0]
+	for _, c := range post.Conditions {
+		if c == nil {
+			out = append(out, c)
+			continue
+		}
+		if c.Branch != nil {
+			c.Branch.Steps = r.steps(c.Branch.Steps)
+		}
+		n, keep := r.fn(c)
+		if !keep {
+			continue
+		}
+		replacement, ok := n.(*model.BuildCondition)
+		if !ok {
+			r.mismatch(c, n)
+			replacement = c
+		}
+		out = append(out, replacement)
+	}
+	post.Conditions = out
+}
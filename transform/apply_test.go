@@ -0,0 +1,112 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const applyPipeline = `pipeline {
+    agent any
+    environment {
+        LEGACY = 'true'
+        GOFLAGS = '-mod=vendor'
+    }
+    stages {
+        stage('Build') {
+            steps {
+                sh 'make'
+                dir('docs') {
+                    sh 'make'
+                }
+            }
+        }
+        stage('Legacy') {
+            steps {
+                echo 'obsolete'
+            }
+        }
+    }
+}
+`
+
+func TestApply(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(applyPipeline))
+	require.NoError(t, err)
+
+	out, err := Apply(root, func(n Node) (Node, bool) {
+		switch t := n.(type) {
+		case *model.Step:
+			if t.Name == "sh" && t.Arguments.Get("script").String() == "make" {
+				return &model.TreeStep{
+					Name:      "container",
+					Arguments: &model.ArgumentList{Single: literalString("golang")},
+					Children:  []*model.AnyStep{{Step: t}},
+				}, true
+			}
+		case *model.Stage:
+			return t, t.Name != "Legacy"
+		case *model.EnvironmentEntry:
+			return t, t.Key != "LEGACY"
+		}
+		return n, true
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writer.Write(out, buf))
+	assert.Equal(t, `pipeline {
+    agent any
+    environment {
+        GOFLAGS = '-mod=vendor'
+    }
+    stages {
+        stage('Build') {
+            steps {
+                container('golang') {
+                    sh 'make'
+                }
+                dir('docs') {
+                    container('golang') {
+                        sh 'make'
+                    }
+                }
+            }
+        }
+    }
+}
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writer.Write(root, buf))
+	assert.Equal(t, applyPipeline, buf.String(), "the original is untouched")
+}
+
+func TestApplyErrors(t *testing.T) {
+	_, err := Apply(nil, func(n Node) (Node, bool) { return n, true })
+	assert.EqualError(t, err, "a root with a pipeline is required")
+
+	root, err := parser.Parse(strings.NewReader(applyPipeline))
+	require.NoError(t, err)
+	_, err = Apply(root, func(n Node) (Node, bool) {
+		if s, ok := n.(*model.Stage); ok {
+			return &model.Step{Name: s.Name}, true
+		}
+		return n, true
+	})
+	assert.EqualError(t, err, "a *model.Stage can't be replaced with a *model.Step")
+
+	_, err = Apply(root, func(n Node) (Node, bool) {
+		if s, ok := n.(*model.Stage); ok {
+			s.Name = "Same"
+		}
+		return n, true
+	})
+	assert.EqualError(t, err, `pipeline violates model invariants: stage "Same" is not the only stage with its name`)
+}
@@ -0,0 +1,341 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Kinds of change to a stage
+const (
+	StageAdded   = "added"
+	StageRemoved = "removed"
+	StageChanged = "changed"
+)
+
+// NameChanges lists the named items of a section, such as options by name or environment variables by key, which were
+// added, removed, or changed, each in the order they appear
+type NameChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// StageChange is how a single stage changed
+type StageChange struct {
+	// Stage is the stage's path, its name and those of its enclosing stages joined with "/".
+	Stage string `json:"stage"`
+	// Change is StageAdded, StageRemoved, or StageChanged. The stages nested in added and removed stages are listed
+	// too.
+	Change string `json:"change"`
+	// Sections are the sections of a changed stage which changed, such as "steps", "when", or "agent". Nested stages
+	// which changed are listed separately, rather than as a section of their parent.
+	Sections []string `json:"sections,omitempty"`
+	// Options and Environment detail changes to the stage's options and environment variables.
+	Options     *NameChanges `json:"options,omitempty"`
+	Environment *NameChanges `json:"environment,omitempty"`
+}
+
+// Changes summarizes the differences between two versions of a pipeline, such as before and after it was
+// transformed, for inclusion in the descriptions of pull requests making the change. Annotations are ignored.
+type Changes struct {
+	Agent       bool         `json:"agent,omitempty"`
+	Options     *NameChanges `json:"options,omitempty"`
+	Environment *NameChanges `json:"environment,omitempty"`
+	Tools       *NameChanges `json:"tools,omitempty"`
+	Parameters  *NameChanges `json:"parameters,omitempty"`
+	Triggers    *NameChanges `json:"triggers,omitempty"`
+	Libraries   bool         `json:"libraries,omitempty"`
+	// Post lists the post conditions whose steps were added, removed, or changed.
+	Post   *NameChanges   `json:"post,omitempty"`
+	Stages []*StageChange `json:"stages,omitempty"`
+}
+
+// named is an item of a section, identified by name
+type named struct {
+	name  string
+	value interface{}
+}
+
+// Summarize returns how the pipeline changed between before and after. Stages are matched by path, so a renamed stage
+// is reported as one stage removed and another added.
+func Summarize(before, after *model.Root) (*Changes, error) {
+	if before == nil || before.Pipeline == nil || after == nil || after.Pipeline == nil {
+		return nil, errors.New("roots with pipelines are required")
+	}
+	b, a := before.Pipeline, after.Pipeline
+	c := &Changes{
+		Agent:       !sameJSON(b.Agent, a.Agent),
+		Options:     compareNamed(methodCalls(optionCalls(b.Options)), methodCalls(optionCalls(a.Options))),
+		Environment: compareNamed(environment(b.Environment), environment(a.Environment)),
+		Tools:       compareNamed(tools(b.Tools), tools(a.Tools)),
+		Libraries:   !sameJSON(b.Libraries, a.Libraries),
+		Post:        compareNamed(postConditions(b.Post), postConditions(a.Post)),
+	}
+	if b.Parameters != nil || a.Parameters != nil {
+		c.Parameters = compareNamed(parameters(b.Parameters), parameters(a.Parameters))
+	}
+	if b.Triggers != nil || a.Triggers != nil {
+		c.Triggers = compareNamed(methodCalls(triggerCalls(b.Triggers)), methodCalls(triggerCalls(a.Triggers)))
+	}
+	c.Stages = compareStages(b.Stages, a.Stages, nil)
+	return c, nil
+}
+
+// Empty returns true if nothing changed
+func (strct *Changes) Empty() bool {
+	return !strct.Agent && !strct.Libraries && strct.Options == nil && strct.Environment == nil && strct.Tools == nil &&
+		strct.Parameters == nil && strct.Triggers == nil && strct.Post == nil && len(strct.Stages) == 0
+}
+
+// Markdown renders the changes as a Markdown list, for pull request descriptions
+func (strct *Changes) Markdown() string {
+	buf := &bytes.Buffer{}
+	if strct.Empty() {
+		buf.WriteString("No changes to the pipeline.\n")
+		return buf.String()
+	}
+	if strct.Agent {
+		buf.WriteString("- Pipeline agent changed\n")
+	}
+	for _, s := range []struct {
+		what    string
+		changes *NameChanges
+	}{
+		{"Pipeline options", strct.Options},
+		{"Pipeline environment variables", strct.Environment},
+		{"Pipeline tools", strct.Tools},
+		{"Parameters", strct.Parameters},
+		{"Triggers", strct.Triggers},
+		{"Pipeline post conditions", strct.Post},
+	} {
+		writeNameChanges(buf, "", s.what, s.changes)
+	}
+	if strct.Libraries {
+		buf.WriteString("- Libraries changed\n")
+	}
+	for _, s := range strct.Stages {
+		fmt.Fprintf(buf, "- Stage `%s` %s", s.Stage, s.Change)
+		if len(s.Sections) > 0 {
+			fmt.Fprintf(buf, ": %s", strings.Join(s.Sections, ", "))
+		}
+		buf.WriteString("\n")
+		writeNameChanges(buf, "  ", "Options", s.Options)
+		writeNameChanges(buf, "  ", "Environment variables", s.Environment)
+	}
+	return buf.String()
+}
+
+func writeNameChanges(buf *bytes.Buffer, indent, what string, c *NameChanges) {
+	if c == nil {
+		return
+	}
+	for _, l := range []struct {
+		verb  string
+		names []string
+	}{{"added", c.Added}, {"removed", c.Removed}, {"changed", c.Changed}} {
+		if len(l.names) > 0 {
+			fmt.Fprintf(buf, "%s- %s %s: `%s`\n", indent, what, l.verb, strings.Join(l.names, "`, `"))
+		}
+	}
+}
+
+// compareStages compares stages with the same parents, matching them by name
+func compareStages(before, after []*model.Stage, parents []string) []*StageChange {
+	var out []*StageChange
+	find := func(stages []*model.Stage, name string) *model.Stage {
+		for _, s := range stages {
+			if s != nil && s.Name == name {
+				return s
+			}
+		}
+		return nil
+	}
+	for _, b := range before {
+		if b != nil && find(after, b.Name) == nil {
+			out = append(out, wholeStage(b, parents, StageRemoved)...)
+		}
+	}
+	for _, a := range after {
+		if a == nil {
+			continue
+		}
+		b := find(before, a.Name)
+		if b == nil {
+			out = append(out, wholeStage(a, parents, StageAdded)...)
+			continue
+		}
+		path := append(append([]string{}, parents...), a.Name)
+		sc := &StageChange{
+			Stage:       strings.Join(path, "/"),
+			Change:      StageChanged,
+			Options:     compareNamed(methodCalls(optionCalls(b.Options)), methodCalls(optionCalls(a.Options))),
+			Environment: compareNamed(environment(b.Environment), environment(a.Environment)),
+		}
+		for _, section := range []struct {
+			name          string
+			before, after interface{}
+		}{
+			{"agent", b.Agent, a.Agent},
+			{"tools", b.Tools, a.Tools},
+			{"when", b.When, a.When},
+			{"input", b.Input, a.Input},
+			{"steps", b.Branches, a.Branches},
+			{"matrix", matrixAxes(b.Matrix), matrixAxes(a.Matrix)},
+			{"post", b.Post, a.Post},
+			{"failFast", b.FailFast, a.FailFast},
+			{"dependsOn", b.DependsOn, a.DependsOn},
+		} {
+			if !sameJSON(section.before, section.after) {
+				sc.Sections = append(sc.Sections, section.name)
+			}
+		}
+		if len(sc.Sections) > 0 || sc.Options != nil || sc.Environment != nil {
+			out = append(out, sc)
+		}
+		out = append(out, compareStages(childStages(b), childStages(a), path)...)
+	}
+	return out
+}
+
+// wholeStage reports a stage and those nested in it as all added or all removed
+func wholeStage(s *model.Stage, parents []string, change string) []*StageChange {
+	var out []*StageChange
+	visitStages([]*model.Stage{s}, parents, func(stage *model.Stage, path []string) {
+		out = append(out, &StageChange{Stage: strings.Join(path, "/"), Change: change})
+	})
+	return out
+}
+
+func childStages(s *model.Stage) []*model.Stage {
+	children := append(append([]*model.Stage{}, s.Stages...), s.Parallel...)
+	if s.Matrix != nil {
+		children = append(children, s.Matrix.Stages...)
+	}
+	return children
+}
+
+func matrixAxes(m *model.Matrix) interface{} {
+	if m == nil {
+		return nil
+	}
+	return []interface{}{m.Axes, m.Excludes}
+}
+
+// compareNamed compares the items of a section by name, returning nil if none changed
+func compareNamed(before, after []named) *NameChanges {
+	c := &NameChanges{}
+	find := func(items []named, name string) *named {
+		for i := range items {
+			if items[i].name == name {
+				return &items[i]
+			}
+		}
+		return nil
+	}
+	for _, a := range after {
+		b := find(before, a.name)
+		switch {
+		case b == nil:
+			c.Added = append(c.Added, a.name)
+		case !sameJSON(b.value, a.value):
+			c.Changed = append(c.Changed, a.name)
+		}
+	}
+	for _, b := range before {
+		if find(after, b.name) == nil {
+			c.Removed = append(c.Removed, b.name)
+		}
+	}
+	if len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0 {
+		return nil
+	}
+	return c
+}
+
+func optionCalls(o *model.Options) []*model.MethodCall {
+	if o == nil {
+		return nil
+	}
+	return o.Options
+}
+
+func triggerCalls(t *model.Triggers) []*model.MethodCall {
+	if t == nil {
+		return nil
+	}
+	return t.Triggers
+}
+
+func methodCalls(calls []*model.MethodCall) []named {
+	var out []named
+	for _, c := range calls {
+		if c != nil {
+			out = append(out, named{c.Name, c})
+		}
+	}
+	return out
+}
+
+// parameters names parameters by their name argument, falling back to their type
+func parameters(p *model.Parameters) []named {
+	if p == nil {
+		return nil
+	}
+	var out []named
+	for _, c := range p.Parameters {
+		if c == nil {
+			continue
+		}
+		name := c.Name
+		if n := c.Args().Get("name").String(); n != "" {
+			name = n
+		}
+		out = append(out, named{name, c})
+	}
+	return out
+}
+
+func environment(entries []*model.EnvironmentEntry) []named {
+	var out []named
+	for _, e := range entries {
+		if e != nil {
+			out = append(out, named{e.Key, e.Value})
+		}
+	}
+	return out
+}
+
+func tools(values []*model.ArgumentValue) []named {
+	var out []named
+	for _, t := range values {
+		if t != nil {
+			out = append(out, named{t.Key, t.Value})
+		}
+	}
+	return out
+}
+
+func postConditions(p *model.Post) []named {
+	if p == nil {
+		return nil
+	}
+	var out []named
+	for _, c := range p.Conditions {
+		if c != nil {
+			out = append(out, named{c.Condition, c.Branch})
+		}
+	}
+	return out
+}
+
+// sameJSON returns true if a and b marshal to the same JSON, which leaves out annotations
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
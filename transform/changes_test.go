@@ -0,0 +1,56 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	before := loadRoot(t, "deploy")
+	after := loadRoot(t, "deploy")
+	wrap, err := ParseWrapRules([]byte("rules:\n- stage: Deploy/Apply\n  timeout: 30m\n"))
+	require.NoError(t, err)
+	_, err = Wrap(after, wrap)
+	require.NoError(t, err)
+	defaults, err := ParseDefaults([]byte("options:\n- name: disableConcurrentBuilds\n" +
+		"notifications:\n- condition: failure\n  step: mail\n  arguments: {to: team@example.com}\n"))
+	require.NoError(t, err)
+	_, err = ApplyDefaults(after, defaults)
+	require.NoError(t, err)
+	after.Pipeline.Stages[0].Name = "Compile"
+	after.Pipeline.Stages[1].Stages[0].Environment = []*model.EnvironmentEntry{{Key: "TF_IN_AUTOMATION",
+		Value: &model.EnvironmentValue{Single: literalString("1")}}}
+
+	changes, err := Summarize(before, after)
+	require.NoError(t, err)
+	b, err := json.Marshal(changes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"options": {"added": ["disableConcurrentBuilds"]},
+		"post": {"added": ["failure"]},
+		"stages": [
+			{"stage": "Build", "change": "removed"},
+			{"stage": "Compile", "change": "added"},
+			{"stage": "Deploy/Plan", "change": "changed", "environment": {"added": ["TF_IN_AUTOMATION"]}},
+			{"stage": "Deploy/Apply", "change": "changed", "options": {"added": ["timeout"]}}]}`, string(b))
+	assert.Equal(t, "- Pipeline options added: `disableConcurrentBuilds`\n"+
+		"- Pipeline post conditions added: `failure`\n"+
+		"- Stage `Build` removed\n"+
+		"- Stage `Compile` added\n"+
+		"- Stage `Deploy/Plan` changed\n"+
+		"  - Environment variables added: `TF_IN_AUTOMATION`\n"+
+		"- Stage `Deploy/Apply` changed\n"+
+		"  - Options added: `timeout`\n", changes.Markdown())
+
+	// Annotations alone aren't changes.
+	annotated := loadRoot(t, "deploy")
+	annotated.Pipeline.Stages[0].Annotations.AddProvenance("test")
+	changes, err = Summarize(before, annotated)
+	require.NoError(t, err)
+	assert.True(t, changes.Empty())
+	assert.Equal(t, "No changes to the pipeline.\n", changes.Markdown())
+}
@@ -0,0 +1,238 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// AnnotationDefaults is the pipeline annotation in which ApplyDefaults records the defaults it has applied, one per
+// line, such as "option timeout", "tool maven", or "notification failure slackSend".
+const AnnotationDefaults = "defaults"
+
+// Option is a pipeline option, such as timeout or buildDiscarder
+type Option struct {
+	Name string `json:"name"`
+	// Argument is a single unnamed argument, as in retry(3). It can't be combined with Arguments.
+	Argument interface{} `json:"argument,omitempty"`
+	// Arguments are the option's named arguments. Values are strings, numbers, booleans, or options themselves for
+	// arguments which are method calls, as in buildDiscarder(logRotator(numToKeepStr: '10')).
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// Defaults are organization-wide defaults for pipelines which lack them
+type Defaults struct {
+	// Options are added to pipelines without an option of the same name.
+	Options []*Option `json:"options,omitempty"`
+	// Tools map tool types, such as "maven", to installation names, and are added to pipelines without a tool of the
+	// same type.
+	Tools map[string]string `json:"tools,omitempty"`
+	// Notifications are added to the pipeline's post section as Notify does.
+	Notifications []*Notification `json:"notifications,omitempty"`
+}
+
+// ParseDefaults parses a YAML or JSON defaults file, such as:
+//
+//	options:
+//	- name: timeout
+//	  arguments: {time: 1, unit: HOURS}
+//	- name: buildDiscarder
+//	  argument:
+//	    name: logRotator
+//	    arguments: {numToKeepStr: '20'}
+//	tools:
+//	  jdk: temurin-17
+//	notifications:
+//	- condition: failure
+//	  step: slackSend
+//	  arguments:
+//	    channel: '#builds'
+func ParseDefaults(b []byte) (*Defaults, error) {
+	d := &Defaults{}
+	if err := parseRules(b, d); err != nil {
+		return nil, err
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Defaults) check() error {
+	for i, o := range d.Options {
+		if _, err := o.call(); err != nil {
+			return fmt.Errorf("option %d: %s", i, err)
+		}
+	}
+	for t, name := range d.Tools {
+		if name == "" {
+			return fmt.Errorf("tool %s: an installation name is required", t)
+		}
+	}
+	return (&NotificationRules{Notifications: d.Notifications}).check()
+}
+
+// call converts the option to a method call
+func (o *Option) call() (*model.MethodCall, error) {
+	if o.Name == "" {
+		return nil, errors.New("a name is required")
+	}
+	if o.Argument != nil && len(o.Arguments) > 0 {
+		return nil, errors.New("argument and arguments can't be combined")
+	}
+	call := &model.MethodCall{Name: o.Name, Arguments: []*model.MethodArg{}}
+	if o.Argument != nil {
+		v, err := optionValue(o.Argument)
+		if err != nil {
+			return nil, fmt.Errorf("argument: %s", err)
+		}
+		call.Arguments = append(call.Arguments, &model.MethodArg{Single: v})
+	}
+	keys := make([]string, 0, len(o.Arguments))
+	for k := range o.Arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, err := optionValue(o.Arguments[k])
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %s", k, err)
+		}
+		call.Arguments = append(call.Arguments, &model.MethodArg{WithKey: &model.KeyAndValueOrMethodCall{Key: k, Value: v}})
+	}
+	return call, nil
+}
+
+func optionValue(v interface{}) (*model.ValueOrMethodCall, error) {
+	switch v := v.(type) {
+	case string:
+		return &model.ValueOrMethodCall{Single: literalString(v)}, nil
+	case float64:
+		return &model.ValueOrMethodCall{Single: &model.RawArgument{IsLiteral: true,
+			Value: &model.RawArgumentValue{AsFloat: &v}}}, nil
+	case bool:
+		return &model.ValueOrMethodCall{Single: &model.RawArgument{IsLiteral: true,
+			Value: &model.RawArgumentValue{AsBool: &v}}}, nil
+	case map[string]interface{}:
+		nested := &Option{}
+		for k, e := range v {
+			var ok bool
+			switch k {
+			case "name":
+				nested.Name, ok = e.(string)
+			case "argument":
+				nested.Argument, ok = e, true
+			case "arguments":
+				nested.Arguments, ok = e.(map[string]interface{})
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown or invalid field %q in method call", k)
+			}
+		}
+		call, err := nested.call()
+		if err != nil {
+			return nil, err
+		}
+		return &model.ValueOrMethodCall{Call: call}, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+// ApplyDefaults adds the defaults a pipeline lacks and returns how many it added. Each default applied is recorded in
+// the pipeline's AnnotationDefaults annotation, and isn't applied again even if it's since been removed, so applying
+// the defaults again changes nothing and pipelines can opt out of a default by deleting it. Defaults the pipeline
+// already has, such as an option of the same name or a notification step for the same condition, aren't added or
+// recorded. Added notification steps also record their provenance.
+func ApplyDefaults(root *model.Root, d *Defaults) (int, error) {
+	if root == nil || root.Pipeline == nil {
+		return 0, errors.New("a root with a pipeline is required")
+	}
+	if err := d.check(); err != nil {
+		return 0, err
+	}
+	p := root.Pipeline
+	applied := map[string]bool{}
+	if prev := p.Annotations.Get(AnnotationDefaults); prev != "" {
+		for _, m := range strings.Split(prev, "\n") {
+			applied[m] = true
+		}
+	}
+	added := 0
+	record := func(marker string) {
+		if prev := p.Annotations.Get(AnnotationDefaults); prev != "" {
+			marker = prev + "\n" + marker
+		}
+		p.Annotations.Set(AnnotationDefaults, marker)
+		added++
+	}
+
+	for _, o := range d.Options {
+		marker := "option " + o.Name
+		if applied[marker] || p.Options.Get(o.Name) != nil {
+			continue
+		}
+		call, _ := o.call()
+		if p.Options == nil {
+			p.Options = &model.Options{}
+		}
+		p.Options.Set(call)
+		record(marker)
+	}
+
+	types := make([]string, 0, len(d.Tools))
+	for t := range d.Tools {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		marker := "tool " + t
+		if applied[marker] || hasTool(p.Tools, t) {
+			continue
+		}
+		p.Tools = append(p.Tools, &model.ArgumentValue{Key: t, Value: literalString(d.Tools[t])})
+		record(marker)
+	}
+
+	for i, n := range d.Notifications {
+		marker := "notification " + n.Condition + " " + n.Step
+		if applied[marker] || hasNotification(p.Post, n) {
+			continue
+		}
+		if addNotification(p, n, fmt.Sprintf("transform: default notification %d", i)) {
+			record(marker)
+		}
+	}
+	p.Post.SortConditions()
+	return added, model.CheckInvariants(root)
+}
+
+func hasTool(tools []*model.ArgumentValue, t string) bool {
+	for _, tool := range tools {
+		if tool != nil && tool.Key == t {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNotification returns true if the post section runs the notification's step for its condition, whatever its
+// arguments
+func hasNotification(post *model.Post, n *Notification) bool {
+	if post == nil {
+		return false
+	}
+	for _, c := range post.Conditions {
+		if c == nil || c.Condition != n.Condition || c.Branch == nil {
+			continue
+		}
+		for _, s := range c.Branch.Steps {
+			if name, _ := stepNameAndArgs(s); name == n.Step {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	d, err := ParseDefaults([]byte(`options:
+- name: timeout
+  arguments: {time: 1, unit: HOURS}
+- name: buildDiscarder
+  argument:
+    name: logRotator
+    arguments: {numToKeepStr: '20'}
+tools:
+  jdk: temurin-17
+  maven: maven-3
+notifications:
+- condition: failure
+  step: slackSend
+  arguments:
+    channel: '#builds'
+- condition: always
+  step: cleanWs
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+	root.Pipeline.Tools = []*model.ArgumentValue{{Key: "maven", Value: literalString("maven-3.9")}}
+	root.Pipeline.Post = &model.Post{Conditions: []*model.BuildCondition{{Condition: "always", Branch: &model.Branch{
+		Name: "default", Steps: []*model.AnyStep{{Step: &model.Step{Name: "cleanWs",
+			Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{{Key: "deleteDirs", Value: literalString("true")}}}}}}}}}}
+
+	added, err := ApplyDefaults(root, d)
+	require.NoError(t, err)
+	assert.Equal(t, 4, added)
+	assert.Equal(t, "option timeout\noption buildDiscarder\ntool jdk\nnotification failure slackSend",
+		root.Pipeline.Annotations.Get(AnnotationDefaults))
+
+	b, err := json.Marshal(root.Pipeline.Options)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"options": [
+		{"name": "timeout", "arguments": [
+			{"key": "time", "value": {"isLiteral": true, "value": 1}},
+			{"key": "unit", "value": {"isLiteral": true, "value": "HOURS"}}]},
+		{"name": "buildDiscarder", "arguments": [
+			{"name": "logRotator", "arguments": [{"key": "numToKeepStr", "value": {"isLiteral": true, "value": "20"}}]}]}]}`,
+		string(b))
+	b, err = json.Marshal(root.Pipeline.Tools)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"key": "maven", "value": {"isLiteral": true, "value": "maven-3.9"}},
+		{"key": "jdk", "value": {"isLiteral": true, "value": "temurin-17"}}]`, string(b))
+	require.Len(t, root.Pipeline.Post.Conditions, 2)
+	assert.Equal(t, "failure", root.Pipeline.Post.Conditions[1].Condition)
+	assert.Len(t, root.Pipeline.Post.Conditions[0].Branch.Steps, 1)
+	assert.Equal(t, []string{"transform: default notification 0"},
+		root.Pipeline.Post.Conditions[1].Branch.Steps[0].Step.Annotations.Provenance())
+
+	// Defaults which were applied aren't applied again, even once they've been removed.
+	root.Pipeline.Options.Remove("timeout")
+	added, err = ApplyDefaults(root, d)
+	require.NoError(t, err)
+	assert.Equal(t, 0, added)
+	assert.Nil(t, root.Pipeline.Options.Get("timeout"))
+}
+
+func TestParseDefaultsErrors(t *testing.T) {
+	_, err := ParseDefaults([]byte("options:\n- arguments: {time: 1}\n"))
+	assert.EqualError(t, err, "option 0: a name is required")
+	_, err = ParseDefaults([]byte("options:\n- name: retry\n  argument: [1, 2]\n"))
+	assert.EqualError(t, err, "option 0: argument: unsupported value [1 2]")
+	_, err = ParseDefaults([]byte("tools:\n  jdk: ''\n"))
+	assert.EqualError(t, err, "tool jdk: an installation name is required")
+	_, err = ParseDefaults([]byte("notifications:\n- condition: failure\n"))
+	assert.EqualError(t, err, "notification 0: a step is required")
+}
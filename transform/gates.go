@@ -0,0 +1,216 @@
+package transform
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+// DefaultDeployCommands are the commands which mark a stage as a deployment when GateRules doesn't list any
+var DefaultDeployCommands = []string{"kubectl", "helm"}
+
+// Approval is a manual approval required before deployments
+type Approval struct {
+	Message string `json:"message"`
+	// Submitter lists the users and groups who may approve, separated by commas. Anyone may if it's empty.
+	Submitter string `json:"submitter,omitempty"`
+	Ok        string `json:"ok,omitempty"`
+}
+
+// Signing is a stage run before deployments, such as to sign or verify artifacts. Exactly one of Script and Step is
+// required.
+type Signing struct {
+	// Name prefixes the names of the signing stages, which are followed by the deployment stage's name. It defaults
+	// to "Sign".
+	Name string `json:"name,omitempty"`
+	// Script is a shell script for the stage to run.
+	Script string `json:"script,omitempty"`
+	// Step is the name of a step, such as one from a shared library, for the stage to call without arguments.
+	Step string `json:"step,omitempty"`
+}
+
+// GateRules describe which stages are deployments and the gates to put in front of them. At least one of Approval
+// and Signing is required.
+type GateRules struct {
+	// Stage is an Ant-style glob matched against stage paths, as in Selector, for stages which are deployments
+	// whatever their steps.
+	Stage string `json:"stage,omitempty"`
+	// Commands mark stages with steps of the same name, or shell scripts running them, as deployments. They default
+	// to DefaultDeployCommands.
+	Commands []string  `json:"commands,omitempty"`
+	Approval *Approval `json:"approval,omitempty"`
+	Signing  *Signing  `json:"signing,omitempty"`
+
+	commands *regexp.Regexp
+}
+
+// ParseGateRules parses a YAML or JSON rule file, such as:
+//
+//	commands: [kubectl, helm, terraform]
+//	approval:
+//	  message: Deploy to production?
+//	  submitter: release-managers
+func ParseGateRules(b []byte) (*GateRules, error) {
+	rules := &GateRules{}
+	if err := parseRules(b, rules); err != nil {
+		return nil, err
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (g *GateRules) compile() error {
+	if g.Approval == nil && g.Signing == nil {
+		return errors.New("an approval or signing gate is required")
+	}
+	if g.Approval != nil && g.Approval.Message == "" {
+		return errors.New("approval: a message is required")
+	}
+	if g.Signing != nil && (g.Signing.Script == "") == (g.Signing.Step == "") {
+		return errors.New("signing: exactly one of script and step is required")
+	}
+	commands := g.Commands
+	if commands == nil {
+		commands = DefaultDeployCommands
+	}
+	var quoted []string
+	for _, c := range commands {
+		quoted = append(quoted, regexp.QuoteMeta(c))
+	}
+	g.commands = nil
+	if len(quoted) > 0 {
+		g.commands = regexp.MustCompile(`(^|[\s;&|(])(` + strings.Join(quoted, "|") + `)(\s|$)`)
+	}
+	return nil
+}
+
+// Gate puts the gates in front of every deployment stage, and returns the paths of the stages it gated. Approvals
+// become the stage's input directive, so Jenkins asks before running the stage. Signing stages are inserted before the
+// deployment stage, or before the parallel or matrix stage containing it, since parallel branches have nothing to run
+// before. Stages which already have an input directive, or a signing stage in front of them, are left as they are.
+func Gate(root *model.Root, rules *GateRules) ([]string, error) {
+	if root == nil || root.Pipeline == nil {
+		return nil, errors.New("a root with a pipeline is required")
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	var gated []string
+	if rules.Approval != nil {
+		visitStages(root.Pipeline.Stages, nil, func(s *model.Stage, path []string) {
+			if s.Input == nil && rules.deployment(s, path) {
+				s.Input = &model.Input{Message: literalString(rules.Approval.Message)}
+				if rules.Approval.Submitter != "" {
+					s.Input.Submitter = literalString(rules.Approval.Submitter)
+				}
+				if rules.Approval.Ok != "" {
+					s.Input.Ok = literalString(rules.Approval.Ok)
+				}
+				s.Annotations.AddProvenance("transform: approval gate")
+				gated = append(gated, strings.Join(path, "/"))
+			}
+		})
+	}
+	if rules.Signing != nil {
+		root.Pipeline.Stages = rules.sign(root.Pipeline.Stages, nil, &gated)
+	}
+	return dedupe(gated), model.CheckInvariants(root)
+}
+
+// deployment returns true if the stage, not counting its nested stages, is a deployment
+func (g *GateRules) deployment(s *model.Stage, path []string) bool {
+	if g.Stage != "" && when.Glob(g.Stage, strings.Join(path, "/")) {
+		return true
+	}
+	if g.commands == nil {
+		return false
+	}
+	found := false
+	var visit func(steps []*model.AnyStep)
+	visit = func(steps []*model.AnyStep) {
+		for _, step := range steps {
+			name, args := stepNameAndArgs(step)
+			switch name {
+			case "":
+				continue
+			case "sh", "bat", "powershell", "pwsh":
+				found = found || g.commands.MatchString(args.Get("script").String())
+			default:
+				found = found || g.commands.MatchString(name)
+			}
+			if step.Tree != nil {
+				visit(step.Tree.Children)
+			}
+		}
+	}
+	for _, b := range s.Branches {
+		if b != nil {
+			visit(b.Steps)
+		}
+	}
+	return found
+}
+
+// containsDeployment returns true if the stage or any stage nested in it is a deployment
+func (g *GateRules) containsDeployment(s *model.Stage, path []string) bool {
+	found := false
+	visitStages([]*model.Stage{s}, path[:len(path)-1], func(n *model.Stage, p []string) {
+		found = found || g.deployment(n, p)
+	})
+	return found
+}
+
+// sign inserts signing stages into a list of sequential stages, descending into nested sequential stages
+func (g *GateRules) sign(stages []*model.Stage, parents []string, gated *[]string) []*model.Stage {
+	prefix := g.Signing.Name
+	if prefix == "" {
+		prefix = "Sign"
+	}
+	var out []*model.Stage
+	for _, s := range stages {
+		if s == nil {
+			out = append(out, s)
+			continue
+		}
+		path := append(append([]string{}, parents...), s.Name)
+		name := prefix + " " + s.Name
+		switch {
+		case len(s.Stages) > 0 && !g.deployment(s, path):
+			s.Stages = g.sign(s.Stages, path, gated)
+		case g.containsDeployment(s, path) && (len(out) == 0 || out[len(out)-1] == nil || out[len(out)-1].Name != name):
+			out = append(out, g.signingStage(name))
+			*gated = append(*gated, strings.Join(path, "/"))
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func (g *GateRules) signingStage(name string) *model.Stage {
+	step := &model.Step{Name: g.Signing.Step, Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{}}}
+	if g.Signing.Script != "" {
+		step = &model.Step{Name: "sh", Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{
+			{Key: "script", Value: literalString(g.Signing.Script)}}}}
+	}
+	stage := &model.Stage{Name: name, Branches: []*model.Branch{{Name: "default", Steps: []*model.AnyStep{{Step: step}}}}}
+	stage.Annotations.AddProvenance("transform: signing gate")
+	return stage
+}
+
+// dedupe removes repeated entries, keeping the first of each
+func dedupe(list []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range list {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
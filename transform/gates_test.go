@@ -0,0 +1,76 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stageNames(stages []*model.Stage) []string {
+	var names []string
+	for _, s := range stages {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestGateApproval(t *testing.T) {
+	rules, err := ParseGateRules([]byte(`approval:
+  message: Deploy?
+  submitter: release-managers
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+
+	gated, err := Gate(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Deploy/Apply"}, gated)
+	apply := root.Pipeline.Stages[1].Stages[1]
+	require.NotNil(t, apply.Input)
+	assert.Equal(t, "Deploy?", apply.Input.Message.String())
+	assert.Equal(t, "release-managers", apply.Input.Submitter.String())
+	assert.Nil(t, apply.Input.Ok)
+	assert.Equal(t, []string{"transform: approval gate"}, apply.Annotations.Provenance())
+
+	gated, err = Gate(root, rules)
+	require.NoError(t, err)
+	assert.Empty(t, gated)
+}
+
+func TestGateSigning(t *testing.T) {
+	rules, err := ParseGateRules([]byte(`commands: [terraform, helm]
+signing:
+  script: cosign verify
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+	helm := &model.Stage{Name: "Charts", Branches: []*model.Branch{{Name: "default", Steps: []*model.AnyStep{{Step: &model.Step{
+		Name: "sh", Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{
+			{Key: "script", Value: literalString("helm upgrade --install app ./chart")}}}}}}}}}
+	root.Pipeline.Stages = append(root.Pipeline.Stages, &model.Stage{Name: "Release", Parallel: []*model.Stage{helm}})
+
+	gated, err := Gate(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Deploy/Plan", "Deploy/Apply", "Release"}, gated)
+	assert.Equal(t, []string{"Build", "Deploy", "Sign Release", "Release"}, stageNames(root.Pipeline.Stages))
+	assert.Equal(t, []string{"Sign Plan", "Plan", "Sign Apply", "Apply"}, stageNames(root.Pipeline.Stages[1].Stages))
+	sign := root.Pipeline.Stages[1].Stages[0]
+	assert.Equal(t, "cosign verify", sign.Branches[0].Steps[0].Step.Arguments.Get("script").String())
+	assert.Equal(t, []string{"transform: signing gate"}, sign.Annotations.Provenance())
+
+	gated, err = Gate(root, rules)
+	require.NoError(t, err)
+	assert.Empty(t, gated)
+	assert.Len(t, root.Pipeline.Stages, 4)
+}
+
+func TestParseGateRulesErrors(t *testing.T) {
+	_, err := ParseGateRules([]byte("stage: Deploy\n"))
+	assert.EqualError(t, err, "an approval or signing gate is required")
+	_, err = ParseGateRules([]byte("approval: {submitter: admins}\n"))
+	assert.EqualError(t, err, "approval: a message is required")
+	_, err = ParseGateRules([]byte("signing: {name: Verify}\n"))
+	assert.EqualError(t, err, "signing: exactly one of script and step is required")
+}
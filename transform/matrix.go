@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/internal/walk"
+	"github.com/abayer/go-jenkinsfile/matrix"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// provenanceMatrix is recorded on stages made by ExpandMatrixToParallel
+const provenanceMatrix = "transform: expanded matrix to parallel stages"
+
+// ExpandMatrixToParallel returns a copy of a matrix stage with its matrix rewritten as parallel stages, one per cell
+// after excludes, for targets and Jenkins versions which don't support matrix. The stage itself keeps its other
+// sections, such as its agent and failFast. Each cell's stage is named after the stage and the cell, such as
+// "Test (OS = linux, BROWSER = firefox)", and has the matrix's agent, options, when, input, tools, and post, with the
+// axis values followed by the matrix's environment variables as its environment, and the matrix's stages as its
+// sequential stages. As stage names must be unique, the names of those stages are suffixed with the cell in the same
+// way. The original stage is left untouched.
+//
+// Copies of nodes with UIDs keep them in the first cell only, so other cells need AssignUIDs if UIDs are wanted.
+func ExpandMatrixToParallel(stage *model.Stage) (*model.Stage, error) {
+	if stage == nil || stage.Matrix == nil {
+		return nil, errors.New("a stage with a matrix is required")
+	}
+	cells := matrix.Cells(stage.Matrix)
+	if len(cells) == 0 {
+		return nil, fmt.Errorf("the matrix of stage %q has no cells", stage.Name)
+	}
+
+	out := stage.DeepCopy()
+	m := out.Matrix
+	out.Matrix = nil
+	out.Annotations.AddProvenance(provenanceMatrix)
+	for i, c := range cells {
+		if i > 0 {
+			m = stage.Matrix.DeepCopy()
+			clearUIDs(m)
+		}
+		var label []string
+		cell := &model.Stage{
+			Agent:   m.Agent,
+			Input:   m.Input,
+			Options: m.Options,
+			Post:    m.Post,
+			Stages:  m.Stages,
+			Tools:   m.Tools,
+			When:    m.When,
+		}
+		for _, axis := range m.Axes {
+			if axis == nil {
+				continue
+			}
+			cell.Environment = append(cell.Environment, &model.EnvironmentEntry{
+				Key:   axis.Name,
+				Value: &model.EnvironmentValue{Single: axisValue(axis, c[axis.Name])},
+			})
+			label = append(label, fmt.Sprintf("%s = %s", axis.Name, c[axis.Name]))
+		}
+		cell.Environment = append(cell.Environment, m.Environment...)
+		suffix := " (" + strings.Join(label, ", ") + ")"
+		cell.Name = stage.Name + suffix
+		walk.Stages(cell.Stages, func(s *model.Stage, _ []*model.Stage) {
+			s.Name += suffix
+		})
+		cell.Annotations.AddProvenance(provenanceMatrix)
+		out.Parallel = append(out.Parallel, cell)
+	}
+	return out, model.CheckInvariants(&model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{out}}})
+}
+
+// axisValue returns the axis's value with the given string form
+func axisValue(axis *model.Axis, value string) *model.RawArgument {
+	for _, v := range axis.Values {
+		if v.String() == value {
+			return v
+		}
+	}
+	return nil
+}
+
+// clearUIDs removes the UIDs of the stages and steps of a copied matrix, so they don't clash with the originals
+func clearUIDs(m *model.Matrix) {
+	clearStep := func(step *model.AnyStep, _ []*model.TreeStep) {
+		switch {
+		case step.Step != nil:
+			delete(step.Step.Annotations, model.AnnotationUID)
+		case step.Tree != nil:
+			delete(step.Tree.Annotations, model.AnnotationUID)
+		}
+	}
+	walk.PostSteps(m.Post, clearStep)
+	walk.Stages(m.Stages, func(s *model.Stage, _ []*model.Stage) {
+		delete(s.Annotations, model.AnnotationUID)
+		walk.StageSteps(s, clearStep)
+		walk.PostSteps(s.Post, clearStep)
+	})
+}
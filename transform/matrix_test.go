@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/abayer/go-jenkinsfile/writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMatrixToParallel(t *testing.T) {
+	root, err := parser.Parse(strings.NewReader(`pipeline {
+  agent none
+  stages {
+    stage('Test') {
+      failFast true
+      matrix {
+        agent { label "${PLATFORM}" }
+        axes {
+          axis {
+            name 'PLATFORM'
+            values 'linux', 'windows'
+          }
+          axis {
+            name 'BROWSER'
+            values 'firefox', 'safari'
+          }
+        }
+        excludes {
+          exclude {
+            axis {
+              name 'PLATFORM'
+              values 'linux'
+            }
+            axis {
+              name 'BROWSER'
+              values 'safari'
+            }
+          }
+        }
+        environment {
+          CI = 'true'
+        }
+        stages {
+          stage('Unit') {
+            steps {
+              sh 'make test'
+            }
+          }
+        }
+      }
+    }
+  }
+}`))
+	require.NoError(t, err)
+	model.AssignUIDs(root)
+	stage := root.Pipeline.Stages[0]
+
+	expanded, err := ExpandMatrixToParallel(stage)
+	require.NoError(t, err)
+	assert.NotNil(t, stage.Matrix, "the original stage is unchanged")
+	assert.Equal(t, []string{provenanceMatrix}, expanded.Annotations.Provenance())
+	require.Len(t, expanded.Parallel, 3)
+	assert.NotEmpty(t, expanded.Parallel[0].Stages[0].Annotations.Get(model.AnnotationUID))
+	assert.Empty(t, expanded.Parallel[1].Stages[0].Annotations.Get(model.AnnotationUID))
+
+	root.Pipeline.Stages[0] = expanded
+	buf := &bytes.Buffer{}
+	require.NoError(t, writer.Write(root, buf))
+	assert.Contains(t, buf.String(), `        stage('Test') {
+            failFast true
+            parallel {
+                stage('Test (PLATFORM = linux, BROWSER = firefox)') {
+                    agent { label "${PLATFORM}" }
+                    environment {
+                        PLATFORM = 'linux'
+                        BROWSER = 'firefox'
+                        CI = 'true'
+                    }
+                    stages {
+                        stage('Unit (PLATFORM = linux, BROWSER = firefox)') {
+                            steps {
+                                sh 'make test'
+                            }
+                        }
+                    }
+                }
+                stage('Test (PLATFORM = windows, BROWSER = firefox)') {`)
+	assert.Contains(t, buf.String(), "stage('Unit (PLATFORM = windows, BROWSER = safari)')")
+	assert.NotContains(t, buf.String(), "linux, BROWSER = safari")
+}
+
+func TestExpandMatrixToParallelErrors(t *testing.T) {
+	_, err := ExpandMatrixToParallel(&model.Stage{Name: "Build"})
+	assert.EqualError(t, err, "a stage with a matrix is required")
+
+	_, err = ExpandMatrixToParallel(&model.Stage{Name: "Test", Matrix: &model.Matrix{}})
+	assert.EqualError(t, err, `the matrix of stage "Test" has no cells`)
+}
@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/groovyq"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Notification is a step to run for a post condition, such as slackSend on failure
+type Notification struct {
+	// Condition is the post condition, such as "failure" or "fixed".
+	Condition string `json:"condition"`
+	// Step is the name of the step, such as "slackSend" or "emailext".
+	Step string `json:"step"`
+	// Arguments are the step's named arguments. Values containing $ are Groovy strings, so ${env.BUILD_URL} and the
+	// like are interpolated; other values are literal.
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// NotificationRules are the notifications added by Notify, in order
+type NotificationRules struct {
+	Notifications []*Notification `json:"notifications"`
+}
+
+// ParseNotificationRules parses a YAML or JSON rule file, such as:
+//
+//	notifications:
+//	- condition: failure
+//	  step: slackSend
+//	  arguments:
+//	    channel: '#builds'
+//	    message: 'Failed: ${env.BUILD_URL}'
+func ParseNotificationRules(b []byte) (*NotificationRules, error) {
+	rules := &NotificationRules{}
+	if err := parseRules(b, rules); err != nil {
+		return nil, err
+	}
+	if err := rules.check(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *NotificationRules) check() error {
+	for i, n := range r.Notifications {
+		switch {
+		case n.Step == "":
+			return fmt.Errorf("notification %d: a step is required", i)
+		case !contains(model.PostConditions, n.Condition):
+			return fmt.Errorf("notification %d: unknown post condition %q, must be one of: %s", i, n.Condition,
+				strings.Join(model.PostConditions, ", "))
+		}
+	}
+	return nil
+}
+
+// Notify adds the notifications to the pipeline's post section and returns how many it added. They're appended to
+// the steps of existing post conditions, and new conditions are added in the order Jenkins runs them. Notifications
+// which are already there, with the same step and arguments, aren't added again.
+func Notify(root *model.Root, rules *NotificationRules) (int, error) {
+	if root == nil || root.Pipeline == nil {
+		return 0, errors.New("a root with a pipeline is required")
+	}
+	if err := rules.check(); err != nil {
+		return 0, err
+	}
+	added := 0
+	for i, n := range rules.Notifications {
+		if addNotification(root.Pipeline, n, fmt.Sprintf("transform: notification %d", i)) {
+			added++
+		}
+	}
+	root.Pipeline.Post.SortConditions()
+	return added, model.CheckInvariants(root)
+}
+
+// addNotification adds a notification to the pipeline's post section, recording provenance on its step, unless it's
+// already there. It returns whether it was added. The caller sorts the post conditions afterwards.
+func addNotification(p *model.Pipeline, n *Notification, provenance string) bool {
+	if p.Post == nil {
+		p.Post = &model.Post{Conditions: []*model.BuildCondition{}}
+	}
+	step := n.step()
+	var condition *model.BuildCondition
+	for _, c := range p.Post.Conditions {
+		if c != nil && c.Condition == n.Condition {
+			condition = c
+		}
+	}
+	if condition == nil {
+		condition = &model.BuildCondition{Condition: n.Condition, Branch: &model.Branch{Name: "default"}}
+		p.Post.Conditions = append(p.Post.Conditions, condition)
+	}
+	if condition.Branch == nil {
+		condition.Branch = &model.Branch{Name: "default"}
+	}
+	if hasStep(condition.Branch.Steps, step) {
+		return false
+	}
+	step.Annotations.AddProvenance(provenance)
+	condition.Branch.Steps = append(condition.Branch.Steps, &model.AnyStep{Step: step})
+	return true
+}
+
+func (n *Notification) step() *model.Step {
+	args := &model.ArgumentList{Named: []*model.ArgumentValue{}}
+	keys := make([]string, 0, len(n.Arguments))
+	for k := range n.Arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := literalString(n.Arguments[k])
+		if strings.Contains(n.Arguments[k], "$") {
+			src := groovyq.GString(n.Arguments[k])
+			v = &model.RawArgument{Value: &model.RawArgumentValue{AsString: &src}}
+		}
+		args.Named = append(args.Named, &model.ArgumentValue{Key: k, Value: v})
+	}
+	return &model.Step{Name: n.Step, Arguments: args}
+}
+
+// hasStep returns true if the steps include one with the same name and arguments
+func hasStep(steps []*model.AnyStep, step *model.Step) bool {
+	for _, s := range steps {
+		if s != nil && s.Step != nil && s.Step.Name == step.Name && reflect.DeepEqual(s.Step.Arguments, step.Arguments) {
+			return true
+		}
+	}
+	return false
+}
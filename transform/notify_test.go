@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	rules, err := ParseNotificationRules([]byte(`notifications:
+- condition: failure
+  step: slackSend
+  arguments:
+    channel: '#builds'
+    message: 'Failed: ${env.BUILD_URL}'
+- condition: always
+  step: junit
+  arguments:
+    testResults: '**/target/*.xml'
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+	root.Pipeline.Post = &model.Post{Conditions: []*model.BuildCondition{{Condition: "always", Branch: &model.Branch{
+		Name: "default", Steps: []*model.AnyStep{{Step: &model.Step{Name: "cleanWs",
+			Arguments: &model.ArgumentList{Named: []*model.ArgumentValue{}}}}}}}}}
+
+	added, err := Notify(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	b, err := json.Marshal(root.Pipeline.Post)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"conditions": [
+		{"condition": "always", "branch": {"name": "default", "steps": [
+			{"name": "cleanWs", "arguments": []},
+			{"name": "junit", "arguments": [{"key": "testResults", "value": {"isLiteral": true, "value": "**/target/*.xml"}}]}]}},
+		{"condition": "failure", "branch": {"name": "default", "steps": [
+			{"name": "slackSend", "arguments": [
+				{"key": "channel", "value": {"isLiteral": true, "value": "#builds"}},
+				{"key": "message", "value": {"isLiteral": false, "value": "\"Failed: ${env.BUILD_URL}\""}}]}]}}]}`, string(b))
+	assert.Equal(t, []string{"transform: notification 0"},
+		root.Pipeline.Post.Conditions[1].Branch.Steps[0].Step.Annotations.Provenance())
+
+	added, err = Notify(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, 0, added)
+}
+
+func TestParseNotificationRulesErrors(t *testing.T) {
+	_, err := ParseNotificationRules([]byte("notifications:\n- condition: failure\n"))
+	assert.EqualError(t, err, "notification 0: a step is required")
+	_, err = ParseNotificationRules([]byte("notifications:\n- condition: broken\n  step: mail\n"))
+	assert.EqualError(t, err, `notification 0: unknown post condition "broken", must be one of: always, changed, `+
+		`fixed, regression, aborted, success, unsuccessful, unstable, failure, notBuilt, cleanup`)
+}
@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Patch combines the rule files of the other transformers into one document, for bulk edits across many pipelines.
+// Its sections are applied in the order they're declared here, and any may be left out.
+type Patch struct {
+	Defaults      *Defaults       `json:"defaults,omitempty"`
+	Wrap          []*WrapRule     `json:"wrap,omitempty"`
+	Notifications []*Notification `json:"notifications,omitempty"`
+	Gates         *GateRules      `json:"gates,omitempty"`
+}
+
+// ParsePatch parses a YAML or JSON patch file, such as:
+//
+//	defaults:
+//	  options:
+//	  - name: timeout
+//	    arguments: {time: 1, unit: HOURS}
+//	wrap:
+//	- script: terraform apply
+//	  retry: 2
+//	notifications:
+//	- condition: failure
+//	  step: slackSend
+//	  arguments:
+//	    channel: '#builds'
+//	gates:
+//	  approval:
+//	    message: Deploy to production?
+func ParsePatch(b []byte) (*Patch, error) {
+	p := &Patch{}
+	if err := parseRules(b, p); err != nil {
+		return nil, err
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Patch) check() error {
+	if p.Defaults == nil && len(p.Wrap) == 0 && len(p.Notifications) == 0 && p.Gates == nil {
+		return errors.New("a patch must have defaults, wrap, notifications, or gates")
+	}
+	if p.Defaults != nil {
+		if err := p.Defaults.check(); err != nil {
+			return fmt.Errorf("defaults: %s", err)
+		}
+	}
+	for i, r := range p.Wrap {
+		if err := r.compile(); err != nil {
+			return fmt.Errorf("wrap: rule %d: %s", i, err)
+		}
+	}
+	if err := (&NotificationRules{Notifications: p.Notifications}).check(); err != nil {
+		return fmt.Errorf("notifications: %s", err)
+	}
+	if p.Gates != nil {
+		if err := p.Gates.compile(); err != nil {
+			return fmt.Errorf("gates: %s", err)
+		}
+	}
+	return nil
+}
+
+// ApplyPatch applies the patch to the pipeline, as ApplyDefaults, Wrap, Notify, and Gate do for each section, so
+// applying a patch again changes nothing. Like them, it changes the pipeline in place, so writer.Rewrite can reuse the
+// original text of the parts it leaves alone.
+func ApplyPatch(root *model.Root, p *Patch) error {
+	if root == nil || root.Pipeline == nil {
+		return errors.New("a root with a pipeline is required")
+	}
+	if err := p.check(); err != nil {
+		return err
+	}
+	if p.Defaults != nil {
+		if _, err := ApplyDefaults(root, p.Defaults); err != nil {
+			return fmt.Errorf("defaults: %s", err)
+		}
+	}
+	if len(p.Wrap) > 0 {
+		if _, err := Wrap(root, &WrapRules{Rules: p.Wrap}); err != nil {
+			return fmt.Errorf("wrap: %s", err)
+		}
+	}
+	if len(p.Notifications) > 0 {
+		if _, err := Notify(root, &NotificationRules{Notifications: p.Notifications}); err != nil {
+			return fmt.Errorf("notifications: %s", err)
+		}
+	}
+	if p.Gates != nil {
+		if _, err := Gate(root, p.Gates); err != nil {
+			return fmt.Errorf("gates: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	p, err := ParsePatch([]byte(`defaults:
+  options:
+  - name: timeout
+    arguments: {time: 1, unit: HOURS}
+wrap:
+- script: terraform apply
+  retry: 2
+notifications:
+- condition: failure
+  step: slackSend
+  arguments:
+    channel: '#builds'
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+
+	patched := root.DeepCopy()
+	require.NoError(t, ApplyPatch(patched, p))
+	assert.NotNil(t, patched.Pipeline.Options.Get("timeout"))
+	assert.Equal(t, "retry", patched.Pipeline.Stages[1].Stages[1].Branches[0].Steps[0].Tree.Children[0].Tree.Name)
+	require.Len(t, patched.Pipeline.Post.Conditions, 1)
+	assert.Equal(t, "failure", patched.Pipeline.Post.Conditions[0].Condition)
+
+	changes, err := Summarize(root, patched)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"timeout"}, changes.Options.Added)
+	assert.Equal(t, []string{"failure"}, changes.Post.Added)
+
+	// Applying the patch again changes nothing.
+	again := patched.DeepCopy()
+	require.NoError(t, ApplyPatch(again, p))
+	changes, err = Summarize(patched, again)
+	require.NoError(t, err)
+	assert.True(t, changes.Empty())
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	_, err := ParsePatch([]byte("{}"))
+	assert.EqualError(t, err, "a patch must have defaults, wrap, notifications, or gates")
+	_, err = ParsePatch([]byte("wrap:\n- step: sh\n"))
+	assert.EqualError(t, err, "wrap: rule 0: a retry of at least 2 or a timeout is required")
+	_, err = ParsePatch([]byte("notifications:\n- condition: sometimes\n  step: mail\n"))
+	assert.Contains(t, err.Error(), `notifications: notification 0: unknown post condition "sometimes"`)
+	_, err = ParsePatch([]byte("gates: {}\n"))
+	assert.EqualError(t, err, "gates: an approval or signing gate is required")
+	_, err = ParsePatch([]byte("rules: []\n"))
+	assert.Error(t, err)
+}
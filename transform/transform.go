@@ -0,0 +1,98 @@
+// Package transform rewrites pipelines according to organization-wide rules, such as wrapping flaky steps in retries.
+// Each transformer records the rule responsible in the provenance annotation of the nodes it adds or changes, and
+// checks the result with model.CheckInvariants.
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+// Selector selects stages and steps. Empty fields match anything.
+type Selector struct {
+	// Stage is an Ant-style glob matched against the stage's path, its name and those of its enclosing stages joined
+	// with "/", such as "Deploy/**".
+	Stage string `json:"stage,omitempty"`
+	// Step is the name of the steps to select.
+	Step string `json:"step,omitempty"`
+	// Script is a regular expression matched against the scripts of sh, bat, powershell, and pwsh steps.
+	Script string `json:"script,omitempty"`
+
+	script *regexp.Regexp
+}
+
+// compile checks the selector and compiles its regular expression
+func (s *Selector) compile() error {
+	if s.Script == "" {
+		return nil
+	}
+	re, err := regexp.Compile(s.Script)
+	if err != nil {
+		return fmt.Errorf("script: %s", err)
+	}
+	s.script = re
+	return nil
+}
+
+// selectsSteps returns true if the selector picks steps within stages, rather than whole stages
+func (s *Selector) selectsSteps() bool {
+	return s.Step != "" || s.Script != ""
+}
+
+func (s *Selector) matchStage(path []string) bool {
+	return s.Stage == "" || when.Glob(s.Stage, strings.Join(path, "/"))
+}
+
+func (s *Selector) matchStep(step *model.AnyStep) bool {
+	name, args := stepNameAndArgs(step)
+	if s.Step != "" && s.Step != name {
+		return false
+	}
+	if s.script != nil {
+		switch name {
+		case "sh", "bat", "powershell", "pwsh":
+			return s.script.MatchString(args.Get("script").String())
+		}
+		return false
+	}
+	return true
+}
+
+func stepNameAndArgs(step *model.AnyStep) (string, *model.ArgumentList) {
+	switch {
+	case step == nil:
+		return "", nil
+	case step.Step != nil:
+		return step.Step.Name, step.Step.Arguments
+	case step.Tree != nil:
+		return step.Tree.Name, step.Tree.Arguments
+	}
+	return "", nil
+}
+
+// parseRules decodes a YAML or JSON rule file into v
+func parseRules(b []byte, v interface{}) error {
+	return yaml.UnmarshalStrict(b, v)
+}
+
+// visitStages calls fn for each stage in the pipeline, with its path
+func visitStages(stages []*model.Stage, parents []string, fn func(stage *model.Stage, path []string)) {
+	for _, s := range stages {
+		if s == nil {
+			continue
+		}
+		path := append(append([]string{}, parents...), s.Name)
+		fn(s, path)
+		visitStages(s.Stages, path, fn)
+		visitStages(s.Parallel, path, fn)
+		if s.Matrix != nil {
+			visitStages(s.Matrix.Stages, path, fn)
+		}
+	}
+}
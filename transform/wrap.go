@@ -0,0 +1,196 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// WrapRule wraps the selected steps in retry and timeout steps. If the selector picks stages rather than steps, the
+// stages get retry and timeout options instead, which apply to the whole stage.
+type WrapRule struct {
+	Selector
+	// Retry is the number of attempts to make, if more than one.
+	Retry int `json:"retry,omitempty"`
+	// Timeout limits each attempt, as a duration such as "10m".
+	Timeout string `json:"timeout,omitempty"`
+
+	timeout time.Duration
+}
+
+// WrapRules are the rules applied by Wrap, in order
+type WrapRules struct {
+	Rules []*WrapRule `json:"rules"`
+}
+
+// ParseWrapRules parses a YAML or JSON rule file, such as:
+//
+//	rules:
+//	- script: terraform apply
+//	  retry: 2
+//	  timeout: 30m
+func ParseWrapRules(b []byte) (*WrapRules, error) {
+	rules := &WrapRules{}
+	if err := parseRules(b, rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *WrapRule) compile() error {
+	if err := r.Selector.compile(); err != nil {
+		return err
+	}
+	if r.Timeout != "" {
+		d, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return fmt.Errorf("timeout: %s", err)
+		}
+		if d < time.Second {
+			return errors.New("timeout: must be at least a second")
+		}
+		r.timeout = d
+	}
+	if r.Retry < 2 && r.timeout == 0 {
+		return errors.New("a retry of at least 2 or a timeout is required")
+	}
+	return nil
+}
+
+// Wrap applies the rules to the pipeline and returns how many steps and stages it changed. Steps are wrapped so that
+// the timeout applies to each attempt, as in retry(2) { timeout(time: 30, unit: 'MINUTES') { ... } }. Steps and
+// stages which are already inside a retry or timeout aren't wrapped in another, so applying the rules again changes
+// nothing. Steps in post conditions are left alone.
+func Wrap(root *model.Root, rules *WrapRules) (int, error) {
+	if root == nil || root.Pipeline == nil {
+		return 0, errors.New("a root with a pipeline is required")
+	}
+	changed := 0
+	for i, r := range rules.Rules {
+		if err := r.compile(); err != nil {
+			return 0, fmt.Errorf("rule %d: %s", i, err)
+		}
+		provenance := fmt.Sprintf("transform: wrap rule %d", i)
+		visitStages(root.Pipeline.Stages, nil, func(s *model.Stage, path []string) {
+			if !r.matchStage(path) {
+				return
+			}
+			if !r.selectsSteps() {
+				if r.wrapStage(s) {
+					s.Annotations.AddProvenance(provenance)
+					changed++
+				}
+				return
+			}
+			for _, b := range s.Branches {
+				if b == nil {
+					continue
+				}
+				var n int
+				b.Steps, n = r.wrapSteps(b.Steps, nil, provenance)
+				changed += n
+			}
+		})
+	}
+	return changed, model.CheckInvariants(root)
+}
+
+// wrapStage adds retry and timeout options to a stage which has neither
+func (r *WrapRule) wrapStage(s *model.Stage) bool {
+	if s.Options.Get("retry") != nil || s.Options.Get("timeout") != nil {
+		return false
+	}
+	if s.Options == nil {
+		s.Options = &model.Options{}
+	}
+	if r.Retry > 1 {
+		s.Options.Set(&model.MethodCall{Name: "retry", Arguments: []*model.MethodArg{{Single: &model.ValueOrMethodCall{
+			Single: literalInt(r.Retry)}}}})
+	}
+	if r.timeout > 0 {
+		amount, unit := timeoutUnits(r.timeout)
+		s.Options.Set(&model.MethodCall{Name: "timeout", Arguments: []*model.MethodArg{
+			{WithKey: &model.KeyAndValueOrMethodCall{Key: "time", Value: &model.ValueOrMethodCall{Single: literalInt(amount)}}},
+			{WithKey: &model.KeyAndValueOrMethodCall{Key: "unit", Value: &model.ValueOrMethodCall{Single: literalString(unit)}}},
+		}})
+	}
+	return true
+}
+
+// wrapSteps wraps the selected steps in a list, descending into tree steps, and returns the new list and the number
+// of steps wrapped
+func (r *WrapRule) wrapSteps(steps []*model.AnyStep, parents []string, provenance string) ([]*model.AnyStep, int) {
+	wrapped := 0
+	for i, s := range steps {
+		if s == nil {
+			continue
+		}
+		if r.matchStep(s) && !contains(parents, "retry") && !contains(parents, "timeout") {
+			steps[i] = r.wrapStep(s, provenance)
+			wrapped++
+			continue
+		}
+		if s.Tree != nil {
+			children, n := r.wrapSteps(s.Tree.Children, append(parents, s.Tree.Name), provenance)
+			s.Tree.Children = children
+			wrapped += n
+		}
+	}
+	return steps, wrapped
+}
+
+func (r *WrapRule) wrapStep(step *model.AnyStep, provenance string) *model.AnyStep {
+	if r.timeout > 0 {
+		amount, unit := timeoutUnits(r.timeout)
+		args := model.NewNamedArguments(
+			&model.ArgumentValue{Key: "time", Value: literalInt(amount)},
+			&model.ArgumentValue{Key: "unit", Value: literalString(unit)},
+		)
+		tree := &model.TreeStep{Name: "timeout", Arguments: args, Children: []*model.AnyStep{step}}
+		tree.Annotations.AddProvenance(provenance)
+		step = model.NewAnyTree(tree)
+	}
+	if r.Retry > 1 {
+		tree := &model.TreeStep{Name: "retry", Arguments: model.NewSingleArgument(literalInt(r.Retry)),
+			Children: []*model.AnyStep{step}}
+		tree.Annotations.AddProvenance(provenance)
+		step = model.NewAnyTree(tree)
+	}
+	return step
+}
+
+// timeoutUnits expresses a duration in the largest of the timeout step's units which represents it exactly
+func timeoutUnits(d time.Duration) (int, string) {
+	switch {
+	case d%time.Hour == 0:
+		return int(d / time.Hour), "HOURS"
+	case d%time.Minute == 0:
+		return int(d / time.Minute), "MINUTES"
+	}
+	return int(d / time.Second), "SECONDS"
+}
+
+func literalInt(n int) *model.RawArgument {
+	f := float64(n)
+	return &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsFloat: &f}}
+}
+
+func literalString(s string) *model.RawArgument {
+	return &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: &s}}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
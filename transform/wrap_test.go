@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	rules, err := ParseWrapRules([]byte(`rules:
+- script: terraform apply
+  retry: 2
+  timeout: 30m
+- stage: Deploy/Plan
+  timeout: 90s
+`))
+	require.NoError(t, err)
+	root := loadRoot(t, "deploy")
+
+	changed, err := Wrap(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, 2, changed)
+
+	dir := root.Pipeline.Stages[1].Stages[1].Branches[0].Steps[0].Tree
+	retry := dir.Children[0].Tree
+	require.NotNil(t, retry)
+	assert.Equal(t, "retry", retry.Name)
+	assert.Equal(t, "2", retry.Arguments.Single.String())
+	assert.Equal(t, []string{"transform: wrap rule 0"}, retry.Annotations.Provenance())
+	timeout := retry.Children[0].Tree
+	require.NotNil(t, timeout)
+	b, err := json.Marshal(timeout.Arguments)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"key": "time", "value": {"isLiteral": true, "value": 30}},
+		{"key": "unit", "value": {"isLiteral": true, "value": "MINUTES"}}]`, string(b))
+	assert.Equal(t, "sh", timeout.Children[0].Step.Name)
+
+	plan := root.Pipeline.Stages[1].Stages[0]
+	b, err = json.Marshal(plan.Options)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"options": [{"name": "timeout", "arguments": [
+		{"key": "time", "value": {"isLiteral": true, "value": 90}},
+		{"key": "unit", "value": {"isLiteral": true, "value": "SECONDS"}}]}]}`, string(b))
+
+	// Applying the rules again changes nothing.
+	changed, err = Wrap(root, rules)
+	require.NoError(t, err)
+	assert.Equal(t, 0, changed)
+}
+
+func TestParseWrapRulesErrors(t *testing.T) {
+	_, err := ParseWrapRules([]byte("rules:\n- step: sh\n"))
+	assert.EqualError(t, err, "rule 0: a retry of at least 2 or a timeout is required")
+	_, err = ParseWrapRules([]byte("rules:\n- step: sh\n  timeout: soon\n"))
+	assert.EqualError(t, err, `rule 0: timeout: time: invalid duration "soon"`)
+	_, err = ParseWrapRules([]byte("rules:\n- script: '('\n  retry: 2\n"))
+	assert.EqualError(t, err, "rule 0: script: error parsing regexp: missing closing ): `(`")
+	_, err = ParseWrapRules([]byte("rules:\n- retries: 2\n"))
+	assert.Error(t, err)
+}
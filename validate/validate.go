@@ -0,0 +1,291 @@
+// Package validate checks pipelines against the semantics of Declarative Pipelines, which decoding doesn't: that
+// stages have exactly one kind of content, that matrices and parallel stages aren't nested, that when and post
+// conditions are ones Jenkins knows, and so on.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/catalog"
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// ValidationError is a violation of Declarative semantics
+type ValidationError struct {
+	// Path is the JSON pointer of the offending value in the pipeline's JSON, such as "/pipeline/stages/0/when".
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// vocabulary is the catalog when conditions, post conditions, and options are checked against
+var vocabulary = catalog.Default()
+
+// Pipeline checks a pipeline against the semantics of Declarative Pipelines, and returns every violation, in the order
+// they appear in the pipeline. It returns no errors for a valid pipeline. The checks are:
+//
+//   - the pipeline has an agent and at least one stage
+//   - stage names are unique
+//   - each stage has exactly one of steps, sequential stages, parallel stages, or a matrix, and its steps aren't empty
+//   - stages inside parallel stages or matrices have no parallel stages or matrices of their own
+//   - failFast is only set on stages with parallel stages, parallel branches, or a matrix
+//   - matrices have axes, each with a unique name and unique values, and excludes only name those axes
+//   - when conditions are known, and allOf, anyOf, and not have children, with not having exactly one
+//   - post conditions are known and not repeated
+//   - agents have a type, and options which are only valid for the pipeline aren't used in stages
+//   - input directives have a message
+func Pipeline(root *model.Root) []ValidationError {
+	v := &validator{names: map[string]string{}}
+	defer v.record()
+	if root == nil || root.Pipeline == nil {
+		v.error("/pipeline", "a pipeline is required")
+		return v.errors
+	}
+	p := root.Pipeline
+	if p.Agent == nil {
+		v.error("/pipeline/agent", "the pipeline must have an agent")
+	}
+	v.agent("/pipeline/agent", p.Agent)
+	if len(p.Stages) == 0 {
+		v.error("/pipeline/stages", "the pipeline must have at least one stage")
+	}
+	v.stages("/pipeline/stages", p.Stages, false)
+	v.post("/pipeline/post", p.Post)
+	return v.errors
+}
+
+type validator struct {
+	// names are the paths of the stages seen so far, by name.
+	names  map[string]string
+	errors []ValidationError
+}
+
+// record reports the errors found to the metrics sink, as findings of the rule "validate"
+func (v *validator) record() {
+	if len(v.errors) > 0 {
+		metrics.Add(metrics.Findings, metrics.Labels{"rule": "validate"}, float64(len(v.errors)))
+	}
+}
+
+func (v *validator) error(path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// stages checks a list of stages. nested is true for stages inside parallel stages or a matrix.
+func (v *validator) stages(path string, stages []*model.Stage, nested bool) {
+	for i, s := range stages {
+		if s != nil {
+			v.stage(fmt.Sprintf("%s/%d", path, i), s, nested)
+		}
+	}
+}
+
+func (v *validator) stage(path string, s *model.Stage, nested bool) {
+	if first, ok := v.names[s.Name]; ok {
+		v.error(path+"/name", "stage %q has the same name as the stage at %s", s.Name, first)
+	} else {
+		v.names[s.Name] = path
+	}
+
+	kinds := 0
+	for _, present := range []bool{len(s.Branches) > 0, len(s.Stages) > 0, len(s.Parallel) > 0, s.Matrix != nil} {
+		if present {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		v.error(path, "stage %q must have exactly one of steps, stages, parallel, or matrix", s.Name)
+	}
+	for i, b := range s.Branches {
+		if b != nil && len(b.Steps) == 0 {
+			v.error(fmt.Sprintf("%s/branches/%d/steps", path, i), "stage %q has no steps", s.Name)
+		}
+	}
+	if nested && len(s.Parallel) > 0 {
+		v.error(path+"/parallel", "stage %q can't have parallel stages inside parallel stages or a matrix", s.Name)
+	}
+	if nested && s.Matrix != nil {
+		v.error(path+"/matrix", "stage %q can't have a matrix inside parallel stages or a matrix", s.Name)
+	}
+	if s.FailFast && len(s.Parallel) == 0 && s.Matrix == nil && len(s.Branches) < 2 {
+		v.error(path+"/failFast", "stage %q can only set failFast with parallel stages, branches, or a matrix", s.Name)
+	}
+
+	v.agent(path+"/agent", s.Agent)
+	v.options(path+"/options", s.Options)
+	v.when(path+"/when", s.When)
+	v.input(path+"/input", s.Input)
+	v.post(path+"/post", s.Post)
+	v.stages(path+"/stages", s.Stages, nested)
+	v.stages(path+"/parallel", s.Parallel, true)
+	if s.Matrix != nil {
+		v.matrix(path+"/matrix", s.Matrix)
+	}
+}
+
+func (v *validator) matrix(path string, m *model.Matrix) {
+	if len(m.Axes) == 0 {
+		v.error(path+"/axes", "a matrix must have at least one axis")
+	}
+	axes := map[string]bool{}
+	for i, a := range m.Axes {
+		if a == nil {
+			continue
+		}
+		at := fmt.Sprintf("%s/axes/%d", path, i)
+		switch {
+		case a.Name == "":
+			v.error(at+"/name", "an axis must have a name")
+		case axes[a.Name]:
+			v.error(at+"/name", "axis %s is declared more than once", a.Name)
+		}
+		axes[a.Name] = true
+		if len(a.Values) == 0 {
+			v.error(at+"/values", "axis %s must have at least one value", a.Name)
+		}
+		values := map[string]bool{}
+		for j, value := range a.Values {
+			if value == nil {
+				continue
+			}
+			if values[value.String()] {
+				v.error(fmt.Sprintf("%s/values/%d", at, j), "axis %s has value %s more than once", a.Name, value.String())
+			}
+			values[value.String()] = true
+		}
+	}
+	for i, exclude := range m.Excludes {
+		for j, a := range exclude {
+			if a != nil && a.Name != nil && !axes[*a.Name] {
+				v.error(fmt.Sprintf("%s/excludes/%d/%d/name", path, i, j), "exclude names unknown axis %s", *a.Name)
+			}
+		}
+	}
+	if len(m.Stages) == 0 {
+		v.error(path+"/stages", "a matrix must have at least one stage")
+	}
+
+	v.agent(path+"/agent", m.Agent)
+	v.options(path+"/options", m.Options)
+	v.when(path+"/when", m.When)
+	v.input(path+"/input", m.Input)
+	v.post(path+"/post", m.Post)
+	v.stages(path+"/stages", m.Stages, true)
+}
+
+func (v *validator) agent(path string, a *model.Agent) {
+	if a == nil {
+		return
+	}
+	// Agent types other than the catalog's may come from plugins, so only a missing type is reported.
+	if a.Type == "" {
+		v.error(path+"/type", "an agent must have a type")
+	}
+}
+
+// options checks a stage's options, which can only be the options valid in stages. Unknown options, such as those
+// of plugins the catalog doesn't describe, aren't reported.
+func (v *validator) options(path string, o *model.Options) {
+	if o == nil {
+		return
+	}
+	for i, opt := range o.Options {
+		if opt == nil {
+			continue
+		}
+		if s := vocabulary.Option(opt.Name); s != nil && !s.Stage {
+			v.error(fmt.Sprintf("%s/options/%d", path, i), "option %s is only valid for the pipeline", opt.Name)
+		}
+	}
+}
+
+func (v *validator) when(path string, w *model.When) {
+	if w == nil {
+		return
+	}
+	if len(w.Conditions) == 0 {
+		v.error(path+"/conditions", "when must have at least one condition")
+	}
+	v.conditions(path+"/conditions", w.Conditions)
+}
+
+func (v *validator) conditions(path string, conditions []*model.StepOrNestedWhenCondition) {
+	for i, c := range conditions {
+		at := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case c == nil:
+		case c.Step != nil:
+			s := find(vocabulary.WhenConditions, c.Step.Name)
+			switch {
+			case s == nil:
+				v.error(at+"/name", "unknown when condition %q", c.Step.Name)
+			case s.Block && c.Step.Name != "expression":
+				v.error(at, "when condition %s must have nested conditions", c.Step.Name)
+			}
+		case c.Nested != nil:
+			n := c.Nested
+			s := find(vocabulary.WhenConditions, n.Name)
+			switch {
+			case s == nil:
+				v.error(at+"/name", "unknown when condition %q", n.Name)
+			case !s.Block || n.Name == "expression":
+				v.error(at+"/children", "when condition %s can't have nested conditions", n.Name)
+			case n.Name == "not" && len(n.Children) != 1:
+				v.error(at+"/children", "when condition not must have exactly one nested condition")
+			case len(n.Children) == 0:
+				v.error(at+"/children", "when condition %s must have at least one nested condition", n.Name)
+			}
+			v.conditions(at+"/children", n.Children)
+		}
+	}
+}
+
+func (v *validator) input(path string, in *model.Input) {
+	if in != nil && (in.Message == nil || strings.TrimSpace(in.Message.String()) == "") {
+		v.error(path+"/message", "input must have a message")
+	}
+}
+
+func (v *validator) post(path string, post *model.Post) {
+	if post == nil {
+		return
+	}
+	seen := map[string]bool{}
+	for i, b := range post.Conditions {
+		if b == nil {
+			continue
+		}
+		at := fmt.Sprintf("%s/conditions/%d/condition", path, i)
+		switch {
+		case !known(vocabulary.PostConditions, b.Condition):
+			v.error(at, "unknown post condition %q, expected one of %s", b.Condition,
+				strings.Join(vocabulary.PostConditions, ", "))
+		case seen[b.Condition]:
+			v.error(at, "post condition %s is declared more than once", b.Condition)
+		}
+		seen[b.Condition] = true
+	}
+}
+
+func find(symbols []*catalog.Symbol, name string) *catalog.Symbol {
+	for _, s := range symbols {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func known(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
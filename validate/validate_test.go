@@ -0,0 +1,116 @@
+package validate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/metrics"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineFixtures(t *testing.T) {
+	err := filepath.Walk(filepath.Join("..", "model", "testdata", "json"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		root := &model.Root{}
+		require.NoError(t, json.Unmarshal(contents, root))
+		assert.Empty(t, Pipeline(root), path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+const invalidPipeline = `{"pipeline": {
+  "agent": {"type": ""},
+  "stages": [
+    {"name": "Build", "failFast": true, "branches": [{"name": "default", "steps": []}],
+      "options": {"options": [{"name": "buildDiscarder"}, {"name": "timeout"}]},
+      "when": {"conditions": [
+        {"name": "onFridays", "arguments": []},
+        {"name": "not", "children": [
+          {"name": "branch", "arguments": {"isLiteral": true, "value": "main"}},
+          {"name": "buildingTag", "arguments": []}
+        ]},
+        {"name": "anyOf", "arguments": []}
+      ]},
+      "input": {"message": {"isLiteral": true, "value": " "}},
+      "post": {"conditions": [
+        {"condition": "always", "branch": {"name": "default", "steps": []}},
+        {"condition": "always", "branch": {"name": "default", "steps": []}},
+        {"condition": "sometimes", "branch": {"name": "default", "steps": []}}
+      ]}},
+    {"name": "Test", "parallel": [
+      {"name": "Build", "branches": [{"name": "default", "steps": [{"name": "echo", "arguments": []}]}]},
+      {"name": "Nested", "matrix": {
+        "axes": [
+          {"name": "OS", "values": [{"isLiteral": true, "value": "linux"}, {"isLiteral": true, "value": "linux"}]},
+          {"name": "OS", "values": []}
+        ],
+        "excludes": [[{"name": "JDK", "values": [{"isLiteral": true, "value": "8"}]}]],
+        "stages": []
+      }}
+    ]},
+    {"name": "Empty"}
+  ]
+}}`
+
+func TestPipelineViolations(t *testing.T) {
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal([]byte(invalidPipeline), root))
+
+	assert.Equal(t, []ValidationError{
+		{Path: "/pipeline/agent/type", Message: "an agent must have a type"},
+		{Path: "/pipeline/stages/0/branches/0/steps", Message: `stage "Build" has no steps`},
+		{Path: "/pipeline/stages/0/failFast",
+			Message: `stage "Build" can only set failFast with parallel stages, branches, or a matrix`},
+		{Path: "/pipeline/stages/0/options/options/0", Message: "option buildDiscarder is only valid for the pipeline"},
+		{Path: "/pipeline/stages/0/when/conditions/0/name", Message: `unknown when condition "onFridays"`},
+		{Path: "/pipeline/stages/0/when/conditions/1/children",
+			Message: "when condition not must have exactly one nested condition"},
+		{Path: "/pipeline/stages/0/when/conditions/2", Message: "when condition anyOf must have nested conditions"},
+		{Path: "/pipeline/stages/0/input/message", Message: "input must have a message"},
+		{Path: "/pipeline/stages/0/post/conditions/1/condition",
+			Message: "post condition always is declared more than once"},
+		{Path: "/pipeline/stages/0/post/conditions/2/condition", Message: `unknown post condition "sometimes", ` +
+			"expected one of always, changed, fixed, regression, aborted, success, unsuccessful, unstable, failure, " +
+			"notBuilt, cleanup"},
+		{Path: "/pipeline/stages/1/parallel/0/name",
+			Message: `stage "Build" has the same name as the stage at /pipeline/stages/0`},
+		{Path: "/pipeline/stages/1/parallel/1/matrix",
+			Message: `stage "Nested" can't have a matrix inside parallel stages or a matrix`},
+		{Path: "/pipeline/stages/1/parallel/1/matrix/axes/0/values/1", Message: "axis OS has value linux more than once"},
+		{Path: "/pipeline/stages/1/parallel/1/matrix/axes/1/name", Message: "axis OS is declared more than once"},
+		{Path: "/pipeline/stages/1/parallel/1/matrix/axes/1/values", Message: "axis OS must have at least one value"},
+		{Path: "/pipeline/stages/1/parallel/1/matrix/excludes/0/0/name", Message: "exclude names unknown axis JDK"},
+		{Path: "/pipeline/stages/1/parallel/1/matrix/stages", Message: "a matrix must have at least one stage"},
+		{Path: "/pipeline/stages/2", Message: `stage "Empty" must have exactly one of steps, stages, parallel, or matrix`},
+	}, Pipeline(root))
+}
+
+func TestPipelineMissing(t *testing.T) {
+	errs := Pipeline(nil)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "/pipeline: a pipeline is required")
+
+	assert.Equal(t, []ValidationError{
+		{Path: "/pipeline/agent", Message: "the pipeline must have an agent"},
+		{Path: "/pipeline/stages", Message: "the pipeline must have at least one stage"},
+	}, Pipeline(&model.Root{Pipeline: &model.Pipeline{}}))
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	p := metrics.NewPrometheus()
+	metrics.SetSink(p)
+	defer metrics.SetSink(nil)
+
+	Pipeline(&model.Root{Pipeline: &model.Pipeline{}})
+	assert.Equal(t, []*metrics.Sample{{Name: metrics.Findings, Labels: `{rule="validate"}`, Value: 2}}, p.Samples())
+}
@@ -0,0 +1,306 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitHubURL and DefaultGitLabURL are the API URLs of github.com and gitlab.com
+const (
+	DefaultGitHubURL = "https://api.github.com"
+	DefaultGitLabURL = "https://gitlab.com/api/v4"
+)
+
+// maxDescription is the longest description GitHub accepts for a commit status
+const maxDescription = 140
+
+// perPage is the number of items to request for each page of a list
+const perPage = 100
+
+// StatusError is a response from an SCM's API with a status other than 2xx
+type StatusError struct {
+	Method string
+	Path   string
+	Status string
+	Code   int
+}
+
+func (strct *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", strct.Method, strct.Path, strct.Status)
+}
+
+// IsNotFound returns true if err is a 404 response, such as for a file the repository doesn't have
+func IsNotFound(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.Code == http.StatusNotFound
+}
+
+// Repository is a repository listed by an SCM
+type Repository struct {
+	// Name is the repository's full name, such as "org/repo".
+	Name          string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+	Archived      bool   `json:"archived,omitempty"`
+}
+
+// GitHub is an SCM using the GitHub REST API
+type GitHub struct {
+	// URL is the API's root URL, which defaults to DefaultGitHubURL. GitHub Enterprise's ends with /api/v3.
+	URL string
+	// Token is a token allowed to read the repositories' contents and write their commit statuses, and to write
+	// their contents and pull requests to open pull requests.
+	Token string
+	// Client is the HTTP client to use. Nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// Fetch gets a file's raw contents from the contents API.
+func (strct *GitHub) Fetch(repo, commit, path string) ([]byte, error) {
+	u := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", repo, escapePath(path), url.QueryEscape(commit))
+	return strct.do(http.MethodGet, u, nil, "application/vnd.github.raw")
+}
+
+// Repositories lists the repositories of an organization, following the API's pages.
+func (strct *GitHub) Repositories(org string) ([]*Repository, error) {
+	var out []*Repository
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("/orgs/%s/repos?per_page=%d&page=%d", url.PathEscape(org), perPage, page)
+		b, err := strct.do(http.MethodGet, u, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var repos []*Repository
+		if err := json.Unmarshal(b, &repos); err != nil {
+			return nil, fmt.Errorf("%s: %s", u, err)
+		}
+		out = append(out, repos...)
+		if len(repos) < perPage {
+			return out, nil
+		}
+	}
+}
+
+// SetStatus creates a commit status.
+func (strct *GitHub) SetStatus(repo, commit string, status *Status) error {
+	body := map[string]string{"state": string(status.State), "context": status.Context(),
+		"description": truncate(status.Description, maxDescription)}
+	_, err := strct.do(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", repo, commit), body, "")
+	return err
+}
+
+// CreateBranch creates a branch starting at the head of base.
+func (strct *GitHub) CreateBranch(repo, branch, base string) error {
+	b, err := strct.do(http.MethodGet, fmt.Sprintf("/repos/%s/git/ref/heads/%s", repo, escapePath(base)), nil, "")
+	if err != nil {
+		return err
+	}
+	var ref struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(b, &ref); err != nil {
+		return fmt.Errorf("branch %s: %s", base, err)
+	}
+	body := map[string]string{"ref": "refs/heads/" + branch, "sha": ref.Object.SHA}
+	_, err = strct.do(http.MethodPost, fmt.Sprintf("/repos/%s/git/refs", repo), body, "")
+	return err
+}
+
+// WriteFile commits a file's new contents to a branch, creating the file if it doesn't exist there.
+func (strct *GitHub) WriteFile(repo, branch, path string, content []byte, message string) error {
+	u := fmt.Sprintf("/repos/%s/contents/%s", repo, escapePath(path))
+	body := map[string]string{"message": message, "branch": branch,
+		"content": base64.StdEncoding.EncodeToString(content)}
+	// Replacing a file requires the ID of the blob being replaced.
+	b, err := strct.do(http.MethodGet, u+"?ref="+url.QueryEscape(branch), nil, "")
+	switch {
+	case IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		var existing struct {
+			SHA string `json:"sha"`
+		}
+		if err := json.Unmarshal(b, &existing); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		body["sha"] = existing.SHA
+	}
+	_, err = strct.do(http.MethodPut, u, body, "")
+	return err
+}
+
+// OpenPullRequest opens a pull request merging head into base, and returns its URL.
+func (strct *GitHub) OpenPullRequest(repo, head, base, title, description string) (string, error) {
+	body := map[string]string{"head": head, "base": base, "title": title, "body": description}
+	b, err := strct.do(http.MethodPost, fmt.Sprintf("/repos/%s/pulls", repo), body, "")
+	if err != nil {
+		return "", err
+	}
+	var pr struct {
+		URL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(b, &pr); err != nil {
+		return "", fmt.Errorf("pull request: %s", err)
+	}
+	return pr.URL, nil
+}
+
+func (strct *GitHub) do(method, path string, body interface{}, accept string) ([]byte, error) {
+	base := strct.URL
+	if base == "" {
+		base = DefaultGitHubURL
+	}
+	header := http.Header{}
+	if strct.Token != "" {
+		header.Set("Authorization", "Bearer "+strct.Token)
+	}
+	if accept == "" {
+		accept = "application/vnd.github+json"
+	}
+	header.Set("Accept", accept)
+	return do(strct.Client, method, strings.TrimSuffix(base, "/")+path, header, body)
+}
+
+// GitLab is an SCM using the GitLab REST API. Repositories are identified by project ID.
+type GitLab struct {
+	// URL is the API's root URL, which defaults to DefaultGitLabURL.
+	URL string
+	// Token is a token with the api scope.
+	Token string
+	// Client is the HTTP client to use. Nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// Fetch gets a file's raw contents from the repository files API.
+func (strct *GitLab) Fetch(repo, commit, path string) ([]byte, error) {
+	u := fmt.Sprintf("/projects/%s/repository/files/%s/raw?ref=%s", url.PathEscape(repo), url.PathEscape(path),
+		url.QueryEscape(commit))
+	return strct.do(http.MethodGet, u, nil)
+}
+
+// SetStatus creates a commit status. GitLab calls the failure state "failed", and has no error state, so errors are
+// reported as failures too.
+func (strct *GitLab) SetStatus(repo, commit string, status *Status) error {
+	state := "success"
+	if status.State != Success {
+		state = "failed"
+	}
+	body := map[string]string{"state": state, "name": status.Context(),
+		"description": truncate(status.Description, maxDescription)}
+	_, err := strct.do(http.MethodPost, fmt.Sprintf("/projects/%s/statuses/%s", url.PathEscape(repo), commit), body)
+	return err
+}
+
+// CreateBranch creates a branch starting at the head of base.
+func (strct *GitLab) CreateBranch(repo, branch, base string) error {
+	body := map[string]string{"branch": branch, "ref": base}
+	_, err := strct.do(http.MethodPost, fmt.Sprintf("/projects/%s/repository/branches", url.PathEscape(repo)), body)
+	return err
+}
+
+// WriteFile commits a file's new contents to a branch, creating the file if it doesn't exist there.
+func (strct *GitLab) WriteFile(repo, branch, path string, content []byte, message string) error {
+	u := fmt.Sprintf("/projects/%s/repository/files/%s", url.PathEscape(repo), url.PathEscape(path))
+	// GitLab creates files with POST and updates them with PUT.
+	method := http.MethodPut
+	_, err := strct.do(http.MethodGet, u+"?ref="+url.QueryEscape(branch), nil)
+	switch {
+	case IsNotFound(err):
+		method = http.MethodPost
+	case err != nil:
+		return err
+	}
+	body := map[string]string{"branch": branch, "content": string(content), "commit_message": message}
+	_, err = strct.do(method, u, body)
+	return err
+}
+
+// OpenPullRequest opens a merge request merging head into base, and returns its URL.
+func (strct *GitLab) OpenPullRequest(repo, head, base, title, description string) (string, error) {
+	body := map[string]string{"source_branch": head, "target_branch": base, "title": title,
+		"description": description}
+	b, err := strct.do(http.MethodPost, fmt.Sprintf("/projects/%s/merge_requests", url.PathEscape(repo)), body)
+	if err != nil {
+		return "", err
+	}
+	var mr struct {
+		URL string `json:"web_url"`
+	}
+	if err := json.Unmarshal(b, &mr); err != nil {
+		return "", fmt.Errorf("merge request: %s", err)
+	}
+	return mr.URL, nil
+}
+
+func (strct *GitLab) do(method, path string, body interface{}) ([]byte, error) {
+	base := strct.URL
+	if base == "" {
+		base = DefaultGitLabURL
+	}
+	header := http.Header{}
+	if strct.Token != "" {
+		header.Set("PRIVATE-TOKEN", strct.Token)
+	}
+	return do(strct.Client, method, strings.TrimSuffix(base, "/")+path, header, body)
+}
+
+// do sends a request with a JSON body, if body isn't nil, and returns the response's body
+func do(client *http.Client, method, u string, header http.Header, body interface{}) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{Method: method, Path: req.URL.Path, Status: resp.Status, Code: resp.StatusCode}
+	}
+	return b, nil
+}
+
+// escapePath escapes each segment of a path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
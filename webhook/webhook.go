@@ -0,0 +1,335 @@
+// Package webhook validates the Jenkinsfiles changed by pushes to GitHub and GitLab repositories and reports the
+// results as commit statuses, so organizations can require valid pipelines before merging without building them.
+// Its GitHub and GitLab clients can also list repositories and open pull requests, for the audit command and the
+// automation package.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/when"
+)
+
+func init() {
+	analysis.RegisterRule("parse", analysis.Rule{Source: analysis.SourceValidation, Severity: analysis.Error})
+	analysis.RegisterRule("invariants", analysis.Rule{Source: analysis.SourceValidation, Severity: analysis.Error})
+}
+
+// DefaultPattern matches the Jenkinsfiles validated when Handler has no Pattern
+const DefaultPattern = "**/Jenkinsfile"
+
+// zeroCommit is the commit a push deleting a branch moves it to
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+// Push is a push to a repository
+type Push struct {
+	// Repo is the repository's full name, such as "org/repo", or the project's ID for GitLab.
+	Repo string `json:"repo"`
+	Ref  string `json:"ref"`
+	// Commit is the commit the ref was pushed to.
+	Commit string `json:"commit"`
+	// Changed are the paths of the files added or modified by the push, and not removed again, in the order they were
+	// first changed.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// pushEvent is the part of GitHub and GitLab push events Push is taken from
+type pushEvent struct {
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Project struct {
+		ID int64 `json:"id"`
+	} `json:"project"`
+}
+
+// ParseGitHubPush parses a GitHub push event. It returns nil if the push deleted the branch.
+func ParseGitHubPush(b []byte) (*Push, error) {
+	e, err := parsePushEvent(b)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if e.Repository.FullName == "" {
+		return nil, errors.New("push event: a repository is required")
+	}
+	return e.push(e.Repository.FullName), nil
+}
+
+// ParseGitLabPush parses a GitLab push hook. It returns nil if the push deleted the branch.
+func ParseGitLabPush(b []byte) (*Push, error) {
+	e, err := parsePushEvent(b)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if e.Project.ID == 0 {
+		return nil, errors.New("push event: a project is required")
+	}
+	return e.push(fmt.Sprint(e.Project.ID)), nil
+}
+
+func parsePushEvent(b []byte) (*pushEvent, error) {
+	e := &pushEvent{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("push event: %s", err)
+	}
+	if e.Deleted || e.After == zeroCommit {
+		return nil, nil
+	}
+	if e.After == "" {
+		return nil, errors.New("push event: a commit is required")
+	}
+	return e, nil
+}
+
+func (e *pushEvent) push(repo string) *Push {
+	p := &Push{Repo: repo, Ref: e.Ref, Commit: e.After}
+	changed := map[string]bool{}
+	for _, c := range e.Commits {
+		for _, path := range append(append([]string{}, c.Added...), c.Modified...) {
+			if _, seen := changed[path]; !seen {
+				p.Changed = append(p.Changed, path)
+			}
+			changed[path] = true
+		}
+		for _, path := range c.Removed {
+			if _, seen := changed[path]; seen {
+				changed[path] = false
+			}
+		}
+	}
+	out := p.Changed[:0]
+	for _, path := range p.Changed {
+		if changed[path] {
+			out = append(out, path)
+		}
+	}
+	p.Changed = out
+	return p
+}
+
+// State is the state of a commit status
+type State string
+
+// Commit status states
+const (
+	Success State = "success"
+	Failure State = "failure"
+	// Error is for Jenkinsfiles which couldn't be fetched or validated.
+	Error State = "error"
+)
+
+// Status is the result of validating one Jenkinsfile, reported as a commit status
+type Status struct {
+	// Path is the Jenkinsfile's path in the repository.
+	Path  string `json:"path"`
+	State State  `json:"state"`
+	// Description summarizes the result in a line.
+	Description string              `json:"description"`
+	Findings    []*analysis.Finding `json:"findings,omitempty"`
+}
+
+// Context names the status on the commit, distinguishing it from those of other Jenkinsfiles and other checks.
+func (strct *Status) Context() string {
+	return "jenkinsfile/" + strct.Path
+}
+
+// SCM fetches files from and reports statuses to a source code host
+type SCM interface {
+	// Fetch returns the contents of a file at a commit.
+	Fetch(repo, commit, path string) ([]byte, error)
+	// SetStatus sets a commit status.
+	SetStatus(repo, commit string, status *Status) error
+}
+
+// Validator validates a Jenkinsfile, returning the problems found. It returns an error if the Jenkinsfile couldn't be
+// validated at all, as opposed to being invalid.
+type Validator func(path string, contents []byte) ([]*analysis.Finding, error)
+
+// ModelValidator returns a Validator which parses Jenkinsfiles into models with parse, and checks their invariants,
+// options, and parameters. Jenkinsfiles which can't be parsed are reported as a single "parse" finding.
+func ModelValidator(parse func(contents []byte) (*model.Root, error)) Validator {
+	return func(path string, contents []byte) ([]*analysis.Finding, error) {
+		root, err := parse(contents)
+		if err != nil {
+			return []*analysis.Finding{{Rule: "parse", Message: err.Error()}}, nil
+		}
+		var findings []*analysis.Finding
+		if err := model.CheckInvariants(root); err != nil {
+			findings = append(findings, &analysis.Finding{Rule: "invariants", Message: err.Error()})
+		}
+		for _, check := range []func(*model.Root) ([]*analysis.Finding, error){analysis.DuplicateOptions,
+			analysis.Parameters} {
+			f, err := check(root)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, f...)
+		}
+		return findings, nil
+	}
+}
+
+// Handler is an http.Handler for GitHub and GitLab push webhooks. It validates the Jenkinsfiles each push changes and
+// sets a commit status for each of them, responding with the statuses as JSON once they're all set. Other events,
+// such as GitHub's ping, are acknowledged and ignored.
+type Handler struct {
+	// GitHub and GitLab are the hosts' clients. Events from a host without one are rejected.
+	GitHub SCM
+	GitLab SCM
+	// Secret is the webhooks' secret, which GitHub signs its events with and GitLab sends as a token. If it's empty,
+	// events aren't authenticated.
+	Secret   string
+	Validate Validator
+	// Pattern is an Ant-style glob matching the paths of the Jenkinsfiles to validate. It defaults to DefaultPattern.
+	Pattern string
+	// Policy decides which findings fail a Jenkinsfile's status. Nil fails on errors.
+	Policy *analysis.ExitPolicy
+}
+
+// maxEventSize limits the size of webhook events read
+const maxEventSize = 25 << 20
+
+func (strct *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "webhooks must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var scm SCM
+	var push *Push
+	switch {
+	case r.Header.Get("X-GitHub-Event") != "":
+		if !strct.verifyGitHub(r.Header.Get("X-Hub-Signature-256"), body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-GitHub-Event") != "push" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		scm = strct.GitHub
+		push, err = ParseGitHubPush(body)
+	case r.Header.Get("X-Gitlab-Event") != "":
+		if strct.Secret != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(strct.Secret)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Gitlab-Event") != "Push Hook" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		scm = strct.GitLab
+		push, err = ParseGitLabPush(body)
+	default:
+		http.Error(w, "unknown webhook", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case scm == nil:
+		http.Error(w, "the webhook's host isn't configured", http.StatusNotImplemented)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case push == nil:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	statuses, err := strct.Check(scm, push)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// verifyGitHub checks the signature of a GitHub event
+func (strct *Handler) verifyGitHub(signature string, body []byte) bool {
+	if strct.Secret == "" {
+		return true
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(strct.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Check validates the Jenkinsfiles changed by a push and sets their commit statuses, returning them. It returns an
+// error if a status couldn't be set.
+func (strct *Handler) Check(scm SCM, push *Push) ([]*Status, error) {
+	if strct.Validate == nil {
+		return nil, errors.New("a validator is required")
+	}
+	pattern := strct.Pattern
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	statuses := []*Status{}
+	for _, path := range push.Changed {
+		if !when.Glob(pattern, path) {
+			continue
+		}
+		status := strct.check(scm, push, path)
+		if err := scm.SetStatus(push.Repo, push.Commit, status); err != nil {
+			return statuses, fmt.Errorf("%s: setting status: %s", path, err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (strct *Handler) check(scm SCM, push *Push, path string) *Status {
+	status := &Status{Path: path}
+	contents, err := scm.Fetch(push.Repo, push.Commit, path)
+	if err != nil {
+		status.State, status.Description = Error, "couldn't fetch: "+err.Error()
+		return status
+	}
+	status.Findings, err = strct.Validate(path, contents)
+	failing := strct.Policy.Failing(status.Findings)
+	switch {
+	case err != nil:
+		status.State, status.Description = Error, "couldn't validate: "+err.Error()
+	case len(status.Findings) == 0:
+		status.State, status.Description = Success, "valid"
+	case len(failing) == 0:
+		status.State = Success
+		status.Description = fmt.Sprintf("valid, with %d findings below the failure threshold", len(status.Findings))
+	case len(failing) == 1:
+		status.State, status.Description = Failure, failing[0].Message
+	default:
+		status.State = Failure
+		status.Description = fmt.Sprintf("%d problems, first: %s", len(failing), failing[0].Message)
+	}
+	return status
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/analysis"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const githubPush = `{
+  "ref": "refs/heads/main",
+  "after": "abc123",
+  "repository": {"full_name": "org/repo"},
+  "commits": [
+    {"added": ["Jenkinsfile", "ci/old/Jenkinsfile"], "modified": ["README.md"], "removed": []},
+    {"added": [], "modified": ["services/api/Jenkinsfile"], "removed": ["ci/old/Jenkinsfile"]}
+  ]
+}`
+
+func TestParsePush(t *testing.T) {
+	push, err := ParseGitHubPush([]byte(githubPush))
+	require.NoError(t, err)
+	assert.Equal(t, &Push{Repo: "org/repo", Ref: "refs/heads/main", Commit: "abc123",
+		Changed: []string{"Jenkinsfile", "README.md", "services/api/Jenkinsfile"}}, push)
+
+	push, err = ParseGitLabPush([]byte(`{"ref": "refs/heads/main", "after": "def456", "project": {"id": 42},
+		"commits": [{"added": [], "modified": ["Jenkinsfile"], "removed": []}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Push{Repo: "42", Ref: "refs/heads/main", Commit: "def456", Changed: []string{"Jenkinsfile"}}, push)
+
+	push, err = ParseGitHubPush([]byte(`{"ref": "refs/heads/gone", "after": "` + zeroCommit + `", "deleted": true,
+		"repository": {"full_name": "org/repo"}}`))
+	require.NoError(t, err)
+	assert.Nil(t, push)
+
+	_, err = ParseGitLabPush([]byte(`{"ref": "refs/heads/main", "after": "def456"}`))
+	assert.EqualError(t, err, "push event: a project is required")
+}
+
+// fakeSCM serves files from a map and records statuses
+type fakeSCM struct {
+	files    map[string]string
+	statuses []*Status
+}
+
+func (f *fakeSCM) Fetch(repo, commit, path string) ([]byte, error) {
+	contents, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(contents), nil
+}
+
+func (f *fakeSCM) SetStatus(repo, commit string, status *Status) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func parseJSON(contents []byte) (*model.Root, error) {
+	root := &model.Root{}
+	if err := json.Unmarshal(contents, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func TestHandler(t *testing.T) {
+	scm := &fakeSCM{files: map[string]string{
+		"Jenkinsfile": `{"pipeline": {"agent": {"type": "any"}, "stages": [{"name": "Build", "branches": [
+			{"name": "default", "steps": [{"name": "sh", "arguments": {"isLiteral": true, "value": "make"}}]}]}]}}`,
+		"services/api/Jenkinsfile": `{"pipeline": {}}`,
+	}}
+	h := &Handler{GitHub: scm, Secret: "s3cret", Validate: ModelValidator(parseJSON)}
+
+	post := func(event, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(githubPush))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(githubPush))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, http.StatusUnauthorized, post("push", "sha256=00").Code)
+	assert.Equal(t, http.StatusNoContent, post("ping", signature).Code)
+	assert.Empty(t, scm.statuses)
+
+	rec := post("push", signature)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, scm.statuses, 2)
+	assert.Equal(t, "jenkinsfile/Jenkinsfile", scm.statuses[0].Context())
+	assert.Equal(t, Success, scm.statuses[0].State)
+	assert.Equal(t, "services/api/Jenkinsfile", scm.statuses[1].Path)
+	assert.Equal(t, Failure, scm.statuses[1].State)
+	assert.Equal(t, "parse", scm.statuses[1].Findings[0].Rule)
+
+	var statuses []*Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	assert.Len(t, statuses, 2)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	req.Header.Set("X-Gitlab-Token", "s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestGitHub(t *testing.T) {
+	var status map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/contents/ci/Jenkinsfile":
+			assert.Equal(t, "abc123", r.URL.Query().Get("ref"))
+			_, _ = w.Write([]byte("pipeline {}"))
+		case r.Method == http.MethodGet && r.URL.Path == "/orgs/org/repos":
+			if r.URL.Query().Get("page") == "1" {
+				repos := make([]map[string]interface{}, perPage)
+				for i := range repos {
+					repos[i] = map[string]interface{}{"full_name": fmt.Sprintf("org/repo%d", i),
+						"default_branch": "main"}
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(repos))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"full_name": "org/old", "default_branch": "master", "archived": true}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/repo/statuses/abc123":
+			b, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(b, &status))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gh := &GitHub{URL: server.URL, Token: "token"}
+	contents, err := gh.Fetch("org/repo", "abc123", "ci/Jenkinsfile")
+	require.NoError(t, err)
+	assert.Equal(t, "pipeline {}", string(contents))
+	_, err = gh.Fetch("org/repo", "abc123", "missing")
+	assert.EqualError(t, err, "GET /repos/org/repo/contents/missing: 404 Not Found")
+	assert.True(t, IsNotFound(err))
+
+	repos, err := gh.Repositories("org")
+	require.NoError(t, err)
+	require.Len(t, repos, perPage+1)
+	assert.Equal(t, &Repository{Name: "org/repo0", DefaultBranch: "main"}, repos[0])
+	assert.Equal(t, &Repository{Name: "org/old", DefaultBranch: "master", Archived: true}, repos[perPage])
+
+	require.NoError(t, gh.SetStatus("org/repo", "abc123", &Status{Path: "ci/Jenkinsfile", State: Failure,
+		Description: strings.Repeat("x", 200)}))
+	assert.Equal(t, "failure", status["state"])
+	assert.Equal(t, "jenkinsfile/ci/Jenkinsfile", status["context"])
+	assert.Len(t, []rune(status["description"]), maxDescription)
+}
+
+func TestGitHubPullRequest(t *testing.T) {
+	var requests []string
+	var written, opened map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/org/repo/git/ref/heads/main":
+			_, _ = w.Write([]byte(`{"object": {"sha": "abc123"}}`))
+		case "POST /repos/org/repo/git/refs":
+			b, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"ref": "refs/heads/update", "sha": "abc123"}`, string(b))
+			w.WriteHeader(http.StatusCreated)
+		case "GET /repos/org/repo/contents/Jenkinsfile":
+			assert.Equal(t, "update", r.URL.Query().Get("ref"))
+			_, _ = w.Write([]byte(`{"sha": "def456"}`))
+		case "PUT /repos/org/repo/contents/Jenkinsfile":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&written))
+		case "POST /repos/org/repo/pulls":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&opened))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"html_url": "https://github.com/org/repo/pull/1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gh := &GitHub{URL: server.URL}
+	require.NoError(t, gh.CreateBranch("org/repo", "update", "main"))
+	require.NoError(t, gh.WriteFile("org/repo", "update", "Jenkinsfile", []byte("pipeline {}"), "Update"))
+	assert.Equal(t, map[string]string{"message": "Update", "branch": "update", "sha": "def456",
+		"content": "cGlwZWxpbmUge30="}, written)
+	u, err := gh.OpenPullRequest("org/repo", "update", "main", "Update", "Changes")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/org/repo/pull/1", u)
+	assert.Equal(t, map[string]string{"head": "update", "base": "main", "title": "Update", "body": "Changes"}, opened)
+	assert.Len(t, requests, 5)
+}
+
+func TestGitLabMergeRequest(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("PRIVATE-TOKEN"))
+		switch r.URL.EscapedPath() {
+		case "/projects/42/repository/branches":
+			w.WriteHeader(http.StatusCreated)
+		case "/projects/42/repository/files/ci%2FJenkinsfile":
+			methods = append(methods, r.Method)
+			if r.Method == http.MethodGet {
+				http.NotFound(w, r)
+			}
+		case "/projects/42/merge_requests":
+			_, _ = w.Write([]byte(`{"web_url": "https://gitlab.com/org/repo/-/merge_requests/1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gl := &GitLab{URL: server.URL, Token: "token"}
+	require.NoError(t, gl.CreateBranch("42", "update", "main"))
+	require.NoError(t, gl.WriteFile("42", "update", "ci/Jenkinsfile", []byte("pipeline {}"), "Update"))
+	assert.Equal(t, []string{http.MethodGet, http.MethodPost}, methods, "missing files are created")
+	u, err := gl.OpenPullRequest("42", "update", "main", "Update", "Changes")
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.com/org/repo/-/merge_requests/1", u)
+}
+
+func TestCheckPolicy(t *testing.T) {
+	scm := &fakeSCM{files: map[string]string{"Jenkinsfile": "pipeline {}"}}
+	h := &Handler{Validate: func(path string, contents []byte) ([]*analysis.Finding, error) {
+		return []*analysis.Finding{{Rule: "unused-parameter", Message: `parameter "A" is never used`}}, nil
+	}}
+	push := &Push{Repo: "org/repo", Commit: "abc123", Changed: []string{"Jenkinsfile"}}
+	statuses, err := h.Check(scm, push)
+	require.NoError(t, err)
+	assert.Equal(t, Success, statuses[0].State)
+	assert.Equal(t, "valid, with 1 findings below the failure threshold", statuses[0].Description)
+
+	h.Policy = &analysis.ExitPolicy{Default: analysis.Info}
+	statuses, err = h.Check(scm, push)
+	require.NoError(t, err)
+	assert.Equal(t, Failure, statuses[0].State)
+	assert.Equal(t, `parameter "A" is never used`, statuses[0].Description)
+}
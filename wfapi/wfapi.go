@@ -0,0 +1,209 @@
+// Package wfapi correlates the static structure of a pipeline with the runtime data returned by the Jenkins
+// workflow-api REST endpoints (wfapi/describe), so tools can join stages and steps with their results.
+package wfapi
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/plan"
+)
+
+// Run is the response of a build's wfapi/describe endpoint
+type Run struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	StartTimeMillis int64   `json:"startTimeMillis"`
+	DurationMillis  int64   `json:"durationMillis"`
+	Stages          []*Node `json:"stages"`
+}
+
+// Node is a stage in a Run, or the response of a stage node's execution/node/<id>/wfapi/describe endpoint, which
+// also lists the step nodes run by the stage
+type Node struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	ExecNode            string `json:"execNode,omitempty"`
+	Status              string `json:"status"`
+	StartTimeMillis     int64  `json:"startTimeMillis"`
+	DurationMillis      int64  `json:"durationMillis"`
+	PauseDurationMillis int64  `json:"pauseDurationMillis"`
+	// StageFlowNodes are the step nodes of a stage node description.
+	StageFlowNodes []*StepNode `json:"stageFlowNodes,omitempty"`
+}
+
+// StepNode is a step in a stage node description
+type StepNode struct {
+	ID string `json:"id"`
+	// Name is the step's display name, such as "Shell Script" for sh.
+	Name                 string   `json:"name"`
+	ParameterDescription string   `json:"parameterDescription,omitempty"`
+	Status               string   `json:"status"`
+	StartTimeMillis      int64    `json:"startTimeMillis"`
+	DurationMillis       int64    `json:"durationMillis"`
+	ParentNodes          []string `json:"parentNodes,omitempty"`
+}
+
+// Duration returns the node's duration.
+func (strct *Node) Duration() time.Duration {
+	return time.Duration(strct.DurationMillis) * time.Millisecond
+}
+
+// DecodeRun decodes the response of a wfapi/describe endpoint.
+func DecodeRun(r io.Reader) (*Run, error) {
+	run := &Run{}
+	if err := json.NewDecoder(r).Decode(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// DecodeNode decodes the response of a stage node's wfapi/describe endpoint.
+func DecodeNode(r io.Reader) (*Node, error) {
+	node := &Node{}
+	if err := json.NewDecoder(r).Decode(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// StepDisplayNames maps step names to the display names workflow-api reports for them. Steps not listed here are
+// matched by their own name.
+var StepDisplayNames = map[string]string{
+	"archiveArtifacts": "Archive the artifacts",
+	"bat":              "Windows Batch Script",
+	"build":            "Build a job",
+	"catchError":       "Catch error and set build result to failure",
+	"checkout":         "Check out from version control",
+	"deleteDir":        "Recursively delete the current directory from the workspace",
+	"dir":              "Change current directory",
+	"echo":             "Print Message",
+	"error":            "Error signal",
+	"git":              "Git",
+	"input":            "Wait for interactive input",
+	"junit":            "Archive JUnit-formatted test results",
+	"powershell":       "Windows PowerShell Script",
+	"pwsh":             "PowerShell Core Script",
+	"readFile":         "Read file from workspace",
+	"retry":            "Retry the body up to N times",
+	"sh":               "Shell Script",
+	"sleep":            "Sleep",
+	"stash":            "Stash some files to be used later in the build",
+	"timeout":          "Enforce time limit",
+	"unstash":          "Restore files previously stashed",
+	"withCredentials":  "Bind credentials to variables",
+	"withEnv":          "Set environment variables",
+	"writeFile":        "Write file to workspace",
+}
+
+// StageResult joins a plan stage with the node workflow-api reported for it
+type StageResult struct {
+	Stage *plan.Stage `json:"-"`
+	// ID is the plan stage's ID.
+	ID   string `json:"id"`
+	Node *Node  `json:"node"`
+	// Steps joins the stage's steps, in order, with the step nodes of Node. It is only set when Node has
+	// StageFlowNodes, that is when it comes from a stage node description.
+	Steps []*StepResult `json:"steps,omitempty"`
+}
+
+// StepResult joins a plan step with the step node workflow-api reported for it
+type StepResult struct {
+	Step *plan.Step `json:"-"`
+	Name string     `json:"name"`
+	Node *StepNode  `json:"node,omitempty"`
+}
+
+// Correlation is the result of joining a plan with a run
+type Correlation struct {
+	Run    *Run           `json:"-"`
+	Stages []*StageResult `json:"stages"`
+	// NotRun are the IDs of plan stages with no node in the run, such as stages after a failure.
+	NotRun []string `json:"notRun,omitempty"`
+	// Unknown are the names of run stages with no stage in the plan, such as stages from a different revision of
+	// the Jenkinsfile.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// Correlate joins the stages of a plan with the stages of a run. Declarative stage names are unique within a
+// pipeline, so stages are matched by name. If nodes maps stage node IDs to their descriptions, the steps of each
+// matched stage are joined with its step nodes too.
+func Correlate(p *plan.Plan, run *Run, nodes map[string]*Node) *Correlation {
+	byName := map[string]*Node{}
+	for _, n := range run.Stages {
+		if n != nil {
+			byName[n.Name] = n
+		}
+	}
+	c := &Correlation{Run: run, Stages: []*StageResult{}}
+	matched := map[string]bool{}
+	var visit func(stages []*plan.Stage)
+	visit = func(stages []*plan.Stage) {
+		for _, s := range stages {
+			if n, ok := byName[s.Name]; ok {
+				matched[s.Name] = true
+				if described, ok := nodes[n.ID]; ok {
+					n = described
+				}
+				c.Stages = append(c.Stages, &StageResult{Stage: s, ID: s.ID, Node: n, Steps: steps(s, n)})
+			} else if len(s.Children) == 0 {
+				c.NotRun = append(c.NotRun, s.ID)
+			}
+			visit(s.Children)
+		}
+	}
+	visit(p.Stages)
+	for _, n := range run.Stages {
+		if n != nil && !matched[n.Name] {
+			c.Unknown = append(c.Unknown, n.Name)
+		}
+	}
+	return c
+}
+
+// steps joins a stage's steps with its step nodes in order. Each step is matched with the next node with its display
+// name, skipping nodes which don't match, such as those of steps run from script blocks.
+func steps(s *plan.Stage, n *Node) []*StepResult {
+	if len(n.StageFlowNodes) == 0 {
+		return nil
+	}
+	var out []*StepResult
+	next := 0
+	var visit func(steps []*plan.Step)
+	visit = func(steps []*plan.Step) {
+		for _, st := range steps {
+			r := &StepResult{Step: st, Name: st.Name}
+			display, ok := StepDisplayNames[st.Name]
+			if !ok {
+				display = st.Name
+			}
+			for i := next; i < len(n.StageFlowNodes); i++ {
+				if n.StageFlowNodes[i].Name == display {
+					r.Node = n.StageFlowNodes[i]
+					next = i + 1
+					break
+				}
+			}
+			out = append(out, r)
+			visit(st.Children)
+		}
+	}
+	visit(s.Steps)
+	return out
+}
+
+// Durations returns the duration of each matched stage keyed by plan stage ID, and of the whole run keyed by the
+// empty string, in the form accepted by plan.Plan.AttachDurations. Correlations of several runs can be merged by
+// appending their durations.
+func (strct *Correlation) Durations() map[string][]time.Duration {
+	out := map[string][]time.Duration{}
+	if strct.Run != nil && strct.Run.DurationMillis > 0 {
+		out[""] = []time.Duration{time.Duration(strct.Run.DurationMillis) * time.Millisecond}
+	}
+	for _, s := range strct.Stages {
+		out[s.ID] = append(out[s.ID], s.Node.Duration())
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package wfapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadPlan(t *testing.T, name string) *plan.Plan {
+	contents, err := ioutil.ReadFile(filepath.Join("..", "model", "testdata", "json", name+".json"))
+	require.NoError(t, err)
+	root := &model.Root{}
+	require.NoError(t, json.Unmarshal(contents, root))
+	p, err := plan.Build(root)
+	require.NoError(t, err)
+	return p
+}
+
+func TestCorrelate(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "describe.json"))
+	require.NoError(t, err)
+	defer f.Close()
+	run, err := DecodeRun(f)
+	require.NoError(t, err)
+
+	f, err = os.Open(filepath.Join("testdata", "node-19.json"))
+	require.NoError(t, err)
+	defer f.Close()
+	node, err := DecodeNode(f)
+	require.NoError(t, err)
+
+	c := Correlate(loadPlan(t, "parallel/parallelStagesGroupsAndStages"), run, map[string]*Node{node.ID: node})
+	var ids []string
+	for _, s := range c.Stages {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"foo", "foo/first", "foo/second", "foo/second/inner-first"}, ids)
+	assert.Equal(t, []string{"foo/second/inner-second"}, c.NotRun)
+	assert.Equal(t, []string{"Declarative: Post Actions"}, c.Unknown)
+
+	assert.Nil(t, c.Stages[1].Steps)
+	inner := c.Stages[3]
+	assert.Equal(t, "FAILED", inner.Node.Status)
+	require.Len(t, inner.Steps, 2)
+	assert.Equal(t, "echo", inner.Steps[0].Name)
+	assert.Equal(t, "22", inner.Steps[0].Node.ID)
+	assert.Equal(t, "script", inner.Steps[1].Name)
+	assert.Nil(t, inner.Steps[1].Node)
+
+	assert.Equal(t, map[string][]time.Duration{
+		"":                       {95 * time.Second},
+		"foo":                    {80 * time.Second},
+		"foo/first":              {1500 * time.Millisecond},
+		"foo/second":             {78 * time.Second},
+		"foo/second/inner-first": {77 * time.Second},
+	}, c.Durations())
+}
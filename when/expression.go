@@ -0,0 +1,378 @@
+package when
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression evaluates the Groovy of an expression condition against the context, by Groovy truth. It understands
+// string, number, boolean, and null literals; the identifiers Lookup resolves; the toBoolean, toString, trim, and
+// equals methods, with or without safe navigation; and the ==, !=, ==~, !, &&, and || operators, with parentheses.
+// Anything else, such as interpolated strings or other method calls, is Unknown.
+func Expression(expr string, ctx *Context) Result {
+	v, ok := value(expr, ctx)
+	if !ok {
+		return Unknown
+	}
+	return fromBool(truthy(v))
+}
+
+// value evaluates an expression, returning its value and false if it isn't known
+func value(expr string, ctx *Context) (interface{}, bool) {
+	expr = strings.TrimSuffix(strings.TrimSpace(expr), ";")
+	expr = strings.TrimPrefix(expr, "return ")
+	p := &exprParser{ctx: ctx}
+	if !p.tokenize(expr) {
+		return nil, false
+	}
+	v := p.or()
+	if p.failed || p.pos < len(p.tokens) {
+		return nil, false
+	}
+	return v.value, v.known
+}
+
+// operand is a value which may not be known
+type operand struct {
+	value interface{}
+	known bool
+}
+
+type tokenKind int
+
+const (
+	tokenOperator tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenRegexp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// exprParser is a recursive descent parser evaluating expressions as it goes. failed is set if the expression uses
+// something it doesn't understand.
+type exprParser struct {
+	ctx    *Context
+	tokens []*token
+	pos    int
+	failed bool
+}
+
+var (
+	exprOperators  = []string{"==~", "==", "!=", "&&", "||", "?.", "!", "(", ")", "."}
+	exprIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*`)
+	exprNumber     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?`)
+)
+
+func (p *exprParser) tokenize(s string) bool {
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if m := exprIdentifier.FindString(s); m != "" {
+			p.tokens = append(p.tokens, &token{kind: tokenIdentifier, text: m})
+			s = s[len(m):]
+			continue
+		}
+		if m := exprNumber.FindString(s); m != "" {
+			p.tokens = append(p.tokens, &token{kind: tokenNumber, text: m})
+			s = s[len(m):]
+			continue
+		}
+		if s[0] == '\'' || s[0] == '"' || s[0] == '/' && p.last("==~") {
+			text, rest, ok := quoted(s)
+			if !ok {
+				return false
+			}
+			kind := tokenString
+			if s[0] == '/' {
+				kind = tokenRegexp
+			}
+			p.tokens = append(p.tokens, &token{kind: kind, text: text})
+			s = rest
+			continue
+		}
+		found := false
+		for _, op := range exprOperators {
+			if strings.HasPrefix(s, op) {
+				p.tokens = append(p.tokens, &token{kind: tokenOperator, text: op})
+				s = s[len(op):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// quoted returns the text of the string at the start of s, quoted with its first character, and the rest of s. It
+// returns false for unterminated strings, and for interpolated ones, whose values aren't known.
+func quoted(s string) (string, string, bool) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == quote:
+			return b.String(), s[i+1:], true
+		case c == '$' && quote != '\'':
+			return "", "", false
+		case c == '\\' && i+1 < len(s) && quote != '/':
+			i++
+			b.WriteByte(s[i])
+		case c == '\\' && i+1 < len(s) && s[i+1] == '/':
+			i++
+			b.WriteByte('/')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
+
+// last returns whether the last token so far is the given operator
+func (p *exprParser) last(op string) bool {
+	return len(p.tokens) > 0 && p.tokens[len(p.tokens)-1].kind == tokenOperator && p.tokens[len(p.tokens)-1].text == op
+}
+
+// accept consumes the next token if it's the given operator
+func (p *exprParser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) next() *token {
+	if p.pos >= len(p.tokens) {
+		p.failed = true
+		return &token{kind: tokenOperator}
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *exprParser) or() operand {
+	left := p.and()
+	for p.accept("||") {
+		right := p.and()
+		switch {
+		case left.known && truthy(left.value), right.known && truthy(right.value):
+			left = operand{true, true}
+		case left.known && right.known:
+			left = operand{false, true}
+		default:
+			left = operand{}
+		}
+	}
+	return left
+}
+
+func (p *exprParser) and() operand {
+	left := p.comparison()
+	for p.accept("&&") {
+		right := p.comparison()
+		switch {
+		case left.known && !truthy(left.value), right.known && !truthy(right.value):
+			left = operand{false, true}
+		case left.known && right.known:
+			left = operand{true, true}
+		default:
+			left = operand{}
+		}
+	}
+	return left
+}
+
+func (p *exprParser) unary() operand {
+	if p.accept("!") {
+		v := p.unary()
+		if !v.known {
+			return v
+		}
+		return operand{!truthy(v.value), true}
+	}
+	return p.primary()
+}
+
+func (p *exprParser) comparison() operand {
+	left := p.unary()
+	for _, op := range []string{"==~", "==", "!="} {
+		if !p.accept(op) {
+			continue
+		}
+		right := p.unary()
+		if !left.known || !right.known {
+			return operand{}
+		}
+		switch op {
+		case "==":
+			return operand{equal(left.value, right.value), true}
+		case "!=":
+			return operand{!equal(left.value, right.value), true}
+		}
+		s, ok := left.value.(string)
+		pattern, ok2 := right.value.(string)
+		if !ok || !ok2 {
+			return operand{}
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return operand{}
+		}
+		return operand{re.MatchString(s), true}
+	}
+	return left
+}
+
+func (p *exprParser) primary() operand {
+	var v operand
+	switch t := p.next(); {
+	case t.kind == tokenOperator && t.text == "(":
+		v = p.or()
+		if !p.accept(")") {
+			p.failed = true
+		}
+	case t.kind == tokenString, t.kind == tokenRegexp:
+		v = operand{t.text, true}
+	case t.kind == tokenNumber:
+		f, _ := strconv.ParseFloat(t.text, 64)
+		v = operand{f, true}
+	case t.kind == tokenIdentifier && (t.text == "true" || t.text == "false"):
+		v = operand{t.text == "true", true}
+	case t.kind == tokenIdentifier && t.text == "null":
+		v = operand{nil, true}
+	case t.kind == tokenIdentifier:
+		name := t.text
+		for p.pos+1 < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == "." &&
+			p.tokens[p.pos+1].kind == tokenIdentifier && !p.method(p.pos+1) {
+			name += "." + p.tokens[p.pos+1].text
+			p.pos += 2
+		}
+		v.value, v.known = p.ctx.Lookup(name)
+	default:
+		p.failed = true
+		return operand{}
+	}
+	return p.methods(v)
+}
+
+// method returns whether the identifier token at i is a method name, followed by its arguments
+func (p *exprParser) method(i int) bool {
+	return i+1 < len(p.tokens) && p.tokens[i+1].kind == tokenOperator && p.tokens[i+1].text == "("
+}
+
+// methods applies the method calls following a value
+func (p *exprParser) methods(v operand) operand {
+	for {
+		safe := p.accept("?.")
+		if !safe && !p.accept(".") {
+			return v
+		}
+		name := p.next()
+		if name.kind != tokenIdentifier || !p.accept("(") {
+			p.failed = true
+			return operand{}
+		}
+		var arg operand
+		if name.text == "equals" {
+			arg = p.or()
+		}
+		if !p.accept(")") {
+			p.failed = true
+			return operand{}
+		}
+		if !v.known {
+			continue
+		}
+		if v.value == nil {
+			if !safe {
+				// A null pointer exception fails the build, rather than the condition.
+				p.failed = true
+			}
+			continue
+		}
+		switch name.text {
+		case "toBoolean":
+			if s, ok := v.value.(string); ok {
+				s = strings.ToLower(strings.TrimSpace(s))
+				v.value = s == "true" || s == "y" || s == "1"
+			} else if _, ok := v.value.(bool); !ok {
+				p.failed = true
+			}
+		case "toString":
+			v.value = toString(v.value)
+		case "trim":
+			s, ok := v.value.(string)
+			if !ok {
+				p.failed = true
+			}
+			v.value = strings.TrimFunc(s, unicode.IsSpace)
+		case "equals":
+			v = operand{equal(v.value, arg.value), arg.known}
+		default:
+			p.failed = true
+		}
+	}
+}
+
+// truthy returns the Groovy truth of a value
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	}
+	if f, ok := number(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// equal compares values as Groovy's == does, so numbers of different types are compared by value
+func equal(a, b interface{}) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	return a == b
+}
+
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	}
+	return 0, false
+}
+
+// toString returns a value as Groovy prints it
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	if f, ok := number(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return ""
+}
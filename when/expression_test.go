@@ -0,0 +1,81 @@
+package when
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpression(t *testing.T) {
+	ctx := &Context{
+		Branch:         "release/1.2",
+		Env:            map[string]string{"DEPLOY": "true", "EMPTY": ""},
+		Params:         map[string]interface{}{"RUN_TESTS": false, "TARGET": "staging", "RETRIES": float64(3)},
+		Result:         "SUCCESS",
+		Number:         42,
+		PreviousResult: "FAILURE",
+	}
+	tests := []struct {
+		expr     string
+		expected Result
+	}{
+		{"true", True},
+		{"return false;", False},
+		{"env.BRANCH_NAME == 'release/1.2'", True},
+		{`BRANCH_NAME ==~ /release\/.*/`, True},
+		{`env.BRANCH_NAME ==~ "main|master"`, False},
+		{"env.DEPLOY.toBoolean()", True},
+		{"env.EMPTY", False},
+		{"env.MISSING == null", True},
+		{"env.MISSING?.trim()", False},
+		{"params.RUN_TESTS", False},
+		{"!params.RUN_TESTS", True},
+		{"params.RUN_TESTS == false", True},
+		{"RUN_TESTS", False},
+		{"env.RUN_TESTS == 'false'", True},
+		{"params.TARGET.equals('staging') && params.RETRIES == 3", True},
+		{"params.UNDEFINED", False},
+		{"currentBuild.result == null", True},
+		{"currentBuild.currentResult == 'SUCCESS'", True},
+		{"currentBuild.number == 42", True},
+		{"currentBuild.previousBuild.result != 'SUCCESS'", True},
+		{"(params.TARGET == 'prod' || env.DEPLOY == 'true') && !(currentBuild.number == 1)", True},
+		// Method calls and operators which aren't understood make the whole expression unknown.
+		{"isRelease() || true", Unknown},
+		{"currentBuild.changeSets.size() > 0", Unknown},
+		// Unknown operands decide nothing, unless the other operand does.
+		{"currentBuild.description == 'x' || true", True},
+		{"currentBuild.description == 'x' && false", False},
+		{"currentBuild.description == 'x' && true", Unknown},
+		{`"${env.TARGET}" == 'staging'`, Unknown},
+		{"env.MISSING.trim()", Unknown},
+		{"(true", Unknown},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Expression(tc.expr, ctx))
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ctx := &Context{Branch: "main", Params: map[string]interface{}{"DEPLOY": true}}
+
+	v, ok := ctx.Lookup("params.DEPLOY")
+	assert.True(t, ok)
+	assert.Equal(t, true, v)
+	v, ok = ctx.Lookup("env.DEPLOY")
+	assert.True(t, ok)
+	assert.Equal(t, "true", v)
+	v, ok = ctx.Lookup("env.BRANCH_NAME")
+	assert.True(t, ok)
+	assert.Equal(t, "main", v)
+
+	// Without Env, other variables and the results aren't known.
+	_, ok = ctx.Lookup("env.OTHER")
+	assert.False(t, ok)
+	_, ok = ctx.Lookup("currentBuild.result")
+	assert.False(t, ok)
+	_, ok = ctx.Lookup("currentBuild.number")
+	assert.False(t, ok)
+}
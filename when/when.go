@@ -0,0 +1,504 @@
+// Package when evaluates the when conditions of Declarative stages against a hypothetical build, described by a
+// Context. Conditions which can't be decided from the context, such as Groovy expressions calling arbitrary methods,
+// evaluate to Unknown rather than guessing.
+package when
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/model"
+)
+
+// Result is the outcome of evaluating a condition
+type Result int
+
+const (
+	// False means the condition is not met
+	False Result = iota
+	// True means the condition is met
+	True
+	// Unknown means the condition can't be decided from the context
+	Unknown
+)
+
+func (r Result) String() string {
+	switch r {
+	case True:
+		return "true"
+	case False:
+		return "false"
+	}
+	return "unknown"
+}
+
+func fromBool(b bool) Result {
+	if b {
+		return True
+	}
+	return False
+}
+
+// ChangeRequest describes the pull or merge request a build is for
+type ChangeRequest struct {
+	ID                string `json:"id,omitempty"`
+	Target            string `json:"target,omitempty"`
+	Branch            string `json:"branch,omitempty"`
+	Fork              string `json:"fork,omitempty"`
+	URL               string `json:"url,omitempty"`
+	Title             string `json:"title,omitempty"`
+	Author            string `json:"author,omitempty"`
+	AuthorDisplayName string `json:"authorDisplayName,omitempty"`
+	AuthorEmail       string `json:"authorEmail,omitempty"`
+}
+
+// Context describes a hypothetical build. Nil slices and maps mean the information isn't known, so conditions which
+// depend on it evaluate to Unknown; empty ones mean there is nothing, such as no changed files.
+type Context struct {
+	// Name identifies the context in reports.
+	Name string `json:"name"`
+	// Branch is the branch being built, as in BRANCH_NAME. An empty branch is unknown.
+	Branch string `json:"branch,omitempty"`
+	// Tag is the tag being built, if any.
+	Tag string `json:"tag,omitempty"`
+	// ChangeRequest is set when building a pull or merge request.
+	ChangeRequest *ChangeRequest `json:"changeRequest,omitempty"`
+	// ChangedFiles are the paths changed by the build's commits.
+	ChangedFiles []string `json:"changedFiles,omitempty"`
+	// ChangeLog are the messages of the build's commits.
+	ChangeLog []string `json:"changeLog,omitempty"`
+	// Env is the build's environment, besides the variables Getenv derives from the other fields.
+	Env map[string]string `json:"env,omitempty"`
+	// Causes are the names of the causes of the build, such as "UserIdCause" or "TimerTrigger".
+	Causes []string `json:"causes,omitempty"`
+	// Params are the build's parameters, as typed values: strings, bools, and float64 numbers, as decoded from JSON.
+	// Parameters are also environment variables, as strings.
+	Params map[string]interface{} `json:"params,omitempty"`
+	// Result is the build's result so far, such as "UNSTABLE", for conditions comparing currentBuild.result or
+	// currentBuild.currentResult. An empty result is unknown.
+	Result string `json:"result,omitempty"`
+	// Number is the build's number, as in currentBuild.number. Zero is unknown.
+	Number int `json:"number,omitempty"`
+	// PreviousResult is the result of the previous build, as in currentBuild.previousBuild.result. An empty result is
+	// unknown.
+	PreviousResult string `json:"previousResult,omitempty"`
+}
+
+// Lookup returns the typed value of an identifier during the build, such as "env.BRANCH_NAME", "params.DEPLOY", or
+// "currentBuild.result", and false if it isn't known. Values are strings, bools, float64 numbers, or nil for null.
+// Other names are environment variables or parameters, which Groovy scripts can use without a prefix.
+func (ctx *Context) Lookup(name string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(name, "env."):
+		return ctx.variable(strings.TrimPrefix(name, "env."))
+	case strings.HasPrefix(name, "params."):
+		if ctx.Params == nil {
+			return nil, false
+		}
+		// Parameters which aren't defined are null.
+		return ctx.Params[strings.TrimPrefix(name, "params.")], true
+	case name == "currentBuild.currentResult" || name == "currentBuild.result":
+		return currentResult(name, ctx)
+	case name == "currentBuild.number":
+		return float64(ctx.Number), ctx.Number > 0
+	case name == "currentBuild.previousBuild.result":
+		return ctx.PreviousResult, ctx.PreviousResult != ""
+	case strings.Contains(name, "."):
+		return nil, false
+	}
+	if v, ok := ctx.Params[name]; ok {
+		return v, true
+	}
+	return ctx.variable(name)
+}
+
+// variable returns the value of an environment variable, or nil if Env is given and it isn't set
+func (ctx *Context) variable(name string) (interface{}, bool) {
+	if v, ok := ctx.env(name); ok {
+		return v, true
+	}
+	return nil, ctx.Env != nil
+}
+
+// Getenv returns the value of an environment variable during the build, and false if it isn't known. Besides Env,
+// this includes the parameters, and the variables Jenkins sets from the branch, tag, and change request, such as
+// BRANCH_NAME and CHANGE_TARGET.
+func (ctx *Context) Getenv(name string) (string, bool) {
+	if v, ok := ctx.env(name); ok {
+		return v, true
+	}
+	return "", ctx.Env != nil
+}
+
+// env returns the value of an environment variable, and false if it isn't set, or isn't known to be
+func (ctx *Context) env(name string) (string, bool) {
+	if v, ok := ctx.Env[name]; ok {
+		return v, true
+	}
+	if v, ok := ctx.Params[name]; ok {
+		return toString(v), true
+	}
+	switch {
+	case name == "BRANCH_NAME" && ctx.Branch != "":
+		return ctx.Branch, true
+	case name == "TAG_NAME" && ctx.Tag != "":
+		return ctx.Tag, true
+	case ctx.ChangeRequest != nil:
+		if get, ok := changeRequestEnv[name]; ok {
+			return get(ctx.ChangeRequest), true
+		}
+	}
+	return "", false
+}
+
+// changeRequestEnv are the environment variables Jenkins sets from a change request
+var changeRequestEnv = map[string]func(cr *ChangeRequest) string{
+	"CHANGE_ID":                  func(cr *ChangeRequest) string { return cr.ID },
+	"CHANGE_TARGET":              func(cr *ChangeRequest) string { return cr.Target },
+	"CHANGE_BRANCH":              func(cr *ChangeRequest) string { return cr.Branch },
+	"CHANGE_FORK":                func(cr *ChangeRequest) string { return cr.Fork },
+	"CHANGE_URL":                 func(cr *ChangeRequest) string { return cr.URL },
+	"CHANGE_TITLE":               func(cr *ChangeRequest) string { return cr.Title },
+	"CHANGE_AUTHOR":              func(cr *ChangeRequest) string { return cr.Author },
+	"CHANGE_AUTHOR_DISPLAY_NAME": func(cr *ChangeRequest) string { return cr.AuthorDisplayName },
+	"CHANGE_AUTHOR_EMAIL":        func(cr *ChangeRequest) string { return cr.AuthorEmail },
+}
+
+// Evaluate evaluates a stage's when directive. A nil directive is always met.
+func Evaluate(w *model.When, ctx *Context) Result {
+	if w == nil {
+		return True
+	}
+	return all(w.Conditions, ctx)
+}
+
+func all(conditions []*model.StepOrNestedWhenCondition, ctx *Context) Result {
+	result := True
+	for _, c := range conditions {
+		switch Condition(c, ctx) {
+		case False:
+			return False
+		case Unknown:
+			result = Unknown
+		}
+	}
+	return result
+}
+
+// Condition evaluates a single when condition.
+func Condition(c *model.StepOrNestedWhenCondition, ctx *Context) Result {
+	switch {
+	case c == nil:
+		return True
+	case c.Step != nil:
+		// Step is only decoded when the condition isn't a nested one, so check it first.
+		return step(c.Step, ctx)
+	case c.Nested != nil:
+		return nested(c.Nested, ctx)
+	}
+	return Unknown
+}
+
+func nested(n *model.NestedWhenCondition, ctx *Context) Result {
+	switch n.Name {
+	case "allOf":
+		return all(n.Children, ctx)
+	case "anyOf":
+		result := False
+		for _, c := range n.Children {
+			switch Condition(c, ctx) {
+			case True:
+				return True
+			case Unknown:
+				result = Unknown
+			}
+		}
+		return result
+	case "not":
+		switch all(n.Children, ctx) {
+		case True:
+			return False
+		case False:
+			return True
+		}
+	}
+	return Unknown
+}
+
+// literal returns the value of a literal argument, and false if it's missing or not literal. A single unnamed
+// argument is the pattern or cause, the only conditions' default parameters.
+func literal(args *model.ArgumentList, key string) (string, bool) {
+	if args != nil && args.Single != nil && key != "pattern" && key != "cause" {
+		return "", false
+	}
+	a := args.Get(key)
+	if a == nil || !a.IsLiteral {
+		return "", false
+	}
+	return a.String(), true
+}
+
+func step(s *model.Step, ctx *Context) Result {
+	args := s.Arguments
+	switch s.Name {
+	case "branch":
+		pattern, ok := literal(args, "pattern")
+		if !ok || ctx.Branch == "" {
+			return Unknown
+		}
+		return match(comparator(args, "GLOB"), pattern, ctx.Branch, true)
+	case "buildingTag":
+		return fromBool(ctx.Tag != "")
+	case "tag":
+		pattern, ok := literal(args, "pattern")
+		if !ok {
+			if args.Get("pattern") == nil {
+				// With no pattern, tag is met by any tag.
+				return fromBool(ctx.Tag != "")
+			}
+			return Unknown
+		}
+		if ctx.Tag == "" {
+			return False
+		}
+		return match(comparator(args, "GLOB"), pattern, ctx.Tag, true)
+	case "changeset":
+		pattern, ok := literal(args, "pattern")
+		if !ok || ctx.ChangedFiles == nil {
+			return Unknown
+		}
+		caseSensitive := false
+		if v, ok := literal(args, "caseSensitive"); ok {
+			caseSensitive = v == "true"
+		}
+		result := False
+		for _, f := range ctx.ChangedFiles {
+			switch match(comparator(args, "GLOB"), pattern, f, caseSensitive) {
+			case True:
+				return True
+			case Unknown:
+				result = Unknown
+			}
+		}
+		return result
+	case "changelog":
+		pattern, ok := literal(args, "pattern")
+		if !ok || ctx.ChangeLog == nil {
+			return Unknown
+		}
+		re, err := regexp.Compile("(?s)" + pattern)
+		if err != nil {
+			return Unknown
+		}
+		for _, msg := range ctx.ChangeLog {
+			if re.MatchString(msg) {
+				return True
+			}
+		}
+		return False
+	case "changeRequest":
+		return changeRequest(args, ctx)
+	case "environment":
+		name, ok := literal(args, "name")
+		value, ok2 := literal(args, "value")
+		if !ok || !ok2 {
+			return Unknown
+		}
+		actual, known := ctx.Getenv(name)
+		if !known {
+			return Unknown
+		}
+		return fromBool(actual == value)
+	case "equals":
+		expected, ok := equalsValue(args, "expected", ctx)
+		actual, ok2 := equalsValue(args, "actual", ctx)
+		if !ok || !ok2 {
+			return Unknown
+		}
+		return fromBool(expected == actual)
+	case "triggeredBy":
+		cause, ok := literal(args, "cause")
+		if !ok || ctx.Causes == nil {
+			return Unknown
+		}
+		for _, c := range ctx.Causes {
+			if c == cause {
+				return True
+			}
+		}
+		return False
+	case "expression":
+		if v, ok := literal(args, "scriptBlock"); ok {
+			return Expression(v, ctx)
+		}
+	}
+	return Unknown
+}
+
+// equalsValue returns the value of an argument of an equals condition: a literal, or an expression Lookup resolves
+func equalsValue(args *model.ArgumentList, key string, ctx *Context) (string, bool) {
+	if v, ok := literal(args, key); ok {
+		return v, true
+	}
+	if a := args.Get(key); a != nil && args.Single == nil {
+		v, ok := value(a.String(), ctx)
+		if !ok {
+			return "", false
+		}
+		if v == nil {
+			return "", true
+		}
+		return toString(v), true
+	}
+	return "", false
+}
+
+// currentResult returns the value of currentBuild.result or currentBuild.currentResult, and false if the result
+// isn't known. currentBuild.result is null until the build's result is set to something other than SUCCESS.
+func currentResult(name string, ctx *Context) (interface{}, bool) {
+	if ctx.Result == "" {
+		return nil, false
+	}
+	if name == "currentBuild.result" && ctx.Result == "SUCCESS" {
+		return nil, true
+	}
+	return ctx.Result, true
+}
+
+// ChangeRequestCondition is the typed form of the arguments of a changeRequest condition. Attributes which aren't
+// given are empty, and match any change request.
+type ChangeRequestCondition struct {
+	ID                string `jenkins:"id"`
+	Target            string `jenkins:"target"`
+	Branch            string `jenkins:"branch"`
+	Fork              string `jenkins:"fork"`
+	URL               string `jenkins:"url"`
+	Title             string `jenkins:"title"`
+	Author            string `jenkins:"author"`
+	AuthorDisplayName string `jenkins:"authorDisplayName"`
+	AuthorEmail       string `jenkins:"authorEmail"`
+	// Comparator is how attributes are compared: EQUALS, the default, GLOB, or REGEXP.
+	Comparator string `jenkins:"comparator"`
+}
+
+// ParseChangeRequest decodes the arguments of a changeRequest condition. Arguments given by Groovy expressions other
+// than constants return an error, since their values aren't known.
+func ParseChangeRequest(args *model.ArgumentList) (*ChangeRequestCondition, error) {
+	c := &ChangeRequestCondition{}
+	if err := args.Bind(c); err != nil {
+		return nil, err
+	}
+	c.Comparator = strings.ToUpper(c.Comparator)
+	if c.Comparator == "" {
+		c.Comparator = "EQUALS"
+	}
+	return c, nil
+}
+
+// Match returns whether a change request has all of the condition's attributes. A nil change request, for builds
+// which aren't of one, never matches.
+func (c *ChangeRequestCondition) Match(cr *ChangeRequest) Result {
+	if cr == nil {
+		return False
+	}
+	attributes := []struct{ pattern, value string }{
+		{c.ID, cr.ID}, {c.Target, cr.Target}, {c.Branch, cr.Branch}, {c.Fork, cr.Fork}, {c.URL, cr.URL},
+		{c.Title, cr.Title}, {c.Author, cr.Author}, {c.AuthorDisplayName, cr.AuthorDisplayName},
+		{c.AuthorEmail, cr.AuthorEmail},
+	}
+	for _, a := range attributes {
+		if a.pattern == "" {
+			continue
+		}
+		if r := match(c.Comparator, a.pattern, a.value, true); r != True {
+			return r
+		}
+	}
+	return True
+}
+
+func changeRequest(args *model.ArgumentList, ctx *Context) Result {
+	if ctx.ChangeRequest == nil && ctx.Branch == "" {
+		return Unknown
+	}
+	c, err := ParseChangeRequest(args)
+	if err != nil {
+		return Unknown
+	}
+	return c.Match(ctx.ChangeRequest)
+}
+
+func comparator(args *model.ArgumentList, def string) string {
+	if c, ok := literal(args, "comparator"); ok {
+		return strings.ToUpper(c)
+	}
+	return def
+}
+
+// match compares a value with a pattern using one of the when condition comparators: EQUALS, GLOB, or REGEXP.
+func match(comparator string, pattern string, value string, caseSensitive bool) Result {
+	if !caseSensitive {
+		pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	}
+	switch comparator {
+	case "EQUALS":
+		return fromBool(pattern == value)
+	case "REGEXP":
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return Unknown
+		}
+		return fromBool(re.MatchString(value))
+	case "GLOB":
+		return fromBool(Glob(pattern, value))
+	}
+	return Unknown
+}
+
+// Glob matches a path against an Ant-style pattern, as Jenkins does for branch, tag, and changeset conditions: "*"
+// and "?" match within a path segment, and "**" matches any number of segments.
+func Glob(pattern string, path string) bool {
+	return globSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func globSegments(pattern []string, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if globSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 || !globSegment(pattern[0], path[0]) {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func globSegment(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := 0; i <= len(s); i++ {
+				if globSegment(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
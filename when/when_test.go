@@ -0,0 +1,142 @@
+package when
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func condition(t *testing.T, src string) *model.When {
+	w := &model.When{}
+	require.NoError(t, json.Unmarshal([]byte(`{"conditions": [`+src+`]}`), w))
+	return w
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := &Context{
+		Branch:        "feature/login",
+		ChangedFiles:  []string{"src/Main.java", "docs/README.md"},
+		ChangeLog:     []string{"Fix login\n\n[ci skip]"},
+		Env:           map[string]string{"DEPLOY": "true"},
+		Causes:        []string{"UserIdCause"},
+		ChangeRequest: &ChangeRequest{ID: "42", Target: "main", Author: "octocat"},
+	}
+	tests := []struct {
+		name      string
+		condition string
+		expected  Result
+	}{
+		{"branch glob", `{"name": "branch", "arguments": {"isLiteral": true, "value": "feature/*"}}`, True},
+		{"branch no match", `{"name": "branch", "arguments": {"isLiteral": true, "value": "main"}}`, False},
+		{"branch regexp", `{"name": "branch", "arguments": [{"key": "pattern", "value": {"isLiteral": true, "value": "feat.*"}},
+			{"key": "comparator", "value": {"isLiteral": true, "value": "REGEXP"}}]}`, True},
+		{"changeset", `{"name": "changeset", "arguments": {"isLiteral": true, "value": "**/*.java"}}`, True},
+		{"changeset case", `{"name": "changeset", "arguments": [{"key": "pattern", "value": {"isLiteral": true, "value": "**/*.JAVA"}},
+			{"key": "caseSensitive", "value": {"isLiteral": true, "value": true}}]}`, False},
+		{"changelog", `{"name": "changelog", "arguments": {"isLiteral": true, "value": ".*\\[ci skip\\].*"}}`, True},
+		{"environment", `{"name": "environment", "arguments": [{"key": "name", "value": {"isLiteral": true, "value": "DEPLOY"}},
+			{"key": "value", "value": {"isLiteral": true, "value": "true"}}]}`, True},
+		{"triggeredBy", `{"name": "triggeredBy", "arguments": {"isLiteral": true, "value": "TimerTrigger"}}`, False},
+		{"changeRequest", `{"name": "changeRequest", "arguments": [{"key": "target", "value": {"isLiteral": true, "value": "main"}}]}`, True},
+		{"tag", `{"name": "buildingTag", "arguments": []}`, False},
+		{"expression", `{"name": "expression", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true, "value": "return isRelease()"}}]}`, Unknown},
+		{"expression env", `{"name": "expression", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true,
+			"value": "return env.DEPLOY.toBoolean() && BRANCH_NAME ==~ /feature\\/.*/"}}]}`, True},
+		{"expression false", `{"name": "expression", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true, "value": "false"}}]}`, False},
+		{"anyOf unknown", `{"name": "anyOf", "children": [
+			{"name": "branch", "arguments": {"isLiteral": true, "value": "main"}},
+			{"name": "expression", "arguments": [{"key": "scriptBlock", "value": {"isLiteral": true, "value": "isRelease()"}}]}]}`, Unknown},
+		{"not", `{"name": "not", "children": [{"name": "branch", "arguments": {"isLiteral": true, "value": "main"}}]}`, True},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Evaluate(condition(t, tc.condition), ctx))
+		})
+	}
+}
+
+func TestEvaluateUnknownContext(t *testing.T) {
+	w := condition(t, `{"name": "changeset", "arguments": {"isLiteral": true, "value": "**/*.java"}}`)
+	assert.Equal(t, Unknown, Evaluate(w, &Context{}))
+	assert.Equal(t, False, Evaluate(w, &Context{ChangedFiles: []string{}}))
+	assert.Equal(t, True, Evaluate(nil, &Context{}))
+}
+
+func TestEvaluateResult(t *testing.T) {
+	equals := condition(t, `{"name": "equals", "arguments": [{"key": "expected", "value": {"isLiteral": true, "value": "UNSTABLE"}},
+		{"key": "actual", "value": {"isLiteral": false, "value": "currentBuild.result"}}]}`)
+	expression := condition(t, `{"name": "expression", "arguments": [{"key": "scriptBlock",
+		"value": {"isLiteral": true, "value": "return currentBuild.currentResult != 'SUCCESS'"}}]}`)
+
+	assert.Equal(t, Unknown, Evaluate(equals, &Context{}))
+	assert.Equal(t, Unknown, Evaluate(expression, &Context{}))
+	assert.Equal(t, True, Evaluate(equals, &Context{Result: "UNSTABLE"}))
+	assert.Equal(t, True, Evaluate(expression, &Context{Result: "UNSTABLE"}))
+	assert.Equal(t, False, Evaluate(equals, &Context{Result: "SUCCESS"}))
+	assert.Equal(t, False, Evaluate(expression, &Context{Result: "SUCCESS"}))
+}
+
+func TestChangeRequest(t *testing.T) {
+	pr := &ChangeRequest{ID: "42", Target: "main", Branch: "fix/login", Title: "WIP: fix login", Author: "octocat"}
+	tests := []struct {
+		name      string
+		condition string
+		expected  Result
+	}{
+		{"any", `{"name": "changeRequest", "arguments": []}`, True},
+		{"author and target", `{"name": "changeRequest", "arguments": [
+			{"key": "author", "value": {"isLiteral": true, "value": "octocat"}},
+			{"key": "target", "value": {"isLiteral": true, "value": "release"}}]}`, False},
+		{"title glob", `{"name": "changeRequest", "arguments": [{"key": "title", "value": {"isLiteral": true, "value": "WIP:*"}},
+			{"key": "comparator", "value": {"isLiteral": true, "value": "glob"}}]}`, True},
+		{"branch regexp", `{"name": "changeRequest", "arguments": [{"key": "branch", "value": {"isLiteral": true, "value": "fix/.*"}},
+			{"key": "comparator", "value": {"isLiteral": true, "value": "REGEXP"}}]}`, True},
+		{"constant expression", `{"name": "changeRequest", "arguments": [{"key": "target", "value": {"isLiteral": false, "value": "'main'"}}]}`, True},
+		{"variable", `{"name": "changeRequest", "arguments": [{"key": "target", "value": {"isLiteral": false, "value": "env.TARGET"}}]}`, Unknown},
+		{"change variable", `{"name": "environment", "arguments": [{"key": "name", "value": {"isLiteral": true, "value": "CHANGE_TARGET"}},
+			{"key": "value", "value": {"isLiteral": true, "value": "main"}}]}`, True},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Evaluate(condition(t, tc.condition), &Context{Branch: "PR-42", ChangeRequest: pr}))
+		})
+	}
+
+	w := condition(t, tests[0].condition)
+	assert.Equal(t, False, Evaluate(w, &Context{Branch: "main"}))
+	assert.Equal(t, Unknown, Evaluate(w, &Context{}))
+
+	c, err := ParseChangeRequest(nil)
+	require.NoError(t, err)
+	assert.Equal(t, &ChangeRequestCondition{Comparator: "EQUALS"}, c)
+}
+
+func TestGetenv(t *testing.T) {
+	ctx := &Context{Branch: "PR-7", ChangeRequest: &ChangeRequest{ID: "7", AuthorEmail: "a@example.com"}}
+	v, ok := ctx.Getenv("CHANGE_AUTHOR_EMAIL")
+	assert.True(t, ok)
+	assert.Equal(t, "a@example.com", v)
+	v, _ = ctx.Getenv("BRANCH_NAME")
+	assert.Equal(t, "PR-7", v)
+	_, ok = ctx.Getenv("DEPLOY")
+	assert.False(t, ok)
+
+	ctx.Env = map[string]string{"CHANGE_ID": "override"}
+	v, _ = ctx.Getenv("CHANGE_ID")
+	assert.Equal(t, "override", v)
+	v, ok = ctx.Getenv("DEPLOY")
+	assert.True(t, ok)
+	assert.Empty(t, v)
+}
+
+func TestGlob(t *testing.T) {
+	assert.True(t, Glob("release-*", "release-1.0"))
+	assert.False(t, Glob("release-*", "release/1.0"))
+	assert.True(t, Glob("**/*.go", "main.go"))
+	assert.True(t, Glob("src/**/test/*.go", "src/a/b/test/x.go"))
+	assert.True(t, Glob("v?.*", "v1.2"))
+	assert.False(t, Glob("docs/**", "src/docs/a.md"))
+}
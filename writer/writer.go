@@ -0,0 +1,586 @@
+// Package writer renders pipeline models as Declarative Jenkinsfiles, for tools which change a pipeline and write it
+// back to its repository. Parsing what it writes with the parser package gives back the same model.
+package writer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/abayer/go-jenkinsfile/groovyq"
+	"github.com/abayer/go-jenkinsfile/internal/groovymodel"
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+)
+
+// Indent is the indentation of each level of blocks
+const Indent = "    "
+
+// identifier matches the names which can be map keys without quoting
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Write renders the pipeline as a Declarative Jenkinsfile. Sections are written in a conventional order, with
+// literal strings single-quoted, non-literal values as their Groovy source, and raw Groovy steps (see model.RawStep)
+// verbatim. Stages' dependsOn and annotations have no Groovy syntax, so they're left out.
+func Write(root *model.Root, w io.Writer) error {
+	if root == nil || root.Pipeline == nil {
+		return errors.New("a root with a pipeline is required")
+	}
+	p := &printer{indent: Indent}
+	p.pipeline(root.Pipeline)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// Rewrite renders the pipeline like Write, but reuses the original text of the stages, steps, post conditions, and
+// sections which haven't changed since the pipeline was parsed from src, with their formatting and comments, and keeps
+// what was around the pipeline block, so that automated edits make small diffs. Nodes which were replaced rather than
+// changed in place, such as stages replaced by copies, are rendered anew.
+func Rewrite(root *model.Root, src *parser.Source, w io.Writer) error {
+	if root == nil || root.Pipeline == nil {
+		return errors.New("a root with a pipeline is required")
+	}
+	p := &printer{src: src, indent: src.Indent()}
+	before, after, _ := src.Surrounding(root.Pipeline)
+	p.buf.WriteString(before)
+	p.pipeline(root.Pipeline)
+	p.buf.WriteString(after)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// printer accumulates the Jenkinsfile, and the first error, which stops nothing but is returned by Write
+type printer struct {
+	buf    bytes.Buffer
+	depth  int
+	indent string
+	err    error
+	// src is the source to reuse the text of unchanged nodes from, if any.
+	src *parser.Source
+}
+
+// line writes a line at the current indentation
+func (p *printer) line(format string, args ...interface{}) {
+	if p.indent == "" {
+		p.indent = Indent
+	}
+	p.buf.WriteString(strings.Repeat(p.indent, p.depth))
+	fmt.Fprintf(&p.buf, format, args...)
+	p.buf.WriteByte('\n')
+}
+
+// block writes "head {", the block's contents, and "}"
+func (p *printer) block(head string, contents func()) {
+	p.line("%s {", head)
+	p.depth++
+	contents()
+	p.depth--
+	p.line("}")
+}
+
+// original writes the original text of a node if it's unchanged, returning false if it isn't, after writing the
+// comments which were above it, for the node to be rendered after them
+func (p *printer) original(node interface{}, section string) bool {
+	text, ok := p.reusable(node, section)
+	if !ok {
+		p.buf.WriteString(p.leading(node, section))
+		return false
+	}
+	p.buf.WriteString(text)
+	p.buf.WriteByte('\n')
+	return true
+}
+
+// section writes a section of a node with render, unless its original text can be reused. The comments which were
+// above it are kept unless render writes nothing, as when the section was removed.
+func (p *printer) section(node interface{}, name string, render func()) {
+	if text, ok := p.reusable(node, name); ok {
+		p.buf.WriteString(text)
+		p.buf.WriteByte('\n')
+		return
+	}
+	mark := p.buf.Len()
+	render()
+	if leading := p.leading(node, name); leading != "" && p.buf.Len() > mark {
+		rendered := append([]byte(leading), p.buf.Bytes()[mark:]...)
+		p.buf.Truncate(mark)
+		p.buf.Write(rendered)
+	}
+}
+
+// reusable returns the original text of a node or section if it's unchanged and at the depth it was parsed at. Text
+// moved to another depth, such as a step wrapped in a retry, would be misindented, so it's rendered anew.
+func (p *printer) reusable(node interface{}, section string) (string, bool) {
+	text, ok := p.src.Original(node, section)
+	if !ok {
+		return "", false
+	}
+	indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+	if indent != strings.Repeat(p.indent, p.depth) {
+		return "", false
+	}
+	return text, true
+}
+
+// leading returns the comment lines which were above a node or section, indented to the current depth
+func (p *printer) leading(node interface{}, section string) string {
+	comments := p.src.Leading(node, section)
+	if comments == "" {
+		return ""
+	}
+	var out strings.Builder
+	for _, l := range strings.SplitAfter(comments, "\n") {
+		if l != "" {
+			out.WriteString(strings.Repeat(p.indent, p.depth) + strings.TrimLeft(l, " \t"))
+		}
+	}
+	return out.String()
+}
+
+func (p *printer) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (p *printer) pipeline(pl *model.Pipeline) {
+	if p.original(pl, "") {
+		return
+	}
+	p.block("pipeline", func() {
+		p.section(pl, "libraries", func() {
+			if pl.Libraries != nil && len(pl.Libraries.Libraries) > 0 {
+				p.block("libraries", func() {
+					for _, l := range pl.Libraries.Libraries {
+						p.line("lib(%s)", value(l))
+					}
+				})
+			}
+		})
+		p.directives(pl, pl.Agent, pl.Environment, pl.Tools, pl.Options)
+		p.section(pl, "parameters", func() {
+			if pl.Parameters != nil {
+				p.methodCalls("parameters", pl.Parameters.Parameters)
+			}
+		})
+		p.section(pl, "triggers", func() {
+			if pl.Triggers != nil {
+				p.methodCalls("triggers", pl.Triggers.Triggers)
+			}
+		})
+		p.stages("stages", pl.Stages)
+		p.section(pl, "post", func() { p.post(pl.Post) })
+	})
+}
+
+// directives writes the agent, environment, tools, and options of a pipeline, stage, or matrix
+func (p *printer) directives(node interface{}, agent *model.Agent, env []*model.EnvironmentEntry,
+	tools []*model.ArgumentValue, options *model.Options) {
+	p.section(node, "agent", func() { p.agent(agent) })
+	p.section(node, "environment", func() { p.environment(env) })
+	p.section(node, "tools", func() { p.tools(tools) })
+	p.section(node, "options", func() {
+		if options != nil {
+			p.methodCalls("options", options.Options)
+		}
+	})
+}
+
+func (p *printer) agent(a *model.Agent) {
+	switch {
+	case a == nil:
+	case a.Argument == nil && len(a.Arguments) == 0 && (a.Type == "any" || a.Type == "none"):
+		p.line("agent %s", a.Type)
+	case a.Argument != nil:
+		p.line("agent { %s %s }", a.Type, value(a.Argument))
+	case len(a.Arguments) == 0:
+		p.line("agent { %s }", a.Type)
+	default:
+		p.block("agent", func() {
+			p.block(a.Type, func() {
+				p.mapArguments(a.Arguments)
+			})
+		})
+	}
+}
+
+func (p *printer) mapArguments(args []*model.MapArgumentValue) {
+	for _, a := range args {
+		switch {
+		case a == nil || a.Value == nil:
+		case a.Value.Raw != nil:
+			p.line("%s %s", a.Key, value(a.Value.Raw))
+		default:
+			p.block(a.Key, func() {
+				p.mapArguments(a.Value.List)
+			})
+		}
+	}
+}
+
+func (p *printer) environment(env []*model.EnvironmentEntry) {
+	if len(env) == 0 {
+		return
+	}
+	p.block("environment", func() {
+		for _, e := range env {
+			if e != nil {
+				p.line("%s = %s", e.Key, e.Value.Source())
+			}
+		}
+	})
+}
+
+func (p *printer) tools(tools []*model.ArgumentValue) {
+	if len(tools) == 0 {
+		return
+	}
+	p.block("tools", func() {
+		for _, t := range tools {
+			if t != nil {
+				p.line("%s %s", t.Key, value(t.Value))
+			}
+		}
+	})
+}
+
+// methodCalls writes an options, parameters, or triggers block
+func (p *printer) methodCalls(section string, calls []*model.MethodCall) {
+	if len(calls) == 0 {
+		return
+	}
+	p.block(section, func() {
+		for _, c := range calls {
+			if c != nil {
+				p.line("%s", methodCall(c))
+			}
+		}
+	})
+}
+
+func (p *printer) stages(section string, stages []*model.Stage) {
+	p.block(section, func() {
+		for _, s := range stages {
+			if s != nil {
+				p.stage(s)
+			}
+		}
+	})
+}
+
+func (p *printer) stage(s *model.Stage) {
+	if p.original(s, "") {
+		return
+	}
+	p.block(fmt.Sprintf("stage(%s)", groovyq.Quote(s.Name)), func() {
+		p.directives(s, s.Agent, s.Environment, s.Tools, s.Options)
+		p.section(s, "when", func() { p.when(s.When) })
+		p.section(s, "input", func() { p.input(s.Input) })
+		if len(s.Branches) < 2 {
+			p.section(s, "failFast", func() {
+				if s.FailFast {
+					p.line("failFast true")
+				}
+			})
+		}
+		switch {
+		case len(s.Branches) == 1:
+			p.section(s, "steps", func() {
+				p.block("steps", func() {
+					p.steps(s.Branches[0].Steps)
+				})
+			})
+		case len(s.Branches) > 1:
+			p.section(s, "steps", func() {
+				p.block("steps", func() {
+					p.parallel(s)
+				})
+			})
+		case len(s.Stages) > 0:
+			p.stages("stages", s.Stages)
+		case len(s.Parallel) > 0:
+			p.stages("parallel", s.Parallel)
+		case s.Matrix != nil:
+			p.matrix(s.Matrix)
+		default:
+			p.fail("stage %q has no steps, stages, parallel, or matrix", s.Name)
+		}
+		p.section(s, "post", func() { p.post(s.Post) })
+	})
+}
+
+// parallel writes the branches of a stage as a parallel step, the form Jenkins reads them from
+func (p *printer) parallel(s *model.Stage) {
+	p.line("parallel(")
+	p.depth++
+	for i, b := range s.Branches {
+		key := b.Name
+		if !identifier.MatchString(key) {
+			key = groovyq.Quote(key)
+		}
+		p.line("%s: {", key)
+		p.depth++
+		p.steps(b.Steps)
+		p.depth--
+		if i < len(s.Branches)-1 || s.FailFast {
+			p.line("},")
+		} else {
+			p.line("}")
+		}
+	}
+	if s.FailFast {
+		p.line("failFast: true")
+	}
+	p.depth--
+	p.line(")")
+}
+
+func (p *printer) matrix(m *model.Matrix) {
+	if p.original(m, "") {
+		return
+	}
+	p.block("matrix", func() {
+		p.directives(m, m.Agent, m.Environment, m.Tools, m.Options)
+		p.section(m, "when", func() { p.when(m.When) })
+		p.section(m, "input", func() { p.input(m.Input) })
+		p.section(m, "axes", func() { p.axes(m.Axes) })
+		p.section(m, "excludes", func() { p.excludes(m.Excludes) })
+		p.stages("stages", m.Stages)
+		p.section(m, "post", func() { p.post(m.Post) })
+	})
+}
+
+func (p *printer) axes(axes []*model.Axis) {
+	p.block("axes", func() {
+		for _, a := range axes {
+			if a != nil {
+				p.axis(a.Name, "values", a.Values)
+			}
+		}
+	})
+}
+
+func (p *printer) excludes(excludes [][]*model.ExcludeAxis) {
+	if len(excludes) == 0 {
+		return
+	}
+	p.block("excludes", func() {
+		for _, e := range excludes {
+			p.block("exclude", func() {
+				for _, a := range e {
+					if a == nil || a.Name == nil {
+						continue
+					}
+					field := "values"
+					if a.Inverse != nil && *a.Inverse {
+						field = "notValues"
+					}
+					p.axis(*a.Name, field, a.Values)
+				}
+			})
+		}
+	})
+}
+
+func (p *printer) axis(name string, field string, values []*model.RawArgument) {
+	p.block("axis", func() {
+		p.line("name %s", groovyq.Quote(name))
+		p.line("%s %s", field, valueList(values))
+	})
+}
+
+func (p *printer) input(in *model.Input) {
+	if in == nil {
+		return
+	}
+	p.block("input", func() {
+		for _, f := range []struct {
+			name  string
+			value *model.RawArgument
+		}{{"message", in.Message}, {"id", in.ID}, {"ok", in.Ok}, {"submitter", in.Submitter},
+			{"submitterParameter", in.SubmitterParameter}} {
+			if f.value != nil {
+				p.line("%s %s", f.name, value(f.value))
+			}
+		}
+		if in.Parameters != nil {
+			p.methodCalls("parameters", in.Parameters.Parameters)
+		}
+	})
+}
+
+func (p *printer) when(w *model.When) {
+	if w == nil {
+		return
+	}
+	p.block("when", func() {
+		if w.BeforeAgent {
+			p.line("beforeAgent true")
+		}
+		if w.BeforeInput {
+			p.line("beforeInput true")
+		}
+		if w.BeforeOptions {
+			p.line("beforeOptions true")
+		}
+		p.whenConditions(w.Conditions)
+	})
+}
+
+func (p *printer) whenConditions(conditions []*model.StepOrNestedWhenCondition) {
+	for _, c := range conditions {
+		switch {
+		case c == nil:
+		case c.Step != nil && c.Step.Name == "expression":
+			p.closure("expression", c.Step.Arguments.Get("scriptBlock").String())
+		case c.Step != nil:
+			p.step(c.Step)
+		case c.Nested != nil:
+			p.block(c.Nested.Name, func() {
+				p.whenConditions(c.Nested.Children)
+			})
+		}
+	}
+}
+
+func (p *printer) post(post *model.Post) {
+	if post == nil || len(post.Conditions) == 0 {
+		return
+	}
+	p.block("post", func() {
+		for _, c := range post.Conditions {
+			if c == nil || p.original(c, "") {
+				continue
+			}
+			p.block(c.Condition, func() {
+				if c.Branch != nil {
+					p.steps(c.Branch.Steps)
+				}
+			})
+		}
+	})
+}
+
+func (p *printer) steps(steps []*model.AnyStep) {
+	for _, s := range steps {
+		switch {
+		case s == nil || p.original(s, ""):
+		case s.Step != nil:
+			p.step(s.Step)
+		case s.Tree != nil:
+			head := s.Tree.Name
+			if args := arguments(s.Tree.Arguments); args != "" {
+				head += "(" + args + ")"
+			}
+			p.block(head, func() {
+				p.steps(s.Tree.Children)
+			})
+		}
+	}
+}
+
+func (p *printer) step(s *model.Step) {
+	switch {
+	case s.Raw != nil:
+		p.line("%s", s.Raw.Source)
+		return
+	case s.Name == "script" && s.Arguments != nil && len(s.Arguments.Named) == 1 &&
+		s.Arguments.Named[0].Key == "scriptBlock":
+		p.closure("script", s.Arguments.Named[0].Value.String())
+		return
+	}
+	args := s.Arguments
+	if args != nil && len(args.Named) == 1 && args.Named[0] != nil &&
+		args.Named[0].Key == groovymodel.DefaultParameter(s.Name) {
+		// A step's default parameter doesn't need naming, as in sh 'make' rather than sh script: 'make'.
+		args = &model.ArgumentList{Single: args.Named[0].Value}
+	}
+	switch {
+	case args == nil || args.Single == nil && len(args.Named) == 0 && len(args.Positional) == 0:
+		p.line("%s()", s.Name)
+	case len(args.Positional) > 0:
+		p.line("%s(%s)", s.Name, arguments(args))
+	default:
+		p.line("%s %s", s.Name, arguments(args))
+	}
+}
+
+// closure writes a block of Groovy source, such as a script step's. Its lines after the first are written as they
+// are, since they may be inside multiline strings.
+func (p *printer) closure(name string, source string) {
+	p.block(name, func() {
+		p.line("%s", source)
+	})
+}
+
+// arguments renders an argument list, without parentheses
+func arguments(args *model.ArgumentList) string {
+	if args == nil {
+		return ""
+	}
+	switch {
+	case args.Single != nil:
+		return value(args.Single)
+	case len(args.Positional) > 0:
+		return valueList(args.Positional)
+	}
+	parts := make([]string, 0, len(args.Named))
+	for _, a := range args.Named {
+		if a != nil {
+			parts = append(parts, a.Key+": "+value(a.Value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func methodCall(c *model.MethodCall) string {
+	parts := make([]string, 0, len(c.Arguments))
+	for _, a := range c.Arguments {
+		switch {
+		case a == nil:
+		case a.Single != nil:
+			parts = append(parts, valueOrMethodCall(a.Single))
+		case a.WithKey != nil:
+			parts = append(parts, a.WithKey.Key+": "+valueOrMethodCall(a.WithKey.Value))
+		}
+	}
+	return c.Name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func valueOrMethodCall(v *model.ValueOrMethodCall) string {
+	switch {
+	case v == nil:
+		return "null"
+	case v.Call != nil:
+		return methodCall(v.Call)
+	}
+	return value(v.Single)
+}
+
+// value renders an argument: literal strings quoted, other literals as they are, and anything else as its source
+func value(a *model.RawArgument) string {
+	switch {
+	case a == nil || a.Value == nil:
+		return "null"
+	case a.IsLiteral && a.Value.AsString != nil:
+		return groovyq.Quote(*a.Value.AsString)
+	}
+	return a.Value.String()
+}
+
+func valueList(args []*model.RawArgument) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = value(a)
+	}
+	return strings.Join(parts, ", ")
+}
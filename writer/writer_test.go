@@ -0,0 +1,324 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abayer/go-jenkinsfile/model"
+	"github.com/abayer/go-jenkinsfile/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lossyDecoding are the pipelines in the corpus whose method call and positional arguments lose their values when
+// decoded, so they can't be written as they were.
+var lossyDecoding = map[string]bool{
+	"libraries/globalLibrarySuccess.json": true,
+	"multipleWrappers.json":               true,
+	"options/simpleJobProperties.json":    true,
+	"options/simpleWrapper.json":          true,
+	"parametersInInput.json":              true,
+	"simpleParameters.json":               true,
+	"simpleTriggers.json":                 true,
+	"stageWrapper.json":                   true,
+	"when/whenBeforeInputFalse.json":      true,
+}
+
+// TestCorpus writes each pipeline in the model package's corpus, parses what was written, and compares the JSON of the
+// two.
+func TestCorpus(t *testing.T) {
+	dir := filepath.Join("..", "model", "testdata", "json")
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if lossyDecoding[filepath.ToSlash(rel)] {
+			return nil
+		}
+		t.Run(rel, func(t *testing.T) {
+			contents, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			root := &model.Root{}
+			require.NoError(t, json.Unmarshal(contents, root))
+			expected, err := json.Marshal(root)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, Write(root, &buf))
+			written, err := parser.Parse(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err, buf.String())
+			actual, err := json.Marshal(written)
+			require.NoError(t, err)
+			assert.Equal(t, withoutFalse(t, expected), withoutFalse(t, actual), buf.String())
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestRoundTrip parses each Jenkinsfile in the parser package's tests, writes it, and parses what was written, which
+// should give the same model, and be written the same way again.
+func TestRoundTrip(t *testing.T) {
+	dir := filepath.Join("..", "parser", "testdata")
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".groovy") {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		t.Run(rel, func(t *testing.T) {
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+			root, err := parser.Parse(f)
+			require.NoError(t, err)
+
+			var first, second bytes.Buffer
+			require.NoError(t, Write(root, &first))
+			written, err := parser.Parse(bytes.NewReader(first.Bytes()))
+			require.NoError(t, err, first.String())
+			model.ClearUIDs(root)
+			model.ClearUIDs(written)
+			assert.Equal(t, root, written, first.String())
+			require.NoError(t, Write(written, &second))
+			assert.Equal(t, first.String(), second.String())
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	src := `pipeline {
+  agent { docker { image 'maven:3.9'; args '-v /tmp:/tmp' } }
+  environment { TOKEN = credentials('token'); GREETING = "hello ${env.USER}" }
+  options { timeout(time: 1, unit: 'HOURS') }
+  stages {
+    stage('Build') {
+      when { allOf { branch 'main'; expression { return true } } }
+      steps {
+        sh 'make'
+        sh script: 'make test', returnStatus: true
+        dir('sub') { echo "in ${pwd()}" }
+        script {
+          def x = 1
+        }
+        currentBuild.description = 'built'
+      }
+    }
+    stage('Both') {
+      steps {
+        parallel(first: { echo 'one' }, 'second one': { echo 'two' }, failFast: true)
+      }
+    }
+  }
+  post { always { junit '**/*.xml' } }
+}`
+	root, err := parser.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, Write(root, &buf))
+	assert.Equal(t, `pipeline {
+    agent {
+        docker {
+            image 'maven:3.9'
+            args '-v /tmp:/tmp'
+        }
+    }
+    environment {
+        TOKEN = credentials('token')
+        GREETING = "hello ${env.USER}"
+    }
+    options {
+        timeout(time: 1, unit: 'HOURS')
+    }
+    stages {
+        stage('Build') {
+            when {
+                allOf {
+                    branch 'main'
+                    expression {
+                        return true
+                    }
+                }
+            }
+            steps {
+                sh 'make'
+                sh script: 'make test', returnStatus: true
+                dir('sub') {
+                    echo "in ${pwd()}"
+                }
+                script {
+                    def x = 1
+                }
+                currentBuild.description = 'built'
+            }
+        }
+        stage('Both') {
+            steps {
+                parallel(
+                    first: {
+                        echo 'one'
+                    },
+                    'second one': {
+                        echo 'two'
+                    },
+                    failFast: true
+                )
+            }
+        }
+    }
+    post {
+        always {
+            junit '**/*.xml'
+        }
+    }
+}
+`, buf.String())
+
+	assert.EqualError(t, Write(&model.Root{}, &buf), "a root with a pipeline is required")
+	assert.EqualError(t, Write(&model.Root{Pipeline: &model.Pipeline{Stages: []*model.Stage{{Name: "Empty"}}}}, &buf),
+		`stage "Empty" has no steps, stages, parallel, or matrix`)
+}
+
+func TestRewrite(t *testing.T) {
+	src := `@Library('utils') _
+
+// Builds and tests the service
+pipeline {
+  agent { label 'linux' }
+  stages {
+    // Compiles everything
+    stage("Build") {
+      steps {
+        sh "make"   // the default target
+        sh 'make docs'
+      }
+    }
+    stage('Test') {
+      steps { sh 'make test' }
+    }
+  }
+}
+`
+	root, source, err := parser.ParseSource(strings.NewReader(src))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, Rewrite(root, source, &buf))
+	assert.Equal(t, src, buf.String())
+
+	docs := root.Pipeline.Stages[0].Branches[0].Steps[1].Step
+	docs.Arguments.Named[0].Value.Value.AsString = stringPtr("make site")
+	root.Pipeline.Stages = append(root.Pipeline.Stages, &model.Stage{Name: "Deploy", Branches: []*model.Branch{{
+		Name: "default", Steps: []*model.AnyStep{{Step: &model.Step{Name: "echo", Arguments: &model.ArgumentList{
+			Single: &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsString: stringPtr("hi")}}}}}},
+	}}})
+	buf.Reset()
+	require.NoError(t, Rewrite(root, source, &buf))
+	assert.Equal(t, `@Library('utils') _
+
+// Builds and tests the service
+pipeline {
+  agent { label 'linux' }
+  stages {
+    // Compiles everything
+    stage('Build') {
+      steps {
+        sh "make"   // the default target
+        sh 'make site'
+      }
+    }
+    stage('Test') {
+      steps { sh 'make test' }
+    }
+    stage('Deploy') {
+      steps {
+        echo 'hi'
+      }
+    }
+  }
+}
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, Rewrite(root, nil, &buf))
+	var written bytes.Buffer
+	require.NoError(t, Write(root, &written))
+	assert.Equal(t, written.String(), buf.String())
+}
+
+func TestRewriteMovedStep(t *testing.T) {
+	src := `pipeline {
+  agent any
+  stages {
+    stage('Deploy') {
+      steps {
+        // Applies the plan
+        sh 'terraform apply'
+      }
+    }
+  }
+}
+`
+	root, source, err := parser.ParseSource(strings.NewReader(src))
+	require.NoError(t, err)
+	branch, two := root.Pipeline.Stages[0].Branches[0], int64(2)
+	branch.Steps = []*model.AnyStep{model.NewAnyTree(&model.TreeStep{Name: "retry", Arguments: &model.ArgumentList{
+		Single: &model.RawArgument{IsLiteral: true, Value: &model.RawArgumentValue{AsInteger: &two}}},
+		Children: branch.Steps})}
+	var buf bytes.Buffer
+	require.NoError(t, Rewrite(root, source, &buf))
+	// The step is a level deeper, so it's rendered anew rather than reused at its old indentation.
+	assert.Equal(t, `pipeline {
+  agent any
+  stages {
+    stage('Deploy') {
+      steps {
+        retry(2) {
+          // Applies the plan
+          sh 'terraform apply'
+        }
+      }
+    }
+  }
+}
+`, buf.String())
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// alwaysMarshaled are the boolean fields models marshal even when false
+var alwaysMarshaled = []string{"failFast", "beforeAgent", "beforeInput", "beforeOptions"}
+
+// withoutFalse decodes JSON, dropping the fields models always marshal when they're false
+func withoutFalse(t *testing.T, b []byte) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal(b, &v))
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, k := range alwaysMarshaled {
+				if v[k] == false {
+					delete(v, k)
+				}
+			}
+			for _, e := range v {
+				visit(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				visit(e)
+			}
+		}
+	}
+	visit(v)
+	return v
+}